@@ -3,6 +3,7 @@ package job
 import (
 	"context"
 	"github.com/SeanZhenggg/go-utils/logger"
+	"jaystar/internal/config"
 	"jaystar/internal/controller/job"
 	"jaystar/internal/controller/job/middleware"
 	"jaystar/internal/cronjob"
@@ -10,6 +11,8 @@ import (
 	"log"
 )
 
+const defaultSemesterSettlementSpec = "0 0 4 * * *"
+
 type IJob interface {
 	Init()
 	Start()
@@ -20,14 +23,16 @@ type Job struct {
 	cronJob *cronjob.CronJob `wire:"-"`
 	ctrl    *job.JobController
 	mw      middleware.IJobMiddleware
+	cfg     config.IConfigEnv
 	logger  logger.ILogger
 }
 
-func ProvideJob(ctrl *job.JobController, mw middleware.IJobMiddleware) IJob {
+func ProvideJob(ctrl *job.JobController, mw middleware.IJobMiddleware, cfg config.IConfigEnv) IJob {
 	return &Job{
 		cronJob: cronjob.ProvideCronJob(),
 		ctrl:    ctrl,
 		mw:      mw,
+		cfg:     cfg,
 	}
 }
 
@@ -35,10 +40,24 @@ func (j *Job) Init() {
 	j.cronJob.Use(j.mw.Handle)
 
 	if !osUtil.IsLocal() {
-		err := j.cronJob.AddSchedule("0 0 4 * * *", j.ctrl.SemesterSettlement)
+		settlementSpec := j.cfg.GetJobConfig().SemesterSettlementSpec
+		if settlementSpec == "" {
+			settlementSpec = defaultSemesterSettlementSpec
+		}
+		err := j.cronJob.AddSchedule(settlementSpec, j.ctrl.SemesterSettlement)
+		if err != nil {
+			log.Fatalf("cron job AddSchedule failed: %v", err)
+		}
+		err = j.cronJob.AddSchedule("0 */5 * * * *", j.ctrl.IncrementalSync)
 		if err != nil {
 			log.Fatalf("cron job AddSchedule failed: %v", err)
 		}
+		if j.cfg.GetJobConfig().Reconciliation {
+			err = j.cronJob.AddSchedule("0 30 3 * * *", j.ctrl.Reconciliation)
+			if err != nil {
+				log.Fatalf("cron job AddSchedule failed: %v", err)
+			}
+		}
 	}
 }
 
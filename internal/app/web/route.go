@@ -1,68 +1,123 @@
 package web
 
 import (
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
-	"jaystar/internal/constant/user"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/controller/web/middleware"
 	"net/http"
 )
 
-var (
-	authKey    = []byte{244, 195, 59, 48, 142, 194, 159, 37, 55, 24, 137, 251, 140, 115, 249, 129, 177, 108, 172, 184, 246, 216, 45, 224, 32, 77, 245, 48, 174, 210, 58, 47}
-	encryptKey = []byte{218, 39, 222, 9, 180, 65, 105, 1, 82, 148, 220, 60, 154, 3, 153, 9, 11, 183, 232, 100, 139, 124, 63, 99, 2, 143, 88, 153, 28, 235, 112, 64}
-)
-
 func (app *webApp) setInternalRoutes(g *gin.Engine) {
 	internalGroup := g.Group("/_internal")
 	internalGroup.GET("/health", func(ctx *gin.Context) { ctx.JSON(http.StatusOK, gin.H{"message": "ok"}) })
 	internalGroup.Use(app.HttpLogMw.Handle)
 	internalGroup.Use(app.RespMw.Handle)
 	internalGroup.Use(app.RecoverMw.Handle)
+	internalGroup.Use(app.SessionMw.Handle)
+
+	internalGroup.POST("/admin/login", app.Ctrl.UserCtrl.AdminLogin)
+
+	// 只允許登入的管理者，不接受 API key
+	adminGroup := internalGroup.Group("")
+	adminGroup.Use(app.AdminAuthMw.Handle)
+	adminManage := middleware.RequireScope(scope.AdminManage)
 
+	adminGroup.POST("/admin/logout", app.Ctrl.UserCtrl.AdminLogout)
+	adminGroup.GET("/admin/me", app.Ctrl.UserCtrl.AdminGetMe)
+	adminGroup.GET("/admin_users", adminManage, app.Ctrl.UserCtrl.AdminGetAdminUsers)
+	adminGroup.POST("/admin_user", adminManage, app.Ctrl.UserCtrl.AdminCreateAdminUser)
+	adminGroup.PUT("/admin_user/:user_id", adminManage, app.Ctrl.UserCtrl.AdminUpdateAdminUser)
+	adminGroup.GET("/api_keys", adminManage, app.Ctrl.ApiKeyCtrl.AdminGetApiKeys)
+	adminGroup.POST("/api_key", adminManage, app.Ctrl.ApiKeyCtrl.AdminCreateApiKey)
+	adminGroup.POST("/api_key/:key_id/revoke", adminManage, app.Ctrl.ApiKeyCtrl.AdminRevokeApiKey)
+
+	// 管理者或 API key 都可以呼叫，每個路徑都需要以 RequireScope 指定 scope
 	internalAuthGroup := internalGroup.Group("")
-	internalAuthGroup.Use(app.InternalAuthMw.Handle)
-	internalAuthGroup.POST("/generate/password", app.Ctrl.UserCtrl.AdminGetEncryptedPassword)
-
-	internalAuthGroup.GET("/users", app.Ctrl.UserCtrl.AdminGetUsers)
-	internalAuthGroup.PUT("/user/:user_id", app.Ctrl.UserCtrl.AdminUpdateUser)
-
-	internalAuthGroup.GET("/students", app.Ctrl.StudentCtrl.AdminGetStudents)
-	internalAuthGroup.GET("/deposit_records", app.Ctrl.DepositRecordCtrl.AdminGetDepositRecords)
-	internalAuthGroup.GET("/reduce_records", app.Ctrl.ReduceRecordCtrl.AdminGetReduceRecords)
-	internalAuthGroup.GET("/schedules", app.Ctrl.ScheduleCtrl.AdminGetSchedules)
-
-	internalAuthGroup.GET("/semester_settle_records", app.Ctrl.SemesterSettleRecordCtrl.AdminGetSemesterSettleRecords)
-	internalAuthGroup.POST("/semester_settle_record/settle", app.Ctrl.SemesterSettleRecordCtrl.AdminSemesterSettlePoints)
-
-	internalAuthGroup.POST("/sync/student", app.Ctrl.SyncCtrl.AdminBatchSyncStudentsAndUsers)
-	internalAuthGroup.POST("/sync/deposit_record", app.Ctrl.SyncCtrl.AdminBatchSyncDepositRecord)
-	internalAuthGroup.POST("/sync/reduce_record", app.Ctrl.SyncCtrl.AdminBatchSyncReduceRecord)
-	internalAuthGroup.POST("/sync/schedule", app.Ctrl.SyncCtrl.AdminBatchSyncSchedule)
-	internalAuthGroup.POST("/sync/point_card", app.Ctrl.SyncCtrl.AdminBatchSyncPointCard)
-	internalAuthGroup.POST("/sync/semester_settle_record", app.Ctrl.SyncCtrl.AdminBatchSyncSemesterSettleRecord)
-	internalAuthGroup.POST("/sync/all/by_student", app.Ctrl.SyncCtrl.AdminSyncAllByStudent)
+	internalAuthGroup.Use(app.ApiKeyMw.Handle)
+	internalAuthGroup.Use(app.AdminAuthMw.Handle)
+	usersRead := middleware.RequireScope(scope.UsersRead)
+	usersWrite := middleware.RequireScope(scope.UsersWrite)
+	studentsRead := middleware.RequireScope(scope.StudentsRead)
+	recordsRead := middleware.RequireScope(scope.RecordsRead)
+	settlementRead := middleware.RequireScope(scope.SettlementRead)
+	settlementWrite := middleware.RequireScope(scope.SettlementWrite)
+	syncRead := middleware.RequireScope(scope.SyncRead)
+	syncWrite := middleware.RequireScope(scope.SyncWrite)
+	ledgerRead := middleware.RequireScope(scope.LedgerRead)
+	webhookRead := middleware.RequireScope(scope.WebhookRead)
+	webhookWrite := middleware.RequireScope(scope.WebhookWrite)
+	auditRead := middleware.RequireScope(scope.AuditRead)
+
+	internalAuthGroup.POST("/generate/password", usersWrite, app.Ctrl.UserCtrl.AdminGetEncryptedPassword)
+
+	internalAuthGroup.GET("/users", usersRead, app.Ctrl.UserCtrl.AdminGetUsers)
+	internalAuthGroup.PUT("/user/:user_id", usersWrite, app.Ctrl.UserCtrl.AdminUpdateUser)
+	internalAuthGroup.GET("/user/:user_id/sessions", usersRead, app.Ctrl.LoginSessionCtrl.AdminGetUserLoginSessions)
+	internalAuthGroup.POST("/user/:user_id/sessions/revoke", usersWrite, app.Ctrl.LoginSessionCtrl.AdminRevokeUserLoginSessions)
+	internalAuthGroup.POST("/user/:user_id/session/:session_id/revoke", usersWrite, app.Ctrl.LoginSessionCtrl.AdminRevokeUserLoginSession)
+
+	internalAuthGroup.GET("/students", studentsRead, app.Ctrl.StudentCtrl.AdminGetStudents)
+	internalAuthGroup.GET("/deposit_records", recordsRead, app.Ctrl.DepositRecordCtrl.AdminGetDepositRecords)
+	internalAuthGroup.GET("/reduce_records", recordsRead, app.Ctrl.ReduceRecordCtrl.AdminGetReduceRecords)
+	internalAuthGroup.GET("/schedules", recordsRead, app.Ctrl.ScheduleCtrl.AdminGetSchedules)
+
+	internalAuthGroup.GET("/semester_settle_records", recordsRead, app.Ctrl.SemesterSettleRecordCtrl.AdminGetSemesterSettleRecords)
+	internalAuthGroup.POST("/semester_settle_record/settle", settlementWrite, app.Ctrl.SemesterSettleRecordCtrl.AdminSemesterSettlePoints)
+
+	internalAuthGroup.GET("/semesters", settlementRead, app.Ctrl.SemesterCtrl.AdminGetSemesters)
+	internalAuthGroup.POST("/semester", settlementWrite, app.Ctrl.SemesterCtrl.AdminCreateSemester)
+	internalAuthGroup.PUT("/semester/:semester_id", settlementWrite, app.Ctrl.SemesterCtrl.AdminUpdateSemester)
+	internalAuthGroup.DELETE("/semester/:semester_id", settlementWrite, app.Ctrl.SemesterCtrl.AdminDeleteSemester)
+	internalAuthGroup.GET("/semester/:semester_id/settlement_preview", settlementRead, app.Ctrl.SemesterSettleRecordCtrl.AdminPreviewSettlement)
+	internalAuthGroup.GET("/settlement_runs", settlementRead, app.Ctrl.SemesterSettleRecordCtrl.AdminGetSettlementRuns)
+	internalAuthGroup.GET("/settlement_runs/:run_id", settlementRead, app.Ctrl.SemesterSettleRecordCtrl.AdminGetSettlementRun)
+	internalAuthGroup.POST("/settlement_runs/:run_id/resume", settlementWrite, app.Ctrl.SemesterSettleRecordCtrl.AdminResumeSettlementRun)
+	internalAuthGroup.GET("/settlement_runs/:run_id/reversals", settlementRead, app.Ctrl.SemesterSettleRecordCtrl.AdminGetSettlementReversals)
+	internalAuthGroup.POST("/settlement_runs/:run_id/reverse", settlementWrite, app.Ctrl.SemesterSettleRecordCtrl.AdminReverseSettlementRun)
+	internalAuthGroup.POST("/settlement_runs/:run_id/students/:student_id/reverse", settlementWrite, app.Ctrl.SemesterSettleRecordCtrl.AdminReverseSettlementStudent)
+
+	internalAuthGroup.POST("/sync/student", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncStudentsAndUsers)
+	internalAuthGroup.POST("/sync/deposit_record", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncDepositRecord)
+	internalAuthGroup.POST("/sync/reduce_record", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncReduceRecord)
+	internalAuthGroup.POST("/sync/schedule", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncSchedule)
+	internalAuthGroup.POST("/sync/point_card", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncPointCard)
+	internalAuthGroup.POST("/sync/semester_settle_record", syncWrite, app.Ctrl.SyncCtrl.AdminBatchSyncSemesterSettleRecord)
+	internalAuthGroup.POST("/sync/all/by_student", syncWrite, app.Ctrl.SyncCtrl.AdminSyncAllByStudent)
+	internalAuthGroup.POST("/sync/incremental", syncWrite, app.Ctrl.SyncCtrl.AdminIncrementalSync)
+	internalAuthGroup.GET("/sync/jobs", syncRead, app.Ctrl.SyncCtrl.AdminGetSyncJobs)
+	internalAuthGroup.GET("/sync/jobs/:job_id", syncRead, app.Ctrl.SyncCtrl.AdminGetSyncJob)
+
+	internalAuthGroup.POST("/reconciliation", ledgerRead, app.Ctrl.ReconciliationCtrl.AdminStartReconciliation)
+	internalAuthGroup.GET("/reconciliation/:job_id", ledgerRead, app.Ctrl.ReconciliationCtrl.AdminGetReconciliation)
+	internalAuthGroup.GET("/ledger/students/:student_id", ledgerRead, app.Ctrl.LedgerCtrl.AdminGetStudentLedger)
+	internalAuthGroup.GET("/ledger/students/:student_id/balance", ledgerRead, app.Ctrl.LedgerCtrl.AdminGetStudentBalanceAsOf)
+	internalAuthGroup.GET("/ledger/mismatches", ledgerRead, app.Ctrl.LedgerCtrl.AdminGetBalanceMismatches)
+
+	internalAuthGroup.GET("/webhook_events", webhookRead, app.Ctrl.WebhookCtrl.AdminGetWebhookEvents)
+	internalAuthGroup.POST("/webhook_events/replay", webhookWrite, app.Ctrl.WebhookCtrl.AdminReplayWebhookEvents)
+
+	internalAuthGroup.GET("/audit_logs", auditRead, app.Ctrl.AuditLogCtrl.AdminGetAuditLogs)
 }
 
 func (app *webApp) setWebhookRoutes(g *gin.Engine) {
 	webHookGroup := g.Group("/_kintone/webhook")
 	webHookGroup.Use(app.HttpLogMw.Handle)
 	webHookGroup.Use(app.RecoverMw.WebhookHandle)
-	webHookGroup.POST("/student", app.Ctrl.StudentCtrl.KintoneStudentWebhook)
-	webHookGroup.POST("/deposit_record", app.Ctrl.DepositRecordCtrl.KintoneDepositRecordWebhook)
-	webHookGroup.POST("/reduce_record", app.Ctrl.ReduceRecordCtrl.KintoneReduceRecordWebhook)
-	webHookGroup.POST("/schedule", app.Ctrl.ScheduleCtrl.KintoneScheduleWebhook)
-	webHookGroup.POST("/semester_settle_record", app.Ctrl.SemesterSettleRecordCtrl.KintoneSemesterSettleRecordWebhook)
-	webHookGroup.POST("/point_card", app.Ctrl.PointCardCtrl.KintonePointCardWebhook)
+	webHookGroup.POST("/student", app.WebhookAuthMw.Handle(webhook.SourceStudent), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourceStudent))
+	webHookGroup.POST("/deposit_record", app.WebhookAuthMw.Handle(webhook.SourceDepositRecord), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourceDepositRecord))
+	webHookGroup.POST("/reduce_record", app.WebhookAuthMw.Handle(webhook.SourceReduceRecord), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourceReduceRecord))
+	webHookGroup.POST("/schedule", app.WebhookAuthMw.Handle(webhook.SourceSchedule), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourceSchedule))
+	webHookGroup.POST("/semester_settle_record", app.WebhookAuthMw.Handle(webhook.SourceSemesterSettleRecord), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourceSemesterSettleRecord))
+	webHookGroup.POST("/point_card", app.WebhookAuthMw.Handle(webhook.SourcePointCard), app.Ctrl.WebhookCtrl.KintoneWebhook(webhook.SourcePointCard))
 }
 
 func (app *webApp) setApiRoutes(g *gin.Engine) {
-	store := cookie.NewStore(authKey, encryptKey)
 	apiGroup := g.Group("/api")
 	apiGroup.Use(app.HttpLogMw.Handle)
 	apiGroup.Use(app.RespMw.Handle)
 	apiGroup.Use(app.RecoverMw.Handle)
-	apiGroup.Use(sessions.Sessions(user.SessionID, store))
+	apiGroup.Use(app.SessionMw.Handle)
 
 	apiGroup.POST("/user/login", app.Ctrl.UserCtrl.UserLogin)
 	apiGroup.POST("/user", app.Ctrl.UserCtrl.RegisterUser)
@@ -72,8 +127,11 @@ func (app *webApp) setApiRoutes(g *gin.Engine) {
 	authApiGroup.GET("/user", app.Ctrl.UserCtrl.GetUser)
 	authApiGroup.PUT("/user/:user_id/password", app.Ctrl.UserCtrl.UpdateUserPassword)
 	authApiGroup.POST("/user/logout", app.Ctrl.UserCtrl.UserLogout)
+	authApiGroup.GET("/user/sessions", app.Ctrl.LoginSessionCtrl.GetLoginSessions)
+	authApiGroup.POST("/user/session/:session_id/revoke", app.Ctrl.LoginSessionCtrl.RevokeLoginSession)
 	authApiGroup.GET("/student/list", app.Ctrl.StudentCtrl.GetStudents)
 	authApiGroup.GET("/deposit_record/list", app.Ctrl.DepositRecordCtrl.GetDepositRecords)
 	authApiGroup.GET("/reduce_record/list", app.Ctrl.ReduceRecordCtrl.GetReduceRecords)
 	authApiGroup.GET("/schedule/list", app.Ctrl.ScheduleCtrl.GetSchedule)
+	authApiGroup.GET("/student/balance", app.Ctrl.LedgerCtrl.GetStudentBalanceAsOf)
 }
@@ -16,26 +16,35 @@ func ProvideWebApp(
 	httpLogMw middleware.IHttpLogMiddleware,
 	authMw middleware.IAuthMiddleware,
 	recoverMw middleware.IRecoverMiddleware,
-	internalAuthMw middleware.IInternalAuthMiddleware,
+	adminAuthMw middleware.IAdminAuthMiddleware,
+	apiKeyMw middleware.IApiKeyMiddleware,
+	webhookAuthMw middleware.IWebhookAuthMiddleware,
+	sessionMw middleware.ISessionMiddleware,
 	ctrl *web.Controller,
 ) IWebApp {
 	return &webApp{
-		RespMw:         respMw,
-		HttpLogMw:      httpLogMw,
-		Ctrl:           ctrl,
-		AuthMw:         authMw,
-		RecoverMw:      recoverMw,
-		InternalAuthMw: internalAuthMw,
+		RespMw:        respMw,
+		HttpLogMw:     httpLogMw,
+		Ctrl:          ctrl,
+		AuthMw:        authMw,
+		RecoverMw:     recoverMw,
+		AdminAuthMw:   adminAuthMw,
+		ApiKeyMw:      apiKeyMw,
+		WebhookAuthMw: webhookAuthMw,
+		SessionMw:     sessionMw,
 	}
 }
 
 type webApp struct {
-	Ctrl           *web.Controller
-	RespMw         middleware.IResponseMiddleware
-	HttpLogMw      middleware.IHttpLogMiddleware
-	AuthMw         middleware.IAuthMiddleware
-	RecoverMw      middleware.IRecoverMiddleware
-	InternalAuthMw middleware.IInternalAuthMiddleware
+	Ctrl          *web.Controller
+	RespMw        middleware.IResponseMiddleware
+	HttpLogMw     middleware.IHttpLogMiddleware
+	AuthMw        middleware.IAuthMiddleware
+	RecoverMw     middleware.IRecoverMiddleware
+	AdminAuthMw   middleware.IAdminAuthMiddleware
+	ApiKeyMw      middleware.IApiKeyMiddleware
+	WebhookAuthMw middleware.IWebhookAuthMiddleware
+	SessionMw     middleware.ISessionMiddleware
 }
 
 func (app *webApp) Init(g *gin.Engine) {
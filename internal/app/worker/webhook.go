@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/controller/web"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"time"
+)
+
+type IWebhookWorker interface {
+	Start()
+	Stop(ctx context.Context)
+}
+
+type WebhookWorker struct {
+	webhookEventSrv     interfaces.IWebhookEventSrv
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv
+	handlers            web.WebhookHandlers
+	logger              logger.ILogger
+	stop                chan struct{} `wire:"-"`
+	done                chan struct{} `wire:"-"`
+}
+
+func ProvideWebhookWorker(
+	webhookEventSrv interfaces.IWebhookEventSrv,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	handlers web.WebhookHandlers,
+	logger logger.ILogger,
+) IWebhookWorker {
+	return &WebhookWorker{
+		webhookEventSrv:     webhookEventSrv,
+		recordLockCommonSrv: recordLockCommonSrv,
+		handlers:            handlers,
+		logger:              logger,
+		stop:                make(chan struct{}),
+		done:                make(chan struct{}),
+	}
+}
+
+func (w *WebhookWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(webhook.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			w.processEvents()
+
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 等目前處理中的事件結束，未處理的事件留在 webhook_events 下次啟動再處理
+func (w *WebhookWorker) Stop(ctx context.Context) {
+	close(w.stop)
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.logger.Warn(ctx, "webhook worker graceful stop failed")
+	}
+}
+
+func (w *WebhookWorker) processEvents() {
+	for {
+		events, err := w.webhookEventSrv.ClaimEvents(context.Background(), webhook.ClaimBatchSize)
+		if err != nil {
+			w.logger.Error(context.Background(), "webhookWorker processEvents ClaimEvents", err)
+			return
+		}
+
+		for _, event := range events {
+			w.processEvent(event)
+		}
+
+		if len(events) < webhook.ClaimBatchSize {
+			return
+		}
+
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+	}
+}
+
+func (w *WebhookWorker) processEvent(event *bo.WebhookEvent) {
+	ctx := context.WithValue(context.Background(), logger.CtxActionIdKey, uuid.NewString())
+	// 稽核記錄以觸發的 webhook 事件為操作人
+	ctx = ctxUtil.WithActor(ctx, fmt.Sprintf("%s%d", audit.ActorWebhookEventPrefix, event.EventId))
+	fields := []zap.Field{
+		zap.Int64("event_id", event.EventId),
+		zap.String("source", string(event.Source)),
+		zap.String("record_id", event.RecordId),
+		zap.Int("attempts", event.Attempts),
+	}
+
+	// 與 BatchSync 共用記錄鎖，同一筆記錄不會同時被 webhook 與 BatchSync 更新
+	err := w.recordLockCommonSrv.LockRecord(ctx, event.Source, event.RecordId, func() error {
+		stale, err := w.webhookEventSrv.IsStaleEvent(ctx, event)
+		if err != nil {
+			return xerrors.Errorf("webhookEventSrv.IsStaleEvent: %w", err)
+		}
+		if stale {
+			w.logger.Info(ctx, "webhookWorker processEvent skip stale event", fields...)
+			return w.webhookEventSrv.SkipEvent(ctx, event)
+		}
+
+		if err := w.handle(ctx, event); err != nil {
+			w.logger.Error(ctx, "webhookWorker processEvent handle", err, fields...)
+			if err = w.webhookEventSrv.FailEvent(ctx, event, err); err != nil {
+				return xerrors.Errorf("webhookEventSrv.FailEvent: %w", err)
+			}
+			return nil
+		}
+
+		return w.webhookEventSrv.CompleteEvent(ctx, event)
+	})
+	if err != nil {
+		w.logger.Error(ctx, "webhookWorker processEvent", err, fields...)
+	}
+}
+
+func (w *WebhookWorker) handle(ctx context.Context, event *bo.WebhookEvent) (err error) {
+	handler, found := w.handlers[event.Source]
+	if !found {
+		return xerrors.Errorf("webhookWorker handle unknown source %s: %w", event.Source, errs.WebhookErr.InvalidPayloadError)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("webhookWorker handle recover: %v", r)
+		}
+	}()
+
+	return handler(ctx, []byte(event.Payload))
+}
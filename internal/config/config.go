@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
 	"jaystar/internal/utils/osUtil"
 	"log"
 	"path"
@@ -20,6 +22,8 @@ type IConfigEnv interface {
 	GetDbConfig() DbConfig
 	GetHttpConfig() httpConfig
 	GetKintoneConfig() kintoneConfig
+	GetJobConfig() jobConfig
+	GetSessionConfig() sessionConfig
 }
 
 func ProviderIConfigEnv() IConfigEnv {
@@ -42,9 +46,27 @@ func ProviderIConfigEnv() IConfigEnv {
 		log.Fatalf("🔔🔔🔔 fatal error viper.Unmarshal: %v 🔔🔔🔔", err)
 	}
 
+	if err := cfg.SessionConfig.decodeKeys(); err != nil {
+		log.Fatalf("🔔🔔🔔 fatal error SessionConfig.decodeKeys: %v 🔔🔔🔔", err)
+	}
+
 	return &cfg
 }
 
+// NewKintoneConfigEnv 不讀取設定檔，只設定 kintone 連線資訊 (e.g. 測試時指向 kintoneFake.Server)
+func NewKintoneConfigEnv(url string, userAuthorization string, appId AppIdInfo) IConfigEnv {
+	return &configEnv{
+		AppEnv:    "test",
+		LogConfig: logConfig{Name: "test", Encoding: "console", Level: "info"},
+		KintoneConfig: kintoneConfig{
+			Url:                     url,
+			CommonUserAuthorization: userAuthorization,
+			AdminUserAuthorization:  userAuthorization,
+			AppId:                   appId,
+		},
+	}
+}
+
 // fields need to be public for viper to set values in
 type configEnv struct {
 	AppEnv        string
@@ -52,11 +74,15 @@ type configEnv struct {
 	LogConfig     logConfig     `mapstructure:"log"`
 	DbConfig      DbConfig      `mapstructure:"postgres"`
 	KintoneConfig kintoneConfig `mapstructure:"kintone"`
+	JobConfig     jobConfig     `mapstructure:"job"`
+	SessionConfig sessionConfig `mapstructure:"session"`
 }
 
 type httpConfig struct {
 	BaseUrl string `mapstructure:"baseUrl"`
 	Port    string `mapstructure:"port"`
+	// TrustedProxies 只信任這些 proxy 設定的 X-Forwarded-For，沒有設定時不信任任何 proxy
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type logConfig struct {
@@ -74,14 +100,83 @@ type DbConfig struct {
 	LogLevel string `mapstructure:"log_level"`
 }
 
+type jobConfig struct {
+	// Reconciliation 每晚比對 kintone 與 db 的資料，差異寫入 log
+	Reconciliation bool `mapstructure:"reconciliation"`
+	// SemesterSettlementSpec 檢查是否為學期結算日的排程，沒有設定時每天 04:00 執行
+	SemesterSettlementSpec string `mapstructure:"semester_settlement_spec"`
+}
+
+// sessionConfig 簽章與加密 session cookie 的 key，以 hex 字串設定
+type sessionConfig struct {
+	// AuthKey 簽章用，長度 32 或 64 bytes
+	AuthKey string `mapstructure:"auth_key"`
+	// EncryptKey 加密用，長度 16、24 或 32 bytes，沒有設定時只簽章不加密
+	EncryptKey string `mapstructure:"encrypt_key"`
+	// PreviousKeys 輪替時保留舊的 key，舊 key 簽章的 cookie 仍然有效，下次寫入時改用新的 key
+	PreviousKeys []SessionKeyPair `mapstructure:"previous_keys"`
+
+	keyPairs [][]byte
+}
+
+type SessionKeyPair struct {
+	AuthKey    string `mapstructure:"auth_key"`
+	EncryptKey string `mapstructure:"encrypt_key"`
+}
+
+// KeyPairs 依序為簽章與加密 key，第一組用來寫入，其餘只用來讀取
+func (c sessionConfig) KeyPairs() [][]byte {
+	return c.keyPairs
+}
+
+func (c *sessionConfig) decodeKeys() error {
+	pairs := append([]SessionKeyPair{{AuthKey: c.AuthKey, EncryptKey: c.EncryptKey}}, c.PreviousKeys...)
+
+	keyPairs := make([][]byte, 0, len(pairs)*2)
+	for i, pair := range pairs {
+		authKey, err := hex.DecodeString(pair.AuthKey)
+		if err != nil {
+			return xerrors.Errorf("key pair %d auth_key: %w", i, err)
+		}
+		if len(authKey) != 32 && len(authKey) != 64 {
+			return xerrors.Errorf("key pair %d auth_key: invalid length %d", i, len(authKey))
+		}
+		encryptKey, err := hex.DecodeString(pair.EncryptKey)
+		if err != nil {
+			return xerrors.Errorf("key pair %d encrypt_key: %w", i, err)
+		}
+		switch len(encryptKey) {
+		case 0:
+			encryptKey = nil
+		case 16, 24, 32:
+		default:
+			return xerrors.Errorf("key pair %d encrypt_key: invalid length %d", i, len(encryptKey))
+		}
+		keyPairs = append(keyPairs, authKey, encryptKey)
+	}
+
+	c.keyPairs = keyPairs
+	return nil
+}
+
 type kintoneConfig struct {
-	Url                     string    `mapstructure:"url"`
-	CommonUserAuthorization string    `mapstructure:"common_user_authorization"`
-	AdminUserAuthorization  string    `mapstructure:"admin_user_authorization"`
-	AppId                   AppIdInfo `mapstructure:"app_id"`
+	Url                     string        `mapstructure:"url"`
+	CommonUserAuthorization string        `mapstructure:"common_user_authorization"`
+	AdminUserAuthorization  string        `mapstructure:"admin_user_authorization"`
+	AppId                   AppIdInfo     `mapstructure:"app_id"`
+	Webhook                 WebhookConfig `mapstructure:"webhook"`
 }
 
-type AppIdInfo struct {
+// WebhookConfig 驗證收到的 webhook 確實來自 kintone
+// kintone 的 webhook 無法自訂 header，token 設定在 webhook URL 的 query (e.g. /_kintone/webhook/student?token=xxx)
+type WebhookConfig struct {
+	Tokens AppWebhookTokens `mapstructure:"tokens"`
+	// AllowedIps 允許的來源 IP 或 CIDR，沒有設定時不限制來源
+	AllowedIps []string `mapstructure:"allowed_ips"`
+}
+
+// AppWebhookTokens 各應用程式 webhook 的共用密鑰，沒有設定的應用程式會拒絕所有 webhook
+type AppWebhookTokens struct {
 	StudentInfo          string `mapstructure:"student_info"`
 	PointCard            string `mapstructure:"point_card"`
 	ScheduleRecord       string `mapstructure:"schedule_record"`
@@ -90,6 +185,54 @@ type AppIdInfo struct {
 	SemesterSettleRecord string `mapstructure:"semester_settle_record"`
 }
 
+type AppIdInfo struct {
+	StudentInfo          string       `mapstructure:"student_info"`
+	PointCard            string       `mapstructure:"point_card"`
+	ScheduleRecord       string       `mapstructure:"schedule_record"`
+	DepositRecord        string       `mapstructure:"deposit_record"`
+	ReduceRecord         string       `mapstructure:"reduce_record"`
+	SemesterSettleRecord string       `mapstructure:"semester_settle_record"`
+	ApiTokens            AppApiTokens `mapstructure:"api_tokens"`
+}
+
+// AppApiTokens 各應用程式的 API token，沒有設定的應用程式使用帳號密碼認證
+type AppApiTokens struct {
+	StudentInfo          AppApiToken `mapstructure:"student_info"`
+	PointCard            AppApiToken `mapstructure:"point_card"`
+	ScheduleRecord       AppApiToken `mapstructure:"schedule_record"`
+	DepositRecord        AppApiToken `mapstructure:"deposit_record"`
+	ReduceRecord         AppApiToken `mapstructure:"reduce_record"`
+	SemesterSettleRecord AppApiToken `mapstructure:"semester_settle_record"`
+}
+
+// AppApiToken 讀取與寫入分開設定，方便只給特定應用程式寫入權限
+// 更新含有 lookup 欄位的記錄時，需要以逗號串接來源應用程式的 token (e.g. "writeToken,pointCardToken")
+type AppApiToken struct {
+	Read  string `mapstructure:"read"`
+	Write string `mapstructure:"write"`
+}
+
+// GetApiToken 以 app id 取得對應的 API token 設定
+func (a AppIdInfo) GetApiToken(appId string) AppApiToken {
+	switch appId {
+	case "":
+		return AppApiToken{}
+	case a.StudentInfo:
+		return a.ApiTokens.StudentInfo
+	case a.PointCard:
+		return a.ApiTokens.PointCard
+	case a.ScheduleRecord:
+		return a.ApiTokens.ScheduleRecord
+	case a.DepositRecord:
+		return a.ApiTokens.DepositRecord
+	case a.ReduceRecord:
+		return a.ApiTokens.ReduceRecord
+	case a.SemesterSettleRecord:
+		return a.ApiTokens.SemesterSettleRecord
+	}
+	return AppApiToken{}
+}
+
 func (c *configEnv) GetAppEnv() string {
 	return c.AppEnv
 }
@@ -110,6 +253,14 @@ func (c *configEnv) GetKintoneConfig() kintoneConfig {
 	return c.KintoneConfig
 }
 
+func (c *configEnv) GetJobConfig() jobConfig {
+	return c.JobConfig
+}
+
+func (c *configEnv) GetSessionConfig() sessionConfig {
+	return c.SessionConfig
+}
+
 func GetExactRoot(pathDepRelFromRoot int) string {
 	_, filename, _, _ := runtime.Caller(0)
 	curFileDir := path.Dir(filename)
@@ -0,0 +1,19 @@
+package apikey
+
+import "time"
+
+const (
+	// KeyPrefix 產生的 API key 都以此開頭，方便辨識外流的 key
+	KeyPrefix = "jsk_"
+	// KeyBytes API key 隨機部分的位元組數
+	KeyBytes = 32
+	// DisplayPrefixLen 保存在 db 的 key 開頭字元數，列表時用來辨識是哪一把 key
+	DisplayPrefixLen = 12
+
+	// HeaderKey 呼叫內部 API 時以 Authorization: Bearer <key> 帶入
+	HeaderKey    = "Authorization"
+	BearerPrefix = "Bearer "
+
+	// LastUsedInterval 最後使用時間的更新間隔，避免每個請求都寫入 db
+	LastUsedInterval = time.Minute
+)
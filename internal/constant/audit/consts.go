@@ -0,0 +1,48 @@
+package audit
+
+const (
+	// ActorSystem 沒有操作人的呼叫 (e.g. 排程的學期結算)
+	ActorSystem = "system"
+	// ActorWebhookEventPrefix webhook 的操作人為 webhook_event:<event_id>
+	ActorWebhookEventPrefix = "webhook_event:"
+	// RedactedValue 不保存原始值的欄位 (e.g. 密碼)，只表示有變更
+	RedactedValue = "[redacted]"
+)
+
+type Action string
+
+const (
+	ActionUserUpdate          Action = "user.update"
+	ActionAdminUserCreate     Action = "admin_user.create"
+	ActionApiKeyCreate        Action = "api_key.create"
+	ActionApiKeyRevoke        Action = "api_key.revoke"
+	ActionSemesterCreate      Action = "semester.create"
+	ActionSemesterUpdate      Action = "semester.update"
+	ActionSemesterDelete      Action = "semester.delete"
+	ActionSettlementSettle    Action = "settlement.settle"
+	ActionSettlementResume    Action = "settlement.resume"
+	ActionSettlementReverse   Action = "settlement.reverse"
+	ActionSyncStart           Action = "sync.start"
+	ActionWebhookReplay       Action = "webhook.replay"
+	ActionDepositRecordSync   Action = "deposit_record.sync"
+	ActionDepositRecordDelete Action = "deposit_record.delete"
+	ActionReduceRecordSync    Action = "reduce_record.sync"
+	ActionReduceRecordDelete  Action = "reduce_record.delete"
+	ActionLoginSessionRevoke  Action = "login_session.revoke"
+	// ActionLoginSessionRevokeAll 撤銷使用者所有的登入，target 為使用者
+	ActionLoginSessionRevokeAll Action = "login_session.revoke_all"
+)
+
+type TargetType string
+
+const (
+	TargetUser          TargetType = "user"
+	TargetApiKey        TargetType = "api_key"
+	TargetSemester      TargetType = "semester"
+	TargetSettlementRun TargetType = "settlement_run"
+	TargetSyncJob       TargetType = "sync_job"
+	TargetWebhookEvent  TargetType = "webhook_event"
+	TargetDepositRecord TargetType = "deposit_record"
+	TargetReduceRecord  TargetType = "reduce_record"
+	TargetLoginSession  TargetType = "login_session"
+)
@@ -7,4 +7,8 @@ const (
 	StackTrace       = "stackTrace"
 
 	UserSession = "userSession"
+	ApiKey      = "apiKey"
+	Actor       = "actor"
+	// SessionToken 目前請求的登入 session token
+	SessionToken = "sessionToken"
 )
@@ -1,21 +1,29 @@
 package kintone
 
+import "fmt"
+
 // API URL path
 const (
 	RecordsPath = "/k/v1/records.json" // 批量記錄
 	RecordPath  = "/k/v1/record.json"  // 單筆記錄
+
+	RecordsCursorPath = "/k/v1/records/cursor.json" // cursor 批量讀取
+
+	BulkRequestPath = "/k/v1/bulkRequest.json" // 多個應用程式的寫入請求一次送出
 )
 
 // API 常用參數名稱
 const (
 	// header
 	HeaderUserAuthorization = "X-Cybozu-Authorization"
+	HeaderApiToken          = "X-Cybozu-API-Token"
 
 	// querystring/body/response
 	QueryApp   = "app"
 	QueryQuery = "query"
 	QueryField = "field[%d]"
 	TotalCount = "totalCount"
+	QueryId    = "id"
 )
 
 // API 「query」參數常用 keyword
@@ -25,6 +33,9 @@ const (
 	QueryQueryOffset  = "offset"
 )
 
+// RevisionConflictMaxAttempts 更新遇到 revision 衝突 (GAIA_CO02) 時重新讀取並重試的次數上限
+const RevisionConflictMaxAttempts = 3
+
 // Webhook 相關
 const (
 	WebhookClientIP  = "103.79.14.86"
@@ -34,4 +45,38 @@ const (
 // API 限制
 const (
 	BatchInsertRecordsMaxLimit = 100
+	BatchUpdateRecordsMaxLimit = 100
+	BatchDeleteRecordsMaxLimit = 100
+	CursorMaxSize              = 500 // cursor 每次取得的最大筆數
+	BulkRequestMaxRequests     = 20  // bulkRequest 一次最多包含的請求數
 )
+
+// App 應用程式代號，實際的 app id 由設定檔決定
+type App int
+
+const (
+	AppStudentInfo App = iota + 1
+	AppPointCard
+	AppScheduleRecord
+	AppDepositRecord
+	AppReduceRecord
+	AppSemesterSettleRecord
+)
+
+func (a App) String() string {
+	switch a {
+	case AppStudentInfo:
+		return "student_info"
+	case AppPointCard:
+		return "point_card"
+	case AppScheduleRecord:
+		return "schedule_record"
+	case AppDepositRecord:
+		return "deposit_record"
+	case AppReduceRecord:
+		return "reduce_record"
+	case AppSemesterSettleRecord:
+		return "semester_settle_record"
+	}
+	return fmt.Sprintf("App(%d)", int(a))
+}
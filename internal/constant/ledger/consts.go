@@ -0,0 +1,10 @@
+package ledger
+
+// EntryType 影響點數的記錄來源
+type EntryType string
+
+const (
+	EntryTypeDepositRecord EntryType = "deposit_record"
+	EntryTypeReduceRecord  EntryType = "reduce_record"
+	EntryTypeSettleRecord  EntryType = "settle_record"
+)
@@ -17,4 +17,6 @@ const (
 	ResponseStatus = "response_status"
 	StackTrace     = "stacktrace"
 	ErrorMessage   = "error_message"
+
+	ReconciliationMismatches = "reconciliation_mismatches"
 )
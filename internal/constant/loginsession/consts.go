@@ -0,0 +1,18 @@
+package loginsession
+
+const (
+	// TokenBytes session token 隨機部分的位元組數，cookie 中只保存簽章過的 token
+	TokenBytes = 32
+	// MaxUserAgentLen 保存的 user agent 長度上限
+	MaxUserAgentLen = 512
+)
+
+// RevokeReason 登入被撤銷的原因
+type RevokeReason string
+
+const (
+	ReasonLogout          RevokeReason = "logout"
+	ReasonRevoked         RevokeReason = "revoked"
+	ReasonPasswordChanged RevokeReason = "password_changed"
+	ReasonDeactivated     RevokeReason = "deactivated"
+)
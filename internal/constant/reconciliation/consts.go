@@ -0,0 +1,27 @@
+package reconciliation
+
+// Field 對帳時比對的學生統計欄位
+type Field string
+
+const (
+	FieldDepositRecordCount        Field = "deposit_record_count"
+	FieldDepositedPoints           Field = "deposited_points"
+	FieldReduceRecordCount         Field = "reduce_record_count"
+	FieldReducePoints              Field = "reduce_points"
+	FieldSemesterSettleRecordCount Field = "semester_settle_record_count"
+	FieldClearPoints               Field = "clear_points"
+	FieldPointCardCount            Field = "point_card_count"
+	FieldRestPoints                Field = "rest_points"
+)
+
+// Fields 報表中欄位的順序
+var Fields = []Field{
+	FieldDepositRecordCount,
+	FieldDepositedPoints,
+	FieldReduceRecordCount,
+	FieldReducePoints,
+	FieldSemesterSettleRecordCount,
+	FieldClearPoints,
+	FieldPointCardCount,
+	FieldRestPoints,
+}
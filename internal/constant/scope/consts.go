@@ -0,0 +1,40 @@
+package scope
+
+// Scope 內部 API 的權限，管理者依角色取得，API key 建立時指定
+type Scope string
+
+const (
+	UsersRead       Scope = "users:read"
+	UsersWrite      Scope = "users:write"
+	StudentsRead    Scope = "students:read"
+	RecordsRead     Scope = "records:read"
+	SettlementRead  Scope = "settlement:read"
+	SettlementWrite Scope = "settlement:write"
+	SyncRead        Scope = "sync:read"
+	SyncWrite       Scope = "sync:write"
+	LedgerRead      Scope = "ledger:read"
+	WebhookRead     Scope = "webhook:read"
+	WebhookWrite    Scope = "webhook:write"
+	AuditRead       Scope = "audit:read"   // 稽核記錄，管理者只有 manager 可以查詢
+	AdminManage     Scope = "admin:manage" // 管理者帳號與 API key，不開放給 API key
+)
+
+var (
+	// ReadScopes 所有管理者都有的 scope
+	ReadScopes = []Scope{UsersRead, StudentsRead, RecordsRead, SettlementRead, SyncRead, LedgerRead, WebhookRead}
+
+	// ApiKeyScopes 可以授權給 API key 的 scope
+	ApiKeyScopes = []Scope{
+		UsersRead, UsersWrite, StudentsRead, RecordsRead, SettlementRead, SettlementWrite,
+		SyncRead, SyncWrite, LedgerRead, WebhookRead, WebhookWrite, AuditRead,
+	}
+)
+
+func IsApiKeyScope(s Scope) bool {
+	for _, apiKeyScope := range ApiKeyScopes {
+		if s == apiKeyScope {
+			return true
+		}
+	}
+	return false
+}
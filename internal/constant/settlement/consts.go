@@ -0,0 +1,42 @@
+package settlement
+
+// RunStatus 學期結算的執行狀態，每個學期只會有一筆未撤銷的結算
+type RunStatus string
+
+const (
+	RunStatusPending   RunStatus = "pending" // 已建立結算清單，尚未執行
+	RunStatusRunning   RunStatus = "running"
+	RunStatusFailed    RunStatus = "failed" // 可以從中斷的步驟繼續執行
+	RunStatusCompleted RunStatus = "completed"
+	RunStatusReversing RunStatus = "reversing" // 撤銷中，撤銷結束後回到原本的狀態
+	RunStatusReversed  RunStatus = "reversed"  // 整個學期的結算已撤銷，不會再執行，學期可以重新結算
+)
+
+// ItemStep 學生已完成的結算步驟，依序執行
+type ItemStep string
+
+const (
+	ItemStepPending              ItemStep = "pending"
+	ItemStepSettleRecordInserted ItemStep = "settle_record_inserted" // 已新增 kintone 結算記錄
+	ItemStepPointCardUpdated     ItemStep = "point_card_updated"     // 已更新 db 與 kintone 的點數卡
+	ItemStepSynced               ItemStep = "synced"                 // 已將 kintone 結算記錄同步回 db
+	ItemStepReversing            ItemStep = "reversing"              // 撤銷中，不會再繼續執行結算步驟
+	ItemStepReversed             ItemStep = "reversed"               // 已刪除結算記錄並退回點數
+)
+
+// ItemSteps 結算步驟的順序
+var ItemSteps = []ItemStep{
+	ItemStepPending,
+	ItemStepSettleRecordInserted,
+	ItemStepPointCardUpdated,
+	ItemStepSynced,
+}
+
+// ReversalStatus 撤銷學期結算的執行狀態
+type ReversalStatus string
+
+const (
+	ReversalStatusRunning   ReversalStatus = "running"
+	ReversalStatusFailed    ReversalStatus = "failed" // 可以再次撤銷，停在撤銷中的學生會繼續處理
+	ReversalStatusCompleted ReversalStatus = "completed"
+)
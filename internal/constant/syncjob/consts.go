@@ -0,0 +1,68 @@
+package syncjob
+
+import "time"
+
+// Type 同步的 kintone 資料，對應 /_internal/sync/:type
+type Type string
+
+const (
+	TypeStudent              Type = "student"
+	TypeDepositRecord        Type = "deposit_record"
+	TypeReduceRecord         Type = "reduce_record"
+	TypeSchedule             Type = "schedule"
+	TypePointCard            Type = "point_card"
+	TypeSemesterSettleRecord Type = "semester_settle_record"
+	TypeAllByStudent         Type = "all_by_student" // 依序同步單一學生的所有資料
+	TypeIncremental          Type = "incremental"    // 依 watermark 同步各應用程式更新過的記錄
+	TypeReconciliation       Type = "reconciliation" // 比對 kintone 與 db，不寫入 db
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"  // 無法完成同步 (e.g. 取得 kintone 資料失敗)
+	StatusPartial   Status = "partial" // 同步完成，但有部分記錄失敗
+)
+
+// Action 單筆記錄的同步結果
+type Action string
+
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionDeleted Action = "deleted"
+	ActionSkipped Action = "skipped" // 記錄還有 webhook 沒處理完，交給 webhook 處理
+	// ActionUnchanged dry run 時 db 的記錄與 kintone 相同，不需要更新
+	ActionUnchanged Action = "unchanged"
+)
+
+// DiffKind dry run 時記錄會套用的變更
+type DiffKind string
+
+const (
+	DiffCreate  DiffKind = "create"
+	DiffUpdate  DiffKind = "update"
+	DiffDelete  DiffKind = "delete"  // 標示為刪除
+	DiffRestore DiffKind = "restore" // 重置刪除狀態，同時更新有差異的欄位
+)
+
+const (
+	// MaxRunningJobs 同時執行的同步數，其他的同步維持 queued
+	MaxRunningJobs = 2
+	// ProgressInterval 執行中每隔多久寫入一次進度與 heartbeat
+	ProgressInterval = 5 * time.Second
+	// HeartbeatTimeout queued/running 的同步超過這個時間沒有 heartbeat 時視為已中斷 (e.g. 服務重啟)
+	HeartbeatTimeout = 12 * ProgressInterval
+	// MaxFailures 只保留前面幾筆失敗的記錄，失敗數仍會完整計算
+	MaxFailures = 500
+	// MaxDiffRecords dry run 只保留前面幾筆變更，變更數仍會完整計算
+	MaxDiffRecords = 2000
+	// WatermarkOverlap 增量同步往前多取的時間，避免 kintone 更新時間與本機時間的誤差漏掉記錄
+	WatermarkOverlap = time.Minute
+)
+
+// InterruptedError 沒有 heartbeat 而被標示為 failed 的同步的錯誤訊息
+const InterruptedError = "sync job interrupted: no heartbeat"
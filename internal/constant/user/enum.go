@@ -1,5 +1,7 @@
 package user
 
+import "jaystar/internal/constant/scope"
+
 var (
 	UserStatusMap = map[UserStatus]Dto{
 		Activate:   {Key: "activate", Value: "啟用"},
@@ -9,6 +11,17 @@ var (
 		Admin: {Key: "admin", Value: "管理者"},
 		User:  {Key: "user", Value: "使用者"},
 	}
+	AdminRoleMap = map[AdminRole]Dto{
+		RoleViewer:   {Key: "viewer", Value: "檢視"},
+		RoleOperator: {Key: "operator", Value: "營運"},
+		RoleFinance:  {Key: "finance", Value: "財務"},
+		RoleManager:  {Key: "manager", Value: "帳號管理"},
+	}
+	// adminRoleWriteScopes 各角色除了查詢以外可以執行的操作
+	adminRoleWriteScopes = map[AdminRole][]scope.Scope{
+		RoleOperator: {scope.UsersWrite, scope.SyncWrite, scope.WebhookWrite},
+		RoleFinance:  {scope.SettlementWrite},
+	}
 )
 
 type Dto struct {
@@ -81,3 +94,66 @@ func UserLevelToEnum(raw string) UserLevel {
 		return LevelNone
 	}
 }
+
+// AdminRole 管理者帳號的角色，只有 level 為 Admin 的帳號有角色
+type AdminRole int
+
+const (
+	RoleNone   AdminRole = 0
+	RoleViewer AdminRole = iota
+	RoleOperator
+	RoleFinance
+	RoleManager
+)
+
+func (r AdminRole) ToKey() string {
+	if v, found := AdminRoleMap[r]; found {
+		return v.Key
+	}
+	return ""
+}
+
+func (r AdminRole) ToValue() string {
+	if v, found := AdminRoleMap[r]; found {
+		return v.Value
+	}
+	return ""
+}
+
+// HasScope 所有角色都可以查詢，RoleManager 可以執行所有操作
+func (r AdminRole) HasScope(s scope.Scope) bool {
+	switch r {
+	case RoleNone:
+		return false
+	case RoleManager:
+		return true
+	}
+
+	for _, readScope := range scope.ReadScopes {
+		if s == readScope {
+			return true
+		}
+	}
+	for _, roleScope := range adminRoleWriteScopes[r] {
+		if s == roleScope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func AdminRoleToEnum(raw string) AdminRole {
+	switch raw {
+	case "viewer", "檢視":
+		return RoleViewer
+	case "operator", "營運":
+		return RoleOperator
+	case "finance", "財務":
+		return RoleFinance
+	case "manager", "帳號管理":
+		return RoleManager
+	default:
+		return RoleNone
+	}
+}
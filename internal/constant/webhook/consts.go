@@ -0,0 +1,46 @@
+package webhook
+
+import "time"
+
+// Source 收到 webhook 的 kintone 應用程式，對應 /_kintone/webhook/:source
+type Source string
+
+const (
+	SourceStudent              Source = "student"
+	SourceDepositRecord        Source = "deposit_record"
+	SourceReduceRecord         Source = "reduce_record"
+	SourceSchedule             Source = "schedule"
+	SourceSemesterSettleRecord Source = "semester_settle_record"
+	SourcePointCard            Source = "point_card"
+)
+
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusProcessing Status = "processing"
+	StatusSucceeded  Status = "succeeded"
+	StatusSkipped    Status = "skipped" // 同一筆記錄已處理過較新的事件或已刪除，不需再處理
+	StatusDead       Status = "dead"    // 超過重試次數或資料無法處理，需人工確認後 replay
+)
+
+const (
+	PollInterval   = 5 * time.Second
+	ClaimBatchSize = 20
+	MaxAttempts    = 8
+	RetryBaseDelay = 30 * time.Second
+	RetryMaxDelay  = 30 * time.Minute
+	// ProcessingTimeout 處理中的事件超過這個時間沒有結果 (e.g. 服務中途重啟)，視為可以重新處理
+	ProcessingTimeout = 10 * time.Minute
+	// MaxRecordLocks 同時持有的記錄鎖數，每個記錄鎖佔用一個 db 連線，需小於連線池大小
+	MaxRecordLocks = 20
+)
+
+// 驗證 webhook 的共用密鑰，kintone 只能設定在 URL query，其他來源 (e.g. 手動 replay 工具) 可以使用 header
+const (
+	TokenQueryKey  = "token"
+	TokenHeaderKey = "X-Webhook-Token"
+)
+
+// MaxPayloadBytes kintone webhook 的資料只有幾 KB，超過時不讀取完整內容
+const MaxPayloadBytes = 64 << 10
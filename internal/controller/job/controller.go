@@ -1,20 +1,26 @@
 package job
 
 import (
+	"errors"
 	"jaystar/internal/constant/log"
 	"jaystar/internal/cronjob"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/errs"
 	"time"
 )
 
 type JobController struct {
 	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv
+	incrementalSyncSrv      interfaces.IIncrementalSyncSrv
+	reconciliationSrv       interfaces.IReconciliationSrv
 }
 
-func ProvideController(semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv) *JobController {
+func ProvideController(semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv, incrementalSyncSrv interfaces.IIncrementalSyncSrv, reconciliationSrv interfaces.IReconciliationSrv) *JobController {
 	return &JobController{
 		semesterSettleRecordSrv: semesterSettleRecordSrv,
+		incrementalSyncSrv:      incrementalSyncSrv,
+		reconciliationSrv:       reconciliationSrv,
 	}
 }
 
@@ -24,3 +30,23 @@ func (ctrl *JobController) SemesterSettlement(ctx *cronjob.Context) {
 		cronjob.SetActionLogs(ctx, log.ErrorMessage, err)
 	}
 }
+
+// IncrementalSync 補上漏掉的 webhook，上一次增量同步還沒結束時略過
+func (ctrl *JobController) IncrementalSync(ctx *cronjob.Context) {
+	_, err := ctrl.incrementalSyncSrv.StartIncrementalSync(ctx, false)
+	if err != nil && !errors.Is(err, errs.SyncJobErr.JobIsActiveError) {
+		cronjob.SetActionLogs(ctx, log.ErrorMessage, err)
+	}
+}
+
+// Reconciliation 比對 kintone 與 db 的資料，有差異時寫入 log
+func (ctrl *JobController) Reconciliation(ctx *cronjob.Context) {
+	report, err := ctrl.reconciliationSrv.Reconcile(ctx)
+	if err != nil {
+		cronjob.SetActionLogs(ctx, log.ErrorMessage, err)
+		return
+	}
+	if messages := report.MismatchMessages(); len(messages) > 0 {
+		cronjob.SetActionLogs(ctx, log.ReconciliationMismatches, messages)
+	}
+}
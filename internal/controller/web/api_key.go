@@ -0,0 +1,129 @@
+package web
+
+import (
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideApiKeyController(apiKeySrv interfaces.IApiKeySrv, reqParse util.IRequestParse, logger logger.ILogger) *ApiKeyCtrl {
+	return &ApiKeyCtrl{
+		apiKeySrv: apiKeySrv,
+		reqParse:  reqParse,
+		logger:    logger,
+	}
+}
+
+type ApiKeyCtrl struct {
+	apiKeySrv interfaces.IApiKeySrv
+	reqParse  util.IRequestParse
+	logger    logger.ILogger
+}
+
+func (ctrl *ApiKeyCtrl) AdminGetApiKeys(ctx *gin.Context) {
+	apiKeys, err := ctrl.apiKeySrv.GetApiKeys(ctx)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	apiKeyVOs := make([]dto.AdminApiKeyVO, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		apiKeyVOs = append(apiKeyVOs, toAdminApiKeyVO(apiKey))
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, apiKeyVOs)
+}
+
+func (ctrl *ApiKeyCtrl) AdminCreateApiKey(ctx *gin.Context) {
+	req := dto.AdminCreateApiKeyIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	data := &bo.CreateApiKeyData{
+		Name:      req.Name,
+		Scopes:    make([]scope.Scope, 0, len(req.Scopes)),
+		CreatedBy: ctxUtil.GetOperatorFromCtx(ctx),
+	}
+	for _, s := range req.Scopes {
+		data.Scopes = append(data.Scopes, scope.Scope(s))
+	}
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+			return
+		}
+		data.ExpiresAt = &expiresAt
+	}
+
+	createdApiKey, err := ctrl.apiKeySrv.CreateApiKey(ctx, data)
+	if err != nil {
+		ctrl.logger.Error(ctx, "ApiKeyCtrl AdminCreateApiKey", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, dto.AdminCreatedApiKeyVO{
+		AdminApiKeyVO: toAdminApiKeyVO(createdApiKey.ApiKey),
+		Key:           createdApiKey.Key,
+	})
+}
+
+func (ctrl *ApiKeyCtrl) AdminRevokeApiKey(ctx *gin.Context) {
+	keyId, err := strconv.ParseInt(ctx.Param("key_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	apiKey, err := ctrl.apiKeySrv.RevokeApiKey(ctx, keyId)
+	if err != nil {
+		if errors.Is(err, errs.ApiKeyErr.ApiKeyNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminApiKeyVO(apiKey))
+}
+
+func toAdminApiKeyVO(apiKey *bo.ApiKey) dto.AdminApiKeyVO {
+	scopes := make([]string, 0, len(apiKey.Scopes))
+	for _, s := range apiKey.Scopes {
+		scopes = append(scopes, string(s))
+	}
+
+	return dto.AdminApiKeyVO{
+		KeyId:      strconv.FormatInt(apiKey.KeyId, 10),
+		Name:       apiKey.Name,
+		KeyPrefix:  apiKey.KeyPrefix,
+		Scopes:     scopes,
+		ExpiresAt:  formatOptionalTime(apiKey.ExpiresAt),
+		LastUsedAt: formatOptionalTime(apiKey.LastUsedAt),
+		RevokedAt:  formatOptionalTime(apiKey.RevokedAt),
+		CreatedBy:  apiKey.CreatedBy,
+		CreatedAt:  apiKey.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
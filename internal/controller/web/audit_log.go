@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideAuditLogController(auditLogSrv interfaces.IAuditLogSrv, reqParse util.IRequestParse, logger logger.ILogger) *AuditLogCtrl {
+	return &AuditLogCtrl{
+		auditLogSrv: auditLogSrv,
+		reqParse:    reqParse,
+		logger:      logger,
+	}
+}
+
+type AuditLogCtrl struct {
+	auditLogSrv interfaces.IAuditLogSrv
+	reqParse    util.IRequestParse
+	logger      logger.ILogger
+}
+
+func (ctrl *AuditLogCtrl) AdminGetAuditLogs(ctx *gin.Context) {
+	req := &dto.AdminGetAuditLogsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.AuditLogCond{
+		Since: req.Since,
+		Until: req.Until,
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "log_id desc",
+		},
+	}
+	if req.Actor != nil {
+		boCond.Actor = *req.Actor
+	}
+	if req.Action != nil {
+		boCond.Action = audit.Action(*req.Action)
+	}
+	if req.TargetType != nil {
+		boCond.TargetType = audit.TargetType(*req.TargetType)
+	}
+	if req.TargetId != nil {
+		boCond.TargetId = *req.TargetId
+	}
+	if req.RequestId != nil {
+		boCond.RequestId = *req.RequestId
+	}
+
+	logs, pagerResult, err := ctrl.auditLogSrv.GetAuditLogs(ctx, boCond)
+	if err != nil {
+		ctrl.logger.Error(ctx, "AuditLogCtrl AdminGetAuditLogs", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	logsVO := make([]dto.AdminAuditLogVO, 0, len(logs))
+	for _, log := range logs {
+		logsVO = append(logsVO, toAdminAuditLogVO(log))
+	}
+
+	listVO := dto.ListVO{
+		List: logsVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, listVO)
+}
+
+func toAdminAuditLogVO(log *bo.AuditLog) dto.AdminAuditLogVO {
+	logVO := dto.AdminAuditLogVO{
+		LogId:      strconv.FormatInt(log.LogId, 10),
+		Actor:      log.Actor,
+		Action:     string(log.Action),
+		TargetType: string(log.TargetType),
+		TargetId:   strconv.FormatInt(log.TargetId, 10),
+		Before:     json.RawMessage("null"),
+		After:      json.RawMessage("null"),
+		RequestId:  log.RequestId,
+		CreatedAt:  log.CreatedAt.Format(time.RFC3339),
+	}
+	if log.Before != nil {
+		logVO.Before = json.RawMessage(*log.Before)
+	}
+	if log.After != nil {
+		logVO.After = json.RawMessage(*log.After)
+	}
+
+	return logVO
+}
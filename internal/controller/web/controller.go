@@ -14,6 +14,13 @@ func ProvideController(
 	semesterSettleRecordCtrl *SemesterSettleRecordCtrl,
 	pointCardCtrl *PointCardCtrl,
 	syncCtrl *SyncCtrl,
+	webhookCtrl *WebhookCtrl,
+	reconciliationCtrl *ReconciliationCtrl,
+	ledgerCtrl *LedgerCtrl,
+	semesterCtrl *SemesterCtrl,
+	apiKeyCtrl *ApiKeyCtrl,
+	auditLogCtrl *AuditLogCtrl,
+	loginSessionCtrl *LoginSessionCtrl,
 ) *Controller {
 	return &Controller{
 		UserCtrl:                 userCtrl,
@@ -24,6 +31,13 @@ func ProvideController(
 		SemesterSettleRecordCtrl: semesterSettleRecordCtrl,
 		PointCardCtrl:            pointCardCtrl,
 		SyncCtrl:                 syncCtrl,
+		WebhookCtrl:              webhookCtrl,
+		ReconciliationCtrl:       reconciliationCtrl,
+		LedgerCtrl:               ledgerCtrl,
+		SemesterCtrl:             semesterCtrl,
+		ApiKeyCtrl:               apiKeyCtrl,
+		AuditLogCtrl:             auditLogCtrl,
+		LoginSessionCtrl:         loginSessionCtrl,
 	}
 }
 
@@ -36,6 +50,13 @@ type Controller struct {
 	SemesterSettleRecordCtrl *SemesterSettleRecordCtrl
 	PointCardCtrl            *PointCardCtrl
 	SyncCtrl                 *SyncCtrl
+	WebhookCtrl              *WebhookCtrl
+	ReconciliationCtrl       *ReconciliationCtrl
+	LedgerCtrl               *LedgerCtrl
+	SemesterCtrl             *SemesterCtrl
+	ApiKeyCtrl               *ApiKeyCtrl
+	AuditLogCtrl             *AuditLogCtrl
+	LoginSessionCtrl         *LoginSessionCtrl
 }
 
 func SetStandardResponse(ctx *gin.Context, statusCode int, data interface{}) {
@@ -1,9 +1,10 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/controller/web/util"
@@ -175,78 +176,48 @@ func (ctrl *DepositRecordCtrl) AdminGetDepositRecords(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, listVO)
 }
 
-func (ctrl *DepositRecordCtrl) KintoneDepositRecordWebhook(ctx *gin.Context) {
+// ProcessKintoneDepositRecordWebhook 處理從 webhook_events 取出的儲值記錄 webhook
+func (ctrl *DepositRecordCtrl) ProcessKintoneDepositRecordWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookDepositRecordIO{}
-	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl KintoneDepositRecordWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("depositRecordCtrl ProcessKintoneDepositRecordWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addDepositRecord(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updateDepositRecord(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.syncDepositRecord(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deleteDepositRecord(ctx, req)
-	}
-}
-
-func (ctrl *DepositRecordCtrl) addDepositRecord(ctx *gin.Context, req dto.KintoneWebhookDepositRecordIO) {
-	boKintoneDepositRecord, err := ctrl.checkBasicRequestData(req)
-	if err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl addDepositRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return ctrl.deleteDepositRecord(ctx, req)
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneDepositRecord.StudentName,
-		ParentPhone: boKintoneDepositRecord.ParentPhone,
-	}
-
-	if err := ctrl.recordSrv.AddDepositRecord(ctx, boKintoneDepositRecord, boStudentCond); err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl addDepositRecord AddDepositRecord", err, zap.Int("record_ref_id", boKintoneDepositRecord.Id))
-	}
+	return nil
 }
 
-func (ctrl *DepositRecordCtrl) updateDepositRecord(ctx *gin.Context, req dto.KintoneWebhookDepositRecordIO) {
+// syncDepositRecord 新增與更新都以 kintone 上的記錄 upsert，重送或先收到更新的 webhook 不會新增重複的記錄
+func (ctrl *DepositRecordCtrl) syncDepositRecord(ctx context.Context, req dto.KintoneWebhookDepositRecordIO) error {
 	boKintoneDepositRecord, err := ctrl.checkBasicRequestData(req)
 	if err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl updateDepositRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return xerrors.Errorf("depositRecordCtrl syncDepositRecord checkBasicRequestData record_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneDepositRecord.StudentName,
-		ParentPhone: boKintoneDepositRecord.ParentPhone,
+	if err := ctrl.recordSrv.SyncDepositRecord(ctx, boKintoneDepositRecord); err != nil {
+		return xerrors.Errorf("depositRecordCtrl syncDepositRecord SyncDepositRecord record_ref_id: %d: %w", boKintoneDepositRecord.Id, err)
 	}
 
-	boUpdateDepositRecordData := &bo.UpdateDepositRecordData{
-		ChargingDate:         boKintoneDepositRecord.ChargingDate,
-		TaxId:                boKintoneDepositRecord.TaxId,
-		AccountLastFiveYards: boKintoneDepositRecord.AccountLastFiveYards,
-		ChargingAmount:       &boKintoneDepositRecord.ChargingAmount,
-		TeacherName:          boKintoneDepositRecord.TeacherName,
-		DepositedPoints:      &boKintoneDepositRecord.DepositedPoints,
-		ChargingMethod:       boKintoneDepositRecord.ChargingMethod,
-		ChargingStatus:       boKintoneDepositRecord.ChargingStatus,
-		ActualChargingAmount: &boKintoneDepositRecord.ActualChargingAmount,
-	}
-	if err = ctrl.recordSrv.UpdateDepositRecord(ctx, &bo.DepositRecordCond{RecordRefId: boKintoneDepositRecord.Id}, boStudentCond, boUpdateDepositRecordData); err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl updateDepositRecord UpdateDepositRecord", err, zap.Int("record_ref_id", boKintoneDepositRecord.Id))
-	}
+	return nil
 }
 
-func (ctrl *DepositRecordCtrl) deleteDepositRecord(ctx *gin.Context, req dto.KintoneWebhookDepositRecordIO) {
+func (ctrl *DepositRecordCtrl) deleteDepositRecord(ctx context.Context, req dto.KintoneWebhookDepositRecordIO) error {
 	recordId, err := strconv.Atoi(req.RecordId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl deleteDepositRecord Atoi", err, zap.String("record_id", req.RecordId))
-		return
+		return xerrors.Errorf("depositRecordCtrl deleteDepositRecord Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
 	}
 
 	if err := ctrl.recordSrv.DeleteDepositRecord(ctx, &bo.DepositRecordCond{RecordRefId: recordId}); err != nil {
-		ctrl.logger.Error(ctx, "depositRecordCtrl deleteDepositRecord DeleteDepositRecord", err, zap.Int("record_ref_id", recordId))
+		return xerrors.Errorf("depositRecordCtrl deleteDepositRecord DeleteDepositRecord record_ref_id: %d: %w", recordId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *DepositRecordCtrl) checkBasicRequestData(req dto.KintoneWebhookDepositRecordIO) (*bo.KintoneDepositRecord, error) {
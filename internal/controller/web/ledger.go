@@ -0,0 +1,159 @@
+package web
+
+import (
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/strUtil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideLedgerController(ledgerSrv interfaces.ILedgerSrv, reqParse util.IRequestParse, logger logger.ILogger) *LedgerCtrl {
+	return &LedgerCtrl{
+		ledgerSrv: ledgerSrv,
+		reqParse:  reqParse,
+		logger:    logger,
+	}
+}
+
+type LedgerCtrl struct {
+	ledgerSrv interfaces.ILedgerSrv
+	reqParse  util.IRequestParse
+	logger    logger.ILogger
+}
+
+func (ctrl *LedgerCtrl) GetStudentBalanceAsOf(ctx *gin.Context) {
+	req := dto.StudentBalanceAsOfIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	userStore := ctxUtil.GetUserSessionFromCtx(ctx)
+
+	balance, err := ctrl.ledgerSrv.GetStudentBalanceAsOf(ctx, &bo.StudentCond{UserId: userStore.UserId, StudentId: req.StudentId}, *req.AsOf)
+	if err != nil {
+		if errors.Is(err, errs.StudentErr.StudentNotFoundErr) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	balanceVO := toStudentBalanceAsOfVO(balance)
+	balanceVO.StudentName = strUtil.GetStudentNameByStudentName(balance.StudentName)
+	balanceVO.ParentPhone = ""
+
+	SetStandardResponse(ctx, http.StatusOK, balanceVO)
+}
+
+func (ctrl *LedgerCtrl) AdminGetStudentBalanceAsOf(ctx *gin.Context) {
+	studentId, err := strconv.ParseInt(ctx.Param("student_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	req := dto.AdminStudentBalanceAsOfIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	balance, err := ctrl.ledgerSrv.GetStudentBalanceAsOf(ctx, &bo.StudentCond{StudentId: studentId}, *req.AsOf)
+	if err != nil {
+		if errors.Is(err, errs.StudentErr.StudentNotFoundErr) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toStudentBalanceAsOfVO(balance))
+}
+
+func (ctrl *LedgerCtrl) AdminGetStudentLedger(ctx *gin.Context) {
+	studentId, err := strconv.ParseInt(ctx.Param("student_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	studentLedger, err := ctrl.ledgerSrv.GetStudentLedger(ctx, studentId)
+	if err != nil {
+		if errors.Is(err, errs.StudentErr.StudentNotFoundErr) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ledgerVO := dto.AdminStudentLedgerVO{
+		AdminStudentBalanceVO: toAdminStudentBalanceVO(&studentLedger.StudentBalance),
+		Entries:               toLedgerEntryVOs(studentLedger.Entries),
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, ledgerVO)
+}
+
+func (ctrl *LedgerCtrl) AdminGetBalanceMismatches(ctx *gin.Context) {
+	balances, err := ctrl.ledgerSrv.GetBalanceMismatches(ctx)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	balanceVOs := make([]dto.AdminStudentBalanceVO, 0, len(balances))
+	for _, balance := range balances {
+		balanceVOs = append(balanceVOs, toAdminStudentBalanceVO(balance))
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, balanceVOs)
+}
+
+func toStudentBalanceAsOfVO(balance *bo.StudentBalanceAsOf) dto.StudentBalanceAsOfVO {
+	return dto.StudentBalanceAsOfVO{
+		StudentId:   strconv.FormatInt(balance.StudentId, 10),
+		StudentName: balance.StudentName,
+		ParentPhone: balance.ParentPhone,
+		AsOf:        balance.AsOf.Format(time.RFC3339),
+		Balance:     balance.Balance,
+		Entries:     toLedgerEntryVOs(balance.Entries),
+	}
+}
+
+func toLedgerEntryVOs(entries []*bo.LedgerEntry) []dto.LedgerEntryVO {
+	entryVOs := make([]dto.LedgerEntryVO, 0, len(entries))
+	for _, entry := range entries {
+		entryVOs = append(entryVOs, dto.LedgerEntryVO{
+			EntryType:   string(entry.EntryType),
+			RecordRefId: entry.RecordRefId,
+			OccurredAt:  entry.OccurredAt.Format(time.RFC3339),
+			Change:      entry.Change,
+			Balance:     entry.Balance,
+		})
+	}
+	return entryVOs
+}
+
+func toAdminStudentBalanceVO(balance *bo.StudentBalance) dto.AdminStudentBalanceVO {
+	return dto.AdminStudentBalanceVO{
+		StudentId:        strconv.FormatInt(balance.StudentId, 10),
+		StudentName:      balance.StudentName,
+		ParentPhone:      balance.ParentPhone,
+		DerivedBalance:   balance.DerivedBalance,
+		PointCardBalance: balance.PointCardBalance,
+		HasPointCard:     balance.HasPointCard,
+		BalanceMatched:   balance.BalanceMatched(),
+	}
+}
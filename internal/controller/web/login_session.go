@@ -0,0 +1,198 @@
+package web
+
+import (
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/constant/loginsession"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideLoginSessionController(loginSessionSrv interfaces.ILoginSessionSrv, userSrv interfaces.IUserSrv, reqParse util.IRequestParse, logger logger.ILogger) *LoginSessionCtrl {
+	return &LoginSessionCtrl{
+		loginSessionSrv: loginSessionSrv,
+		userSrv:         userSrv,
+		reqParse:        reqParse,
+		logger:          logger,
+	}
+}
+
+type LoginSessionCtrl struct {
+	loginSessionSrv interfaces.ILoginSessionSrv
+	userSrv         interfaces.IUserSrv
+	reqParse        util.IRequestParse
+	logger          logger.ILogger
+}
+
+// GetLoginSessions 目前登入的使用者所有有效的登入
+func (ctrl *LoginSessionCtrl) GetLoginSessions(ctx *gin.Context) {
+	req := &dto.GetLoginSessionsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.LoginSessionCond{
+		UserId:       ctxUtil.GetUserSessionFromCtx(ctx).UserId,
+		ActiveOnly:   true,
+		CurrentToken: ctxUtil.GetSessionTokenFromCtx(ctx),
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "last_seen_at desc",
+		},
+	}
+	ctrl.getLoginSessions(ctx, boCond)
+}
+
+// RevokeLoginSession 使用者只能撤銷自己的登入
+func (ctrl *LoginSessionCtrl) RevokeLoginSession(ctx *gin.Context) {
+	sessionId, err := strconv.ParseInt(ctx.Param("session_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	ctrl.revokeLoginSession(ctx, &bo.LoginSessionCond{SessionId: sessionId, UserId: ctxUtil.GetUserSessionFromCtx(ctx).UserId})
+}
+
+func (ctrl *LoginSessionCtrl) AdminGetUserLoginSessions(ctx *gin.Context) {
+	userId, valid := ctrl.validateUser(ctx)
+	if !valid {
+		return
+	}
+
+	req := &dto.AdminGetLoginSessionsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.LoginSessionCond{
+		UserId: userId,
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "session_id desc",
+		},
+	}
+	if req.Active != nil {
+		boCond.ActiveOnly = *req.Active
+	}
+	ctrl.getLoginSessions(ctx, boCond)
+}
+
+func (ctrl *LoginSessionCtrl) AdminRevokeUserLoginSession(ctx *gin.Context) {
+	userId, valid := ctrl.validateUser(ctx)
+	if !valid {
+		return
+	}
+	sessionId, err := strconv.ParseInt(ctx.Param("session_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	ctrl.revokeLoginSession(ctx, &bo.LoginSessionCond{SessionId: sessionId, UserId: userId})
+}
+
+// AdminRevokeUserLoginSessions 撤銷使用者所有的登入，讓使用者在所有裝置上登出
+func (ctrl *LoginSessionCtrl) AdminRevokeUserLoginSessions(ctx *gin.Context) {
+	userId, valid := ctrl.validateUser(ctx)
+	if !valid {
+		return
+	}
+
+	data := &bo.RevokeLoginSessionsData{UserIds: []int64{userId}, Reason: loginsession.ReasonRevoked}
+	if err := ctrl.loginSessionSrv.RevokeUserLoginSessions(ctx, data); err != nil {
+		ctrl.logger.Error(ctx, "LoginSessionCtrl AdminRevokeUserLoginSessions", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, nil)
+}
+
+func (ctrl *LoginSessionCtrl) getLoginSessions(ctx *gin.Context, boCond *bo.LoginSessionCond) {
+	loginSessions, pagerResult, err := ctrl.loginSessionSrv.GetLoginSessions(ctx, boCond)
+	if err != nil {
+		ctrl.logger.Error(ctx, "LoginSessionCtrl getLoginSessions", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	loginSessionsVO := make([]dto.LoginSessionVO, 0, len(loginSessions))
+	for _, loginSession := range loginSessions {
+		loginSessionsVO = append(loginSessionsVO, toLoginSessionVO(loginSession))
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, dto.ListVO{
+		List: loginSessionsVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
+	})
+}
+
+func (ctrl *LoginSessionCtrl) revokeLoginSession(ctx *gin.Context, boCond *bo.LoginSessionCond) {
+	loginSession, err := ctrl.loginSessionSrv.RevokeLoginSession(ctx, boCond)
+	if err != nil {
+		if errors.Is(err, errs.LoginSessionErr.SessionNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		ctrl.logger.Error(ctx, "LoginSessionCtrl revokeLoginSession", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toLoginSessionVO(loginSession))
+}
+
+// validateUser 管理者帳號的登入不透過這些 API 管理
+func (ctrl *LoginSessionCtrl) validateUser(ctx *gin.Context) (int64, bool) {
+	userId, err := strconv.ParseInt(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return 0, false
+	}
+
+	if _, err := ctrl.userSrv.GetUser(ctx, &bo.UserCond{UserId: userId, Level: user.User}); err != nil {
+		if errors.Is(err, errs.UserErr.UserNotFoundErr) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return 0, false
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	return userId, true
+}
+
+func toLoginSessionVO(loginSession *bo.LoginSession) dto.LoginSessionVO {
+	return dto.LoginSessionVO{
+		SessionId:     strconv.FormatInt(loginSession.SessionId, 10),
+		UserId:        strconv.FormatInt(loginSession.UserId, 10),
+		UserAgent:     loginSession.UserAgent,
+		Ip:            loginSession.Ip,
+		ExpiresAt:     loginSession.ExpiresAt.Format(time.RFC3339),
+		LastSeenAt:    loginSession.LastSeenAt.Format(time.RFC3339),
+		RevokedAt:     formatOptionalTime(loginSession.RevokedAt),
+		RevokedReason: string(loginSession.RevokedReason),
+		CreatedAt:     loginSession.CreatedAt.Format(time.RFC3339),
+		IsCurrent:     loginSession.IsCurrent,
+	}
+}
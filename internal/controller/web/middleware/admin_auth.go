@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/context"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"net/http"
+)
+
+type IAdminAuthMiddleware interface {
+	IMiddleware
+}
+
+func ProvideAdminAuthMiddleware(logger logger.ILogger, config config.IConfigEnv, userCommonSrv interfaces.IUserCommonSrv) IAdminAuthMiddleware {
+	return &adminAuthMiddleware{
+		logger:        logger,
+		cfg:           config,
+		userCommonSrv: userCommonSrv,
+	}
+}
+
+type adminAuthMiddleware struct {
+	logger        logger.ILogger
+	cfg           config.IConfigEnv
+	userCommonSrv interfaces.IUserCommonSrv
+}
+
+// Handle 驗證登入的管理者帳號
+// 每次都從 db 取得帳號狀態與角色，停用或調整角色後立即生效，不用等 session 過期
+func (m *adminAuthMiddleware) Handle(ctx *gin.Context) {
+	// 已經以 API key 驗證
+	if ctxUtil.GetApiKeyFromCtx(ctx) != nil {
+		ctx.Next()
+		return
+	}
+
+	store := auth.GetUserSession(ctx, user.SessionUserKey)
+	if store == nil || store.Level != user.Admin {
+		SetResp(ctx, http.StatusUnauthorized, errs.CommonErr.AuthFailedError)
+		ctx.Abort()
+		return
+	}
+
+	admin, err := m.userCommonSrv.GetUser(ctx, &bo.UserCond{UserId: store.UserId, Level: user.Admin})
+	if err != nil {
+		m.logger.Error(ctx, "adminAuthMiddleware userCommonSrv.GetUser", err)
+		SetResp(ctx, http.StatusUnauthorized, errs.CommonErr.AuthFailedError)
+		ctx.Abort()
+		return
+	}
+	if admin.Status != user.Activate || admin.Role == user.RoleNone {
+		SetResp(ctx, http.StatusUnauthorized, errs.CommonErr.AuthFailedError)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(context.UserSession, auth.UserSession{
+		UserId:  admin.UserId,
+		Level:   admin.Level,
+		Account: admin.Account,
+		Role:    admin.Role,
+	})
+	ctx.Set(context.SessionToken, sessions.Default(ctx).ID())
+
+	if err := auth.RenewSession(ctx, m.cfg.GetAppEnv()); err != nil {
+		m.logger.Error(ctx, "adminAuthMiddleware auth.RenewSession", err)
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubUserCommonSrv struct {
+	interfaces.IUserCommonSrv
+	users map[int64]*bo.User
+}
+
+func (s *stubUserCommonSrv) GetUser(_ context.Context, cond *bo.UserCond) (*bo.User, error) {
+	u, ok := s.users[cond.UserId]
+	if !ok || (cond.Level != user.LevelNone && u.Level != cond.Level) {
+		return nil, errs.DbErr.NoRow
+	}
+	return u, nil
+}
+
+type stubApiKeySrv struct {
+	interfaces.IApiKeySrv
+	keys map[string]*bo.ApiKey
+}
+
+func (s *stubApiKeySrv) AuthenticateApiKey(_ context.Context, key string) (*bo.ApiKey, error) {
+	apiKey, ok := s.keys[key]
+	if !ok {
+		return nil, errs.ApiKeyErr.ApiKeyInvalidError
+	}
+	return apiKey, nil
+}
+
+func newTestAdminRouter(users map[int64]*bo.User, keys map[string]*bo.ApiKey) *gin.Engine {
+	cfg := config.NewKintoneConfigEnv("", "", config.AppIdInfo{})
+	log := logger.ProviderILogger(cfg)
+	m := ProvideAdminAuthMiddleware(log, cfg, &stubUserCommonSrv{users: users})
+	apiKeyMw := ProvideApiKeyMiddleware(log, &stubApiKeySrv{keys: keys})
+
+	auth.RegSessionValueTypes()
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(ProvideResponseMiddleware(log).Handle)
+	g.Use(sessions.Sessions(user.SessionID, cookie.NewStore([]byte("test-secret"))))
+
+	// 模擬登入，寫入 session 中的 user id 與 level
+	g.POST("/login/:user_id/:level", func(ctx *gin.Context) {
+		userId, _ := strconv.ParseInt(ctx.Param("user_id"), 10, 64)
+		session := sessions.Default(ctx)
+		session.Set(user.SessionUserKey, &auth.UserSession{UserId: userId, Level: user.UserLevelToEnum(ctx.Param("level"))})
+		_ = session.Save()
+		SetResp(ctx, http.StatusOK, nil)
+	})
+
+	ok := func(ctx *gin.Context) { SetResp(ctx, http.StatusOK, nil) }
+	adminGroup := g.Group("", m.Handle)
+	adminGroup.GET("/me", ok)
+	authGroup := g.Group("", apiKeyMw.Handle, m.Handle)
+	authGroup.GET("/records", RequireScope(scope.RecordsRead), ok)
+	authGroup.POST("/sync", RequireScope(scope.SyncWrite), ok)
+	authGroup.POST("/settle", RequireScope(scope.SettlementWrite), ok)
+	authGroup.GET("/api_keys", RequireScope(scope.AdminManage), ok)
+	return g
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	users := map[int64]*bo.User{
+		1: {UserId: 1, Account: "viewer", Level: user.Admin, Role: user.RoleViewer, Status: user.Activate},
+		2: {UserId: 2, Account: "operator", Level: user.Admin, Role: user.RoleOperator, Status: user.Activate},
+		3: {UserId: 3, Account: "finance", Level: user.Admin, Role: user.RoleFinance, Status: user.Activate},
+		4: {UserId: 4, Account: "manager", Level: user.Admin, Role: user.RoleManager, Status: user.Activate},
+		5: {UserId: 5, Account: "deactivated", Level: user.Admin, Role: user.RoleFinance, Status: user.Deactivate},
+		6: {UserId: 6, Account: "parent", Level: user.User, Status: user.Activate},
+		7: {UserId: 7, Account: "no_role", Level: user.Admin, Status: user.Activate},
+	}
+	g := newTestAdminRouter(users, nil)
+
+	login := func(t *testing.T, userId int64, level string) []*http.Cookie {
+		req := httptest.NewRequest(http.MethodPost, "/login/"+strconv.FormatInt(userId, 10)+"/"+level, nil)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Result().Cookies()
+	}
+
+	tests := []struct {
+		name       string
+		userId     int64
+		level      string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "not logged in", method: http.MethodGet, path: "/records", wantStatus: http.StatusUnauthorized},
+		{name: "viewer reads", userId: 1, level: "admin", method: http.MethodGet, path: "/records", wantStatus: http.StatusOK},
+		{name: "viewer cannot sync", userId: 1, level: "admin", method: http.MethodPost, path: "/sync", wantStatus: http.StatusForbidden},
+		{name: "operator syncs", userId: 2, level: "admin", method: http.MethodPost, path: "/sync", wantStatus: http.StatusOK},
+		{name: "operator cannot settle", userId: 2, level: "admin", method: http.MethodPost, path: "/settle", wantStatus: http.StatusForbidden},
+		{name: "finance settles", userId: 3, level: "admin", method: http.MethodPost, path: "/settle", wantStatus: http.StatusOK},
+		{name: "finance cannot sync", userId: 3, level: "admin", method: http.MethodPost, path: "/sync", wantStatus: http.StatusForbidden},
+		{name: "manager settles", userId: 4, level: "admin", method: http.MethodPost, path: "/settle", wantStatus: http.StatusOK},
+		{name: "manager manages", userId: 4, level: "admin", method: http.MethodGet, path: "/api_keys", wantStatus: http.StatusOK},
+		{name: "finance cannot manage", userId: 3, level: "admin", method: http.MethodGet, path: "/api_keys", wantStatus: http.StatusForbidden},
+		{name: "deactivated admin", userId: 5, level: "admin", method: http.MethodGet, path: "/records", wantStatus: http.StatusUnauthorized},
+		{name: "parent session", userId: 6, level: "user", method: http.MethodGet, path: "/records", wantStatus: http.StatusUnauthorized},
+		// session 中的 level 被竄改也會以 db 為準
+		{name: "parent claims admin", userId: 6, level: "admin", method: http.MethodGet, path: "/records", wantStatus: http.StatusUnauthorized},
+		{name: "admin without role", userId: 7, level: "admin", method: http.MethodGet, path: "/records", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.userId != 0 {
+				for _, c := range login(t, tt.userId, tt.level) {
+					req.AddCookie(c)
+				}
+			}
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestApiKeyMiddleware(t *testing.T) {
+	users := map[int64]*bo.User{
+		1: {UserId: 1, Account: "viewer", Level: user.Admin, Role: user.RoleViewer, Status: user.Activate},
+	}
+	keys := map[string]*bo.ApiKey{
+		"jsk_sync": {KeyId: 1, Name: "kintone", Scopes: []scope.Scope{scope.SyncWrite}},
+		"jsk_read": {KeyId: 2, Name: "report", Scopes: []scope.Scope{scope.RecordsRead}},
+	}
+	g := newTestAdminRouter(users, keys)
+
+	tests := []struct {
+		name       string
+		header     string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "key with scope", header: "Bearer jsk_sync", method: http.MethodPost, path: "/sync", wantStatus: http.StatusOK},
+		{name: "key without scope", header: "Bearer jsk_sync", method: http.MethodPost, path: "/settle", wantStatus: http.StatusForbidden},
+		// 非寫入的 scope 也要明確給予
+		{name: "key without read scope", header: "Bearer jsk_sync", method: http.MethodGet, path: "/records", wantStatus: http.StatusForbidden},
+		{name: "read key", header: "Bearer jsk_read", method: http.MethodGet, path: "/records", wantStatus: http.StatusOK},
+		{name: "key cannot manage", header: "Bearer jsk_sync", method: http.MethodGet, path: "/api_keys", wantStatus: http.StatusForbidden},
+		{name: "invalid key", header: "Bearer jsk_unknown", method: http.MethodPost, path: "/sync", wantStatus: http.StatusUnauthorized},
+		{name: "not bearer falls back to session", header: "Basic jsk_sync", method: http.MethodPost, path: "/sync", wantStatus: http.StatusUnauthorized},
+		{name: "session only route rejects key", header: "Bearer jsk_sync", method: http.MethodGet, path: "/me", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", tt.header)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestAdminRoleHasScope(t *testing.T) {
+	assert.True(t, user.RoleViewer.HasScope(scope.RecordsRead))
+	assert.False(t, user.RoleViewer.HasScope(scope.SyncWrite))
+	assert.True(t, user.RoleOperator.HasScope(scope.SyncWrite))
+	assert.False(t, user.RoleOperator.HasScope(scope.SettlementWrite))
+	assert.True(t, user.RoleFinance.HasScope(scope.SettlementWrite))
+	assert.False(t, user.RoleFinance.HasScope(scope.AdminManage))
+	assert.True(t, user.RoleManager.HasScope(scope.AdminManage))
+	assert.False(t, user.RoleViewer.HasScope(scope.AuditRead))
+	assert.True(t, user.RoleManager.HasScope(scope.AuditRead))
+	assert.False(t, user.RoleNone.HasScope(scope.RecordsRead))
+}
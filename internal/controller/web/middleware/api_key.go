@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"jaystar/internal/constant/apikey"
+	"jaystar/internal/constant/context"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strings"
+)
+
+type IApiKeyMiddleware interface {
+	// Handle 有帶 API key 時驗證 key，沒有帶時交給 IAdminAuthMiddleware 驗證管理者登入
+	IMiddleware
+}
+
+func ProvideApiKeyMiddleware(logger logger.ILogger, apiKeySrv interfaces.IApiKeySrv) IApiKeyMiddleware {
+	return &apiKeyMiddleware{
+		logger:    logger,
+		apiKeySrv: apiKeySrv,
+	}
+}
+
+type apiKeyMiddleware struct {
+	logger    logger.ILogger
+	apiKeySrv interfaces.IApiKeySrv
+}
+
+func (m *apiKeyMiddleware) Handle(ctx *gin.Context) {
+	key, ok := bearerApiKey(ctx)
+	if !ok {
+		ctx.Next()
+		return
+	}
+
+	apiKey, err := m.apiKeySrv.AuthenticateApiKey(ctx, key)
+	if err != nil {
+		m.logger.Error(ctx, "apiKeyMiddleware apiKeySrv.AuthenticateApiKey", err, zap.String("client_ip", ctx.ClientIP()))
+		SetResp(ctx, http.StatusUnauthorized, errs.ApiKeyErr.ApiKeyInvalidError)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(context.ApiKey, apiKey)
+	ctx.Next()
+}
+
+// bearerApiKey 只處理 Authorization: Bearer <key>，其他 Authorization 一律視為沒有帶 key
+func bearerApiKey(ctx *gin.Context) (string, bool) {
+	header := ctx.GetHeader(apikey.HeaderKey)
+	if !strings.HasPrefix(header, apikey.BearerPrefix) {
+		return "", false
+	}
+
+	key := strings.TrimSpace(strings.TrimPrefix(header, apikey.BearerPrefix))
+	return key, key != ""
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/context"
@@ -36,6 +37,7 @@ func (m *authMiddleware) Handle(ctx *gin.Context) {
 	}
 
 	ctx.Set(context.UserSession, *store)
+	ctx.Set(context.SessionToken, sessions.Default(ctx).ID())
 
 	if err := auth.RenewSession(ctx, m.cfg.GetAppEnv()); err != nil {
 		m.logger.Error(ctx, "authMiddleware auth.RenewSession", err)
@@ -10,8 +10,10 @@ import (
 	"jaystar/internal/constant/context"
 	"jaystar/internal/constant/log"
 	"jaystar/internal/constant/user"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/utils"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -68,7 +70,7 @@ func (mw *httpLogMiddleware) loggerStart(ctx *gin.Context, m map[string]interfac
 
 	m[log.ClientIP] = ctx.ClientIP()
 
-	m[log.Query] = ctx.Request.URL.RawQuery
+	m[log.Query] = maskQuery(ctx.Request.URL)
 
 	m[log.UserAgent] = ctx.Request.UserAgent()
 
@@ -121,3 +123,14 @@ func (mw *httpLogMiddleware) processLogMessage(ctx *gin.Context, m map[string]in
 	logMessageByte, _ := json.Marshal(m)
 	return string(logMessageByte)
 }
+
+// maskQuery 不在 log 中留下 webhook 的 token
+func maskQuery(u *url.URL) string {
+	query := u.Query()
+	if !query.Has(webhook.TokenQueryKey) {
+		return u.RawQuery
+	}
+
+	query.Set(webhook.TokenQueryKey, "***")
+	return query.Encode()
+}
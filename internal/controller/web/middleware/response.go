@@ -34,6 +34,10 @@ func (mw *respMiddleware) Handle(ctx *gin.Context) {
 	ctx.Next()
 
 	// after request
+	// handler 已自行寫入 response (e.g. 下載檔案)
+	if ctx.Writer.Written() {
+		return
+	}
 	mw.standardResponse(ctx)
 }
 
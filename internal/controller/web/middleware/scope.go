@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"net/http"
+)
+
+// RequireScope 需搭配 IApiKeyMiddleware、IAdminAuthMiddleware 使用
+// API key 依建立時指定的 scope，管理者依角色判斷
+func RequireScope(s scope.Scope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		allowed := false
+		if apiKey := ctxUtil.GetApiKeyFromCtx(ctx); apiKey != nil {
+			allowed = apiKey.HasScope(s)
+		} else {
+			allowed = ctxUtil.GetUserSessionFromCtx(ctx).Role.HasScope(s)
+		}
+
+		if !allowed {
+			SetResp(ctx, http.StatusForbidden, errs.CommonErr.AuthDeniedError)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+	"golang.org/x/xerrors"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/errs"
+	"net"
+	"net/http"
+	"time"
+)
+
+type ISessionMiddleware interface {
+	// Handle 載入 cookie 對應的登入 session，之後以 sessions.Default 取得
+	IMiddleware
+}
+
+func ProvideSessionMiddleware(config config.IConfigEnv, loginSessionSrv interfaces.ILoginSessionSrv) ISessionMiddleware {
+	return newSessionMiddleware(loginSessionSrv, config.GetSessionConfig().KeyPairs()...)
+}
+
+func newSessionMiddleware(loginSessionSrv interfaces.ILoginSessionSrv, keyPairs ...[]byte) *sessionMiddleware {
+	store := &dbSessionStore{
+		loginSessionSrv: loginSessionSrv,
+		codecs:          securecookie.CodecsFromPairs(keyPairs...),
+		options:         &gsessions.Options{Path: "/", MaxAge: 60 * 60},
+	}
+
+	return &sessionMiddleware{
+		handler: sessions.Sessions(user.SessionID, store),
+	}
+}
+
+type sessionMiddleware struct {
+	handler gin.HandlerFunc
+}
+
+type clientIpCtxKey struct{}
+
+func (m *sessionMiddleware) Handle(ctx *gin.Context) {
+	// store 只拿得到 http.Request，來源 IP 需要由 gin 依 trusted proxies 判斷
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), clientIpCtxKey{}, ctx.ClientIP()))
+	m.handler(ctx)
+}
+
+// dbSessionStore session 內容保存在 db，cookie 只保存簽章過的 token
+// db 中的 session 被撤銷或過期後，cookie 即使還沒過期也會視為未登入
+type dbSessionStore struct {
+	loginSessionSrv interfaces.ILoginSessionSrv
+	codecs          []securecookie.Codec
+	options         *gsessions.Options
+}
+
+func (s *dbSessionStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+func (s *dbSessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New 沒有 cookie、cookie 無效或 session 已撤銷、過期時回傳空的 session
+func (s *dbSessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, c.Value, &token, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	loginSession, err := s.loginSessionSrv.GetActiveLoginSession(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, errs.LoginSessionErr.SessionNotFoundError) {
+			return session, nil
+		}
+		return session, xerrors.Errorf("dbSessionStore New loginSessionSrv.GetActiveLoginSession: %w", err)
+	}
+	if err := securecookie.DecodeMulti(name, loginSession.Data, &session.Values, s.codecs...); err != nil {
+		return session, xerrors.Errorf("dbSessionStore New securecookie.DecodeMulti: %w", err)
+	}
+
+	session.ID = token
+	session.IsNew = false
+	return session, nil
+}
+
+// Save MaxAge <= 0 時為登出，撤銷 db 中的 session 並清除 cookie
+func (s *dbSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	ctx := r.Context()
+
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.loginSessionSrv.EndLoginSession(ctx, session.ID); err != nil {
+				return xerrors.Errorf("dbSessionStore Save loginSessionSrv.EndLoginSession: %w", err)
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	userSession, ok := session.Values[user.SessionUserKey].(*auth.UserSession)
+	if !ok || userSession == nil {
+		return xerrors.Errorf("dbSessionStore Save: %w", errs.LoginSessionErr.SessionUserEmptyError)
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return xerrors.Errorf("dbSessionStore Save securecookie.EncodeMulti values: %w", err)
+	}
+
+	data := &bo.SaveLoginSessionData{
+		Token:     session.ID,
+		UserId:    userSession.UserId,
+		Data:      encoded,
+		UserAgent: r.UserAgent(),
+		Ip:        requestClientIp(r),
+		ExpiresAt: time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+	if session.ID == "" {
+		token, err := s.loginSessionSrv.CreateLoginSession(ctx, data)
+		if err != nil {
+			return xerrors.Errorf("dbSessionStore Save loginSessionSrv.CreateLoginSession: %w", err)
+		}
+		session.ID = token
+	} else if err := s.loginSessionSrv.RefreshLoginSession(ctx, data); err != nil {
+		return xerrors.Errorf("dbSessionStore Save loginSessionSrv.RefreshLoginSession: %w", err)
+	}
+
+	cookieValue, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return xerrors.Errorf("dbSessionStore Save securecookie.EncodeMulti token: %w", err)
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), cookieValue, session.Options))
+
+	return nil
+}
+
+// Regenerate 登入時撤銷 cookie 帶來的 session 並清除 token，避免 session fixation
+// 之後 Save 會由 CreateLoginSession 發行新的 token
+func (s *dbSessionStore) Regenerate(r *http.Request, session *gsessions.Session) error {
+	if session.ID != "" {
+		if err := s.loginSessionSrv.EndLoginSession(r.Context(), session.ID); err != nil {
+			return xerrors.Errorf("dbSessionStore Regenerate loginSessionSrv.EndLoginSession: %w", err)
+		}
+	}
+
+	session.ID = ""
+	session.IsNew = true
+	session.Values = make(map[interface{}]interface{})
+	return nil
+}
+
+func requestClientIp(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIpCtxKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
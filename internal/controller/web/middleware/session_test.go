@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/loginsession"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoginSessionSrv struct {
+	interfaces.ILoginSessionSrv
+	sessions map[string]*bo.LoginSession
+	seq      int
+}
+
+func (s *fakeLoginSessionSrv) GetActiveLoginSession(_ context.Context, token string) (*bo.LoginSession, error) {
+	loginSession, ok := s.sessions[token]
+	if !ok || !loginSession.IsActive(time.Now()) {
+		return nil, errs.LoginSessionErr.SessionNotFoundError
+	}
+	return loginSession, nil
+}
+
+func (s *fakeLoginSessionSrv) CreateLoginSession(_ context.Context, data *bo.SaveLoginSessionData) (string, error) {
+	s.seq++
+	token := "token" + strconv.Itoa(s.seq)
+	s.sessions[token] = &bo.LoginSession{SessionId: int64(s.seq), UserId: data.UserId, Data: data.Data, Ip: data.Ip, ExpiresAt: data.ExpiresAt}
+	return token, nil
+}
+
+func (s *fakeLoginSessionSrv) RefreshLoginSession(_ context.Context, data *bo.SaveLoginSessionData) error {
+	if loginSession, ok := s.sessions[data.Token]; ok && loginSession.RevokedAt == nil && loginSession.UserId == data.UserId {
+		loginSession.Data = data.Data
+		loginSession.ExpiresAt = data.ExpiresAt
+	}
+	return nil
+}
+
+func (s *fakeLoginSessionSrv) EndLoginSession(_ context.Context, token string) error {
+	s.revoke(token, loginsession.ReasonLogout)
+	return nil
+}
+
+func (s *fakeLoginSessionSrv) revoke(token string, reason loginsession.RevokeReason) {
+	if loginSession, ok := s.sessions[token]; ok && loginSession.RevokedAt == nil {
+		now := time.Now()
+		loginSession.RevokedAt = &now
+		loginSession.RevokedReason = reason
+	}
+}
+
+var (
+	testAuthKey    = []byte("0123456789abcdef0123456789abcdef")
+	testEncryptKey = []byte("abcdef0123456789abcdef0123456789")
+	newAuthKey     = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newTestSessionRouter(srv *fakeLoginSessionSrv, keyPairs ...[]byte) *gin.Engine {
+	cfg := config.NewKintoneConfigEnv("", "", config.AppIdInfo{})
+	log := logger.ProviderILogger(cfg)
+
+	auth.RegSessionValueTypes()
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(ProvideResponseMiddleware(log).Handle)
+	g.Use(newSessionMiddleware(srv, keyPairs...).Handle)
+
+	g.POST("/login/:user_id", func(ctx *gin.Context) {
+		userId, _ := strconv.ParseInt(ctx.Param("user_id"), 10, 64)
+		if err := auth.RegenerateSession(ctx); err != nil {
+			SetResp(ctx, http.StatusBadRequest, err)
+			return
+		}
+		session := sessions.Default(ctx)
+		session.Set(user.SessionUserKey, &auth.UserSession{UserId: userId, Level: user.User})
+		if err := session.Save(); err != nil {
+			SetResp(ctx, http.StatusBadRequest, err)
+			return
+		}
+		SetResp(ctx, http.StatusOK, nil)
+	})
+
+	authGroup := g.Group("", ProvideAuthMiddleware(log, cfg).Handle)
+	authGroup.GET("/me", func(ctx *gin.Context) { SetResp(ctx, http.StatusOK, nil) })
+	authGroup.POST("/logout", func(ctx *gin.Context) {
+		session := sessions.Default(ctx)
+		session.Clear()
+		session.Options(sessions.Options{Path: "/", MaxAge: -1})
+		_ = session.Save()
+		SetResp(ctx, http.StatusOK, nil)
+	})
+	return g
+}
+
+func serve(g *gin.Engine, method, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestDbSessionStore(t *testing.T) {
+	login := func(t *testing.T, g *gin.Engine) []*http.Cookie {
+		w := serve(g, http.MethodPost, "/login/1", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Result().Cookies()
+	}
+
+	t.Run("cookie only holds token", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+		cookies := login(t, g)
+
+		require.Len(t, srv.sessions, 1)
+		assert.Equal(t, int64(1), srv.sessions["token1"].UserId)
+		assert.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/me", cookies).Code)
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+		cookies := login(t, g)
+
+		srv.revoke("token1", loginsession.ReasonPasswordChanged)
+		assert.Equal(t, http.StatusUnauthorized, serve(g, http.MethodGet, "/me", cookies).Code)
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+		cookies := login(t, g)
+
+		srv.sessions["token1"].ExpiresAt = time.Now().Add(-time.Second)
+		assert.Equal(t, http.StatusUnauthorized, serve(g, http.MethodGet, "/me", cookies).Code)
+	})
+
+	t.Run("logout revokes session", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+		cookies := login(t, g)
+
+		require.Equal(t, http.StatusOK, serve(g, http.MethodPost, "/logout", cookies).Code)
+		assert.Equal(t, loginsession.ReasonLogout, srv.sessions["token1"].RevokedReason)
+		// 登出前的 cookie 也無法再使用
+		assert.Equal(t, http.StatusUnauthorized, serve(g, http.MethodGet, "/me", cookies).Code)
+	})
+
+	t.Run("login issues new token", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+
+		// 事先植入的 cookie 在登入後不會變成受害者的 session
+		planted := serve(g, http.MethodPost, "/login/2", nil).Result().Cookies()
+		w := serve(g, http.MethodPost, "/login/1", planted)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		require.Len(t, srv.sessions, 2)
+		assert.Equal(t, int64(2), srv.sessions["token1"].UserId)
+		assert.Equal(t, loginsession.ReasonLogout, srv.sessions["token1"].RevokedReason)
+		assert.Equal(t, int64(1), srv.sessions["token2"].UserId)
+		assert.Equal(t, http.StatusUnauthorized, serve(g, http.MethodGet, "/me", planted).Code)
+		assert.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/me", w.Result().Cookies()).Code)
+	})
+
+	t.Run("rotated keys", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		cookies := login(t, newTestSessionRouter(srv, testAuthKey, testEncryptKey))
+
+		// 舊的 key 保留在後面時仍然可以讀取
+		rotated := newTestSessionRouter(srv, newAuthKey, nil, testAuthKey, testEncryptKey)
+		assert.Equal(t, http.StatusOK, serve(rotated, http.MethodGet, "/me", cookies).Code)
+
+		removed := newTestSessionRouter(srv, newAuthKey, nil)
+		assert.Equal(t, http.StatusUnauthorized, serve(removed, http.MethodGet, "/me", cookies).Code)
+	})
+
+	t.Run("tampered cookie", func(t *testing.T) {
+		srv := &fakeLoginSessionSrv{sessions: map[string]*bo.LoginSession{}}
+		g := newTestSessionRouter(srv, testAuthKey, testEncryptKey)
+		login(t, g)
+
+		cookies := []*http.Cookie{{Name: user.SessionID, Value: "token1"}}
+		assert.Equal(t, http.StatusUnauthorized, serve(g, http.MethodGet, "/me", cookies).Code)
+	})
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"io"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/errs"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type IWebhookAuthMiddleware interface {
+	// Handle 驗證 webhook 來自 kintone 上 source 對應的應用程式
+	Handle(source webhook.Source) gin.HandlerFunc
+}
+
+func ProvideWebhookAuthMiddleware(logger logger.ILogger, cfg config.IConfigEnv) IWebhookAuthMiddleware {
+	kintoneCfg := cfg.GetKintoneConfig()
+	appId, tokens := kintoneCfg.AppId, kintoneCfg.Webhook.Tokens
+
+	allowedNets, err := parseAllowedIps(kintoneCfg.Webhook.AllowedIps)
+	if err != nil {
+		log.Fatalf("🔔🔔🔔 fatal error webhook allowed_ips: %v 🔔🔔🔔", err)
+	}
+
+	return &webhookAuthMiddleware{
+		logger: logger,
+		apps: map[webhook.Source]webhookApp{
+			webhook.SourceStudent:              {appId: appId.StudentInfo, token: tokens.StudentInfo},
+			webhook.SourceDepositRecord:        {appId: appId.DepositRecord, token: tokens.DepositRecord},
+			webhook.SourceReduceRecord:         {appId: appId.ReduceRecord, token: tokens.ReduceRecord},
+			webhook.SourceSchedule:             {appId: appId.ScheduleRecord, token: tokens.ScheduleRecord},
+			webhook.SourceSemesterSettleRecord: {appId: appId.SemesterSettleRecord, token: tokens.SemesterSettleRecord},
+			webhook.SourcePointCard:            {appId: appId.PointCard, token: tokens.PointCard},
+		},
+		allowedNets: allowedNets,
+	}
+}
+
+type webhookApp struct {
+	appId string
+	token string
+}
+
+type webhookAuthMiddleware struct {
+	logger logger.ILogger
+	apps   map[webhook.Source]webhookApp
+	// allowedNets 為 nil 時不限制來源 IP
+	allowedNets []*net.IPNet
+}
+
+func (m *webhookAuthMiddleware) Handle(source webhook.Source) gin.HandlerFunc {
+	app := m.apps[source]
+
+	return func(ctx *gin.Context) {
+		if !m.isAllowedIp(ctx.ClientIP()) {
+			m.reject(ctx, source, http.StatusForbidden, errs.WebhookErr.SourceIpDeniedError)
+			return
+		}
+
+		if !isValidWebhookToken(app.token, webhookToken(ctx)) {
+			m.reject(ctx, source, http.StatusUnauthorized, errs.WebhookErr.TokenInvalidError)
+			return
+		}
+
+		payload, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, webhook.MaxPayloadBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				m.reject(ctx, source, http.StatusRequestEntityTooLarge, xerrors.Errorf("io.ReadAll: %v: %w", err, errs.WebhookErr.PayloadTooLargeError))
+				return
+			}
+			m.reject(ctx, source, http.StatusBadRequest, xerrors.Errorf("io.ReadAll: %v: %w", err, errs.WebhookErr.InvalidPayloadError))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(payload))
+
+		// 避免其他應用程式的 webhook 送到錯誤的路徑 (e.g. 購課記錄的資料送到課表)
+		req := dto.KintoneWebhookIO{}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			m.reject(ctx, source, http.StatusBadRequest, xerrors.Errorf("json.Unmarshal: %v: %w", err, errs.WebhookErr.InvalidPayloadError))
+			return
+		}
+		if req.App.Id != app.appId {
+			m.reject(ctx, source, http.StatusBadRequest, xerrors.Errorf("app_id: %s: %w", req.App.Id, errs.WebhookErr.AppIdMismatchError))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func (m *webhookAuthMiddleware) reject(ctx *gin.Context, source webhook.Source, status int, err error) {
+	m.logger.Error(ctx, "webhookAuthMiddleware Handle", err,
+		zap.String("source", string(source)),
+		zap.String("client_ip", ctx.ClientIP()),
+	)
+	ctx.AbortWithStatus(status)
+}
+
+func (m *webhookAuthMiddleware) isAllowedIp(clientIp string) bool {
+	if m.allowedNets == nil {
+		return true
+	}
+
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range m.allowedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func webhookToken(ctx *gin.Context) string {
+	if token := ctx.Query(webhook.TokenQueryKey); token != "" {
+		return token
+	}
+	return ctx.GetHeader(webhook.TokenHeaderKey)
+}
+
+// isValidWebhookToken 沒有設定 token 的應用程式一律拒絕
+func isValidWebhookToken(expected string, token string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// parseAllowedIps 支援 IP 與 CIDR，沒有設定時回傳 nil
+func parseAllowedIps(allowedIps []string) ([]*net.IPNet, error) {
+	if len(allowedIps) == 0 {
+		return nil, nil
+	}
+
+	allowedNets := make([]*net.IPNet, 0, len(allowedIps))
+	for _, allowedIp := range allowedIps {
+		if !strings.Contains(allowedIp, "/") {
+			ip := net.ParseIP(allowedIp)
+			if ip == nil {
+				return nil, xerrors.Errorf("invalid ip: %s", allowedIp)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			allowedNets = append(allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(allowedIp)
+		if err != nil {
+			return nil, xerrors.Errorf("net.ParseCIDR: %w", err)
+		}
+		allowedNets = append(allowedNets, ipNet)
+	}
+
+	return allowedNets, nil
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"jaystar/internal/config"
+	"jaystar/internal/constant/webhook"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDepositRecordAppId = "5"
+	testScheduleAppId      = "7"
+	testWebhookToken       = "deposit-secret"
+)
+
+func newTestWebhookRouter(t *testing.T, allowedIps []string) *gin.Engine {
+	allowedNets, err := parseAllowedIps(allowedIps)
+	require.NoError(t, err)
+
+	m := &webhookAuthMiddleware{
+		logger: logger.ProviderILogger(config.NewKintoneConfigEnv("", "", config.AppIdInfo{})),
+		apps: map[webhook.Source]webhookApp{
+			webhook.SourceDepositRecord: {appId: testDepositRecordAppId, token: testWebhookToken},
+			webhook.SourceSchedule:      {appId: testScheduleAppId},
+		},
+		allowedNets: allowedNets,
+	}
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	g.POST("/deposit_record", m.Handle(webhook.SourceDepositRecord), ok)
+	g.POST("/schedule", m.Handle(webhook.SourceSchedule), ok)
+	return g
+}
+
+func TestWebhookAuthMiddleware(t *testing.T) {
+	depositPayload := `{"id":"01","type":"ADD_RECORD","app":{"id":"` + testDepositRecordAppId + `"}}`
+	schedulePayload := `{"id":"02","type":"ADD_RECORD","app":{"id":"` + testScheduleAppId + `"}}`
+
+	tests := []struct {
+		name       string
+		allowedIps []string
+		path       string
+		header     http.Header
+		payload    string
+		remoteAddr string
+		wantStatus int
+	}{
+		{
+			name:       "token in query",
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    depositPayload,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "token in header",
+			path:       "/deposit_record",
+			header:     http.Header{webhook.TokenHeaderKey: []string{testWebhookToken}},
+			payload:    depositPayload,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token",
+			path:       "/deposit_record",
+			payload:    depositPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong token",
+			path:       "/deposit_record?token=wrong",
+			payload:    depositPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "app without token rejects all",
+			path:       "/schedule?token=",
+			payload:    schedulePayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "payload of other app",
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    schedulePayload,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid payload",
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    `{"app":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "payload too large",
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    `{"id":"` + strings.Repeat("0", webhook.MaxPayloadBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "allowed ip",
+			allowedIps: []string{"103.79.14.86", "10.0.0.0/8"},
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    depositPayload,
+			remoteAddr: "10.1.2.3:4567",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ip not allowed",
+			allowedIps: []string{"103.79.14.86"},
+			path:       "/deposit_record?token=" + testWebhookToken,
+			payload:    depositPayload,
+			remoteAddr: "192.0.2.1:4567",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestWebhookRouter(t, tt.allowedIps)
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.payload))
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestParseAllowedIps(t *testing.T) {
+	nets, err := parseAllowedIps(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, nets)
+
+	_, err = parseAllowedIps([]string{"103.79.14"})
+	assert.Error(t, err)
+
+	_, err = parseAllowedIps([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	nets, err = parseAllowedIps([]string{"103.79.14.86", "2001:db8::/32"})
+	require.NoError(t, err)
+	assert.Len(t, nets, 2)
+}
@@ -1,10 +1,10 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/SeanZhenggg/go-utils/logger"
-	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/controller/web/util"
@@ -29,63 +29,48 @@ type PointCardCtrl struct {
 	logger   logger.ILogger
 }
 
-func (ctrl *PointCardCtrl) KintonePointCardWebhook(ctx *gin.Context) {
+// ProcessKintonePointCardWebhook 處理從 webhook_events 取出的點數卡 webhook
+func (ctrl *PointCardCtrl) ProcessKintonePointCardWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookPointCardIO{}
-	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl KintonePointCardWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("PointCardCtrl ProcessKintonePointCardWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addPointCard(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updatePointCard(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.syncPointCard(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deletePointCard(ctx, req)
-	}
-}
-
-func (ctrl *PointCardCtrl) addPointCard(ctx *gin.Context, req dto.KintoneWebhookPointCardIO) {
-	boPointCard, err := ctrl.checkPointCardBasicRequestData(req)
-	if err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl addPointCard checkPointCardBasicRequestData", err, zap.String("record_ref_id", req.Record.Id.Value))
-		return
+		return ctrl.deletePointCard(ctx, req)
 	}
 
-	err = ctrl.srv.AddPointCard(ctx, boPointCard, &bo.StudentCond{StudentName: boPointCard.StudentName, ParentPhone: boPointCard.ParentPhone})
-	if err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl addPointCard srv.AddPointCard", err, zap.Int("record_ref_id", boPointCard.RecordRefId))
-	}
+	return nil
 }
 
-func (ctrl *PointCardCtrl) updatePointCard(ctx *gin.Context, req dto.KintoneWebhookPointCardIO) {
+// syncPointCard 新增與更新都以 kintone 上的記錄 upsert，重送或先收到更新的 webhook 不會新增重複的記錄
+func (ctrl *PointCardCtrl) syncPointCard(ctx context.Context, req dto.KintoneWebhookPointCardIO) error {
 	boPointCard, err := ctrl.checkPointCardBasicRequestData(req)
 	if err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl updatePointCard checkPointCardBasicRequestData", err, zap.String("record_ref_id", req.Record.Id.Value))
-		return
+		return xerrors.Errorf("PointCardCtrl syncPointCard checkPointCardBasicRequestData record_ref_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
 	}
 
-	err = ctrl.srv.UpdatePointCard(ctx,
-		&bo.UpdatePointCardCond{RecordRefId: boPointCard.RecordRefId},
-		&bo.StudentCond{StudentName: boPointCard.StudentName, ParentPhone: boPointCard.ParentPhone},
-		&bo.UpdatePointCardRecordData{RestPoints: &boPointCard.RestPoints},
-	)
-	if err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl updatePointCard srv.UpdatePointCard", err, zap.Int("record_ref_id", boPointCard.RecordRefId))
+	if err = ctrl.srv.SyncPointCard(ctx, boPointCard); err != nil {
+		return xerrors.Errorf("PointCardCtrl syncPointCard srv.SyncPointCard record_ref_id: %d: %w", boPointCard.RecordRefId, err)
 	}
+
+	return nil
 }
 
-func (ctrl *PointCardCtrl) deletePointCard(ctx *gin.Context, req dto.KintoneWebhookPointCardIO) {
+func (ctrl *PointCardCtrl) deletePointCard(ctx context.Context, req dto.KintoneWebhookPointCardIO) error {
 	recordRefId, err := strconv.Atoi(req.RecordId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl deletePointCard Atoi", err, zap.String("record_id", req.RecordId))
-		return
+		return xerrors.Errorf("PointCardCtrl deletePointCard Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
 	}
 
 	if err = ctrl.srv.DeletePointCard(ctx, &bo.UpdatePointCardCond{RecordRefId: recordRefId}); err != nil {
-		ctrl.logger.Error(ctx, "PointCardCtrl deletePointCard DeletePointCard", err, zap.Int("record_ref_id", recordRefId))
+		return xerrors.Errorf("PointCardCtrl deletePointCard DeletePointCard record_ref_id: %d: %w", recordRefId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *PointCardCtrl) checkPointCardBasicRequestData(req dto.KintoneWebhookPointCardIO) (*bo.PointCard, error) {
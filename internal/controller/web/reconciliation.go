@@ -0,0 +1,122 @@
+package web
+
+import (
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideReconciliationController(reconciliationSrv interfaces.IReconciliationSrv, logger logger.ILogger) *ReconciliationCtrl {
+	return &ReconciliationCtrl{
+		reconciliationSrv: reconciliationSrv,
+		logger:            logger,
+	}
+}
+
+type ReconciliationCtrl struct {
+	reconciliationSrv interfaces.IReconciliationSrv
+	logger            logger.ILogger
+}
+
+// AdminStartReconciliation 建立對帳工作後立即回應 job_id，對帳結果以 AdminGetReconciliation 查詢
+func (ctrl *ReconciliationCtrl) AdminStartReconciliation(ctx *gin.Context) {
+	job, err := ctrl.reconciliationSrv.StartReconcile(ctx)
+	if err != nil {
+		if errors.Is(err, errs.SyncJobErr.JobIsActiveError) {
+			SetStandardResponse(ctx, http.StatusConflict, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, dto.AdminStartSyncJobVO{JobId: strconv.FormatInt(job.JobId, 10)})
+}
+
+// AdminGetReconciliation 對帳工作的狀態，完成後回傳有差異的應用程式與學生
+func (ctrl *ReconciliationCtrl) AdminGetReconciliation(ctx *gin.Context) {
+	jobId, err := strconv.ParseInt(ctx.Param("job_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	job, err := ctrl.reconciliationSrv.GetReconcileJob(ctx, jobId)
+	if err != nil {
+		if errors.Is(err, errs.SyncJobErr.JobNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminReconciliationJobVO(job))
+}
+
+func toAdminReconciliationJobVO(job *bo.SyncJob) dto.AdminReconciliationJobVO {
+	jobVO := dto.AdminReconciliationJobVO{
+		JobId:     strconv.FormatInt(job.JobId, 10),
+		Status:    string(job.Status),
+		Error:     job.Report.Error,
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+	if job.Report.Reconciliation != nil {
+		reportVO := toAdminReconciliationReportVO(job.Report.Reconciliation)
+		jobVO.Report = &reportVO
+	}
+	if job.StartedAt != nil {
+		jobVO.StartedAt = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.FinishedAt != nil {
+		jobVO.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+
+	return jobVO
+}
+
+func toAdminReconciliationReportVO(report *bo.ReconciliationReport) dto.AdminReconciliationReportVO {
+	reportVO := dto.AdminReconciliationReportVO{
+		CheckedAt:   report.CheckedAt.Format(time.RFC3339),
+		HasMismatch: report.HasMismatch(),
+		Apps:        make([]dto.AdminReconciliationAppVO, 0, len(report.Apps)),
+		Students:    make([]dto.AdminReconciliationStudentVO, 0, len(report.Students)),
+	}
+	for _, app := range report.Apps {
+		reportVO.Apps = append(reportVO.Apps, dto.AdminReconciliationAppVO{
+			App:              app.App.String(),
+			Matched:          app.Matched(),
+			KintoneCount:     app.KintoneCount,
+			DbCount:          app.DbCount,
+			MissingInDb:      app.MissingInDb,
+			MissingInKintone: app.MissingInKintone,
+		})
+	}
+	for _, student := range report.Students {
+		studentVO := dto.AdminReconciliationStudentVO{
+			StudentName: student.StudentName,
+			ParentPhone: student.ParentPhone,
+			Mismatches:  make([]dto.AdminReconciliationMismatchVO, 0, len(student.Mismatches)),
+		}
+		if student.StudentId != 0 {
+			studentVO.StudentId = strconv.FormatInt(student.StudentId, 10)
+		}
+		for _, mismatch := range student.Mismatches {
+			studentVO.Mismatches = append(studentVO.Mismatches, dto.AdminReconciliationMismatchVO{
+				Field:   string(mismatch.Field),
+				Kintone: mismatch.Kintone,
+				Db:      mismatch.Db,
+			})
+		}
+		reportVO.Students = append(reportVO.Students, studentVO)
+	}
+
+	return reportVO
+}
@@ -1,9 +1,10 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/controller/web/util"
@@ -181,75 +182,48 @@ func (ctrl *ReduceRecordCtrl) AdminGetReduceRecords(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, listVO)
 }
 
-func (ctrl *ReduceRecordCtrl) KintoneReduceRecordWebhook(ctx *gin.Context) {
+// ProcessKintoneReduceRecordWebhook 處理從 webhook_events 取出的扣點記錄 webhook
+func (ctrl *ReduceRecordCtrl) ProcessKintoneReduceRecordWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookReduceRecordIO{}
-	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl KintoneReduceRecordWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("reduceRecordCtrl ProcessKintoneReduceRecordWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addReduceRecord(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updateReduceRecord(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.syncReduceRecord(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deleteReduceRecord(ctx, req)
-	}
-}
-
-func (ctrl *ReduceRecordCtrl) addReduceRecord(ctx *gin.Context, req dto.KintoneWebhookReduceRecordIO) {
-	boKintoneReduceRecord, err := ctrl.checkBasicRequestData(req)
-	if err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl addReduceRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return ctrl.deleteReduceRecord(ctx, req)
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneReduceRecord.StudentName,
-		ParentPhone: boKintoneReduceRecord.ParentPhone,
-	}
-
-	if err = ctrl.recordSrv.AddReduceRecord(ctx, boKintoneReduceRecord, boStudentCond); err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl addReduceRecord AddReduceRecord", err, zap.Int("record_ref_id", boKintoneReduceRecord.Id))
-	}
+	return nil
 }
 
-func (ctrl *ReduceRecordCtrl) updateReduceRecord(ctx *gin.Context, req dto.KintoneWebhookReduceRecordIO) {
+// syncReduceRecord 新增與更新都以 kintone 上的記錄 upsert，重送或先收到更新的 webhook 不會新增重複的記錄
+func (ctrl *ReduceRecordCtrl) syncReduceRecord(ctx context.Context, req dto.KintoneWebhookReduceRecordIO) error {
 	boKintoneReduceRecord, err := ctrl.checkBasicRequestData(req)
 	if err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl updateReduceRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return xerrors.Errorf("reduceRecordCtrl syncReduceRecord checkBasicRequestData record_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneReduceRecord.StudentName,
-		ParentPhone: boKintoneReduceRecord.ParentPhone,
+	if err = ctrl.recordSrv.SyncReduceRecord(ctx, boKintoneReduceRecord); err != nil {
+		return xerrors.Errorf("reduceRecordCtrl syncReduceRecord SyncReduceRecord record_ref_id: %d: %w", boKintoneReduceRecord.Id, err)
 	}
 
-	boUpdateReduceRecordData := &bo.UpdateReduceRecordData{
-		ClassLevel:   boKintoneReduceRecord.ClassLevel,
-		ClassType:    boKintoneReduceRecord.ClassType,
-		ClassTime:    boKintoneReduceRecord.ClassTime,
-		TeacherName:  boKintoneReduceRecord.TeacherName,
-		ReducePoints: &boKintoneReduceRecord.ReducePoints,
-		IsAttended:   &boKintoneReduceRecord.AttendStatus,
-	}
-	if err = ctrl.recordSrv.UpdateReduceRecord(ctx, &bo.ReduceRecordCond{RecordRefId: boKintoneReduceRecord.Id}, boStudentCond, boUpdateReduceRecordData); err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl updateReduceRecord UpdateReduceRecord", err, zap.Int("record_ref_id", boKintoneReduceRecord.Id))
-	}
+	return nil
 }
 
-func (ctrl *ReduceRecordCtrl) deleteReduceRecord(ctx *gin.Context, req dto.KintoneWebhookReduceRecordIO) {
+func (ctrl *ReduceRecordCtrl) deleteReduceRecord(ctx context.Context, req dto.KintoneWebhookReduceRecordIO) error {
 	recordId, err := strconv.Atoi(req.RecordId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl deleteReduceRecord Atoi", err, zap.String("record_id", req.RecordId))
-		return
+		return xerrors.Errorf("reduceRecordCtrl deleteReduceRecord Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
 	}
 
 	if err = ctrl.recordSrv.DeleteReduceRecord(ctx, &bo.ReduceRecordCond{RecordRefId: recordId}); err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl deleteReduceRecord DeleteReduceRecord", err, zap.Int("record_ref_id", recordId))
+		return xerrors.Errorf("reduceRecordCtrl deleteReduceRecord DeleteReduceRecord record_ref_id: %d: %w", recordId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *ReduceRecordCtrl) checkBasicRequestData(req dto.KintoneWebhookReduceRecordIO) (*bo.KintoneReduceRecord, error) {
@@ -1,6 +1,8 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -162,53 +164,28 @@ func (ctrl *ScheduleCtrl) AdminGetSchedules(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, listVo)
 }
 
-func (ctrl *ScheduleCtrl) KintoneScheduleWebhook(ctx *gin.Context) {
+// ProcessKintoneScheduleWebhook 處理從 webhook_events 取出的課表 webhook
+// 新增也走 updateSchedule 比對 db 現有資料，重試時不會重複新增
+func (ctrl *ScheduleCtrl) ProcessKintoneScheduleWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookScheduleIO{}
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctrl.logger.Error(ctx, "reduceRecordCtrl KintoneScheduleWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("scheduleCtrl ProcessKintoneScheduleWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addSchedule(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updateSchedule(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.updateSchedule(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deleteSchedule(ctx, req)
-	}
-}
-
-func (ctrl *ScheduleCtrl) addSchedule(ctx *gin.Context, req dto.KintoneWebhookScheduleIO) {
-	boKintoneSchedules, err := ctrl.checkBasicRequestData(req)
-	if err != nil {
-		ctrl.logger.Error(ctx, "scheduleCtrl addSchedule checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return ctrl.deleteSchedule(ctx, req)
 	}
 
-	failedRecords := make([]service.FailedRecord, 0, len(boKintoneSchedules))
-
-	for _, boKintoneSchedule := range boKintoneSchedules {
-		studentCond := &bo.StudentCond{
-			StudentName: boKintoneSchedule.StudentName,
-			ParentPhone: boKintoneSchedule.ParentPhone,
-		}
-
-		if err = ctrl.scheduleSrv.AddSchedule(ctx, boKintoneSchedule, studentCond); err != nil {
-			ctrl.logger.Error(ctx, "scheduleCtrl addSchedule AddSchedule", err, zap.String("student_name", boKintoneSchedule.StudentName))
-			failedRecords = append(failedRecords, service.FailedRecord{ScheduleRefId: boKintoneSchedule.ScheduleRefId, Name: boKintoneSchedule.StudentName})
-		}
-	}
-	if len(failedRecords) > 0 {
-		ctrl.logger.Warn(ctx, "added schedule failed records", zap.ObjectValues("records", failedRecords))
-	}
+	return nil
 }
 
-func (ctrl *ScheduleCtrl) updateSchedule(ctx *gin.Context, req dto.KintoneWebhookScheduleIO) {
+func (ctrl *ScheduleCtrl) updateSchedule(ctx context.Context, req dto.KintoneWebhookScheduleIO) error {
 	boKintoneSchedules, err := ctrl.checkBasicRequestData(req)
 	if err != nil {
-		ctrl.logger.Error(ctx, "scheduleCtrl updateSchedule checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return xerrors.Errorf("scheduleCtrl updateSchedule checkBasicRequestData record_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
 	}
 	// 這邊不用檢查 ToId error 因為在 checkBasicRequestData 中的 ToSchedules 已經有檢查過
 	scheduleRefId, _ := req.Record.Id.ToId()
@@ -216,8 +193,7 @@ func (ctrl *ScheduleCtrl) updateSchedule(ctx *gin.Context, req dto.KintoneWebhoo
 	// 1. 找出 db 所有對應這筆 schedule id 的資料
 	schedulesInDb, err := ctrl.scheduleSrv.GetSchedulesByRefId(ctx, scheduleRefId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "scheduleCtrl updateSchedule GetSchedulesByRefId", err, zap.Int("schedule_ref_id", scheduleRefId))
-		return
+		return xerrors.Errorf("scheduleCtrl updateSchedule GetSchedulesByRefId schedule_ref_id: %d: %w", scheduleRefId, err)
 	}
 
 	failedRecords := make([]service.FailedRecord, 0, len(boKintoneSchedules)+len(schedulesInDb))
@@ -290,21 +266,25 @@ func (ctrl *ScheduleCtrl) updateSchedule(ctx *gin.Context, req dto.KintoneWebhoo
 
 	if len(failedRecords) > 0 {
 		ctrl.logger.Warn(ctx, "updated schedule failed records", zap.ObjectValues("records", failedRecords))
+		return xerrors.Errorf("scheduleCtrl updateSchedule schedule_ref_id: %d failed records: %d", scheduleRefId, len(failedRecords))
 	}
+
+	return nil
 }
 
-func (ctrl *ScheduleCtrl) deleteSchedule(ctx *gin.Context, req dto.KintoneWebhookScheduleIO) {
+func (ctrl *ScheduleCtrl) deleteSchedule(ctx context.Context, req dto.KintoneWebhookScheduleIO) error {
 	// 刪除 db 此 scheduleId 對應的所有資料
 	scheduleRefId, err := strconv.Atoi(req.RecordId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "scheduleCtrl deleteSchedule Atoi", err, zap.String("record_id", req.RecordId))
-		return
+		return xerrors.Errorf("scheduleCtrl deleteSchedule Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
 	}
 
 	err = ctrl.scheduleSrv.DeleteSchedule(ctx, &bo.UpdateScheduleCond{ScheduleRefId: scheduleRefId})
 	if err != nil {
-		ctrl.logger.Error(ctx, "scheduleCtrl deleteSchedule DeleteSchedule", err, zap.Int("schedule_ref_id", scheduleRefId))
+		return xerrors.Errorf("scheduleCtrl deleteSchedule DeleteSchedule schedule_ref_id: %d: %w", scheduleRefId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *ScheduleCtrl) checkBasicRequestData(req dto.KintoneWebhookScheduleIO) ([]*bo.KintoneSchedule, error) {
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func ProvideSemesterController(semesterSrv interfaces.ISemesterSrv, reqParse util.IRequestParse, logger logger.ILogger) *SemesterCtrl {
+	return &SemesterCtrl{
+		semesterSrv: semesterSrv,
+		reqParse:    reqParse,
+		logger:      logger,
+	}
+}
+
+type SemesterCtrl struct {
+	semesterSrv interfaces.ISemesterSrv
+	reqParse    util.IRequestParse
+	logger      logger.ILogger
+}
+
+func (ctrl *SemesterCtrl) AdminGetSemesters(ctx *gin.Context) {
+	semesters, err := ctrl.semesterSrv.GetSemesters(ctx)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	semesterVOs := make([]dto.AdminSemesterVO, 0, len(semesters))
+	for _, semester := range semesters {
+		semesterVOs = append(semesterVOs, toAdminSemesterVO(semester))
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, semesterVOs)
+}
+
+func (ctrl *SemesterCtrl) AdminCreateSemester(ctx *gin.Context) {
+	req := dto.AdminSemesterIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	semester, err := toSemesterBo(&req)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	semester, err = ctrl.semesterSrv.CreateSemester(ctx, semester)
+	if err != nil {
+		ctrl.logger.Error(ctx, "SemesterCtrl AdminCreateSemester", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSemesterVO(semester))
+}
+
+func (ctrl *SemesterCtrl) AdminUpdateSemester(ctx *gin.Context) {
+	semesterId, err := strconv.ParseInt(ctx.Param("semester_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	req := dto.AdminSemesterIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	semester, err := toSemesterBo(&req)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	semester.SemesterId = semesterId
+
+	semester, err = ctrl.semesterSrv.UpdateSemester(ctx, semester)
+	if err != nil {
+		if errors.Is(err, errs.SemesterErr.SemesterNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		ctrl.logger.Error(ctx, "SemesterCtrl AdminUpdateSemester", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSemesterVO(semester))
+}
+
+func (ctrl *SemesterCtrl) AdminDeleteSemester(ctx *gin.Context) {
+	semesterId, err := strconv.ParseInt(ctx.Param("semester_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	if err := ctrl.semesterSrv.DeleteSemester(ctx, semesterId); err != nil {
+		if errors.Is(err, errs.SemesterErr.SemesterNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errs.SemesterErr.SemesterSettledError) {
+			SetStandardResponse(ctx, http.StatusConflict, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, nil)
+}
+
+func toSemesterBo(req *dto.AdminSemesterIO) (*bo.Semester, error) {
+	startDate, err := time.Parse(time.DateOnly, req.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := time.Parse(time.DateOnly, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	settleDate, err := time.Parse(time.DateOnly, req.SettleDate)
+	if err != nil {
+		return nil, err
+	}
+
+	semester := &bo.Semester{
+		Name:       req.Name,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		SettleDate: settleDate,
+		TimeZone:   req.TimeZone,
+	}
+	if semester.TimeZone == "" {
+		semester.TimeZone = utils.GetLocation().String()
+	}
+
+	return semester, nil
+}
+
+func toAdminSemesterVO(semester *bo.Semester) dto.AdminSemesterVO {
+	return dto.AdminSemesterVO{
+		SemesterId: strconv.FormatInt(semester.SemesterId, 10),
+		Name:       semester.Name,
+		StartDate:  semester.StartDate.Format(time.DateOnly),
+		EndDate:    semester.EndDate.Format(time.DateOnly),
+		SettleDate: semester.SettleDate.Format(time.DateOnly),
+		TimeZone:   semester.TimeZone,
+		CreatedAt:  semester.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  semester.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -1,9 +1,14 @@
 package web
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/controller/web/util"
@@ -12,10 +17,12 @@ import (
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
 	"jaystar/internal/utils"
+	"jaystar/internal/utils/ctxUtil"
 	"jaystar/internal/utils/errs"
 	"jaystar/internal/utils/strUtil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -101,21 +108,21 @@ func (ctrl *SemesterSettleRecordCtrl) AdminGetSemesterSettleRecords(ctx *gin.Con
 	SetStandardResponse(ctx, http.StatusOK, listVO)
 }
 
-func (ctrl *SemesterSettleRecordCtrl) KintoneSemesterSettleRecordWebhook(ctx *gin.Context) {
+// ProcessKintoneSemesterSettleRecordWebhook 處理從 webhook_events 取出的學期結算記錄 webhook
+func (ctrl *SemesterSettleRecordCtrl) ProcessKintoneSemesterSettleRecordWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookSemesterSettleRecordIO{}
-	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl KintoneSemesterSettleRecordWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("SemesterSettleRecordCtrl ProcessKintoneSemesterSettleRecordWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addSemesterSettleRecord(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updateSemesterSettleRecord(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.syncSemesterSettleRecord(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deleteSemesterSettleRecord(ctx, req)
+		return ctrl.deleteSemesterSettleRecord(ctx, req)
 	}
+
+	return nil
 }
 
 func (ctrl *SemesterSettleRecordCtrl) AdminSemesterSettlePoints(ctx *gin.Context) {
@@ -143,55 +150,365 @@ func (ctrl *SemesterSettleRecordCtrl) AdminSemesterSettlePoints(ctx *gin.Context
 	SetStandardResponse(ctx, http.StatusOK, nil)
 }
 
-func (ctrl *SemesterSettleRecordCtrl) addSemesterSettleRecord(ctx *gin.Context, req dto.KintoneWebhookSemesterSettleRecordIO) {
-	boKintoneSemesterSettleRecord, err := ctrl.checkBasicRequestData(req)
+// AdminPreviewSettlement 預覽學期結算會清除的點數，format=csv 時以 csv 檔案下載
+// 預覽不會先同步 kintone 資料，正式結算前會先同步，結果可能與預覽不同
+func (ctrl *SemesterSettleRecordCtrl) AdminPreviewSettlement(ctx *gin.Context) {
+	semesterId, err := strconv.ParseInt(ctx.Param("semester_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	req := dto.AdminSettlementPreviewIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	preview, err := ctrl.recordSrv.PreviewSettlement(ctx, semesterId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl addSemesterSettleRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
+		if errors.Is(err, errs.SemesterErr.SemesterNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl AdminPreviewSettlement", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneSemesterSettleRecord.StudentName,
-		ParentPhone: boKintoneSemesterSettleRecord.ParentPhone,
+	previewVO := dto.AdminSettlementPreviewVO{
+		SemesterId:   strconv.FormatInt(preview.Semester.SemesterId, 10),
+		SemesterName: preview.Semester.Name,
+		StartTime:    preview.StartTime.Format(time.RFC3339),
+		EndTime:      preview.EndTime.Format(time.RFC3339),
+		Synced:       false,
+		Items:        make([]dto.AdminSettlementPreviewItemVO, 0, len(preview.Items)),
+	}
+	for _, item := range preview.Items {
+		previewVO.Items = append(previewVO.Items, dto.AdminSettlementPreviewItemVO{
+			StudentId:          strconv.FormatInt(item.StudentId, 10),
+			StudentName:        item.StudentName,
+			ParentPhone:        item.ParentPhone,
+			TotalDepositPoints: item.TotalDepositPoints,
+			TotalReducePoints:  item.TotalReducePoints,
+			ClearPoints:        item.ClearPoints,
+			RestPointsBefore:   item.RestPointsBefore,
+			RestPointsAfter:    item.RestPointsAfter,
+		})
 	}
 
-	if err = ctrl.recordSrv.AddSemesterSettleRecord(ctx, boKintoneSemesterSettleRecord, boStudentCond); err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl addSemesterSettleRecord AddSemesterSettleRecord", err, zap.Int("record_ref_id", boKintoneSemesterSettleRecord.RecordRefId))
+	if req.Format != "csv" {
+		SetStandardResponse(ctx, http.StatusOK, previewVO)
+		return
+	}
+
+	content, err := settlementPreviewToCsv(previewVO)
+	if err != nil {
+		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl AdminPreviewSettlement settlementPreviewToCsv", err)
+		SetStandardResponse(ctx, http.StatusInternalServerError, err)
+		return
 	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlement_preview_%s.csv"`, previewVO.SemesterId))
+	ctx.Data(http.StatusOK, "text/csv; charset=utf-8", content)
 }
 
-func (ctrl *SemesterSettleRecordCtrl) updateSemesterSettleRecord(ctx *gin.Context, req dto.KintoneWebhookSemesterSettleRecordIO) {
-	boKintoneSemesterSettleRecord, err := ctrl.checkBasicRequestData(req)
+func (ctrl *SemesterSettleRecordCtrl) AdminGetSettlementRuns(ctx *gin.Context) {
+	req := dto.AdminGetSettlementRunsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.SettlementRunCond{
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "run_id desc",
+		},
+	}
+	if req.SemesterId != nil {
+		boCond.SemesterId = *req.SemesterId
+	}
+
+	runs, pagerResult, err := ctrl.recordSrv.GetSettlementRuns(ctx, boCond)
 	if err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl updateSemesterSettleRecord checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	boStudentCond := &bo.StudentCond{
-		StudentName: boKintoneSemesterSettleRecord.StudentName,
-		ParentPhone: boKintoneSemesterSettleRecord.ParentPhone,
+	runsVO := make([]dto.AdminSettlementRunVO, 0, len(runs))
+	for _, run := range runs {
+		runsVO = append(runsVO, toAdminSettlementRunVO(run))
+	}
+
+	listVO := dto.ListVO{
+		List: runsVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
 	}
 
-	boUpdateSemesterSettleRecordData := &bo.UpdateSemesterSettleRecordData{
-		StartTime:   boKintoneSemesterSettleRecord.StartTime,
-		EndTime:     boKintoneSemesterSettleRecord.EndTime,
-		ClearPoints: &boKintoneSemesterSettleRecord.ClearPoints,
+	SetStandardResponse(ctx, http.StatusOK, listVO)
+}
+
+func (ctrl *SemesterSettleRecordCtrl) AdminGetSettlementRun(ctx *gin.Context) {
+	runId, err := strconv.ParseInt(ctx.Param("run_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
 	}
-	if err = ctrl.recordSrv.UpdateSemesterSettleRecord(ctx, &bo.UpdateSemesterSettleRecordCond{RecordRefId: boKintoneSemesterSettleRecord.RecordRefId}, boStudentCond, boUpdateSemesterSettleRecordData); err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl updateSemesterSettleRecord UpdateSemesterSettleRecord", err, zap.Int("record_ref_id", boKintoneSemesterSettleRecord.RecordRefId))
+
+	run, err := ctrl.recordSrv.GetSettlementRun(ctx, runId)
+	if err != nil {
+		if errors.Is(err, errs.SettlementErr.RunNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
 	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSettlementRunVO(run))
 }
 
-func (ctrl *SemesterSettleRecordCtrl) deleteSemesterSettleRecord(ctx *gin.Context, req dto.KintoneWebhookSemesterSettleRecordIO) {
-	recordId, err := strconv.Atoi(req.RecordId)
+// AdminResumeSettlementRun 從中斷的步驟繼續執行結算，已完成的學生不會重複處理
+func (ctrl *SemesterSettleRecordCtrl) AdminResumeSettlementRun(ctx *gin.Context) {
+	runId, err := strconv.ParseInt(ctx.Param("run_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	req := dto.AdminResumeSettlementRunIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	if err := ctrl.recordSrv.ResumeSettlementRun(ctx, runId, req.Force); err != nil {
+		if errors.Is(err, errs.SettlementErr.RunNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl AdminResumeSettlementRun", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := ctrl.recordSrv.GetSettlementRun(ctx, runId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl deleteSemesterSettleRecord Atoi", err, zap.String("record_id", req.RecordId))
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
+	SetStandardResponse(ctx, http.StatusOK, toAdminSettlementRunVO(run))
+}
+
+func toAdminSettlementRunVO(run *bo.SettlementRun) dto.AdminSettlementRunVO {
+	runVO := dto.AdminSettlementRunVO{
+		RunId:      strconv.FormatInt(run.RunId, 10),
+		SemesterId: strconv.FormatInt(run.SemesterId, 10),
+		StartTime:  run.StartTime.Format(time.RFC3339),
+		EndTime:    run.EndTime.Format(time.RFC3339),
+		Status:     string(run.Status),
+		TotalCount: run.TotalCount,
+		StepCounts: make(map[string]int, len(run.StepCounts)),
+		Error:      run.Error,
+		CreatedAt:  run.CreatedAt.Format(time.RFC3339),
+	}
+	for step, count := range run.StepCounts {
+		runVO.StepCounts[string(step)] = count
+	}
+	if run.StartedAt != nil {
+		runVO.StartedAt = run.StartedAt.Format(time.RFC3339)
+	}
+	if run.FinishedAt != nil {
+		runVO.FinishedAt = run.FinishedAt.Format(time.RFC3339)
+	}
+	if run.Items != nil {
+		runVO.Items = make([]dto.AdminSettlementRunItemVO, 0, len(run.Items))
+		for _, item := range run.Items {
+			runVO.Items = append(runVO.Items, dto.AdminSettlementRunItemVO{
+				StudentId:          strconv.FormatInt(item.StudentId, 10),
+				KintoneStudentName: item.KintoneStudentName,
+				ClearPoints:        item.ClearPoints,
+				Step:               string(item.Step),
+				SettleRecordRefId:  item.SettleRecordRefId,
+				RestPointsBefore:   item.RestPointsBefore,
+				RestPointsAfter:    item.RestPointsAfter,
+				ReversalId:         formatOptionalId(item.ReversalId),
+			})
+		}
+	}
+
+	return runVO
+}
+
+func (ctrl *SemesterSettleRecordCtrl) AdminGetSettlementReversals(ctx *gin.Context) {
+	runId, err := strconv.ParseInt(ctx.Param("run_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	reversals, err := ctrl.recordSrv.GetSettlementReversals(ctx, runId)
+	if err != nil {
+		if errors.Is(err, errs.SettlementErr.RunNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	reversalsVO := make([]dto.AdminSettlementReversalVO, 0, len(reversals))
+	for _, reversal := range reversals {
+		reversalsVO = append(reversalsVO, toAdminSettlementReversalVO(reversal))
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, reversalsVO)
+}
+
+// AdminReverseSettlementRun 撤銷整個學期的結算
+func (ctrl *SemesterSettleRecordCtrl) AdminReverseSettlementRun(ctx *gin.Context) {
+	runId, err := strconv.ParseInt(ctx.Param("run_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	ctrl.reverseSettlement(ctx, runId, 0)
+}
+
+// AdminReverseSettlementStudent 撤銷單一學生的學期結算
+func (ctrl *SemesterSettleRecordCtrl) AdminReverseSettlementStudent(ctx *gin.Context) {
+	runId, err := strconv.ParseInt(ctx.Param("run_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+	studentId, err := strconv.ParseInt(ctx.Param("student_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	ctrl.reverseSettlement(ctx, runId, studentId)
+}
+
+func (ctrl *SemesterSettleRecordCtrl) reverseSettlement(ctx *gin.Context, runId int64, studentId int64) {
+	req := dto.AdminReverseSettlementIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	reversal, err := ctrl.recordSrv.ReverseSettlement(ctx, &bo.ReverseSettlementCond{
+		RunId:     runId,
+		StudentId: studentId,
+		Operator:  ctxUtil.GetOperatorFromCtx(ctx),
+		Reason:    req.Reason,
+		Force:     req.Force,
+	})
+	if err != nil {
+		if errors.Is(err, errs.SettlementErr.RunNotFoundError) || errors.Is(err, errs.SettlementErr.ItemNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl reverseSettlement", err)
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSettlementReversalVO(reversal))
+}
+
+func toAdminSettlementReversalVO(reversal *bo.SettlementReversal) dto.AdminSettlementReversalVO {
+	reversalVO := dto.AdminSettlementReversalVO{
+		ReversalId:    strconv.FormatInt(reversal.ReversalId, 10),
+		RunId:         strconv.FormatInt(reversal.RunId, 10),
+		StudentId:     formatOptionalId(reversal.StudentId),
+		Operator:      reversal.Operator,
+		Reason:        reversal.Reason,
+		Status:        string(reversal.Status),
+		ReversedCount: reversal.ReversedCount,
+		Error:         reversal.Error,
+		CreatedAt:     reversal.CreatedAt.Format(time.RFC3339),
+	}
+	if reversal.FinishedAt != nil {
+		reversalVO.FinishedAt = reversal.FinishedAt.Format(time.RFC3339)
+	}
+
+	return reversalVO
+}
+
+func formatOptionalId(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func settlementPreviewToCsv(previewVO dto.AdminSettlementPreviewVO) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	// 加上 BOM，Excel 開啟時中文才不會變成亂碼
+	buf.WriteString("\uFEFF")
+
+	w := csv.NewWriter(buf)
+	records := [][]string{{"student_id", "student_name", "parent_phone", "total_deposit_points", "total_reduce_points", "clear_points", "rest_points_before", "rest_points_after"}}
+	for _, item := range previewVO.Items {
+		records = append(records, []string{
+			item.StudentId,
+			csvSafeCell(item.StudentName),
+			csvSafeCell(item.ParentPhone),
+			strconv.Itoa(item.TotalDepositPoints),
+			item.TotalReducePoints.String(),
+			item.ClearPoints.String(),
+			item.RestPointsBefore.String(),
+			item.RestPointsAfter.String(),
+		})
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, xerrors.Errorf("csv.Writer WriteAll: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// csvSafeCell kintone 輸入的文字以 = + - @ 或 tab、換行開頭時加上單引號，避免 Excel 當成公式執行
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// syncSemesterSettleRecord 新增與更新都以 kintone 上的記錄 upsert，重送或先收到更新的 webhook 不會新增重複的記錄
+func (ctrl *SemesterSettleRecordCtrl) syncSemesterSettleRecord(ctx context.Context, req dto.KintoneWebhookSemesterSettleRecordIO) error {
+	boKintoneSemesterSettleRecord, err := ctrl.checkBasicRequestData(req)
+	if err != nil {
+		return xerrors.Errorf("SemesterSettleRecordCtrl syncSemesterSettleRecord checkBasicRequestData record_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
+	}
+
+	if err = ctrl.recordSrv.SyncSemesterSettleRecord(ctx, boKintoneSemesterSettleRecord); err != nil {
+		return xerrors.Errorf("SemesterSettleRecordCtrl syncSemesterSettleRecord SyncSemesterSettleRecord record_ref_id: %d: %w", boKintoneSemesterSettleRecord.RecordRefId, err)
+	}
+
+	return nil
+}
+
+func (ctrl *SemesterSettleRecordCtrl) deleteSemesterSettleRecord(ctx context.Context, req dto.KintoneWebhookSemesterSettleRecordIO) error {
+	recordId, err := strconv.Atoi(req.RecordId)
+	if err != nil {
+		return xerrors.Errorf("SemesterSettleRecordCtrl deleteSemesterSettleRecord Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
+	}
+
 	if err = ctrl.recordSrv.DeleteSemesterSettleRecord(ctx, &bo.UpdateSemesterSettleRecordCond{RecordRefId: recordId}); err != nil {
-		ctrl.logger.Error(ctx, "SemesterSettleRecordCtrl deleteSemesterSettleRecord DeleteSemesterSettleRecord", err, zap.Int("record_ref_id", recordId))
+		return xerrors.Errorf("SemesterSettleRecordCtrl deleteSemesterSettleRecord DeleteSemesterSettleRecord record_ref_id: %d: %w", recordId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *SemesterSettleRecordCtrl) checkBasicRequestData(req dto.KintoneWebhookSemesterSettleRecordIO) (*bo.SemesterSettleRecord, error) {
@@ -1,9 +1,10 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/controller/web/util"
@@ -137,59 +138,48 @@ func (ctrl *StudentCtrl) AdminGetStudents(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, listVO)
 }
 
-func (ctrl *StudentCtrl) KintoneStudentWebhook(ctx *gin.Context) {
+// ProcessKintoneStudentWebhook 處理從 webhook_events 取出的學生資料 webhook
+func (ctrl *StudentCtrl) ProcessKintoneStudentWebhook(ctx context.Context, payload []byte) error {
 	req := dto.KintoneWebhookStudentIO{}
-	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl KintoneStudentWebhook Bind", err)
-		return
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return xerrors.Errorf("studentCtrl ProcessKintoneStudentWebhook json.Unmarshal: %w", invalidWebhookPayload(err))
 	}
 
 	switch req.Type {
-	case kintone.AddRecordType:
-		ctrl.addStudent(ctx, req)
-	case kintone.UpdateRecordType, kintone.UpdateStatusType:
-		ctrl.updateStudent(ctx, req)
+	case kintone.AddRecordType, kintone.UpdateRecordType, kintone.UpdateStatusType:
+		return ctrl.syncStudent(ctx, req)
 	case kintone.DeleteRecordType:
-		ctrl.deleteStudent(ctx, req)
-	}
-}
-
-func (ctrl *StudentCtrl) addStudent(ctx *gin.Context, req dto.KintoneWebhookStudentIO) {
-	boStudent, err := ctrl.checkBasicRequestData(req)
-	if err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl addStudent checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return ctrl.deleteStudent(ctx, req)
 	}
 
-	err = ctrl.studentSrv.UserRegisterAndCreateStudent(ctx, boStudent)
-	if err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl addStudent UserRegisterAndCreateStudent", err, zap.Int("record_ref_id", boStudent.StudentRefId))
-	}
+	return nil
 }
 
-func (ctrl *StudentCtrl) updateStudent(ctx *gin.Context, req dto.KintoneWebhookStudentIO) {
+// syncStudent 新增與更新都以 kintone 上的學生資料 upsert，重送或先收到更新的 webhook 不會重複建立學生
+func (ctrl *StudentCtrl) syncStudent(ctx context.Context, req dto.KintoneWebhookStudentIO) error {
 	boStudent, err := ctrl.checkBasicRequestData(req)
 	if err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl updateStudent checkBasicRequestData", err, zap.String("record_id", req.Record.Id.Value))
-		return
+		return xerrors.Errorf("studentCtrl syncStudent checkBasicRequestData record_id: %s: %w", req.Record.Id.Value, invalidWebhookPayload(err))
 	}
 
-	if err = ctrl.studentSrv.UpdateStudent(ctx, boStudent); err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl updateStudent UpdateStudentProcess", err, zap.Int("record_ref_id", boStudent.StudentRefId))
+	if err = ctrl.studentSrv.SyncStudent(ctx, boStudent); err != nil {
+		return xerrors.Errorf("studentCtrl syncStudent SyncStudent record_ref_id: %d: %w", boStudent.StudentRefId, err)
 	}
+
+	return nil
 }
 
-func (ctrl *StudentCtrl) deleteStudent(ctx *gin.Context, req dto.KintoneWebhookStudentIO) {
+func (ctrl *StudentCtrl) deleteStudent(ctx context.Context, req dto.KintoneWebhookStudentIO) error {
 	studentRefId, err := strconv.Atoi(req.RecordId)
 	if err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl deleteStudent Atoi", err, zap.String("record_id", req.RecordId))
-		return
+		return xerrors.Errorf("studentCtrl deleteStudent Atoi record_id: %s: %w", req.RecordId, invalidWebhookPayload(err))
 	}
 
 	if err = ctrl.studentSrv.DeleteStudent(ctx, studentRefId); err != nil {
-		ctrl.logger.Error(ctx, "studentCtrl deleteStudent DeleteStudent", err, zap.Int("record_ref_id", studentRefId))
-		return
+		return xerrors.Errorf("studentCtrl deleteStudent DeleteStudent record_ref_id: %d: %w", studentRefId, err)
 	}
+
+	return nil
 }
 
 func (ctrl *StudentCtrl) checkBasicRequestData(req dto.KintoneWebhookStudentIO) (*bo.Student, error) {
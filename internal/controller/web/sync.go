@@ -1,15 +1,21 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
+	"jaystar/internal/constant/syncjob"
 	"jaystar/internal/controller/web/util"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
 	"jaystar/internal/utils/errs"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
 func ProvideSyncController(
@@ -19,6 +25,8 @@ func ProvideSyncController(
 	reduceRecordSrv interfaces.IReduceRecordSrv,
 	scheduleSrv interfaces.IScheduleSrv,
 	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv,
+	syncJobSrv interfaces.ISyncJobSrv,
+	incrementalSyncSrv interfaces.IIncrementalSyncSrv,
 	reqParse util.IRequestParse,
 	logger logger.ILogger,
 ) *SyncCtrl {
@@ -29,6 +37,8 @@ func ProvideSyncController(
 		reduceRecordSrv:         reduceRecordSrv,
 		scheduleSrv:             scheduleSrv,
 		semesterSettleRecordSrv: semesterSettleRecordSrv,
+		syncJobSrv:              syncJobSrv,
+		incrementalSyncSrv:      incrementalSyncSrv,
 		reqParse:                reqParse,
 		logger:                  logger,
 	}
@@ -41,6 +51,8 @@ type SyncCtrl struct {
 	reduceRecordSrv         interfaces.IReduceRecordSrv
 	scheduleSrv             interfaces.IScheduleSrv
 	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv
+	syncJobSrv              interfaces.ISyncJobSrv
+	incrementalSyncSrv      interfaces.IIncrementalSyncSrv
 	reqParse                util.IRequestParse
 	logger                  logger.ILogger
 }
@@ -52,66 +64,59 @@ func (ctrl *SyncCtrl) AdminSyncAllByStudent(ctx *gin.Context) {
 		return
 	}
 
-	studentWg := sync.WaitGroup{}
-	// 會員資料
-	// 必須先同步學生資料，後續才同步其他跟學生資料相關的資料
-	boSyncStudentCond := &bo.SyncStudentCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err := ctrl.studentSrv.BatchSyncStudentsAndUsers(ctx, boSyncStudentCond, &studentWg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
-	studentWg.Wait()
-
-	wg := sync.WaitGroup{}
-	// 點數管理
-	boSyncPointCardCond := &bo.SyncPointCardCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err = ctrl.pointCardSrv.BatchSyncPointCard(ctx, boSyncPointCardCond, &wg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
-	// 購課記錄
-	boSyncDepositRecordCond := &bo.SyncDepositRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err = ctrl.depositRecordSrv.BatchSyncDepositRecord(ctx, boSyncDepositRecordCond, &wg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
-	// 點名管理
-	boReduceRecordCond := &bo.SyncReduceRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err = ctrl.reduceRecordSrv.BatchSyncReduceRecord(ctx, boReduceRecordCond, &wg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
-	// 課表管理合併點名
-	boScheduleCond := &bo.SyncScheduleCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err = ctrl.scheduleSrv.BatchSyncSchedule(ctx, boScheduleCond, &wg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
-	// 學期制結算記錄
-	boSemesterSettleRecordCond := &bo.SyncSemesterSettleRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
-	err = ctrl.semesterSettleRecordSrv.BatchSyncSemesterSettleRecord(ctx, boSemesterSettleRecordCond, &wg)
-	if err != nil {
-		SetStandardResponse(ctx, http.StatusInternalServerError, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypeAllByStudent, req, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		studentWg := sync.WaitGroup{}
+		// 會員資料
+		// 必須先同步學生資料，後續才同步其他跟學生資料相關的資料
+		boSyncStudentCond := &bo.SyncStudentCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.studentSrv.BatchSyncStudentsAndUsers(ctx, boSyncStudentCond, tracker, &studentWg); err != nil {
+			return err
+		}
+		studentWg.Wait()
 
-	wg.Wait()
+		wg := sync.WaitGroup{}
+		// 其中一項取得 kintone 資料失敗時，仍等待已開始的同步完成
+		defer wg.Wait()
+		// 點數管理
+		boSyncPointCardCond := &bo.SyncPointCardCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.pointCardSrv.BatchSyncPointCard(ctx, boSyncPointCardCond, tracker, &wg); err != nil {
+			return err
+		}
+		// 購課記錄
+		boSyncDepositRecordCond := &bo.SyncDepositRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.depositRecordSrv.BatchSyncDepositRecord(ctx, boSyncDepositRecordCond, tracker, &wg); err != nil {
+			return err
+		}
+		// 點名管理
+		boReduceRecordCond := &bo.SyncReduceRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.reduceRecordSrv.BatchSyncReduceRecord(ctx, boReduceRecordCond, tracker, &wg); err != nil {
+			return err
+		}
+		// 課表管理合併點名
+		boScheduleCond := &bo.SyncScheduleCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.scheduleSrv.BatchSyncSchedule(ctx, boScheduleCond, tracker, &wg); err != nil {
+			return err
+		}
+		// 學期制結算記錄
+		boSemesterSettleRecordCond := &bo.SyncSemesterSettleRecordCond{StudentName: &req.StudentName, ParentPhone: &req.ParentPhone}
+		if err := ctrl.semesterSettleRecordSrv.BatchSyncSemesterSettleRecord(ctx, boSemesterSettleRecordCond, tracker, &wg); err != nil {
+			return err
+		}
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncStudentsAndUsers(ctx *gin.Context) {
-	if err := ctrl.studentSrv.BatchSyncStudentsAndUsers(ctx, nil); err != nil {
-		SetStandardResponse(ctx, http.StatusBadRequest, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypeStudent, nil, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.studentSrv.BatchSyncStudentsAndUsers(ctx, nil, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncDepositRecord(ctx *gin.Context) {
@@ -126,12 +131,15 @@ func (ctrl *SyncCtrl) AdminBatchSyncDepositRecord(ctx *gin.Context) {
 		ChargingDateEnd:   req.DepositedDateEnd,
 	}
 
-	if err := ctrl.depositRecordSrv.BatchSyncDepositRecord(ctx, boSyncDepositRecordCond); err != nil {
-		SetStandardResponse(ctx, http.StatusBadRequest, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypeDepositRecord, req, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.depositRecordSrv.BatchSyncDepositRecord(ctx, boSyncDepositRecordCond, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncReduceRecord(ctx *gin.Context) {
@@ -146,12 +154,15 @@ func (ctrl *SyncCtrl) AdminBatchSyncReduceRecord(ctx *gin.Context) {
 		ClassTimeEnd:   req.ClassTimeEnd,
 	}
 
-	if err := ctrl.reduceRecordSrv.BatchSyncReduceRecord(ctx, reduceRecordReq); err != nil {
-		SetStandardResponse(ctx, http.StatusBadRequest, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypeReduceRecord, req, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.reduceRecordSrv.BatchSyncReduceRecord(ctx, reduceRecordReq, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncSchedule(ctx *gin.Context) {
@@ -166,21 +177,27 @@ func (ctrl *SyncCtrl) AdminBatchSyncSchedule(ctx *gin.Context) {
 		ClassTimeEnd:   req.ClassTimeEnd,
 	}
 
-	if err := ctrl.scheduleSrv.BatchSyncSchedule(ctx, boSyncScheduleCond); err != nil {
-		SetStandardResponse(ctx, http.StatusBadRequest, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypeSchedule, req, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.scheduleSrv.BatchSyncSchedule(ctx, boSyncScheduleCond, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncPointCard(ctx *gin.Context) {
-	if err := ctrl.pointCardSrv.BatchSyncPointCard(ctx, nil); err != nil {
-		SetStandardResponse(ctx, http.StatusBadRequest, err)
-		return
-	}
+	ctrl.startJob(ctx, syncjob.TypePointCard, nil, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.pointCardSrv.BatchSyncPointCard(ctx, nil, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+		return nil
+	})
 }
 
 func (ctrl *SyncCtrl) AdminBatchSyncSemesterSettleRecord(ctx *gin.Context) {
@@ -195,10 +212,180 @@ func (ctrl *SyncCtrl) AdminBatchSyncSemesterSettleRecord(ctx *gin.Context) {
 		EndTime:   req.EndTime,
 	}
 
-	if err := ctrl.semesterSettleRecordSrv.BatchSyncSemesterSettleRecord(ctx, boSyncSemesterSettleRecordCond); err != nil {
+	ctrl.startJob(ctx, syncjob.TypeSemesterSettleRecord, req, func(ctx context.Context, tracker *bo.SyncJobTracker) error {
+		wg := sync.WaitGroup{}
+		if err := ctrl.semesterSettleRecordSrv.BatchSyncSemesterSettleRecord(ctx, boSyncSemesterSettleRecordCond, tracker, &wg); err != nil {
+			return err
+		}
+		wg.Wait()
+
+		return nil
+	})
+}
+
+// AdminIncrementalSync 同步各應用程式上次同步之後更新的記錄
+func (ctrl *SyncCtrl) AdminIncrementalSync(ctx *gin.Context) {
+	dryRunReq := dto.SyncDryRunIO{}
+	if err := ctx.ShouldBindQuery(&dryRunReq); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	job, err := ctrl.incrementalSyncSrv.StartIncrementalSync(ctx, dryRunReq.DryRun)
+	if err != nil {
+		if errors.Is(err, errs.SyncJobErr.JobIsActiveError) {
+			SetStandardResponse(ctx, http.StatusConflict, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, dto.AdminStartSyncJobVO{JobId: strconv.FormatInt(job.JobId, 10)})
+}
+
+func (ctrl *SyncCtrl) AdminGetSyncJob(ctx *gin.Context) {
+	jobId, err := strconv.ParseInt(ctx.Param("job_id"), 10, 64)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	job, err := ctrl.syncJobSrv.GetJob(ctx, jobId)
+	if err != nil {
+		if errors.Is(err, errs.SyncJobErr.JobNotFoundError) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSyncJobVO(job))
+}
+
+func (ctrl *SyncCtrl) AdminGetSyncJobs(ctx *gin.Context) {
+	req := &dto.AdminGetSyncJobsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.SyncJobCond{
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "job_id desc",
+		},
+	}
+	if req.JobType != nil {
+		boCond.JobType = syncjob.Type(*req.JobType)
+	}
+	if req.Status != nil {
+		boCond.Status = syncjob.Status(*req.Status)
+	}
+
+	jobs, pagerResult, err := ctrl.syncJobSrv.GetJobs(ctx, boCond)
+	if err != nil {
 		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	SetStandardResponse(ctx, http.StatusOK, nil)
+	jobsVO := make([]dto.AdminSyncJobVO, 0, len(jobs))
+	for _, job := range jobs {
+		jobsVO = append(jobsVO, toAdminSyncJobVO(job))
+	}
+
+	listVO := dto.ListVO{
+		List: jobsVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, listVO)
+}
+
+// startJob 建立同步工作後立即回應 job_id，同步結果以 AdminGetSyncJob 查詢
+// query 帶 dry_run=true 時只計算變更，變更內容記錄在同步工作的 diff
+func (ctrl *SyncCtrl) startJob(ctx *gin.Context, jobType syncjob.Type, cond any, run func(ctx context.Context, tracker *bo.SyncJobTracker) error) {
+	dryRunReq := dto.SyncDryRunIO{}
+	if err := ctx.ShouldBindQuery(&dryRunReq); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	job, err := ctrl.syncJobSrv.StartJob(ctx, jobType, cond, dryRunReq.DryRun, run)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, dto.AdminStartSyncJobVO{JobId: strconv.FormatInt(job.JobId, 10)})
+}
+
+func toAdminSyncJobVO(job *bo.SyncJob) dto.AdminSyncJobVO {
+	jobVO := dto.AdminSyncJobVO{
+		JobId:          strconv.FormatInt(job.JobId, 10),
+		JobType:        string(job.JobType),
+		DryRun:         job.DryRun,
+		Cond:           []byte(job.Cond),
+		Status:         string(job.Status),
+		TotalCount:     job.Report.Total,
+		CreatedCount:   job.Report.Created,
+		UpdatedCount:   job.Report.Updated,
+		DeletedCount:   job.Report.Deleted,
+		SkippedCount:   job.Report.Skipped,
+		FailedCount:    job.Report.Failed,
+		UnchangedCount: job.Report.Unchanged,
+		Failures:       make([]dto.AdminSyncJobFailureVO, 0, len(job.Report.Failures)),
+		Error:          job.Report.Error,
+		CreatedAt:      job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      job.UpdatedAt.Format(time.RFC3339),
+	}
+	for _, failure := range job.Report.Failures {
+		jobVO.Failures = append(jobVO.Failures, dto.AdminSyncJobFailureVO{
+			Type:        string(failure.Type),
+			RecordRefId: failure.RecordRefId,
+			Error:       failure.Error,
+		})
+	}
+	if job.Report.Diff != nil {
+		jobVO.Diff = &dto.AdminSyncDiffVO{
+			Creates:   toAdminSyncDiffRecordVOs(job.Report.Diff.Creates),
+			Updates:   toAdminSyncDiffRecordVOs(job.Report.Diff.Updates),
+			Deletes:   toAdminSyncDiffRecordVOs(job.Report.Diff.Deletes),
+			Restores:  toAdminSyncDiffRecordVOs(job.Report.Diff.Restores),
+			Truncated: job.Report.Diff.Truncated,
+		}
+	}
+	if job.StartedAt != nil {
+		jobVO.StartedAt = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.FinishedAt != nil {
+		jobVO.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+
+	return jobVO
+}
+
+func toAdminSyncDiffRecordVOs(records []*bo.SyncDiffRecord) []dto.AdminSyncDiffRecordVO {
+	recordVOs := make([]dto.AdminSyncDiffRecordVO, 0, len(records))
+	for _, record := range records {
+		recordVO := dto.AdminSyncDiffRecordVO{
+			Type:        string(record.Type),
+			RecordRefId: record.RecordRefId,
+			Key:         record.Key,
+			Changes:     make([]dto.AdminSyncFieldChangeVO, 0, len(record.Changes)),
+		}
+		for _, change := range record.Changes {
+			recordVO.Changes = append(recordVO.Changes, dto.AdminSyncFieldChangeVO{Field: change.Field, Old: change.Old, New: change.New})
+		}
+		recordVOs = append(recordVOs, recordVO)
+	}
+
+	return recordVOs
 }
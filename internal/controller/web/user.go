@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"jaystar/internal/config"
@@ -41,6 +42,7 @@ func (ctrl *UserCtrl) AdminGetUsers(ctx *gin.Context) {
 	}
 
 	boUserCond := &bo.UserCond{
+		Level: user.User,
 		Pager: po.Pager{
 			Index: req.Index,
 			Size:  req.Size,
@@ -69,30 +71,7 @@ func (ctrl *UserCtrl) AdminGetUsers(ctx *gin.Context) {
 		return
 	}
 
-	usersVO := make([]dto.AdminUserVO, 0, len(boUsers))
-	for _, boUser := range boUsers {
-		usersVO = append(usersVO, dto.AdminUserVO{
-			UserId:            strconv.FormatInt(boUser.UserId, 10),
-			Account:           boUser.Account,
-			Status:            boUser.Status.ToValue(),
-			Level:             boUser.Level.ToValue(),
-			IsChangedPassword: boUser.IsChangedPassword,
-			CreatedAt:         boUser.CreatedAt.Format(time.DateTime),
-			UpdatedAt:         boUser.UpdatedAt.Format(time.DateTime),
-		})
-	}
-
-	listVO := dto.ListVO{
-		List: usersVO,
-		Pager: dto.PagerVO{
-			Index: pagerResult.Index,
-			Size:  pagerResult.Size,
-			Pages: pagerResult.Pages,
-			Total: pagerResult.Total,
-		},
-	}
-
-	SetStandardResponse(ctx, http.StatusOK, listVO)
+	SetStandardResponse(ctx, http.StatusOK, toAdminUserListVO(boUsers, pagerResult))
 }
 
 func (ctrl *UserCtrl) AdminUpdateUser(ctx *gin.Context) {
@@ -109,7 +88,8 @@ func (ctrl *UserCtrl) AdminUpdateUser(ctx *gin.Context) {
 	}
 
 	// cond
-	boUserCond := &bo.UserCond{UserId: userIdInt}
+	// 管理者帳號只能透過 AdminUpdateAdminUser 修改
+	boUserCond := &bo.UserCond{UserId: userIdInt, Level: user.User}
 
 	// data
 	boUpdateUserData := &bo.UpdateUserData{
@@ -132,6 +112,152 @@ func (ctrl *UserCtrl) AdminUpdateUser(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, nil)
 }
 
+func (ctrl *UserCtrl) AdminLogin(ctx *gin.Context) {
+	req := dto.UserLoginIO{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boUser, err := ctrl.userSrv.AdminLogin(ctx, &bo.UserLoginCond{
+		Account:  req.Account,
+		Password: req.Password,
+	})
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := ctrl.saveUserSession(ctx, boUser); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminSessionVO(boUser))
+}
+
+func (ctrl *UserCtrl) AdminLogout(ctx *gin.Context) {
+	if err := ctrl.clearUserSession(ctx); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	SetStandardResponse(ctx, http.StatusOK, nil)
+}
+
+// AdminGetMe 目前登入的管理者
+func (ctrl *UserCtrl) AdminGetMe(ctx *gin.Context) {
+	admin := ctxUtil.GetUserSessionFromCtx(ctx)
+
+	SetStandardResponse(ctx, http.StatusOK, dto.AdminSessionVO{
+		UserId:  strconv.FormatInt(admin.UserId, 10),
+		Account: admin.Account,
+		Role:    admin.Role.ToKey(),
+	})
+}
+
+func (ctrl *UserCtrl) AdminGetAdminUsers(ctx *gin.Context) {
+	req := dto.AdminGetUsersIO{}
+	if err := ctrl.reqParseSrv.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boUserCond := &bo.UserCond{
+		Level: user.Admin,
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+		},
+	}
+	if req.Accounts != nil {
+		boUserCond.Accounts = strUtil.GetAccArrFromAccStr(*req.Accounts)
+	}
+	if req.Status != nil {
+		if *req.Status {
+			boUserCond.Status = user.Activate
+		} else {
+			boUserCond.Status = user.Deactivate
+		}
+	}
+
+	boUsers, pagerResult, err := ctrl.userSrv.GetUsers(ctx, boUserCond)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminUserListVO(boUsers, pagerResult))
+}
+
+func (ctrl *UserCtrl) AdminCreateAdminUser(ctx *gin.Context) {
+	req := dto.CreateAdminUserIO{}
+	if err := ctrl.reqParseSrv.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boUser, err := ctrl.userSrv.CreateAdminUser(ctx, &bo.CreateUserData{
+		Account:  req.Account,
+		Password: req.Password,
+		Role:     user.AdminRoleToEnum(req.Role),
+	})
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, toAdminUserVO(boUser))
+}
+
+func (ctrl *UserCtrl) AdminUpdateAdminUser(ctx *gin.Context) {
+	userIdInt, valid := ctrl.validateUserId(ctx)
+	if !valid {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	req := dto.UpdateAdminUserIO{}
+	if err := ctrl.reqParseSrv.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boUpdateUserData := &bo.UpdateUserData{Password: req.Password}
+	if req.Status != nil {
+		if *req.Status {
+			boUpdateUserData.Status = user.Activate
+		} else {
+			boUpdateUserData.Status = user.Deactivate
+		}
+	}
+	if req.Role != nil {
+		boUpdateUserData.Role = user.AdminRoleToEnum(*req.Role)
+		if boUpdateUserData.Role == user.RoleNone {
+			SetStandardResponse(ctx, http.StatusBadRequest, errs.UserErr.AdminRoleInvalidErr)
+			return
+		}
+	}
+
+	// 避免把自己停用或移除帳號管理權限後，沒有人能管理帳號
+	admin := ctxUtil.GetUserSessionFromCtx(ctx)
+	if admin.UserId == userIdInt && (boUpdateUserData.Status == user.Deactivate || (boUpdateUserData.Role != user.RoleNone && boUpdateUserData.Role != admin.Role)) {
+		SetStandardResponse(ctx, http.StatusForbidden, errs.CommonErr.AuthDeniedError)
+		return
+	}
+
+	boUserCond := &bo.UserCond{UserId: userIdInt, Level: user.Admin}
+	if err := ctrl.userSrv.UpdateUser(ctx, boUserCond, boUpdateUserData); err != nil {
+		if errors.Is(err, errs.UserErr.UserNotFoundErr) {
+			SetStandardResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, nil)
+}
+
 func (ctrl *UserCtrl) AdminGetEncryptedPassword(ctx *gin.Context) {
 	req := dto.PasswordIO{}
 	if err := ctrl.reqParseSrv.Bind(ctx, &req); err != nil {
@@ -192,27 +318,7 @@ func (ctrl *UserCtrl) UserLogin(ctx *gin.Context) {
 		return
 	}
 
-	// only using https cookie in production
-	secure := false
-	if ctrl.cfg.GetAppEnv() == "prod" {
-		secure = true
-	}
-
-	session := sessions.Default(ctx)
-	session.Options(sessions.Options{
-		Path:   "/",
-		Domain: ctx.Request.URL.Host,
-		MaxAge: 60 * 60,
-		Secure: secure,
-	})
-
-	userSession := &auth.UserSession{
-		UserId: boUser.UserId,
-		Level:  boUser.Level,
-	}
-	session.Set(user.SessionUserKey, userSession)
-
-	if err := session.Save(); err != nil {
+	if err := ctrl.saveUserSession(ctx, boUser); err != nil {
 		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
@@ -221,22 +327,7 @@ func (ctrl *UserCtrl) UserLogin(ctx *gin.Context) {
 }
 
 func (ctrl *UserCtrl) UserLogout(ctx *gin.Context) {
-	session := sessions.Default(ctx)
-	session.Clear()
-
-	// only using https cookie in production
-	secure := false
-	if ctrl.cfg.GetAppEnv() == "prod" {
-		secure = true
-	}
-
-	session.Options(sessions.Options{
-		Path:   "/",
-		Domain: ctx.Request.URL.Host,
-		MaxAge: -1,
-		Secure: secure,
-	})
-	if err := session.Save(); err != nil {
+	if err := ctrl.clearUserSession(ctx); err != nil {
 		SetStandardResponse(ctx, http.StatusBadRequest, err)
 		return
 	}
@@ -304,6 +395,56 @@ func (ctrl *UserCtrl) UpdateUserPassword(ctx *gin.Context) {
 	SetStandardResponse(ctx, http.StatusOK, nil)
 }
 
+func (ctrl *UserCtrl) saveUserSession(ctx *gin.Context, boUser *bo.User) error {
+	// only using https cookie in production
+	secure := false
+	if ctrl.cfg.GetAppEnv() == "prod" {
+		secure = true
+	}
+
+	if err := auth.RegenerateSession(ctx); err != nil {
+		return err
+	}
+
+	session := sessions.Default(ctx)
+	session.Options(sessions.Options{
+		Path:   "/",
+		Domain: ctx.Request.URL.Host,
+		MaxAge: 60 * 60,
+		Secure: secure,
+	})
+
+	userSession := &auth.UserSession{
+		UserId:  boUser.UserId,
+		Level:   boUser.Level,
+		Account: boUser.Account,
+		Role:    boUser.Role,
+	}
+	session.Set(user.SessionUserKey, userSession)
+
+	return session.Save()
+}
+
+func (ctrl *UserCtrl) clearUserSession(ctx *gin.Context) error {
+	session := sessions.Default(ctx)
+	session.Clear()
+
+	// only using https cookie in production
+	secure := false
+	if ctrl.cfg.GetAppEnv() == "prod" {
+		secure = true
+	}
+
+	session.Options(sessions.Options{
+		Path:   "/",
+		Domain: ctx.Request.URL.Host,
+		MaxAge: -1,
+		Secure: secure,
+	})
+
+	return session.Save()
+}
+
 func (ctrl *UserCtrl) validateUserId(ctx *gin.Context) (int64, bool) {
 	userId := ctx.Param("user_id")
 	if userId == "" {
@@ -318,3 +459,41 @@ func (ctrl *UserCtrl) validateUserId(ctx *gin.Context) (int64, bool) {
 
 	return userIdInt, true
 }
+
+func toAdminUserVO(boUser *bo.User) dto.AdminUserVO {
+	return dto.AdminUserVO{
+		UserId:            strconv.FormatInt(boUser.UserId, 10),
+		Account:           boUser.Account,
+		Status:            boUser.Status.ToValue(),
+		Level:             boUser.Level.ToValue(),
+		Role:              boUser.Role.ToKey(),
+		IsChangedPassword: boUser.IsChangedPassword,
+		CreatedAt:         boUser.CreatedAt.Format(time.DateTime),
+		UpdatedAt:         boUser.UpdatedAt.Format(time.DateTime),
+	}
+}
+
+func toAdminUserListVO(boUsers []*bo.User, pagerResult *po.PagerResult) dto.ListVO {
+	usersVO := make([]dto.AdminUserVO, 0, len(boUsers))
+	for _, boUser := range boUsers {
+		usersVO = append(usersVO, toAdminUserVO(boUser))
+	}
+
+	return dto.ListVO{
+		List: usersVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
+	}
+}
+
+func toAdminSessionVO(boUser *bo.User) dto.AdminSessionVO {
+	return dto.AdminSessionVO{
+		UserId:  strconv.FormatInt(boUser.UserId, 10),
+		Account: boUser.Account,
+		Role:    boUser.Role.ToKey(),
+	}
+}
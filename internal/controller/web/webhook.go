@@ -0,0 +1,181 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/controller/web/util"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WebhookHandler 處理一筆 webhook 原始資料，回傳 error 時事件會依重試設定再處理
+type WebhookHandler func(ctx context.Context, payload []byte) error
+
+type WebhookHandlers map[webhook.Source]WebhookHandler
+
+func ProvideWebhookHandlers(
+	studentCtrl *StudentCtrl,
+	scheduleCtrl *ScheduleCtrl,
+	depositRecordCtrl *DepositRecordCtrl,
+	reduceRecordCtrl *ReduceRecordCtrl,
+	semesterSettleRecordCtrl *SemesterSettleRecordCtrl,
+	pointCardCtrl *PointCardCtrl,
+) WebhookHandlers {
+	return WebhookHandlers{
+		webhook.SourceStudent:              studentCtrl.ProcessKintoneStudentWebhook,
+		webhook.SourceSchedule:             scheduleCtrl.ProcessKintoneScheduleWebhook,
+		webhook.SourceDepositRecord:        depositRecordCtrl.ProcessKintoneDepositRecordWebhook,
+		webhook.SourceReduceRecord:         reduceRecordCtrl.ProcessKintoneReduceRecordWebhook,
+		webhook.SourceSemesterSettleRecord: semesterSettleRecordCtrl.ProcessKintoneSemesterSettleRecordWebhook,
+		webhook.SourcePointCard:            pointCardCtrl.ProcessKintonePointCardWebhook,
+	}
+}
+
+func ProvideWebhookController(webhookEventSrv interfaces.IWebhookEventSrv, reqParse util.IRequestParse, logger logger.ILogger) *WebhookCtrl {
+	return &WebhookCtrl{
+		webhookEventSrv: webhookEventSrv,
+		reqParse:        reqParse,
+		logger:          logger,
+	}
+}
+
+type WebhookCtrl struct {
+	webhookEventSrv interfaces.IWebhookEventSrv
+	reqParse        util.IRequestParse
+	logger          logger.ILogger
+}
+
+// KintoneWebhook 只把 webhook 存進 webhook_events 就回應，實際資料由 worker 處理
+func (ctrl *WebhookCtrl) KintoneWebhook(source webhook.Source) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctrl.logger.Error(ctx, "webhookCtrl KintoneWebhook ReadAll", err, zap.String("source", string(source)))
+			ctx.Status(http.StatusBadRequest)
+			return
+		}
+
+		if _, err = ctrl.webhookEventSrv.AddEvent(ctx, source, payload); err != nil {
+			// Kintone 重送的 webhook 已經在 inbox 中，直接回應成功
+			if errors.Is(err, errs.WebhookErr.DuplicateEventError) {
+				ctrl.logger.Info(ctx, "webhookCtrl KintoneWebhook duplicated webhook", zap.String("source", string(source)))
+				ctx.Status(http.StatusOK)
+				return
+			}
+			ctrl.logger.Error(ctx, "webhookCtrl KintoneWebhook AddEvent", err, zap.String("source", string(source)))
+			if errors.Is(err, errs.WebhookErr.InvalidPayloadError) {
+				ctx.Status(http.StatusBadRequest)
+				return
+			}
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func (ctrl *WebhookCtrl) AdminGetWebhookEvents(ctx *gin.Context) {
+	req := &dto.AdminGetWebhookEventsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	boCond := &bo.WebhookEventCond{
+		Pager: po.Pager{
+			Index: req.Index,
+			Size:  req.Size,
+			Order: "event_id desc",
+		},
+	}
+	if req.Source != nil {
+		boCond.Source = webhook.Source(*req.Source)
+	}
+	if req.Status != nil {
+		boCond.Status = webhook.Status(*req.Status)
+	}
+
+	events, pagerResult, err := ctrl.webhookEventSrv.GetEvents(ctx, boCond)
+	if err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	eventsVO := make([]dto.AdminWebhookEventVO, 0, len(events))
+	for _, event := range events {
+		eventVO := dto.AdminWebhookEventVO{}
+		eventVO.EventId = strconv.FormatInt(event.EventId, 10)
+		eventVO.Source = string(event.Source)
+		eventVO.WebhookId = event.WebhookId
+		eventVO.WebhookType = event.WebhookType
+		eventVO.AppId = event.AppId
+		eventVO.RecordId = event.RecordId
+		eventVO.Payload = []byte(event.Payload)
+		eventVO.Status = string(event.Status)
+		eventVO.Attempts = event.Attempts
+		eventVO.NextAttemptAt = event.NextAttemptAt.Format(time.RFC3339)
+		eventVO.LastError = event.LastError
+		if event.ProcessedAt != nil {
+			eventVO.ProcessedAt = event.ProcessedAt.Format(time.RFC3339)
+		}
+		eventVO.CreatedAt = event.CreatedAt.Format(time.RFC3339)
+		eventVO.UpdatedAt = event.UpdatedAt.Format(time.RFC3339)
+
+		eventsVO = append(eventsVO, eventVO)
+	}
+
+	listVO := dto.ListVO{
+		List: eventsVO,
+		Pager: dto.PagerVO{
+			Index: pagerResult.Index,
+			Size:  pagerResult.Size,
+			Pages: pagerResult.Pages,
+			Total: pagerResult.Total,
+		},
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, listVO)
+}
+
+func (ctrl *WebhookCtrl) AdminReplayWebhookEvents(ctx *gin.Context) {
+	req := &dto.AdminReplayWebhookEventsIO{}
+	if err := ctrl.reqParse.Bind(ctx, &req); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+		return
+	}
+
+	eventIds := make([]int64, 0, len(req.EventIds))
+	for _, id := range req.EventIds {
+		eventId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			SetStandardResponse(ctx, http.StatusBadRequest, errs.CommonErr.RequestParamError)
+			return
+		}
+		eventIds = append(eventIds, eventId)
+	}
+
+	if err := ctrl.webhookEventSrv.ReplayEvents(ctx, eventIds); err != nil {
+		SetStandardResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	SetStandardResponse(ctx, http.StatusOK, nil)
+}
+
+// invalidWebhookPayload 資料本身有問題時重試也不會成功，讓事件直接進 dead
+func invalidWebhookPayload(err error) error {
+	return fmt.Errorf("%w: %w", errs.WebhookErr.InvalidPayloadError, err)
+}
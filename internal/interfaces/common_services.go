@@ -2,14 +2,18 @@ package interfaces
 
 import (
 	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
+	"time"
 )
 
 type IUserCommonSrv interface {
 	GetUser(ctx context.Context, cond *bo.UserCond) (*bo.User, error)
 	CreateUser(ctx context.Context, data *bo.CreateUserData) (int64, error)
+	GetOrCreateUser(ctx context.Context, db *gorm.DB, data *bo.CreateUserData) (int64, error)
 	CreateUserAndStudent(ctx context.Context, data *bo.CreateUserData, studentData *bo.Student) error
 	GetHashedPasswordFromEncrypted(password string) (string, error)
 	GenHashedPassword(password []byte) (string, error)
@@ -20,20 +24,30 @@ type IUserCommonSrv interface {
 
 type IStudentCommonSrv interface {
 	GetKintoneStudents(ctx context.Context, cond *dto.StudentReq) ([]*bo.Student, int, error)
+	GetAllKintoneStudents(ctx context.Context, cond *dto.StudentReq) ([]*bo.Student, error)
 	GetStudent(ctx context.Context, cond *bo.StudentCond) (*bo.Student, error)
 }
 
 type IDepositRecordCommonSrv interface {
 	GetKintoneDepositRecords(ctx context.Context, cond *dto.DepositRecordReq) ([]*bo.KintoneDepositRecord, int, error)
-	UpdateKintoneDepositRecordsStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error
+	GetAllKintoneDepositRecords(ctx context.Context, cond *dto.DepositRecordReq) ([]*bo.KintoneDepositRecord, error)
+	GetKintoneDepositRecordsStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) (forward []*dto.KintoneBulkUpdateRecords, backward []*dto.KintoneBulkUpdateRecords, err error)
 }
 
 type IReduceRecordCommonSrv interface {
 	GetKintoneReduceRecords(ctx context.Context, cond *dto.ReduceRecordReq) ([]*bo.KintoneReduceRecord, int, error)
-	UpdateKintoneReduceRecordsStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error
+	GetAllKintoneReduceRecords(ctx context.Context, cond *dto.ReduceRecordReq) ([]*bo.KintoneReduceRecord, error)
+	GetKintoneReduceRecordsStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) (forward []*dto.KintoneBulkUpdateRecords, backward []*dto.KintoneBulkUpdateRecords, err error)
 }
 
 type IScheduleCommonSrv interface {
 	GetKintoneSchedules(ctx context.Context, cond *dto.ScheduleReq) ([]dto.ScheduleRecord, int, error)
-	UpdateKintoneSchedulesStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error
+	GetAllKintoneSchedules(ctx context.Context, cond *dto.ScheduleReq) ([]dto.ScheduleRecord, error)
+	GetKintoneSchedulesStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) (forward []*dto.KintoneBulkUpdateRecords, backward []*dto.KintoneBulkUpdateRecords, err error)
+}
+
+type IRecordLockCommonSrv interface {
+	LockRecord(ctx context.Context, source webhook.Source, recordRefId string, fn func() error) error
+	GetPendingRecords(ctx context.Context, source webhook.Source, snapshotAt time.Time) (*bo.PendingRecords, error)
+	SyncRecord(ctx context.Context, pendingRecords *bo.PendingRecords, recordRefId int, fn func() error) error
 }
@@ -2,35 +2,60 @@ package interfaces
 
 import (
 	"context"
+	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/dto"
 )
 
+// IKintoneRecordCursor kintone cursor API 的串流讀取，中途結束時必須 Close
+type IKintoneRecordCursor[T any] interface {
+	Next(ctx context.Context) bool
+	Records() []T
+	TotalCount() int
+	Err() error
+	Close(ctx context.Context) error
+}
+
 type IKintoneStudentRepo interface {
 	GetKintoneStudents(ctx context.Context, req *dto.StudentReq) (*dto.StudentRes, error)
+	GetKintoneStudentsCursor(ctx context.Context, req *dto.StudentReq) (IKintoneRecordCursor[dto.StudentRecord], error)
 }
 
 type IKintoneDepositRecordRepo interface {
 	GetKintoneDepositRecords(ctx context.Context, req *dto.DepositRecordReq) (*dto.DepositRecordRes, error)
+	GetKintoneDepositRecordsCursor(ctx context.Context, req *dto.DepositRecordReq) (IKintoneRecordCursor[dto.KintoneDepositRecordRecordDto], error)
 	UpdateKintoneDepositRecords(ctx context.Context, req *dto.UpdateDepositRecordsReq) (*dto.UpdateDepositRecordsRes, error)
 }
 
 type IKintoneReduceRecordRepo interface {
 	GetKintoneReduceRecords(ctx context.Context, req *dto.ReduceRecordReq) (*dto.ReduceRecordRes, error)
+	GetKintoneReduceRecordsCursor(ctx context.Context, req *dto.ReduceRecordReq) (IKintoneRecordCursor[dto.ReduceRecordRecord], error)
 	UpdateKintoneReduceRecords(ctx context.Context, req *dto.UpdateReduceRecordsReq) (*dto.UpdateReduceRecordsRes, error)
 }
 
 type IKintoneScheduleRepo interface {
 	GetKintoneSchedules(ctx context.Context, req *dto.ScheduleReq) (*dto.ScheduleRes, error)
+	GetKintoneSchedulesCursor(ctx context.Context, req *dto.ScheduleReq) (IKintoneRecordCursor[dto.ScheduleRecord], error)
 	UpdateKintoneSchedules(ctx context.Context, req *dto.UpdateSchedulesReq) (*dto.UpdateSchedulesRes, error)
 }
 
 type IKintonePointCardRepo interface {
 	GetPointCards(ctx context.Context, req *dto.GetPointCardReq) (*dto.GetPointCardRes, error)
+	GetPointCardsCursor(ctx context.Context, req *dto.GetPointCardReq) (IKintoneRecordCursor[dto.PointCardRecord], error)
 	UpdatePointCard(ctx context.Context, req *dto.UpdatePointCardReq) (*dto.UpdatePointCardRes, error)
-	UpdatePointCards(ctx context.Context, req *dto.UpdatePointCardsReq) (*dto.UpdatePointCardRes, error)
+	UpdatePointCards(ctx context.Context, req *dto.UpdatePointCardsReq) (*dto.UpdatePointCardsRes, error)
 }
 
 type IKintoneSemesterSettleRecordRepo interface {
 	GetKintoneSemesterSettleRecords(ctx context.Context, req *dto.SemesterSettleRecordReq) (*dto.SemesterSettleRecordRes, error)
+	GetKintoneSemesterSettleRecordsCursor(ctx context.Context, req *dto.SemesterSettleRecordReq) (IKintoneRecordCursor[dto.SemesterSettleRecord], error)
 	InsertKintoneSemesterSettleRecords(ctx context.Context, req *dto.InsertSemesterSettleRecordsReq) (*dto.InsertSemesterSettleRecordsRes, error)
+	DeleteKintoneSemesterSettleRecords(ctx context.Context, req *dto.DeleteSemesterSettleRecordsReq) error
+}
+
+type IKintoneRecordRepo interface {
+	GetAllRecordIds(ctx context.Context, app kintone.App) (map[int]struct{}, error)
+}
+
+type IKintoneBulkRepo interface {
+	BulkUpdateRecords(ctx context.Context, updates []*dto.KintoneBulkUpdateRecords) (*dto.KintoneBulkRequestRes, error)
 }
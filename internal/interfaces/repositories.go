@@ -3,12 +3,16 @@ package interfaces
 import (
 	"context"
 	"gorm.io/gorm"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/model/po"
+	"time"
 )
 
 type IStudentRepo interface {
 	ICommonRepo
 	GetStudents(ctx context.Context, db *gorm.DB, cond *po.StudentCond, pager *po.Pager) ([]*po.StudentWithBalance, error)
+	ListStudents(ctx context.Context, db *gorm.DB, cond *po.StudentCond) ([]*po.Student, error)
 	GetStudentsPager(ctx context.Context, db *gorm.DB, cond *po.StudentCond, pager *po.Pager) (*po.PagerResult, error)
 	GetStudent(ctx context.Context, db *gorm.DB, cond *po.StudentCond) (*po.StudentWithBalance, error)
 	GetStudentRefIds(ctx context.Context, db *gorm.DB, cond *po.StudentCond) ([]int, error)
@@ -65,6 +69,7 @@ type ISemesterSettleRecordRepo interface {
 	AddRecord(ctx context.Context, db *gorm.DB, data *po.SemesterSettleRecord) error
 	UpdateRecord(ctx context.Context, db *gorm.DB, cond *po.UpdateSemesterSettleRecordCond, data *po.UpdateSemesterSettleRecordData) error
 	GetSemesterSettleRecordRefIds(ctx context.Context, db *gorm.DB, cond *po.SemesterSettleRecordCond) ([]int, error)
+	GetStudentTotalClearPoints(ctx context.Context, db *gorm.DB, cond *po.SemesterSettleRecordCond) ([]*po.StudentTotalClearPoints, error)
 }
 
 type IPointCardRepo interface {
@@ -76,6 +81,77 @@ type IPointCardRepo interface {
 	GetPointCardRefIds(ctx context.Context, db *gorm.DB, cond *po.PointCardCond) ([]int, error)
 }
 
+type IWebhookEventRepo interface {
+	GetEvents(ctx context.Context, db *gorm.DB, cond *po.WebhookEventCond, pager *po.Pager) ([]*po.WebhookEvent, error)
+	GetEventsPager(ctx context.Context, db *gorm.DB, cond *po.WebhookEventCond, pager *po.Pager) (*po.PagerResult, error)
+	AddEvent(ctx context.Context, db *gorm.DB, data *po.WebhookEvent) error
+	ClaimEvents(ctx context.Context, db *gorm.DB, cond *po.ClaimWebhookEventsCond) ([]*po.WebhookEvent, error)
+	GetRecordIdsWithEventsSince(ctx context.Context, db *gorm.DB, cond *po.RecordWebhookEventsCond) ([]string, error)
+	LockRecord(ctx context.Context, db *gorm.DB, source webhook.Source, recordId string) error
+	UpdateEvents(ctx context.Context, db *gorm.DB, cond *po.UpdateWebhookEventCond, data *po.UpdateWebhookEventData) (int64, error)
+}
+
+type ISyncJobRepo interface {
+	GetJob(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond) (*po.SyncJob, error)
+	GetJobs(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond, pager *po.Pager) ([]*po.SyncJob, error)
+	GetJobsPager(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond, pager *po.Pager) (*po.PagerResult, error)
+	AddJob(ctx context.Context, db *gorm.DB, data *po.SyncJob) error
+	UpdateJob(ctx context.Context, db *gorm.DB, cond *po.UpdateSyncJobCond, data *po.UpdateSyncJobData) error
+	UpdateJobs(ctx context.Context, db *gorm.DB, cond *po.UpdateSyncJobCond, data *po.UpdateSyncJobData) (int64, error)
+}
+
+type ISyncWatermarkRepo interface {
+	GetWatermarks(ctx context.Context, db *gorm.DB) ([]*po.SyncWatermark, error)
+	UpsertWatermark(ctx context.Context, db *gorm.DB, jobType syncjob.Type, watermark time.Time) error
+}
+
+type ILedgerRepo interface {
+	GetEntries(ctx context.Context, db *gorm.DB, cond *po.LedgerEntryCond) ([]*po.LedgerEntry, error)
+}
+
+type ISemesterRepo interface {
+	GetSemester(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) (*po.Semester, error)
+	GetSemesters(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) ([]*po.Semester, error)
+	AddSemester(ctx context.Context, db *gorm.DB, data *po.Semester) error
+	UpdateSemester(ctx context.Context, db *gorm.DB, cond *po.UpdateSemesterCond, data *po.UpdateSemesterData) error
+	DeleteSemester(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) error
+}
+
+type ISettlementRunRepo interface {
+	GetRun(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond) (*po.SettlementRun, error)
+	GetRuns(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond, pager *po.Pager) ([]*po.SettlementRun, error)
+	GetRunsPager(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond, pager *po.Pager) (*po.PagerResult, error)
+	AddRun(ctx context.Context, db *gorm.DB, run *po.SettlementRun, items []*po.SettlementRunItem) error
+	UpdateRun(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementRunCond, data *po.UpdateSettlementRunData) (int64, error)
+	GetRunItems(ctx context.Context, db *gorm.DB, cond *po.SettlementRunItemCond) ([]*po.SettlementRunItem, error)
+	GetRunStepCounts(ctx context.Context, db *gorm.DB, runIds []int64) ([]*po.SettlementRunStepCount, error)
+	UpdateRunItems(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementRunItemCond, data *po.UpdateSettlementRunItemData) error
+	GetReversals(ctx context.Context, db *gorm.DB, cond *po.SettlementReversalCond) ([]*po.SettlementReversal, error)
+	AddReversal(ctx context.Context, db *gorm.DB, reversal *po.SettlementReversal) error
+	UpdateReversal(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementReversalCond, data *po.UpdateSettlementReversalData) error
+}
+
+type IApiKeyRepo interface {
+	GetApiKeys(ctx context.Context, db *gorm.DB) ([]*po.ApiKey, error)
+	GetApiKey(ctx context.Context, db *gorm.DB, cond *po.ApiKeyCond) (*po.ApiKey, error)
+	AddApiKey(ctx context.Context, db *gorm.DB, data *po.ApiKey) error
+	UpdateApiKey(ctx context.Context, db *gorm.DB, cond *po.UpdateApiKeyCond, data *po.UpdateApiKeyData) error
+}
+
+type IAuditLogRepo interface {
+	GetAuditLogs(ctx context.Context, db *gorm.DB, cond *po.AuditLogCond, pager *po.Pager) ([]*po.AuditLog, error)
+	GetAuditLogsPager(ctx context.Context, db *gorm.DB, cond *po.AuditLogCond, pager *po.Pager) (*po.PagerResult, error)
+	AddAuditLog(ctx context.Context, db *gorm.DB, data *po.AuditLog) error
+}
+
+type ILoginSessionRepo interface {
+	GetLoginSessions(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond, pager *po.Pager) ([]*po.LoginSession, error)
+	GetLoginSessionsPager(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond, pager *po.Pager) (*po.PagerResult, error)
+	GetLoginSession(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond) (*po.LoginSession, error)
+	AddLoginSession(ctx context.Context, db *gorm.DB, data *po.LoginSession) error
+	UpdateLoginSessions(ctx context.Context, db *gorm.DB, cond *po.UpdateLoginSessionCond, data *po.UpdateLoginSessionData) error
+}
+
 type ICommonRepo interface {
 	ResetFromDeleted(ctx context.Context, db *gorm.DB, tableName string, whereScopes func(db *gorm.DB) *gorm.DB) error
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
 	"sync"
+	"time"
 )
 
 type IStudentSrv interface {
@@ -15,7 +18,8 @@ type IStudentSrv interface {
 	UserRegisterAndCreateStudent(ctx context.Context, data *bo.Student) error
 	UpdateStudent(ctx context.Context, data *bo.Student) error
 	DeleteStudent(ctx context.Context, studentRefId int) error
-	BatchSyncStudentsAndUsers(ctx context.Context, cond *bo.SyncStudentCond, wg ...*sync.WaitGroup) error
+	SyncStudent(ctx context.Context, data *bo.Student) error
+	BatchSyncStudentsAndUsers(ctx context.Context, cond *bo.SyncStudentCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
 	GetStudentsSettled(ctx context.Context, db *gorm.DB) ([]*bo.Student, error)
 }
 
@@ -23,6 +27,8 @@ type IUserSrv interface {
 	GetUsers(ctx context.Context, cond *bo.UserCond) ([]*bo.User, *po.PagerResult, error)
 	GetUser(ctx context.Context, cond *bo.UserCond) (*bo.User, error)
 	UserLogin(ctx *gin.Context, cond *bo.UserLoginCond) (*bo.User, error)
+	AdminLogin(ctx *gin.Context, cond *bo.UserLoginCond) (*bo.User, error)
+	CreateAdminUser(ctx context.Context, data *bo.CreateUserData) (*bo.User, error)
 	UserRegister(ctx context.Context, cond *bo.StudentCond, data *bo.CreateUserData) error
 	UpdateUser(ctx context.Context, cond *bo.UserCond, data *bo.UpdateUserData) error
 	GetEncryptedPassword(ctx context.Context, data dto.PasswordIO) (string, error)
@@ -33,7 +39,8 @@ type IDepositRecordSrv interface {
 	AddDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord, studentCond *bo.StudentCond) error
 	UpdateDepositRecord(ctx context.Context, cond *bo.DepositRecordCond, studentCond *bo.StudentCond, data *bo.UpdateDepositRecordData) error
 	DeleteDepositRecord(ctx context.Context, cond *bo.DepositRecordCond) error
-	BatchSyncDepositRecord(ctx context.Context, cond *bo.SyncDepositRecordCond, wg ...*sync.WaitGroup) error
+	SyncDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord) error
+	BatchSyncDepositRecord(ctx context.Context, cond *bo.SyncDepositRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
 	GetStudentTotalDepositPoints(ctx context.Context, db *gorm.DB, cond *bo.StudentTotalDepositPointsCond) (map[int64]*bo.StudentTotalDepositPoints, error)
 }
 
@@ -42,7 +49,8 @@ type IReduceRecordSrv interface {
 	AddReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord, studentCond *bo.StudentCond) error
 	UpdateReduceRecord(ctx context.Context, cond *bo.ReduceRecordCond, studentCond *bo.StudentCond, data *bo.UpdateReduceRecordData) error
 	DeleteReduceRecord(ctx context.Context, cond *bo.ReduceRecordCond) error
-	BatchSyncReduceRecord(ctx context.Context, cond *bo.SyncReduceRecordCond, wg ...*sync.WaitGroup) error
+	SyncReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord) error
+	BatchSyncReduceRecord(ctx context.Context, cond *bo.SyncReduceRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
 	GetStudentTotalReducePoints(ctx context.Context, db *gorm.DB, cond *bo.StudentTotalReducePointsCond) (map[int64]*bo.StudentTotalReducePoints, error)
 }
 
@@ -52,7 +60,7 @@ type IScheduleSrv interface {
 	AddSchedule(ctx context.Context, data *bo.KintoneSchedule, studentCond *bo.StudentCond) error
 	UpdateScheduleById(ctx context.Context, scheduleId int64, studentCond *bo.StudentCond, data *bo.UpdateScheduleData) error
 	DeleteSchedule(ctx context.Context, cond *bo.UpdateScheduleCond) error
-	BatchSyncSchedule(ctx context.Context, cond *bo.SyncScheduleCond, wg ...*sync.WaitGroup) error
+	BatchSyncSchedule(ctx context.Context, cond *bo.SyncScheduleCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
 }
 
 type ISemesterSettleRecordSrv interface {
@@ -60,16 +68,97 @@ type ISemesterSettleRecordSrv interface {
 	AddSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord, studentCond *bo.StudentCond) error
 	UpdateSemesterSettleRecord(ctx context.Context, cond *bo.UpdateSemesterSettleRecordCond, studentCond *bo.StudentCond, data *bo.UpdateSemesterSettleRecordData) error
 	DeleteSemesterSettleRecord(ctx context.Context, cond *bo.UpdateSemesterSettleRecordCond) error
-	BatchSyncSemesterSettleRecord(ctx context.Context, cond *bo.SyncSemesterSettleRecordCond, wg ...*sync.WaitGroup) error
-	SettleSemesterPoints(ctx context.Context, cond *bo.SettleSemesterPointsCond) (err error)
+	SyncSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord) error
+	BatchSyncSemesterSettleRecord(ctx context.Context, cond *bo.SyncSemesterSettleRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
+	SettleSemesterPoints(ctx context.Context, cond *bo.SettleSemesterPointsCond) error
+	PreviewSettlement(ctx context.Context, semesterId int64) (*bo.SettlementPreview, error)
+	GetSettlementRuns(ctx context.Context, cond *bo.SettlementRunCond) ([]*bo.SettlementRun, *po.PagerResult, error)
+	GetSettlementRun(ctx context.Context, runId int64) (*bo.SettlementRun, error)
+	ResumeSettlementRun(ctx context.Context, runId int64, force bool) error
+	GetSettlementReversals(ctx context.Context, runId int64) ([]*bo.SettlementReversal, error)
+	ReverseSettlement(ctx context.Context, cond *bo.ReverseSettlementCond) (*bo.SettlementReversal, error)
+	GetAllKintoneSemesterSettleRecords(ctx context.Context, req *dto.SemesterSettleRecordReq) ([]*bo.SemesterSettleRecord, error)
 }
 
 type IPointCardSrv interface {
 	GetPointCards(ctx context.Context, db *gorm.DB, cond *bo.GetPointCardCond) (map[int64]*bo.PointCard, error)
-	UpdateKintonePointCardStudentName(ctx context.Context, oldPointCardName string, newPointCardName string) error
+	GetKintonePointCardStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) (forward []*dto.KintoneBulkUpdateRecords, backward []*dto.KintoneBulkUpdateRecords, err error)
 	AddPointCard(ctx context.Context, data *bo.PointCard, studentCond *bo.StudentCond) error
 	UpdatePointCard(ctx context.Context, cond *bo.UpdatePointCardCond, studentCond *bo.StudentCond, data *bo.UpdatePointCardRecordData) error
 	DeletePointCard(ctx context.Context, cond *bo.UpdatePointCardCond) error
-	BatchSyncPointCard(ctx context.Context, cond *bo.SyncPointCardCond, wg ...*sync.WaitGroup) error
+	SyncPointCard(ctx context.Context, data *bo.PointCard) error
+	BatchSyncPointCard(ctx context.Context, cond *bo.SyncPointCardCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error
 	SyncSettledStudentPointCards(ctx context.Context, db *gorm.DB, data []*bo.SyncSettledStudentPointCardData) error
+	GetAllKintonePointCards(ctx context.Context, req *dto.GetPointCardReq) ([]*bo.PointCard, error)
+}
+
+type ISyncJobSrv interface {
+	StartJob(ctx context.Context, jobType syncjob.Type, cond any, dryRun bool, run func(ctx context.Context, tracker *bo.SyncJobTracker) error) (*bo.SyncJob, error)
+	GetJob(ctx context.Context, jobId int64) (*bo.SyncJob, error)
+	GetJobs(ctx context.Context, cond *bo.SyncJobCond) ([]*bo.SyncJob, *po.PagerResult, error)
+	HasActiveJob(ctx context.Context, jobType syncjob.Type) (bool, error)
+}
+
+type IWebhookEventSrv interface {
+	AddEvent(ctx context.Context, source webhook.Source, payload []byte) (*bo.WebhookEvent, error)
+	ClaimEvents(ctx context.Context, limit int) ([]*bo.WebhookEvent, error)
+	IsStaleEvent(ctx context.Context, event *bo.WebhookEvent) (bool, error)
+	CompleteEvent(ctx context.Context, event *bo.WebhookEvent) error
+	SkipEvent(ctx context.Context, event *bo.WebhookEvent) error
+	FailEvent(ctx context.Context, event *bo.WebhookEvent, processErr error) error
+	GetEvents(ctx context.Context, cond *bo.WebhookEventCond) ([]*bo.WebhookEvent, *po.PagerResult, error)
+	ReplayEvents(ctx context.Context, eventIds []int64) error
+}
+
+type IIncrementalSyncSrv interface {
+	StartIncrementalSync(ctx context.Context, dryRun bool) (*bo.SyncJob, error)
+}
+
+type IReconciliationSrv interface {
+	Reconcile(ctx context.Context) (*bo.ReconciliationReport, error)
+	StartReconcile(ctx context.Context) (*bo.SyncJob, error)
+	GetReconcileJob(ctx context.Context, jobId int64) (*bo.SyncJob, error)
+}
+
+type ILedgerSrv interface {
+	GetStudentLedger(ctx context.Context, studentId int64) (*bo.StudentLedger, error)
+	GetBalanceMismatches(ctx context.Context) ([]*bo.StudentBalance, error)
+	GetStudentBalanceAsOf(ctx context.Context, cond *bo.StudentCond, asOf time.Time) (*bo.StudentBalanceAsOf, error)
+}
+
+type IApiKeySrv interface {
+	GetApiKeys(ctx context.Context) ([]*bo.ApiKey, error)
+	CreateApiKey(ctx context.Context, data *bo.CreateApiKeyData) (*bo.CreatedApiKey, error)
+	RevokeApiKey(ctx context.Context, keyId int64) (*bo.ApiKey, error)
+	// AuthenticateApiKey 驗證呼叫端帶入的 API key，並更新最後使用時間
+	AuthenticateApiKey(ctx context.Context, key string) (*bo.ApiKey, error)
+}
+
+type IAuditLogSrv interface {
+	// AddAuditLog 操作完成後記錄，寫入失敗時由呼叫端記錄 log，不影響已完成的操作
+	AddAuditLog(ctx context.Context, data *bo.AddAuditLogData) error
+	GetAuditLogs(ctx context.Context, cond *bo.AuditLogCond) ([]*bo.AuditLog, *po.PagerResult, error)
+}
+
+type ILoginSessionSrv interface {
+	// GetActiveLoginSession 以 cookie 中的 token 取得 session，已撤銷或過期的 session 視為不存在
+	GetActiveLoginSession(ctx context.Context, token string) (*bo.LoginSession, error)
+	// CreateLoginSession 產生新的 token 並保存 session
+	CreateLoginSession(ctx context.Context, data *bo.SaveLoginSessionData) (string, error)
+	RefreshLoginSession(ctx context.Context, data *bo.SaveLoginSessionData) error
+	// EndLoginSession 登出時撤銷目前的 session
+	EndLoginSession(ctx context.Context, token string) error
+	GetLoginSessions(ctx context.Context, cond *bo.LoginSessionCond) ([]*bo.LoginSession, *po.PagerResult, error)
+	RevokeLoginSession(ctx context.Context, cond *bo.LoginSessionCond) (*bo.LoginSession, error)
+	RevokeUserLoginSessions(ctx context.Context, data *bo.RevokeLoginSessionsData) error
+}
+
+type ISemesterSrv interface {
+	GetSemesters(ctx context.Context) ([]*bo.Semester, error)
+	GetSemester(ctx context.Context, semesterId int64) (*bo.Semester, error)
+	CreateSemester(ctx context.Context, semester *bo.Semester) (*bo.Semester, error)
+	UpdateSemester(ctx context.Context, semester *bo.Semester) (*bo.Semester, error)
+	DeleteSemester(ctx context.Context, semesterId int64) error
+	GetSettleSemester(ctx context.Context, t time.Time) (*bo.Semester, error)
+	GetUpcomingSemester(ctx context.Context, t time.Time) (*bo.Semester, error)
 }
@@ -0,0 +1,48 @@
+package bo
+
+import (
+	"jaystar/internal/constant/scope"
+	"time"
+)
+
+type ApiKey struct {
+	KeyId      int64
+	Name       string
+	KeyPrefix  string
+	Scopes     []scope.Scope
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedBy  string
+	CreatedAt  time.Time
+}
+
+// IsActive 未撤銷且未過期
+func (k *ApiKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+func (k *ApiKey) HasScope(s scope.Scope) bool {
+	for _, keyScope := range k.Scopes {
+		if keyScope == s {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateApiKeyData struct {
+	Name      string
+	Scopes    []scope.Scope
+	ExpiresAt *time.Time // nil 代表不會過期
+	CreatedBy string
+}
+
+// CreatedApiKey Key 為原始的 API key，只在建立時回傳一次
+type CreatedApiKey struct {
+	*ApiKey
+	Key string
+}
@@ -0,0 +1,40 @@
+package bo
+
+import (
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/model/po"
+	"time"
+)
+
+type AuditLog struct {
+	LogId      int64
+	Actor      string
+	Action     audit.Action
+	TargetType audit.TargetType
+	TargetId   int64
+	Before     *string // json，新增時為 nil
+	After      *string // json，刪除時為 nil
+	RequestId  string
+	CreatedAt  time.Time
+}
+
+type AuditLogCond struct {
+	Actor      string
+	Action     audit.Action
+	TargetType audit.TargetType
+	TargetId   int64
+	RequestId  string
+	Since      *time.Time
+	Until      *time.Time
+	po.Pager
+}
+
+// AddAuditLogData 操作人與 request id 由 ctx 取得
+// Before、After 為異動前後的資料，會轉為 json 保存
+type AddAuditLogData struct {
+	Action     audit.Action
+	TargetType audit.TargetType
+	TargetId   int64
+	Before     any
+	After      any
+}
@@ -8,6 +8,7 @@ import (
 
 type KintoneDepositRecord struct {
 	Id                   int
+	Revision             int
 	ChargingMethod       []kintoneConst.ChargingMethod
 	ActualChargingAmount int
 	Gender               string
@@ -74,8 +75,7 @@ type SyncDepositRecordCond struct {
 	ParentPhone       *string
 	ChargingDateStart *time.Time
 	ChargingDateEnd   *time.Time
-	Offset            *int
-	Limit             *int
+	IncrementalSyncCond
 }
 
 type StudentTotalDepositPointsCond struct {
@@ -0,0 +1,45 @@
+package bo
+
+import (
+	"jaystar/internal/constant/ledger"
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+// StudentBalance 比較由儲值、扣點與學期結算記錄推算的餘額與點數卡的剩餘點數
+type StudentBalance struct {
+	StudentId        int64
+	StudentName      string
+	ParentPhone      string
+	DerivedBalance   points.Points
+	PointCardBalance points.Points
+	HasPointCard     bool
+}
+
+// BalanceMatched 沒有點數卡的學生視為不一致
+func (b *StudentBalance) BalanceMatched() bool {
+	return b.HasPointCard && b.DerivedBalance == b.PointCardBalance
+}
+
+type StudentLedger struct {
+	StudentBalance
+	Entries []*LedgerEntry
+}
+
+// StudentBalanceAsOf 某個時間點的餘額與構成餘額的記錄
+type StudentBalanceAsOf struct {
+	StudentId   int64
+	StudentName string
+	ParentPhone string
+	AsOf        time.Time
+	Balance     points.Points
+	Entries     []*LedgerEntry
+}
+
+type LedgerEntry struct {
+	EntryType   ledger.EntryType
+	RecordRefId int
+	OccurredAt  time.Time
+	Change      points.Points // 儲值為正，扣點與結算為負
+	Balance     points.Points // 套用此筆記錄後的餘額
+}
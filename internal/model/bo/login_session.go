@@ -0,0 +1,52 @@
+package bo
+
+import (
+	"jaystar/internal/constant/loginsession"
+	"jaystar/internal/model/po"
+	"time"
+)
+
+type LoginSession struct {
+	SessionId     int64
+	UserId        int64
+	Data          string
+	UserAgent     string
+	Ip            string
+	ExpiresAt     time.Time
+	LastSeenAt    time.Time
+	RevokedAt     *time.Time
+	RevokedReason loginsession.RevokeReason
+	CreatedAt     time.Time
+	IsCurrent     bool // 是否為目前請求使用的 session
+}
+
+// IsActive 未撤銷且未過期
+func (s *LoginSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type LoginSessionCond struct {
+	SessionId  int64
+	UserId     int64
+	ActiveOnly bool
+	// CurrentToken 目前請求的 session token，用來標示 IsCurrent
+	CurrentToken string
+	po.Pager
+}
+
+// SaveLoginSessionData Data 為 session store 編碼後的 session 內容
+type SaveLoginSessionData struct {
+	Token     string
+	UserId    int64
+	Data      string
+	UserAgent string
+	Ip        string
+	ExpiresAt time.Time
+}
+
+// RevokeLoginSessionsData ExceptToken 的 session 不會被撤銷 (e.g. 自己修改密碼時保留目前的登入)
+type RevokeLoginSessionsData struct {
+	UserIds     []int64
+	Reason      loginsession.RevokeReason
+	ExceptToken string
+}
@@ -1,13 +1,16 @@
 package bo
 
+import "jaystar/internal/utils/points"
+
 type PointCard struct {
 	RecordId           int64
 	RecordRefId        int
+	Revision           int // kintone $revision
 	StudentId          int64
 	KintoneStudentName string
 	StudentName        string
 	ParentPhone        string
-	RestPoints         float64
+	RestPoints         points.Points
 }
 
 type GetPointCardCond struct {
@@ -19,7 +22,7 @@ type UpdatePointCardCond struct {
 }
 
 type UpdatePointCardRecordData struct {
-	RestPoints *float64
+	RestPoints *points.Points
 }
 
 type SyncPointCardCond struct {
@@ -27,11 +30,12 @@ type SyncPointCardCond struct {
 	ParentPhone *string
 	Limit       *int
 	Offset      *int
+	IncrementalSyncCond
 }
 
 type SyncSettledStudentPointCardData struct {
 	StudentId          int64
 	KintoneStudentName string
-	ClearPoints        float64
-	RestPoints         float64
+	ClearPoints        points.Points
+	RestPoints         points.Points
 }
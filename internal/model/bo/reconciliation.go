@@ -0,0 +1,66 @@
+package bo
+
+import (
+	"fmt"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/reconciliation"
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+// ReconciliationReport kintone 與 db 的對帳結果，對帳工作的結果以 json 保存
+type ReconciliationReport struct {
+	CheckedAt time.Time                `json:"checked_at"`
+	Apps      []*ReconciliationApp     `json:"apps"`
+	Students  []*ReconciliationStudent `json:"students"` // 只列出有差異的學生
+}
+
+// ReconciliationApp 應用程式的記錄數比對，db 只計算未刪除的記錄
+type ReconciliationApp struct {
+	App              kintone.App `json:"app"`
+	KintoneCount     int         `json:"kintone_count"`
+	DbCount          int         `json:"db_count"`
+	MissingInDb      []int       `json:"missing_in_db"`      // kintone 有但 db 沒有的 record ref id
+	MissingInKintone []int       `json:"missing_in_kintone"` // db 有但 kintone 已經沒有的 record ref id
+}
+
+type ReconciliationStudent struct {
+	StudentId   int64                     `json:"student_id"` // db 沒有此學生時為 0
+	StudentName string                    `json:"student_name"`
+	ParentPhone string                    `json:"parent_phone"`
+	Mismatches  []*ReconciliationMismatch `json:"mismatches"`
+}
+
+type ReconciliationMismatch struct {
+	Field   reconciliation.Field `json:"field"`
+	Kintone points.Points        `json:"kintone"`
+	Db      points.Points        `json:"db"`
+}
+
+func (a *ReconciliationApp) Matched() bool {
+	return a.KintoneCount == a.DbCount && len(a.MissingInDb) == 0 && len(a.MissingInKintone) == 0
+}
+
+func (r *ReconciliationReport) HasMismatch() bool {
+	return len(r.MismatchMessages()) > 0
+}
+
+// MismatchMessages 將差異整理成可讀的訊息，寫入 log 用
+func (r *ReconciliationReport) MismatchMessages() []string {
+	messages := make([]string, 0)
+	for _, app := range r.Apps {
+		if app.Matched() {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: kintone %d, db %d, missing in db %v, missing in kintone %v",
+			app.App, app.KintoneCount, app.DbCount, app.MissingInDb, app.MissingInKintone))
+	}
+	for _, student := range r.Students {
+		for _, mismatch := range student.Mismatches {
+			messages = append(messages, fmt.Sprintf("%s/%s %s: kintone %v, db %v",
+				student.StudentName, student.ParentPhone, mismatch.Field, mismatch.Kintone, mismatch.Db))
+		}
+	}
+
+	return messages
+}
@@ -3,11 +3,13 @@ package bo
 import (
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils/points"
 	"time"
 )
 
 type KintoneReduceRecord struct {
 	Id                 int
+	Revision           int
 	KintoneStudentName string
 	StudentName        string // 學生姓名，不含家長電話的
 	ParentPhone        string // 家長電話
@@ -15,7 +17,7 @@ type KintoneReduceRecord struct {
 	ClassType          kintone.ClassType
 	ClassTime          time.Time
 	TeacherName        string
-	ReducePoints       float64
+	ReducePoints       points.Points
 	AttendStatus       bool
 	Description        string
 	CreatedBy          string
@@ -34,7 +36,7 @@ type ReduceRecord struct {
 	ClassType    kintone.ClassType
 	ClassTime    time.Time
 	TeacherName  string
-	ReducePoints float64
+	ReducePoints points.Points
 	IsAttended   bool
 	IsDeleted    bool
 	CreatedAt    time.Time
@@ -55,7 +57,7 @@ type UpdateReduceRecordData struct {
 	ClassType    kintone.ClassType
 	ClassTime    time.Time
 	TeacherName  string
-	ReducePoints *float64
+	ReducePoints *points.Points
 	IsAttended   *bool
 }
 
@@ -64,8 +66,7 @@ type SyncReduceRecordCond struct {
 	ParentPhone    *string
 	ClassTimeStart *time.Time
 	ClassTimeEnd   *time.Time
-	Offset         *int
-	Limit          *int
+	IncrementalSyncCond
 }
 
 type StudentTotalReducePointsCond struct {
@@ -76,5 +77,5 @@ type StudentTotalReducePointsCond struct {
 
 type StudentTotalReducePoints struct {
 	StudentId         int64
-	TotalReducePoints float64
+	TotalReducePoints points.Points
 }
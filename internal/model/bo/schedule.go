@@ -66,6 +66,5 @@ type SyncScheduleCond struct {
 	ParentPhone    *string
 	ClassTimeStart *time.Time
 	ClassTimeEnd   *time.Time
-	Limit          *int
-	Offset         *int
+	IncrementalSyncCond
 }
@@ -0,0 +1,38 @@
+package bo
+
+import (
+	"time"
+)
+
+// Semester 日期欄位只使用年月日，以 TimeZone 換算成實際時間
+type Semester struct {
+	SemesterId int64
+	Name       string
+	StartDate  time.Time
+	EndDate    time.Time
+	SettleDate time.Time // 結算 StartDate ~ EndDate 之間點數的日期
+	TimeZone   string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (s *Semester) Location() (*time.Location, error) {
+	return time.LoadLocation(s.TimeZone)
+}
+
+// IsSettleDate t 換算成學期時區後是否為結算日
+func (s *Semester) IsSettleDate(t time.Time, loc *time.Location) bool {
+	return t.In(loc).Format(time.DateOnly) == s.SettleDate.Format(time.DateOnly)
+}
+
+// SettlesAfter t 換算成學期時區後，結算日是否還在之後
+func (s *Semester) SettlesAfter(t time.Time, loc *time.Location) bool {
+	return s.SettleDate.Format(time.DateOnly) > t.In(loc).Format(time.DateOnly)
+}
+
+// Range 學期在時區內的起訖時間，結束時間為結束日的最後一秒
+func (s *Semester) Range(loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(s.StartDate.Year(), s.StartDate.Month(), s.StartDate.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(s.EndDate.Year(), s.EndDate.Month(), s.EndDate.Day()+1, 0, 0, 0, 0, loc).Add(-time.Second)
+	return start, end
+}
@@ -2,6 +2,7 @@ package bo
 
 import (
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils/points"
 	"time"
 )
 
@@ -13,7 +14,7 @@ type SemesterSettleRecord struct {
 	ParentPhone        string // 家長電話
 	StartTime          time.Time
 	EndTime            time.Time
-	ClearPoints        float64
+	ClearPoints        points.Points
 	CreatedBy          string
 	CreatedAt          time.Time
 	UpdatedBy          string
@@ -36,7 +37,7 @@ type UpdateSemesterSettleRecordCond struct {
 type UpdateSemesterSettleRecordData struct {
 	StartTime   time.Time
 	EndTime     time.Time
-	ClearPoints *float64
+	ClearPoints *points.Points
 }
 
 type SyncSemesterSettleRecordCond struct {
@@ -46,8 +47,28 @@ type SyncSemesterSettleRecordCond struct {
 	EndTime     *time.Time
 	Offset      *int
 	Limit       *int
+	IncrementalSyncCond
 }
 
 type SettleSemesterPointsCond struct {
 	Date time.Time
 }
+
+// SettlementPreview 學期結算會清除的點數，不包含已經有結算記錄的學生
+type SettlementPreview struct {
+	Semester  *Semester
+	StartTime time.Time
+	EndTime   time.Time
+	Items     []*SettlementPreviewItem
+}
+
+type SettlementPreviewItem struct {
+	StudentId          int64
+	StudentName        string
+	ParentPhone        string
+	TotalDepositPoints int
+	TotalReducePoints  points.Points
+	ClearPoints        points.Points
+	RestPointsBefore   points.Points
+	RestPointsAfter    points.Points
+}
@@ -0,0 +1,61 @@
+package bo
+
+import (
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+type SettlementRun struct {
+	RunId      int64
+	SemesterId int64
+	StartTime  time.Time
+	EndTime    time.Time
+	Status     settlement.RunStatus
+	TotalCount int
+	StepCounts map[settlement.ItemStep]int // 各步驟的學生數
+	Error      string
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Items      []*SettlementRunItem // 只有查詢單筆結算時才會帶
+}
+
+type SettlementRunItem struct {
+	StudentId          int64
+	KintoneStudentName string
+	ClearPoints        points.Points
+	Step               settlement.ItemStep
+	SettleRecordRefId  *int
+	RestPointsBefore   points.Points
+	RestPointsAfter    *points.Points
+	ReversalId         *int64
+}
+
+type SettlementRunCond struct {
+	SemesterId int64
+	po.Pager
+}
+
+type SettlementReversal struct {
+	ReversalId    int64
+	RunId         int64
+	StudentId     *int64
+	Operator      string
+	Reason        string
+	Status        settlement.ReversalStatus
+	ReversedCount int
+	Error         string
+	FinishedAt    *time.Time
+	CreatedAt     time.Time
+}
+
+type ReverseSettlementCond struct {
+	RunId     int64
+	StudentId int64 // 0 代表撤銷整個學期的結算
+	Operator  string
+	Reason    string
+	Force     bool // 接手狀態停在撤銷中的結算
+}
@@ -3,6 +3,7 @@ package bo
 import (
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils/points"
 	"time"
 )
 
@@ -25,7 +26,7 @@ type Student struct {
 	StudentName      string
 	ParentName       string
 	ParentPhone      string
-	Balance          float64
+	Balance          points.Points
 	IsDeleted        bool
 	Mode             kintone.Mode
 	IsSettleNormally bool
@@ -43,7 +44,7 @@ type UpdateStudentData struct {
 	StudentName      string
 	ParentName       string
 	ParentPhone      string
-	Balance          *float64
+	Balance          *points.Points
 	Mode             *kintone.Mode
 	IsSettleNormally *bool
 }
@@ -61,6 +62,5 @@ type AddStudentAndUserIfNeededResp struct {
 type SyncStudentCond struct {
 	StudentName *string
 	ParentPhone *string
-	Limit       *int
-	Offset      *int
+	IncrementalSyncCond
 }
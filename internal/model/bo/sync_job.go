@@ -0,0 +1,215 @@
+package bo
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/po"
+	"sync"
+	"time"
+)
+
+type SyncJob struct {
+	JobId      int64
+	JobType    syncjob.Type
+	DryRun     bool
+	Cond       string
+	Status     syncjob.Status
+	Report     SyncJobReport
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type SyncJobCond struct {
+	JobType syncjob.Type
+	Status  syncjob.Status
+	po.Pager
+}
+
+type SyncJobReport struct {
+	Total   int
+	Created int
+	Updated int
+	Deleted int
+	Skipped int
+	Failed  int
+	// Unchanged dry run 時與 kintone 相同的記錄數
+	Unchanged int
+	Failures  []*SyncJobFailure
+	// Error 無法完成同步的錯誤，不屬於任何一筆記錄 (e.g. 取得 kintone 資料失敗)
+	Error string
+	// Diff dry run 時會套用的變更，非 dry run 時為 nil
+	Diff *SyncDiff
+	// Reconciliation 對帳工作的結果，其他同步為 nil
+	Reconciliation *ReconciliationReport
+}
+
+type SyncJobFailure struct {
+	Type        syncjob.Type `json:"type"`
+	RecordRefId int          `json:"record_ref_id"`
+	Error       string       `json:"error"`
+}
+
+// SyncDiff dry run 時依變更類型列出的記錄
+type SyncDiff struct {
+	Creates  []*SyncDiffRecord `json:"creates"`
+	Updates  []*SyncDiffRecord `json:"updates"`
+	Deletes  []*SyncDiffRecord `json:"deletes"`
+	Restores []*SyncDiffRecord `json:"restores"`
+	// Truncated 超過 MaxDiffRecords 時只保留前面的記錄
+	Truncated bool `json:"truncated"`
+}
+
+type SyncDiffRecord struct {
+	Type        syncjob.Type `json:"type"`
+	RecordRefId int          `json:"record_ref_id"`
+	// Key 同一筆 kintone 記錄對應多筆 db 記錄時用來區分 (e.g. 課表的學生)
+	Key     string             `json:"key,omitempty"`
+	Changes []*SyncFieldChange `json:"changes,omitempty"`
+}
+
+type SyncFieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// IncrementalSyncCond UpdatedSince 不為 nil 時為增量同步，只同步此時間之後更新的記錄
+// 已刪除的記錄改以 kintone 上全部的 $id 判斷
+type IncrementalSyncCond struct {
+	UpdatedSince *time.Time
+}
+
+// SyncJobTracker 記錄同步中每筆記錄的結果，BatchSync 不需要追蹤時傳入 nil
+// dry run 時 BatchSync 不寫入 db，只透過 Diff 記錄會套用的變更
+type SyncJobTracker struct {
+	mu     sync.Mutex
+	dryRun bool
+	report SyncJobReport
+}
+
+func NewSyncJobTracker() *SyncJobTracker {
+	return &SyncJobTracker{}
+}
+
+func NewDryRunSyncJobTracker() *SyncJobTracker {
+	return &SyncJobTracker{dryRun: true, report: SyncJobReport{Diff: &SyncDiff{}}}
+}
+
+func (t *SyncJobTracker) DryRun() bool {
+	return t != nil && t.dryRun
+}
+
+// AddTotal 增加預計同步的記錄數
+func (t *SyncJobTracker) AddTotal(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.report.Total += n
+}
+
+// Track 記錄一筆記錄的同步結果，err 不為 nil 時記為失敗
+func (t *SyncJobTracker) Track(jobType syncjob.Type, recordRefId int, action syncjob.Action, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.report.Failed++
+		if len(t.report.Failures) < syncjob.MaxFailures {
+			t.report.Failures = append(t.report.Failures, &SyncJobFailure{Type: jobType, RecordRefId: recordRefId, Error: err.Error()})
+		}
+		return
+	}
+
+	switch action {
+	case syncjob.ActionCreated:
+		t.report.Created++
+	case syncjob.ActionUpdated:
+		t.report.Updated++
+	case syncjob.ActionDeleted:
+		t.report.Deleted++
+	case syncjob.ActionSkipped:
+		t.report.Skipped++
+	case syncjob.ActionUnchanged:
+		t.report.Unchanged++
+	}
+}
+
+// Diff 記錄 dry run 時一筆記錄會套用的變更
+func (t *SyncJobTracker) Diff(kind syncjob.DiffKind, record *SyncDiffRecord) {
+	if !t.DryRun() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	diff := t.report.Diff
+	if len(diff.Creates)+len(diff.Updates)+len(diff.Deletes)+len(diff.Restores) >= syncjob.MaxDiffRecords {
+		diff.Truncated = true
+		return
+	}
+	switch kind {
+	case syncjob.DiffCreate:
+		diff.Creates = append(diff.Creates, record)
+	case syncjob.DiffUpdate:
+		diff.Updates = append(diff.Updates, record)
+	case syncjob.DiffDelete:
+		diff.Deletes = append(diff.Deletes, record)
+	case syncjob.DiffRestore:
+		diff.Restores = append(diff.Restores, record)
+	}
+}
+
+// Abort 記錄無法完成同步的錯誤，同步最後會標記為失敗
+func (t *SyncJobTracker) Abort(jobType syncjob.Type, err error) {
+	if t == nil || err == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	msg := string(jobType) + ": " + err.Error()
+	if t.report.Error != "" {
+		msg = t.report.Error + "; " + msg
+	}
+	t.report.Error = msg
+}
+
+// SetReconciliation 記錄對帳工作的結果
+func (t *SyncJobTracker) SetReconciliation(report *ReconciliationReport) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.report.Reconciliation = report
+}
+
+// Report 目前的同步結果
+func (t *SyncJobTracker) Report() SyncJobReport {
+	if t == nil {
+		return SyncJobReport{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := t.report
+	report.Failures = append([]*SyncJobFailure(nil), t.report.Failures...)
+	if t.report.Diff != nil {
+		report.Diff = &SyncDiff{
+			Creates:   append([]*SyncDiffRecord(nil), t.report.Diff.Creates...),
+			Updates:   append([]*SyncDiffRecord(nil), t.report.Diff.Updates...),
+			Deletes:   append([]*SyncDiffRecord(nil), t.report.Diff.Deletes...),
+			Restores:  append([]*SyncDiffRecord(nil), t.report.Diff.Restores...),
+			Truncated: t.report.Diff.Truncated,
+		}
+	}
+	return report
+}
@@ -11,7 +11,8 @@ type User struct {
 	Account           string
 	Password          string
 	Status            user.UserStatus
-	Level             user.UserLevel // unused
+	Level             user.UserLevel
+	Role              user.AdminRole
 	IsChangedPassword bool
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
@@ -21,6 +22,7 @@ type UserCond struct {
 	UserId            int64
 	Accounts          []string
 	Status            user.UserStatus
+	Level             user.UserLevel
 	IsChangedPassword *bool
 	po.Pager
 }
@@ -33,10 +35,13 @@ type UserLoginCond struct {
 type CreateUserData struct {
 	Account  string
 	Password string
+	Level    user.UserLevel // 沒有指定時為一般使用者
+	Role     user.AdminRole
 }
 
 type UpdateUserData struct {
 	Password          *string
 	Status            user.UserStatus
+	Role              user.AdminRole
 	IsChangedPassword *bool
 }
@@ -0,0 +1,37 @@
+package bo
+
+import (
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/model/po"
+	"time"
+)
+
+type WebhookEvent struct {
+	EventId        int64
+	Source         webhook.Source
+	WebhookId      string
+	WebhookType    string
+	AppId          string
+	RecordId       string
+	RecordRevision int
+	Payload        string
+	Status         webhook.Status
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	ProcessedAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// PendingRecords BatchSync 開始同步時，還有 webhook 沒處理完或 snapshotAt 之後才收到 webhook 的記錄
+type PendingRecords struct {
+	Source    webhook.Source
+	RecordIds map[string]struct{}
+}
+
+type WebhookEventCond struct {
+	Source webhook.Source
+	Status webhook.Status
+	po.Pager
+}
@@ -0,0 +1,25 @@
+package dto
+
+type AdminCreateApiKeyIO struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresAt string   `json:"expires_at"` // RFC3339，不帶代表不會過期
+}
+
+type AdminApiKeyVO struct {
+	KeyId      string   `json:"key_id"`
+	Name       string   `json:"name"`
+	KeyPrefix  string   `json:"key_prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	RevokedAt  string   `json:"revoked_at"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// AdminCreatedApiKeyVO Key 只在建立時回傳一次，之後無法再取得
+type AdminCreatedApiKeyVO struct {
+	AdminApiKeyVO
+	Key string `json:"key"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AdminGetAuditLogsIO struct {
+	Actor      *string    `form:"actor"`
+	Action     *string    `form:"action"`
+	TargetType *string    `form:"target_type"`
+	TargetId   *int64     `form:"target_id"`
+	RequestId  *string    `form:"request_id"`
+	Since      *time.Time `form:"since"` // RFC3339
+	Until      *time.Time `form:"until"` // RFC3339
+	*PagerIO
+}
+
+type AdminAuditLogVO struct {
+	LogId      string          `json:"log_id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetId   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestId  string          `json:"request_id"`
+	CreatedAt  string          `json:"created_at"`
+}
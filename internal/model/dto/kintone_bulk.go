@@ -0,0 +1,49 @@
+package dto
+
+import "jaystar/internal/constant/kintone"
+
+type KintoneBulkRequestReq struct {
+	Requests []KintoneBulkRequest `json:"requests"`
+}
+
+type KintoneBulkRequest struct {
+	Method  string `json:"method"`
+	Api     string `json:"api"`
+	Payload any    `json:"payload"`
+}
+
+// KintoneBulkRequestRes results 與 requests 的順序相同
+type KintoneBulkRequestRes struct {
+	Results []KintoneBulkRequestResult `json:"results"`
+}
+
+type KintoneBulkRequestResult struct {
+	Records []KintoneApiUpdateBaseResponse `json:"records"`
+}
+
+// KintoneBulkUpdateRecords bulkRequest 中的一個 PUT records.json 請求
+type KintoneBulkUpdateRecords struct {
+	App     kintone.App
+	Records []KintoneUpdateRecord
+}
+
+// KintoneUpdateRecord Record 為各應用程式的更新欄位 (e.g. UpdateDepositRecordValue)
+type KintoneUpdateRecord struct {
+	KintoneUpdateIdBase
+	Record any `json:"record"`
+}
+
+type KintoneUpdateRecordsPayload struct {
+	KintoneUpdateAppBase
+	Records []KintoneUpdateRecord `json:"records"`
+}
+
+// NewKintoneBulkUpdateRecords 依 BatchUpdateRecordsMaxLimit 切成多個請求
+func NewKintoneBulkUpdateRecords(app kintone.App, records []KintoneUpdateRecord) []*KintoneBulkUpdateRecords {
+	updates := make([]*KintoneBulkUpdateRecords, 0, (len(records)+kintone.BatchUpdateRecordsMaxLimit-1)/kintone.BatchUpdateRecordsMaxLimit)
+	for start := 0; start < len(records); start += kintone.BatchUpdateRecordsMaxLimit {
+		end := min(start+kintone.BatchUpdateRecordsMaxLimit, len(records))
+		updates = append(updates, &KintoneBulkUpdateRecords{App: app, Records: records[start:end]})
+	}
+	return updates
+}
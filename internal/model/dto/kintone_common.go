@@ -40,4 +40,47 @@ type KintoneUpdateAppBase struct {
 
 type KintoneUpdateIdBase struct {
 	Id int `json:"id"`
+	// Revision 讀取時的 $revision，kintone 上的記錄已被修改時回傳 GAIA_CO02，0 代表不檢查
+	Revision int `json:"revision,omitempty"`
+}
+
+func (b KintoneUpdateIdBase) GetRevision() int {
+	return b.Revision
+}
+
+// HasRevision 是否有任一筆記錄帶 revision
+// 帶 revision 的更新不能自動重送：第一次請求實際已寫入時，重送會收到 GAIA_CO02 而被誤判為衝突
+func HasRevision[T interface{ GetRevision() int }](records []T) bool {
+	for _, record := range records {
+		if record.GetRevision() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// KintoneRecordIdDto 只取 $id 欄位的記錄
+type KintoneRecordIdDto struct {
+	Id IdField `json:"$id"`
+}
+
+type KintoneCreateCursorReq struct {
+	App    string   `json:"app"`
+	Fields []string `json:"fields,omitempty"`
+	Query  string   `json:"query,omitempty"`
+	Size   int      `json:"size,omitempty"`
+}
+
+type KintoneCreateCursorRes struct {
+	Id         string `json:"id"`
+	TotalCount string `json:"totalCount"`
+}
+
+type KintoneCursorRecordsRes[T any] struct {
+	Records []T  `json:"records"`
+	Next    bool `json:"next"`
+}
+
+type KintoneDeleteCursorReq struct {
+	Id string `json:"id"`
 }
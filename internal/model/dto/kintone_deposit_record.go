@@ -4,16 +4,35 @@ import (
 	"golang.org/x/xerrors"
 	kintoneConst "jaystar/internal/constant/kintone"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/kintoneQuery"
 	"jaystar/internal/utils/strUtil"
 	"time"
 )
 
 type DepositRecordReq struct {
-	StudentName       string    `kQuery:"studentName"`
-	ChargingDateStart time.Time `kQuery:"chargingDate >="`
-	ChargingDateEnd   time.Time `kQuery:"chargingDate <="`
-	Limit             int       `kQuery:"limit"`
-	Offset            int       `kQuery:"offset"`
+	StudentName       string
+	ChargingDateStart time.Time
+	ChargingDateEnd   time.Time
+	UpdatedSince      time.Time // 只取得此時間之後更新的記錄
+	Limit             int
+	Offset            int
+}
+
+func (req *DepositRecordReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New()
+	if req.StudentName != "" {
+		q.Where(kintoneQuery.Eq("studentName", req.StudentName))
+	}
+	if !req.ChargingDateStart.IsZero() {
+		q.Where(kintoneQuery.Ge("chargingDate", req.ChargingDateStart))
+	}
+	if !req.ChargingDateEnd.IsZero() {
+		q.Where(kintoneQuery.Le("chargingDate", req.ChargingDateEnd))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("updatedAt", req.UpdatedSince))
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type DepositRecordRes struct {
@@ -23,6 +42,7 @@ type DepositRecordRes struct {
 
 type KintoneDepositRecordRecordDto struct {
 	Id                   IdField       `json:"$id"`
+	Revision             IntField      `json:"$revision"`
 	ChargingMethod       CheckboxField `json:"chargingMethod"`
 	ActualChargingAmount IntField      `json:"actualChargingAmount"`
 	Gender               StringField   `json:"gender"`
@@ -50,6 +70,10 @@ func (d *KintoneDepositRecordRecordDto) ToKintoneDepositRecordBo() (*bo.KintoneD
 		return nil, xerrors.Errorf("d.Id.ToId: %w", err)
 	}
 	boDepositRecord.Id = id
+	boDepositRecord.Revision, err = d.Revision.ToInt()
+	if err != nil {
+		return nil, xerrors.Errorf("d.Revision.ToInt: %w", err)
+	}
 	kintoneStudentName := d.StudentName.ToString()
 	boDepositRecord.KintoneStudentName = kintoneStudentName
 	boDepositRecord.StudentName = strUtil.GetStudentNameByStudentName(kintoneStudentName)
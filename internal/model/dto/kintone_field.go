@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"jaystar/internal/utils/points"
 	"strconv"
 	"time"
 )
@@ -42,9 +43,8 @@ type FloatField struct {
 	NormalField
 }
 
-func (f FloatField) ToFloat() (float64, error) {
-	return strconv.ParseFloat(f.Value, 64)
-
+func (f FloatField) ToPoints() (points.Points, error) {
+	return points.Parse(f.Value)
 }
 
 type StringField struct {
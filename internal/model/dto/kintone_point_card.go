@@ -3,13 +3,27 @@ package dto
 import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/kintoneQuery"
 	"jaystar/internal/utils/strUtil"
+	"time"
 )
 
 type GetPointCardReq struct {
-	StudentName string `kQuery:"studentName"`
-	Limit       int    `kQuery:"limit"`
-	Offset      int    `kQuery:"offset"`
+	StudentName  string
+	UpdatedSince time.Time // 只取得此時間之後更新的記錄
+	Limit        int
+	Offset       int
+}
+
+func (req *GetPointCardReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New()
+	if req.StudentName != "" {
+		q.Where(kintoneQuery.Eq("studentName", req.StudentName))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("更新時間", req.UpdatedSince))
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type GetPointCardRes struct {
@@ -19,6 +33,7 @@ type GetPointCardRes struct {
 
 type PointCardRecord struct {
 	Id          IdField       `json:"$id"`
+	Revision    IntField      `json:"$revision"`
 	StudentName StringField   `json:"studentName"`
 	RestPoints  FloatField    `json:"restPoints"`
 	CreatedBy   StringOpField `json:"建立人"`
@@ -34,13 +49,17 @@ func (rr *PointCardRecord) ToPointCard() (*bo.PointCard, error) {
 		return nil, xerrors.Errorf("Id.ToId: %w", err)
 	}
 	pointCard.RecordRefId = id
+	pointCard.Revision, err = rr.Revision.ToInt()
+	if err != nil {
+		return nil, xerrors.Errorf("Revision.ToInt: %w", err)
+	}
 	kintoneStudentName := rr.StudentName.ToString()
 	pointCard.KintoneStudentName = kintoneStudentName
 	pointCard.StudentName = strUtil.GetStudentNameByStudentName(kintoneStudentName)
 	pointCard.ParentPhone = strUtil.GetParentPhoneByStudentName(kintoneStudentName)
-	pointCard.RestPoints, err = rr.RestPoints.ToFloat()
+	pointCard.RestPoints, err = rr.RestPoints.ToPoints()
 	if err != nil {
-		return nil, xerrors.Errorf("RestPoints.ToFloat: %w", err)
+		return nil, xerrors.Errorf("RestPoints.ToPoints: %w", err)
 	}
 
 	return pointCard, nil
@@ -68,7 +87,13 @@ type UpdatePointCardsRecord struct {
 		Field string `json:"field"`
 		Value string `json:"value"`
 	} `json:"updateKey"`
-	Record UpdatePointCardsRecordValue `json:"record"`
+	// Revision 0 代表不檢查
+	Revision int                         `json:"revision,omitempty"`
+	Record   UpdatePointCardsRecordValue `json:"record"`
+}
+
+func (r UpdatePointCardsRecord) GetRevision() int {
+	return r.Revision
 }
 
 type UpdatePointCardsRecordValue struct {
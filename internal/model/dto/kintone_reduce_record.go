@@ -3,6 +3,7 @@ package dto
 import (
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/kintoneQuery"
 	"jaystar/internal/utils/strUtil"
 	"time"
 
@@ -10,11 +11,29 @@ import (
 )
 
 type ReduceRecordReq struct {
-	StudentName    string    `kQuery:"studentName"`
-	Limit          int       `kQuery:"limit"`
-	Offset         int       `kQuery:"offset"`
-	ClassTimeStart time.Time `kQuery:"classTime >="`
-	ClassTimeEnd   time.Time `kQuery:"classTime <="`
+	StudentName    string
+	Limit          int
+	Offset         int
+	ClassTimeStart time.Time
+	ClassTimeEnd   time.Time
+	UpdatedSince   time.Time // 只取得此時間之後更新的記錄
+}
+
+func (req *ReduceRecordReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New()
+	if req.StudentName != "" {
+		q.Where(kintoneQuery.Eq("studentName", req.StudentName))
+	}
+	if !req.ClassTimeStart.IsZero() {
+		q.Where(kintoneQuery.Ge("classTime", req.ClassTimeStart))
+	}
+	if !req.ClassTimeEnd.IsZero() {
+		q.Where(kintoneQuery.Le("classTime", req.ClassTimeEnd))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("updatedAt", req.UpdatedSince))
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type ReduceRecordRes struct {
@@ -24,6 +43,7 @@ type ReduceRecordRes struct {
 
 type ReduceRecordRecord struct {
 	Id           IdField       `json:"$id"`
+	Revision     IntField      `json:"$revision"`
 	StudentName  StringField   `json:"studentName"`
 	ClassLevel   StringField   `json:"classLevel"`
 	ClassType    StringField   `json:"classType"`
@@ -45,6 +65,10 @@ func (rr *ReduceRecordRecord) ToKintoneReduceRecord() (*bo.KintoneReduceRecord,
 		return nil, xerrors.Errorf("Id.ToId: %w", err)
 	}
 	reduceRecord.Id = id
+	reduceRecord.Revision, err = rr.Revision.ToInt()
+	if err != nil {
+		return nil, xerrors.Errorf("Revision.ToInt: %w", err)
+	}
 	kintoneStudentName := rr.StudentName.ToString()
 	reduceRecord.KintoneStudentName = kintoneStudentName
 	reduceRecord.StudentName = strUtil.GetStudentNameByStudentName(kintoneStudentName)
@@ -56,9 +80,9 @@ func (rr *ReduceRecordRecord) ToKintoneReduceRecord() (*bo.KintoneReduceRecord,
 		return nil, xerrors.Errorf("ClassTime.ToDateTime: %w", err)
 	}
 	reduceRecord.TeacherName = rr.TeacherName.ToString()
-	reduceRecord.ReducePoints, err = rr.ReducePoints.ToFloat()
+	reduceRecord.ReducePoints, err = rr.ReducePoints.ToPoints()
 	if err != nil {
-		return nil, xerrors.Errorf("ReducePoints.ToFloat: %w", err)
+		return nil, xerrors.Errorf("ReducePoints.ToPoints: %w", err)
 	}
 	if len(rr.AttendStatus.Value) > 0 && rr.AttendStatus.Value[0] == "出席" {
 		reduceRecord.AttendStatus = true
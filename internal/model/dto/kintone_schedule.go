@@ -4,6 +4,7 @@ import (
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/kintoneQuery"
 	"jaystar/internal/utils/strUtil"
 	"time"
 
@@ -11,12 +12,31 @@ import (
 )
 
 type ScheduleReq struct {
-	StudentName    string    `kQuery:"studentName in"`
-	ClassTimeStart time.Time `kQuery:"classTime >="`
-	ClassTimeEnd   time.Time `kQuery:"classTime <="`
-	Limit          int       `kQuery:"limit"`
-	Offset         int       `kQuery:"offset"`
-	OrderBy        string    `kQuery:"order by"`
+	// StudentNames 子表格欄位只能用 in 查詢
+	StudentNames   []string
+	ClassTimeStart time.Time
+	ClassTimeEnd   time.Time
+	UpdatedSince   time.Time // 只取得此時間之後更新的記錄
+	Limit          int
+	Offset         int
+	OrderBy        []kintoneQuery.Order
+}
+
+func (req *ScheduleReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New(kintoneQuery.In("studentName", req.StudentNames))
+	if !req.ClassTimeStart.IsZero() {
+		q.Where(kintoneQuery.Ge("classTime", req.ClassTimeStart))
+	}
+	if !req.ClassTimeEnd.IsZero() {
+		q.Where(kintoneQuery.Le("classTime", req.ClassTimeEnd))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("updatedAt", req.UpdatedSince))
+	}
+	for _, o := range req.OrderBy {
+		q.OrderBy(o.Field, o.Direction)
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type ScheduleRes struct {
@@ -26,6 +46,7 @@ type ScheduleRes struct {
 
 type ScheduleRecord struct {
 	Id          IdField       `json:"$id"`
+	Revision    IntField      `json:"$revision"`
 	TeacherName StringField   `json:"teacherName"`
 	ClassLevel  StringField   `json:"classLevel"`
 	ClassType   StringField   `json:"classType"`
@@ -3,17 +3,39 @@ package dto
 import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/kintoneQuery"
 	"jaystar/internal/utils/strUtil"
 	"time"
 )
 
 type SemesterSettleRecordReq struct {
-	StudentName string    `kQuery:"studentName"`
-	StartTime   time.Time `kQuery:"startTime >="`
-	EndTime     time.Time `kQuery:"endTime <="`
-	Limit       int       `kQuery:"limit"`
-	Offset      int       `kQuery:"offset"`
-	OrderBy     string    `kQuery:"order by"`
+	StudentName  string
+	StartTime    time.Time
+	EndTime      time.Time
+	UpdatedSince time.Time // 只取得此時間之後更新的記錄
+	Limit        int
+	Offset       int
+	OrderBy      []kintoneQuery.Order
+}
+
+func (req *SemesterSettleRecordReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New()
+	if req.StudentName != "" {
+		q.Where(kintoneQuery.Eq("studentName", req.StudentName))
+	}
+	if !req.StartTime.IsZero() {
+		q.Where(kintoneQuery.Ge("startTime", req.StartTime))
+	}
+	if !req.EndTime.IsZero() {
+		q.Where(kintoneQuery.Le("endTime", req.EndTime))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("更新時間", req.UpdatedSince))
+	}
+	for _, o := range req.OrderBy {
+		q.OrderBy(o.Field, o.Direction)
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type SemesterSettleRecordRes struct {
@@ -23,6 +45,7 @@ type SemesterSettleRecordRes struct {
 
 type SemesterSettleRecord struct {
 	Id          IdField       `json:"$id"`
+	Revision    IntField      `json:"$revision"`
 	StudentName StringField   `json:"studentName"`
 	StartTime   DateTimeField `json:"startTime"`
 	EndTime     DateTimeField `json:"endTime"`
@@ -52,9 +75,9 @@ func (rr *SemesterSettleRecord) ToSemesterSettleRecord() (*bo.SemesterSettleReco
 	if err != nil {
 		return nil, xerrors.Errorf("EndTime.ToDate: %w", err)
 	}
-	semesterSettleRecord.ClearPoints, err = rr.ClearPoints.ToFloat()
+	semesterSettleRecord.ClearPoints, err = rr.ClearPoints.ToPoints()
 	if err != nil {
-		return nil, xerrors.Errorf("ClearPoints.ToFloat: %w", err)
+		return nil, xerrors.Errorf("ClearPoints.ToPoints: %w", err)
 	}
 	semesterSettleRecord.CreatedBy = rr.CreatedBy.ToString()
 	semesterSettleRecord.CreatedAt, _ = rr.CreatedAt.ToDateTime()
@@ -79,3 +102,7 @@ type InsertSemesterSettleRecordsRes struct {
 	Ids       []string `json:"ids"`
 	Revisions []string `json:"revisions"`
 }
+
+type DeleteSemesterSettleRecordsReq struct {
+	Ids []int `json:"ids"`
+}
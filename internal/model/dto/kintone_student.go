@@ -4,13 +4,30 @@ import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/kintoneQuery"
+	"time"
 )
 
 type StudentReq struct {
-	StudentName string `kQuery:"studentName"`
-	ParentPhone string `kQuery:"parentPhone"`
-	Limit       int    `kQuery:"limit"`
-	Offset      int    `kQuery:"offset"`
+	StudentName  string
+	ParentPhone  string
+	UpdatedSince time.Time // 只取得此時間之後更新的記錄
+	Limit        int
+	Offset       int
+}
+
+func (req *StudentReq) ToQuery() *kintoneQuery.Query {
+	q := kintoneQuery.New()
+	if req.StudentName != "" {
+		q.Where(kintoneQuery.Eq("studentName", req.StudentName))
+	}
+	if req.ParentPhone != "" {
+		q.Where(kintoneQuery.Eq("parentPhone", req.ParentPhone))
+	}
+	if !req.UpdatedSince.IsZero() {
+		q.Where(kintoneQuery.Ge("updatedAt", req.UpdatedSince))
+	}
+	return q.Limit(req.Limit).Offset(req.Offset)
 }
 
 type StudentRes struct {
@@ -20,6 +37,7 @@ type StudentRes struct {
 
 type StudentRecord struct {
 	Id               IdField       `json:"$id"`
+	Revision         IntField      `json:"$revision"`
 	StudentName      StringField   `json:"studentName"`
 	Birthday         StringField   `json:"birthday"`
 	Gender           StringField   `json:"gender"`
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+type AdminStudentBalanceVO struct {
+	StudentId        string        `json:"student_id"`
+	StudentName      string        `json:"student_name"`
+	ParentPhone      string        `json:"parent_phone"`
+	DerivedBalance   points.Points `json:"derived_balance"` // 由儲值、扣點與學期結算記錄推算的餘額
+	PointCardBalance points.Points `json:"point_card_balance"`
+	HasPointCard     bool          `json:"has_point_card"`
+	BalanceMatched   bool          `json:"balance_matched"`
+}
+
+type AdminStudentLedgerVO struct {
+	AdminStudentBalanceVO
+	Entries []LedgerEntryVO `json:"entries"`
+}
+
+type LedgerEntryVO struct {
+	EntryType   string        `json:"entry_type"`
+	RecordRefId int           `json:"record_ref_id"`
+	OccurredAt  string        `json:"occurred_at"`
+	Change      points.Points `json:"change"`
+	Balance     points.Points `json:"balance"`
+}
+
+type StudentBalanceAsOfIO struct {
+	StudentId int64      `form:"student_id" binding:"required"`
+	AsOf      *time.Time `form:"as_of" binding:"required"` // RFC3339
+}
+
+type AdminStudentBalanceAsOfIO struct {
+	AsOf *time.Time `form:"as_of" binding:"required"` // RFC3339
+}
+
+type StudentBalanceAsOfVO struct {
+	StudentId   string          `json:"student_id"`
+	StudentName string          `json:"student_name"`
+	ParentPhone string          `json:"parent_phone,omitempty"`
+	AsOf        string          `json:"as_of"`
+	Balance     points.Points   `json:"balance"`
+	Entries     []LedgerEntryVO `json:"entries"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+type GetLoginSessionsIO struct {
+	*PagerIO
+}
+
+type AdminGetLoginSessionsIO struct {
+	Active *bool `form:"active"` // 只查詢未撤銷且未過期的登入
+	*PagerIO
+}
+
+type LoginSessionVO struct {
+	SessionId     string `json:"session_id"`
+	UserId        string `json:"user_id"`
+	UserAgent     string `json:"user_agent"`
+	Ip            string `json:"ip"`
+	ExpiresAt     string `json:"expires_at"`
+	LastSeenAt    string `json:"last_seen_at"`
+	RevokedAt     string `json:"revoked_at"`
+	RevokedReason string `json:"revoked_reason"`
+	CreatedAt     string `json:"created_at"`
+	IsCurrent     bool   `json:"is_current"`
+}
@@ -0,0 +1,43 @@
+package dto
+
+import "jaystar/internal/utils/points"
+
+// AdminReconciliationJobVO 對帳完成前 report 為 null
+type AdminReconciliationJobVO struct {
+	JobId      string                       `json:"job_id"`
+	Status     string                       `json:"status"`
+	Error      string                       `json:"error"`
+	Report     *AdminReconciliationReportVO `json:"report"`
+	StartedAt  string                       `json:"started_at"`
+	FinishedAt string                       `json:"finished_at"`
+	CreatedAt  string                       `json:"created_at"`
+}
+
+type AdminReconciliationReportVO struct {
+	CheckedAt   string                         `json:"checked_at"`
+	HasMismatch bool                           `json:"has_mismatch"`
+	Apps        []AdminReconciliationAppVO     `json:"apps"`
+	Students    []AdminReconciliationStudentVO `json:"students"` // 只列出有差異的學生
+}
+
+type AdminReconciliationAppVO struct {
+	App              string `json:"app"`
+	Matched          bool   `json:"matched"`
+	KintoneCount     int    `json:"kintone_count"`
+	DbCount          int    `json:"db_count"`
+	MissingInDb      []int  `json:"missing_in_db"`
+	MissingInKintone []int  `json:"missing_in_kintone"`
+}
+
+type AdminReconciliationStudentVO struct {
+	StudentId   string                          `json:"student_id"`
+	StudentName string                          `json:"student_name"`
+	ParentPhone string                          `json:"parent_phone"`
+	Mismatches  []AdminReconciliationMismatchVO `json:"mismatches"`
+}
+
+type AdminReconciliationMismatchVO struct {
+	Field   string        `json:"field"`
+	Kintone points.Points `json:"kintone"`
+	Db      points.Points `json:"db"`
+}
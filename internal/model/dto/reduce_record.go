@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"jaystar/internal/utils/points"
 	"time"
 )
 
@@ -18,16 +19,16 @@ type ReduceRecordGetIO struct {
 }
 
 type ReduceRecordGetVO struct {
-	RecordType   string  `json:"record_type"`
-	RecordId     string  `json:"record_id"`
-	StudentName  string  `json:"student_name"`
-	ParentPhone  string  `json:"parent_phone,omitempty"`
-	ClassLevel   string  `json:"class_level"`
-	ClassType    string  `json:"class_type"`
-	ClassTime    string  `json:"class_time"`
-	TeacherName  string  `json:"teacher_name"`
-	ReducePoints float64 `json:"reduce_points"`
-	IsAttended   bool    `json:"is_attended"`
+	RecordType   string        `json:"record_type"`
+	RecordId     string        `json:"record_id"`
+	StudentName  string        `json:"student_name"`
+	ParentPhone  string        `json:"parent_phone,omitempty"`
+	ClassLevel   string        `json:"class_level"`
+	ClassType    string        `json:"class_type"`
+	ClassTime    string        `json:"class_time"`
+	TeacherName  string        `json:"teacher_name"`
+	ReducePoints points.Points `json:"reduce_points"`
+	IsAttended   bool          `json:"is_attended"`
 }
 
 type ReduceRecordSyncIO struct {
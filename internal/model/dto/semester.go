@@ -0,0 +1,20 @@
+package dto
+
+type AdminSemesterIO struct {
+	Name       string `json:"name" binding:"required"`
+	StartDate  string `json:"start_date" binding:"required"`  // 2006-01-02
+	EndDate    string `json:"end_date" binding:"required"`    // 2006-01-02
+	SettleDate string `json:"settle_date" binding:"required"` // 2006-01-02
+	TimeZone   string `json:"time_zone"`                      // 沒有帶時使用 Asia/Taipei
+}
+
+type AdminSemesterVO struct {
+	SemesterId string `json:"semester_id"`
+	Name       string `json:"name"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	SettleDate string `json:"settle_date"`
+	TimeZone   string `json:"time_zone"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
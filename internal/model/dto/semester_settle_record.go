@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"jaystar/internal/utils/points"
+	"time"
+)
 
 type KintoneWebhookSemesterSettleRecordIO struct {
 	KintoneWebhookIO
@@ -17,12 +20,12 @@ type AdminGetSemesterSettleRecordsIO struct {
 }
 
 type GetSemesterSettleRecordsVO struct {
-	RecordId    string  `json:"record_id"`
-	StudentName string  `json:"student_name"`
-	ParentPhone string  `json:"parent_phone"`
-	StartTime   string  `json:"start_time"`
-	EndTime     string  `json:"end_time"`
-	ClearPoints float64 `json:"clear_points"`
+	RecordId    string        `json:"record_id"`
+	StudentName string        `json:"student_name"`
+	ParentPhone string        `json:"parent_phone"`
+	StartTime   string        `json:"start_time"`
+	EndTime     string        `json:"end_time"`
+	ClearPoints points.Points `json:"clear_points"`
 }
 
 type AdminGetSemesterSettleRecordsVO struct {
@@ -38,3 +41,81 @@ type SyncSemesterSettleRecordIO struct {
 type SettleSemesterPointsIO struct {
 	Date string `json:"date"`
 }
+
+type AdminSettlementPreviewIO struct {
+	Format string `form:"format"` // csv 時以檔案下載
+}
+
+type AdminSettlementPreviewVO struct {
+	SemesterId   string `json:"semester_id"`
+	SemesterName string `json:"semester_name"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	// Synced 預覽前是否已同步 kintone 資料，預覽的學生與點數使用 db 目前的資料，正式結算前才會同步
+	Synced bool                           `json:"synced"`
+	Items  []AdminSettlementPreviewItemVO `json:"items"`
+}
+
+type AdminSettlementPreviewItemVO struct {
+	StudentId          string        `json:"student_id"`
+	StudentName        string        `json:"student_name"`
+	ParentPhone        string        `json:"parent_phone"`
+	TotalDepositPoints int           `json:"total_deposit_points"`
+	TotalReducePoints  points.Points `json:"total_reduce_points"`
+	ClearPoints        points.Points `json:"clear_points"`
+	RestPointsBefore   points.Points `json:"rest_points_before"`
+	RestPointsAfter    points.Points `json:"rest_points_after"`
+}
+
+type AdminGetSettlementRunsIO struct {
+	SemesterId *int64 `form:"semester_id"`
+	*PagerIO
+}
+
+type AdminResumeSettlementRunIO struct {
+	Force bool `json:"force"` // 接手狀態停在執行中的結算
+}
+
+type AdminSettlementRunVO struct {
+	RunId      string                     `json:"run_id"`
+	SemesterId string                     `json:"semester_id"`
+	StartTime  string                     `json:"start_time"`
+	EndTime    string                     `json:"end_time"`
+	Status     string                     `json:"status"`
+	TotalCount int                        `json:"total_count"`
+	StepCounts map[string]int             `json:"step_counts"`
+	Error      string                     `json:"error"`
+	StartedAt  string                     `json:"started_at"`
+	FinishedAt string                     `json:"finished_at"`
+	CreatedAt  string                     `json:"created_at"`
+	Items      []AdminSettlementRunItemVO `json:"items,omitempty"`
+}
+
+type AdminSettlementRunItemVO struct {
+	StudentId          string         `json:"student_id"`
+	KintoneStudentName string         `json:"kintone_student_name"`
+	ClearPoints        points.Points  `json:"clear_points"`
+	Step               string         `json:"step"`
+	SettleRecordRefId  *int           `json:"settle_record_ref_id"`
+	RestPointsBefore   points.Points  `json:"rest_points_before"`
+	RestPointsAfter    *points.Points `json:"rest_points_after"`
+	ReversalId         string         `json:"reversal_id"`
+}
+
+type AdminReverseSettlementIO struct {
+	Reason string `json:"reason"`
+	Force  bool   `json:"force"` // 接手狀態停在撤銷中的結算
+}
+
+type AdminSettlementReversalVO struct {
+	ReversalId    string `json:"reversal_id"`
+	RunId         string `json:"run_id"`
+	StudentId     string `json:"student_id"` // 空字串代表撤銷整個學期的結算
+	Operator      string `json:"operator"`
+	Reason        string `json:"reason"`
+	Status        string `json:"status"`
+	ReversedCount int    `json:"reversed_count"`
+	Error         string `json:"error"`
+	FinishedAt    string `json:"finished_at"`
+	CreatedAt     string `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "jaystar/internal/utils/points"
+
 type AdminGetStudentsIO struct {
 	StudentName      *string `form:"student_name"`
 	StudentRefId     *int    `form:"student_ref_id"`
@@ -16,14 +18,14 @@ type StudentIO struct {
 }
 
 type StudentVO struct {
-	StudentId        string  `json:"student_id"`
-	StudentRefId     int     `json:"student_ref_id"`
-	StudentName      string  `json:"student_name"`
-	ParentName       string  `json:"parent_name"`
-	ParentPhone      string  `json:"parent_phone"`
-	Balance          float64 `json:"balance"`
-	Mode             string  `json:"mode"`
-	IsSettleNormally bool    `json:"is_settle_normally"`
+	StudentId        string        `json:"student_id"`
+	StudentRefId     int           `json:"student_ref_id"`
+	StudentName      string        `json:"student_name"`
+	ParentName       string        `json:"parent_name"`
+	ParentPhone      string        `json:"parent_phone"`
+	Balance          points.Points `json:"balance"`
+	Mode             string        `json:"mode"`
+	IsSettleNormally bool          `json:"is_settle_normally"`
 }
 
 type AdminStudentVO struct {
@@ -1,6 +1,73 @@
 package dto
 
+import "encoding/json"
+
 type SyncAllByStudentIO struct {
 	StudentName string `json:"student_name" binding:"required"`
 	ParentPhone string `json:"parent_phone" binding:"required"`
 }
+
+// SyncDryRunIO 所有同步 API 共用的 query，dry_run=true 時只計算變更不寫入 db
+type SyncDryRunIO struct {
+	DryRun bool `form:"dry_run"`
+}
+
+type AdminGetSyncJobsIO struct {
+	JobType *string `form:"job_type"`
+	Status  *string `form:"status"`
+	*PagerIO
+}
+
+type AdminStartSyncJobVO struct {
+	JobId string `json:"job_id"`
+}
+
+type AdminSyncJobVO struct {
+	JobId        string          `json:"job_id"`
+	JobType      string          `json:"job_type"`
+	DryRun       bool            `json:"dry_run"`
+	Cond         json.RawMessage `json:"cond"`
+	Status       string          `json:"status"`
+	TotalCount   int             `json:"total_count"`
+	CreatedCount int             `json:"created_count"`
+	UpdatedCount int             `json:"updated_count"`
+	DeletedCount int             `json:"deleted_count"`
+	SkippedCount int             `json:"skipped_count"`
+	FailedCount  int             `json:"failed_count"`
+	// UnchangedCount dry run 時與 kintone 相同的記錄數
+	UnchangedCount int                     `json:"unchanged_count"`
+	Failures       []AdminSyncJobFailureVO `json:"failures"`
+	Error          string                  `json:"error"`
+	Diff           *AdminSyncDiffVO        `json:"diff,omitempty"`
+	StartedAt      string                  `json:"started_at"`
+	FinishedAt     string                  `json:"finished_at"`
+	CreatedAt      string                  `json:"created_at"`
+	UpdatedAt      string                  `json:"updated_at"`
+}
+
+type AdminSyncJobFailureVO struct {
+	Type        string `json:"type"`
+	RecordRefId int    `json:"record_ref_id"`
+	Error       string `json:"error"`
+}
+
+type AdminSyncDiffVO struct {
+	Creates   []AdminSyncDiffRecordVO `json:"creates"`
+	Updates   []AdminSyncDiffRecordVO `json:"updates"`
+	Deletes   []AdminSyncDiffRecordVO `json:"deletes"`
+	Restores  []AdminSyncDiffRecordVO `json:"restores"`
+	Truncated bool                    `json:"truncated"`
+}
+
+type AdminSyncDiffRecordVO struct {
+	Type        string                   `json:"type"`
+	RecordRefId int                      `json:"record_ref_id"`
+	Key         string                   `json:"key,omitempty"`
+	Changes     []AdminSyncFieldChangeVO `json:"changes"`
+}
+
+type AdminSyncFieldChangeVO struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
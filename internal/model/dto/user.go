@@ -30,6 +30,18 @@ type UpdateUserIO struct {
 	IsChangedPassword *bool   `json:"is_changed_password"`
 }
 
+type CreateAdminUserIO struct {
+	Account  string `json:"account" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+type UpdateAdminUserIO struct {
+	Password *string `json:"password"`
+	Status   *bool   `json:"status"`
+	Role     *string `json:"role"`
+}
+
 type UpdateUserPasswordIO struct {
 	Password string `json:"password" binding:"required"`
 }
@@ -46,11 +58,18 @@ type AdminUserVO struct {
 	Account           string `json:"account"`
 	Status            string `json:"status"`
 	Level             string `json:"user_level"`
+	Role              string `json:"role,omitempty"`
 	IsChangedPassword bool   `json:"is_changed_password"`
 	CreatedAt         string `json:"created_at"`
 	UpdatedAt         string `json:"updated_at"`
 }
 
+type AdminSessionVO struct {
+	UserId  string `json:"user_id"`
+	Account string `json:"account"`
+	Role    string `json:"role"`
+}
+
 type PasswordIO struct {
 	Password *string `json:"password"`
 }
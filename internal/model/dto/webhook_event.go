@@ -0,0 +1,39 @@
+package dto
+
+import "encoding/json"
+
+// KintoneWebhookEventIO 存入 webhook_events 時只需要的共用欄位，record 內容留給各應用程式處理
+type KintoneWebhookEventIO struct {
+	KintoneWebhookIO
+	Record struct {
+		Id       IdField `json:"$id"`
+		Revision IdField `json:"$revision"`
+	} `json:"record"`
+}
+
+type AdminGetWebhookEventsIO struct {
+	Source *string `form:"source"`
+	Status *string `form:"status"`
+	*PagerIO
+}
+
+type AdminWebhookEventVO struct {
+	EventId       string          `json:"event_id"`
+	Source        string          `json:"source"`
+	WebhookId     string          `json:"webhook_id"`
+	WebhookType   string          `json:"webhook_type"`
+	AppId         string          `json:"app_id"`
+	RecordId      string          `json:"record_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt string          `json:"next_attempt_at"`
+	LastError     string          `json:"last_error"`
+	ProcessedAt   string          `json:"processed_at"`
+	CreatedAt     string          `json:"created_at"`
+	UpdatedAt     string          `json:"updated_at"`
+}
+
+type AdminReplayWebhookEventsIO struct {
+	EventIds []string `json:"event_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,34 @@
+package po
+
+import "time"
+
+type ApiKey struct {
+	KeyId      int64      `gorm:"column:key_id"`
+	Name       string     `gorm:"column:name"`
+	KeyPrefix  string     `gorm:"column:key_prefix"`
+	KeyHash    string     `gorm:"column:key_hash"`
+	Scopes     string     `gorm:"column:scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	CreatedBy  string     `gorm:"column:created_by"`
+	BaseTimeColumns
+}
+
+func (ApiKey) TableName() string {
+	return "api_keys"
+}
+
+type ApiKeyCond struct {
+	KeyId   int64
+	KeyHash string
+}
+
+type UpdateApiKeyCond struct {
+	KeyId int64
+}
+
+type UpdateApiKeyData struct {
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
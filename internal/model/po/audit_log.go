@@ -0,0 +1,32 @@
+package po
+
+import (
+	"jaystar/internal/constant/audit"
+	"time"
+)
+
+type AuditLog struct {
+	LogId      int64            `gorm:"column:log_id"`
+	Actor      string           `gorm:"column:actor"`
+	Action     audit.Action     `gorm:"column:action"`
+	TargetType audit.TargetType `gorm:"column:target_type"`
+	TargetId   int64            `gorm:"column:target_id"`
+	Before     *string          `gorm:"column:before"`
+	After      *string          `gorm:"column:after"`
+	RequestId  string           `gorm:"column:request_id"`
+	CreatedAt  time.Time        `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+type AuditLogCond struct {
+	Actor      string
+	Action     audit.Action
+	TargetType audit.TargetType
+	TargetId   int64
+	RequestId  string
+	Since      *time.Time
+	Until      *time.Time
+}
@@ -79,4 +79,5 @@ func (DepositRecord) TableName() string {
 type StudentTotalDepositPoints struct {
 	StudentId            int64 `gorm:"column:student_id"`
 	TotalDepositedPoints int   `gorm:"column:total_deposited_points"`
+	RecordCount          int   `gorm:"column:record_count"`
 }
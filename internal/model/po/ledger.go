@@ -0,0 +1,22 @@
+package po
+
+import (
+	"jaystar/internal/constant/ledger"
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+// LedgerEntry 由儲值、扣點與學期結算記錄組成，Points 皆為正數
+type LedgerEntry struct {
+	EntryType   ledger.EntryType `gorm:"column:entry_type"`
+	RecordId    int64            `gorm:"column:record_id"`
+	RecordRefId int              `gorm:"column:record_ref_id"`
+	StudentId   int64            `gorm:"column:student_id"`
+	OccurredAt  time.Time        `gorm:"column:occurred_at"`
+	Points      points.Points    `gorm:"column:points"`
+}
+
+type LedgerEntryCond struct {
+	StudentId int64
+	AsOf      time.Time // 有帶值時只取該時間點前發生、且當時尚未刪除的記錄
+}
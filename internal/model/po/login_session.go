@@ -0,0 +1,45 @@
+package po
+
+import "time"
+
+type LoginSession struct {
+	SessionId     int64      `gorm:"column:session_id"`
+	TokenHash     string     `gorm:"column:token_hash"`
+	UserId        int64      `gorm:"column:user_id"`
+	Data          string     `gorm:"column:data"`
+	UserAgent     string     `gorm:"column:user_agent"`
+	Ip            string     `gorm:"column:ip"`
+	ExpiresAt     time.Time  `gorm:"column:expires_at"`
+	LastSeenAt    time.Time  `gorm:"column:last_seen_at"`
+	RevokedAt     *time.Time `gorm:"column:revoked_at"`
+	RevokedReason string     `gorm:"column:revoked_reason"`
+	BaseTimeColumns
+}
+
+func (LoginSession) TableName() string {
+	return "login_sessions"
+}
+
+type LoginSessionCond struct {
+	SessionId int64
+	UserId    int64
+	TokenHash string
+	// ActiveAt 只查詢該時間點未撤銷且未過期的 session
+	ActiveAt *time.Time
+}
+
+// UpdateLoginSessionCond 只會更新未撤銷的 session
+type UpdateLoginSessionCond struct {
+	SessionId       int64
+	TokenHash       string
+	UserIds         []int64
+	ExceptTokenHash string
+}
+
+type UpdateLoginSessionData struct {
+	Data          string
+	ExpiresAt     *time.Time
+	LastSeenAt    *time.Time
+	RevokedAt     *time.Time
+	RevokedReason string
+}
@@ -1,12 +1,15 @@
 package po
 
-import "time"
+import (
+	"jaystar/internal/utils/points"
+	"time"
+)
 
 type PointCard struct {
-	RecordId    int64   `gorm:"column:record_id;autoIncrement"`
-	RecordRefId int     `gorm:"column:record_ref_id"`
-	StudentId   int64   `gorm:"column:student_id"`
-	RestPoints  float64 `gorm:"column:rest_points"`
+	RecordId    int64         `gorm:"column:record_id;autoIncrement"`
+	RecordRefId int           `gorm:"column:record_ref_id"`
+	StudentId   int64         `gorm:"column:student_id"`
+	RestPoints  points.Points `gorm:"column:rest_points"`
 	DeleteRelatedColumns
 	BaseTimeColumns
 }
@@ -28,7 +31,7 @@ type UpdatePointCardCond struct {
 
 type UpdatePointCardData struct {
 	StudentId  int64
-	RestPoints *float64
+	RestPoints *points.Points
 	IsDeleted  *bool
 	DeletedAt  *time.Time
 }
@@ -1,19 +1,20 @@
 package po
 
 import (
+	"jaystar/internal/utils/points"
 	"time"
 )
 
 type ReduceRecord struct {
-	RecordId     int64      `gorm:"column:record_id"`
-	RecordRefId  int        `gorm:"column:record_ref_id"`
-	StudentId    int64      `gorm:"column:student_id"`
-	ClassType    string     `gorm:"column:class_type;type:class_type"`
-	ClassLevel   string     `gorm:"column:class_level;type:class_level"`
-	ClassTime    *time.Time `gorm:"column:class_time"`
-	ReducePoints float64    `gorm:"column:reduce_points"`
-	TeacherName  string     `gorm:"column:teacher_name"`
-	IsAttended   bool       `gorm:"column:is_attended"`
+	RecordId     int64         `gorm:"column:record_id"`
+	RecordRefId  int           `gorm:"column:record_ref_id"`
+	StudentId    int64         `gorm:"column:student_id"`
+	ClassType    string        `gorm:"column:class_type;type:class_type"`
+	ClassLevel   string        `gorm:"column:class_level;type:class_level"`
+	ClassTime    *time.Time    `gorm:"column:class_time"`
+	ReducePoints points.Points `gorm:"column:reduce_points"`
+	TeacherName  string        `gorm:"column:teacher_name"`
+	IsAttended   bool          `gorm:"column:is_attended"`
 	DeleteRelatedColumns
 	BaseTimeColumns
 }
@@ -49,13 +50,14 @@ type UpdateReduceRecordData struct {
 	ClassLevel   string
 	ClassTime    *time.Time
 	TeacherName  string
-	ReducePoints *float64
+	ReducePoints *points.Points
 	IsAttended   *bool
 	IsDeleted    *bool
 	DeletedAt    *time.Time
 }
 
 type StudentTotalReducePoints struct {
-	StudentId         int64   `gorm:"column:student_id"`
-	TotalReducePoints float64 `gorm:"column:total_reduce_points"`
+	StudentId         int64         `gorm:"column:student_id"`
+	TotalReducePoints points.Points `gorm:"column:total_reduce_points"`
+	RecordCount       int           `gorm:"column:record_count"`
 }
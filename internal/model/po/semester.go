@@ -0,0 +1,33 @@
+package po
+
+import "time"
+
+type Semester struct {
+	SemesterId int64     `gorm:"column:semester_id"`
+	Name       string    `gorm:"column:name"`
+	StartDate  time.Time `gorm:"column:start_date"`
+	EndDate    time.Time `gorm:"column:end_date"`
+	SettleDate time.Time `gorm:"column:settle_date"`
+	TimeZone   string    `gorm:"column:time_zone"`
+	BaseTimeColumns
+}
+
+func (Semester) TableName() string {
+	return "semesters"
+}
+
+type SemesterCond struct {
+	SemesterId int64
+}
+
+type UpdateSemesterCond struct {
+	SemesterId int64
+}
+
+type UpdateSemesterData struct {
+	Name       string
+	StartDate  time.Time
+	EndDate    time.Time
+	SettleDate time.Time
+	TimeZone   string
+}
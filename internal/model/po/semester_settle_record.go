@@ -1,14 +1,17 @@
 package po
 
-import "time"
+import (
+	"jaystar/internal/utils/points"
+	"time"
+)
 
 type SemesterSettleRecord struct {
-	RecordId    int64     `gorm:"column:record_id"`
-	RecordRefId int       `gorm:"column:record_ref_id"`
-	StudentId   int64     `gorm:"column:student_id"`
-	StartTime   time.Time `gorm:"column:start_time"`
-	EndTime     time.Time `gorm:"column:end_time"`
-	ClearPoints float64   `gorm:"column:clear_points"`
+	RecordId    int64         `gorm:"column:record_id"`
+	RecordRefId int           `gorm:"column:record_ref_id"`
+	StudentId   int64         `gorm:"column:student_id"`
+	StartTime   time.Time     `gorm:"column:start_time"`
+	EndTime     time.Time     `gorm:"column:end_time"`
+	ClearPoints points.Points `gorm:"column:clear_points"`
 	DeleteRelatedColumns
 	BaseTimeColumns
 }
@@ -31,6 +34,12 @@ type SemesterSettleRecordCond struct {
 	IsDeleted   *bool
 }
 
+type StudentTotalClearPoints struct {
+	StudentId        int64         `gorm:"column:student_id"`
+	TotalClearPoints points.Points `gorm:"column:total_clear_points"`
+	RecordCount      int           `gorm:"column:record_count"`
+}
+
 type UpdateSemesterSettleRecordCond struct {
 	RecordRefId int
 }
@@ -39,7 +48,7 @@ type UpdateSemesterSettleRecordData struct {
 	StudentId   *int64
 	StartTime   *time.Time
 	EndTime     *time.Time
-	ClearPoints *float64
+	ClearPoints *points.Points
 	IsDeleted   *bool
 	DeletedAt   *time.Time
 }
@@ -0,0 +1,116 @@
+package po
+
+import (
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/utils/points"
+	"time"
+)
+
+type SettlementRun struct {
+	RunId      int64                `gorm:"column:run_id"`
+	SemesterId int64                `gorm:"column:semester_id"`
+	StartTime  time.Time            `gorm:"column:start_time"`
+	EndTime    time.Time            `gorm:"column:end_time"`
+	Status     settlement.RunStatus `gorm:"column:status"`
+	TotalCount int                  `gorm:"column:total_count"`
+	Error      string               `gorm:"column:error"`
+	StartedAt  *time.Time           `gorm:"column:started_at"`
+	FinishedAt *time.Time           `gorm:"column:finished_at"`
+	BaseTimeColumns
+}
+
+func (SettlementRun) TableName() string {
+	return "settlement_runs"
+}
+
+type SettlementRunCond struct {
+	RunId           int64
+	SemesterId      int64
+	ExcludeStatuses []settlement.RunStatus // 有帶值時排除這些狀態的結算
+}
+
+type UpdateSettlementRunCond struct {
+	RunId    int64
+	Statuses []settlement.RunStatus // 有帶值時只更新這些狀態的結算
+}
+
+type UpdateSettlementRunData struct {
+	Status     *settlement.RunStatus
+	Error      *string
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+type SettlementRunItem struct {
+	RunId              int64               `gorm:"column:run_id"`
+	StudentId          int64               `gorm:"column:student_id"`
+	KintoneStudentName string              `gorm:"column:kintone_student_name"`
+	ClearPoints        points.Points       `gorm:"column:clear_points"`
+	Step               settlement.ItemStep `gorm:"column:step"`
+	SettleRecordRefId  *int                `gorm:"column:settle_record_ref_id"`
+	RestPointsBefore   points.Points       `gorm:"column:rest_points_before"` // 建立結算清單時的剩餘點數
+	RestPointsAfter    *points.Points      `gorm:"column:rest_points_after"`
+	ReversalId         *int64              `gorm:"column:reversal_id"`
+	BaseTimeColumns
+}
+
+func (SettlementRunItem) TableName() string {
+	return "settlement_run_items"
+}
+
+type SettlementRunItemCond struct {
+	RunId     int64
+	StudentId int64
+	Step      settlement.ItemStep
+}
+
+type UpdateSettlementRunItemCond struct {
+	RunId      int64
+	StudentIds []int64
+	Step       settlement.ItemStep
+}
+
+type UpdateSettlementRunItemData struct {
+	Step              settlement.ItemStep
+	SettleRecordRefId *int
+	RestPointsAfter   *points.Points
+	ReversalId        *int64
+}
+
+type SettlementRunStepCount struct {
+	RunId int64               `gorm:"column:run_id"`
+	Step  settlement.ItemStep `gorm:"column:step"`
+	Count int                 `gorm:"column:count"`
+}
+
+type SettlementReversal struct {
+	ReversalId    int64                     `gorm:"column:reversal_id"`
+	RunId         int64                     `gorm:"column:run_id"`
+	StudentId     *int64                    `gorm:"column:student_id"`
+	Operator      string                    `gorm:"column:operator"`
+	Reason        string                    `gorm:"column:reason"`
+	Status        settlement.ReversalStatus `gorm:"column:status"`
+	ReversedCount int                       `gorm:"column:reversed_count"`
+	Error         string                    `gorm:"column:error"`
+	FinishedAt    *time.Time                `gorm:"column:finished_at"`
+	BaseTimeColumns
+}
+
+func (SettlementReversal) TableName() string {
+	return "settlement_reversals"
+}
+
+type SettlementReversalCond struct {
+	RunId int64
+}
+
+type UpdateSettlementReversalCond struct {
+	ReversalId int64
+}
+
+type UpdateSettlementReversalData struct {
+	Status        settlement.ReversalStatus
+	ReversedCount int
+	Error         string
+	FinishedAt    time.Time
+}
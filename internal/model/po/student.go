@@ -1,6 +1,7 @@
 package po
 
 import (
+	"jaystar/internal/utils/points"
 	"time"
 )
 
@@ -19,7 +20,7 @@ type Student struct {
 
 type StudentWithBalance struct {
 	Student
-	Balance float64 `gorm:"column:rest_points"`
+	Balance points.Points `gorm:"column:rest_points"`
 }
 
 func (Student) TableName() string {
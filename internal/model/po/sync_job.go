@@ -0,0 +1,68 @@
+package po
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"time"
+)
+
+type SyncJob struct {
+	JobId          int64          `gorm:"column:job_id"`
+	JobType        syncjob.Type   `gorm:"column:job_type"`
+	DryRun         bool           `gorm:"column:dry_run"`
+	Cond           string         `gorm:"column:cond"`
+	Status         syncjob.Status `gorm:"column:status"`
+	TotalCount     int            `gorm:"column:total_count"`
+	CreatedCount   int            `gorm:"column:created_count"`
+	UpdatedCount   int            `gorm:"column:updated_count"`
+	DeletedCount   int            `gorm:"column:deleted_count"`
+	SkippedCount   int            `gorm:"column:skipped_count"`
+	FailedCount    int            `gorm:"column:failed_count"`
+	UnchangedCount int            `gorm:"column:unchanged_count"`
+	Failures       string         `gorm:"column:failures"`
+	Diff           *string        `gorm:"column:diff"`
+	Reconciliation *string        `gorm:"column:reconciliation"`
+	Error          string         `gorm:"column:error"`
+	StartedAt      *time.Time     `gorm:"column:started_at"`
+	FinishedAt     *time.Time     `gorm:"column:finished_at"`
+	HeartbeatAt    time.Time      `gorm:"column:heartbeat_at"`
+	BaseTimeColumns
+}
+
+func (SyncJob) TableName() string {
+	return "sync_jobs"
+}
+
+type SyncJobCond struct {
+	JobId    int64
+	JobType  syncjob.Type
+	Status   syncjob.Status
+	Statuses []syncjob.Status
+	// HeartbeatAfter 只查詢該時間之後還有 heartbeat 的同步
+	HeartbeatAfter *time.Time
+}
+
+type UpdateSyncJobCond struct {
+	JobId    int64
+	JobType  syncjob.Type
+	Statuses []syncjob.Status
+	// HeartbeatBefore 只更新該時間之前就沒有 heartbeat 的同步
+	HeartbeatBefore *time.Time
+}
+
+type UpdateSyncJobData struct {
+	Status         *syncjob.Status
+	TotalCount     *int
+	CreatedCount   *int
+	UpdatedCount   *int
+	DeletedCount   *int
+	SkippedCount   *int
+	FailedCount    *int
+	UnchangedCount *int
+	Failures       *string
+	Diff           *string
+	Reconciliation *string
+	Error          *string
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	HeartbeatAt    *time.Time
+}
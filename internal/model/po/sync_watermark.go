@@ -0,0 +1,17 @@
+package po
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"time"
+)
+
+// SyncWatermark 各應用程式增量同步的進度，下次只同步 watermark 之後更新的記錄
+type SyncWatermark struct {
+	JobType   syncjob.Type `gorm:"column:job_type"`
+	Watermark time.Time    `gorm:"column:watermark"`
+	BaseTimeColumns
+}
+
+func (SyncWatermark) TableName() string {
+	return "sync_watermarks"
+}
@@ -5,7 +5,8 @@ type User struct {
 	Account           string `gorm:"column:account"`
 	Password          string `gorm:"column:password"`
 	Status            string `gorm:"column:status;type:user_status"`
-	Level             string `gorm:"column:level;type:user_level"` // unused
+	Level             string `gorm:"column:level;type:user_level"`
+	Role              string `gorm:"column:role;type:admin_role;default:null"` // 只有管理者有角色
 	IsChangedPassword bool   `gorm:"column:is_changed_password"`
 	BaseTimeColumns
 }
@@ -31,5 +32,6 @@ type UserLoginCond struct {
 type UpdateUserData struct {
 	Password          string
 	Status            string
+	Role              string
 	IsChangedPassword *bool
 }
@@ -0,0 +1,59 @@
+package po
+
+import (
+	"jaystar/internal/constant/webhook"
+	"time"
+)
+
+type WebhookEvent struct {
+	EventId        int64          `gorm:"column:event_id"`
+	Source         webhook.Source `gorm:"column:source"`
+	WebhookId      string         `gorm:"column:webhook_id"`
+	WebhookType    string         `gorm:"column:webhook_type"`
+	AppId          string         `gorm:"column:app_id"`
+	RecordId       string         `gorm:"column:record_id"`
+	RecordRevision int            `gorm:"column:record_revision"`
+	Payload        string         `gorm:"column:payload"`
+	Status         webhook.Status `gorm:"column:status"`
+	Attempts       int            `gorm:"column:attempts"`
+	NextAttemptAt  time.Time      `gorm:"column:next_attempt_at"`
+	LockedAt       *time.Time     `gorm:"column:locked_at"`
+	LastError      string         `gorm:"column:last_error"`
+	ProcessedAt    *time.Time     `gorm:"column:processed_at"`
+	BaseTimeColumns
+}
+
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
+
+type WebhookEventCond struct {
+	EventIds []int64
+	Source   webhook.Source
+	RecordId string
+	Status   webhook.Status
+}
+
+type RecordWebhookEventsCond struct {
+	Source        webhook.Source
+	ReceivedSince time.Time
+}
+
+type ClaimWebhookEventsCond struct {
+	Now         time.Time
+	StaleBefore time.Time // 處理中但 locked_at 早於此時間的事件可重新領取
+	Limit       int
+}
+
+type UpdateWebhookEventCond struct {
+	EventIds []int64
+	Status   webhook.Status // 只更新目前為此狀態的事件
+}
+
+type UpdateWebhookEventData struct {
+	Status        *webhook.Status
+	Attempts      *int
+	NextAttemptAt *time.Time
+	LastError     *string
+	ProcessedAt   *time.Time
+}
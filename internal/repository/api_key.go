@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+func ProvideApiKeyRepository() *ApiKeyRepo {
+	return &ApiKeyRepo{}
+}
+
+type ApiKeyRepo struct{}
+
+func (repo *ApiKeyRepo) GetApiKeys(ctx context.Context, db *gorm.DB) ([]*po.ApiKey, error) {
+	apiKeys := make([]*po.ApiKey, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.ApiKey{}).
+		Order("key_id desc").
+		Find(&apiKeys).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return apiKeys, nil
+}
+
+func (repo *ApiKeyRepo) GetApiKey(ctx context.Context, db *gorm.DB, cond *po.ApiKeyCond) (*po.ApiKey, error) {
+	apiKey := &po.ApiKey{}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.ApiKey{}).
+		Scopes(repo.makeApiKeyCond(ctx, cond)).
+		First(apiKey).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return apiKey, nil
+}
+
+func (repo *ApiKeyRepo) AddApiKey(ctx context.Context, db *gorm.DB, data *po.ApiKey) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *ApiKeyRepo) UpdateApiKey(ctx context.Context, db *gorm.DB, cond *po.UpdateApiKeyCond, data *po.UpdateApiKeyData) error {
+	updated := make(map[string]interface{})
+	if data.LastUsedAt != nil {
+		updated["last_used_at"] = *data.LastUsedAt
+	}
+	if data.RevokedAt != nil {
+		updated["revoked_at"] = *data.RevokedAt
+	}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.ApiKey{}).
+		Where("key_id = ?", cond.KeyId).
+		Updates(updated).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *ApiKeyRepo) makeApiKeyCond(ctx context.Context, cond *po.ApiKeyCond) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.KeyId != 0 {
+				db = db.Where("key_id = ?", cond.KeyId)
+			}
+			if cond.KeyHash != "" {
+				db = db.Where("key_hash = ?", cond.KeyHash)
+			}
+		}
+
+		return db
+	}
+}
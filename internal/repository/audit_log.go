@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+func ProvideAuditLogRepository() *AuditLogRepo {
+	return &AuditLogRepo{}
+}
+
+// AuditLogRepo 稽核記錄只能新增，不提供修改與刪除
+type AuditLogRepo struct{}
+
+func (repo *AuditLogRepo) GetAuditLogs(ctx context.Context, db *gorm.DB, cond *po.AuditLogCond, pager *po.Pager) ([]*po.AuditLog, error) {
+	logs := make([]*po.AuditLog, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.AuditLog{}).
+		Scopes(repo.makeAuditLogCond(ctx, cond, pager)).
+		Find(&logs).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return logs, nil
+}
+
+func (repo *AuditLogRepo) GetAuditLogsPager(ctx context.Context, db *gorm.DB, cond *po.AuditLogCond, pager *po.Pager) (*po.PagerResult, error) {
+	var total int64
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.AuditLog{}).
+		Scopes(repo.makeAuditLogCond(ctx, cond, nil)).
+		Count(&total).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return po.NewPagerResult(pager, total), nil
+}
+
+func (repo *AuditLogRepo) AddAuditLog(ctx context.Context, db *gorm.DB, data *po.AuditLog) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *AuditLogRepo) makeAuditLogCond(ctx context.Context, cond *po.AuditLogCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.Actor != "" {
+				db = db.Where("actor = ?", cond.Actor)
+			}
+			if cond.Action != "" {
+				db = db.Where("action = ?", cond.Action)
+			}
+			if cond.TargetType != "" {
+				db = db.Where("target_type = ?", cond.TargetType)
+			}
+			if cond.TargetId != 0 {
+				db = db.Where("target_id = ?", cond.TargetId)
+			}
+			if cond.RequestId != "" {
+				db = db.Where("request_id = ?", cond.RequestId)
+			}
+			if cond.Since != nil {
+				db = db.Where("created_at >= ?", *cond.Since)
+			}
+			if cond.Until != nil {
+				db = db.Where("created_at < ?", *cond.Until)
+			}
+		}
+		if pager != nil {
+			db.Scopes(parsePaging(pager))
+		}
+		return db
+	}
+}
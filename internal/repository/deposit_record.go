@@ -134,7 +134,7 @@ func (repo *DepositRecordRepo) GetStudentTotalDepositPoints(ctx context.Context,
 	tableName := depositRecord.TableName()
 	if err := db.
 		Model(depositRecord).
-		Select(fmt.Sprintf("%s.student_id, sum(%s.deposited_points) as total_deposited_points", tableName, tableName)).
+		Select(fmt.Sprintf("%s.student_id, sum(%s.deposited_points) as total_deposited_points, count(*) as record_count", tableName, tableName)).
 		Scopes(repo.makeDepositRecordCond(ctx, cond, nil)).
 		Group(tableName + ".student_id").Find(&records).Error; err != nil {
 		return nil, handleDBError(err)
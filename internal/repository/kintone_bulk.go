@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/kintoneAPI"
+	"net/http"
+
+	"golang.org/x/xerrors"
+)
+
+type KintoneBulkRepository struct {
+	cfg        config.IConfigEnv
+	kintoneCli *kintoneAPI.KintoneClient
+}
+
+func ProvideKintoneBulkRepository(cfg config.IConfigEnv, kintoneCli *kintoneAPI.KintoneClient) *KintoneBulkRepository {
+	return &KintoneBulkRepository{
+		cfg:        cfg,
+		kintoneCli: kintoneCli,
+	}
+}
+
+// BulkUpdateRecords 以 bulkRequest 一次送出多個應用程式的更新，任一請求失敗時所有請求都不會套用
+func (repo *KintoneBulkRepository) BulkUpdateRecords(ctx context.Context, updates []*dto.KintoneBulkUpdateRecords) (*dto.KintoneBulkRequestRes, error) {
+	if len(updates) > kintone.BulkRequestMaxRequests {
+		return nil, xerrors.Errorf("KintoneBulkRepository BulkUpdateRecords requests: %d: %w", len(updates), errs.KintoneErr.BulkRequestLimitError)
+	}
+
+	req := &dto.KintoneBulkRequestReq{Requests: make([]dto.KintoneBulkRequest, 0, len(updates))}
+	apps := make([]string, 0, len(updates))
+	for _, update := range updates {
+		if len(update.Records) > kintone.BatchUpdateRecordsMaxLimit {
+			return nil, xerrors.Errorf("KintoneBulkRepository BulkUpdateRecords records: %d: %w", len(update.Records), errs.KintoneErr.BulkRequestLimitError)
+		}
+
+		appId, err := kintoneAppId(repo.cfg, update.App)
+		if err != nil {
+			return nil, xerrors.Errorf("KintoneBulkRepository BulkUpdateRecords kintoneAppId: %w", err)
+		}
+		apps = append(apps, appId)
+
+		req.Requests = append(req.Requests, dto.KintoneBulkRequest{
+			Method: http.MethodPut,
+			Api:    kintone.RecordsPath,
+			Payload: dto.KintoneUpdateRecordsPayload{
+				KintoneUpdateAppBase: dto.KintoneUpdateAppBase{App: appId},
+				Records:              update.Records,
+			},
+		})
+	}
+
+	bulkRes := &dto.KintoneBulkRequestRes{}
+	err := repo.kintoneCli.Post(ctx, kintoneAPI.BulkWriteAuth(apps...), kintone.BulkRequestPath, req, bulkRes)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneBulkRepository BulkUpdateRecords kintoneCli.Post: %w", err)
+	}
+
+	return bulkRes, nil
+}
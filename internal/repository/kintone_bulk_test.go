@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+)
+
+func newKintoneBulkRepo(t *testing.T) (*KintoneBulkRepository, *kintoneFake.Server) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
+	logger := logger.ProviderILogger(envConfig)
+	return ProvideKintoneBulkRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger)), server
+}
+
+func renameUpdates(depositRevision int) []*dto.KintoneBulkUpdateRecords {
+	return []*dto.KintoneBulkUpdateRecords{
+		{
+			App: kintone.AppReduceRecord,
+			Records: []dto.KintoneUpdateRecord{{
+				KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: 23441},
+				Record:              dto.UpdateReduceRecordValue{StudentName: dto.NormalField{Value: kintoneFake.StudentARenamed}},
+			}},
+		},
+		{
+			App: kintone.AppDepositRecord,
+			Records: []dto.KintoneUpdateRecord{{
+				KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: 1730, Revision: depositRevision},
+				Record:              dto.UpdateDepositRecordValue{StudentName: dto.NormalField{Value: kintoneFake.StudentARenamed}},
+			}},
+		},
+	}
+}
+
+func TestBulkUpdateRecords(t *testing.T) {
+	repo, server := newKintoneBulkRepo(t)
+
+	res, err := repo.BulkUpdateRecords(context.TODO(), renameUpdates(1))
+	if err != nil {
+		t.Fatalf("BulkUpdateRecords() error = %v", err)
+	}
+	if len(res.Results) != 2 || res.Results[1].Records[0].Id != "1730" || res.Results[1].Records[0].Revision != "2" {
+		t.Errorf("BulkUpdateRecords() results = %+v", res.Results)
+	}
+	if rec, _ := server.GetRecord(kintoneFake.AppId.ReduceRecord, 23441); rec["studentName"].Value != kintoneFake.StudentARenamed {
+		t.Errorf("BulkUpdateRecords() reduce record studentName = %v", rec["studentName"].Value)
+	}
+}
+
+func TestBulkUpdateRecordsRevisionConflict(t *testing.T) {
+	repo, server := newKintoneBulkRepo(t)
+
+	_, err := repo.BulkUpdateRecords(context.TODO(), renameUpdates(2))
+	if !errors.Is(err, errs.KintoneErr.RevisionConflictError) {
+		t.Fatalf("BulkUpdateRecords() error = %v, want RevisionConflictError", err)
+	}
+	if kErr, _ := kintoneAPI.AsKintoneError(err); kErr.BulkRequestIndex != 2 {
+		t.Errorf("BulkUpdateRecords() BulkRequestIndex = %d, want 2", kErr.BulkRequestIndex)
+	}
+	if rec, _ := server.GetRecord(kintoneFake.AppId.ReduceRecord, 23441); rec["studentName"].Value != kintoneFake.StudentA {
+		t.Errorf("BulkUpdateRecords() must not apply any request, reduce record studentName = %v", rec["studentName"].Value)
+	}
+}
+
+func TestBulkUpdateRecordsLimit(t *testing.T) {
+	repo, server := newKintoneBulkRepo(t)
+
+	updates := make([]*dto.KintoneBulkUpdateRecords, 0, kintone.BulkRequestMaxRequests+1)
+	for len(updates) <= kintone.BulkRequestMaxRequests {
+		updates = append(updates, renameUpdates(0)[0])
+	}
+	_, err := repo.BulkUpdateRecords(context.TODO(), updates)
+	if !errors.Is(err, errs.KintoneErr.BulkRequestLimitError) {
+		t.Errorf("BulkUpdateRecords() error = %v, want BulkRequestLimitError", err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("BulkUpdateRecords() must not send request over limit")
+	}
+}
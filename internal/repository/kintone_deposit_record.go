@@ -5,6 +5,7 @@ import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 )
@@ -24,18 +25,14 @@ func ProvideKintoneDepositRecordRepository(cfg config.IConfigEnv, kintoneCli *ki
 func (repo *KintoneDepositRecordRepository) GetKintoneDepositRecords(ctx context.Context, req *dto.DepositRecordReq) (*dto.DepositRecordRes, error) {
 	cfg := repo.cfg.GetKintoneConfig()
 
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("KintoneDepositRecordRepository GetKintoneDepositRecords kintoneAPI.ParseReqStructToMap: %w", err)
-	}
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.DepositRecord,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	poDepositRecordResp := &dto.DepositRecordRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, poDepositRecordResp)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.DepositRecord), kintone.RecordsPath, query, poDepositRecordResp)
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneDepositRecordRepository GetKintoneDepositRecords kintoneCli.Get: %w", err)
 	}
@@ -57,10 +54,26 @@ func (repo *KintoneDepositRecordRepository) UpdateKintoneDepositRecords(ctx cont
 	}
 
 	poDepositRecordResp := &dto.UpdateDepositRecordsRes{}
-	err := repo.kintoneCli.Put(ctx, cfg.AdminUserAuthorization, kintone.RecordsPath, body, poDepositRecordResp)
+	err := repo.kintoneCli.Put(ctx, kintoneAPI.WriteAuth(cfg.AppId.DepositRecord), kintone.RecordsPath, body, poDepositRecordResp, kintoneAPI.WithIdempotent(!dto.HasRevision(req.Records)))
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneDepositRecordRepository UpdateKintoneDepositRecords kintoneCli.Put: %w", err)
 	}
 
 	return poDepositRecordResp, nil
 }
+
+func (repo *KintoneDepositRecordRepository) GetKintoneDepositRecordsCursor(ctx context.Context, req *dto.DepositRecordReq) (interfaces.IKintoneRecordCursor[dto.KintoneDepositRecordRecordDto], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.DepositRecord,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.KintoneDepositRecordRecordDto](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.DepositRecord), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneDepositRecordRepository GetKintoneDepositRecordsCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
+}
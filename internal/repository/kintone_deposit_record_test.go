@@ -2,18 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/SeanZhenggg/go-utils/logger"
-	"jaystar/internal/config"
+	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/errs"
 	"jaystar/internal/utils/kintoneAPI"
-	"os"
+	"jaystar/internal/utils/kintoneFake"
+	"net/http"
 	"slices"
 	"testing"
 )
 
-func newKintoneDepositRecordRepo() *KintoneDepositRecordRepository {
-	os.Setenv("APP_ENV", "dev")
-	envConfig := config.ProviderIConfigEnv()
+func newKintoneDepositRecordRepo(t *testing.T) *KintoneDepositRecordRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
 	logger := logger.ProviderILogger(envConfig)
 	return ProvideKintoneDepositRecordRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
 }
@@ -40,7 +44,7 @@ func TestUpdateKintoneDepositRecords(t *testing.T) {
 						},
 						Record: dto.UpdateDepositRecordValue{
 							StudentName: dto.NormalField{
-								Value: "沈品言/0975296250",
+								Value: kintoneFake.StudentARenamed,
 							},
 						},
 					},
@@ -50,7 +54,7 @@ func TestUpdateKintoneDepositRecords(t *testing.T) {
 						},
 						Record: dto.UpdateDepositRecordValue{
 							StudentName: dto.NormalField{
-								Value: "沈品言/0975296250",
+								Value: kintoneFake.StudentARenamed,
 							},
 						},
 					},
@@ -60,7 +64,7 @@ func TestUpdateKintoneDepositRecords(t *testing.T) {
 		updateIds: []string{"1730", "1929"},
 	}}
 
-	repo := newKintoneDepositRecordRepo()
+	repo := newKintoneDepositRecordRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.UpdateKintoneDepositRecords(tt.args.ctx, tt.args.cond)
@@ -78,3 +82,66 @@ func TestUpdateKintoneDepositRecords(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateKintoneDepositRecordsRevisionConflict(t *testing.T) {
+	repo := newKintoneDepositRecordRepo(t)
+
+	req := &dto.UpdateDepositRecordsReq{
+		Records: []dto.UpdateDepositRecord{{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: 1730, Revision: 1},
+			Record:              dto.UpdateDepositRecordValue{StudentName: dto.NormalField{Value: kintoneFake.StudentARenamed}},
+		}},
+	}
+	if _, err := repo.UpdateKintoneDepositRecords(context.TODO(), req); err != nil {
+		t.Fatalf("UpdateKintoneDepositRecords() error = %v", err)
+	}
+
+	// 同一個 revision 再更新一次，記錄已被修改過
+	_, err := repo.UpdateKintoneDepositRecords(context.TODO(), req)
+	if !errors.Is(err, errs.KintoneErr.RevisionConflictError) {
+		t.Errorf("UpdateKintoneDepositRecords() error = %v, want RevisionConflictError", err)
+	}
+}
+
+func TestUpdateKintoneDepositRecordsRetry(t *testing.T) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
+	repo := ProvideKintoneDepositRecordRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger.ProviderILogger(envConfig)))
+
+	countPuts := func() int {
+		count := 0
+		for _, req := range server.Requests() {
+			if req.Method == http.MethodPut {
+				count++
+			}
+		}
+		return count
+	}
+	update := func(revision int) error {
+		server.InjectFault(kintoneFake.Fault{Method: http.MethodPut, Path: kintone.RecordsPath, StatusCode: http.StatusServiceUnavailable})
+		_, err := repo.UpdateKintoneDepositRecords(context.TODO(), &dto.UpdateDepositRecordsReq{
+			Records: []dto.UpdateDepositRecord{{
+				KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: 1730, Revision: revision},
+				Record:              dto.UpdateDepositRecordValue{StudentName: dto.NormalField{Value: kintoneFake.StudentARenamed}},
+			}},
+		})
+		return err
+	}
+
+	// 沒有 revision 的更新可以重送
+	if err := update(0); err != nil {
+		t.Fatalf("UpdateKintoneDepositRecords() error = %v", err)
+	}
+	if got := countPuts(); got != 2 {
+		t.Errorf("UpdateKintoneDepositRecords() put requests = %d, want 2", got)
+	}
+
+	// 帶 revision 的更新不重送，避免已寫入的請求重送後被誤判為衝突
+	if err := update(2); err == nil {
+		t.Fatal("UpdateKintoneDepositRecords() error = nil, want error")
+	}
+	if got := countPuts(); got != 3 {
+		t.Errorf("UpdateKintoneDepositRecords() put requests = %d, want 3", got)
+	}
+}
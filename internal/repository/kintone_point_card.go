@@ -5,6 +5,7 @@ import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 )
@@ -24,18 +25,14 @@ func ProvideKintonePointCardRepository(cfg config.IConfigEnv, kintoneCli *kinton
 func (repo *KintonePointCardRepository) GetPointCards(ctx context.Context, req *dto.GetPointCardReq) (*dto.GetPointCardRes, error) {
 	cfg := repo.cfg.GetKintoneConfig()
 
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("kintoneAPI.ParseReqStructToMap: %w", err)
-	}
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.PointCard,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	poPointCardResp := &dto.GetPointCardRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, poPointCardResp)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.PointCard), kintone.RecordsPath, query, poPointCardResp)
 	if err != nil {
 		return nil, xerrors.Errorf("kintoneCli.Get: %w", err)
 	}
@@ -57,7 +54,7 @@ func (repo *KintonePointCardRepository) UpdatePointCard(ctx context.Context, req
 	}
 
 	poUpdatePointCardRes := &dto.UpdatePointCardRes{}
-	err := repo.kintoneCli.Put(ctx, cfg.AdminUserAuthorization, kintone.RecordPath, body, poUpdatePointCardRes)
+	err := repo.kintoneCli.Put(ctx, kintoneAPI.WriteAuth(cfg.AppId.PointCard), kintone.RecordPath, body, poUpdatePointCardRes, kintoneAPI.WithIdempotent(req.Revision == 0))
 	if err != nil {
 		return nil, xerrors.Errorf("KintonePointCardRepository UpdatePointCard kintoneCli.Put: %w", err)
 	}
@@ -65,7 +62,7 @@ func (repo *KintonePointCardRepository) UpdatePointCard(ctx context.Context, req
 	return poUpdatePointCardRes, nil
 }
 
-func (repo *KintonePointCardRepository) UpdatePointCards(ctx context.Context, req *dto.UpdatePointCardsReq) (*dto.UpdatePointCardRes, error) {
+func (repo *KintonePointCardRepository) UpdatePointCards(ctx context.Context, req *dto.UpdatePointCardsReq) (*dto.UpdatePointCardsRes, error) {
 	cfg := repo.cfg.GetKintoneConfig()
 
 	body := struct {
@@ -78,11 +75,27 @@ func (repo *KintonePointCardRepository) UpdatePointCards(ctx context.Context, re
 		UpdatePointCardsReq: req,
 	}
 
-	poUpdatePointCardRes := &dto.UpdatePointCardRes{}
-	err := repo.kintoneCli.Put(ctx, cfg.AdminUserAuthorization, kintone.RecordsPath, body, poUpdatePointCardRes)
+	poUpdatePointCardsRes := &dto.UpdatePointCardsRes{}
+	err := repo.kintoneCli.Put(ctx, kintoneAPI.WriteAuth(cfg.AppId.PointCard), kintone.RecordsPath, body, poUpdatePointCardsRes, kintoneAPI.WithIdempotent(!dto.HasRevision(req.Records)))
 	if err != nil {
 		return nil, xerrors.Errorf("kintoneCli.Put: %w", err)
 	}
 
-	return poUpdatePointCardRes, nil
+	return poUpdatePointCardsRes, nil
+}
+
+func (repo *KintonePointCardRepository) GetPointCardsCursor(ctx context.Context, req *dto.GetPointCardReq) (interfaces.IKintoneRecordCursor[dto.PointCardRecord], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.PointCard,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.PointCardRecord](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.PointCard), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintonePointCardRepository GetPointCardsCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
 }
@@ -3,16 +3,16 @@ package repository
 import (
 	"context"
 	"github.com/SeanZhenggg/go-utils/logger"
-	"jaystar/internal/config"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
-	"os"
+	"jaystar/internal/utils/kintoneFake"
 	"testing"
 )
 
-func newKintonePointCardRepo() *KintonePointCardRepository {
-	os.Setenv("APP_ENV", "dev")
-	envConfig := config.ProviderIConfigEnv()
+func newKintonePointCardRepo(t *testing.T) *KintonePointCardRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
 	logger := logger.ProviderILogger(envConfig)
 	return ProvideKintonePointCardRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
 }
@@ -36,14 +36,14 @@ func TestUpdatePointCard(t *testing.T) {
 						Id: 1439,
 					},
 					Record: dto.UpdatePointCardRecord{
-						StudentName: dto.NormalField{Value: "沈品言/0975296250"},
+						StudentName: dto.NormalField{Value: kintoneFake.StudentARenamed},
 					},
 				},
 			},
 			wantErr: false,
 		},
 	}
-	repo := newKintonePointCardRepo()
+	repo := newKintonePointCardRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.UpdatePointCard(tt.args.ctx, tt.args.cond)
@@ -78,7 +78,7 @@ func TestUpdatePointCards(t *testing.T) {
 						UpdateKey: struct {
 							Field string `json:"field"`
 							Value string `json:"value"`
-						}{Field: "studentName", Value: "小鄭/0937054388"},
+						}{Field: "studentName", Value: kintoneFake.StudentB},
 						Record: dto.UpdatePointCardsRecordValue{
 							ClearPoints: dto.NormalField{Value: "2"},
 						},
@@ -89,18 +89,40 @@ func TestUpdatePointCards(t *testing.T) {
 			wantErr: false,
 		},
 	}
-	repo := newKintonePointCardRepo()
+	repo := newKintonePointCardRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.UpdatePointCards(tt.args.ctx, tt.args.cond)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("UpdatePointCard() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("UpdatePointCards() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if len(resp.Revision) == 0 {
-				t.Errorf("UpdatePointCard() error revision response = %v", resp.Revision)
+			if len(resp.Records) != len(tt.args.cond.Records) || len(resp.Records[0].Revision) == 0 {
+				t.Errorf("UpdatePointCards() error records response = %v", resp.Records)
 			}
 		})
 	}
 }
+
+func TestGetPointCardsCursor(t *testing.T) {
+	repo := newKintonePointCardRepo(t)
+
+	cursor, err := repo.GetPointCardsCursor(context.TODO(), &dto.GetPointCardReq{})
+	if err != nil {
+		t.Fatalf("GetPointCardsCursor() error = %v", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	count := 0
+	for cursor.Next(context.TODO()) {
+		count += len(cursor.Records())
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("GetPointCardsCursor() cursor error = %v", err)
+	}
+
+	if count != cursor.TotalCount() || count == 0 {
+		t.Errorf("GetPointCardsCursor() count = %v, totalCount %v", count, cursor.TotalCount())
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/xerrors"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/kintoneAPI"
+)
+
+type KintoneRecordRepository struct {
+	cfg        config.IConfigEnv
+	kintoneCli *kintoneAPI.KintoneClient
+}
+
+func ProvideKintoneRecordRepository(cfg config.IConfigEnv, kintoneCli *kintoneAPI.KintoneClient) *KintoneRecordRepository {
+	return &KintoneRecordRepository{
+		cfg:        cfg,
+		kintoneCli: kintoneCli,
+	}
+}
+
+// GetAllRecordIds 取得應用程式內所有記錄的 $id，增量同步時用來判斷已刪除的記錄
+func (repo *KintoneRecordRepository) GetAllRecordIds(ctx context.Context, app kintone.App) (ids map[int]struct{}, err error) {
+	appId, err := kintoneAppId(repo.cfg, app)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneRecordRepository GetAllRecordIds kintoneAppId: %w", err)
+	}
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:    appId,
+		Fields: []string{"$id"},
+		Size:   kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.KintoneRecordIdDto](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(appId), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneRecordRepository GetAllRecordIds kintoneAPI.NewRecordCursor: %w", err)
+	}
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("KintoneRecordRepository GetAllRecordIds cursor.Close: %w", closeErr)
+		}
+	}()
+
+	ids = make(map[int]struct{}, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			id, err := record.Id.ToId()
+			if err != nil {
+				return nil, xerrors.Errorf("KintoneRecordRepository GetAllRecordIds ToId: %w", err)
+			}
+			ids[id] = struct{}{}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("KintoneRecordRepository GetAllRecordIds cursor.Next: %w", err)
+	}
+
+	return ids, nil
+}
+
+func kintoneAppId(cfg config.IConfigEnv, app kintone.App) (string, error) {
+	appId := cfg.GetKintoneConfig().AppId
+	switch app {
+	case kintone.AppStudentInfo:
+		return appId.StudentInfo, nil
+	case kintone.AppPointCard:
+		return appId.PointCard, nil
+	case kintone.AppScheduleRecord:
+		return appId.ScheduleRecord, nil
+	case kintone.AppDepositRecord:
+		return appId.DepositRecord, nil
+	case kintone.AppReduceRecord:
+		return appId.ReduceRecord, nil
+	case kintone.AppSemesterSettleRecord:
+		return appId.SemesterSettleRecord, nil
+	}
+	return "", fmt.Errorf("unknown kintone app: %d", app)
+}
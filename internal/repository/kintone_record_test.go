@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"testing"
+	"time"
+)
+
+func TestGetAllRecordIds(t *testing.T) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
+	kintoneCli := kintoneAPI.ProvideKintoneClient(envConfig, logger.ProviderILogger(envConfig))
+	repo := ProvideKintoneRecordRepository(envConfig, kintoneCli)
+	studentRepo := ProvideKintoneStudentRepository(envConfig, kintoneCli)
+
+	ids, err := repo.GetAllRecordIds(context.TODO(), kintone.AppStudentInfo)
+	if err != nil {
+		t.Fatalf("GetAllRecordIds() error = %v", err)
+	}
+
+	res, err := studentRepo.GetKintoneStudents(context.TODO(), &dto.StudentReq{Limit: 500})
+	if err != nil {
+		t.Fatalf("GetKintoneStudents() error = %v", err)
+	}
+	if len(ids) == 0 || len(ids) != len(res.Records) {
+		t.Errorf("GetAllRecordIds() ids = %v, records %v", len(ids), len(res.Records))
+	}
+	for _, record := range res.Records {
+		id, _ := record.Id.ToId()
+		if _, found := ids[id]; !found {
+			t.Errorf("GetAllRecordIds() missing id %v", id)
+		}
+	}
+
+	// 增量同步只取得 updatedAt 之後更新的記錄
+	res, err = studentRepo.GetKintoneStudents(context.TODO(), &dto.StudentReq{UpdatedSince: time.Now().Add(time.Hour), Limit: 500})
+	if err != nil {
+		t.Fatalf("GetKintoneStudents() error = %v", err)
+	}
+	if len(res.Records) != 0 {
+		t.Errorf("GetKintoneStudents() UpdatedSince records = %v, want 0", len(res.Records))
+	}
+
+	if _, err := repo.GetAllRecordIds(context.TODO(), kintone.App(-1)); err == nil {
+		t.Errorf("GetAllRecordIds() unknown app want error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 
@@ -25,18 +26,14 @@ func ProvideKintoneReduceRecordRepository(cfg config.IConfigEnv, kintoneCli *kin
 func (repo *KintoneReduceRecordRepository) GetKintoneReduceRecords(ctx context.Context, req *dto.ReduceRecordReq) (*dto.ReduceRecordRes, error) {
 	cfg := repo.cfg.GetKintoneConfig()
 
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("KintoneReduceRecordRepository GetKintoneReduceRecords kintoneAPI.ParseReqStructToMap: %w", err)
-	}
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.ReduceRecord,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	boReduceRecordRes := &dto.ReduceRecordRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, &boReduceRecordRes)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.ReduceRecord), kintone.RecordsPath, query, &boReduceRecordRes)
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneReduceRecordRepository GetKintoneReduceRecords kintoneCli.Get: %w", err)
 	}
@@ -58,10 +55,26 @@ func (repo *KintoneReduceRecordRepository) UpdateKintoneReduceRecords(ctx contex
 	}
 
 	poReduceRecordResp := &dto.UpdateReduceRecordsRes{}
-	err := repo.kintoneCli.Put(ctx, cfg.AdminUserAuthorization, kintone.RecordsPath, body, poReduceRecordResp)
+	err := repo.kintoneCli.Put(ctx, kintoneAPI.WriteAuth(cfg.AppId.ReduceRecord), kintone.RecordsPath, body, poReduceRecordResp, kintoneAPI.WithIdempotent(!dto.HasRevision(req.Records)))
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneReduceRecordRepository UpdateKintoneReduceRecords kintoneCli.Put: %w", err)
 	}
 
 	return poReduceRecordResp, nil
 }
+
+func (repo *KintoneReduceRecordRepository) GetKintoneReduceRecordsCursor(ctx context.Context, req *dto.ReduceRecordReq) (interfaces.IKintoneRecordCursor[dto.ReduceRecordRecord], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.ReduceRecord,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.ReduceRecordRecord](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.ReduceRecord), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneReduceRecordRepository GetKintoneReduceRecordsCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
+}
@@ -2,19 +2,19 @@ package repository
 
 import (
 	"context"
-	"jaystar/internal/config"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
-	"os"
+	"jaystar/internal/utils/kintoneFake"
 	"slices"
 	"testing"
 
 	"github.com/SeanZhenggg/go-utils/logger"
 )
 
-func newKintoneReduceRecordRepo() *KintoneReduceRecordRepository {
-	os.Setenv("APP_ENV", "dev")
-	envConfig := config.ProviderIConfigEnv()
+func newKintoneReduceRecordRepo(t *testing.T) *KintoneReduceRecordRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
 	logger := logger.ProviderILogger(envConfig)
 	return ProvideKintoneReduceRecordRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
 }
@@ -41,7 +41,7 @@ func TestUpdateKintoneReduceRecords(t *testing.T) {
 						},
 						Record: dto.UpdateReduceRecordValue{
 							StudentName: dto.NormalField{
-								Value: "沈品言/0975296255",
+								Value: kintoneFake.StudentA,
 							},
 						},
 					},
@@ -51,7 +51,7 @@ func TestUpdateKintoneReduceRecords(t *testing.T) {
 						},
 						Record: dto.UpdateReduceRecordValue{
 							StudentName: dto.NormalField{
-								Value: "沈品言/0975296255",
+								Value: kintoneFake.StudentA,
 							},
 						},
 					},
@@ -61,7 +61,7 @@ func TestUpdateKintoneReduceRecords(t *testing.T) {
 		updateIds: []string{"23612", "23448"},
 	}}
 
-	repo := newKintoneReduceRecordRepo()
+	repo := newKintoneReduceRecordRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.UpdateKintoneReduceRecords(tt.args.ctx, tt.args.cond)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 
@@ -23,20 +24,15 @@ func ProvideKintoneScheduleRepository(cfg config.IConfigEnv, kintoneCli *kintone
 }
 
 func (repo *KintoneScheduleRepository) GetKintoneSchedules(ctx context.Context, req *dto.ScheduleReq) (*dto.ScheduleRes, error) {
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("KintoneScheduleRepository GetKintoneSchedules kintoneAPI.ParseReqStructToMap: %w", err)
-	}
-
 	cfg := repo.cfg.GetKintoneConfig()
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.ScheduleRecord,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	boScheduleRes := &dto.ScheduleRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, &boScheduleRes)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.ScheduleRecord), kintone.RecordsPath, query, &boScheduleRes)
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneScheduleRepository GetKintoneSchedules kintoneCli.Get: %w", err)
 	}
@@ -58,10 +54,26 @@ func (repo *KintoneScheduleRepository) UpdateKintoneSchedules(ctx context.Contex
 	}
 
 	poScheduleResp := &dto.UpdateSchedulesRes{}
-	err := repo.kintoneCli.Put(ctx, cfg.AdminUserAuthorization, kintone.RecordsPath, body, poScheduleResp)
+	err := repo.kintoneCli.Put(ctx, kintoneAPI.WriteAuth(cfg.AppId.ScheduleRecord), kintone.RecordsPath, body, poScheduleResp, kintoneAPI.WithIdempotent(!dto.HasRevision(req.Records)))
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneScheduleRepository UpdateKintoneSchedules kintoneCli.Put: %w", err)
 	}
 
 	return poScheduleResp, nil
 }
+
+func (repo *KintoneScheduleRepository) GetKintoneSchedulesCursor(ctx context.Context, req *dto.ScheduleReq) (interfaces.IKintoneRecordCursor[dto.ScheduleRecord], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.ScheduleRecord,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.ScheduleRecord](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.ScheduleRecord), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneScheduleRepository GetKintoneSchedulesCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
+}
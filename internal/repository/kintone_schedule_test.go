@@ -2,33 +2,34 @@ package repository
 
 import (
 	"context"
-	"jaystar/internal/config"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
-	"log"
-	"os"
+	"jaystar/internal/utils/kintoneFake"
 	"slices"
 	"testing"
 
 	"github.com/SeanZhenggg/go-utils/logger"
 )
 
-func newKintoneScheduleRepo() *KintoneScheduleRepository {
-	os.Setenv("APP_ENV", "dev")
-	envConfig := config.ProviderIConfigEnv()
+func newKintoneScheduleRepo(t *testing.T) *KintoneScheduleRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
 	logger := logger.ProviderILogger(envConfig)
 	return ProvideKintoneScheduleRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
 }
 
 func TestGetKintoneSchedules(t *testing.T) {
-	repo := newKintoneScheduleRepo()
+	repo := newKintoneScheduleRepo(t)
 
-	schedules, err := repo.GetKintoneSchedules(context.TODO(), &dto.ScheduleReq{StudentName: "沈品言/0975296250"})
+	schedules, err := repo.GetKintoneSchedules(context.TODO(), &dto.ScheduleReq{StudentNames: []string{kintoneFake.StudentA}})
 	if err != nil {
-		panic(err)
+		t.Fatalf("GetKintoneSchedules() error = %v", err)
 	}
 
-	log.Println(schedules)
+	if len(schedules.Records) != 3 {
+		t.Errorf("GetKintoneSchedules() records length = %v, want 3", len(schedules.Records))
+	}
 }
 
 func TestUpdateKintoneSchedules(t *testing.T) {
@@ -60,7 +61,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "楊鈞傑/0918236165",
+													Value: kintoneFake.StudentC,
 												},
 											},
 										},
@@ -70,7 +71,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "沈品言/0975296250",
+													Value: kintoneFake.StudentARenamed,
 												},
 											},
 										},
@@ -92,7 +93,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "劉安寶/0930518181",
+													Value: kintoneFake.StudentD,
 												},
 											},
 										},
@@ -102,7 +103,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "黃貝安/0987148407",
+													Value: kintoneFake.StudentE,
 												},
 											},
 										},
@@ -112,7 +113,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "温宥翔.宥瑄/0910301963",
+													Value: kintoneFake.StudentF,
 												},
 											},
 										},
@@ -122,7 +123,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "沈品言/0975296250",
+													Value: kintoneFake.StudentARenamed,
 												},
 											},
 										},
@@ -144,7 +145,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "楊鈞傑/0918236165",
+													Value: kintoneFake.StudentC,
 												},
 											},
 										},
@@ -154,7 +155,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 										Value: dto.AttendanceRecordValue{
 											StudentName: dto.StringField{
 												NormalField: dto.NormalField{
-													Value: "沈品言/0975296250",
+													Value: kintoneFake.StudentARenamed,
 												},
 											},
 										},
@@ -169,7 +170,7 @@ func TestUpdateKintoneSchedules(t *testing.T) {
 		updateIds: []string{"7460", "7452", "7369"},
 	}}
 
-	repo := newKintoneScheduleRepo()
+	repo := newKintoneScheduleRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.UpdateKintoneSchedules(tt.args.ctx, tt.args.cond)
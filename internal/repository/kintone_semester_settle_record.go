@@ -5,6 +5,7 @@ import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 )
@@ -22,20 +23,15 @@ func ProvideKintoneSemesterSettleRecordRepository(cfg config.IConfigEnv, kintone
 }
 
 func (repo *KintoneSemesterSettleRecordRepository) GetKintoneSemesterSettleRecords(ctx context.Context, req *dto.SemesterSettleRecordReq) (*dto.SemesterSettleRecordRes, error) {
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("KintoneScheduleRepository GetKintoneSchedules kintoneAPI.ParseReqStructToMap: %w", err)
-	}
-
 	cfg := repo.cfg.GetKintoneConfig()
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.SemesterSettleRecord,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	boScheduleRes := &dto.SemesterSettleRecordRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, &boScheduleRes)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.SemesterSettleRecord), kintone.RecordsPath, query, &boScheduleRes)
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneScheduleRepository GetKintoneSchedules kintoneCli.Get: %w", err)
 	}
@@ -57,10 +53,47 @@ func (repo *KintoneSemesterSettleRecordRepository) InsertKintoneSemesterSettleRe
 	}
 
 	insertSemesterSettleRecordsResResp := &dto.InsertSemesterSettleRecordsRes{}
-	err := repo.kintoneCli.Post(ctx, cfg.AdminUserAuthorization, kintone.RecordsPath, body, insertSemesterSettleRecordsResResp)
+	err := repo.kintoneCli.Post(ctx, kintoneAPI.WriteAuth(cfg.AppId.SemesterSettleRecord), kintone.RecordsPath, body, insertSemesterSettleRecordsResResp)
 	if err != nil {
 		return nil, xerrors.Errorf("kintoneCli.Post: %w", err)
 	}
 
 	return insertSemesterSettleRecordsResResp, nil
 }
+
+func (repo *KintoneSemesterSettleRecordRepository) DeleteKintoneSemesterSettleRecords(ctx context.Context, req *dto.DeleteSemesterSettleRecordsReq) error {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	body := struct {
+		dto.KintoneUpdateAppBase
+		*dto.DeleteSemesterSettleRecordsReq
+	}{
+		KintoneUpdateAppBase: dto.KintoneUpdateAppBase{
+			App: cfg.AppId.SemesterSettleRecord,
+		},
+		DeleteSemesterSettleRecordsReq: req,
+	}
+
+	err := repo.kintoneCli.Delete(ctx, kintoneAPI.WriteAuth(cfg.AppId.SemesterSettleRecord), kintone.RecordsPath, body, &struct{}{})
+	if err != nil {
+		return xerrors.Errorf("kintoneCli.Delete: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *KintoneSemesterSettleRecordRepository) GetKintoneSemesterSettleRecordsCursor(ctx context.Context, req *dto.SemesterSettleRecordReq) (interfaces.IKintoneRecordCursor[dto.SemesterSettleRecord], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.SemesterSettleRecord,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.SemesterSettleRecord](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.SemesterSettleRecord), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneSemesterSettleRecordRepository GetKintoneSemesterSettleRecordsCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
+}
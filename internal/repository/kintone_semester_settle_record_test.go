@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"testing"
+)
+
+func newKintoneSemesterSettleRecordRepo(t *testing.T) *KintoneSemesterSettleRecordRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
+	logger := logger.ProviderILogger(envConfig)
+	return ProvideKintoneSemesterSettleRecordRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
+}
+
+func TestDeleteKintoneSemesterSettleRecords(t *testing.T) {
+	repo := newKintoneSemesterSettleRecordRepo(t)
+	ctx := context.TODO()
+
+	if err := repo.DeleteKintoneSemesterSettleRecords(ctx, &dto.DeleteSemesterSettleRecordsReq{Ids: []int{301}}); err != nil {
+		t.Fatalf("DeleteKintoneSemesterSettleRecords() error = %v", err)
+	}
+
+	res, err := repo.GetKintoneSemesterSettleRecords(ctx, &dto.SemesterSettleRecordReq{Limit: 100})
+	if err != nil {
+		t.Fatalf("GetKintoneSemesterSettleRecords() error = %v", err)
+	}
+	for _, record := range res.Records {
+		if record.Id.Value == "301" {
+			t.Errorf("GetKintoneSemesterSettleRecords() record 301 was not deleted")
+		}
+	}
+
+	// 已刪除的記錄再刪除一次會失敗
+	if err := repo.DeleteKintoneSemesterSettleRecords(ctx, &dto.DeleteSemesterSettleRecordsReq{Ids: []int{301}}); err == nil {
+		t.Errorf("DeleteKintoneSemesterSettleRecords() deleting a missing record should fail")
+	}
+}
+
+func TestGetKintoneSemesterSettleRecordsCursor(t *testing.T) {
+	repo := newKintoneSemesterSettleRecordRepo(t)
+
+	cursor, err := repo.GetKintoneSemesterSettleRecordsCursor(context.TODO(), &dto.SemesterSettleRecordReq{})
+	if err != nil {
+		t.Fatalf("GetKintoneSemesterSettleRecordsCursor() error = %v", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	count := 0
+	for cursor.Next(context.TODO()) {
+		count += len(cursor.Records())
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("GetKintoneSemesterSettleRecordsCursor() cursor error = %v", err)
+	}
+
+	if count != cursor.TotalCount() || count == 0 {
+		t.Errorf("GetKintoneSemesterSettleRecordsCursor() count = %v, totalCount %v", count, cursor.TotalCount())
+	}
+}
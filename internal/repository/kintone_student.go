@@ -5,6 +5,7 @@ import (
 	"golang.org/x/xerrors"
 	"jaystar/internal/config"
 	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
 )
@@ -24,22 +25,33 @@ func ProvideKintoneStudentRepository(cfg config.IConfigEnv, kintoneCli *kintoneA
 func (repo *KintoneStudentRepository) GetKintoneStudents(ctx context.Context, req *dto.StudentReq) (*dto.StudentRes, error) {
 	cfg := repo.cfg.GetKintoneConfig()
 
-	m, err := kintoneAPI.ParseReqStructToMap(req)
-	if err != nil {
-		return nil, xerrors.Errorf("KintoneStudentRepository GetKintoneStudent kintoneAPI.ParseReqStructToMap: %w", err)
-	}
-
 	query := map[string]string{
 		kintone.QueryApp:   cfg.AppId.StudentInfo,
 		kintone.TotalCount: "true",
-		kintone.QueryQuery: kintoneAPI.ConvMapToQueryStringByAnd(m),
+		kintone.QueryQuery: req.ToQuery().String(),
 	}
 
 	poStudentRes := &dto.StudentRes{}
-	err = repo.kintoneCli.Get(ctx, cfg.CommonUserAuthorization, kintone.RecordsPath, query, poStudentRes)
+	err := repo.kintoneCli.Get(ctx, kintoneAPI.ReadAuth(cfg.AppId.StudentInfo), kintone.RecordsPath, query, poStudentRes)
 	if err != nil {
 		return nil, xerrors.Errorf("KintoneStudentRepository GetKintoneStudents kintoneCli.Get: %w", err)
 	}
 
 	return poStudentRes, nil
 }
+
+func (repo *KintoneStudentRepository) GetKintoneStudentsCursor(ctx context.Context, req *dto.StudentReq) (interfaces.IKintoneRecordCursor[dto.StudentRecord], error) {
+	cfg := repo.cfg.GetKintoneConfig()
+
+	cursorReq := &dto.KintoneCreateCursorReq{
+		App:   cfg.AppId.StudentInfo,
+		Query: req.ToQuery().StringWithoutPaging(),
+		Size:  kintone.CursorMaxSize,
+	}
+	cursor, err := kintoneAPI.NewRecordCursor[dto.StudentRecord](ctx, repo.kintoneCli, kintoneAPI.ReadAuth(cfg.AppId.StudentInfo), cursorReq)
+	if err != nil {
+		return nil, xerrors.Errorf("KintoneStudentRepository GetKintoneStudentsCursor kintoneAPI.NewRecordCursor: %w", err)
+	}
+
+	return cursor, nil
+}
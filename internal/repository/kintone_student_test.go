@@ -3,16 +3,16 @@ package repository
 import (
 	"context"
 	"github.com/SeanZhenggg/go-utils/logger"
-	"jaystar/internal/config"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/kintoneAPI"
-	"os"
+	"jaystar/internal/utils/kintoneFake"
 	"testing"
 )
 
-func newKintoneStudentRepo() *KintoneStudentRepository {
-	os.Setenv("APP_ENV", "dev")
-	envConfig := config.ProviderIConfigEnv()
+func newKintoneStudentRepo(t *testing.T) *KintoneStudentRepository {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	envConfig := server.ConfigEnv()
 	logger := logger.ProviderILogger(envConfig)
 	return ProvideKintoneStudentRepository(envConfig, kintoneAPI.ProvideKintoneClient(envConfig, logger))
 }
@@ -29,12 +29,12 @@ func TestGetKintoneStudents(t *testing.T) {
 		wantErr    bool
 	}{{
 		name:       "normal get",
-		args:       args{ctx: context.TODO(), cond: &dto.StudentReq{ParentPhone: "0975296255", Limit: 1, Offset: 0}},
+		args:       args{ctx: context.TODO(), cond: &dto.StudentReq{ParentPhone: kintoneFake.StudentAPhone, Limit: 1, Offset: 0}},
 		wantLength: 1,
 		wantErr:    false,
 	}}
 
-	repo := newKintoneStudentRepo()
+	repo := newKintoneStudentRepo(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			resp, err := repo.GetKintoneStudents(tt.args.ctx, tt.args.cond)
@@ -51,3 +51,25 @@ func TestGetKintoneStudents(t *testing.T) {
 		})
 	}
 }
+
+func TestGetKintoneStudentsCursor(t *testing.T) {
+	repo := newKintoneStudentRepo(t)
+
+	cursor, err := repo.GetKintoneStudentsCursor(context.TODO(), &dto.StudentReq{})
+	if err != nil {
+		t.Fatalf("GetKintoneStudentsCursor() error = %v", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	count := 0
+	for cursor.Next(context.TODO()) {
+		count += len(cursor.Records())
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("GetKintoneStudentsCursor() cursor error = %v", err)
+	}
+
+	if count != cursor.TotalCount() || count == 0 {
+		t.Errorf("GetKintoneStudentsCursor() count = %v, totalCount %v", count, cursor.TotalCount())
+	}
+}
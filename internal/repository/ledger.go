@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"jaystar/internal/constant/ledger"
+	"jaystar/internal/model/po"
+)
+
+func ProvideLedgerRepository() *LedgerRepo {
+	return &LedgerRepo{}
+}
+
+type LedgerRepo struct{}
+
+// GetEntries 依發生時間排序未刪除的記錄，同一時間依儲值、扣點、結算的順序
+// 儲值記錄以 charging_date、扣點記錄以 class_time、結算記錄以 end_time 為發生時間，沒有時間的記錄以建立時間代替
+func (repo *LedgerRepo) GetEntries(ctx context.Context, db *gorm.DB, cond *po.LedgerEntryCond) ([]*po.LedgerEntry, error) {
+	entries := make([]*po.LedgerEntry, 0)
+
+	query := db.
+		WithContext(ctx).
+		Table(
+			"(? union all ? union all ?) as entries",
+			db.Table("deposit_point_records as dpr").
+				Select(fmt.Sprintf(`'%s' AS entry_type, 1 AS entry_order, dpr.record_id, dpr.record_ref_id, dpr.student_id, coalesce(dpr.charging_date, dpr.created_at) AS occurred_at, dpr.deposited_points AS points`, ledger.EntryTypeDepositRecord)).
+				Scopes(repo.makeLedgerEntryCond(ctx, cond, "dpr")),
+			db.Table("reduce_point_records as rpr").
+				Select(fmt.Sprintf(`'%s' AS entry_type, 2 AS entry_order, rpr.record_id, rpr.record_ref_id, rpr.student_id, coalesce(rpr.class_time, rpr.created_at) AS occurred_at, rpr.reduce_points AS points`, ledger.EntryTypeReduceRecord)).
+				Scopes(repo.makeLedgerEntryCond(ctx, cond, "rpr")),
+			db.Table("semester_settle_records as ssr").
+				Select(fmt.Sprintf(`'%s' AS entry_type, 3 AS entry_order, ssr.record_id, ssr.record_ref_id, ssr.student_id, ssr.end_time AS occurred_at, ssr.clear_points AS points`, ledger.EntryTypeSettleRecord)).
+				Scopes(repo.makeLedgerEntryCond(ctx, cond, "ssr")),
+		)
+	if cond != nil && !cond.AsOf.IsZero() {
+		query = query.Where("entries.occurred_at <= ?", cond.AsOf)
+	}
+
+	if err := query.
+		Order("entries.occurred_at, entries.entry_order, entries.record_ref_id").
+		Scan(&entries).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return entries, nil
+}
+
+func (repo *LedgerRepo) makeLedgerEntryCond(ctx context.Context, cond *po.LedgerEntryCond, tableName string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil && !cond.AsOf.IsZero() {
+			// 之後才刪除的記錄在該時間點仍然有效
+			db = db.Where(fmt.Sprintf("(%[1]s.is_deleted = false OR %[1]s.deleted_at > ?)", tableName), cond.AsOf)
+		} else {
+			db = db.Where(tableName + ".is_deleted = false")
+		}
+		if cond != nil {
+			if cond.StudentId != 0 {
+				db = db.Where(tableName+".student_id = ?", cond.StudentId)
+			}
+		}
+		return db
+	}
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+func ProvideLoginSessionRepository() *LoginSessionRepo {
+	return &LoginSessionRepo{}
+}
+
+type LoginSessionRepo struct{}
+
+func (repo *LoginSessionRepo) GetLoginSessions(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond, pager *po.Pager) ([]*po.LoginSession, error) {
+	loginSessions := make([]*po.LoginSession, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.LoginSession{}).
+		Scopes(repo.makeLoginSessionCond(ctx, cond, pager)).
+		Find(&loginSessions).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return loginSessions, nil
+}
+
+func (repo *LoginSessionRepo) GetLoginSessionsPager(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond, pager *po.Pager) (*po.PagerResult, error) {
+	var total int64
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.LoginSession{}).
+		Scopes(repo.makeLoginSessionCond(ctx, cond, nil)).
+		Count(&total).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return po.NewPagerResult(pager, total), nil
+}
+
+func (repo *LoginSessionRepo) GetLoginSession(ctx context.Context, db *gorm.DB, cond *po.LoginSessionCond) (*po.LoginSession, error) {
+	loginSession := &po.LoginSession{}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.LoginSession{}).
+		Scopes(repo.makeLoginSessionCond(ctx, cond, nil)).
+		First(loginSession).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return loginSession, nil
+}
+
+func (repo *LoginSessionRepo) AddLoginSession(ctx context.Context, db *gorm.DB, data *po.LoginSession) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *LoginSessionRepo) UpdateLoginSessions(ctx context.Context, db *gorm.DB, cond *po.UpdateLoginSessionCond, data *po.UpdateLoginSessionData) error {
+	// 沒有指定 session 或使用者時不更新，避免撤銷所有人的登入
+	if cond.SessionId == 0 && cond.TokenHash == "" && len(cond.UserIds) == 0 {
+		return nil
+	}
+
+	updated := make(map[string]interface{})
+	if data.Data != "" {
+		updated["data"] = data.Data
+	}
+	if data.ExpiresAt != nil {
+		updated["expires_at"] = *data.ExpiresAt
+	}
+	if data.LastSeenAt != nil {
+		updated["last_seen_at"] = *data.LastSeenAt
+	}
+	if data.RevokedAt != nil {
+		updated["revoked_at"] = *data.RevokedAt
+	}
+	if data.RevokedReason != "" {
+		updated["revoked_reason"] = data.RevokedReason
+	}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.LoginSession{}).
+		Scopes(repo.makeUpdateLoginSessionCond(ctx, cond)).
+		Updates(updated).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *LoginSessionRepo) makeLoginSessionCond(ctx context.Context, cond *po.LoginSessionCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.SessionId != 0 {
+				db = db.Where("session_id = ?", cond.SessionId)
+			}
+			if cond.UserId != 0 {
+				db = db.Where("user_id = ?", cond.UserId)
+			}
+			if cond.TokenHash != "" {
+				db = db.Where("token_hash = ?", cond.TokenHash)
+			}
+			if cond.ActiveAt != nil {
+				db = db.Where("revoked_at IS NULL AND expires_at > ?", *cond.ActiveAt)
+			}
+		}
+		if pager != nil {
+			db.Scopes(parsePaging(pager))
+		}
+		return db
+	}
+}
+
+// makeUpdateLoginSessionCond 已撤銷的 session 不會再被更新，避免重新啟用或覆蓋撤銷原因
+func (repo *LoginSessionRepo) makeUpdateLoginSessionCond(ctx context.Context, cond *po.UpdateLoginSessionCond) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("revoked_at IS NULL")
+		if cond.SessionId != 0 {
+			db = db.Where("session_id = ?", cond.SessionId)
+		}
+		if cond.TokenHash != "" {
+			db = db.Where("token_hash = ?", cond.TokenHash)
+		}
+		if len(cond.UserIds) > 0 {
+			db = db.Where("user_id IN ?", cond.UserIds)
+		}
+		if cond.ExceptTokenHash != "" {
+			db = db.Where("token_hash != ?", cond.ExceptTokenHash)
+		}
+		return db
+	}
+}
@@ -148,7 +148,7 @@ func (repo *ReduceRecordRepo) GetStudentTotalReducePoints(ctx context.Context, d
 	tableName := reduceRecord.TableName()
 	if err := db.
 		Model(reduceRecord).
-		Select(fmt.Sprintf("%s.student_id, sum(%s.reduce_points) as total_reduce_points", tableName, tableName)).
+		Select(fmt.Sprintf("%s.student_id, sum(%s.reduce_points) as total_reduce_points, count(*) as record_count", tableName, tableName)).
 		Scopes(repo.makeReduceRecordCond(ctx, cond, nil)).
 		Group(tableName + ".student_id").
 		Find(&records).Error; err != nil {
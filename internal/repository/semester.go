@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+func ProvideSemesterRepository() *SemesterRepo {
+	return &SemesterRepo{}
+}
+
+type SemesterRepo struct{}
+
+func (repo *SemesterRepo) GetSemester(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) (*po.Semester, error) {
+	semester := &po.Semester{}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.Semester{}).
+		Scopes(repo.makeSemesterCond(ctx, cond)).
+		First(semester).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return semester, nil
+}
+
+func (repo *SemesterRepo) GetSemesters(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) ([]*po.Semester, error) {
+	semesters := make([]*po.Semester, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.Semester{}).
+		Scopes(repo.makeSemesterCond(ctx, cond)).
+		Order("start_date").
+		Find(&semesters).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return semesters, nil
+}
+
+func (repo *SemesterRepo) AddSemester(ctx context.Context, db *gorm.DB, data *po.Semester) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SemesterRepo) UpdateSemester(ctx context.Context, db *gorm.DB, cond *po.UpdateSemesterCond, data *po.UpdateSemesterData) error {
+	updated := map[string]interface{}{
+		"name":        data.Name,
+		"start_date":  data.StartDate,
+		"end_date":    data.EndDate,
+		"settle_date": data.SettleDate,
+		"time_zone":   data.TimeZone,
+	}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.Semester{}).
+		Where("semester_id = ?", cond.SemesterId).
+		Updates(updated).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SemesterRepo) DeleteSemester(ctx context.Context, db *gorm.DB, cond *po.SemesterCond) error {
+	if err := db.
+		WithContext(ctx).
+		Where("semester_id = ?", cond.SemesterId).
+		Delete(&po.Semester{}).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SemesterRepo) makeSemesterCond(ctx context.Context, cond *po.SemesterCond) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.SemesterId != 0 {
+				db = db.Where("semester_id = ?", cond.SemesterId)
+			}
+		}
+		return db
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"gorm.io/gorm"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/po"
@@ -111,6 +112,22 @@ func (repo *SemesterSettleRecordRepository) GetSemesterSettleRecordRefIds(ctx co
 	return semesterSettleRecordRefIds, nil
 }
 
+func (repo *SemesterSettleRecordRepository) GetStudentTotalClearPoints(ctx context.Context, db *gorm.DB, cond *po.SemesterSettleRecordCond) ([]*po.StudentTotalClearPoints, error) {
+	records := make([]*po.StudentTotalClearPoints, 0)
+	semesterSettleRecord := &po.SemesterSettleRecord{}
+	tableName := semesterSettleRecord.TableName()
+	if err := db.
+		Model(semesterSettleRecord).
+		Select(fmt.Sprintf("%s.student_id, sum(%s.clear_points) as total_clear_points, count(*) as record_count", tableName, tableName)).
+		Scopes(repo.makeGetSemesterSettleRecordsCond(ctx, cond, nil)).
+		Group(tableName + ".student_id").
+		Find(&records).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return records, nil
+}
+
 func (repo *SemesterSettleRecordRepository) makeGetSemesterSettleRecordsCond(ctx context.Context, cond *po.SemesterSettleRecordCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
 	tableName := new(po.SemesterSettleRecord).TableName()
 	return func(db *gorm.DB) *gorm.DB {
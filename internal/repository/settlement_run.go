@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+const settlementRunItemsBatchSize = 500
+
+func ProvideSettlementRunRepository() *SettlementRunRepo {
+	return &SettlementRunRepo{}
+}
+
+type SettlementRunRepo struct{}
+
+func (repo *SettlementRunRepo) GetRun(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond) (*po.SettlementRun, error) {
+	run := &po.SettlementRun{}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementRun{}).
+		Scopes(repo.makeSettlementRunCond(ctx, cond, nil)).
+		First(run).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return run, nil
+}
+
+func (repo *SettlementRunRepo) GetRuns(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond, pager *po.Pager) ([]*po.SettlementRun, error) {
+	runs := make([]*po.SettlementRun, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementRun{}).
+		Scopes(repo.makeSettlementRunCond(ctx, cond, pager)).
+		Find(&runs).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return runs, nil
+}
+
+func (repo *SettlementRunRepo) GetRunsPager(ctx context.Context, db *gorm.DB, cond *po.SettlementRunCond, pager *po.Pager) (*po.PagerResult, error) {
+	var total int64
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementRun{}).
+		Scopes(repo.makeSettlementRunCond(ctx, cond, nil)).
+		Count(&total).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return po.NewPagerResult(pager, total), nil
+}
+
+func (repo *SettlementRunRepo) AddRun(ctx context.Context, db *gorm.DB, run *po.SettlementRun, items []*po.SettlementRunItem) error {
+	if err := db.WithContext(ctx).Create(run).Error; err != nil {
+		return handleDBError(err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := db.WithContext(ctx).CreateInBatches(items, settlementRunItemsBatchSize).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+// UpdateRun 回傳更新的筆數，用來判斷是否搶到執行權
+func (repo *SettlementRunRepo) UpdateRun(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementRunCond, data *po.UpdateSettlementRunData) (int64, error) {
+	updated := make(map[string]interface{})
+
+	if data.Status != nil {
+		updated["status"] = *data.Status
+	}
+	if data.Error != nil {
+		updated["error"] = *data.Error
+	}
+	if data.StartedAt != nil {
+		updated["started_at"] = *data.StartedAt
+	}
+	if data.FinishedAt != nil {
+		updated["finished_at"] = *data.FinishedAt
+	}
+
+	query := db.
+		WithContext(ctx).
+		Model(&po.SettlementRun{}).
+		Where("run_id = ?", cond.RunId)
+	if len(cond.Statuses) > 0 {
+		query = query.Where("status IN ?", cond.Statuses)
+	}
+
+	result := query.Updates(updated)
+	if result.Error != nil {
+		return 0, handleDBError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (repo *SettlementRunRepo) GetRunItems(ctx context.Context, db *gorm.DB, cond *po.SettlementRunItemCond) ([]*po.SettlementRunItem, error) {
+	items := make([]*po.SettlementRunItem, 0)
+
+	query := db.
+		WithContext(ctx).
+		Model(&po.SettlementRunItem{}).
+		Where("run_id = ?", cond.RunId)
+	if cond.StudentId != 0 {
+		query = query.Where("student_id = ?", cond.StudentId)
+	}
+	if cond.Step != "" {
+		query = query.Where("step = ?", cond.Step)
+	}
+
+	if err := query.Order("student_id").Find(&items).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return items, nil
+}
+
+func (repo *SettlementRunRepo) GetRunStepCounts(ctx context.Context, db *gorm.DB, runIds []int64) ([]*po.SettlementRunStepCount, error) {
+	counts := make([]*po.SettlementRunStepCount, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementRunItem{}).
+		Select("run_id, step, count(*) AS count").
+		Where("run_id IN ?", runIds).
+		Group("run_id, step").
+		Scan(&counts).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return counts, nil
+}
+
+func (repo *SettlementRunRepo) UpdateRunItems(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementRunItemCond, data *po.UpdateSettlementRunItemData) error {
+	updated := map[string]interface{}{
+		"step": data.Step,
+	}
+	if data.SettleRecordRefId != nil {
+		updated["settle_record_ref_id"] = *data.SettleRecordRefId
+	}
+	if data.RestPointsAfter != nil {
+		updated["rest_points_after"] = *data.RestPointsAfter
+	}
+	if data.ReversalId != nil {
+		updated["reversal_id"] = *data.ReversalId
+	}
+
+	query := db.
+		WithContext(ctx).
+		Model(&po.SettlementRunItem{}).
+		Where("run_id = ?", cond.RunId)
+	if len(cond.StudentIds) > 0 {
+		query = query.Where("student_id IN ?", cond.StudentIds)
+	}
+	if cond.Step != "" {
+		query = query.Where("step = ?", cond.Step)
+	}
+
+	if err := query.Updates(updated).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SettlementRunRepo) GetReversals(ctx context.Context, db *gorm.DB, cond *po.SettlementReversalCond) ([]*po.SettlementReversal, error) {
+	reversals := make([]*po.SettlementReversal, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementReversal{}).
+		Where("run_id = ?", cond.RunId).
+		Order("reversal_id desc").
+		Find(&reversals).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return reversals, nil
+}
+
+func (repo *SettlementRunRepo) AddReversal(ctx context.Context, db *gorm.DB, reversal *po.SettlementReversal) error {
+	if err := db.WithContext(ctx).Create(reversal).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SettlementRunRepo) UpdateReversal(ctx context.Context, db *gorm.DB, cond *po.UpdateSettlementReversalCond, data *po.UpdateSettlementReversalData) error {
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SettlementReversal{}).
+		Where("reversal_id = ?", cond.ReversalId).
+		Updates(map[string]interface{}{
+			"status":         data.Status,
+			"reversed_count": data.ReversedCount,
+			"error":          data.Error,
+			"finished_at":    data.FinishedAt,
+		}).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SettlementRunRepo) makeSettlementRunCond(ctx context.Context, cond *po.SettlementRunCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.RunId != 0 {
+				db = db.Where("run_id = ?", cond.RunId)
+			}
+			if cond.SemesterId != 0 {
+				db = db.Where("semester_id = ?", cond.SemesterId)
+			}
+			if len(cond.ExcludeStatuses) > 0 {
+				db = db.Where("status NOT IN ?", cond.ExcludeStatuses)
+			}
+		}
+		if pager != nil {
+			db.Scopes(parsePaging(pager))
+		}
+		return db
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/database"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSettlementRunResettle 結算 → 撤銷整個學期 → 重新結算
+func TestSettlementRunResettle(t *testing.T) {
+	os.Setenv("APP_ENV", "local")
+	iConfigEnv := config.ProviderIConfigEnv()
+	db := database.ProvidePostgresDB(iConfigEnv)
+	repo := ProvideSettlementRunRepository()
+	ctx := context.TODO()
+
+	semesterId := time.Now().UnixNano()
+	t.Cleanup(func() {
+		db.Session().Where("semester_id = ?", semesterId).Delete(&po.SettlementRun{})
+	})
+	newRun := func(runId int64) *po.SettlementRun {
+		return &po.SettlementRun{
+			RunId:      runId,
+			SemesterId: semesterId,
+			StartTime:  time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local),
+			EndTime:    time.Date(2027, 2, 28, 0, 0, 0, 0, time.Local),
+			Status:     settlement.RunStatusPending,
+		}
+	}
+	activeCond := &po.SettlementRunCond{SemesterId: semesterId, ExcludeStatuses: []settlement.RunStatus{settlement.RunStatusReversed}}
+
+	if err := repo.AddRun(ctx, db.Session(), newRun(semesterId), nil); err != nil {
+		t.Fatalf("AddRun() error = %v", err)
+	}
+	// 同一個學期只能有一筆未撤銷的結算
+	if err := repo.AddRun(ctx, db.Session(), newRun(semesterId+1), nil); !errors.Is(err, errs.DbErr.UniqueViolation) {
+		t.Fatalf("AddRun() error = %v, want UniqueViolation", err)
+	}
+
+	reversed := settlement.RunStatusReversed
+	if _, err := repo.UpdateRun(ctx, db.Session(), &po.UpdateSettlementRunCond{RunId: semesterId}, &po.UpdateSettlementRunData{Status: &reversed}); err != nil {
+		t.Fatalf("UpdateRun() error = %v", err)
+	}
+	if _, err := repo.GetRun(ctx, db.Session(), activeCond); !errors.Is(err, errs.DbErr.NoRow) {
+		t.Fatalf("GetRun() error = %v, want NoRow", err)
+	}
+
+	if err := repo.AddRun(ctx, db.Session(), newRun(semesterId+1), nil); err != nil {
+		t.Fatalf("AddRun() after reversed error = %v", err)
+	}
+	run, err := repo.GetRun(ctx, db.Session(), activeCond)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.RunId != semesterId+1 {
+		t.Errorf("GetRun() run_id = %d, want %d", run.RunId, semesterId+1)
+	}
+}
@@ -32,6 +32,19 @@ func (repo *StudentRepo) GetStudents(ctx context.Context, db *gorm.DB, cond *po.
 	return students, nil
 }
 
+// ListStudents 不 join 點數卡，沒有點數卡的學生也會列出
+func (repo *StudentRepo) ListStudents(ctx context.Context, db *gorm.DB, cond *po.StudentCond) ([]*po.Student, error) {
+	students := make([]*po.Student, 0)
+
+	if err := db.
+		Model(&po.Student{}).
+		Scopes(repo.makeStudentCond(ctx, cond, nil)).
+		Find(&students).Error; err != nil {
+		return nil, errs.ParseDBError(err)
+	}
+	return students, nil
+}
+
 func (repo *StudentRepo) GetStudentsPager(ctx context.Context, db *gorm.DB, cond *po.StudentCond, pager *po.Pager) (*po.PagerResult, error) {
 	var count int64
 
@@ -5,6 +5,7 @@ import (
 	"jaystar/internal/config"
 	"jaystar/internal/database"
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils/kintoneFake"
 	"log"
 	"os"
 	"testing"
@@ -16,7 +17,7 @@ func TestGetStudentRefIds(t *testing.T) {
 	db := database.ProvidePostgresDB(iConfigEnv)
 	repo := ProvideStudentRepository()
 
-	ids, err := repo.GetStudentRefIds(context.TODO(), db.Session(), &po.StudentCond{StudentName: "施鈞捷", ParentPhone: "0975670131"})
+	ids, err := repo.GetStudentRefIds(context.TODO(), db.Session(), &po.StudentCond{StudentName: kintoneFake.StudentAName, ParentPhone: kintoneFake.StudentAPhone})
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/model/po"
+)
+
+func ProvideSyncJobRepository() *SyncJobRepo {
+	return &SyncJobRepo{}
+}
+
+type SyncJobRepo struct{}
+
+func (repo *SyncJobRepo) GetJob(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond) (*po.SyncJob, error) {
+	job := &po.SyncJob{}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SyncJob{}).
+		Scopes(repo.makeSyncJobCond(ctx, cond, nil)).
+		First(job).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return job, nil
+}
+
+func (repo *SyncJobRepo) GetJobs(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond, pager *po.Pager) ([]*po.SyncJob, error) {
+	jobs := make([]*po.SyncJob, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SyncJob{}).
+		Scopes(repo.makeSyncJobCond(ctx, cond, pager)).
+		Find(&jobs).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return jobs, nil
+}
+
+func (repo *SyncJobRepo) GetJobsPager(ctx context.Context, db *gorm.DB, cond *po.SyncJobCond, pager *po.Pager) (*po.PagerResult, error) {
+	var total int64
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SyncJob{}).
+		Scopes(repo.makeSyncJobCond(ctx, cond, nil)).
+		Count(&total).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return po.NewPagerResult(pager, total), nil
+}
+
+func (repo *SyncJobRepo) AddJob(ctx context.Context, db *gorm.DB, data *po.SyncJob) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *SyncJobRepo) UpdateJob(ctx context.Context, db *gorm.DB, cond *po.UpdateSyncJobCond, data *po.UpdateSyncJobData) error {
+	updated := make(map[string]interface{})
+
+	if data.Status != nil {
+		updated["status"] = *data.Status
+	}
+	if data.TotalCount != nil {
+		updated["total_count"] = *data.TotalCount
+	}
+	if data.CreatedCount != nil {
+		updated["created_count"] = *data.CreatedCount
+	}
+	if data.UpdatedCount != nil {
+		updated["updated_count"] = *data.UpdatedCount
+	}
+	if data.DeletedCount != nil {
+		updated["deleted_count"] = *data.DeletedCount
+	}
+	if data.SkippedCount != nil {
+		updated["skipped_count"] = *data.SkippedCount
+	}
+	if data.FailedCount != nil {
+		updated["failed_count"] = *data.FailedCount
+	}
+	if data.UnchangedCount != nil {
+		updated["unchanged_count"] = *data.UnchangedCount
+	}
+	if data.Failures != nil {
+		updated["failures"] = *data.Failures
+	}
+	if data.Diff != nil {
+		updated["diff"] = *data.Diff
+	}
+	if data.Reconciliation != nil {
+		updated["reconciliation"] = *data.Reconciliation
+	}
+	if data.Error != nil {
+		updated["error"] = *data.Error
+	}
+	if data.StartedAt != nil {
+		updated["started_at"] = *data.StartedAt
+	}
+	if data.FinishedAt != nil {
+		updated["finished_at"] = *data.FinishedAt
+	}
+	if data.HeartbeatAt != nil {
+		updated["heartbeat_at"] = *data.HeartbeatAt
+	}
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SyncJob{}).
+		Where("job_id = ?", cond.JobId).
+		Updates(updated).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+// UpdateJobs 更新符合條件的同步，回傳更新的筆數
+func (repo *SyncJobRepo) UpdateJobs(ctx context.Context, db *gorm.DB, cond *po.UpdateSyncJobCond, data *po.UpdateSyncJobData) (int64, error) {
+	// 沒有指定同步或狀態時不更新，避免改到所有的同步
+	if cond.JobId == 0 && len(cond.Statuses) == 0 {
+		return 0, nil
+	}
+
+	updated := map[string]interface{}{}
+	if data.Status != nil {
+		updated["status"] = *data.Status
+	}
+	if data.Error != nil {
+		updated["error"] = *data.Error
+	}
+	if data.FinishedAt != nil {
+		updated["finished_at"] = *data.FinishedAt
+	}
+
+	result := db.
+		WithContext(ctx).
+		Model(&po.SyncJob{}).
+		Scopes(repo.makeUpdateSyncJobCond(cond)).
+		Updates(updated)
+	if err := result.Error; err != nil {
+		return 0, handleDBError(err)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (repo *SyncJobRepo) makeSyncJobCond(ctx context.Context, cond *po.SyncJobCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if cond.JobId != 0 {
+				db = db.Where("job_id = ?", cond.JobId)
+			}
+			if cond.JobType != "" {
+				db = db.Where("job_type = ?", cond.JobType)
+			}
+			if cond.Status != "" {
+				db = db.Where("status = ?", cond.Status)
+			}
+			if len(cond.Statuses) > 0 {
+				db = db.Where("status IN ?", cond.Statuses)
+			}
+			if cond.HeartbeatAfter != nil {
+				db = db.Where("heartbeat_at > ?", *cond.HeartbeatAfter)
+			}
+		}
+		if pager != nil {
+			db.Scopes(parsePaging(pager))
+		}
+		return db
+	}
+}
+
+func (repo *SyncJobRepo) makeUpdateSyncJobCond(cond *po.UpdateSyncJobCond) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond.JobId != 0 {
+			db = db.Where("job_id = ?", cond.JobId)
+		}
+		if cond.JobType != "" {
+			db = db.Where("job_type = ?", cond.JobType)
+		}
+		if len(cond.Statuses) > 0 {
+			db = db.Where("status IN ?", cond.Statuses)
+		}
+		if cond.HeartbeatBefore != nil {
+			db = db.Where("heartbeat_at < ?", *cond.HeartbeatBefore)
+		}
+		return db
+	}
+}
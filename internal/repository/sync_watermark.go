@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/po"
+	"time"
+)
+
+func ProvideSyncWatermarkRepository() *SyncWatermarkRepo {
+	return &SyncWatermarkRepo{}
+}
+
+type SyncWatermarkRepo struct{}
+
+func (repo *SyncWatermarkRepo) GetWatermarks(ctx context.Context, db *gorm.DB) ([]*po.SyncWatermark, error) {
+	watermarks := make([]*po.SyncWatermark, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.SyncWatermark{}).
+		Find(&watermarks).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return watermarks, nil
+}
+
+func (repo *SyncWatermarkRepo) UpsertWatermark(ctx context.Context, db *gorm.DB, jobType syncjob.Type, watermark time.Time) error {
+	data := &po.SyncWatermark{
+		JobType:   jobType,
+		Watermark: watermark,
+	}
+
+	if err := db.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "job_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"watermark", "updated_at"}),
+		}).
+		Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
@@ -74,6 +74,9 @@ func (repo *UserRepo) UpdateUser(ctx context.Context, db *gorm.DB, cond *po.User
 	if data.Status != "" {
 		updated["status"] = data.Status
 	}
+	if data.Role != "" {
+		updated["role"] = data.Role
+	}
 	if data.IsChangedPassword != nil {
 		updated["is_changed_password"] = *data.IsChangedPassword
 	}
@@ -95,6 +98,8 @@ func (repo *UserRepo) DeactivateUserWithNoActiveStudent(ctx context.Context, db
 		Model(&updatedUsers).
 		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}, {Name: "account"}}}).
 		Where("status != ?", user.Deactivate.ToKey()).
+		// 管理者帳號沒有學生
+		Where("level IS DISTINCT FROM ?", user.Admin.ToKey()).
 		Not("user_id IN (?)", userWithActiveStudentSubQuery).
 		Update("status", user.Deactivate.ToKey()).
 		Error; err != nil {
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/model/po"
+)
+
+func ProvideWebhookEventRepository() *WebhookEventRepo {
+	return &WebhookEventRepo{}
+}
+
+type WebhookEventRepo struct{}
+
+func (repo *WebhookEventRepo) GetEvents(ctx context.Context, db *gorm.DB, cond *po.WebhookEventCond, pager *po.Pager) ([]*po.WebhookEvent, error) {
+	events := make([]*po.WebhookEvent, 0)
+
+	if err := db.
+		Model(&po.WebhookEvent{}).
+		Scopes(repo.makeWebhookEventCond(ctx, cond, pager)).
+		Find(&events).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return events, nil
+}
+
+func (repo *WebhookEventRepo) GetEventsPager(ctx context.Context, db *gorm.DB, cond *po.WebhookEventCond, pager *po.Pager) (*po.PagerResult, error) {
+	var total int64
+
+	if err := db.
+		Model(&po.WebhookEvent{}).
+		Scopes(repo.makeWebhookEventCond(ctx, cond, nil)).
+		Count(&total).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return po.NewPagerResult(pager, total), nil
+}
+
+func (repo *WebhookEventRepo) AddEvent(ctx context.Context, db *gorm.DB, data *po.WebhookEvent) error {
+	if err := db.WithContext(ctx).Create(data).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+// ClaimEvents 領取待處理的事件並標記為處理中，SKIP LOCKED 讓多個 instance 不會領到同一筆事件
+// 同一筆記錄還有較早的事件未完成時不領取，確保同一筆記錄依收到的順序處理且不會同時處理
+func (repo *WebhookEventRepo) ClaimEvents(ctx context.Context, db *gorm.DB, cond *po.ClaimWebhookEventsCond) ([]*po.WebhookEvent, error) {
+	events := make([]*po.WebhookEvent, 0)
+	tableName := new(po.WebhookEvent).TableName()
+
+	if err := db.WithContext(ctx).Raw(
+		`UPDATE `+tableName+` SET status = ?, locked_at = ?, attempts = attempts + 1, updated_at = ?
+		WHERE event_id IN (
+			SELECT e.event_id FROM `+tableName+` e
+			WHERE ((e.status = ? AND e.next_attempt_at <= ?) OR (e.status = ? AND e.locked_at < ?))
+			AND NOT EXISTS (
+				SELECT 1 FROM `+tableName+` prev
+				WHERE prev.source = e.source AND prev.record_id = e.record_id AND e.record_id <> ''
+				AND prev.event_id < e.event_id AND prev.status IN (?, ?)
+			)
+			ORDER BY e.event_id
+			LIMIT ?
+			FOR UPDATE OF e SKIP LOCKED
+		)
+		RETURNING *`,
+		webhook.StatusProcessing, cond.Now, cond.Now,
+		webhook.StatusPending, cond.Now, webhook.StatusProcessing, cond.StaleBefore,
+		webhook.StatusPending, webhook.StatusProcessing,
+		cond.Limit,
+	).Scan(&events).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return events, nil
+}
+
+// GetRecordIdsWithEventsSince 尚未處理完，或 ReceivedSince 之後才收到事件的記錄
+func (repo *WebhookEventRepo) GetRecordIdsWithEventsSince(ctx context.Context, db *gorm.DB, cond *po.RecordWebhookEventsCond) ([]string, error) {
+	recordIds := make([]string, 0)
+
+	if err := db.
+		WithContext(ctx).
+		Model(&po.WebhookEvent{}).
+		Distinct("record_id").
+		Where("source = ?", cond.Source).
+		Where("(status IN ? OR created_at >= ?)", []webhook.Status{webhook.StatusPending, webhook.StatusProcessing}, cond.ReceivedSince).
+		Pluck("record_id", &recordIds).Error; err != nil {
+		return nil, handleDBError(err)
+	}
+
+	return recordIds, nil
+}
+
+// LockRecord 以 transaction 層級的 advisory lock 鎖住一筆記錄，transaction 結束時釋放
+// 多個服務實例同時執行時，同一筆記錄的 webhook 與 BatchSync 也會依序處理
+func (repo *WebhookEventRepo) LockRecord(ctx context.Context, db *gorm.DB, source webhook.Source, recordId string) error {
+	if err := db.
+		WithContext(ctx).
+		Exec("SELECT pg_advisory_xact_lock(hashtext(?), hashtext(?))", string(source), recordId).Error; err != nil {
+		return handleDBError(err)
+	}
+
+	return nil
+}
+
+func (repo *WebhookEventRepo) UpdateEvents(ctx context.Context, db *gorm.DB, cond *po.UpdateWebhookEventCond, data *po.UpdateWebhookEventData) (int64, error) {
+	updated := make(map[string]interface{})
+
+	if data.Status != nil {
+		updated["status"] = *data.Status
+	}
+	if data.Attempts != nil {
+		updated["attempts"] = *data.Attempts
+	}
+	if data.NextAttemptAt != nil {
+		updated["next_attempt_at"] = *data.NextAttemptAt
+	}
+	if data.LastError != nil {
+		updated["last_error"] = *data.LastError
+	}
+	if data.ProcessedAt != nil {
+		updated["processed_at"] = *data.ProcessedAt
+	}
+
+	db = db.
+		WithContext(ctx).
+		Model(&po.WebhookEvent{}).
+		Where("event_id IN ?", cond.EventIds)
+	if cond.Status != "" {
+		db = db.Where("status = ?", cond.Status)
+	}
+
+	result := db.Updates(updated)
+	if result.Error != nil {
+		return 0, handleDBError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (repo *WebhookEventRepo) makeWebhookEventCond(ctx context.Context, cond *po.WebhookEventCond, pager *po.Pager) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cond != nil {
+			if len(cond.EventIds) > 0 {
+				db = db.Where("event_id IN ?", cond.EventIds)
+			}
+			if cond.Source != "" {
+				db = db.Where("source = ?", cond.Source)
+			}
+			if cond.RecordId != "" {
+				db = db.Where("record_id = ?", cond.RecordId)
+			}
+			if cond.Status != "" {
+				db = db.Where("status = ?", cond.Status)
+			}
+		}
+		if pager != nil {
+			db.Scopes(parsePaging(pager))
+		}
+		return db
+	}
+}
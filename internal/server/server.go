@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"jaystar/internal/app/job"
 	"jaystar/internal/app/web"
+	"jaystar/internal/app/worker"
 	"jaystar/internal/config"
 	"log"
 	"net/http"
@@ -13,12 +14,13 @@ import (
 )
 
 type appServer struct {
-	gin       *gin.Engine  `wire:"-"`
-	server    *http.Server `wire:"-"`
-	iWebApp   web.IWebApp
-	job       job.IJob
-	configEnv config.IConfigEnv
-	logger    logger.ILogger
+	gin           *gin.Engine  `wire:"-"`
+	server        *http.Server `wire:"-"`
+	iWebApp       web.IWebApp
+	job           job.IJob
+	webhookWorker worker.IWebhookWorker
+	configEnv     config.IConfigEnv
+	logger        logger.ILogger
 }
 
 func (app *appServer) Init() {
@@ -28,6 +30,11 @@ func (app *appServer) Init() {
 
 	app.gin = gin.New()
 	app.gin.Use(gin.Recovery())
+	// webhook 的來源 IP 限制依賴 ClientIP，不能信任任意來源的 X-Forwarded-For
+	// 沒有設定時不信任任何 proxy，ClientIP 為連線的來源 IP
+	if err := app.gin.SetTrustedProxies(app.configEnv.GetHttpConfig().TrustedProxies); err != nil {
+		log.Fatalf("🔔🔔🔔 fatal error gin.SetTrustedProxies: %v 🔔🔔🔔", err)
+	}
 
 	app.iWebApp.Init(app.gin)
 	app.job.Init()
@@ -35,6 +42,7 @@ func (app *appServer) Init() {
 
 func (app *appServer) Run() {
 	app.job.Start()
+	app.webhookWorker.Start()
 
 	port := app.configEnv.GetHttpConfig().Port
 	address := ":" + port
@@ -63,7 +71,7 @@ func (app *appServer) Stop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
-	const waitingProcesses = 2
+	const waitingProcesses = 3
 	ch := make(chan struct{}, waitingProcesses)
 
 	go func() {
@@ -71,6 +79,11 @@ func (app *appServer) Stop() {
 		ch <- struct{}{}
 	}()
 
+	go func() {
+		app.webhookWorker.Stop(ctx)
+		ch <- struct{}{}
+	}()
+
 	go func() {
 		if err := app.server.Shutdown(ctx); err != nil {
 			app.logger.Error(ctx, "server Shutdown error", err)
@@ -8,6 +8,7 @@ import (
 	"github.com/google/wire"
 	"jaystar/internal/app/job"
 	web "jaystar/internal/app/web"
+	"jaystar/internal/app/worker"
 	"jaystar/internal/config"
 	jobCtrl "jaystar/internal/controller/job"
 	jobMw "jaystar/internal/controller/job/middleware"
@@ -35,7 +36,10 @@ func NewAppServer() *appServer {
 			webMw.ProvideHttpLogMiddleware,
 			webMw.ProvideAuthMiddleware,
 			webMw.ProvideRecoverMiddleware,
-			webMw.ProvideInternalAuthMiddleware,
+			webMw.ProvideAdminAuthMiddleware,
+			webMw.ProvideApiKeyMiddleware,
+			webMw.ProvideWebhookAuthMiddleware,
+			webMw.ProvideSessionMiddleware,
 
 			jobMw.ProvideJobLogMiddleware,
 			wire.Bind(new(jobMw.IJobMiddleware), new(*jobMw.JobLogMiddleware)),
@@ -81,6 +85,34 @@ func NewAppServer() *appServer {
 			repository.ProvideKintoneSemesterSettleRecordRepository,
 			wire.Bind(new(interfaces.IKintoneSemesterSettleRecordRepo), new(*repository.KintoneSemesterSettleRecordRepository)),
 
+			repository.ProvideWebhookEventRepository,
+			wire.Bind(new(interfaces.IWebhookEventRepo), new(*repository.WebhookEventRepo)),
+			repository.ProvideSyncJobRepository,
+			wire.Bind(new(interfaces.ISyncJobRepo), new(*repository.SyncJobRepo)),
+			repository.ProvideSyncWatermarkRepository,
+			wire.Bind(new(interfaces.ISyncWatermarkRepo), new(*repository.SyncWatermarkRepo)),
+
+			repository.ProvideSettlementRunRepository,
+			wire.Bind(new(interfaces.ISettlementRunRepo), new(*repository.SettlementRunRepo)),
+
+			repository.ProvideSemesterRepository,
+			wire.Bind(new(interfaces.ISemesterRepo), new(*repository.SemesterRepo)),
+
+			repository.ProvideLedgerRepository,
+			wire.Bind(new(interfaces.ILedgerRepo), new(*repository.LedgerRepo)),
+
+			repository.ProvideApiKeyRepository,
+			wire.Bind(new(interfaces.IApiKeyRepo), new(*repository.ApiKeyRepo)),
+			repository.ProvideAuditLogRepository,
+			wire.Bind(new(interfaces.IAuditLogRepo), new(*repository.AuditLogRepo)),
+			repository.ProvideLoginSessionRepository,
+			wire.Bind(new(interfaces.ILoginSessionRepo), new(*repository.LoginSessionRepo)),
+
+			repository.ProvideKintoneBulkRepository,
+			wire.Bind(new(interfaces.IKintoneBulkRepo), new(*repository.KintoneBulkRepository)),
+			repository.ProvideKintoneRecordRepository,
+			wire.Bind(new(interfaces.IKintoneRecordRepo), new(*repository.KintoneRecordRepository)),
+
 			commonSrv.ProvideUserCommonService,
 			wire.Bind(new(interfaces.IUserCommonSrv), new(*commonSrv.UserCommonService)),
 
@@ -99,6 +131,9 @@ func NewAppServer() *appServer {
 			commonSrv.ProvideScheduleCommonService,
 			wire.Bind(new(interfaces.IScheduleCommonSrv), new(*commonSrv.ScheduleCommonService)),
 
+			commonSrv.ProvideRecordLockCommonService,
+			wire.Bind(new(interfaces.IRecordLockCommonSrv), new(*commonSrv.RecordLockCommonService)),
+
 			service.ProvideUserService,
 			wire.Bind(new(interfaces.IUserSrv), new(*service.UserService)),
 
@@ -117,6 +152,25 @@ func NewAppServer() *appServer {
 			service.ProvideSemesterSettleRecordService,
 			wire.Bind(new(interfaces.ISemesterSettleRecordSrv), new(*service.SemesterSettleRecordService)),
 
+			service.ProvideWebhookEventService,
+			wire.Bind(new(interfaces.IWebhookEventSrv), new(*service.WebhookEventService)),
+			service.ProvideSyncJobService,
+			wire.Bind(new(interfaces.ISyncJobSrv), new(*service.SyncJobService)),
+			service.ProvideIncrementalSyncService,
+			wire.Bind(new(interfaces.IIncrementalSyncSrv), new(*service.IncrementalSyncService)),
+			service.ProvideReconciliationService,
+			wire.Bind(new(interfaces.IReconciliationSrv), new(*service.ReconciliationService)),
+			service.ProvideSemesterService,
+			wire.Bind(new(interfaces.ISemesterSrv), new(*service.SemesterService)),
+			service.ProvideLedgerService,
+			wire.Bind(new(interfaces.ILedgerSrv), new(*service.LedgerService)),
+			service.ProvideApiKeyService,
+			wire.Bind(new(interfaces.IApiKeySrv), new(*service.ApiKeyService)),
+			service.ProvideAuditLogService,
+			wire.Bind(new(interfaces.IAuditLogSrv), new(*service.AuditLogService)),
+			service.ProvideLoginSessionService,
+			wire.Bind(new(interfaces.ILoginSessionSrv), new(*service.LoginSessionService)),
+
 			webCtrl.ProvideUserController,
 
 			webCtrl.ProvideStudentController,
@@ -133,6 +187,20 @@ func NewAppServer() *appServer {
 
 			webCtrl.ProvideSyncController,
 
+			webCtrl.ProvideWebhookController,
+
+			webCtrl.ProvideWebhookHandlers,
+
+			webCtrl.ProvideReconciliationController,
+
+			webCtrl.ProvideLedgerController,
+
+			webCtrl.ProvideSemesterController,
+
+			webCtrl.ProvideApiKeyController,
+			webCtrl.ProvideAuditLogController,
+			webCtrl.ProvideLoginSessionController,
+
 			webCtrl.ProvideController,
 
 			jobCtrl.ProvideController,
@@ -141,6 +209,8 @@ func NewAppServer() *appServer {
 
 			job.ProvideJob,
 
+			worker.ProvideWebhookWorker,
+
 			wire.Struct(new(appServer), "*"),
 		),
 	)
@@ -10,6 +10,7 @@ import (
 	"github.com/SeanZhenggg/go-utils/logger"
 	job2 "jaystar/internal/app/job"
 	web2 "jaystar/internal/app/web"
+	"jaystar/internal/app/worker"
 	"jaystar/internal/config"
 	"jaystar/internal/controller/job"
 	middleware2 "jaystar/internal/controller/job/middleware"
@@ -32,53 +33,87 @@ func NewAppServer() *appServer {
 	iHttpLogMiddleware := middleware.ProvideHttpLogMiddleware(iLogger)
 	iAuthMiddleware := middleware.ProvideAuthMiddleware(iLogger, iConfigEnv)
 	iRecoverMiddleware := middleware.ProvideRecoverMiddleware(iLogger)
-	iInternalAuthMiddleware := middleware.ProvideInternalAuthMiddleware()
 	iPostgresDB := database.ProvidePostgresDB(iConfigEnv)
 	userRepo := repository.ProvideUserRepository(iConfigEnv)
 	studentRepo := repository.ProvideStudentRepository()
-	userCommonService := common.ProvideUserCommonService(iPostgresDB, userRepo, studentRepo)
+	loginSessionRepo := repository.ProvideLoginSessionRepository()
+	userCommonService := common.ProvideUserCommonService(iPostgresDB, userRepo, studentRepo, loginSessionRepo)
+	iAdminAuthMiddleware := middleware.ProvideAdminAuthMiddleware(iLogger, iConfigEnv, userCommonService)
+	apiKeyRepo := repository.ProvideApiKeyRepository()
+	auditLogRepo := repository.ProvideAuditLogRepository()
+	auditLogService := service.ProvideAuditLogService(iPostgresDB, auditLogRepo, iLogger)
+	apiKeyService := service.ProvideApiKeyService(iPostgresDB, apiKeyRepo, auditLogService, iLogger)
+	iApiKeyMiddleware := middleware.ProvideApiKeyMiddleware(iLogger, apiKeyService)
+	iWebhookAuthMiddleware := middleware.ProvideWebhookAuthMiddleware(iLogger, iConfigEnv)
+	loginSessionService := service.ProvideLoginSessionService(iPostgresDB, loginSessionRepo, auditLogService, iLogger)
+	iSessionMiddleware := middleware.ProvideSessionMiddleware(iConfigEnv, loginSessionService)
 	kintoneClient := kintoneAPI.ProvideKintoneClient(iConfigEnv, iLogger)
 	kintoneStudentRepository := repository.ProvideKintoneStudentRepository(iConfigEnv, kintoneClient)
 	studentCommonService := common.ProvideStudentCommonService(iPostgresDB, studentRepo, userRepo, kintoneStudentRepository)
-	userService := service.ProvideUserService(iPostgresDB, userRepo, userCommonService, studentCommonService, iLogger)
+	userService := service.ProvideUserService(iPostgresDB, userRepo, userCommonService, studentCommonService, auditLogService, loginSessionService, iLogger)
 	iRequestParse := util.ProviderRequestParse(iLogger)
 	userCtrl := web.ProvideUserController(userService, iRequestParse, iConfigEnv)
 	kintonePointCardRepository := repository.ProvideKintonePointCardRepository(iConfigEnv, kintoneClient)
 	pointCardRepo := repository.ProvidePointCardRepository()
-	pointCardService := service.ProvidePointCardService(kintonePointCardRepository, pointCardRepo, studentRepo, iPostgresDB, iLogger)
+	webhookEventRepo := repository.ProvideWebhookEventRepository()
+	recordLockCommonService := common.ProvideRecordLockCommonService(iPostgresDB, webhookEventRepo)
+	kintoneRecordRepository := repository.ProvideKintoneRecordRepository(iConfigEnv, kintoneClient)
+	pointCardService := service.ProvidePointCardService(kintonePointCardRepository, pointCardRepo, studentRepo, iPostgresDB, iLogger, recordLockCommonService, kintoneRecordRepository)
 	kintoneDepositRecordRepository := repository.ProvideKintoneDepositRecordRepository(iConfigEnv, kintoneClient)
 	depositRecordCommonService := common.ProvideDepositRecordCommonService(kintoneDepositRecordRepository)
 	kintoneReduceRecordRepository := repository.ProvideKintoneReduceRecordRepository(iConfigEnv, kintoneClient)
 	reduceRecordCommonService := common.ProvideReduceRecordCommonService(kintoneReduceRecordRepository)
 	kintoneScheduleRepository := repository.ProvideKintoneScheduleRepository(iConfigEnv, kintoneClient)
 	scheduleCommonService := common.ProvideScheduleCommonService(kintoneScheduleRepository)
-	studentService := service.ProvideStudentService(iPostgresDB, studentRepo, userCommonService, studentCommonService, iLogger, pointCardService, depositRecordCommonService, reduceRecordCommonService, scheduleCommonService)
+	kintoneBulkRepository := repository.ProvideKintoneBulkRepository(iConfigEnv, kintoneClient)
+	studentService := service.ProvideStudentService(iPostgresDB, studentRepo, userCommonService, studentCommonService, iLogger, pointCardService, depositRecordCommonService, reduceRecordCommonService, scheduleCommonService, kintoneBulkRepository, recordLockCommonService, kintoneRecordRepository)
 	studentCtrl := web.ProvideStudentController(studentService, iRequestParse, iLogger)
 	scheduleRepo := repository.ProvideScheduleRepository(iConfigEnv)
-	scheduleService := service.ProvideScheduleService(studentCommonService, scheduleRepo, iPostgresDB, iLogger, scheduleCommonService)
+	scheduleService := service.ProvideScheduleService(studentCommonService, scheduleRepo, iPostgresDB, iLogger, scheduleCommonService, recordLockCommonService, kintoneRecordRepository)
 	scheduleCtrl := web.ProvideScheduleController(scheduleService, iRequestParse, iLogger)
 	depositRecordRepo := repository.ProvideDepositRecordRepository()
-	depositRecordService := service.ProvideDepositRecordService(depositRecordRepo, iPostgresDB, studentCommonService, iLogger, depositRecordCommonService)
+	depositRecordService := service.ProvideDepositRecordService(depositRecordRepo, iPostgresDB, studentCommonService, iLogger, depositRecordCommonService, recordLockCommonService, kintoneRecordRepository, auditLogService)
 	depositRecordCtrl := web.ProvideDepositRecordController(depositRecordService, iLogger, iRequestParse)
 	reduceRecordRepo := repository.ProvideReduceRecordRepository(iConfigEnv)
-	reduceRecordService := service.ProvideReduceRecordService(reduceRecordRepo, iPostgresDB, studentCommonService, iLogger, reduceRecordCommonService)
+	reduceRecordService := service.ProvideReduceRecordService(reduceRecordRepo, iPostgresDB, studentCommonService, iLogger, reduceRecordCommonService, recordLockCommonService, kintoneRecordRepository, auditLogService)
 	reduceRecordCtrl := web.ProvideReduceRecordController(reduceRecordService, iLogger, iRequestParse)
 	kintoneSemesterSettleRecordRepository := repository.ProvideKintoneSemesterSettleRecordRepository(iConfigEnv, kintoneClient)
 	semesterSettleRecordRepository := repository.ProvideSemesterSettleRecordRepository()
-	semesterSettleRecordService := service.ProvideSemesterSettleRecordService(iPostgresDB, iLogger, depositRecordService, reduceRecordService, kintoneSemesterSettleRecordRepository, semesterSettleRecordRepository, studentService, studentCommonService, pointCardService)
+	semesterRepo := repository.ProvideSemesterRepository()
+	settlementRunRepo := repository.ProvideSettlementRunRepository()
+	semesterService := service.ProvideSemesterService(iPostgresDB, semesterRepo, settlementRunRepo, auditLogService, iLogger)
+	semesterSettleRecordService := service.ProvideSemesterSettleRecordService(iPostgresDB, iLogger, depositRecordService, reduceRecordService, kintoneSemesterSettleRecordRepository, semesterSettleRecordRepository, studentService, studentCommonService, pointCardService, recordLockCommonService, kintoneRecordRepository, semesterService, settlementRunRepo, auditLogService)
 	semesterSettleRecordCtrl := web.ProvideSemesterSettleRecordController(semesterSettleRecordService, iLogger, iRequestParse)
 	pointCardCtrl := web.ProvidePointCardController(pointCardService, iRequestParse, iLogger)
-	syncCtrl := web.ProvideSyncController(studentService, pointCardService, depositRecordService, reduceRecordService, scheduleService, semesterSettleRecordService, iRequestParse, iLogger)
-	controller := web.ProvideController(userCtrl, studentCtrl, scheduleCtrl, depositRecordCtrl, reduceRecordCtrl, semesterSettleRecordCtrl, pointCardCtrl, syncCtrl)
-	iWebApp := web2.ProvideWebApp(iResponseMiddleware, iHttpLogMiddleware, iAuthMiddleware, iRecoverMiddleware, iInternalAuthMiddleware, controller)
-	jobController := job.ProvideController(semesterSettleRecordService)
+	syncJobRepo := repository.ProvideSyncJobRepository()
+	syncJobService := service.ProvideSyncJobService(iPostgresDB, syncJobRepo, auditLogService, iLogger)
+	syncWatermarkRepo := repository.ProvideSyncWatermarkRepository()
+	incrementalSyncService := service.ProvideIncrementalSyncService(iPostgresDB, syncWatermarkRepo, syncJobService, studentService, pointCardService, depositRecordService, reduceRecordService, scheduleService, semesterSettleRecordService, iLogger)
+	syncCtrl := web.ProvideSyncController(studentService, pointCardService, depositRecordService, reduceRecordService, scheduleService, semesterSettleRecordService, syncJobService, incrementalSyncService, iRequestParse, iLogger)
+	webhookEventService := service.ProvideWebhookEventService(iPostgresDB, webhookEventRepo, auditLogService, iLogger)
+	webhookCtrl := web.ProvideWebhookController(webhookEventService, iRequestParse, iLogger)
+	reconciliationService := service.ProvideReconciliationService(iPostgresDB, studentRepo, depositRecordRepo, reduceRecordRepo, scheduleRepo, pointCardRepo, semesterSettleRecordRepository, studentCommonService, depositRecordCommonService, reduceRecordCommonService, scheduleCommonService, pointCardService, semesterSettleRecordService, syncJobService)
+	reconciliationCtrl := web.ProvideReconciliationController(reconciliationService, iLogger)
+	ledgerRepo := repository.ProvideLedgerRepository()
+	ledgerService := service.ProvideLedgerService(iPostgresDB, ledgerRepo, studentRepo, pointCardRepo, depositRecordRepo, reduceRecordRepo, semesterSettleRecordRepository)
+	ledgerCtrl := web.ProvideLedgerController(ledgerService, iRequestParse, iLogger)
+	semesterCtrl := web.ProvideSemesterController(semesterService, iRequestParse, iLogger)
+	apiKeyCtrl := web.ProvideApiKeyController(apiKeyService, iRequestParse, iLogger)
+	auditLogCtrl := web.ProvideAuditLogController(auditLogService, iRequestParse, iLogger)
+	loginSessionCtrl := web.ProvideLoginSessionController(loginSessionService, userService, iRequestParse, iLogger)
+	controller := web.ProvideController(userCtrl, studentCtrl, scheduleCtrl, depositRecordCtrl, reduceRecordCtrl, semesterSettleRecordCtrl, pointCardCtrl, syncCtrl, webhookCtrl, reconciliationCtrl, ledgerCtrl, semesterCtrl, apiKeyCtrl, auditLogCtrl, loginSessionCtrl)
+	iWebApp := web2.ProvideWebApp(iResponseMiddleware, iHttpLogMiddleware, iAuthMiddleware, iRecoverMiddleware, iAdminAuthMiddleware, iApiKeyMiddleware, iWebhookAuthMiddleware, iSessionMiddleware, controller)
+	jobController := job.ProvideController(semesterSettleRecordService, incrementalSyncService, reconciliationService)
 	jobLogMiddleware := middleware2.ProvideJobLogMiddleware(iLogger)
-	iJob := job2.ProvideJob(jobController, jobLogMiddleware)
+	iJob := job2.ProvideJob(jobController, jobLogMiddleware, iConfigEnv)
+	webhookHandlers := web.ProvideWebhookHandlers(studentCtrl, scheduleCtrl, depositRecordCtrl, reduceRecordCtrl, semesterSettleRecordCtrl, pointCardCtrl)
+	iWebhookWorker := worker.ProvideWebhookWorker(webhookEventService, recordLockCommonService, webhookHandlers, iLogger)
 	serverAppServer := &appServer{
-		iWebApp:   iWebApp,
-		job:       iJob,
-		configEnv: iConfigEnv,
-		logger:    iLogger,
+		iWebApp:       iWebApp,
+		job:           iJob,
+		webhookWorker: iWebhookWorker,
+		configEnv:     iConfigEnv,
+		logger:        iLogger,
 	}
 	return serverAppServer
 }
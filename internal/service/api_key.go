@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/apikey"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"strings"
+	"time"
+)
+
+func ProvideApiKeyService(db database.IPostgresDB, apiKeyRepo interfaces.IApiKeyRepo, auditLogSrv interfaces.IAuditLogSrv, logger logger.ILogger) *ApiKeyService {
+	return &ApiKeyService{
+		DB:          db,
+		apiKeyRepo:  apiKeyRepo,
+		auditLogSrv: auditLogSrv,
+		logger:      logger,
+	}
+}
+
+type ApiKeyService struct {
+	DB          database.IPostgresDB
+	apiKeyRepo  interfaces.IApiKeyRepo
+	auditLogSrv interfaces.IAuditLogSrv
+	logger      logger.ILogger
+}
+
+func (srv *ApiKeyService) GetApiKeys(ctx context.Context) ([]*bo.ApiKey, error) {
+	poApiKeys, err := srv.apiKeyRepo.GetApiKeys(ctx, srv.DB.Session())
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService GetApiKeys apiKeyRepo.GetApiKeys: %w", err)
+	}
+
+	apiKeys := make([]*bo.ApiKey, 0, len(poApiKeys))
+	for _, poApiKey := range poApiKeys {
+		apiKeys = append(apiKeys, toApiKeyBo(poApiKey))
+	}
+
+	return apiKeys, nil
+}
+
+func (srv *ApiKeyService) CreateApiKey(ctx context.Context, data *bo.CreateApiKeyData) (*bo.CreatedApiKey, error) {
+	if err := validateApiKeyData(data, time.Now()); err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey validateApiKeyData: %w", err)
+	}
+
+	key, err := generateApiKey()
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey generateApiKey: %w", err)
+	}
+	scopesJson, err := json.Marshal(data.Scopes)
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey json.Marshal: %w", err)
+	}
+	keyId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+
+	poApiKey := &po.ApiKey{
+		KeyId:     keyId,
+		Name:      strings.TrimSpace(data.Name),
+		KeyPrefix: key[:apikey.DisplayPrefixLen],
+		KeyHash:   hashApiKey(key),
+		Scopes:    string(scopesJson),
+		ExpiresAt: data.ExpiresAt,
+		CreatedBy: data.CreatedBy,
+	}
+	if err := srv.apiKeyRepo.AddApiKey(ctx, srv.DB.Session(), poApiKey); err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey apiKeyRepo.AddApiKey: %w", err)
+	}
+
+	apiKey, err := srv.getApiKey(ctx, &po.ApiKeyCond{KeyId: keyId})
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService CreateApiKey getApiKey: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionApiKeyCreate, nil, apiKey)
+
+	return &bo.CreatedApiKey{ApiKey: apiKey, Key: key}, nil
+}
+
+// RevokeApiKey 撤銷後立即失效，已撤銷的 key 直接回傳
+func (srv *ApiKeyService) RevokeApiKey(ctx context.Context, keyId int64) (*bo.ApiKey, error) {
+	apiKey, err := srv.getApiKey(ctx, &po.ApiKeyCond{KeyId: keyId})
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService RevokeApiKey getApiKey: %w", err)
+	}
+	if apiKey.RevokedAt != nil {
+		return apiKey, nil
+	}
+
+	now := time.Now()
+	if err := srv.apiKeyRepo.UpdateApiKey(ctx, srv.DB.Session(), &po.UpdateApiKeyCond{KeyId: keyId}, &po.UpdateApiKeyData{RevokedAt: &now}); err != nil {
+		return nil, xerrors.Errorf("apiKeyService RevokeApiKey apiKeyRepo.UpdateApiKey: %w", err)
+	}
+
+	revoked, err := srv.getApiKey(ctx, &po.ApiKeyCond{KeyId: keyId})
+	if err != nil {
+		return nil, xerrors.Errorf("apiKeyService RevokeApiKey getApiKey: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionApiKeyRevoke, apiKey, revoked)
+
+	return revoked, nil
+}
+
+func (srv *ApiKeyService) AuthenticateApiKey(ctx context.Context, key string) (*bo.ApiKey, error) {
+	if !strings.HasPrefix(key, apikey.KeyPrefix) {
+		return nil, xerrors.Errorf("apiKeyService AuthenticateApiKey prefix: %w", errs.ApiKeyErr.ApiKeyInvalidError)
+	}
+
+	apiKey, err := srv.getApiKey(ctx, &po.ApiKeyCond{KeyHash: hashApiKey(key)})
+	if err != nil {
+		if errors.Is(err, errs.ApiKeyErr.ApiKeyNotFoundError) {
+			return nil, xerrors.Errorf("apiKeyService AuthenticateApiKey getApiKey: %w", errs.ApiKeyErr.ApiKeyInvalidError)
+		}
+		return nil, xerrors.Errorf("apiKeyService AuthenticateApiKey getApiKey: %w", err)
+	}
+
+	now := time.Now()
+	if !apiKey.IsActive(now) {
+		return nil, xerrors.Errorf("apiKeyService AuthenticateApiKey key_id: %d: %w", apiKey.KeyId, errs.ApiKeyErr.ApiKeyInvalidError)
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apikey.LastUsedInterval {
+		// 更新失敗不影響這次請求
+		if err := srv.apiKeyRepo.UpdateApiKey(ctx, srv.DB.Session(), &po.UpdateApiKeyCond{KeyId: apiKey.KeyId}, &po.UpdateApiKeyData{LastUsedAt: &now}); err != nil {
+			srv.logger.Error(ctx, "apiKeyService AuthenticateApiKey apiKeyRepo.UpdateApiKey", err)
+		} else {
+			apiKey.LastUsedAt = &now
+		}
+	}
+
+	return apiKey, nil
+}
+
+func (srv *ApiKeyService) getApiKey(ctx context.Context, cond *po.ApiKeyCond) (*bo.ApiKey, error) {
+	poApiKey, err := srv.apiKeyRepo.GetApiKey(ctx, srv.DB.Session(), cond)
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("apiKeyRepo.GetApiKey: %w", errs.ApiKeyErr.ApiKeyNotFoundError)
+		}
+		return nil, xerrors.Errorf("apiKeyRepo.GetApiKey: %w", err)
+	}
+
+	return toApiKeyBo(poApiKey), nil
+}
+
+// addAuditLog bo.ApiKey 不包含 key 與 hash，可以直接保存
+func (srv *ApiKeyService) addAuditLog(ctx context.Context, action audit.Action, before, after *bo.ApiKey) {
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetApiKey, TargetId: after.KeyId, Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "apiKeyService addAuditLog auditLogSrv.AddAuditLog", err)
+	}
+}
+
+func validateApiKeyData(data *bo.CreateApiKeyData, now time.Time) error {
+	if strings.TrimSpace(data.Name) == "" || len(data.Scopes) == 0 {
+		return errs.ApiKeyErr.InvalidApiKeyError
+	}
+	for _, s := range data.Scopes {
+		if !scope.IsApiKeyScope(s) {
+			return xerrors.Errorf("scope: %s: %w", s, errs.ApiKeyErr.ScopeInvalidError)
+		}
+	}
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(now) {
+		return errs.ApiKeyErr.InvalidApiKeyError
+	}
+
+	return nil
+}
+
+// generateApiKey 產生 KeyPrefix 開頭的隨機字串
+func generateApiKey() (string, error) {
+	b := make([]byte, apikey.KeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", xerrors.Errorf("rand.Read: %w", err)
+	}
+
+	return apikey.KeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashApiKey key 本身是高熵的隨機字串，不需要 bcrypt，用 sha256 即可直接以 hash 查詢
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func toApiKeyBo(poApiKey *po.ApiKey) *bo.ApiKey {
+	scopes := make([]scope.Scope, 0)
+	_ = json.Unmarshal([]byte(poApiKey.Scopes), &scopes)
+
+	return &bo.ApiKey{
+		KeyId:      poApiKey.KeyId,
+		Name:       poApiKey.Name,
+		KeyPrefix:  poApiKey.KeyPrefix,
+		Scopes:     scopes,
+		ExpiresAt:  poApiKey.ExpiresAt,
+		LastUsedAt: poApiKey.LastUsedAt,
+		RevokedAt:  poApiKey.RevokedAt,
+		CreatedBy:  poApiKey.CreatedBy,
+		CreatedAt:  poApiKey.CreatedAt,
+	}
+}
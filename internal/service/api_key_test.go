@@ -0,0 +1,74 @@
+package service
+
+import (
+	"jaystar/internal/constant/apikey"
+	"jaystar/internal/constant/scope"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/errs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateApiKey(t *testing.T) {
+	key1, err := generateApiKey()
+	require.NoError(t, err)
+	key2, err := generateApiKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key1, apikey.KeyPrefix))
+	assert.NotEqual(t, key1, key2)
+	assert.Greater(t, len(key1), apikey.DisplayPrefixLen)
+}
+
+func TestHashApiKey(t *testing.T) {
+	hash := hashApiKey("jsk_test")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, hashApiKey("jsk_test"))
+	assert.NotEqual(t, hash, hashApiKey("jsk_test2"))
+}
+
+func TestValidateApiKeyData(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		data    *bo.CreateApiKeyData
+		wantErr error
+	}{
+		{name: "valid", data: &bo.CreateApiKeyData{Name: "kintone", Scopes: []scope.Scope{scope.SyncWrite}}},
+		{name: "valid with expiry", data: &bo.CreateApiKeyData{Name: "kintone", Scopes: []scope.Scope{scope.SyncWrite}, ExpiresAt: &future}},
+		{name: "empty name", data: &bo.CreateApiKeyData{Name: " ", Scopes: []scope.Scope{scope.SyncWrite}}, wantErr: errs.ApiKeyErr.InvalidApiKeyError},
+		{name: "no scopes", data: &bo.CreateApiKeyData{Name: "kintone"}, wantErr: errs.ApiKeyErr.InvalidApiKeyError},
+		{name: "unknown scope", data: &bo.CreateApiKeyData{Name: "kintone", Scopes: []scope.Scope{"sync:delete"}}, wantErr: errs.ApiKeyErr.ScopeInvalidError},
+		// 管理帳號與 API key 只能由登入的管理者操作
+		{name: "admin scope", data: &bo.CreateApiKeyData{Name: "kintone", Scopes: []scope.Scope{scope.AdminManage}}, wantErr: errs.ApiKeyErr.ScopeInvalidError},
+		{name: "expired", data: &bo.CreateApiKeyData{Name: "kintone", Scopes: []scope.Scope{scope.SyncWrite}, ExpiresAt: &past}, wantErr: errs.ApiKeyErr.InvalidApiKeyError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateApiKeyData(tt.data, now)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestApiKeyIsActive(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	assert.True(t, (&bo.ApiKey{}).IsActive(now))
+	assert.True(t, (&bo.ApiKey{ExpiresAt: &future}).IsActive(now))
+	assert.False(t, (&bo.ApiKey{ExpiresAt: &past}).IsActive(now))
+	assert.False(t, (&bo.ApiKey{RevokedAt: &past}).IsActive(now))
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/ctxUtil"
+)
+
+func ProvideAuditLogService(db database.IPostgresDB, auditLogRepo interfaces.IAuditLogRepo, logger pkgLogger.ILogger) *AuditLogService {
+	return &AuditLogService{
+		DB:           db,
+		auditLogRepo: auditLogRepo,
+		logger:       logger,
+	}
+}
+
+type AuditLogService struct {
+	DB           database.IPostgresDB
+	auditLogRepo interfaces.IAuditLogRepo
+	logger       pkgLogger.ILogger
+}
+
+func (srv *AuditLogService) AddAuditLog(ctx context.Context, data *bo.AddAuditLogData) error {
+	poLog, err := newAuditLogPo(ctx, data)
+	if err != nil {
+		return xerrors.Errorf("auditLogService AddAuditLog newAuditLogPo: %w", err)
+	}
+
+	if err := srv.auditLogRepo.AddAuditLog(ctx, srv.DB.Session(), poLog); err != nil {
+		return xerrors.Errorf("auditLogService AddAuditLog auditLogRepo.AddAuditLog: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *AuditLogService) GetAuditLogs(ctx context.Context, cond *bo.AuditLogCond) ([]*bo.AuditLog, *po.PagerResult, error) {
+	poCond := &po.AuditLogCond{
+		Actor:      cond.Actor,
+		Action:     cond.Action,
+		TargetType: cond.TargetType,
+		TargetId:   cond.TargetId,
+		RequestId:  cond.RequestId,
+		Since:      cond.Since,
+		Until:      cond.Until,
+	}
+	poPager := &po.Pager{
+		Index: cond.Index,
+		Size:  cond.Size,
+		Order: cond.Order,
+	}
+
+	db := srv.DB.Session()
+	poLogs, err := srv.auditLogRepo.GetAuditLogs(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("auditLogService GetAuditLogs auditLogRepo.GetAuditLogs: %w", err)
+	}
+	poPagerResult, err := srv.auditLogRepo.GetAuditLogsPager(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("auditLogService GetAuditLogs auditLogRepo.GetAuditLogsPager: %w", err)
+	}
+
+	boLogs := make([]*bo.AuditLog, 0, len(poLogs))
+	for _, poLog := range poLogs {
+		boLogs = append(boLogs, &bo.AuditLog{
+			LogId:      poLog.LogId,
+			Actor:      poLog.Actor,
+			Action:     poLog.Action,
+			TargetType: poLog.TargetType,
+			TargetId:   poLog.TargetId,
+			Before:     poLog.Before,
+			After:      poLog.After,
+			RequestId:  poLog.RequestId,
+			CreatedAt:  poLog.CreatedAt,
+		})
+	}
+
+	return boLogs, poPagerResult, nil
+}
+
+// newAuditLogPo 操作人與 request id 由 ctx 取得，沒有操作人時為 system
+func newAuditLogPo(ctx context.Context, data *bo.AddAuditLogData) (*po.AuditLog, error) {
+	logId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+	before, err := auditSnapshot(data.Before)
+	if err != nil {
+		return nil, xerrors.Errorf("auditSnapshot before: %w", err)
+	}
+	after, err := auditSnapshot(data.After)
+	if err != nil {
+		return nil, xerrors.Errorf("auditSnapshot after: %w", err)
+	}
+
+	actor := ctxUtil.GetOperatorFromCtx(ctx)
+	if actor == "" {
+		actor = audit.ActorSystem
+	}
+	requestId, _ := ctx.Value(pkgLogger.CtxActionIdKey).(string)
+
+	return &po.AuditLog{
+		LogId:      logId,
+		Actor:      actor,
+		Action:     data.Action,
+		TargetType: data.TargetType,
+		TargetId:   data.TargetId,
+		Before:     before,
+		After:      after,
+		RequestId:  requestId,
+	}, nil
+}
+
+// auditSnapshot nil 或 nil pointer 時不保存
+func auditSnapshot(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, xerrors.Errorf("json.Marshal: %w", err)
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	snapshot := string(b)
+
+	return &snapshot, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"jaystar/internal/constant/audit"
+	contextKey "jaystar/internal/constant/context"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/ctxUtil"
+	"testing"
+
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuditLogPoActor(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantActor string
+	}{
+		{name: "no operator", ctx: context.Background(), wantActor: audit.ActorSystem},
+		{name: "admin", ctx: context.WithValue(context.Background(), contextKey.UserSession, auth.UserSession{Account: "alice"}), wantActor: "alice"},
+		{name: "api key", ctx: context.WithValue(context.Background(), contextKey.ApiKey, &bo.ApiKey{Name: "kintone"}), wantActor: "api_key:kintone"},
+		{name: "webhook event", ctx: ctxUtil.WithActor(context.Background(), "webhook_event:10"), wantActor: "webhook_event:10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poLog, err := newAuditLogPo(tt.ctx, &bo.AddAuditLogData{Action: audit.ActionUserUpdate, TargetType: audit.TargetUser, TargetId: 1})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantActor, poLog.Actor)
+		})
+	}
+}
+
+func TestNewAuditLogPoSnapshot(t *testing.T) {
+	ctx := context.WithValue(context.Background(), pkgLogger.CtxActionIdKey, "req-1")
+	var before *bo.Semester
+	after := &bo.Semester{SemesterId: 1, Name: "2026 上學期"}
+
+	poLog, err := newAuditLogPo(ctx, &bo.AddAuditLogData{
+		Action:     audit.ActionSemesterCreate,
+		TargetType: audit.TargetSemester,
+		TargetId:   1,
+		Before:     before,
+		After:      after,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "req-1", poLog.RequestId)
+	assert.Equal(t, audit.ActionSemesterCreate, poLog.Action)
+	assert.Equal(t, int64(1), poLog.TargetId)
+	// 新增時沒有異動前的資料
+	assert.Nil(t, poLog.Before)
+	require.NotNil(t, poLog.After)
+	assert.Contains(t, *poLog.After, `"Name":"2026 上學期"`)
+}
+
+func TestUserAuditSnapshot(t *testing.T) {
+	boUser := &bo.User{UserId: 1, Account: "alice", Password: "$2a$10$hash", Role: user.RoleFinance}
+
+	snapshot := userAuditSnapshot(boUser, false)
+	assert.Empty(t, snapshot.Password)
+	assert.Equal(t, user.RoleFinance, snapshot.Role)
+	// 不會改到原本的資料
+	assert.Equal(t, "$2a$10$hash", boUser.Password)
+
+	assert.Equal(t, audit.RedactedValue, userAuditSnapshot(boUser, true).Password)
+}
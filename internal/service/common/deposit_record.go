@@ -3,10 +3,10 @@ package common
 import (
 	"context"
 	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
-	"jaystar/internal/utils/errs"
 	"strconv"
 )
 
@@ -44,62 +44,54 @@ func (srv *DepositRecordCommonService) GetKintoneDepositRecords(ctx context.Cont
 	return boDepositRecords, int(total), nil
 }
 
-func (srv *DepositRecordCommonService) UpdateKintoneDepositRecordsStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error {
-	var (
-		limit  = 500
-		offset = 0
-		total  int
-		err    error
-	)
-
-	getReq := &dto.DepositRecordReq{
-		StudentName: oldPointCardName,
-		Limit:       limit,
-		Offset:      offset * limit,
-	}
-
-	depositRecords, total, err := srv.GetKintoneDepositRecords(ctx, getReq)
+// GetAllKintoneDepositRecords 以 cursor 取得符合條件的全部資料，不受 offset 上限限制
+func (srv *DepositRecordCommonService) GetAllKintoneDepositRecords(ctx context.Context, cond *dto.DepositRecordReq) (records []*bo.KintoneDepositRecord, err error) {
+	cursor, err := srv.kintoneDepositRecordRepo.GetKintoneDepositRecordsCursor(ctx, cond)
 	if err != nil {
-		return xerrors.Errorf("GetKintoneDepositRecords: %w", err)
+		return nil, xerrors.Errorf("kintoneDepositRecordRepo.GetKintoneDepositRecordsCursor: %w", err)
 	}
-
-	allRecords := make([]*bo.KintoneDepositRecord, 0, total)
-	allRecords = append(allRecords, depositRecords...)
-
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		getReq.Offset = offset * limit
-		depositRecords, total, err = srv.GetKintoneDepositRecords(ctx, getReq)
-		if err != nil {
-			return xerrors.Errorf("GetKintoneDepositRecords: %w", err)
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("cursor.Close: %w", closeErr)
+		}
+	}()
+
+	boDepositRecords := make([]*bo.KintoneDepositRecord, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			boRecord, err := record.ToKintoneDepositRecordBo()
+			if err != nil {
+				return nil, xerrors.Errorf("record.ToKintoneDepositRecordBo: %w", err)
+			}
+			boDepositRecords = append(boDepositRecords, boRecord)
 		}
-		allRecords = append(allRecords, depositRecords...)
 	}
-
-	if len(allRecords) == 0 {
-		return errs.KintoneErr.ResponseEmptyError
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("cursor.Next: %w", err)
 	}
 
-	for i := 0; i < len(allRecords); i += 100 {
-		end := i + 100
-		if end > len(allRecords) {
-			end = len(allRecords)
-		}
+	return boDepositRecords, nil
+}
 
-		updateReq := &dto.UpdateDepositRecordsReq{}
-		batchRecords := allRecords[i:end]
-		updateReq.Records = make([]dto.UpdateDepositRecord, 0, len(batchRecords))
-		for _, record := range batchRecords {
-			updateDepositRecord := dto.UpdateDepositRecord{}
-			updateDepositRecord.Id = record.Id
-			updateDepositRecord.Record.StudentName.Value = newPointCardName
-			updateReq.Records = append(updateReq.Records, updateDepositRecord)
-		}
-		_, err = srv.kintoneDepositRecordRepo.UpdateKintoneDepositRecords(ctx, updateReq)
-		if err != nil {
-			return xerrors.Errorf("kintoneDepositRecordRepo.UpdateKintoneDepositRecords: %w", err)
-		}
+// GetKintoneDepositRecordsStudentNameUpdates 產生學生名稱改名 (forward) 與還原 (backward) 的更新內容，forward 帶上讀取到的 revision
+func (srv *DepositRecordCommonService) GetKintoneDepositRecordsStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error) {
+	allRecords, err := srv.GetAllKintoneDepositRecords(ctx, &dto.DepositRecordReq{StudentName: oldPointCardName})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("GetAllKintoneDepositRecords: %w", err)
+	}
+
+	forward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	backward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	for _, record := range allRecords {
+		forward = append(forward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: record.Id, Revision: record.Revision},
+			Record:              dto.UpdateDepositRecordValue{StudentName: dto.NormalField{Value: newPointCardName}},
+		})
+		backward = append(backward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: record.Id},
+			Record:              dto.UpdateDepositRecordValue{StudentName: dto.NormalField{Value: oldPointCardName}},
+		})
 	}
 
-	return nil
+	return dto.NewKintoneBulkUpdateRecords(kintone.AppDepositRecord, forward), dto.NewKintoneBulkUpdateRecords(kintone.AppDepositRecord, backward), nil
 }
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"context"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils"
+	"strconv"
+	"time"
+)
+
+func ProvideRecordLockCommonService(db database.IPostgresDB, webhookEventRepo interfaces.IWebhookEventRepo) *RecordLockCommonService {
+	return &RecordLockCommonService{
+		DB:               db,
+		webhookEventRepo: webhookEventRepo,
+		locks:            utils.NewKeyedMutex(),
+		dbLocks:          make(chan struct{}, webhook.MaxRecordLocks),
+	}
+}
+
+type RecordLockCommonService struct {
+	DB               database.IPostgresDB
+	webhookEventRepo interfaces.IWebhookEventRepo
+	locks            *utils.KeyedMutex `wire:"-"`
+	dbLocks          chan struct{}     `wire:"-"`
+}
+
+// LockRecord 同一個應用程式的同一筆記錄，webhook 與 BatchSync 依序處理
+// 同一個實例內先以 KeyedMutex 排隊，避免等待中的 goroutine 各自佔用一個 db 連線
+// 不同實例之間以 advisory lock 排隊，fn 不在鎖的 transaction 中執行
+func (srv *RecordLockCommonService) LockRecord(ctx context.Context, source webhook.Source, recordRefId string, fn func() error) error {
+	unlock := srv.locks.Lock(string(source) + ":" + recordRefId)
+	defer unlock()
+
+	// 持有鎖的 transaction 佔用連線，限制數量避免 fn 拿不到連線
+	srv.dbLocks <- struct{}{}
+	defer func() { <-srv.dbLocks }()
+
+	tx := srv.DB.Session().Begin()
+	if err := tx.Error; err != nil {
+		return xerrors.Errorf("recordLockCommonService LockRecord Begin: %w", err)
+	}
+	// transaction 只用來持有 advisory lock，結束時 rollback 即可釋放
+	defer tx.Rollback()
+
+	if err := srv.webhookEventRepo.LockRecord(ctx, tx, source, recordRefId); err != nil {
+		return xerrors.Errorf("recordLockCommonService LockRecord webhookEventRepo.LockRecord: %w", err)
+	}
+
+	return fn()
+}
+
+// GetPendingRecords BatchSync 取得 kintone 資料後呼叫一次，取得這次同步要交給 webhook 處理的記錄
+// 之後才收到的 webhook 若先於 BatchSync 處理，記錄會被快照蓋過，下一次增量同步會再以 kintone 的資料更新
+func (srv *RecordLockCommonService) GetPendingRecords(ctx context.Context, source webhook.Source, snapshotAt time.Time) (*bo.PendingRecords, error) {
+	recordIds, err := srv.webhookEventRepo.GetRecordIdsWithEventsSince(ctx, srv.DB.Session(), &po.RecordWebhookEventsCond{
+		Source:        source,
+		ReceivedSince: snapshotAt,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("recordLockCommonService GetPendingRecords webhookEventRepo.GetRecordIdsWithEventsSince: %w", err)
+	}
+
+	pendingRecords := &bo.PendingRecords{Source: source, RecordIds: make(map[string]struct{}, len(recordIds))}
+	for _, recordId := range recordIds {
+		pendingRecords.RecordIds[recordId] = struct{}{}
+	}
+
+	return pendingRecords, nil
+}
+
+// SyncRecord BatchSync 以快照的 kintone 資料更新一筆記錄
+// 這筆記錄在 pendingRecords 中時，快照可能比 db 舊，交給 webhook 處理
+func (srv *RecordLockCommonService) SyncRecord(ctx context.Context, pendingRecords *bo.PendingRecords, recordRefId int, fn func() error) error {
+	recordId := strconv.Itoa(recordRefId)
+	if _, found := pendingRecords.RecordIds[recordId]; found {
+		return nil
+	}
+
+	return srv.LockRecord(ctx, pendingRecords.Source, recordId, fn)
+}
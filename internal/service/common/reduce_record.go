@@ -3,10 +3,10 @@ package common
 import (
 	"context"
 	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
-	"jaystar/internal/utils/errs"
 	"strconv"
 )
 
@@ -44,61 +44,54 @@ func (srv *ReduceRecordCommonService) GetKintoneReduceRecords(ctx context.Contex
 	return boReduceRecords, int(total), nil
 }
 
-func (srv *ReduceRecordCommonService) UpdateKintoneReduceRecordsStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error {
-	var (
-		limit  = 500
-		offset = 0
-		total  int
-		err    error
-	)
-
-	getReq := &dto.ReduceRecordReq{
-		StudentName: oldPointCardName,
-		Limit:       limit,
-		Offset:      offset,
-	}
-	reduceRecordRes, total, err := srv.GetKintoneReduceRecords(ctx, getReq)
+// GetAllKintoneReduceRecords 以 cursor 取得符合條件的全部資料，不受 offset 上限限制
+func (srv *ReduceRecordCommonService) GetAllKintoneReduceRecords(ctx context.Context, cond *dto.ReduceRecordReq) (records []*bo.KintoneReduceRecord, err error) {
+	cursor, err := srv.kintoneReduceRecordRepo.GetKintoneReduceRecordsCursor(ctx, cond)
 	if err != nil {
-		return xerrors.Errorf("GetKintoneReduceRecords: %w", err)
+		return nil, xerrors.Errorf("kintoneReduceRecordRepo.GetKintoneReduceRecordsCursor: %w", err)
 	}
-
-	allRecords := make([]*bo.KintoneReduceRecord, 0, total)
-	allRecords = append(allRecords, reduceRecordRes...)
-
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		getReq.Offset = offset * limit
-		reduceRecordRes, total, err = srv.GetKintoneReduceRecords(ctx, getReq)
-		if err != nil {
-			return xerrors.Errorf("GetKintoneReduceRecords: %w", err)
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("cursor.Close: %w", closeErr)
+		}
+	}()
+
+	boReduceRecords := make([]*bo.KintoneReduceRecord, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			boRecord, err := record.ToKintoneReduceRecord()
+			if err != nil {
+				return nil, xerrors.Errorf("record.ToKintoneReduceRecord: %w", err)
+			}
+			boReduceRecords = append(boReduceRecords, boRecord)
 		}
-		allRecords = append(allRecords, reduceRecordRes...)
 	}
-
-	if len(allRecords) == 0 {
-		return errs.KintoneErr.ResponseEmptyError
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("cursor.Next: %w", err)
 	}
 
-	for i := 0; i < len(allRecords); i += 100 {
-		end := i + 100
-		if end > len(allRecords) {
-			end = len(allRecords)
-		}
+	return boReduceRecords, nil
+}
 
-		updateReq := &dto.UpdateReduceRecordsReq{}
-		batchRecords := allRecords[i:end]
-		updateReq.Records = make([]dto.UpdateReduceRecord, 0, len(batchRecords))
-		for _, record := range batchRecords {
-			updateReduceRecord := dto.UpdateReduceRecord{}
-			updateReduceRecord.Id = record.Id
-			updateReduceRecord.Record.StudentName.Value = newPointCardName
-			updateReq.Records = append(updateReq.Records, updateReduceRecord)
-		}
-		_, err = srv.kintoneReduceRecordRepo.UpdateKintoneReduceRecords(ctx, updateReq)
-		if err != nil {
-			return xerrors.Errorf("kintoneReduceRecordRepo.UpdateKintoneReduceRecords: %w", err)
-		}
+// GetKintoneReduceRecordsStudentNameUpdates 產生學生名稱改名 (forward) 與還原 (backward) 的更新內容，forward 帶上讀取到的 revision
+func (srv *ReduceRecordCommonService) GetKintoneReduceRecordsStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error) {
+	allRecords, err := srv.GetAllKintoneReduceRecords(ctx, &dto.ReduceRecordReq{StudentName: oldPointCardName})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("GetAllKintoneReduceRecords: %w", err)
+	}
+
+	forward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	backward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	for _, record := range allRecords {
+		forward = append(forward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: record.Id, Revision: record.Revision},
+			Record:              dto.UpdateReduceRecordValue{StudentName: dto.NormalField{Value: newPointCardName}},
+		})
+		backward = append(backward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: record.Id},
+			Record:              dto.UpdateReduceRecordValue{StudentName: dto.NormalField{Value: oldPointCardName}},
+		})
 	}
 
-	return nil
+	return dto.NewKintoneBulkUpdateRecords(kintone.AppReduceRecord, forward), dto.NewKintoneBulkUpdateRecords(kintone.AppReduceRecord, backward), nil
 }
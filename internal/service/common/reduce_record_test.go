@@ -0,0 +1,72 @@
+package common
+
+import (
+	"context"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/repository"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReduceRecordCommonService(t *testing.T) (*ReduceRecordCommonService, *kintoneFake.Server) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	cfg := server.ConfigEnv()
+	kc := kintoneAPI.ProvideKintoneClient(cfg, logger.ProviderILogger(cfg))
+	return ProvideReduceRecordCommonService(repository.ProvideKintoneReduceRecordRepository(cfg, kc)), server
+}
+
+func TestGetKintoneReduceRecordsStudentNameUpdates(t *testing.T) {
+	const newName = kintoneFake.StudentARenamed
+
+	t.Run("forward with revision and backward without", func(t *testing.T) {
+		srv, _ := newReduceRecordCommonService(t)
+
+		forward, backward, err := srv.GetKintoneReduceRecordsStudentNameUpdates(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		require.Len(t, forward, 1)
+		require.Len(t, backward, 1)
+		assert.Equal(t, kintone.AppReduceRecord, forward[0].App)
+		require.Len(t, forward[0].Records, 3)
+
+		for i, record := range forward[0].Records {
+			assert.Equal(t, 1, record.Revision)
+			assert.Equal(t, newName, record.Record.(dto.UpdateReduceRecordValue).StudentName.Value)
+			assert.Equal(t, record.Id, backward[0].Records[i].Id)
+			assert.Zero(t, backward[0].Records[i].Revision)
+			assert.Equal(t, kintoneFake.StudentA, backward[0].Records[i].Record.(dto.UpdateReduceRecordValue).StudentName.Value)
+		}
+	})
+
+	t.Run("split by update records limit", func(t *testing.T) {
+		srv, server := newReduceRecordCommonService(t)
+		base, ok := server.GetRecord(kintoneFake.AppId.ReduceRecord, 23441)
+		require.True(t, ok)
+		delete(base, "$id")
+		for i := 0; i < kintone.BatchUpdateRecordsMaxLimit; i++ {
+			server.AddRecord(kintoneFake.AppId.ReduceRecord, base)
+		}
+
+		forward, backward, err := srv.GetKintoneReduceRecordsStudentNameUpdates(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		require.Len(t, forward, 2)
+		require.Len(t, backward, 2)
+		assert.Len(t, forward[0].Records, kintone.BatchUpdateRecordsMaxLimit)
+		assert.Len(t, forward[1].Records, 3)
+	})
+
+	t.Run("no records", func(t *testing.T) {
+		srv, _ := newReduceRecordCommonService(t)
+
+		forward, backward, err := srv.GetKintoneReduceRecordsStudentNameUpdates(context.TODO(), "不存在/0900000000", newName)
+		require.NoError(t, err)
+		assert.Empty(t, forward)
+		assert.Empty(t, backward)
+	})
+}
@@ -3,10 +3,9 @@ package common
 import (
 	"context"
 	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/dto"
-	"jaystar/internal/utils/errs"
-	"slices"
 	"strconv"
 )
 
@@ -34,72 +33,67 @@ func (srv *ScheduleCommonService) GetKintoneSchedules(ctx context.Context, cond
 	return dtoScheduleRes.Records, int(total), nil
 }
 
-func (srv *ScheduleCommonService) UpdateKintoneSchedulesStudentNames(ctx context.Context, oldPointCardName string, newPointCardName string) error {
-	var (
-		limit  = 500
-		offset = 0
-		total  int
-		err    error
-	)
-
-	getReq := &dto.ScheduleReq{
-		StudentName: oldPointCardName,
-		Limit:       limit,
-		Offset:      offset,
-	}
-	schedules, total, err := srv.GetKintoneSchedules(ctx, getReq)
+// GetAllKintoneSchedules 以 cursor 取得符合條件的全部資料，不受 offset 上限限制
+func (srv *ScheduleCommonService) GetAllKintoneSchedules(ctx context.Context, cond *dto.ScheduleReq) (records []dto.ScheduleRecord, err error) {
+	cursor, err := srv.kintoneScheduleRepo.GetKintoneSchedulesCursor(ctx, cond)
 	if err != nil {
-		return xerrors.Errorf("GetKintoneSchedules: %w", err)
+		return nil, xerrors.Errorf("kintoneScheduleRepo.GetKintoneSchedulesCursor: %w", err)
 	}
-
-	allRecords := make([]dto.ScheduleRecord, 0, total)
-	allRecords = append(allRecords, schedules...)
-
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		getReq.Offset = offset * limit
-		schedules, total, err = srv.GetKintoneSchedules(ctx, getReq)
-		if err != nil {
-			return xerrors.Errorf("GetKintoneSchedules: %w", err)
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("cursor.Close: %w", closeErr)
 		}
-		allRecords = append(allRecords, schedules...)
-	}
+	}()
 
-	if len(allRecords) == 0 {
-		return errs.KintoneErr.ResponseEmptyError
+	allRecords := make([]dto.ScheduleRecord, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		allRecords = append(allRecords, cursor.Records()...)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("cursor.Next: %w", err)
 	}
 
-	for i := 0; i < len(allRecords); i += 100 {
-		end := i + 100
-		if end > len(allRecords) {
-			end = len(allRecords)
-		}
-
-		updateReq := &dto.UpdateSchedulesReq{}
-		batchRecords := allRecords[i:end]
-		updateReq.Records = make([]dto.UpdateSchedule, 0, len(batchRecords))
-		for _, record := range batchRecords {
-			updateSchedule := dto.UpdateSchedule{}
-			id, err := record.Id.ToId()
-			if err != nil {
-				return xerrors.Errorf("record.Id.ToId student: %s, id: %s, err: %w", oldPointCardName, record.Id.Value, err)
-			}
-			updateSchedule.Id = id
-			updateSchedule.Record = dto.UpdateScheduleValue{}
-			updateSchedule.Record.Attendance = record.Attendance
-			index := slices.IndexFunc(updateSchedule.Record.Attendance.Value, func(attendanceValue *dto.AttendanceValue) bool {
-				return attendanceValue.Value.StudentName.ToString() == oldPointCardName
-			})
+	return allRecords, nil
+}
 
-			updateSchedule.Record.Attendance.Value[index].Value.StudentName.Value = newPointCardName
+// GetKintoneSchedulesStudentNameUpdates 產生點名子表格中學生名稱改名 (forward) 與還原 (backward) 的更新內容，forward 帶上讀取到的 revision
+func (srv *ScheduleCommonService) GetKintoneSchedulesStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error) {
+	allRecords, err := srv.GetAllKintoneSchedules(ctx, &dto.ScheduleReq{StudentNames: []string{oldPointCardName}})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("GetAllKintoneSchedules: %w", err)
+	}
 
-			updateReq.Records = append(updateReq.Records, updateSchedule)
+	forward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	backward := make([]dto.KintoneUpdateRecord, 0, len(allRecords))
+	for _, record := range allRecords {
+		id, err := record.Id.ToId()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("record.Id.ToId student: %s, id: %s, err: %w", oldPointCardName, record.Id.Value, err)
 		}
-		_, err = srv.kintoneScheduleRepo.UpdateKintoneSchedules(ctx, updateReq)
+		revision, err := record.Revision.ToInt()
 		if err != nil {
-			return xerrors.Errorf("kintoneScheduleRepo.UpdateKintoneSchedules: %w", err)
+			return nil, nil, xerrors.Errorf("record.Revision.ToInt student: %s, id: %s, err: %w", oldPointCardName, record.Id.Value, err)
 		}
+
+		// 子表格整個覆寫，複製每一列避免改到 backward 使用的原始資料
+		attendance := dto.Attendance{Type: record.Attendance.Type, Value: make([]*dto.AttendanceValue, 0, len(record.Attendance.Value))}
+		for _, attendanceValue := range record.Attendance.Value {
+			renamed := *attendanceValue
+			if renamed.Value.StudentName.ToString() == oldPointCardName {
+				renamed.Value.StudentName.Value = newPointCardName
+			}
+			attendance.Value = append(attendance.Value, &renamed)
+		}
+
+		forward = append(forward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: id, Revision: revision},
+			Record:              dto.UpdateScheduleValue{Attendance: attendance},
+		})
+		backward = append(backward, dto.KintoneUpdateRecord{
+			KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: id},
+			Record:              dto.UpdateScheduleValue{Attendance: record.Attendance},
+		})
 	}
 
-	return nil
+	return dto.NewKintoneBulkUpdateRecords(kintone.AppScheduleRecord, forward), dto.NewKintoneBulkUpdateRecords(kintone.AppScheduleRecord, backward), nil
 }
@@ -59,6 +59,35 @@ func (srv *StudentCommonService) GetKintoneStudents(ctx context.Context, cond *d
 	return boStudents, int(total), nil
 }
 
+// GetAllKintoneStudents 以 cursor 取得符合條件的全部資料，不受 offset 上限限制
+func (srv *StudentCommonService) GetAllKintoneStudents(ctx context.Context, cond *dto.StudentReq) (records []*bo.Student, err error) {
+	cursor, err := srv.kintoneStudentRepo.GetKintoneStudentsCursor(ctx, cond)
+	if err != nil {
+		return nil, xerrors.Errorf("studentCommonService GetAllKintoneStudents kintoneStudentRepo.GetKintoneStudentsCursor: %w", err)
+	}
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("studentCommonService GetAllKintoneStudents cursor.Close: %w", closeErr)
+		}
+	}()
+
+	boStudents := make([]*bo.Student, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			boRecord, err := record.ToStudent()
+			if err != nil {
+				return nil, xerrors.Errorf("studentCommonService GetAllKintoneStudents record.ToStudent: %w", err)
+			}
+			boStudents = append(boStudents, boRecord)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("studentCommonService GetAllKintoneStudents cursor.Next: %w", err)
+	}
+
+	return boStudents, nil
+}
+
 func (srv *StudentCommonService) GetStudent(ctx context.Context, cond *bo.StudentCond) (*bo.Student, error) {
 	if reflect.ValueOf(cond).Elem().IsZero() {
 		return nil, errs.CommonErr.RequestParamError
@@ -9,27 +9,32 @@ import (
 	"github.com/forgoer/openssl"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/xerrors"
+	"gorm.io/gorm"
 	"io"
+	"jaystar/internal/constant/loginsession"
 	"jaystar/internal/constant/user"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/po"
 	"jaystar/internal/utils/errs"
+	"time"
 )
 
-func ProvideUserCommonService(db database.IPostgresDB, userRepo interfaces.IUserRepo, studentRepo interfaces.IStudentRepo) *UserCommonService {
+func ProvideUserCommonService(db database.IPostgresDB, userRepo interfaces.IUserRepo, studentRepo interfaces.IStudentRepo, loginSessionRepo interfaces.ILoginSessionRepo) *UserCommonService {
 	return &UserCommonService{
-		DB:          db,
-		userRepo:    userRepo,
-		studentRepo: studentRepo,
+		DB:               db,
+		userRepo:         userRepo,
+		studentRepo:      studentRepo,
+		loginSessionRepo: loginSessionRepo,
 	}
 }
 
 type UserCommonService struct {
-	DB          database.IPostgresDB
-	userRepo    interfaces.IUserRepo
-	studentRepo interfaces.IStudentRepo
+	DB               database.IPostgresDB
+	userRepo         interfaces.IUserRepo
+	studentRepo      interfaces.IStudentRepo
+	loginSessionRepo interfaces.ILoginSessionRepo
 }
 
 func (srv *UserCommonService) GetUser(ctx context.Context, cond *bo.UserCond) (*bo.User, error) {
@@ -47,6 +52,10 @@ func (srv *UserCommonService) GetUser(ctx context.Context, cond *bo.UserCond) (*
 		poUserCond.Status = cond.Status.ToKey()
 	}
 
+	if cond.Level != user.LevelNone {
+		poUserCond.Level = cond.Level.ToKey()
+	}
+
 	poUserCond.IsChangedPassword = cond.IsChangedPassword
 
 	db := srv.DB.Session()
@@ -61,6 +70,7 @@ func (srv *UserCommonService) GetUser(ctx context.Context, cond *bo.UserCond) (*
 		Password:          poUser.Password,
 		Status:            user.UserStatusToEnum(poUser.Status),
 		Level:             user.UserLevelToEnum(poUser.Level),
+		Role:              user.AdminRoleToEnum(poUser.Role),
 		IsChangedPassword: poUser.IsChangedPassword,
 		CreatedAt:         poUser.CreatedAt,
 		UpdatedAt:         poUser.UpdatedAt,
@@ -83,6 +93,27 @@ func (srv *UserCommonService) CreateUser(ctx context.Context, data *bo.CreateUse
 	return poUserData.UserId, nil
 }
 
+// GetOrCreateUser 以 data.Account 取得使用者，不存在時新增，db 可以是呼叫端的 transaction
+func (srv *UserCommonService) GetOrCreateUser(ctx context.Context, db *gorm.DB, data *bo.CreateUserData) (int64, error) {
+	poUser, err := srv.userRepo.GetUser(ctx, db, &po.UserCond{Accounts: []string{data.Account}})
+	if err == nil {
+		return poUser.UserId, nil
+	}
+	if !errors.Is(err, errs.DbErr.NoRow) {
+		return 0, xerrors.Errorf("userCommonService GetOrCreateUser userRepo.GetUser: %w", err)
+	}
+
+	poUserData, err := srv.genCreateUserData(data)
+	if err != nil {
+		return 0, xerrors.Errorf("userCommonService GetOrCreateUser genCreateUserData: %w", err)
+	}
+	if err := srv.userRepo.CreateUser(ctx, db, poUserData); err != nil {
+		return 0, xerrors.Errorf("userCommonService GetOrCreateUser userRepo.CreateUser: %w", err)
+	}
+
+	return poUserData.UserId, nil
+}
+
 func (srv *UserCommonService) CreateUserAndStudent(ctx context.Context, data *bo.CreateUserData, studentData *bo.Student) error {
 	if data.Account == "" || data.Password == "" {
 		return errs.UserErr.AccountOrPasswordInvalidErr
@@ -210,9 +241,33 @@ func (srv *UserCommonService) UserLogin(ctx context.Context, cond *bo.UserLoginC
 	return poUser, nil
 }
 
+// DeactivateUserWithNoActiveStudent 停用帳號的同時撤銷這些帳號所有的登入
 func (srv *UserCommonService) DeactivateUserWithNoActiveStudent(ctx context.Context) ([]*po.User, error) {
-	db := srv.DB.Session()
-	return srv.userRepo.DeactivateUserWithNoActiveStudent(ctx, db)
+	tx := srv.DB.Session().Begin()
+	updatedUsers, err := srv.userRepo.DeactivateUserWithNoActiveStudent(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, xerrors.Errorf("userRepo.DeactivateUserWithNoActiveStudent: %w", err)
+	}
+
+	if len(updatedUsers) > 0 {
+		userIds := make([]int64, 0, len(updatedUsers))
+		for _, u := range updatedUsers {
+			userIds = append(userIds, u.UserId)
+		}
+		now := time.Now()
+		data := &po.UpdateLoginSessionData{RevokedAt: &now, RevokedReason: string(loginsession.ReasonDeactivated)}
+		if err := srv.loginSessionRepo.UpdateLoginSessions(ctx, tx, &po.UpdateLoginSessionCond{UserIds: userIds}, data); err != nil {
+			tx.Rollback()
+			return nil, xerrors.Errorf("loginSessionRepo.UpdateLoginSessions: %w", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, xerrors.Errorf("tx.Commit: %w", err)
+	}
+
+	return updatedUsers, nil
 }
 
 func (srv *UserCommonService) getDecryptedPwd(password string) ([]byte, error) {
@@ -267,12 +322,18 @@ func (srv *UserCommonService) genCreateUserData(data *bo.CreateUserData) (*po.Us
 		return nil, xerrors.Errorf("userCommonService genCreateUserData getHashedPasswordFromEncrypted: %w", err)
 	}
 
+	level := data.Level
+	if level == user.LevelNone {
+		level = user.User
+	}
+
 	poUserData := &po.User{
 		UserId:            userId,
 		Account:           data.Account,
 		Password:          hashedPwd,
 		Status:            user.Activate.ToKey(),
-		Level:             user.User.ToKey(),
+		Level:             level.ToKey(),
+		Role:              data.Role.ToKey(),
 		IsChangedPassword: false,
 	}
 
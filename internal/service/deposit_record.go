@@ -9,8 +9,10 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
+	"jaystar/internal/constant/audit"
 	"jaystar/internal/constant/kintone"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
@@ -30,6 +32,9 @@ func ProvideDepositRecordService(
 	studentCommonSrv interfaces.IStudentCommonSrv,
 	logger logger.ILogger,
 	depositRecordCommonSrv interfaces.IDepositRecordCommonSrv,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
+	auditLogSrv interfaces.IAuditLogSrv,
 ) *DepositRecordService {
 	return &DepositRecordService{
 		recordRepo:             depositRecordRepo,
@@ -37,6 +42,9 @@ func ProvideDepositRecordService(
 		studentCommonSrv:       studentCommonSrv,
 		logger:                 logger,
 		depositRecordCommonSrv: depositRecordCommonSrv,
+		recordLockCommonSrv:    recordLockCommonSrv,
+		kintoneRecordRepo:      kintoneRecordRepo,
+		auditLogSrv:            auditLogSrv,
 		executorPool:           pool.NewExecutorPool(50),
 	}
 }
@@ -47,6 +55,9 @@ type DepositRecordService struct {
 	studentCommonSrv       interfaces.IStudentCommonSrv
 	logger                 logger.ILogger
 	depositRecordCommonSrv interfaces.IDepositRecordCommonSrv
+	recordLockCommonSrv    interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo      interfaces.IKintoneRecordRepo
+	auditLogSrv            interfaces.IAuditLogSrv
 	executorPool           *ants.Pool `wire:"-"`
 }
 
@@ -197,7 +208,22 @@ func (srv *DepositRecordService) UpdateDepositRecord(ctx context.Context, cond *
 	return nil
 }
 
+// DeleteDepositRecord 處理 kintone 刪除記錄的 webhook，並記錄稽核記錄
 func (srv *DepositRecordService) DeleteDepositRecord(ctx context.Context, cond *bo.DepositRecordCond) error {
+	before, err := srv.getDepositRecordSnapshot(ctx, cond.RecordRefId)
+	if err != nil {
+		return xerrors.Errorf("depositRecordService DeleteDepositRecord getDepositRecordSnapshot: %w", err)
+	}
+
+	if err := srv.deleteDepositRecord(ctx, cond); err != nil {
+		return xerrors.Errorf("depositRecordService DeleteDepositRecord deleteDepositRecord: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionDepositRecordDelete, cond.RecordRefId, before)
+
+	return nil
+}
+
+func (srv *DepositRecordService) deleteDepositRecord(ctx context.Context, cond *bo.DepositRecordCond) error {
 	deleted := true
 	poDepositRecordCond := &po.DepositRecordCond{
 		RecordRefId: cond.RecordRefId,
@@ -216,53 +242,28 @@ func (srv *DepositRecordService) DeleteDepositRecord(ctx context.Context, cond *
 	return nil
 }
 
-func (srv *DepositRecordService) BatchSyncDepositRecord(ctx context.Context, cond *bo.SyncDepositRecordCond, wg ...*sync.WaitGroup) error {
+func (srv *DepositRecordService) BatchSyncDepositRecord(ctx context.Context, cond *bo.SyncDepositRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error {
 	boDepositRecordReq := &dto.DepositRecordReq{}
 
-	var (
-		total                 int
-		err                   error
-		kintoneDepositRecords []*bo.KintoneDepositRecord
-	)
-
-	boDepositRecordReq.Limit = request.GetRecordsBatchLimit
-	boDepositRecordReq.Offset = request.GetRecordsBatchOffset
-
 	if cond != nil {
 		if cond.StudentName != nil && cond.ParentPhone != nil {
 			boDepositRecordReq.StudentName = strUtil.GetFullStudentName(*cond.StudentName, *cond.ParentPhone)
 		}
-		if cond.Limit != nil {
-			boDepositRecordReq.Limit = *cond.Limit
-		}
-		if cond.Offset != nil {
-			boDepositRecordReq.Offset = *cond.Offset
-		}
 		if cond.ChargingDateStart != nil {
 			boDepositRecordReq.ChargingDateStart = *cond.ChargingDateStart
 		}
 		if cond.ChargingDateEnd != nil {
 			boDepositRecordReq.ChargingDateEnd = *cond.ChargingDateEnd
 		}
+		if cond.UpdatedSince != nil {
+			boDepositRecordReq.UpdatedSince = *cond.UpdatedSince
+		}
 	}
 
-	kintoneDepositRecords, total, err = srv.depositRecordCommonSrv.GetKintoneDepositRecords(ctx, boDepositRecordReq)
+	snapshotAt := time.Now()
+	allRecords, err := srv.depositRecordCommonSrv.GetAllKintoneDepositRecords(ctx, boDepositRecordReq)
 	if err != nil {
-		return xerrors.Errorf("depositRecordService BatchSyncDepositRecord GetKintoneDepositRecord: %w", err)
-	}
-
-	allRecords := make([]*bo.KintoneDepositRecord, 0, total)
-	allRecords = append(allRecords, kintoneDepositRecords...)
-
-	offset, limit := boDepositRecordReq.Offset, boDepositRecordReq.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		boDepositRecordReq.Offset = offset * limit
-		kintoneDepositRecords, _, err = srv.depositRecordCommonSrv.GetKintoneDepositRecords(ctx, boDepositRecordReq)
-		if err != nil {
-			return xerrors.Errorf("depositRecordService BatchSyncDepositRecord GetKintoneDepositRecord: %w", err)
-		}
-		allRecords = append(allRecords, kintoneDepositRecords...)
+		return xerrors.Errorf("depositRecordService BatchSyncDepositRecord GetAllKintoneDepositRecords: %w", err)
 	}
 
 	var wait *sync.WaitGroup
@@ -276,13 +277,14 @@ func (srv *DepositRecordService) BatchSyncDepositRecord(ctx context.Context, con
 				srv.logger.Error(ctx, "depositRecordService syncDepositRecords panic", nil,
 					zap.Any(logger.PanicMessage, r),
 				)
+				tracker.Abort(syncjob.TypeDepositRecord, xerrors.Errorf("panic: %v", r))
 			}
 			if wait != nil {
 				wait.Done()
 			}
 		}()
 
-		srv.syncDepositRecords(ctx, allRecords, cond)
+		srv.syncDepositRecords(ctx, allRecords, cond, snapshotAt, tracker)
 	}()
 
 	return nil
@@ -321,7 +323,15 @@ func (srv *DepositRecordService) GetStudentTotalDepositPoints(ctx context.Contex
 	return boStudentTotalDepositPoints, nil
 }
 
-func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allRecords []*bo.KintoneDepositRecord, cond *bo.SyncDepositRecordCond) {
+func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allRecords []*bo.KintoneDepositRecord, cond *bo.SyncDepositRecordCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourceDepositRecord, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "depositRecordService syncDepositRecords recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypeDepositRecord, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentRecordIds := map[int]struct{}{}
 	for _, kintoneDepositRecord := range allRecords {
@@ -334,14 +344,24 @@ func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allReco
 						zap.Any(logger.PanicMessage, r),
 						zap.Any("depositRecord", *dr),
 					)
+					tracker.Track(syncjob.TypeDepositRecord, dr.Id, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			err := srv.syncDepositRecord(ctx, dr)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, dr.Id, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffDepositRecord(ctx, dr, tracker)
+					return err
+				}
+				action, err = srv.syncDepositRecord(ctx, dr)
+				return err
+			})
 			if err != nil {
-				srv.logger.Error(ctx, "depositRecordService syncDepositRecords syncDepositRecord", err, zap.Int("record_ref_id", dr.Id), zap.String("student_name", dr.KintoneStudentName))
+				srv.logger.Error(ctx, "depositRecordService syncDepositRecords SyncDepositRecord", err, zap.Int("record_ref_id", dr.Id), zap.String("student_name", dr.KintoneStudentName))
 			}
+			tracker.Track(syncjob.TypeDepositRecord, dr.Id, action, err)
 		})
 
 		currentRecordIds[dr.Id] = struct{}{}
@@ -349,6 +369,13 @@ func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allReco
 
 	wg.Wait()
 
+	currentRecordIds, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppDepositRecord, cond != nil && cond.UpdatedSince != nil, currentRecordIds)
+	if err != nil {
+		srv.logger.Error(ctx, "depositRecordService syncDepositRecords getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypeDepositRecord, err)
+		return
+	}
+
 	db := srv.DB.Session()
 	isDeleted := false
 	poDepositRecordCond := &po.DepositRecordCond{
@@ -363,6 +390,7 @@ func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allReco
 						zap.String("student_name", *cond.StudentName),
 						zap.String("parent_phone", *cond.ParentPhone),
 					)
+					tracker.Abort(syncjob.TypeDepositRecord, errs.StudentErr.StudentNotFoundErr)
 					return
 				}
 
@@ -370,6 +398,7 @@ func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allReco
 					zap.String("student_name", *cond.StudentName),
 					zap.String("parent_phone", *cond.ParentPhone),
 				)
+				tracker.Abort(syncjob.TypeDepositRecord, err)
 
 				return
 			}
@@ -386,29 +415,85 @@ func (srv *DepositRecordService) syncDepositRecords(ctx context.Context, allReco
 	recordRefIds, err := srv.recordRepo.GetDepositRecordRefIds(ctx, db, poDepositRecordCond)
 	if err != nil {
 		srv.logger.Error(ctx, "depositRecordService syncDepositRecords GetDepositRecordRefIds", err)
+		tracker.Abort(syncjob.TypeDepositRecord, err)
 		return
 	}
 
 	for _, recordRefId := range recordRefIds {
 		if _, found := currentRecordIds[recordRefId]; !found {
-			if err := srv.DeleteDepositRecord(ctx, &bo.DepositRecordCond{RecordRefId: recordRefId}); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, recordRefId, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeDepositRecord, RecordRefId: recordRefId})
+					return nil
+				}
+				return srv.deleteDepositRecord(ctx, &bo.DepositRecordCond{RecordRefId: recordRefId})
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "depositRecordService syncDepositRecords DeleteDepositRecord", err, zap.Int("record_ref_id", recordRefId))
 			}
+			tracker.Track(syncjob.TypeDepositRecord, recordRefId, action, err)
 		}
 	}
 }
 
-func (srv *DepositRecordService) syncDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord) error {
+// SyncDepositRecord 處理 kintone 新增或更新記錄的 webhook，以 kintone 上的記錄新增或更新 db 的記錄，並記錄稽核記錄
+func (srv *DepositRecordService) SyncDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord) error {
+	before, err := srv.getDepositRecordSnapshot(ctx, data.Id)
+	if err != nil {
+		return xerrors.Errorf("depositRecordService SyncDepositRecord getDepositRecordSnapshot: %w", err)
+	}
+
+	if _, err := srv.syncDepositRecord(ctx, data); err != nil {
+		return err
+	}
+	srv.addAuditLog(ctx, audit.ActionDepositRecordSync, data.Id, before)
+
+	return nil
+}
+
+// getDepositRecordSnapshot 記錄不存在時回傳 nil
+func (srv *DepositRecordService) getDepositRecordSnapshot(ctx context.Context, recordRefId int) (*po.DepositRecord, error) {
+	record, err := srv.recordRepo.GetRecord(ctx, srv.DB.Session(), &po.DepositRecordCond{RecordRefId: recordRefId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("recordRepo.GetRecord: %w", err)
+	}
+
+	return record, nil
+}
+
+// addAuditLog 以 kintone 記錄 id 為 target id，記錄異動前後 db 的記錄
+func (srv *DepositRecordService) addAuditLog(ctx context.Context, action audit.Action, recordRefId int, before *po.DepositRecord) {
+	after, err := srv.getDepositRecordSnapshot(ctx, recordRefId)
+	if err != nil {
+		srv.logger.Error(ctx, "depositRecordService addAuditLog getDepositRecordSnapshot", err, zap.Int("record_ref_id", recordRefId))
+		return
+	}
+
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetDepositRecord, TargetId: int64(recordRefId), Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "depositRecordService addAuditLog auditLogSrv.AddAuditLog", err, zap.Int("record_ref_id", recordRefId))
+	}
+}
+
+func (srv *DepositRecordService) syncDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord) (syncjob.Action, error) {
 	db := srv.DB.Session()
 	poDepositRecordCond := &po.DepositRecordCond{RecordRefId: data.Id}
 	record, err := srv.recordRepo.GetRecord(ctx, db, poDepositRecordCond)
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return xerrors.Errorf("depositRecordService syncDepositRecord recordRepo.GetRecord: %w", err)
+		return "", xerrors.Errorf("depositRecordService SyncDepositRecord recordRepo.GetRecord: %w", err)
 	}
 
 	boStudentCond := &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone}
+	var action syncjob.Action
 	if record != nil {
 		// Update
+		action = syncjob.ActionUpdated
 		boDepositRecordCond := &bo.DepositRecordCond{RecordRefId: data.Id}
 		boUpdateDepositRecordData := &bo.UpdateDepositRecordData{
 			ChargingDate:         data.ChargingDate,
@@ -422,7 +507,7 @@ func (srv *DepositRecordService) syncDepositRecord(ctx context.Context, data *bo
 			ActualChargingAmount: &data.ActualChargingAmount,
 		}
 		if err := srv.UpdateDepositRecord(ctx, boDepositRecordCond, boStudentCond, boUpdateDepositRecordData); err != nil {
-			return xerrors.Errorf("depositRecordService syncDepositRecord UpdateDepositRecord: %w", err)
+			return "", xerrors.Errorf("depositRecordService SyncDepositRecord UpdateDepositRecord: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -431,15 +516,51 @@ func (srv *DepositRecordService) syncDepositRecord(ctx context.Context, data *bo
 				db.Where("record_id = ?", record.RecordId)
 				return db
 			}); err != nil {
-				return xerrors.Errorf("depositRecordService syncDepositRecord ResetFromDeleted: %w", err)
+				return "", xerrors.Errorf("depositRecordService SyncDepositRecord ResetFromDeleted: %w", err)
 			}
 		}
 	} else {
 		// Create
+		action = syncjob.ActionCreated
 		if err := srv.AddDepositRecord(ctx, data, boStudentCond); err != nil {
-			return xerrors.Errorf("depositRecordService syncDepositRecord AddDepositRecord: %w", err)
+			return "", xerrors.Errorf("depositRecordService SyncDepositRecord AddDepositRecord: %w", err)
 		}
 	}
 
-	return nil
+	return action, nil
+}
+
+// diffDepositRecord dry run 時計算 syncDepositRecord 會套用的變更，不寫入 db
+func (srv *DepositRecordService) diffDepositRecord(ctx context.Context, data *bo.KintoneDepositRecord, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	record, err := srv.recordRepo.GetRecord(ctx, srv.DB.Session(), &po.DepositRecordCond{RecordRefId: data.Id})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("depositRecordService diffDepositRecord recordRepo.GetRecord: %w", err)
+	}
+
+	boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return "", xerrors.Errorf("depositRecordService diffDepositRecord studentCommonSrv.GetStudent: %w", errs.StudentErr.StudentNotFoundErr)
+		}
+
+		return "", xerrors.Errorf("depositRecordService diffDepositRecord studentCommonSrv.GetStudent: %w", err)
+	}
+
+	differ := newSyncFieldDiffer(record == nil)
+	if record == nil {
+		record = &po.DepositRecord{}
+	}
+	differ.compare("student_id", record.StudentId, boStudent.StudentId)
+	differ.compare("charging_date", record.ChargingDate, data.ChargingDate)
+	differ.compare("tax_id", record.TaxId, data.TaxId)
+	differ.compare("account_last_five_yards", record.AccountLastFiveYards, data.AccountLastFiveYards)
+	differ.compare("charging_amount", record.ChargingAmount, data.ChargingAmount)
+	differ.compare("teacher_name", record.TeacherName, data.TeacherName)
+	differ.compare("deposited_points", record.DepositedPoints, data.DepositedPoints)
+	differ.compare("charging_method", record.ChargingMethod.Values, kintone.ChargingMethodToKey(data.ChargingMethod))
+	differ.compare("hit_status", record.HitStatus, data.ChargingStatus.ToValue())
+	differ.compare("actual_charging_amount", record.ActualChargingAmount, data.ActualChargingAmount)
+
+	diffRecord := &bo.SyncDiffRecord{Type: syncjob.TypeDepositRecord, RecordRefId: data.Id, Changes: differ.changes}
+	return trackSyncDiff(tracker, diffRecord, !differ.created, record.IsDeleted || record.DeletedAt != nil), nil
 }
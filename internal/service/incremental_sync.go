@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/errs"
+	"sync"
+	"time"
+)
+
+func ProvideIncrementalSyncService(
+	db database.IPostgresDB,
+	syncWatermarkRepo interfaces.ISyncWatermarkRepo,
+	syncJobSrv interfaces.ISyncJobSrv,
+	studentSrv interfaces.IStudentSrv,
+	pointCardSrv interfaces.IPointCardSrv,
+	depositRecordSrv interfaces.IDepositRecordSrv,
+	reduceRecordSrv interfaces.IReduceRecordSrv,
+	scheduleSrv interfaces.IScheduleSrv,
+	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv,
+	logger pkgLogger.ILogger,
+) *IncrementalSyncService {
+	return &IncrementalSyncService{
+		DB:                      db,
+		syncWatermarkRepo:       syncWatermarkRepo,
+		syncJobSrv:              syncJobSrv,
+		studentSrv:              studentSrv,
+		pointCardSrv:            pointCardSrv,
+		depositRecordSrv:        depositRecordSrv,
+		reduceRecordSrv:         reduceRecordSrv,
+		scheduleSrv:             scheduleSrv,
+		semesterSettleRecordSrv: semesterSettleRecordSrv,
+		logger:                  logger,
+	}
+}
+
+type IncrementalSyncService struct {
+	DB                      database.IPostgresDB
+	syncWatermarkRepo       interfaces.ISyncWatermarkRepo
+	syncJobSrv              interfaces.ISyncJobSrv
+	studentSrv              interfaces.IStudentSrv
+	pointCardSrv            interfaces.IPointCardSrv
+	depositRecordSrv        interfaces.IDepositRecordSrv
+	reduceRecordSrv         interfaces.IReduceRecordSrv
+	scheduleSrv             interfaces.IScheduleSrv
+	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv
+	logger                  pkgLogger.ILogger
+}
+
+type incrementalSyncStep struct {
+	jobType syncjob.Type
+	sync    func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error
+}
+
+// StartIncrementalSync 建立增量同步工作，已有增量同步在排隊或執行中時不重複建立
+// dryRun 時不推進 watermark
+func (srv *IncrementalSyncService) StartIncrementalSync(ctx context.Context, dryRun bool) (*bo.SyncJob, error) {
+	active, err := srv.syncJobSrv.HasActiveJob(ctx, syncjob.TypeIncremental)
+	if err != nil {
+		return nil, xerrors.Errorf("incrementalSyncService StartIncrementalSync syncJobSrv.HasActiveJob: %w", err)
+	}
+	if active {
+		return nil, xerrors.Errorf("incrementalSyncService StartIncrementalSync: %w", errs.SyncJobErr.JobIsActiveError)
+	}
+
+	job, err := srv.syncJobSrv.StartJob(ctx, syncjob.TypeIncremental, nil, dryRun, srv.runIncrementalSync)
+	if err != nil {
+		return nil, xerrors.Errorf("incrementalSyncService StartIncrementalSync syncJobSrv.StartJob: %w", err)
+	}
+
+	return job, nil
+}
+
+// runIncrementalSync 依序同步各應用程式 watermark 之後更新的記錄，沒有 watermark 時同步全部記錄
+// 應用程式全部記錄都同步成功時才推進 watermark，失敗的記錄下次會再同步一次
+func (srv *IncrementalSyncService) runIncrementalSync(ctx context.Context, tracker *bo.SyncJobTracker) error {
+	poWatermarks, err := srv.syncWatermarkRepo.GetWatermarks(ctx, srv.DB.Session())
+	if err != nil {
+		return xerrors.Errorf("incrementalSyncService runIncrementalSync syncWatermarkRepo.GetWatermarks: %w", err)
+	}
+	watermarks := make(map[syncjob.Type]time.Time, len(poWatermarks))
+	for _, poWatermark := range poWatermarks {
+		watermarks[poWatermark.JobType] = poWatermark.Watermark
+	}
+
+	// 學生資料必須先同步，其他資料依學生資料對應
+	for _, step := range srv.steps() {
+		var since *time.Time
+		if watermark, ok := watermarks[step.jobType]; ok {
+			since = incrementalSince(watermark)
+		}
+
+		before := tracker.Report()
+		fetchedAt := time.Now()
+		wg := &sync.WaitGroup{}
+		if err := step.sync(ctx, since, tracker, wg); err != nil {
+			srv.logger.Error(ctx, "incrementalSyncService runIncrementalSync sync", err, zap.String("job_type", string(step.jobType)))
+			tracker.Abort(step.jobType, err)
+			continue
+		}
+		wg.Wait()
+
+		if tracker.DryRun() {
+			continue
+		}
+		if !canAdvanceWatermark(before, tracker.Report()) {
+			srv.logger.Warn(ctx, "incrementalSyncService runIncrementalSync watermark not advanced", zap.String("job_type", string(step.jobType)))
+			continue
+		}
+		if err := srv.syncWatermarkRepo.UpsertWatermark(ctx, srv.DB.Session(), step.jobType, fetchedAt); err != nil {
+			srv.logger.Error(ctx, "incrementalSyncService runIncrementalSync syncWatermarkRepo.UpsertWatermark", err, zap.String("job_type", string(step.jobType)))
+			tracker.Abort(step.jobType, err)
+		}
+	}
+
+	return nil
+}
+
+func (srv *IncrementalSyncService) steps() []incrementalSyncStep {
+	return []incrementalSyncStep{
+		{
+			jobType: syncjob.TypeStudent,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.studentSrv.BatchSyncStudentsAndUsers(ctx, &bo.SyncStudentCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+		{
+			jobType: syncjob.TypePointCard,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.pointCardSrv.BatchSyncPointCard(ctx, &bo.SyncPointCardCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+		{
+			jobType: syncjob.TypeDepositRecord,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.depositRecordSrv.BatchSyncDepositRecord(ctx, &bo.SyncDepositRecordCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+		{
+			jobType: syncjob.TypeReduceRecord,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.reduceRecordSrv.BatchSyncReduceRecord(ctx, &bo.SyncReduceRecordCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+		{
+			jobType: syncjob.TypeSchedule,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.scheduleSrv.BatchSyncSchedule(ctx, &bo.SyncScheduleCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+		{
+			jobType: syncjob.TypeSemesterSettleRecord,
+			sync: func(ctx context.Context, since *time.Time, tracker *bo.SyncJobTracker, wg *sync.WaitGroup) error {
+				return srv.semesterSettleRecordSrv.BatchSyncSemesterSettleRecord(ctx, &bo.SyncSemesterSettleRecordCond{IncrementalSyncCond: bo.IncrementalSyncCond{UpdatedSince: since}}, tracker, wg)
+			},
+		},
+	}
+}
+
+// incrementalSince watermark 往前多取 WatermarkOverlap，避免時間誤差漏掉記錄
+func incrementalSince(watermark time.Time) *time.Time {
+	since := watermark.Add(-syncjob.WatermarkOverlap)
+	return &since
+}
+
+// canAdvanceWatermark 應用程式同步期間沒有新增失敗記錄或錯誤時才推進 watermark
+func canAdvanceWatermark(before, after bo.SyncJobReport) bool {
+	return after.Failed == before.Failed && after.Error == before.Error
+}
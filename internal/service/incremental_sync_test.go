@@ -0,0 +1,35 @@
+package service
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/bo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalSince(t *testing.T) {
+	watermark := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	since := incrementalSince(watermark)
+	assert.Equal(t, watermark.Add(-syncjob.WatermarkOverlap), *since)
+}
+
+func TestCanAdvanceWatermark(t *testing.T) {
+	before := bo.SyncJobReport{Total: 10, Created: 5, Failed: 1, Error: "student: boom"}
+	tests := []struct {
+		name  string
+		after bo.SyncJobReport
+		want  bool
+	}{
+		{name: "all synced", after: bo.SyncJobReport{Total: 20, Created: 10, Updated: 5, Failed: 1, Error: "student: boom"}, want: true},
+		{name: "record failed", after: bo.SyncJobReport{Total: 20, Created: 10, Failed: 2, Error: "student: boom"}, want: false},
+		{name: "sync aborted", after: bo.SyncJobReport{Total: 10, Created: 5, Failed: 1, Error: "student: boom; point_card: boom"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, canAdvanceWatermark(before, tt.after))
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/ledger"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/points"
+	"sort"
+	"time"
+)
+
+func ProvideLedgerService(
+	db database.IPostgresDB,
+	ledgerRepo interfaces.ILedgerRepo,
+	studentRepo interfaces.IStudentRepo,
+	pointCardRepo interfaces.IPointCardRepo,
+	depositRecordRepo interfaces.IDepositRecordRepo,
+	reduceRecordRepo interfaces.IReduceRecordRepo,
+	semesterSettleRecordRepo interfaces.ISemesterSettleRecordRepo,
+) *LedgerService {
+	return &LedgerService{
+		DB:                       db,
+		ledgerRepo:               ledgerRepo,
+		studentRepo:              studentRepo,
+		pointCardRepo:            pointCardRepo,
+		depositRecordRepo:        depositRecordRepo,
+		reduceRecordRepo:         reduceRecordRepo,
+		semesterSettleRecordRepo: semesterSettleRecordRepo,
+	}
+}
+
+type LedgerService struct {
+	DB                       database.IPostgresDB
+	ledgerRepo               interfaces.ILedgerRepo
+	studentRepo              interfaces.IStudentRepo
+	pointCardRepo            interfaces.IPointCardRepo
+	depositRecordRepo        interfaces.IDepositRecordRepo
+	reduceRecordRepo         interfaces.IReduceRecordRepo
+	semesterSettleRecordRepo interfaces.ISemesterSettleRecordRepo
+}
+
+// GetStudentLedger 依發生時間列出學生影響點數的記錄與每筆記錄後的餘額
+func (srv *LedgerService) GetStudentLedger(ctx context.Context, studentId int64) (*bo.StudentLedger, error) {
+	db := srv.DB.Session()
+	isDeleted := false
+
+	students, err := srv.studentRepo.ListStudents(ctx, db, &po.StudentCond{StudentId: studentId, IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetStudentLedger studentRepo.ListStudents: %w", err)
+	}
+	if len(students) == 0 {
+		return nil, xerrors.Errorf("ledgerService GetStudentLedger studentRepo.ListStudents: %w", errs.StudentErr.StudentNotFoundErr)
+	}
+
+	pointCards, err := srv.pointCardRepo.GetPointCards(ctx, db, &po.PointCardCond{StudentIds: []int64{studentId}, IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetStudentLedger pointCardRepo.GetPointCards: %w", err)
+	}
+
+	poEntries, err := srv.ledgerRepo.GetEntries(ctx, db, &po.LedgerEntryCond{StudentId: studentId})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetStudentLedger ledgerRepo.GetEntries: %w", err)
+	}
+
+	entries, derivedBalance := applyLedgerEntries(poEntries)
+	studentLedger := &bo.StudentLedger{
+		StudentBalance: bo.StudentBalance{
+			StudentId:      students[0].StudentId,
+			StudentName:    students[0].StudentName,
+			ParentPhone:    students[0].ParentPhone,
+			DerivedBalance: derivedBalance,
+			HasPointCard:   len(pointCards) > 0,
+		},
+		Entries: entries,
+	}
+	for _, pointCard := range pointCards {
+		studentLedger.PointCardBalance = studentLedger.PointCardBalance.Add(pointCard.RestPoints)
+	}
+
+	return studentLedger, nil
+}
+
+// GetStudentBalanceAsOf 以記錄在 asOf 當下的狀態推算餘額，asOf 之後才刪除的記錄仍會計入
+func (srv *LedgerService) GetStudentBalanceAsOf(ctx context.Context, cond *bo.StudentCond, asOf time.Time) (*bo.StudentBalanceAsOf, error) {
+	if cond.StudentId == 0 {
+		return nil, xerrors.Errorf("ledgerService GetStudentBalanceAsOf: %w", errs.StudentErr.StudentIdInvalidErr)
+	}
+
+	db := srv.DB.Session()
+	isDeleted := false
+
+	students, err := srv.studentRepo.ListStudents(ctx, db, &po.StudentCond{UserId: cond.UserId, StudentId: cond.StudentId, IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetStudentBalanceAsOf studentRepo.ListStudents: %w", err)
+	}
+	if len(students) == 0 {
+		return nil, xerrors.Errorf("ledgerService GetStudentBalanceAsOf studentRepo.ListStudents: %w", errs.StudentErr.StudentNotFoundErr)
+	}
+
+	poEntries, err := srv.ledgerRepo.GetEntries(ctx, db, &po.LedgerEntryCond{StudentId: cond.StudentId, AsOf: asOf})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetStudentBalanceAsOf ledgerRepo.GetEntries: %w", err)
+	}
+
+	entries, balance := applyLedgerEntries(poEntries)
+
+	return &bo.StudentBalanceAsOf{
+		StudentId:   students[0].StudentId,
+		StudentName: students[0].StudentName,
+		ParentPhone: students[0].ParentPhone,
+		AsOf:        asOf,
+		Balance:     balance,
+		Entries:     entries,
+	}, nil
+}
+
+// GetBalanceMismatches 列出推算餘額與點數卡不一致，或沒有點數卡的學生
+func (srv *LedgerService) GetBalanceMismatches(ctx context.Context) ([]*bo.StudentBalance, error) {
+	db := srv.DB.Session()
+	isDeleted := false
+
+	students, err := srv.studentRepo.ListStudents(ctx, db, &po.StudentCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetBalanceMismatches studentRepo.ListStudents: %w", err)
+	}
+	balances := make(map[int64]*bo.StudentBalance, len(students))
+	for _, student := range students {
+		balances[student.StudentId] = &bo.StudentBalance{
+			StudentId:   student.StudentId,
+			StudentName: student.StudentName,
+			ParentPhone: student.ParentPhone,
+		}
+	}
+
+	pointCards, err := srv.pointCardRepo.GetPointCards(ctx, db, &po.PointCardCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetBalanceMismatches pointCardRepo.GetPointCards: %w", err)
+	}
+	for _, pointCard := range pointCards {
+		if balance, ok := balances[pointCard.StudentId]; ok {
+			balance.HasPointCard = true
+			balance.PointCardBalance = balance.PointCardBalance.Add(pointCard.RestPoints)
+		}
+	}
+
+	depositTotals, err := srv.depositRecordRepo.GetStudentTotalDepositPoints(ctx, db, &po.DepositRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetBalanceMismatches depositRecordRepo.GetStudentTotalDepositPoints: %w", err)
+	}
+	for _, total := range depositTotals {
+		if balance, ok := balances[total.StudentId]; ok {
+			balance.DerivedBalance = balance.DerivedBalance.Add(ledgerChange(ledger.EntryTypeDepositRecord, points.FromInt(total.TotalDepositedPoints)))
+		}
+	}
+
+	reduceTotals, err := srv.reduceRecordRepo.GetStudentTotalReducePoints(ctx, db, &po.ReduceRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetBalanceMismatches reduceRecordRepo.GetStudentTotalReducePoints: %w", err)
+	}
+	for _, total := range reduceTotals {
+		if balance, ok := balances[total.StudentId]; ok {
+			balance.DerivedBalance = balance.DerivedBalance.Add(ledgerChange(ledger.EntryTypeReduceRecord, total.TotalReducePoints))
+		}
+	}
+
+	clearTotals, err := srv.semesterSettleRecordRepo.GetStudentTotalClearPoints(ctx, db, &po.SemesterSettleRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, xerrors.Errorf("ledgerService GetBalanceMismatches semesterSettleRecordRepo.GetStudentTotalClearPoints: %w", err)
+	}
+	for _, total := range clearTotals {
+		if balance, ok := balances[total.StudentId]; ok {
+			balance.DerivedBalance = balance.DerivedBalance.Add(ledgerChange(ledger.EntryTypeSettleRecord, total.TotalClearPoints))
+		}
+	}
+
+	mismatches := make([]*bo.StudentBalance, 0)
+	for _, balance := range balances {
+		if !balance.BalanceMatched() {
+			mismatches = append(mismatches, balance)
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].StudentId < mismatches[j].StudentId })
+
+	return mismatches, nil
+}
+
+// applyLedgerEntries 依序累計餘額，回傳每筆記錄後的餘額與最後的餘額
+func applyLedgerEntries(poEntries []*po.LedgerEntry) ([]*bo.LedgerEntry, points.Points) {
+	entries := make([]*bo.LedgerEntry, 0, len(poEntries))
+	var balance points.Points
+	for _, poEntry := range poEntries {
+		change := ledgerChange(poEntry.EntryType, poEntry.Points)
+		balance = balance.Add(change)
+		entries = append(entries, &bo.LedgerEntry{
+			EntryType:   poEntry.EntryType,
+			RecordRefId: poEntry.RecordRefId,
+			OccurredAt:  poEntry.OccurredAt,
+			Change:      change,
+			Balance:     balance,
+		})
+	}
+
+	return entries, balance
+}
+
+// ledgerChange 儲值增加點數，扣點與學期結算清除點數
+func ledgerChange(entryType ledger.EntryType, value points.Points) points.Points {
+	if entryType == ledger.EntryTypeDepositRecord {
+		return value
+	}
+	return value.Neg()
+}
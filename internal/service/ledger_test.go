@@ -0,0 +1,91 @@
+package service
+
+import (
+	"jaystar/internal/constant/ledger"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/points"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pts = points.FromFloat
+
+func TestApplyLedgerEntries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 9, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name        string
+		poEntries   []*po.LedgerEntry
+		wantEntries []*bo.LedgerEntry
+		wantBalance points.Points
+	}{
+		{
+			name:        "no entries",
+			poEntries:   nil,
+			wantEntries: []*bo.LedgerEntry{},
+			wantBalance: points.Points{},
+		},
+		{
+			name: "deposit, reduce then settle",
+			poEntries: []*po.LedgerEntry{
+				{EntryType: ledger.EntryTypeDepositRecord, RecordRefId: 1, OccurredAt: day(1), Points: pts(10)},
+				{EntryType: ledger.EntryTypeReduceRecord, RecordRefId: 2, OccurredAt: day(2), Points: pts(1.5)},
+				{EntryType: ledger.EntryTypeReduceRecord, RecordRefId: 3, OccurredAt: day(3), Points: pts(2)},
+				{EntryType: ledger.EntryTypeSettleRecord, RecordRefId: 4, OccurredAt: day(4), Points: pts(6.5)},
+				{EntryType: ledger.EntryTypeDepositRecord, RecordRefId: 5, OccurredAt: day(5), Points: pts(4)},
+			},
+			wantEntries: []*bo.LedgerEntry{
+				{EntryType: ledger.EntryTypeDepositRecord, RecordRefId: 1, OccurredAt: day(1), Change: pts(10), Balance: pts(10)},
+				{EntryType: ledger.EntryTypeReduceRecord, RecordRefId: 2, OccurredAt: day(2), Change: pts(-1.5), Balance: pts(8.5)},
+				{EntryType: ledger.EntryTypeReduceRecord, RecordRefId: 3, OccurredAt: day(3), Change: pts(-2), Balance: pts(6.5)},
+				{EntryType: ledger.EntryTypeSettleRecord, RecordRefId: 4, OccurredAt: day(4), Change: pts(-6.5), Balance: pts(0)},
+				{EntryType: ledger.EntryTypeDepositRecord, RecordRefId: 5, OccurredAt: day(5), Change: pts(4), Balance: pts(4)},
+			},
+			wantBalance: points.FromInt(4),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, balance := applyLedgerEntries(tt.poEntries)
+			assert.Equal(t, tt.wantEntries, entries)
+			assert.Equal(t, tt.wantBalance, balance)
+		})
+	}
+}
+
+func TestStudentBalanceMatched(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance bo.StudentBalance
+		want    bool
+	}{
+		{
+			name:    "matched",
+			balance: bo.StudentBalance{DerivedBalance: pts(3.5), PointCardBalance: pts(3.5), HasPointCard: true},
+			want:    true,
+		},
+		{
+			name:    "decimal sum is exact",
+			balance: bo.StudentBalance{DerivedBalance: pts(0.1).Add(pts(0.2)), PointCardBalance: pts(0.3), HasPointCard: true},
+			want:    true,
+		},
+		{
+			name:    "mismatched",
+			balance: bo.StudentBalance{DerivedBalance: pts(3), PointCardBalance: pts(3.5), HasPointCard: true},
+			want:    false,
+		},
+		{
+			name:    "no point card",
+			balance: bo.StudentBalance{DerivedBalance: pts(0), PointCardBalance: pts(0), HasPointCard: false},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.balance.BalanceMatched())
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/loginsession"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"time"
+)
+
+func ProvideLoginSessionService(db database.IPostgresDB, loginSessionRepo interfaces.ILoginSessionRepo, auditLogSrv interfaces.IAuditLogSrv, logger logger.ILogger) *LoginSessionService {
+	return &LoginSessionService{
+		DB:               db,
+		loginSessionRepo: loginSessionRepo,
+		auditLogSrv:      auditLogSrv,
+		logger:           logger,
+	}
+}
+
+type LoginSessionService struct {
+	DB               database.IPostgresDB
+	loginSessionRepo interfaces.ILoginSessionRepo
+	auditLogSrv      interfaces.IAuditLogSrv
+	logger           logger.ILogger
+}
+
+func (srv *LoginSessionService) GetActiveLoginSession(ctx context.Context, token string) (*bo.LoginSession, error) {
+	now := time.Now()
+	loginSession, err := srv.getLoginSession(ctx, &po.LoginSessionCond{TokenHash: hashSessionToken(token), ActiveAt: &now})
+	if err != nil {
+		return nil, xerrors.Errorf("loginSessionService GetActiveLoginSession getLoginSession: %w", err)
+	}
+
+	return loginSession, nil
+}
+
+func (srv *LoginSessionService) CreateLoginSession(ctx context.Context, data *bo.SaveLoginSessionData) (string, error) {
+	if data.UserId == 0 {
+		return "", xerrors.Errorf("loginSessionService CreateLoginSession: %w", errs.LoginSessionErr.SessionUserEmptyError)
+	}
+
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", xerrors.Errorf("loginSessionService CreateLoginSession generateSessionToken: %w", err)
+	}
+	sessionId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return "", xerrors.Errorf("loginSessionService CreateLoginSession autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+
+	userAgent := data.UserAgent
+	if len(userAgent) > loginsession.MaxUserAgentLen {
+		userAgent = userAgent[:loginsession.MaxUserAgentLen]
+	}
+	poLoginSession := &po.LoginSession{
+		SessionId:  sessionId,
+		TokenHash:  hashSessionToken(token),
+		UserId:     data.UserId,
+		Data:       data.Data,
+		UserAgent:  userAgent,
+		Ip:         data.Ip,
+		ExpiresAt:  data.ExpiresAt,
+		LastSeenAt: time.Now(),
+	}
+	if err := srv.loginSessionRepo.AddLoginSession(ctx, srv.DB.Session(), poLoginSession); err != nil {
+		return "", xerrors.Errorf("loginSessionService CreateLoginSession loginSessionRepo.AddLoginSession: %w", err)
+	}
+
+	return token, nil
+}
+
+// RefreshLoginSession 更新 session 內容並延長期限，已撤銷或屬於其他使用者的 session 不會被更新
+func (srv *LoginSessionService) RefreshLoginSession(ctx context.Context, data *bo.SaveLoginSessionData) error {
+	if data.UserId == 0 {
+		return xerrors.Errorf("loginSessionService RefreshLoginSession: %w", errs.LoginSessionErr.SessionUserEmptyError)
+	}
+
+	now := time.Now()
+	cond := &po.UpdateLoginSessionCond{TokenHash: hashSessionToken(data.Token), UserIds: []int64{data.UserId}}
+	updateData := &po.UpdateLoginSessionData{
+		Data:       data.Data,
+		ExpiresAt:  &data.ExpiresAt,
+		LastSeenAt: &now,
+	}
+	if err := srv.loginSessionRepo.UpdateLoginSessions(ctx, srv.DB.Session(), cond, updateData); err != nil {
+		return xerrors.Errorf("loginSessionService RefreshLoginSession loginSessionRepo.UpdateLoginSessions: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *LoginSessionService) EndLoginSession(ctx context.Context, token string) error {
+	cond := &po.UpdateLoginSessionCond{TokenHash: hashSessionToken(token)}
+	if err := srv.revokeLoginSessions(ctx, cond, loginsession.ReasonLogout); err != nil {
+		return xerrors.Errorf("loginSessionService EndLoginSession revokeLoginSessions: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *LoginSessionService) GetLoginSessions(ctx context.Context, cond *bo.LoginSessionCond) ([]*bo.LoginSession, *po.PagerResult, error) {
+	poCond := &po.LoginSessionCond{
+		SessionId: cond.SessionId,
+		UserId:    cond.UserId,
+	}
+	if cond.ActiveOnly {
+		now := time.Now()
+		poCond.ActiveAt = &now
+	}
+	poPager := &po.Pager{
+		Index: cond.Index,
+		Size:  cond.Size,
+		Order: cond.Order,
+	}
+
+	db := srv.DB.Session()
+	poLoginSessions, err := srv.loginSessionRepo.GetLoginSessions(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loginSessionService GetLoginSessions loginSessionRepo.GetLoginSessions: %w", err)
+	}
+	poPagerResult, err := srv.loginSessionRepo.GetLoginSessionsPager(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loginSessionService GetLoginSessions loginSessionRepo.GetLoginSessionsPager: %w", err)
+	}
+
+	currentTokenHash := ""
+	if cond.CurrentToken != "" {
+		currentTokenHash = hashSessionToken(cond.CurrentToken)
+	}
+	loginSessions := make([]*bo.LoginSession, 0, len(poLoginSessions))
+	for _, poLoginSession := range poLoginSessions {
+		loginSession := toLoginSessionBo(poLoginSession)
+		loginSession.IsCurrent = currentTokenHash != "" && poLoginSession.TokenHash == currentTokenHash
+		loginSessions = append(loginSessions, loginSession)
+	}
+
+	return loginSessions, poPagerResult, nil
+}
+
+// RevokeLoginSession 撤銷 cond.UserId 的其中一個登入，已撤銷的登入直接回傳
+func (srv *LoginSessionService) RevokeLoginSession(ctx context.Context, cond *bo.LoginSessionCond) (*bo.LoginSession, error) {
+	if cond.UserId == 0 || cond.SessionId == 0 {
+		return nil, xerrors.Errorf("loginSessionService RevokeLoginSession: %w", errs.LoginSessionErr.SessionNotFoundError)
+	}
+
+	poCond := &po.LoginSessionCond{SessionId: cond.SessionId, UserId: cond.UserId}
+	loginSession, err := srv.getLoginSession(ctx, poCond)
+	if err != nil {
+		return nil, xerrors.Errorf("loginSessionService RevokeLoginSession getLoginSession: %w", err)
+	}
+	if loginSession.RevokedAt != nil {
+		return loginSession, nil
+	}
+
+	updateCond := &po.UpdateLoginSessionCond{SessionId: cond.SessionId, UserIds: []int64{cond.UserId}}
+	if err := srv.revokeLoginSessions(ctx, updateCond, loginsession.ReasonRevoked); err != nil {
+		return nil, xerrors.Errorf("loginSessionService RevokeLoginSession revokeLoginSessions: %w", err)
+	}
+
+	revoked, err := srv.getLoginSession(ctx, poCond)
+	if err != nil {
+		return nil, xerrors.Errorf("loginSessionService RevokeLoginSession getLoginSession: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionLoginSessionRevoke, audit.TargetLoginSession, revoked.SessionId, loginSessionAuditSnapshot(loginSession), loginSessionAuditSnapshot(revoked))
+
+	return revoked, nil
+}
+
+// RevokeUserLoginSessions 撤銷使用者所有的登入 (e.g. 修改密碼、停用帳號)
+func (srv *LoginSessionService) RevokeUserLoginSessions(ctx context.Context, data *bo.RevokeLoginSessionsData) error {
+	if len(data.UserIds) == 0 {
+		return nil
+	}
+
+	cond := &po.UpdateLoginSessionCond{UserIds: data.UserIds}
+	if data.ExceptToken != "" {
+		cond.ExceptTokenHash = hashSessionToken(data.ExceptToken)
+	}
+	if err := srv.revokeLoginSessions(ctx, cond, data.Reason); err != nil {
+		return xerrors.Errorf("loginSessionService RevokeUserLoginSessions revokeLoginSessions: %w", err)
+	}
+
+	// 修改密碼與停用帳號由呼叫端記錄
+	if data.Reason == loginsession.ReasonRevoked {
+		for _, userId := range data.UserIds {
+			srv.addAuditLog(ctx, audit.ActionLoginSessionRevokeAll, audit.TargetUser, userId, nil, nil)
+		}
+	}
+
+	return nil
+}
+
+func (srv *LoginSessionService) revokeLoginSessions(ctx context.Context, cond *po.UpdateLoginSessionCond, reason loginsession.RevokeReason) error {
+	now := time.Now()
+	data := &po.UpdateLoginSessionData{RevokedAt: &now, RevokedReason: string(reason)}
+	if err := srv.loginSessionRepo.UpdateLoginSessions(ctx, srv.DB.Session(), cond, data); err != nil {
+		return xerrors.Errorf("loginSessionRepo.UpdateLoginSessions: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *LoginSessionService) getLoginSession(ctx context.Context, cond *po.LoginSessionCond) (*bo.LoginSession, error) {
+	poLoginSession, err := srv.loginSessionRepo.GetLoginSession(ctx, srv.DB.Session(), cond)
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("loginSessionRepo.GetLoginSession: %w", errs.LoginSessionErr.SessionNotFoundError)
+		}
+		return nil, xerrors.Errorf("loginSessionRepo.GetLoginSession: %w", err)
+	}
+
+	return toLoginSessionBo(poLoginSession), nil
+}
+
+func (srv *LoginSessionService) addAuditLog(ctx context.Context, action audit.Action, targetType audit.TargetType, targetId int64, before, after *bo.LoginSession) {
+	data := &bo.AddAuditLogData{Action: action, TargetType: targetType, TargetId: targetId, Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "loginSessionService addAuditLog auditLogSrv.AddAuditLog", err)
+	}
+}
+
+// loginSessionAuditSnapshot 稽核記錄不保存 session 內容
+func loginSessionAuditSnapshot(loginSession *bo.LoginSession) *bo.LoginSession {
+	snapshot := *loginSession
+	snapshot.Data = ""
+	return &snapshot
+}
+
+// generateSessionToken session token 只出現在簽章過的 cookie 中
+func generateSessionToken() (string, error) {
+	b := make([]byte, loginsession.TokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", xerrors.Errorf("rand.Read: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSessionToken 與 API key 相同，db 只保存 token 的 sha256
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toLoginSessionBo(poLoginSession *po.LoginSession) *bo.LoginSession {
+	return &bo.LoginSession{
+		SessionId:     poLoginSession.SessionId,
+		UserId:        poLoginSession.UserId,
+		Data:          poLoginSession.Data,
+		UserAgent:     poLoginSession.UserAgent,
+		Ip:            poLoginSession.Ip,
+		ExpiresAt:     poLoginSession.ExpiresAt,
+		LastSeenAt:    poLoginSession.LastSeenAt,
+		RevokedAt:     poLoginSession.RevokedAt,
+		RevokedReason: loginsession.RevokeReason(poLoginSession.RevokedReason),
+		CreatedAt:     poLoginSession.CreatedAt,
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	contextKey "jaystar/internal/constant/context"
+	"jaystar/internal/constant/loginsession"
+	"jaystar/internal/constant/user"
+	"jaystar/internal/model/bo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserUpdateRevokeData(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey.SessionToken, "current")
+	password := "encrypted"
+	changed := true
+
+	tests := []struct {
+		name string
+		data *bo.UpdateUserData
+		want *bo.RevokeLoginSessionsData
+	}{
+		{name: "no revoke", data: &bo.UpdateUserData{IsChangedPassword: &changed}, want: nil},
+		{name: "activate", data: &bo.UpdateUserData{Status: user.Activate}, want: nil},
+		{name: "password changed keeps current", data: &bo.UpdateUserData{Password: &password}, want: &bo.RevokeLoginSessionsData{UserIds: []int64{1}, Reason: loginsession.ReasonPasswordChanged, ExceptToken: "current"}},
+		{name: "deactivated revokes all", data: &bo.UpdateUserData{Password: &password, Status: user.Deactivate}, want: &bo.RevokeLoginSessionsData{UserIds: []int64{1}, Reason: loginsession.ReasonDeactivated}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, userUpdateRevokeData(ctx, 1, tt.data))
+		})
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	token, err := generateSessionToken()
+	require.NoError(t, err)
+	other, err := generateSessionToken()
+	require.NoError(t, err)
+
+	assert.NotEqual(t, token, other)
+	assert.Len(t, hashSessionToken(token), 64)
+	assert.Equal(t, hashSessionToken(token), hashSessionToken(token))
+	assert.NotEqual(t, hashSessionToken(token), hashSessionToken(other))
+}
+
+func TestLoginSessionIsActive(t *testing.T) {
+	now := time.Now()
+	revokedAt := now.Add(-time.Minute)
+
+	assert.True(t, (&bo.LoginSession{ExpiresAt: now.Add(time.Hour)}).IsActive(now))
+	assert.False(t, (&bo.LoginSession{ExpiresAt: now.Add(-time.Second)}).IsActive(now))
+	assert.False(t, (&bo.LoginSession{ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt}).IsActive(now))
+}
+
+func TestLoginSessionAuditSnapshot(t *testing.T) {
+	loginSession := &bo.LoginSession{SessionId: 1, Data: "encoded"}
+
+	snapshot := loginSessionAuditSnapshot(loginSession)
+	assert.Empty(t, snapshot.Data)
+	assert.Equal(t, "encoded", loginSession.Data)
+}
@@ -9,7 +9,9 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
@@ -18,7 +20,6 @@ import (
 	"jaystar/internal/utils/errs"
 	"jaystar/internal/utils/pool"
 	"jaystar/internal/utils/strUtil"
-	"strconv"
 	"sync"
 	"time"
 )
@@ -29,6 +30,8 @@ func ProvidePointCardService(
 	studentRepo interfaces.IStudentRepo,
 	DB database.IPostgresDB,
 	logger logger.ILogger,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
 ) *PointCardService {
 	return &PointCardService{
 		kintonePointCardRepo: kintonePointCardRepo,
@@ -36,6 +39,8 @@ func ProvidePointCardService(
 		studentRepo:          studentRepo,
 		DB:                   DB,
 		logger:               logger,
+		recordLockCommonSrv:  recordLockCommonSrv,
+		kintoneRecordRepo:    kintoneRecordRepo,
 		executorPool:         pool.NewExecutorPool(30),
 	}
 }
@@ -46,33 +51,37 @@ type PointCardService struct {
 	studentRepo          interfaces.IStudentRepo
 	DB                   database.IPostgresDB
 	logger               logger.ILogger
+	recordLockCommonSrv  interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo    interfaces.IKintoneRecordRepo
 	executorPool         *ants.Pool `wire:"-"`
 }
 
-func (srv *PointCardService) UpdateKintonePointCardStudentName(ctx context.Context, oldPointCardName string, newPointCardName string) error {
+// GetKintonePointCardStudentNameUpdates 產生點數卡學生名稱改名 (forward) 與還原 (backward) 的更新內容，forward 帶上讀取到的 revision
+func (srv *PointCardService) GetKintonePointCardStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error) {
 	getReq := &dto.GetPointCardReq{StudentName: oldPointCardName, Limit: 1, Offset: 0}
 	pointCardRes, err := srv.kintonePointCardRepo.GetPointCards(ctx, getReq)
 	if err != nil {
-		return xerrors.Errorf("kintonePointCardRepo.GetPointCards: %w", err)
+		return nil, nil, xerrors.Errorf("kintonePointCardRepo.GetPointCards: %w", err)
 	}
 	if len(pointCardRes.Records) == 0 {
-		return fmt.Errorf("cannot find point card record for student: %s", oldPointCardName)
+		return nil, nil, fmt.Errorf("cannot find point card record for student: %s", oldPointCardName)
 	}
 
-	id, err := pointCardRes.Records[0].Id.ToId()
+	pointCard, err := pointCardRes.Records[0].ToPointCard()
 	if err != nil {
-		return xerrors.Errorf("pointCardRes.Records[0].Id.ToId student: %s, id: %s, err: %w", oldPointCardName, pointCardRes.Records[0].Id.Value, err)
+		return nil, nil, xerrors.Errorf("pointCardRes.Records[0].ToPointCard student: %s, id: %s, err: %w", oldPointCardName, pointCardRes.Records[0].Id.Value, err)
 	}
 
-	updateReq := &dto.UpdatePointCardReq{}
-	updateReq.Id = id
-	updateReq.Record.StudentName.Value = newPointCardName
-	_, err = srv.kintonePointCardRepo.UpdatePointCard(ctx, updateReq)
-	if err != nil {
-		return xerrors.Errorf("kintonePointCardRepo.UpdatePointCard: %w", err)
-	}
+	forward := []dto.KintoneUpdateRecord{{
+		KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: pointCard.RecordRefId, Revision: pointCard.Revision},
+		Record:              dto.UpdatePointCardRecord{StudentName: dto.NormalField{Value: newPointCardName}},
+	}}
+	backward := []dto.KintoneUpdateRecord{{
+		KintoneUpdateIdBase: dto.KintoneUpdateIdBase{Id: pointCard.RecordRefId},
+		Record:              dto.UpdatePointCardRecord{StudentName: dto.NormalField{Value: oldPointCardName}},
+	}}
 
-	return nil
+	return dto.NewKintoneBulkUpdateRecords(kintone.AppPointCard, forward), dto.NewKintoneBulkUpdateRecords(kintone.AppPointCard, backward), nil
 }
 
 func (srv *PointCardService) GetPointCards(ctx context.Context, db *gorm.DB, cond *bo.GetPointCardCond) (map[int64]*bo.PointCard, error) {
@@ -170,17 +179,8 @@ func (srv *PointCardService) DeletePointCard(ctx context.Context, cond *bo.Updat
 	return nil
 }
 
-func (srv *PointCardService) BatchSyncPointCard(ctx context.Context, cond *bo.SyncPointCardCond, wait ...*sync.WaitGroup) error {
-	getPointCardReq := &dto.GetPointCardReq{
-		Limit:  request.GetRecordsBatchLimit,
-		Offset: request.GetRecordsBatchOffset,
-	}
-
-	var (
-		total        int
-		err          error
-		boPointCards []*bo.PointCard
-	)
+func (srv *PointCardService) BatchSyncPointCard(ctx context.Context, cond *bo.SyncPointCardCond, tracker *bo.SyncJobTracker, wait ...*sync.WaitGroup) error {
+	getPointCardReq := &dto.GetPointCardReq{}
 
 	if cond != nil {
 		if cond.StudentName != nil && cond.ParentPhone != nil {
@@ -192,25 +192,15 @@ func (srv *PointCardService) BatchSyncPointCard(ctx context.Context, cond *bo.Sy
 		if cond.Offset != nil {
 			getPointCardReq.Offset = *cond.Offset
 		}
+		if cond.UpdatedSince != nil {
+			getPointCardReq.UpdatedSince = *cond.UpdatedSince
+		}
 	}
 
-	boPointCards, total, err = srv.getKintonePointCards(ctx, getPointCardReq)
+	snapshotAt := time.Now()
+	allRecords, err := srv.GetAllKintonePointCards(ctx, getPointCardReq)
 	if err != nil {
-		return xerrors.Errorf("getKintonePointCards: %w", err)
-	}
-
-	allRecords := make([]*bo.PointCard, 0, total)
-	allRecords = append(allRecords, boPointCards...)
-
-	offset, limit := getPointCardReq.Offset, getPointCardReq.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		getPointCardReq.Offset = offset * limit
-		boPointCards, _, err = srv.getKintonePointCards(ctx, getPointCardReq)
-		if err != nil {
-			return xerrors.Errorf("getKintonePointCards: %w", err)
-		}
-		allRecords = append(allRecords, boPointCards...)
+		return xerrors.Errorf("GetAllKintonePointCards: %w", err)
 	}
 
 	var wg *sync.WaitGroup
@@ -222,12 +212,13 @@ func (srv *PointCardService) BatchSyncPointCard(ctx context.Context, cond *bo.Sy
 		defer func() {
 			if r := recover(); r != nil {
 				srv.logger.Error(ctx, "pointCardService syncPointCards panic", nil, zap.Any(logger.PanicMessage, r))
+				tracker.Abort(syncjob.TypePointCard, xerrors.Errorf("panic: %v", r))
 			}
 			if wg != nil {
 				wg.Done()
 			}
 		}()
-		srv.syncPointCards(ctx, allRecords, cond)
+		srv.syncPointCards(ctx, allRecords, cond, snapshotAt, tracker)
 	}()
 
 	return nil
@@ -256,7 +247,7 @@ func (srv *PointCardService) SyncSettledStudentPointCards(ctx context.Context, d
 		}
 
 		kintoneUpdatePointCardRecord := dto.UpdatePointCardsRecordValue{}
-		kintoneUpdatePointCardRecord.ClearPoints.Value = strconv.FormatFloat(updatePointCardsData.ClearPoints, 'f', -1, 64)
+		kintoneUpdatePointCardRecord.ClearPoints.Value = updatePointCardsData.ClearPoints.String()
 
 		kintoneUpdatePointCardsRecord.Record = kintoneUpdatePointCardRecord
 		kintoneUpdatePointCardsRecords = append(kintoneUpdatePointCardsRecords, kintoneUpdatePointCardsRecord)
@@ -273,30 +264,44 @@ func (srv *PointCardService) SyncSettledStudentPointCards(ctx context.Context, d
 	return nil
 }
 
-func (srv *PointCardService) getKintonePointCards(ctx context.Context, getPointCardReq *dto.GetPointCardReq) ([]*bo.PointCard, int, error) {
-	kintonePointCardRes, err := srv.kintonePointCardRepo.GetPointCards(ctx, getPointCardReq)
-	if err != nil {
-		return nil, 0, xerrors.Errorf("kintonePointCardRepo.GetPointCards: %w", err)
-	}
-	total, err := strconv.Atoi(kintonePointCardRes.TotalCount)
+// GetAllKintonePointCards 以 cursor 取得符合條件的全部點數卡，不受 offset 上限限制
+func (srv *PointCardService) GetAllKintonePointCards(ctx context.Context, getPointCardReq *dto.GetPointCardReq) (records []*bo.PointCard, err error) {
+	cursor, err := srv.kintonePointCardRepo.GetPointCardsCursor(ctx, getPointCardReq)
 	if err != nil {
-		return nil, 0, xerrors.Errorf("strconv.ParseInt: %w", err)
+		return nil, xerrors.Errorf("kintonePointCardRepo.GetPointCardsCursor: %w", err)
 	}
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("cursor.Close: %w", closeErr)
+		}
+	}()
 
-	boPointCardRecords := make([]*bo.PointCard, 0, len(kintonePointCardRes.Records))
-
-	for _, record := range kintonePointCardRes.Records {
-		boPointCard, err := record.ToPointCard()
-		if err != nil {
-			return nil, 0, xerrors.Errorf("record.ToPointCard: %w", err)
+	boPointCards := make([]*bo.PointCard, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			boPointCard, err := record.ToPointCard()
+			if err != nil {
+				return nil, xerrors.Errorf("record.ToPointCard: %w", err)
+			}
+			boPointCards = append(boPointCards, boPointCard)
 		}
-		boPointCardRecords = append(boPointCardRecords, boPointCard)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("cursor.Next: %w", err)
 	}
 
-	return boPointCardRecords, total, nil
+	return boPointCards, nil
 }
 
-func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*bo.PointCard, cond *bo.SyncPointCardCond) {
+func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*bo.PointCard, cond *bo.SyncPointCardCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourcePointCard, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "pointCardService syncPointCards recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypePointCard, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentRecordRefIdMap := map[int]struct{}{}
 	for _, pointCard := range allRecords {
@@ -309,13 +314,24 @@ func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*b
 						zap.Any(logger.PanicMessage, r),
 						zap.Any("pointCard", *p),
 					)
+					tracker.Track(syncjob.TypePointCard, p.RecordRefId, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			if err := srv.syncPointCard(ctx, p); err != nil {
-				srv.logger.Error(ctx, "PointCardService syncPointCards syncPointCard", err, zap.Int("record_ref_id", p.RecordRefId), zap.String("student_name", p.KintoneStudentName))
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, p.RecordRefId, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffPointCard(ctx, p, tracker)
+					return err
+				}
+				action, err = srv.syncPointCard(ctx, p)
+				return err
+			})
+			if err != nil {
+				srv.logger.Error(ctx, "PointCardService syncPointCards SyncPointCard", err, zap.Int("record_ref_id", p.RecordRefId), zap.String("student_name", p.KintoneStudentName))
 			}
+			tracker.Track(syncjob.TypePointCard, p.RecordRefId, action, err)
 		})
 
 		currentRecordRefIdMap[p.RecordRefId] = struct{}{}
@@ -323,6 +339,13 @@ func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*b
 
 	wg.Wait()
 
+	currentRecordRefIdMap, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppPointCard, cond != nil && cond.UpdatedSince != nil, currentRecordRefIdMap)
+	if err != nil {
+		srv.logger.Error(ctx, "pointCardService syncPointCards getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypePointCard, err)
+		return
+	}
+
 	db := srv.DB.Session()
 	isDeleted := false
 	poPointCardCond := &po.PointCardCond{
@@ -334,10 +357,12 @@ func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*b
 			if err != nil {
 				if errors.Is(err, errs.DbErr.NoRow) {
 					srv.logger.Error(ctx, "PointCardService syncPointCards studentRepo.GetStudent", errs.StudentErr.StudentNotFoundErr, zap.String("student_name", *cond.StudentName), zap.String("parent_phone", *cond.ParentPhone))
+					tracker.Abort(syncjob.TypePointCard, errs.StudentErr.StudentNotFoundErr)
 					return
 				}
 
 				srv.logger.Error(ctx, "PointCardService syncPointCards studentRepo.GetStudent", err)
+				tracker.Abort(syncjob.TypePointCard, err)
 				return
 			}
 
@@ -348,35 +373,55 @@ func (srv *PointCardService) syncPointCards(ctx context.Context, allRecords []*b
 	recordRefIds, err := srv.pointCardRepo.GetPointCardRefIds(ctx, db, poPointCardCond)
 	if err != nil {
 		srv.logger.Error(ctx, "PointCardService syncPointCards pointCardRepo.GetPointCardRefIds", err)
+		tracker.Abort(syncjob.TypePointCard, err)
 		return
 	}
 
 	for _, recordRefId := range recordRefIds {
 		if _, found := currentRecordRefIdMap[recordRefId]; !found {
-			if err := srv.DeletePointCard(ctx, &bo.UpdatePointCardCond{RecordRefId: recordRefId}); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, recordRefId, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypePointCard, RecordRefId: recordRefId})
+					return nil
+				}
+				return srv.DeletePointCard(ctx, &bo.UpdatePointCardCond{RecordRefId: recordRefId})
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "PointCardService syncPointCards DeletePointCard", err, zap.Int("record_ref_id", recordRefId))
 			}
+			tracker.Track(syncjob.TypePointCard, recordRefId, action, err)
 		}
 	}
 }
 
-func (srv *PointCardService) syncPointCard(ctx context.Context, data *bo.PointCard) error {
+// SyncPointCard 以 kintone 上的記錄新增或更新 db 的記錄
+func (srv *PointCardService) SyncPointCard(ctx context.Context, data *bo.PointCard) error {
+	_, err := srv.syncPointCard(ctx, data)
+	return err
+}
+
+func (srv *PointCardService) syncPointCard(ctx context.Context, data *bo.PointCard) (syncjob.Action, error) {
 	db := srv.DB.Session()
 	poPointCardCond := &po.PointCardCond{RecordRefId: data.RecordRefId}
 	record, err := srv.pointCardRepo.GetPointCard(ctx, db, poPointCardCond)
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return xerrors.Errorf("pointCardRepo.GetPointCard: %w", err)
+		return "", xerrors.Errorf("pointCardRepo.GetPointCard: %w", err)
 	}
 
 	boStudentCond := &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone}
+	var action syncjob.Action
 	if record != nil {
 		// Update
+		action = syncjob.ActionUpdated
 		boUpdatePointCardCond := &bo.UpdatePointCardCond{RecordRefId: data.RecordRefId}
 		boUpdatePointCardRecordData := &bo.UpdatePointCardRecordData{
 			RestPoints: &data.RestPoints,
 		}
 		if err := srv.UpdatePointCard(ctx, boUpdatePointCardCond, boStudentCond, boUpdatePointCardRecordData); err != nil {
-			return xerrors.Errorf("UpdatePointCard: %w", err)
+			return "", xerrors.Errorf("UpdatePointCard: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -385,15 +430,44 @@ func (srv *PointCardService) syncPointCard(ctx context.Context, data *bo.PointCa
 				db.Where("record_id = ?", record.RecordId)
 				return db
 			}); err != nil {
-				return xerrors.Errorf("ResetFromDeleted: %w", err)
+				return "", xerrors.Errorf("ResetFromDeleted: %w", err)
 			}
 		}
 	} else {
 		// Create
+		action = syncjob.ActionCreated
 		if err := srv.AddPointCard(ctx, data, boStudentCond); err != nil {
-			return xerrors.Errorf("AddPointCard: %w", err)
+			return "", xerrors.Errorf("AddPointCard: %w", err)
 		}
 	}
 
-	return nil
+	return action, nil
+}
+
+// diffPointCard dry run 時計算 syncPointCard 會套用的變更，不寫入 db
+func (srv *PointCardService) diffPointCard(ctx context.Context, data *bo.PointCard, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	db := srv.DB.Session()
+	record, err := srv.pointCardRepo.GetPointCard(ctx, db, &po.PointCardCond{RecordRefId: data.RecordRefId})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("pointCardRepo.GetPointCard: %w", err)
+	}
+
+	student, err := srv.studentRepo.GetStudent(ctx, db, &po.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return "", xerrors.Errorf("studentRepo.GetStudent: %w", errs.StudentErr.StudentNotFoundErr)
+		}
+
+		return "", xerrors.Errorf("studentRepo.GetStudent: %w", err)
+	}
+
+	differ := newSyncFieldDiffer(record == nil)
+	if record == nil {
+		record = &po.PointCard{}
+	}
+	differ.compare("student_id", record.StudentId, student.StudentId)
+	differ.compare("rest_points", record.RestPoints, data.RestPoints)
+
+	diffRecord := &bo.SyncDiffRecord{Type: syncjob.TypePointCard, RecordRefId: data.RecordRefId, Changes: differ.changes}
+	return trackSyncDiff(tracker, diffRecord, !differ.created, record.IsDeleted || record.DeletedAt != nil), nil
 }
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/reconciliation"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/points"
+	"sort"
+	"time"
+)
+
+func ProvideReconciliationService(
+	db database.IPostgresDB,
+	studentRepo interfaces.IStudentRepo,
+	depositRecordRepo interfaces.IDepositRecordRepo,
+	reduceRecordRepo interfaces.IReduceRecordRepo,
+	scheduleRepo interfaces.IScheduleRepo,
+	pointCardRepo interfaces.IPointCardRepo,
+	semesterSettleRecordRepo interfaces.ISemesterSettleRecordRepo,
+	studentCommonSrv interfaces.IStudentCommonSrv,
+	depositRecordCommonSrv interfaces.IDepositRecordCommonSrv,
+	reduceRecordCommonSrv interfaces.IReduceRecordCommonSrv,
+	scheduleCommonSrv interfaces.IScheduleCommonSrv,
+	pointCardSrv interfaces.IPointCardSrv,
+	semesterSettleRecordSrv interfaces.ISemesterSettleRecordSrv,
+	syncJobSrv interfaces.ISyncJobSrv,
+) *ReconciliationService {
+	return &ReconciliationService{
+		DB:                       db,
+		studentRepo:              studentRepo,
+		depositRecordRepo:        depositRecordRepo,
+		reduceRecordRepo:         reduceRecordRepo,
+		scheduleRepo:             scheduleRepo,
+		pointCardRepo:            pointCardRepo,
+		semesterSettleRecordRepo: semesterSettleRecordRepo,
+		studentCommonSrv:         studentCommonSrv,
+		depositRecordCommonSrv:   depositRecordCommonSrv,
+		reduceRecordCommonSrv:    reduceRecordCommonSrv,
+		scheduleCommonSrv:        scheduleCommonSrv,
+		pointCardSrv:             pointCardSrv,
+		semesterSettleRecordSrv:  semesterSettleRecordSrv,
+		syncJobSrv:               syncJobSrv,
+	}
+}
+
+type ReconciliationService struct {
+	DB                       database.IPostgresDB
+	studentRepo              interfaces.IStudentRepo
+	depositRecordRepo        interfaces.IDepositRecordRepo
+	reduceRecordRepo         interfaces.IReduceRecordRepo
+	scheduleRepo             interfaces.IScheduleRepo
+	pointCardRepo            interfaces.IPointCardRepo
+	semesterSettleRecordRepo interfaces.ISemesterSettleRecordRepo
+	studentCommonSrv         interfaces.IStudentCommonSrv
+	depositRecordCommonSrv   interfaces.IDepositRecordCommonSrv
+	reduceRecordCommonSrv    interfaces.IReduceRecordCommonSrv
+	scheduleCommonSrv        interfaces.IScheduleCommonSrv
+	pointCardSrv             interfaces.IPointCardSrv
+	semesterSettleRecordSrv  interfaces.ISemesterSettleRecordSrv
+	syncJobSrv               interfaces.ISyncJobSrv
+}
+
+// reconciliationApps 報表中應用程式的順序
+var reconciliationApps = []kintone.App{
+	kintone.AppStudentInfo,
+	kintone.AppPointCard,
+	kintone.AppScheduleRecord,
+	kintone.AppDepositRecord,
+	kintone.AppReduceRecord,
+	kintone.AppSemesterSettleRecord,
+}
+
+type reconciliationStudentKey struct {
+	studentName string
+	parentPhone string
+}
+
+// reconciliationSnapshot kintone 或 db 其中一邊的記錄 id 與各學生的統計
+type reconciliationSnapshot struct {
+	refIds map[kintone.App][]int
+	totals map[reconciliationStudentKey]map[reconciliation.Field]points.Points
+}
+
+func newReconciliationSnapshot() *reconciliationSnapshot {
+	return &reconciliationSnapshot{
+		refIds: make(map[kintone.App][]int),
+		totals: make(map[reconciliationStudentKey]map[reconciliation.Field]points.Points),
+	}
+}
+
+func (s *reconciliationSnapshot) add(key reconciliationStudentKey, field reconciliation.Field, value points.Points) {
+	fields, ok := s.totals[key]
+	if !ok {
+		fields = make(map[reconciliation.Field]points.Points)
+		s.totals[key] = fields
+	}
+	fields[field] = fields[field].Add(value)
+}
+
+// Reconcile 比對 kintone 與 db 各應用程式的記錄數，以及各學生的記錄數、點數加總與剩餘點數
+// 先讀取 kintone 再讀取 db，對帳期間有更新的記錄可能會出現暫時性的差異
+func (srv *ReconciliationService) Reconcile(ctx context.Context) (*bo.ReconciliationReport, error) {
+	checkedAt := time.Now()
+
+	kintoneSnapshot, err := srv.getKintoneSnapshot(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("reconciliationService Reconcile getKintoneSnapshot: %w", err)
+	}
+
+	dbSnapshot, studentIds, err := srv.getDbSnapshot(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("reconciliationService Reconcile getDbSnapshot: %w", err)
+	}
+
+	return buildReconciliationReport(checkedAt, kintoneSnapshot, dbSnapshot, studentIds), nil
+}
+
+// StartReconcile 建立對帳工作並在背景執行，已有對帳在排隊或執行中時不重複建立
+// 對帳需要讀取 kintone 所有應用程式的記錄，不在請求中同步執行
+func (srv *ReconciliationService) StartReconcile(ctx context.Context) (*bo.SyncJob, error) {
+	active, err := srv.syncJobSrv.HasActiveJob(ctx, syncjob.TypeReconciliation)
+	if err != nil {
+		return nil, xerrors.Errorf("reconciliationService StartReconcile syncJobSrv.HasActiveJob: %w", err)
+	}
+	if active {
+		return nil, xerrors.Errorf("reconciliationService StartReconcile: %w", errs.SyncJobErr.JobIsActiveError)
+	}
+
+	job, err := srv.syncJobSrv.StartJob(ctx, syncjob.TypeReconciliation, nil, false, srv.runReconcile)
+	if err != nil {
+		return nil, xerrors.Errorf("reconciliationService StartReconcile syncJobSrv.StartJob: %w", err)
+	}
+
+	return job, nil
+}
+
+// GetReconcileJob 取得對帳工作，對帳完成後 Report.Reconciliation 為對帳結果
+func (srv *ReconciliationService) GetReconcileJob(ctx context.Context, jobId int64) (*bo.SyncJob, error) {
+	job, err := srv.syncJobSrv.GetJob(ctx, jobId)
+	if err != nil {
+		return nil, xerrors.Errorf("reconciliationService GetReconcileJob syncJobSrv.GetJob: %w", err)
+	}
+	if job.JobType != syncjob.TypeReconciliation {
+		return nil, xerrors.Errorf("reconciliationService GetReconcileJob: %w", errs.SyncJobErr.JobNotFoundError)
+	}
+
+	return job, nil
+}
+
+func (srv *ReconciliationService) runReconcile(ctx context.Context, tracker *bo.SyncJobTracker) error {
+	report, err := srv.Reconcile(ctx)
+	if err != nil {
+		return xerrors.Errorf("reconciliationService runReconcile Reconcile: %w", err)
+	}
+	tracker.SetReconciliation(report)
+
+	return nil
+}
+
+func (srv *ReconciliationService) getKintoneSnapshot(ctx context.Context) (*reconciliationSnapshot, error) {
+	snapshot := newReconciliationSnapshot()
+
+	students, err := srv.studentCommonSrv.GetAllKintoneStudents(ctx, &dto.StudentReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("studentCommonSrv.GetAllKintoneStudents: %w", err)
+	}
+	for _, student := range students {
+		snapshot.refIds[kintone.AppStudentInfo] = append(snapshot.refIds[kintone.AppStudentInfo], student.StudentRefId)
+	}
+
+	pointCards, err := srv.pointCardSrv.GetAllKintonePointCards(ctx, &dto.GetPointCardReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("pointCardSrv.GetAllKintonePointCards: %w", err)
+	}
+	for _, pointCard := range pointCards {
+		snapshot.refIds[kintone.AppPointCard] = append(snapshot.refIds[kintone.AppPointCard], pointCard.RecordRefId)
+		key := reconciliationStudentKey{studentName: pointCard.StudentName, parentPhone: pointCard.ParentPhone}
+		snapshot.add(key, reconciliation.FieldPointCardCount, points.FromInt(1))
+		snapshot.add(key, reconciliation.FieldRestPoints, pointCard.RestPoints)
+	}
+
+	schedules, err := srv.scheduleCommonSrv.GetAllKintoneSchedules(ctx, &dto.ScheduleReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("scheduleCommonSrv.GetAllKintoneSchedules: %w", err)
+	}
+	for _, schedule := range schedules {
+		id, err := schedule.Id.ToId()
+		if err != nil {
+			return nil, xerrors.Errorf("schedule.Id.ToId: %w", err)
+		}
+		snapshot.refIds[kintone.AppScheduleRecord] = append(snapshot.refIds[kintone.AppScheduleRecord], id)
+	}
+
+	depositRecords, err := srv.depositRecordCommonSrv.GetAllKintoneDepositRecords(ctx, &dto.DepositRecordReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("depositRecordCommonSrv.GetAllKintoneDepositRecords: %w", err)
+	}
+	for _, record := range depositRecords {
+		snapshot.refIds[kintone.AppDepositRecord] = append(snapshot.refIds[kintone.AppDepositRecord], record.Id)
+		key := reconciliationStudentKey{studentName: record.StudentName, parentPhone: record.ParentPhone}
+		snapshot.add(key, reconciliation.FieldDepositRecordCount, points.FromInt(1))
+		snapshot.add(key, reconciliation.FieldDepositedPoints, points.FromInt(record.DepositedPoints))
+	}
+
+	reduceRecords, err := srv.reduceRecordCommonSrv.GetAllKintoneReduceRecords(ctx, &dto.ReduceRecordReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("reduceRecordCommonSrv.GetAllKintoneReduceRecords: %w", err)
+	}
+	for _, record := range reduceRecords {
+		snapshot.refIds[kintone.AppReduceRecord] = append(snapshot.refIds[kintone.AppReduceRecord], record.Id)
+		key := reconciliationStudentKey{studentName: record.StudentName, parentPhone: record.ParentPhone}
+		snapshot.add(key, reconciliation.FieldReduceRecordCount, points.FromInt(1))
+		snapshot.add(key, reconciliation.FieldReducePoints, record.ReducePoints)
+	}
+
+	settleRecords, err := srv.semesterSettleRecordSrv.GetAllKintoneSemesterSettleRecords(ctx, &dto.SemesterSettleRecordReq{})
+	if err != nil {
+		return nil, xerrors.Errorf("semesterSettleRecordSrv.GetAllKintoneSemesterSettleRecords: %w", err)
+	}
+	for _, record := range settleRecords {
+		snapshot.refIds[kintone.AppSemesterSettleRecord] = append(snapshot.refIds[kintone.AppSemesterSettleRecord], record.RecordRefId)
+		key := reconciliationStudentKey{studentName: record.StudentName, parentPhone: record.ParentPhone}
+		snapshot.add(key, reconciliation.FieldSemesterSettleRecordCount, points.FromInt(1))
+		snapshot.add(key, reconciliation.FieldClearPoints, record.ClearPoints)
+	}
+
+	return snapshot, nil
+}
+
+// getDbSnapshot 只計算未刪除的記錄，另外回傳未刪除學生的 student id
+func (srv *ReconciliationService) getDbSnapshot(ctx context.Context) (*reconciliationSnapshot, map[reconciliationStudentKey]int64, error) {
+	db := srv.DB.Session()
+	snapshot := newReconciliationSnapshot()
+	isDeleted := false
+
+	// 記錄可能屬於已刪除的學生，學生名稱需要包含已刪除的學生
+	students, err := srv.studentRepo.ListStudents(ctx, db, &po.StudentCond{})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("studentRepo.ListStudents: %w", err)
+	}
+	studentKeys := make(map[int64]reconciliationStudentKey, len(students))
+	studentIds := make(map[reconciliationStudentKey]int64, len(students))
+	for _, student := range students {
+		key := reconciliationStudentKey{studentName: student.StudentName, parentPhone: student.ParentPhone}
+		studentKeys[student.StudentId] = key
+		if student.IsDeleted {
+			continue
+		}
+		studentIds[key] = student.StudentId
+		snapshot.refIds[kintone.AppStudentInfo] = append(snapshot.refIds[kintone.AppStudentInfo], student.StudentRefId)
+	}
+
+	pointCards, err := srv.pointCardRepo.GetPointCards(ctx, db, &po.PointCardCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("pointCardRepo.GetPointCards: %w", err)
+	}
+	for _, pointCard := range pointCards {
+		snapshot.refIds[kintone.AppPointCard] = append(snapshot.refIds[kintone.AppPointCard], pointCard.RecordRefId)
+		key := studentKeys[pointCard.StudentId]
+		snapshot.add(key, reconciliation.FieldPointCardCount, points.FromInt(1))
+		snapshot.add(key, reconciliation.FieldRestPoints, pointCard.RestPoints)
+	}
+
+	snapshot.refIds[kintone.AppScheduleRecord], err = srv.scheduleRepo.GetAllScheduleRefIds(ctx, db, &po.GetScheduleCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("scheduleRepo.GetAllScheduleRefIds: %w", err)
+	}
+
+	snapshot.refIds[kintone.AppDepositRecord], err = srv.depositRecordRepo.GetDepositRecordRefIds(ctx, db, &po.DepositRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("depositRecordRepo.GetDepositRecordRefIds: %w", err)
+	}
+	depositTotals, err := srv.depositRecordRepo.GetStudentTotalDepositPoints(ctx, db, &po.DepositRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("depositRecordRepo.GetStudentTotalDepositPoints: %w", err)
+	}
+	for _, total := range depositTotals {
+		key := studentKeys[total.StudentId]
+		snapshot.add(key, reconciliation.FieldDepositRecordCount, points.FromInt(total.RecordCount))
+		snapshot.add(key, reconciliation.FieldDepositedPoints, points.FromInt(total.TotalDepositedPoints))
+	}
+
+	snapshot.refIds[kintone.AppReduceRecord], err = srv.reduceRecordRepo.GetReduceRecordRefIds(ctx, db, &po.ReduceRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reduceRecordRepo.GetReduceRecordRefIds: %w", err)
+	}
+	reduceTotals, err := srv.reduceRecordRepo.GetStudentTotalReducePoints(ctx, db, &po.ReduceRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reduceRecordRepo.GetStudentTotalReducePoints: %w", err)
+	}
+	for _, total := range reduceTotals {
+		key := studentKeys[total.StudentId]
+		snapshot.add(key, reconciliation.FieldReduceRecordCount, points.FromInt(total.RecordCount))
+		snapshot.add(key, reconciliation.FieldReducePoints, total.TotalReducePoints)
+	}
+
+	snapshot.refIds[kintone.AppSemesterSettleRecord], err = srv.semesterSettleRecordRepo.GetSemesterSettleRecordRefIds(ctx, db, &po.SemesterSettleRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("semesterSettleRecordRepo.GetSemesterSettleRecordRefIds: %w", err)
+	}
+	clearTotals, err := srv.semesterSettleRecordRepo.GetStudentTotalClearPoints(ctx, db, &po.SemesterSettleRecordCond{IsDeleted: &isDeleted})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("semesterSettleRecordRepo.GetStudentTotalClearPoints: %w", err)
+	}
+	for _, total := range clearTotals {
+		key := studentKeys[total.StudentId]
+		snapshot.add(key, reconciliation.FieldSemesterSettleRecordCount, points.FromInt(total.RecordCount))
+		snapshot.add(key, reconciliation.FieldClearPoints, total.TotalClearPoints)
+	}
+
+	return snapshot, studentIds, nil
+}
+
+func buildReconciliationReport(checkedAt time.Time, kintoneSnapshot *reconciliationSnapshot, dbSnapshot *reconciliationSnapshot, studentIds map[reconciliationStudentKey]int64) *bo.ReconciliationReport {
+	report := &bo.ReconciliationReport{
+		CheckedAt: checkedAt,
+		Apps:      make([]*bo.ReconciliationApp, 0, len(reconciliationApps)),
+		Students:  make([]*bo.ReconciliationStudent, 0),
+	}
+	for _, app := range reconciliationApps {
+		report.Apps = append(report.Apps, reconcileRefIds(app, kintoneSnapshot.refIds[app], dbSnapshot.refIds[app]))
+	}
+
+	keys := make([]reconciliationStudentKey, 0, len(kintoneSnapshot.totals))
+	for key := range kintoneSnapshot.totals {
+		keys = append(keys, key)
+	}
+	for key := range dbSnapshot.totals {
+		if _, ok := kintoneSnapshot.totals[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].studentName != keys[j].studentName {
+			return keys[i].studentName < keys[j].studentName
+		}
+		return keys[i].parentPhone < keys[j].parentPhone
+	})
+
+	for _, key := range keys {
+		mismatches := make([]*bo.ReconciliationMismatch, 0)
+		for _, field := range reconciliation.Fields {
+			kintoneValue, dbValue := kintoneSnapshot.totals[key][field], dbSnapshot.totals[key][field]
+			if kintoneValue != dbValue {
+				mismatches = append(mismatches, &bo.ReconciliationMismatch{Field: field, Kintone: kintoneValue, Db: dbValue})
+			}
+		}
+		if len(mismatches) == 0 {
+			continue
+		}
+		report.Students = append(report.Students, &bo.ReconciliationStudent{
+			StudentId:   studentIds[key],
+			StudentName: key.studentName,
+			ParentPhone: key.parentPhone,
+			Mismatches:  mismatches,
+		})
+	}
+
+	return report
+}
+
+// reconcileRefIds 記錄數以兩邊實際的筆數計算，db 有重複的 record ref id 時筆數會不同
+func reconcileRefIds(app kintone.App, kintoneRefIds []int, dbRefIds []int) *bo.ReconciliationApp {
+	kintoneIdMap := make(map[int]struct{}, len(kintoneRefIds))
+	for _, id := range kintoneRefIds {
+		kintoneIdMap[id] = struct{}{}
+	}
+	dbIdMap := make(map[int]struct{}, len(dbRefIds))
+	for _, id := range dbRefIds {
+		dbIdMap[id] = struct{}{}
+	}
+
+	result := &bo.ReconciliationApp{
+		App:              app,
+		KintoneCount:     len(kintoneRefIds),
+		DbCount:          len(dbRefIds),
+		MissingInDb:      make([]int, 0),
+		MissingInKintone: make([]int, 0),
+	}
+	for id := range kintoneIdMap {
+		if _, ok := dbIdMap[id]; !ok {
+			result.MissingInDb = append(result.MissingInDb, id)
+		}
+	}
+	for id := range dbIdMap {
+		if _, ok := kintoneIdMap[id]; !ok {
+			result.MissingInKintone = append(result.MissingInKintone, id)
+		}
+	}
+	sort.Ints(result.MissingInDb)
+	sort.Ints(result.MissingInKintone)
+
+	return result
+}
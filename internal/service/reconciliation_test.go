@@ -0,0 +1,109 @@
+package service
+
+import (
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/reconciliation"
+	"jaystar/internal/model/bo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileRefIds(t *testing.T) {
+	tests := []struct {
+		name       string
+		kintoneIds []int
+		dbIds      []int
+		want       *bo.ReconciliationApp
+	}{
+		{
+			name:       "matched",
+			kintoneIds: []int{3, 1, 2},
+			dbIds:      []int{1, 2, 3},
+			want:       &bo.ReconciliationApp{App: kintone.AppDepositRecord, KintoneCount: 3, DbCount: 3, MissingInDb: []int{}, MissingInKintone: []int{}},
+		},
+		{
+			name:       "missing on both sides",
+			kintoneIds: []int{5, 1, 4},
+			dbIds:      []int{1, 3, 2},
+			want:       &bo.ReconciliationApp{App: kintone.AppDepositRecord, KintoneCount: 3, DbCount: 3, MissingInDb: []int{4, 5}, MissingInKintone: []int{2, 3}},
+		},
+		{
+			name:       "duplicated in db",
+			kintoneIds: []int{1, 2},
+			dbIds:      []int{1, 2, 2},
+			want:       &bo.ReconciliationApp{App: kintone.AppDepositRecord, KintoneCount: 2, DbCount: 3, MissingInDb: []int{}, MissingInKintone: []int{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reconcileRefIds(kintone.AppDepositRecord, tt.kintoneIds, tt.dbIds)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.KintoneCount == tt.want.DbCount && len(tt.want.MissingInDb) == 0 && len(tt.want.MissingInKintone) == 0, got.Matched())
+		})
+	}
+}
+
+func TestBuildReconciliationReport(t *testing.T) {
+	amy := reconciliationStudentKey{studentName: "Amy", parentPhone: "0912345678"}
+	ben := reconciliationStudentKey{studentName: "Ben", parentPhone: "0987654321"}
+	cat := reconciliationStudentKey{studentName: "Cat", parentPhone: "0911111111"}
+
+	kintoneSnapshot := newReconciliationSnapshot()
+	kintoneSnapshot.refIds[kintone.AppReduceRecord] = []int{1, 2, 3}
+	kintoneSnapshot.add(amy, reconciliation.FieldReduceRecordCount, pts(2))
+	kintoneSnapshot.add(amy, reconciliation.FieldReducePoints, pts(0.1))
+	kintoneSnapshot.add(amy, reconciliation.FieldReducePoints, pts(0.2))
+	kintoneSnapshot.add(ben, reconciliation.FieldReduceRecordCount, pts(1))
+	kintoneSnapshot.add(ben, reconciliation.FieldReducePoints, pts(2))
+	kintoneSnapshot.add(ben, reconciliation.FieldRestPoints, pts(10))
+
+	dbSnapshot := newReconciliationSnapshot()
+	dbSnapshot.refIds[kintone.AppReduceRecord] = []int{1, 2}
+	// 0.1 + 0.2 與 0.3 完全相等，不會有浮點誤差
+	dbSnapshot.add(amy, reconciliation.FieldReduceRecordCount, pts(2))
+	dbSnapshot.add(amy, reconciliation.FieldReducePoints, pts(0.3))
+	dbSnapshot.add(ben, reconciliation.FieldRestPoints, pts(12))
+	dbSnapshot.add(cat, reconciliation.FieldDepositRecordCount, pts(1))
+	dbSnapshot.add(cat, reconciliation.FieldDepositedPoints, pts(50))
+
+	checkedAt := time.Date(2026, 10, 18, 3, 30, 0, 0, time.UTC)
+	studentIds := map[reconciliationStudentKey]int64{amy: 1, ben: 2}
+	report := buildReconciliationReport(checkedAt, kintoneSnapshot, dbSnapshot, studentIds)
+
+	assert.Equal(t, checkedAt, report.CheckedAt)
+	assert.Len(t, report.Apps, len(reconciliationApps))
+	for _, app := range report.Apps {
+		if app.App == kintone.AppReduceRecord {
+			assert.Equal(t, []int{3}, app.MissingInDb)
+			assert.False(t, app.Matched())
+			continue
+		}
+		assert.True(t, app.Matched(), app.App.String())
+	}
+
+	assert.Equal(t, []*bo.ReconciliationStudent{
+		{
+			StudentId:   2,
+			StudentName: "Ben",
+			ParentPhone: "0987654321",
+			Mismatches: []*bo.ReconciliationMismatch{
+				{Field: reconciliation.FieldReduceRecordCount, Kintone: pts(1), Db: pts(0)},
+				{Field: reconciliation.FieldReducePoints, Kintone: pts(2), Db: pts(0)},
+				{Field: reconciliation.FieldRestPoints, Kintone: pts(10), Db: pts(12)},
+			},
+		},
+		{
+			StudentId:   0,
+			StudentName: "Cat",
+			ParentPhone: "0911111111",
+			Mismatches: []*bo.ReconciliationMismatch{
+				{Field: reconciliation.FieldDepositRecordCount, Kintone: pts(0), Db: pts(1)},
+				{Field: reconciliation.FieldDepositedPoints, Kintone: pts(0), Db: pts(50)},
+			},
+		},
+	}, report.Students)
+	assert.True(t, report.HasMismatch())
+	assert.Len(t, report.MismatchMessages(), 6)
+}
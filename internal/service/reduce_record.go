@@ -9,8 +9,10 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
+	"jaystar/internal/constant/audit"
 	"jaystar/internal/constant/kintone"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
@@ -30,6 +32,9 @@ func ProvideReduceRecordService(
 	studentCommonSrv interfaces.IStudentCommonSrv,
 	logger logger.ILogger,
 	reduceRecordCommonSrv interfaces.IReduceRecordCommonSrv,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
+	auditLogSrv interfaces.IAuditLogSrv,
 ) *ReduceRecordService {
 	return &ReduceRecordService{
 		recordRepo:            ReduceRecordRepo,
@@ -37,6 +42,9 @@ func ProvideReduceRecordService(
 		studentCommonSrv:      studentCommonSrv,
 		logger:                logger,
 		reduceRecordCommonSrv: reduceRecordCommonSrv,
+		recordLockCommonSrv:   recordLockCommonSrv,
+		kintoneRecordRepo:     kintoneRecordRepo,
+		auditLogSrv:           auditLogSrv,
 		executorPool:          pool.NewExecutorPool(100),
 	}
 }
@@ -47,6 +55,9 @@ type ReduceRecordService struct {
 	studentCommonSrv      interfaces.IStudentCommonSrv
 	logger                logger.ILogger
 	reduceRecordCommonSrv interfaces.IReduceRecordCommonSrv
+	recordLockCommonSrv   interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo     interfaces.IKintoneRecordRepo
+	auditLogSrv           interfaces.IAuditLogSrv
 	executorPool          *ants.Pool `wire:"-"`
 }
 
@@ -201,7 +212,22 @@ func (srv *ReduceRecordService) UpdateReduceRecord(ctx context.Context, cond *bo
 	return nil
 }
 
+// DeleteReduceRecord 處理 kintone 刪除記錄的 webhook，並記錄稽核記錄
 func (srv *ReduceRecordService) DeleteReduceRecord(ctx context.Context, cond *bo.ReduceRecordCond) error {
+	before, err := srv.getReduceRecordSnapshot(ctx, cond.RecordRefId)
+	if err != nil {
+		return xerrors.Errorf("reduceRecordService DeleteReduceRecord getReduceRecordSnapshot: %w", err)
+	}
+
+	if err := srv.deleteReduceRecord(ctx, cond); err != nil {
+		return xerrors.Errorf("reduceRecordService DeleteReduceRecord deleteReduceRecord: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionReduceRecordDelete, cond.RecordRefId, before)
+
+	return nil
+}
+
+func (srv *ReduceRecordService) deleteReduceRecord(ctx context.Context, cond *bo.ReduceRecordCond) error {
 	deleted := true
 	poReduceRecordCond := &po.ReduceRecordCond{
 		RecordRefId: cond.RecordRefId,
@@ -220,53 +246,28 @@ func (srv *ReduceRecordService) DeleteReduceRecord(ctx context.Context, cond *bo
 	return nil
 }
 
-func (srv *ReduceRecordService) BatchSyncReduceRecord(ctx context.Context, cond *bo.SyncReduceRecordCond, wg ...*sync.WaitGroup) error {
-	boReduceRecordReq := &dto.ReduceRecordReq{
-		Limit:  request.GetRecordsBatchLimit,
-		Offset: request.GetRecordsBatchOffset,
-	}
-
-	var (
-		total                int
-		err                  error
-		kintoneReduceRecords []*bo.KintoneReduceRecord
-	)
+func (srv *ReduceRecordService) BatchSyncReduceRecord(ctx context.Context, cond *bo.SyncReduceRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error {
+	boReduceRecordReq := &dto.ReduceRecordReq{}
 
 	if cond != nil {
 		if cond.StudentName != nil && cond.ParentPhone != nil {
 			boReduceRecordReq.StudentName = strUtil.GetFullStudentName(*cond.StudentName, *cond.ParentPhone)
 		}
-		if cond.Limit != nil {
-			boReduceRecordReq.Limit = *cond.Limit
-		}
-		if cond.Offset != nil {
-			boReduceRecordReq.Offset = *cond.Offset
-		}
 		if cond.ClassTimeStart != nil {
 			boReduceRecordReq.ClassTimeStart = *cond.ClassTimeStart
 		}
 		if cond.ClassTimeEnd != nil {
 			boReduceRecordReq.ClassTimeEnd = *cond.ClassTimeEnd
 		}
+		if cond.UpdatedSince != nil {
+			boReduceRecordReq.UpdatedSince = *cond.UpdatedSince
+		}
 	}
 
-	kintoneReduceRecords, total, err = srv.reduceRecordCommonSrv.GetKintoneReduceRecords(ctx, boReduceRecordReq)
+	snapshotAt := time.Now()
+	allRecords, err := srv.reduceRecordCommonSrv.GetAllKintoneReduceRecords(ctx, boReduceRecordReq)
 	if err != nil {
-		return xerrors.Errorf("reduceRecordService BatchSyncReduceRecord GetKintoneReduceRecord: %w", err)
-	}
-
-	allRecords := make([]*bo.KintoneReduceRecord, 0, total)
-	allRecords = append(allRecords, kintoneReduceRecords...)
-
-	offset, limit := boReduceRecordReq.Offset, boReduceRecordReq.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		boReduceRecordReq.Offset = offset * limit
-		kintoneReduceRecords, _, err = srv.reduceRecordCommonSrv.GetKintoneReduceRecords(ctx, boReduceRecordReq)
-		if err != nil {
-			return xerrors.Errorf("reduceRecordService BatchSyncReduceRecord GetKintoneReduceRecord: %w", err)
-		}
-		allRecords = append(allRecords, kintoneReduceRecords...)
+		return xerrors.Errorf("reduceRecordService BatchSyncReduceRecord GetAllKintoneReduceRecords: %w", err)
 	}
 
 	var wait *sync.WaitGroup
@@ -278,13 +279,14 @@ func (srv *ReduceRecordService) BatchSyncReduceRecord(ctx context.Context, cond
 		defer func() {
 			if r := recover(); r != nil {
 				srv.logger.Error(ctx, "reduceRecordService syncReduceRecords panic", nil, zap.Any(logger.PanicMessage, r))
+				tracker.Abort(syncjob.TypeReduceRecord, xerrors.Errorf("panic: %v", r))
 			}
 			if wait != nil {
 				wait.Done()
 			}
 		}()
 
-		srv.syncReduceRecords(ctx, allRecords, cond)
+		srv.syncReduceRecords(ctx, allRecords, cond, snapshotAt, tracker)
 	}()
 
 	return nil
@@ -323,7 +325,15 @@ func (srv *ReduceRecordService) GetStudentTotalReducePoints(ctx context.Context,
 	return boStudentTotalReducePoints, nil
 }
 
-func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecords []*bo.KintoneReduceRecord, cond *bo.SyncReduceRecordCond) {
+func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecords []*bo.KintoneReduceRecord, cond *bo.SyncReduceRecordCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourceReduceRecord, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "reduceRecordService syncReduceRecords recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypeReduceRecord, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentRecordRefIdMap := map[int]struct{}{}
 	for _, reduceRecord := range allRecords {
@@ -336,12 +346,23 @@ func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecord
 						zap.Any(logger.PanicMessage, r),
 						zap.Any("reduceRecord", *rr),
 					)
+					tracker.Track(syncjob.TypeReduceRecord, rr.Id, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
-			if err := srv.syncReduceRecord(ctx, rr); err != nil {
-				srv.logger.Error(ctx, "reduceRecordService syncReduceRecords syncReduceRecord", err, zap.Int("record_ref_id", rr.Id), zap.String("student_name", rr.KintoneStudentName))
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, rr.Id, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffReduceRecord(ctx, rr, tracker)
+					return err
+				}
+				action, err = srv.syncReduceRecord(ctx, rr)
+				return err
+			})
+			if err != nil {
+				srv.logger.Error(ctx, "reduceRecordService syncReduceRecords SyncReduceRecord", err, zap.Int("record_ref_id", rr.Id), zap.String("student_name", rr.KintoneStudentName))
 			}
+			tracker.Track(syncjob.TypeReduceRecord, rr.Id, action, err)
 		})
 
 		currentRecordRefIdMap[rr.Id] = struct{}{}
@@ -349,6 +370,13 @@ func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecord
 
 	wg.Wait()
 
+	currentRecordRefIdMap, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppReduceRecord, cond != nil && cond.UpdatedSince != nil, currentRecordRefIdMap)
+	if err != nil {
+		srv.logger.Error(ctx, "reduceRecordService syncReduceRecords getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypeReduceRecord, err)
+		return
+	}
+
 	db := srv.DB.Session()
 	isDeleted := false
 	poReduceRecordCond := &po.ReduceRecordCond{
@@ -363,6 +391,7 @@ func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecord
 						zap.String("student_name", *cond.StudentName),
 						zap.String("parent_phone", *cond.ParentPhone),
 					)
+					tracker.Abort(syncjob.TypeReduceRecord, errs.StudentErr.StudentNotFoundErr)
 					return
 				}
 
@@ -370,6 +399,7 @@ func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecord
 					zap.String("student_name", *cond.StudentName),
 					zap.String("parent_phone", *cond.ParentPhone),
 				)
+				tracker.Abort(syncjob.TypeReduceRecord, err)
 
 				return
 			}
@@ -386,29 +416,85 @@ func (srv *ReduceRecordService) syncReduceRecords(ctx context.Context, allRecord
 	recordRefIds, err := srv.recordRepo.GetReduceRecordRefIds(ctx, db, poReduceRecordCond)
 	if err != nil {
 		srv.logger.Error(ctx, "reduceRecordService syncReduceRecords GetReduceRecordRefIds", err)
+		tracker.Abort(syncjob.TypeReduceRecord, err)
 		return
 	}
 
 	for _, recordRefId := range recordRefIds {
 		if _, found := currentRecordRefIdMap[recordRefId]; !found {
-			if err := srv.DeleteReduceRecord(ctx, &bo.ReduceRecordCond{RecordRefId: recordRefId}); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, recordRefId, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeReduceRecord, RecordRefId: recordRefId})
+					return nil
+				}
+				return srv.deleteReduceRecord(ctx, &bo.ReduceRecordCond{RecordRefId: recordRefId})
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "reduceRecordService syncReduceRecords DeleteReduceRecord", err, zap.Int("record_ref_id", recordRefId))
 			}
+			tracker.Track(syncjob.TypeReduceRecord, recordRefId, action, err)
+		}
+	}
+}
+
+// SyncReduceRecord 處理 kintone 新增或更新記錄的 webhook，以 kintone 上的記錄新增或更新 db 的記錄，並記錄稽核記錄
+func (srv *ReduceRecordService) SyncReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord) error {
+	before, err := srv.getReduceRecordSnapshot(ctx, data.Id)
+	if err != nil {
+		return xerrors.Errorf("reduceRecordService SyncReduceRecord getReduceRecordSnapshot: %w", err)
+	}
+
+	if _, err := srv.syncReduceRecord(ctx, data); err != nil {
+		return err
+	}
+	srv.addAuditLog(ctx, audit.ActionReduceRecordSync, data.Id, before)
+
+	return nil
+}
+
+// getReduceRecordSnapshot 記錄不存在時回傳 nil
+func (srv *ReduceRecordService) getReduceRecordSnapshot(ctx context.Context, recordRefId int) (*po.ReduceRecord, error) {
+	record, err := srv.recordRepo.GetRecord(ctx, srv.DB.Session(), &po.ReduceRecordCond{RecordRefId: recordRefId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, nil
 		}
+		return nil, xerrors.Errorf("recordRepo.GetRecord: %w", err)
 	}
+
+	return record, nil
 }
 
-func (srv *ReduceRecordService) syncReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord) error {
+// addAuditLog 以 kintone 記錄 id 為 target id，記錄異動前後 db 的記錄
+func (srv *ReduceRecordService) addAuditLog(ctx context.Context, action audit.Action, recordRefId int, before *po.ReduceRecord) {
+	after, err := srv.getReduceRecordSnapshot(ctx, recordRefId)
+	if err != nil {
+		srv.logger.Error(ctx, "reduceRecordService addAuditLog getReduceRecordSnapshot", err, zap.Int("record_ref_id", recordRefId))
+		return
+	}
+
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetReduceRecord, TargetId: int64(recordRefId), Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "reduceRecordService addAuditLog auditLogSrv.AddAuditLog", err, zap.Int("record_ref_id", recordRefId))
+	}
+}
+
+func (srv *ReduceRecordService) syncReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord) (syncjob.Action, error) {
 	db := srv.DB.Session()
 	poReduceRecordCond := &po.ReduceRecordCond{RecordRefId: data.Id}
 	record, err := srv.recordRepo.GetRecord(ctx, db, poReduceRecordCond)
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return xerrors.Errorf("reduceRecordService syncReduceRecord recordRepo.GetRecord: %w", err)
+		return "", xerrors.Errorf("reduceRecordService SyncReduceRecord recordRepo.GetRecord: %w", err)
 	}
 
 	boStudentCond := &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone}
+	var action syncjob.Action
 	if record != nil {
 		// Update
+		action = syncjob.ActionUpdated
 		boReduceRecordCond := &bo.ReduceRecordCond{RecordRefId: data.Id}
 		boUpdateReduceRecordData := &bo.UpdateReduceRecordData{
 			ClassLevel:   data.ClassLevel,
@@ -419,7 +505,7 @@ func (srv *ReduceRecordService) syncReduceRecord(ctx context.Context, data *bo.K
 			IsAttended:   &data.AttendStatus,
 		}
 		if err := srv.UpdateReduceRecord(ctx, boReduceRecordCond, boStudentCond, boUpdateReduceRecordData); err != nil {
-			return xerrors.Errorf("reduceRecordService syncReduceRecord UpdateReduceRecord: %w", err)
+			return "", xerrors.Errorf("reduceRecordService SyncReduceRecord UpdateReduceRecord: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -428,15 +514,52 @@ func (srv *ReduceRecordService) syncReduceRecord(ctx context.Context, data *bo.K
 				db.Where("record_id = ?", record.RecordId)
 				return db
 			}); err != nil {
-				return xerrors.Errorf("reduceRecordService syncReduceRecord ResetFromDeleted: %w", err)
+				return "", xerrors.Errorf("reduceRecordService SyncReduceRecord ResetFromDeleted: %w", err)
 			}
 		}
 	} else {
 		// Create
+		action = syncjob.ActionCreated
 		if err := srv.AddReduceRecord(ctx, data, boStudentCond); err != nil {
-			return xerrors.Errorf("reduceRecordService syncReduceRecord AddReduceRecord: %w", err)
+			return "", xerrors.Errorf("reduceRecordService SyncReduceRecord AddReduceRecord: %w", err)
 		}
 	}
 
-	return nil
+	return action, nil
+}
+
+// diffReduceRecord dry run 時計算 syncReduceRecord 會套用的變更，不寫入 db
+func (srv *ReduceRecordService) diffReduceRecord(ctx context.Context, data *bo.KintoneReduceRecord, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	if len(data.StudentName) == 0 || len(data.ParentPhone) == 0 {
+		return "", errs.ReduceRecordErr.InvalidStudentNameErr
+	}
+
+	record, err := srv.recordRepo.GetRecord(ctx, srv.DB.Session(), &po.ReduceRecordCond{RecordRefId: data.Id})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("reduceRecordService diffReduceRecord recordRepo.GetRecord: %w", err)
+	}
+
+	boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return "", xerrors.Errorf("reduceRecordService diffReduceRecord studentCommonSrv.GetStudent: %w", errs.StudentErr.StudentNotFoundErr)
+		}
+
+		return "", xerrors.Errorf("reduceRecordService diffReduceRecord studentCommonSrv.GetStudent: %w", err)
+	}
+
+	differ := newSyncFieldDiffer(record == nil)
+	if record == nil {
+		record = &po.ReduceRecord{}
+	}
+	differ.compare("student_id", record.StudentId, boStudent.StudentId)
+	differ.compare("class_type", record.ClassType, data.ClassType.ToKey())
+	differ.compare("class_level", record.ClassLevel, data.ClassLevel.ToKey())
+	differ.compare("class_time", record.ClassTime, data.ClassTime)
+	differ.compare("teacher_name", record.TeacherName, data.TeacherName)
+	differ.compare("reduce_points", record.ReducePoints, data.ReducePoints)
+	differ.compare("is_attended", record.IsAttended, data.AttendStatus)
+
+	diffRecord := &bo.SyncDiffRecord{Type: syncjob.TypeReduceRecord, RecordRefId: data.Id, Changes: differ.changes}
+	return trackSyncDiff(tracker, diffRecord, !differ.created, record.IsDeleted || record.DeletedAt != nil), nil
 }
@@ -11,7 +11,8 @@ import (
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
 	"jaystar/internal/constant/kintone"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
@@ -44,24 +45,30 @@ func ProvideScheduleService(
 	db database.IPostgresDB,
 	logger logger.ILogger,
 	scheduleCommonSrv interfaces.IScheduleCommonSrv,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
 ) *ScheduleService {
 	return &ScheduleService{
-		studentCommonSrv:  studentCommonSrv,
-		scheduleRepo:      scheduleRepo,
-		db:                db,
-		logger:            logger,
-		scheduleCommonSrv: scheduleCommonSrv,
-		executorPool:      pool.NewExecutorPool(100),
+		studentCommonSrv:    studentCommonSrv,
+		scheduleRepo:        scheduleRepo,
+		db:                  db,
+		logger:              logger,
+		scheduleCommonSrv:   scheduleCommonSrv,
+		recordLockCommonSrv: recordLockCommonSrv,
+		kintoneRecordRepo:   kintoneRecordRepo,
+		executorPool:        pool.NewExecutorPool(100),
 	}
 }
 
 type ScheduleService struct {
-	studentCommonSrv  interfaces.IStudentCommonSrv
-	scheduleRepo      interfaces.IScheduleRepo
-	db                database.IPostgresDB
-	logger            logger.ILogger
-	scheduleCommonSrv interfaces.IScheduleCommonSrv
-	executorPool      *ants.Pool `wire:"-"`
+	studentCommonSrv    interfaces.IStudentCommonSrv
+	scheduleRepo        interfaces.IScheduleRepo
+	db                  database.IPostgresDB
+	logger              logger.ILogger
+	scheduleCommonSrv   interfaces.IScheduleCommonSrv
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo   interfaces.IKintoneRecordRepo
+	executorPool        *ants.Pool `wire:"-"`
 }
 
 func (srv *ScheduleService) GetSchedules(ctx context.Context, cond *bo.GetScheduleCond, studentCond *bo.StudentCond) ([]*bo.Schedule, *po.PagerResult, error) {
@@ -274,20 +281,11 @@ func (srv *ScheduleService) DeleteSchedule(ctx context.Context, cond *bo.UpdateS
 	return nil
 }
 
-func (srv *ScheduleService) BatchSyncSchedule(ctx context.Context, cond *bo.SyncScheduleCond, wg ...*sync.WaitGroup) error {
-	var (
-		total            int
-		err              error
-		kintoneSchedules []dto.ScheduleRecord
-	)
-
-	boScheduleReq := &dto.ScheduleReq{
-		Limit:  request.GetRecordsBatchLimit,
-		Offset: request.GetRecordsBatchOffset,
-	}
+func (srv *ScheduleService) BatchSyncSchedule(ctx context.Context, cond *bo.SyncScheduleCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error {
+	boScheduleReq := &dto.ScheduleReq{}
 	if cond != nil {
 		if cond.StudentName != nil && cond.ParentPhone != nil {
-			boScheduleReq.StudentName = strUtil.GetFullStudentName(*cond.StudentName, *cond.ParentPhone)
+			boScheduleReq.StudentNames = []string{strUtil.GetFullStudentName(*cond.StudentName, *cond.ParentPhone)}
 		}
 		if cond.ClassTimeStart != nil {
 			boScheduleReq.ClassTimeStart = *cond.ClassTimeStart
@@ -295,31 +293,15 @@ func (srv *ScheduleService) BatchSyncSchedule(ctx context.Context, cond *bo.Sync
 		if cond.ClassTimeEnd != nil {
 			boScheduleReq.ClassTimeEnd = *cond.ClassTimeEnd
 		}
-		if cond.Limit != nil {
-			boScheduleReq.Limit = *cond.Limit
-		}
-		if cond.Offset != nil {
-			boScheduleReq.Offset = *cond.Offset
+		if cond.UpdatedSince != nil {
+			boScheduleReq.UpdatedSince = *cond.UpdatedSince
 		}
 	}
 
-	kintoneSchedules, total, err = srv.scheduleCommonSrv.GetKintoneSchedules(ctx, boScheduleReq)
+	snapshotAt := time.Now()
+	allRecords, err := srv.scheduleCommonSrv.GetAllKintoneSchedules(ctx, boScheduleReq)
 	if err != nil {
-		return xerrors.Errorf("scheduleService BatchSyncSchedule getKintoneSchedule: %w", err)
-	}
-
-	allRecords := make([]dto.ScheduleRecord, 0, total)
-	allRecords = append(allRecords, kintoneSchedules...)
-
-	offset, limit := boScheduleReq.Offset, boScheduleReq.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		boScheduleReq.Offset = offset * limit
-		kintoneSchedules, _, err = srv.scheduleCommonSrv.GetKintoneSchedules(ctx, boScheduleReq)
-		if err != nil {
-			return xerrors.Errorf("scheduleService BatchSyncSchedule getKintoneSchedule: %w", err)
-		}
-		allRecords = append(allRecords, kintoneSchedules...)
+		return xerrors.Errorf("scheduleService BatchSyncSchedule GetAllKintoneSchedules: %w", err)
 	}
 
 	var wait *sync.WaitGroup
@@ -331,23 +313,45 @@ func (srv *ScheduleService) BatchSyncSchedule(ctx context.Context, cond *bo.Sync
 		defer func() {
 			if r := recover(); r != nil {
 				srv.logger.Error(ctx, "scheduleService BatchSyncSchedule panic", nil, zap.Any(logger.PanicMessage, r))
+				tracker.Abort(syncjob.TypeSchedule, xerrors.Errorf("panic: %v", r))
 			}
 			if wait != nil {
 				wait.Done()
 			}
 		}()
 
-		srv.syncSchedules(ctx, allRecords, cond)
+		srv.syncSchedules(ctx, allRecords, cond, snapshotAt, tracker)
 	}()
 	return nil
 }
 
-func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.ScheduleRecord, cond *bo.SyncScheduleCond) {
+func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.ScheduleRecord, cond *bo.SyncScheduleCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourceSchedule, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "scheduleService syncSchedules recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypeSchedule, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentScheduleRefIdMap := map[int]struct{}{}
 	for _, schedule := range allRecords {
-		wg.Add(1)
 		s := schedule
+		scheduleRefId, err := s.Id.ToId()
+		if err != nil {
+			srv.logger.Error(ctx, "scheduleService syncSchedules scheduleId ToId", err,
+				zap.String("record_id", s.Id.Value),
+				zap.String("teacher_name", s.TeacherName.Value),
+				zap.String("class_type", s.ClassType.Value),
+				zap.String("class_level", s.ClassLevel.Value),
+				zap.String("class_time", s.ClassTime.Value),
+			)
+			tracker.Track(syncjob.TypeSchedule, 0, syncjob.ActionSkipped, err)
+			continue
+		}
+
+		wg.Add(1)
 		srv.executorPool.Submit(func() {
 			defer func() {
 				if r := recover(); r != nil {
@@ -355,31 +359,37 @@ func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.
 						zap.Any(logger.PanicMessage, r),
 						zap.Any("schedule", s),
 					)
+					tracker.Track(syncjob.TypeSchedule, scheduleRefId, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			if err := srv.syncSchedule(ctx, s); err != nil {
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, scheduleRefId, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffSchedule(ctx, s, tracker)
+					return err
+				}
+				action, err = srv.syncSchedule(ctx, s)
+				return err
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "scheduleService syncSchedules syncSchedule", err, zap.String("record_id", s.Id.Value))
 			}
+			tracker.Track(syncjob.TypeSchedule, scheduleRefId, action, err)
 		})
 
-		scheduleRefId, err := s.Id.ToId()
-		if err != nil {
-			srv.logger.Error(ctx, "scheduleService syncSchedules scheduleId ToId", err,
-				zap.String("record_id", s.Id.Value),
-				zap.String("teacher_name", s.TeacherName.Value),
-				zap.String("class_type", s.ClassType.Value),
-				zap.String("class_level", s.ClassLevel.Value),
-				zap.String("class_time", s.ClassTime.Value),
-			)
-			continue
-		}
-
 		currentScheduleRefIdMap[scheduleRefId] = struct{}{}
 	}
 	wg.Wait()
 
+	currentScheduleRefIdMap, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppScheduleRecord, cond != nil && cond.UpdatedSince != nil, currentScheduleRefIdMap)
+	if err != nil {
+		srv.logger.Error(ctx, "scheduleService syncSchedules getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypeSchedule, err)
+		return
+	}
+
 	// 刪除已經不存在 kintone 的 schedules
 	db := srv.db.Session()
 	isDeleted := false
@@ -395,6 +405,7 @@ func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.
 						zap.String("student_name", *cond.StudentName),
 						zap.String("parent_phone", *cond.ParentPhone),
 					)
+					tracker.Abort(syncjob.TypeSchedule, errs.StudentErr.StudentNotFoundErr)
 					return
 				}
 
@@ -402,6 +413,7 @@ func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.
 					zap.String("student_name", *cond.StudentName),
 					zap.String("parent_phone", *cond.ParentPhone),
 				)
+				tracker.Abort(syncjob.TypeSchedule, err)
 
 				return
 			}
@@ -418,21 +430,34 @@ func (srv *ScheduleService) syncSchedules(ctx context.Context, allRecords []dto.
 	allScheduleRefIdsInDb, err := srv.scheduleRepo.GetAllScheduleRefIds(ctx, db, poScheduleCond)
 	if err != nil {
 		srv.logger.Error(ctx, "scheduleService BatchSyncSchedule GetAllScheduleRefIds", err)
+		tracker.Abort(syncjob.TypeSchedule, err)
 		return
 	}
 	for _, scheduleRefIdInDb := range allScheduleRefIdsInDb {
 		if _, found := currentScheduleRefIdMap[scheduleRefIdInDb]; !found {
-			if err := srv.DeleteSchedule(ctx, &bo.UpdateScheduleCond{ScheduleRefId: scheduleRefIdInDb}); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, scheduleRefIdInDb, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeSchedule, RecordRefId: scheduleRefIdInDb})
+					return nil
+				}
+				return srv.DeleteSchedule(ctx, &bo.UpdateScheduleCond{ScheduleRefId: scheduleRefIdInDb})
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "scheduleService BatchSyncSchedule DeleteSchedule", err, zap.Int("schedule_ref_id", scheduleRefIdInDb))
 			}
+			tracker.Track(syncjob.TypeSchedule, scheduleRefIdInDb, action, err)
 		}
 	}
 }
 
-func (srv *ScheduleService) syncSchedule(ctx context.Context, rawSchedule dto.ScheduleRecord) error {
+// syncSchedule 課表的每個學生為 db 的一筆記錄，任一學生同步失敗時回傳錯誤
+func (srv *ScheduleService) syncSchedule(ctx context.Context, rawSchedule dto.ScheduleRecord) (syncjob.Action, error) {
 	boKintoneSchedules, err := rawSchedule.ToSchedules()
 	if err != nil {
-		return xerrors.Errorf("scheduleService syncSchedule schedule.ToSchedules: %w", err)
+		return "", xerrors.Errorf("scheduleService syncSchedule schedule.ToSchedules: %w", err)
 	}
 	// 這邊不用檢查 ToId error 因為在 checkBasicRequestData 中的 ToSchedules 已經有檢查過
 	scheduleRefId, _ := rawSchedule.Id.ToId()
@@ -441,7 +466,7 @@ func (srv *ScheduleService) syncSchedule(ctx context.Context, rawSchedule dto.Sc
 	db := srv.db.Session()
 	schedulesInDb, err := srv.scheduleRepo.GetSchedulesByRefId(ctx, db, scheduleRefId)
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return xerrors.Errorf("scheduleService syncSchedule scheduleSrv.GetSchedulesByRefId: %w", err)
+		return "", xerrors.Errorf("scheduleService syncSchedule scheduleSrv.GetSchedulesByRefId: %w", err)
 	}
 
 	// 失敗記錄
@@ -491,7 +516,7 @@ func (srv *ScheduleService) syncSchedule(ctx context.Context, rawSchedule dto.Sc
 					return db
 				}); err != nil {
 					failedRecords = append(failedRecords, FailedRecord{ScheduleId: scheduleInDb.ScheduleId, ScheduleRefId: scheduleInDb.ScheduleRefId, Name: scheduleInDb.StudentName})
-					return xerrors.Errorf("scheduleService syncSchedule ResetFromDeleted: %w", err)
+					return "", xerrors.Errorf("scheduleService syncSchedule ResetFromDeleted: %w", err)
 				}
 			}
 		}
@@ -511,7 +536,92 @@ func (srv *ScheduleService) syncSchedule(ctx context.Context, rawSchedule dto.Sc
 
 	if len(failedRecords) > 0 {
 		srv.logger.Warn(ctx, "sync failed schedule records", zap.ObjectValues("records", failedRecords))
+		return "", xerrors.Errorf("scheduleService syncSchedule failed records: %d", len(failedRecords))
 	}
 
-	return nil
+	if len(schedulesInDb) == 0 {
+		return syncjob.ActionCreated, nil
+	}
+	return syncjob.ActionUpdated, nil
+}
+
+// diffSchedule dry run 時計算 syncSchedule 會套用的變更，不寫入 db
+// 一筆課表對應每個學生一筆 db 記錄，以 Key 區分學生
+func (srv *ScheduleService) diffSchedule(ctx context.Context, rawSchedule dto.ScheduleRecord, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	boKintoneSchedules, err := rawSchedule.ToSchedules()
+	if err != nil {
+		return "", xerrors.Errorf("scheduleService diffSchedule schedule.ToSchedules: %w", err)
+	}
+	scheduleRefId, _ := rawSchedule.Id.ToId()
+
+	schedulesInDb, err := srv.scheduleRepo.GetSchedulesByRefId(ctx, srv.db.Session(), scheduleRefId)
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("scheduleService diffSchedule scheduleRepo.GetSchedulesByRefId: %w", err)
+	}
+
+	schedulesInDbMap := map[bo.StudentCond]*po.ScheduleView{}
+	for _, scheduleInDb := range schedulesInDb {
+		schedulesInDbMap[bo.StudentCond{StudentName: scheduleInDb.StudentName, ParentPhone: scheduleInDb.ParentPhone}] = scheduleInDb
+	}
+
+	failedRecords := 0
+	action := syncjob.ActionUnchanged
+	currentRecordStudentNames := map[bo.StudentCond]struct{}{}
+	for _, kintoneSchedule := range boKintoneSchedules {
+		studentCond := bo.StudentCond{StudentName: kintoneSchedule.StudentName, ParentPhone: kintoneSchedule.ParentPhone}
+		currentRecordStudentNames[studentCond] = struct{}{}
+
+		if len(studentCond.StudentName) == 0 || len(studentCond.ParentPhone) == 0 {
+			failedRecords++
+			continue
+		}
+		boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &studentCond)
+		if err != nil {
+			srv.logger.Error(ctx, "scheduleService diffSchedule studentCommonSrv.GetStudent", err, zap.String("student_name", kintoneSchedule.StudentName))
+			failedRecords++
+			continue
+		}
+
+		scheduleInDb := schedulesInDbMap[studentCond]
+		differ := newSyncFieldDiffer(scheduleInDb == nil)
+		record := &po.Schedule{}
+		if scheduleInDb != nil {
+			record = &scheduleInDb.Schedule
+		}
+		differ.compare("student_id", record.StudentId, boStudent.StudentId)
+		differ.compare("class_type", record.ClassType, kintoneSchedule.ClassType.ToKey())
+		differ.compare("class_level", record.ClassLevel, kintoneSchedule.ClassLevel.ToKey())
+		differ.compare("class_time", record.ClassTime, kintoneSchedule.ClassTime)
+		differ.compare("teacher_name", record.TeacherName, kintoneSchedule.TeacherName)
+
+		diffRecord := &bo.SyncDiffRecord{
+			Type:        syncjob.TypeSchedule,
+			RecordRefId: scheduleRefId,
+			Key:         strUtil.GetFullStudentName(studentCond.StudentName, studentCond.ParentPhone),
+			Changes:     differ.changes,
+		}
+		if trackSyncDiff(tracker, diffRecord, scheduleInDb != nil, scheduleInDb != nil && (record.IsDeleted || record.DeletedAt != nil)) != syncjob.ActionUnchanged {
+			action = syncjob.ActionUpdated
+		}
+	}
+	for _, scheduleInDb := range schedulesInDb {
+		studentCond := bo.StudentCond{StudentName: scheduleInDb.StudentName, ParentPhone: scheduleInDb.ParentPhone}
+		if _, found := currentRecordStudentNames[studentCond]; !found {
+			tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{
+				Type:        syncjob.TypeSchedule,
+				RecordRefId: scheduleRefId,
+				Key:         strUtil.GetFullStudentName(studentCond.StudentName, studentCond.ParentPhone),
+			})
+			action = syncjob.ActionUpdated
+		}
+	}
+
+	if failedRecords > 0 {
+		return "", xerrors.Errorf("scheduleService diffSchedule failed records: %d", failedRecords)
+	}
+
+	if len(schedulesInDb) == 0 {
+		return syncjob.ActionCreated, nil
+	}
+	return action, nil
 }
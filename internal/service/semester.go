@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"strings"
+	"time"
+)
+
+func ProvideSemesterService(db database.IPostgresDB, semesterRepo interfaces.ISemesterRepo, settlementRunRepo interfaces.ISettlementRunRepo, auditLogSrv interfaces.IAuditLogSrv, logger logger.ILogger) *SemesterService {
+	return &SemesterService{
+		DB:                db,
+		semesterRepo:      semesterRepo,
+		settlementRunRepo: settlementRunRepo,
+		auditLogSrv:       auditLogSrv,
+		logger:            logger,
+	}
+}
+
+type SemesterService struct {
+	DB                database.IPostgresDB
+	semesterRepo      interfaces.ISemesterRepo
+	settlementRunRepo interfaces.ISettlementRunRepo
+	auditLogSrv       interfaces.IAuditLogSrv
+	logger            logger.ILogger
+}
+
+func (srv *SemesterService) GetSemesters(ctx context.Context) ([]*bo.Semester, error) {
+	poSemesters, err := srv.semesterRepo.GetSemesters(ctx, srv.DB.Session(), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService GetSemesters semesterRepo.GetSemesters: %w", err)
+	}
+
+	semesters := make([]*bo.Semester, 0, len(poSemesters))
+	for _, poSemester := range poSemesters {
+		semesters = append(semesters, toSemesterBo(poSemester))
+	}
+
+	return semesters, nil
+}
+
+func (srv *SemesterService) GetSemester(ctx context.Context, semesterId int64) (*bo.Semester, error) {
+	poSemester, err := srv.semesterRepo.GetSemester(ctx, srv.DB.Session(), &po.SemesterCond{SemesterId: semesterId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("semesterService GetSemester semesterRepo.GetSemester: %w", errs.SemesterErr.SemesterNotFoundError)
+		}
+		return nil, xerrors.Errorf("semesterService GetSemester semesterRepo.GetSemester: %w", err)
+	}
+
+	return toSemesterBo(poSemester), nil
+}
+
+func (srv *SemesterService) CreateSemester(ctx context.Context, semester *bo.Semester) (*bo.Semester, error) {
+	if err := validateSemester(semester); err != nil {
+		return nil, xerrors.Errorf("semesterService CreateSemester validateSemester: %w", err)
+	}
+
+	semesters, err := srv.GetSemesters(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService CreateSemester GetSemesters: %w", err)
+	}
+	if err := checkSemesterConflict(semester, semesters); err != nil {
+		return nil, xerrors.Errorf("semesterService CreateSemester checkSemesterConflict: %w", err)
+	}
+
+	semesterId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService CreateSemester autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+
+	poSemester := &po.Semester{
+		SemesterId: semesterId,
+		Name:       semester.Name,
+		StartDate:  semester.StartDate,
+		EndDate:    semester.EndDate,
+		SettleDate: semester.SettleDate,
+		TimeZone:   semester.TimeZone,
+	}
+	if err := srv.semesterRepo.AddSemester(ctx, srv.DB.Session(), poSemester); err != nil {
+		if errors.Is(err, errs.DbErr.UniqueViolation) {
+			return nil, xerrors.Errorf("semesterService CreateSemester semesterRepo.AddSemester: %w", errs.SemesterErr.SemesterConflictError)
+		}
+		return nil, xerrors.Errorf("semesterService CreateSemester semesterRepo.AddSemester: %w", err)
+	}
+
+	created, err := srv.GetSemester(ctx, semesterId)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService CreateSemester GetSemester: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionSemesterCreate, semesterId, nil, created)
+
+	return created, nil
+}
+
+func (srv *SemesterService) UpdateSemester(ctx context.Context, semester *bo.Semester) (*bo.Semester, error) {
+	if err := validateSemester(semester); err != nil {
+		return nil, xerrors.Errorf("semesterService UpdateSemester validateSemester: %w", err)
+	}
+
+	before, err := srv.GetSemester(ctx, semester.SemesterId)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService UpdateSemester GetSemester: %w", err)
+	}
+
+	semesters, err := srv.GetSemesters(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService UpdateSemester GetSemesters: %w", err)
+	}
+	if err := checkSemesterConflict(semester, semesters); err != nil {
+		return nil, xerrors.Errorf("semesterService UpdateSemester checkSemesterConflict: %w", err)
+	}
+
+	err = srv.semesterRepo.UpdateSemester(ctx, srv.DB.Session(), &po.UpdateSemesterCond{SemesterId: semester.SemesterId}, &po.UpdateSemesterData{
+		Name:       semester.Name,
+		StartDate:  semester.StartDate,
+		EndDate:    semester.EndDate,
+		SettleDate: semester.SettleDate,
+		TimeZone:   semester.TimeZone,
+	})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.UniqueViolation) {
+			return nil, xerrors.Errorf("semesterService UpdateSemester semesterRepo.UpdateSemester: %w", errs.SemesterErr.SemesterConflictError)
+		}
+		return nil, xerrors.Errorf("semesterService UpdateSemester semesterRepo.UpdateSemester: %w", err)
+	}
+
+	updated, err := srv.GetSemester(ctx, semester.SemesterId)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService UpdateSemester GetSemester: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionSemesterUpdate, semester.SemesterId, before, updated)
+
+	return updated, nil
+}
+
+// DeleteSemester 已經有結算記錄的學期不能刪除，否則結算與撤銷結算找不到對應的學期
+func (srv *SemesterService) DeleteSemester(ctx context.Context, semesterId int64) error {
+	before, err := srv.GetSemester(ctx, semesterId)
+	if err != nil {
+		return xerrors.Errorf("semesterService DeleteSemester GetSemester: %w", err)
+	}
+
+	db := srv.DB.Session()
+	if _, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{SemesterId: semesterId}); err == nil {
+		return xerrors.Errorf("semesterService DeleteSemester: %w", errs.SemesterErr.SemesterSettledError)
+	} else if !errors.Is(err, errs.DbErr.NoRow) {
+		return xerrors.Errorf("semesterService DeleteSemester settlementRunRepo.GetRun: %w", err)
+	}
+
+	if err := srv.semesterRepo.DeleteSemester(ctx, db, &po.SemesterCond{SemesterId: semesterId}); err != nil {
+		return xerrors.Errorf("semesterService DeleteSemester semesterRepo.DeleteSemester: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionSemesterDelete, semesterId, before, nil)
+
+	return nil
+}
+
+func (srv *SemesterService) addAuditLog(ctx context.Context, action audit.Action, semesterId int64, before, after *bo.Semester) {
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetSemester, TargetId: semesterId, Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "semesterService addAuditLog auditLogSrv.AddAuditLog", err)
+	}
+}
+
+// GetSettleSemester 取得以 t 當天為結算日的學期，沒有的話回傳 nil
+func (srv *SemesterService) GetSettleSemester(ctx context.Context, t time.Time) (*bo.Semester, error) {
+	semesters, err := srv.GetSemesters(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService GetSettleSemester GetSemesters: %w", err)
+	}
+
+	for _, semester := range semesters {
+		loc, err := semester.Location()
+		if err != nil {
+			return nil, xerrors.Errorf("semesterService GetSettleSemester semester.Location semester_id: %d: %w", semester.SemesterId, errs.SemesterErr.TimeZoneInvalidError)
+		}
+		if semester.IsSettleDate(t, loc) {
+			return semester, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetUpcomingSemester 取得 t 之後最近的結算學期，沒有的話回傳 nil
+func (srv *SemesterService) GetUpcomingSemester(ctx context.Context, t time.Time) (*bo.Semester, error) {
+	semesters, err := srv.GetSemesters(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService GetUpcomingSemester GetSemesters: %w", err)
+	}
+
+	semester, err := upcomingSemester(semesters, t)
+	if err != nil {
+		return nil, xerrors.Errorf("semesterService GetUpcomingSemester upcomingSemester: %w", err)
+	}
+
+	return semester, nil
+}
+
+func upcomingSemester(semesters []*bo.Semester, t time.Time) (*bo.Semester, error) {
+	var upcoming *bo.Semester
+	for _, semester := range semesters {
+		loc, err := semester.Location()
+		if err != nil {
+			return nil, xerrors.Errorf("semester.Location semester_id: %d: %w", semester.SemesterId, errs.SemesterErr.TimeZoneInvalidError)
+		}
+		if semester.SettlesAfter(t, loc) && (upcoming == nil || semester.SettleDate.Before(upcoming.SettleDate)) {
+			upcoming = semester
+		}
+	}
+
+	return upcoming, nil
+}
+
+func validateSemester(semester *bo.Semester) error {
+	if strings.TrimSpace(semester.Name) == "" {
+		return errs.SemesterErr.InvalidSemesterError
+	}
+	if _, err := semester.Location(); semester.TimeZone == "" || err != nil {
+		return errs.SemesterErr.TimeZoneInvalidError
+	}
+	// 結算日必須在學期結束之後，否則會漏掉最後幾天的記錄
+	if semester.EndDate.Before(semester.StartDate) || !semester.SettleDate.After(semester.EndDate) {
+		return errs.SemesterErr.InvalidSemesterError
+	}
+
+	return nil
+}
+
+// checkSemesterConflict 學期期間不能與其他學期重疊，也不能使用相同的結算日
+func checkSemesterConflict(semester *bo.Semester, semesters []*bo.Semester) error {
+	for _, other := range semesters {
+		if other.SemesterId == semester.SemesterId {
+			continue
+		}
+		if !semester.StartDate.After(other.EndDate) && !other.StartDate.After(semester.EndDate) {
+			return errs.SemesterErr.SemesterConflictError
+		}
+		if semester.SettleDate.Equal(other.SettleDate) {
+			return errs.SemesterErr.SemesterConflictError
+		}
+	}
+
+	return nil
+}
+
+func toSemesterBo(poSemester *po.Semester) *bo.Semester {
+	return &bo.Semester{
+		SemesterId: poSemester.SemesterId,
+		Name:       poSemester.Name,
+		StartDate:  poSemester.StartDate,
+		EndDate:    poSemester.EndDate,
+		SettleDate: poSemester.SettleDate,
+		TimeZone:   poSemester.TimeZone,
+		CreatedAt:  poSemester.CreatedAt,
+		UpdatedAt:  poSemester.UpdatedAt,
+	}
+}
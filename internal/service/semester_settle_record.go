@@ -3,27 +3,27 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
 	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
 	"github.com/panjf2000/ants"
 	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
+	"jaystar/internal/constant/audit"
 	"jaystar/internal/constant/kintone"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
-	"jaystar/internal/utils"
 	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/points"
 	"jaystar/internal/utils/pool"
 	"jaystar/internal/utils/strUtil"
 	"reflect"
-	"slices"
-	"strconv"
 	"sync"
 	"time"
 )
@@ -38,6 +38,11 @@ type SemesterSettleRecordService struct {
 	studentSrv                      interfaces.IStudentSrv
 	studentCommonSrv                interfaces.IStudentCommonSrv
 	pointCardSrv                    interfaces.IPointCardSrv
+	recordLockCommonSrv             interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo               interfaces.IKintoneRecordRepo
+	semesterSrv                     interfaces.ISemesterSrv
+	settlementRunRepo               interfaces.ISettlementRunRepo
+	auditLogSrv                     interfaces.IAuditLogSrv
 	executorPool                    *ants.Pool `wire:"-"`
 }
 
@@ -51,6 +56,11 @@ func ProvideSemesterSettleRecordService(
 	studentSrv interfaces.IStudentSrv,
 	studentCommonSrv interfaces.IStudentCommonSrv,
 	pointCardSrv interfaces.IPointCardSrv,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
+	semesterSrv interfaces.ISemesterSrv,
+	settlementRunRepo interfaces.ISettlementRunRepo,
+	auditLogSrv interfaces.IAuditLogSrv,
 ) *SemesterSettleRecordService {
 	return &SemesterSettleRecordService{
 		DB:                              db,
@@ -62,17 +72,21 @@ func ProvideSemesterSettleRecordService(
 		studentSrv:                      studentSrv,
 		studentCommonSrv:                studentCommonSrv,
 		pointCardSrv:                    pointCardSrv,
+		recordLockCommonSrv:             recordLockCommonSrv,
+		kintoneRecordRepo:               kintoneRecordRepo,
+		semesterSrv:                     semesterSrv,
+		settlementRunRepo:               settlementRunRepo,
+		auditLogSrv:                     auditLogSrv,
 		executorPool:                    pool.NewExecutorPool(30),
 	}
 }
 
-var (
-	semesterStartAtDates = []string{"03/01", "09/01"}
-)
-
 type settlementItem struct {
-	student     *bo.Student
-	clearPoints float64
+	student            *bo.Student
+	totalDepositPoints int
+	totalReducePoints  points.Points
+	clearPoints        points.Points
+	restPoints         points.Points // 結算前點數卡的剩餘點數
 }
 
 type dateRange struct {
@@ -234,11 +248,8 @@ func (srv *SemesterSettleRecordService) DeleteSemesterSettleRecord(ctx context.C
 	return nil
 }
 
-func (srv *SemesterSettleRecordService) BatchSyncSemesterSettleRecord(ctx context.Context, cond *bo.SyncSemesterSettleRecordCond, wg ...*sync.WaitGroup) error {
-	semesterSettleRecordReq := &dto.SemesterSettleRecordReq{
-		Limit:  request.GetRecordsBatchLimit,
-		Offset: request.GetRecordsBatchOffset,
-	}
+func (srv *SemesterSettleRecordService) BatchSyncSemesterSettleRecord(ctx context.Context, cond *bo.SyncSemesterSettleRecordCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error {
+	semesterSettleRecordReq := &dto.SemesterSettleRecordReq{}
 
 	if cond != nil {
 		if cond.StudentName != nil && cond.ParentPhone != nil {
@@ -256,11 +267,15 @@ func (srv *SemesterSettleRecordService) BatchSyncSemesterSettleRecord(ctx contex
 		if cond.EndTime != nil {
 			semesterSettleRecordReq.EndTime = *cond.EndTime
 		}
+		if cond.UpdatedSince != nil {
+			semesterSettleRecordReq.UpdatedSince = *cond.UpdatedSince
+		}
 	}
 
-	allRecords, err := srv.getAllKintoneSemesterSettleRecords(ctx, semesterSettleRecordReq)
+	snapshotAt := time.Now()
+	allRecords, err := srv.GetAllKintoneSemesterSettleRecords(ctx, semesterSettleRecordReq)
 	if err != nil {
-		return xerrors.Errorf("getAllKintoneSemesterSettleRecords: %w", err)
+		return xerrors.Errorf("GetAllKintoneSemesterSettleRecords: %w", err)
 	}
 
 	var wait *sync.WaitGroup
@@ -272,19 +287,28 @@ func (srv *SemesterSettleRecordService) BatchSyncSemesterSettleRecord(ctx contex
 		defer func() {
 			if r := recover(); r != nil {
 				srv.logger.Error(ctx, "SemesterSettleRecordService syncSemesterSettleRecords panic", nil, zap.Any(pkgLogger.PanicMessage, r))
+				tracker.Abort(syncjob.TypeSemesterSettleRecord, xerrors.Errorf("panic: %v", r))
 			}
 			if wait != nil {
 				wait.Done()
 			}
 		}()
 
-		srv.syncSemesterSettleRecords(ctx, allRecords, cond)
+		srv.syncSemesterSettleRecords(ctx, allRecords, cond, snapshotAt, tracker)
 	}()
 
 	return nil
 }
 
-func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Context, allRecords []*bo.SemesterSettleRecord, cond *bo.SyncSemesterSettleRecordCond) {
+func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Context, allRecords []*bo.SemesterSettleRecord, cond *bo.SyncSemesterSettleRecordCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourceSemesterSettleRecord, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "semesterSettleRecordService syncSemesterSettleRecords recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypeSemesterSettleRecord, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentRecordRefIdMap := map[int]struct{}{}
 	for _, semesterSettleRecord := range allRecords {
@@ -297,13 +321,24 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Co
 						zap.Any(pkgLogger.PanicMessage, r),
 						zap.Any("semesterSettleRecord", *ssr),
 					)
+					tracker.Track(syncjob.TypeSemesterSettleRecord, ssr.RecordRefId, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			if err := srv.syncSemesterSettleRecord(ctx, ssr); err != nil {
-				srv.logger.Error(ctx, "SemesterSettleRecordService syncSemesterSettleRecords syncSemesterSettleRecord", err, zap.Int("record_ref_id", ssr.RecordRefId), zap.String("student_name", ssr.KintoneStudentName))
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, ssr.RecordRefId, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffSemesterSettleRecord(ctx, ssr, tracker)
+					return err
+				}
+				action, err = srv.syncSemesterSettleRecord(ctx, ssr)
+				return err
+			})
+			if err != nil {
+				srv.logger.Error(ctx, "SemesterSettleRecordService syncSemesterSettleRecords SyncSemesterSettleRecord", err, zap.Int("record_ref_id", ssr.RecordRefId), zap.String("student_name", ssr.KintoneStudentName))
 			}
+			tracker.Track(syncjob.TypeSemesterSettleRecord, ssr.RecordRefId, action, err)
 		})
 
 		currentRecordRefIdMap[ssr.RecordRefId] = struct{}{}
@@ -311,6 +346,13 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Co
 
 	wg.Wait()
 
+	currentRecordRefIdMap, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppSemesterSettleRecord, cond != nil && cond.UpdatedSince != nil, currentRecordRefIdMap)
+	if err != nil {
+		srv.logger.Error(ctx, "semesterSettleRecordService syncSemesterSettleRecords getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypeSemesterSettleRecord, err)
+		return
+	}
+
 	db := srv.DB.Session()
 	isDeleted := false
 	poSemesterSettleRecordCond := &po.SemesterSettleRecordCond{
@@ -325,6 +367,7 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Co
 						zap.String("student_name", *cond.StudentName),
 						zap.String("parent_phone", *cond.ParentPhone),
 					)
+					tracker.Abort(syncjob.TypeSemesterSettleRecord, errs.StudentErr.StudentNotFoundErr)
 					return
 				}
 
@@ -332,6 +375,7 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Co
 					zap.String("student_name", *cond.StudentName),
 					zap.String("parent_phone", *cond.ParentPhone),
 				)
+				tracker.Abort(syncjob.TypeSemesterSettleRecord, err)
 
 				return
 			}
@@ -348,29 +392,49 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecords(ctx context.Co
 	recordRefIds, err := srv.semesterSettleRecordRepo.GetSemesterSettleRecordRefIds(ctx, db, poSemesterSettleRecordCond)
 	if err != nil {
 		srv.logger.Error(ctx, "SemesterSettleRecordService syncSemesterSettleRecords GetSemesterSettleRecordRefIds", err)
+		tracker.Abort(syncjob.TypeSemesterSettleRecord, err)
 		return
 	}
 
 	for _, recordRefId := range recordRefIds {
 		if _, found := currentRecordRefIdMap[recordRefId]; !found {
-			if err := srv.DeleteSemesterSettleRecord(ctx, &bo.UpdateSemesterSettleRecordCond{RecordRefId: recordRefId}); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, recordRefId, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeSemesterSettleRecord, RecordRefId: recordRefId})
+					return nil
+				}
+				return srv.DeleteSemesterSettleRecord(ctx, &bo.UpdateSemesterSettleRecordCond{RecordRefId: recordRefId})
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "SemesterSettleRecordService syncSemesterSettleRecords DeleteSemesterSettleRecord", err, zap.Int("record_ref_id", recordRefId))
 			}
+			tracker.Track(syncjob.TypeSemesterSettleRecord, recordRefId, action, err)
 		}
 	}
 }
 
-func (srv *SemesterSettleRecordService) syncSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord) error {
+// SyncSemesterSettleRecord 以 kintone 上的記錄新增或更新 db 的記錄
+func (srv *SemesterSettleRecordService) SyncSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord) error {
+	_, err := srv.syncSemesterSettleRecord(ctx, data)
+	return err
+}
+
+func (srv *SemesterSettleRecordService) syncSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord) (syncjob.Action, error) {
 	db := srv.DB.Session()
 	poSemesterSettleRecord := &po.SemesterSettleRecordCond{RecordRefId: data.RecordRefId}
 	record, err := srv.semesterSettleRecordRepo.GetRecord(ctx, db, poSemesterSettleRecord)
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return xerrors.Errorf("recordRepo.GetRecord: %w", err)
+		return "", xerrors.Errorf("recordRepo.GetRecord: %w", err)
 	}
 
 	boStudentCond := &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone}
+	var action syncjob.Action
 	if record != nil {
 		// Update
+		action = syncjob.ActionUpdated
 		boUpdateSemesterSettleRecordCond := &bo.UpdateSemesterSettleRecordCond{RecordRefId: data.RecordRefId}
 		boUpdateSemesterSettleRecordData := &bo.UpdateSemesterSettleRecordData{
 			StartTime:   data.StartTime,
@@ -378,7 +442,7 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecord(ctx context.Con
 			ClearPoints: &data.ClearPoints,
 		}
 		if err := srv.UpdateSemesterSettleRecord(ctx, boUpdateSemesterSettleRecordCond, boStudentCond, boUpdateSemesterSettleRecordData); err != nil {
-			return xerrors.Errorf("UpdateSemesterSettleRecord: %w", err)
+			return "", xerrors.Errorf("UpdateSemesterSettleRecord: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -387,98 +451,138 @@ func (srv *SemesterSettleRecordService) syncSemesterSettleRecord(ctx context.Con
 				db.Where("record_id = ?", record.RecordId)
 				return db
 			}); err != nil {
-				return xerrors.Errorf("ResetFromDeleted: %w", err)
+				return "", xerrors.Errorf("ResetFromDeleted: %w", err)
 			}
 		}
 	} else {
 		// Create
+		action = syncjob.ActionCreated
 		if err := srv.AddSemesterSettleRecord(ctx, data, boStudentCond); err != nil {
-			return xerrors.Errorf("AddSemesterSettleRecord: %w", err)
+			return "", xerrors.Errorf("AddSemesterSettleRecord: %w", err)
 		}
 	}
 
-	return nil
+	return action, nil
 }
 
-func (srv *SemesterSettleRecordService) SettleSemesterPoints(ctx context.Context, cond *bo.SettleSemesterPointsCond) (err error) {
-	/* 不是特定結算日，不執行 */
-	if !srv.checkIsSettleDate(cond.Date) {
-		return nil
+// diffSemesterSettleRecord dry run 時計算 syncSemesterSettleRecord 會套用的變更，不寫入 db
+func (srv *SemesterSettleRecordService) diffSemesterSettleRecord(ctx context.Context, data *bo.SemesterSettleRecord, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	if len(data.StudentName) == 0 || len(data.ParentPhone) == 0 {
+		return "", errs.ReduceRecordErr.InvalidStudentNameErr
 	}
 
-	/* 取得結算起始、結束時間 */
-	r := dateRange{}
-	err = srv.getSemesterRanges(cond.Date, &r)
+	record, err := srv.semesterSettleRecordRepo.GetRecord(ctx, srv.DB.Session(), &po.SemesterSettleRecordCond{RecordRefId: data.RecordRefId})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("recordRepo.GetRecord: %w", err)
+	}
+
+	boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentName: data.StudentName, ParentPhone: data.ParentPhone})
 	if err != nil {
-		return xerrors.Errorf("getSemesterRanges: %w", err)
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return "", xerrors.Errorf("studentCommonSrv.GetStudent: %w", errs.StudentErr.StudentNotFoundErr)
+		}
+
+		return "", xerrors.Errorf("studentCommonSrv.GetStudent: %w", err)
+	}
+
+	differ := newSyncFieldDiffer(record == nil)
+	if record == nil {
+		record = &po.SemesterSettleRecord{}
 	}
+	differ.compare("student_id", record.StudentId, boStudent.StudentId)
+	differ.compare("start_time", record.StartTime, data.StartTime)
+	differ.compare("end_time", record.EndTime, data.EndTime)
+	differ.compare("clear_points", record.ClearPoints, data.ClearPoints)
+
+	diffRecord := &bo.SyncDiffRecord{Type: syncjob.TypeSemesterSettleRecord, RecordRefId: data.RecordRefId, Changes: differ.changes}
+	return trackSyncDiff(tracker, diffRecord, !differ.created, record.IsDeleted || record.DeletedAt != nil), nil
+}
 
-	/* 同步 kintone 學生/點數/購課/點名資料 */
-	// 必須先同步學生資料，後續才同步其他跟學生資料相關的資料
-	studentWg := sync.WaitGroup{}
-	err = srv.studentSrv.BatchSyncStudentsAndUsers(ctx, nil, &studentWg)
+// SettleSemesterPoints 結算日時建立學期結算並執行，結算失敗時會從中斷的步驟繼續執行，已完成的結算不會重複執行，整個學期撤銷後會重新結算
+func (srv *SemesterSettleRecordService) SettleSemesterPoints(ctx context.Context, cond *bo.SettleSemesterPointsCond) error {
+	/* 不是特定結算日，不執行 */
+	semester, err := srv.checkIsSettleDate(ctx, cond.Date)
 	if err != nil {
-		return xerrors.Errorf("studentSrv.BatchSyncStudentsAndUsers: %w", err)
+		return xerrors.Errorf("checkIsSettleDate: %w", err)
+	}
+	if semester == nil {
+		srv.checkUpcomingSemester(ctx, cond.Date)
+		return nil
 	}
-	studentWg.Wait()
 
-	wg := sync.WaitGroup{}
-	err = srv.pointCardSrv.BatchSyncPointCard(ctx, nil, &wg)
+	/* 取得或建立學期結算 */
+	run, err := srv.getOrCreateSettlementRun(ctx, semester)
 	if err != nil {
-		return xerrors.Errorf("pointCardSrv.BatchSyncPointCard: %w", err)
+		return xerrors.Errorf("getOrCreateSettlementRun: %w", err)
+	}
+	if run.Status == settlement.RunStatusCompleted {
+		srv.logger.Info(ctx, "SemesterSettleRecordService SettleSemesterPoints: semester was settled", zap.Int64("run_id", run.RunId), zap.Int64("semester_id", semester.SemesterId), zap.String("status", string(run.Status)))
+		return nil
 	}
 
-	drCond := bo.SyncDepositRecordCond{
-		ChargingDateStart: &r.Start,
-		ChargingDateEnd:   &r.End,
+	/* 依序執行結算步驟 */
+	if err := srv.executeSettlementRun(ctx, audit.ActionSettlementSettle, run.RunId, false); err != nil {
+		return xerrors.Errorf("executeSettlementRun: %w", err)
 	}
-	err = srv.depositRecordSrv.BatchSyncDepositRecord(ctx, &drCond, &wg)
+
+	return nil
+}
+
+// PreviewSettlement 以與 SettleSemesterPoints 相同的方式計算學期的結算清單，不會同步 kintone 資料，也不會寫入任何資料
+func (srv *SemesterSettleRecordService) PreviewSettlement(ctx context.Context, semesterId int64) (*bo.SettlementPreview, error) {
+	semester, err := srv.semesterSrv.GetSemester(ctx, semesterId)
 	if err != nil {
-		return xerrors.Errorf("depositRecordSrv.BatchSyncDepositRecord: %w", err)
+		return nil, xerrors.Errorf("semesterSrv.GetSemester: %w", err)
 	}
 
-	rrCond := bo.SyncReduceRecordCond{
-		ClassTimeStart: &r.Start,
-		ClassTimeEnd:   &r.End,
+	r := dateRange{}
+	if err := srv.getSemesterRanges(semester, &r); err != nil {
+		return nil, xerrors.Errorf("getSemesterRanges: %w", errs.SemesterErr.TimeZoneInvalidError)
 	}
-	err = srv.reduceRecordSrv.BatchSyncReduceRecord(ctx, &rrCond, &wg)
+
+	settlementList, err := srv.prepareSettlement(ctx, srv.DB.Session(), r)
 	if err != nil {
-		return xerrors.Errorf("reduceRecordSrv.BatchSyncReduceRecord: %w", err)
+		return nil, xerrors.Errorf("prepareSettlement: %w", err)
 	}
 
-	wg.Wait()
+	preview := &bo.SettlementPreview{
+		Semester:  semester,
+		StartTime: r.Start,
+		EndTime:   r.End,
+		Items:     make([]*bo.SettlementPreviewItem, 0, len(settlementList)),
+	}
+	for _, item := range settlementList {
+		preview.Items = append(preview.Items, &bo.SettlementPreviewItem{
+			StudentId:          item.student.StudentId,
+			StudentName:        item.student.StudentName,
+			ParentPhone:        item.student.ParentPhone,
+			TotalDepositPoints: item.totalDepositPoints,
+			TotalReducePoints:  item.totalReducePoints,
+			ClearPoints:        item.clearPoints,
+			RestPointsBefore:   item.restPoints,
+			RestPointsAfter:    item.restPoints.Sub(item.clearPoints),
+		})
+	}
 
-	/* 結算、清零剩餘點數 */
-	db := srv.DB.Session()
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			err = xerrors.Errorf("panic on error: %v", r)
-			return
-		}
-		if err != nil {
-			tx.Rollback()
-			return
-		}
-	}()
+	return preview, nil
+}
 
+// prepareSettlement 撈取需要結算的學生，過濾掉已有該學期結算記錄的學生後計算清除點數，只會讀取資料
+func (srv *SemesterSettleRecordService) prepareSettlement(ctx context.Context, db *gorm.DB, r dateRange) ([]settlementItem, error) {
 	/* 撈取需要結算的學生 */
-	settledStudents, err := srv.studentSrv.GetStudentsSettled(ctx, tx)
+	settledStudents, err := srv.studentSrv.GetStudentsSettled(ctx, db)
 	if err != nil {
-		return xerrors.Errorf("studentSrv.GetStudentsSettled: %w", err)
+		return nil, xerrors.Errorf("studentSrv.GetStudentsSettled: %w", err)
 	}
 
 	/* 撈取學生已結算記錄 */
 	semesterSettleRecordReq := &dto.SemesterSettleRecordReq{
 		StartTime: r.Start,
 		EndTime:   r.End,
-		Limit:     request.GetRecordsBatchLimit,
-		Offset:    request.GetRecordsBatchOffset,
 	}
-	allRecords, err := srv.getAllKintoneSemesterSettleRecords(ctx, semesterSettleRecordReq)
+	allRecords, err := srv.GetAllKintoneSemesterSettleRecords(ctx, semesterSettleRecordReq)
 	if err != nil {
-		return xerrors.Errorf("getAllKintoneSemesterSettleRecords: %w", err)
+		return nil, xerrors.Errorf("GetAllKintoneSemesterSettleRecords: %w", err)
 	}
 	semesterSettleRecordsMap := map[string]struct{}{}
 	for _, semesterSettleRecord := range allRecords {
@@ -490,28 +594,25 @@ func (srv *SemesterSettleRecordService) SettleSemesterPoints(ctx context.Context
 	filteredSettledStudents := make([]*bo.Student, 0, len(settledStudents))
 	for _, student := range settledStudents {
 		if _, ok := semesterSettleRecordsMap[strUtil.GetFullStudentName(student.StudentName, student.ParentPhone)]; ok {
-			srv.logger.Info(ctx, "SemesterSettleRecordService SettleSemesterPoints: skip settled student", zap.Int64("student_id", student.StudentId), zap.String("student_name", student.StudentName))
+			srv.logger.Info(ctx, "SemesterSettleRecordService prepareSettlement: skip settled student", zap.Int64("student_id", student.StudentId), zap.String("student_name", student.StudentName))
 			continue
 		}
 		studentIds = append(studentIds, student.StudentId)
 		filteredSettledStudents = append(filteredSettledStudents, student)
 	}
-
-	/* 所有學生都已經有結算過(有該學期的結算記錄) */
 	if len(studentIds) == 0 {
-		srv.logger.Info(ctx, "SemesterSettleRecordService SettleSemesterPoints: all students were settled, checked by semester settle records", zap.Time("startTime", r.Start), zap.Time("endTime", r.End))
-		return nil
+		return []settlementItem{}, nil
 	}
 
-	/* 撈取未結算學生的過去購買記錄/點名記錄總額, 組出結算資訊 */
+	/* 撈取未結算學生的過去購買記錄/點名記錄總額與點數卡 */
 	boStudentTotalDepositPointsCond := &bo.StudentTotalDepositPointsCond{
 		StudentIds:        studentIds,
 		ChargingDateStart: r.Start,
 		ChargingDateEnd:   r.End,
 	}
-	studentTotalDepositPoints, err := srv.depositRecordSrv.GetStudentTotalDepositPoints(ctx, tx, boStudentTotalDepositPointsCond)
+	studentTotalDepositPoints, err := srv.depositRecordSrv.GetStudentTotalDepositPoints(ctx, db, boStudentTotalDepositPointsCond)
 	if err != nil {
-		return xerrors.Errorf("depositRecordSrv.GetStudentTotalDepositPoints: %w", err)
+		return nil, xerrors.Errorf("depositRecordSrv.GetStudentTotalDepositPoints: %w", err)
 	}
 
 	boStudentTotalReducePointsCond := &bo.StudentTotalReducePointsCond{
@@ -519,193 +620,118 @@ func (srv *SemesterSettleRecordService) SettleSemesterPoints(ctx context.Context
 		ClassTimeStart: r.Start,
 		ClassTimeEnd:   r.End,
 	}
-	studentTotalReducePoints, err := srv.reduceRecordSrv.GetStudentTotalReducePoints(ctx, tx, boStudentTotalReducePointsCond)
+	studentTotalReducePoints, err := srv.reduceRecordSrv.GetStudentTotalReducePoints(ctx, db, boStudentTotalReducePointsCond)
 	if err != nil {
-		return xerrors.Errorf("reduceRecordSrv.GetStudentTotalReducePoints: %w", err)
+		return nil, xerrors.Errorf("reduceRecordSrv.GetStudentTotalReducePoints: %w", err)
 	}
 
-	/* 統計並組出結算清單 */
-	settlementList := make([]settlementItem, 0, len(studentIds))
-	for _, student := range filteredSettledStudents {
-		var total float64
-		if v, ok := studentTotalDepositPoints[student.StudentId]; ok {
-			total += float64(v.TotalDepositPoints)
-		}
-		if v, ok := studentTotalReducePoints[student.StudentId]; ok {
-			total -= v.TotalReducePoints
-		}
-		if total < 0.0 {
-			srv.logger.Warn(ctx, "SemesterSettleRecordService SettleSemesterPoints warning: negative total points",
-				zap.String("student_name", student.StudentName),
-				zap.Float64("total_deposit_points", float64(studentTotalDepositPoints[student.StudentId].TotalDepositPoints)),
-				zap.Float64("total_reduce_points", studentTotalReducePoints[student.StudentId].TotalReducePoints),
-			)
-			total = 0.0
-		}
-		settlementList = append(settlementList, settlementItem{
-			student:     student,
-			clearPoints: total,
-		})
-	}
-
-	/* 批次新增結算記錄 */
-	if err = srv.InsertKintoneSemesterSettleRecords(ctx, settlementList, r); err != nil {
-		return xerrors.Errorf("kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords: %w", err)
-	}
-
-	studentPointCardMap, err := srv.pointCardSrv.GetPointCards(ctx, tx, &bo.GetPointCardCond{StudentIds: studentIds})
+	studentPointCardMap, err := srv.pointCardSrv.GetPointCards(ctx, db, &bo.GetPointCardCond{StudentIds: studentIds})
 	if err != nil {
-		return xerrors.Errorf("pointCardSrv.GetPointCards: %w", err)
+		return nil, xerrors.Errorf("pointCardSrv.GetPointCards: %w", err)
 	}
 
-	boSyncSettledStudentPointCards := make([]*bo.SyncSettledStudentPointCardData, 0, len(settlementList))
+	/* 統計並組出結算清單 */
+	settlementList := buildSettlementList(filteredSettledStudents, studentTotalDepositPoints, studentTotalReducePoints, studentPointCardMap)
 	for _, item := range settlementList {
-		boSyncSettledStudentPointCards = append(boSyncSettledStudentPointCards, &bo.SyncSettledStudentPointCardData{
-			StudentId:          item.student.StudentId,
-			KintoneStudentName: strUtil.GetFullStudentName(item.student.StudentName, item.student.ParentPhone),
-			ClearPoints:        item.clearPoints,
-			RestPoints:         studentPointCardMap[item.student.StudentId].RestPoints - item.clearPoints,
-		})
+		if item.totalReducePoints.Cmp(points.FromInt(item.totalDepositPoints)) > 0 {
+			srv.logger.Warn(ctx, "SemesterSettleRecordService prepareSettlement warning: negative total points",
+				zap.String("student_name", item.student.StudentName),
+				zap.Int("total_deposit_points", item.totalDepositPoints),
+				zap.Stringer("total_reduce_points", item.totalReducePoints),
+			)
+		}
 	}
 
-	err = srv.pointCardSrv.SyncSettledStudentPointCards(ctx, tx, boSyncSettledStudentPointCards)
-	if err != nil {
-		return xerrors.Errorf("pointCardSrv.SyncSettledStudentPointCards: %w", err)
-	}
+	return settlementList, nil
+}
 
-	if err = tx.Commit().Error; err != nil {
-		return xerrors.Errorf("Commit: %w", err)
+// buildSettlementList 清除點數為學期內儲值減去扣點，扣點較多時不清除
+func buildSettlementList(
+	students []*bo.Student,
+	totalDepositPoints map[int64]*bo.StudentTotalDepositPoints,
+	totalReducePoints map[int64]*bo.StudentTotalReducePoints,
+	pointCards map[int64]*bo.PointCard,
+) []settlementItem {
+	settlementList := make([]settlementItem, 0, len(students))
+	for _, student := range students {
+		item := settlementItem{student: student}
+		if v, ok := totalDepositPoints[student.StudentId]; ok {
+			item.totalDepositPoints = v.TotalDepositPoints
+		}
+		if v, ok := totalReducePoints[student.StudentId]; ok {
+			item.totalReducePoints = v.TotalReducePoints
+		}
+		if v, ok := pointCards[student.StudentId]; ok {
+			item.restPoints = v.RestPoints
+		}
+		item.clearPoints = points.FromInt(item.totalDepositPoints).Sub(item.totalReducePoints)
+		if item.clearPoints.Cmp(points.Points{}) < 0 {
+			item.clearPoints = points.Points{}
+		}
+		settlementList = append(settlementList, item)
 	}
 
-	/* 再同步一次新增的結算記錄 */
-	err = srv.BatchSyncSemesterSettleRecord(ctx, &bo.SyncSemesterSettleRecordCond{StartTime: &r.Start, EndTime: &r.End})
+	return settlementList
+}
+
+// checkIsSettleDate 回傳以 t 當天為結算日的學期，不是結算日時回傳 nil
+func (srv *SemesterSettleRecordService) checkIsSettleDate(ctx context.Context, t time.Time) (*bo.Semester, error) {
+	semester, err := srv.semesterSrv.GetSettleSemester(ctx, t)
 	if err != nil {
-		srv.logger.Error(ctx, "SemesterSettleRecordService SettleSemesterPoints BatchSyncSemesterSettleRecord failed", err, zap.Time("startTime", r.Start), zap.Time("endTime", r.End))
+		return nil, xerrors.Errorf("semesterSrv.GetSettleSemester: %w", err)
 	}
 
-	return nil
-}
-
-func (srv *SemesterSettleRecordService) checkIsSettleDate(t time.Time) bool {
-	date := t.Format("01/02")
-	return slices.Contains(semesterStartAtDates, date)
+	return semester, nil
 }
 
-func (srv *SemesterSettleRecordService) getSemesterRanges(t time.Time, r *dateRange) error {
-	nowY := t.Year()
-	date := t.Format("01/02")
-
-	idx := slices.Index(semesterStartAtDates, date)
-	end := semesterStartAtDates[idx]
-	var (
-		atStart bool
-		start   string
-	)
-	if idx > 0 {
-		start = semesterStartAtDates[idx-1]
-	} else {
-		start = semesterStartAtDates[len(semesterStartAtDates)-1]
-		atStart = true
-	}
-
-	t, err := time.ParseInLocation("01/02", end, utils.GetLocation())
+// checkUpcomingSemester 之後沒有要結算的學期時寫入錯誤 log，提醒建立下一個學期，否則結算會停止
+func (srv *SemesterSettleRecordService) checkUpcomingSemester(ctx context.Context, t time.Time) {
+	semester, err := srv.semesterSrv.GetUpcomingSemester(ctx, t)
 	if err != nil {
-		return err
+		srv.logger.Error(ctx, "SemesterSettleRecordService checkUpcomingSemester semesterSrv.GetUpcomingSemester", err)
+		return
 	}
-	t = t.AddDate(nowY, 0, 0)
+	if semester == nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService checkUpcomingSemester", errs.SemesterErr.NoUpcomingSemesterError, zap.Time("date", t))
+	}
+}
 
-	t2, err := time.ParseInLocation("01/02", start, utils.GetLocation())
+func (srv *SemesterSettleRecordService) getSemesterRanges(semester *bo.Semester, r *dateRange) error {
+	loc, err := semester.Location()
 	if err != nil {
 		return err
 	}
-	if atStart {
-		t2 = t2.AddDate(nowY-1, 0, 0)
-	} else {
-		t2 = t2.AddDate(nowY, 0, 0)
-	}
 
-	(*r).Start = t2
-	(*r).End = t.Add(-time.Second)
+	(*r).Start, (*r).End = semester.Range(loc)
 
 	return nil
 }
 
-func (srv *SemesterSettleRecordService) getAllKintoneSemesterSettleRecords(ctx context.Context, req *dto.SemesterSettleRecordReq) ([]*bo.SemesterSettleRecord, error) {
-	kintoneSemesterSettleRecords, total, err := srv.getKintoneSemesterSettleRecords(ctx, req)
+// GetAllKintoneSemesterSettleRecords 以 cursor 取得符合條件的全部資料，不受 offset 上限限制
+func (srv *SemesterSettleRecordService) GetAllKintoneSemesterSettleRecords(ctx context.Context, req *dto.SemesterSettleRecordReq) (records []*bo.SemesterSettleRecord, err error) {
+	cursor, err := srv.kintoneSemesterSettleRecordRepo.GetKintoneSemesterSettleRecordsCursor(ctx, req)
 	if err != nil {
-		return nil, xerrors.Errorf("getKintoneSemesterSettleRecords: %w", err)
+		return nil, xerrors.Errorf("kintoneSemesterSettleRecordRepo.GetKintoneSemesterSettleRecordsCursor: %w", err)
 	}
-
-	allRecords := make([]*bo.SemesterSettleRecord, 0, total)
-	allRecords = append(allRecords, kintoneSemesterSettleRecords...)
-
-	offset, limit := req.Offset, req.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		req.Offset = offset * limit
-		kintoneSemesterSettleRecords, _, err = srv.getKintoneSemesterSettleRecords(ctx, req)
-		if err != nil {
-			return nil, xerrors.Errorf("getKintoneSemesterSettleRecords: %w", err)
+	defer func() {
+		if closeErr := cursor.Close(ctx); closeErr != nil && err == nil {
+			err = xerrors.Errorf("cursor.Close: %w", closeErr)
 		}
-		allRecords = append(allRecords, kintoneSemesterSettleRecords...)
-	}
-
-	return allRecords, nil
-}
-
-func (srv *SemesterSettleRecordService) getKintoneSemesterSettleRecords(ctx context.Context, cond *dto.SemesterSettleRecordReq) ([]*bo.SemesterSettleRecord, int, error) {
-	poSemesterSettleRecordRes, err := srv.kintoneSemesterSettleRecordRepo.GetKintoneSemesterSettleRecords(ctx, cond)
-	if err != nil {
-		return nil, 0, xerrors.Errorf("kintoneSemesterSettleRecordRepo.GetKintoneSemesterSettleRecords: %w", err)
-	}
-
-	total, err := strconv.ParseInt(poSemesterSettleRecordRes.TotalCount, 10, 64)
-	if err != nil {
-		return nil, 0, xerrors.Errorf("strconv.ParseInt: %w", err)
-	}
-
-	boSemesterSettleRecords := make([]*bo.SemesterSettleRecord, 0, len(poSemesterSettleRecordRes.Records))
+	}()
 
-	for _, record := range poSemesterSettleRecordRes.Records {
-		boSemesterSettleRecord, err := record.ToSemesterSettleRecord()
-		if err != nil {
-			return nil, 0, xerrors.Errorf("record.ToSemesterSettleRecord: %w", err)
+	boSemesterSettleRecords := make([]*bo.SemesterSettleRecord, 0, cursor.TotalCount())
+	for cursor.Next(ctx) {
+		for _, record := range cursor.Records() {
+			boSemesterSettleRecord, err := record.ToSemesterSettleRecord()
+			if err != nil {
+				return nil, xerrors.Errorf("record.ToSemesterSettleRecord: %w", err)
+			}
+			boSemesterSettleRecords = append(boSemesterSettleRecords, boSemesterSettleRecord)
 		}
-		boSemesterSettleRecords = append(boSemesterSettleRecords, boSemesterSettleRecord)
 	}
-
-	return boSemesterSettleRecords, int(total), nil
-}
-
-func (srv *SemesterSettleRecordService) InsertKintoneSemesterSettleRecords(ctx context.Context, settlementList []settlementItem, r dateRange) error {
-	batchRecords := make([]dto.InsertSemesterSettleRecord, 0, kintone.BatchInsertRecordsMaxLimit)
-	req := &dto.InsertSemesterSettleRecordsReq{}
-	err := utils.RunInBatch(len(settlementList), kintone.BatchInsertRecordsMaxLimit, func(batchIndex int, start int, end int) error {
-		for _, item := range settlementList[start:end] {
-			insertRecord := dto.InsertSemesterSettleRecord{}
-			insertRecord.StudentName.Value = strUtil.GetFullStudentName(item.student.StudentName, item.student.ParentPhone)
-			// kintone 應用起始時間/結束時間只記錄日期
-			insertRecord.StartTime.Value = r.Start.Format(time.DateOnly)
-			insertRecord.EndTime.Value = r.End.Format(time.DateOnly)
-			insertRecord.ClearPoints.Value = strconv.FormatFloat(item.clearPoints, 'f', -1, 64)
-			batchRecords = append(batchRecords, insertRecord)
-		}
-
-		req.Records = batchRecords
-		if _, err := srv.kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords(ctx, req); err != nil {
-			return xerrors.Errorf("kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords batch [%d]: %w", batchIndex, err)
-		}
-
-		batchRecords = batchRecords[:0]
-		srv.logger.Info(ctx, fmt.Sprintf("InsertKintoneSemesterSettleRecords: batch %d succeeded", batchIndex))
-
-		time.Sleep(100 * time.Millisecond) // 避免短時間發送太多請求造成 API 失敗
-
-		return nil
-	})
-	if err != nil {
-		return err
+	if err := cursor.Err(); err != nil {
+		return nil, xerrors.Errorf("cursor.Next: %w", err)
 	}
 
-	return nil
+	return boSemesterSettleRecords, nil
 }
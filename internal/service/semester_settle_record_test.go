@@ -0,0 +1,62 @@
+package service
+
+import (
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/points"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSettlementList(t *testing.T) {
+	students := []*bo.Student{{StudentId: 1}, {StudentId: 2}, {StudentId: 3}}
+	totalDepositPoints := map[int64]*bo.StudentTotalDepositPoints{
+		1: {StudentId: 1, TotalDepositPoints: 20},
+		2: {StudentId: 2, TotalDepositPoints: 5},
+	}
+	totalReducePoints := map[int64]*bo.StudentTotalReducePoints{
+		1: {StudentId: 1, TotalReducePoints: pts(12.5)},
+		2: {StudentId: 2, TotalReducePoints: pts(8)},
+		3: {StudentId: 3, TotalReducePoints: pts(1)},
+	}
+	pointCards := map[int64]*bo.PointCard{
+		1: {StudentId: 1, RestPoints: pts(7.5)},
+		2: {StudentId: 2, RestPoints: pts(0)},
+	}
+
+	want := []settlementItem{
+		{student: students[0], totalDepositPoints: 20, totalReducePoints: pts(12.5), clearPoints: pts(7.5), restPoints: pts(7.5)},
+		// 扣點多於儲值時不清除
+		{student: students[1], totalDepositPoints: 5, totalReducePoints: pts(8), clearPoints: pts(0), restPoints: pts(0)},
+		// 沒有儲值記錄與點數卡
+		{student: students[2], totalDepositPoints: 0, totalReducePoints: pts(1), clearPoints: pts(0), restPoints: pts(0)},
+	}
+
+	assert.Equal(t, want, buildSettlementList(students, totalDepositPoints, totalReducePoints, pointCards))
+}
+
+func TestBuildSettlementListMatchesKintone(t *testing.T) {
+	// Kintone 上的扣點記錄，加總剛好等於儲值點數，結算時不應該清除任何點數
+	var totalReduce points.Points
+	for _, value := range []string{"0.1", "0.2", "0.7", "1.25", "2.75"} {
+		reducePoints, err := dto.FloatField{NormalField: dto.NormalField{Value: value}}.ToPoints()
+		assert.NoError(t, err)
+		totalReduce = totalReduce.Add(reducePoints)
+	}
+
+	students := []*bo.Student{{StudentId: 1}, {StudentId: 2}}
+	totalDepositPoints := map[int64]*bo.StudentTotalDepositPoints{
+		1: {StudentId: 1, TotalDepositPoints: 5},
+		2: {StudentId: 2, TotalDepositPoints: 6},
+	}
+	totalReducePoints := map[int64]*bo.StudentTotalReducePoints{
+		1: {StudentId: 1, TotalReducePoints: totalReduce},
+		2: {StudentId: 2, TotalReducePoints: totalReduce},
+	}
+
+	settlementList := buildSettlementList(students, totalDepositPoints, totalReducePoints, nil)
+	assert.True(t, settlementList[0].clearPoints.IsZero())
+	assert.Equal(t, "0", settlementList[0].clearPoints.String())
+	assert.Equal(t, "1", settlementList[1].clearPoints.String())
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/errs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSemester(id int64, start, end, settle string) *bo.Semester {
+	parse := func(s string) time.Time {
+		t, _ := time.Parse(time.DateOnly, s)
+		return t
+	}
+	return &bo.Semester{
+		SemesterId: id,
+		Name:       "semester",
+		StartDate:  parse(start),
+		EndDate:    parse(end),
+		SettleDate: parse(settle),
+		TimeZone:   "Asia/Taipei",
+	}
+}
+
+func TestValidateSemester(t *testing.T) {
+	tests := []struct {
+		name     string
+		semester *bo.Semester
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			semester: newTestSemester(1, "2026-09-01", "2027-02-28", "2027-03-01"),
+		},
+		{
+			name: "empty name",
+			semester: func() *bo.Semester {
+				s := newTestSemester(1, "2026-09-01", "2027-02-28", "2027-03-01")
+				s.Name = " "
+				return s
+			}(),
+			wantErr: errs.SemesterErr.InvalidSemesterError,
+		},
+		{
+			name: "invalid time zone",
+			semester: func() *bo.Semester {
+				s := newTestSemester(1, "2026-09-01", "2027-02-28", "2027-03-01")
+				s.TimeZone = "Mars/Olympus"
+				return s
+			}(),
+			wantErr: errs.SemesterErr.TimeZoneInvalidError,
+		},
+		{
+			name:     "end before start",
+			semester: newTestSemester(1, "2027-02-28", "2026-09-01", "2027-03-01"),
+			wantErr:  errs.SemesterErr.InvalidSemesterError,
+		},
+		{
+			name:     "settle on end date",
+			semester: newTestSemester(1, "2026-09-01", "2027-02-28", "2027-02-28"),
+			wantErr:  errs.SemesterErr.InvalidSemesterError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validateSemester(tt.semester))
+		})
+	}
+}
+
+func TestCheckSemesterConflict(t *testing.T) {
+	semesters := []*bo.Semester{
+		newTestSemester(1, "2026-09-01", "2027-02-28", "2027-03-01"),
+		newTestSemester(2, "2027-03-01", "2027-08-31", "2027-09-01"),
+	}
+
+	tests := []struct {
+		name     string
+		semester *bo.Semester
+		wantErr  error
+	}{
+		{
+			name:     "summer term after existing semesters",
+			semester: newTestSemester(0, "2027-09-01", "2027-09-30", "2027-10-01"),
+		},
+		{
+			name:     "update itself",
+			semester: newTestSemester(2, "2027-03-01", "2027-08-15", "2027-08-16"),
+		},
+		{
+			name:     "overlapping range",
+			semester: newTestSemester(0, "2027-08-31", "2027-09-30", "2027-10-01"),
+			wantErr:  errs.SemesterErr.SemesterConflictError,
+		},
+		{
+			name:     "same settle date",
+			semester: newTestSemester(0, "2025-09-01", "2026-02-28", "2027-03-01"),
+			wantErr:  errs.SemesterErr.SemesterConflictError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, checkSemesterConflict(tt.semester, semesters))
+		})
+	}
+}
+
+func TestSemesterRange(t *testing.T) {
+	semester := newTestSemester(1, "2027-03-01", "2027-08-31", "2027-09-01")
+	loc, err := semester.Location()
+	assert.NoError(t, err)
+
+	start, end := semester.Range(loc)
+	assert.Equal(t, time.Date(2027, 3, 1, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2027, 8, 31, 23, 59, 59, 0, loc), end)
+
+	assert.True(t, semester.IsSettleDate(time.Date(2027, 9, 1, 4, 0, 0, 0, loc), loc))
+	// UTC 8/31 晚上在台北已經是 9/1
+	assert.True(t, semester.IsSettleDate(time.Date(2027, 8, 31, 20, 0, 0, 0, time.UTC), loc))
+	assert.False(t, semester.IsSettleDate(time.Date(2027, 8, 31, 4, 0, 0, 0, loc), loc))
+}
+
+func TestUpcomingSemester(t *testing.T) {
+	semesters := []*bo.Semester{
+		newTestSemester(2, "2027-03-01", "2027-08-31", "2027-09-01"),
+		newTestSemester(1, "2026-09-01", "2027-02-28", "2027-03-01"),
+	}
+	loc, _ := time.LoadLocation("Asia/Taipei")
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		wantId int64
+	}{
+		{name: "before all", t: time.Date(2026, 10, 18, 0, 0, 0, 0, loc), wantId: 1},
+		{name: "on settle date", t: time.Date(2027, 3, 1, 4, 0, 0, 0, loc), wantId: 2},
+		{name: "after all", t: time.Date(2027, 9, 1, 4, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			semester, err := upcomingSemester(semesters, tt.t)
+			assert.NoError(t, err)
+			if tt.wantId == 0 {
+				assert.Nil(t, semester)
+				return
+			}
+			assert.Equal(t, tt.wantId, semester.SemesterId)
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/points"
+	"slices"
+	"strings"
+	"time"
+)
+
+func (srv *SemesterSettleRecordService) GetSettlementReversals(ctx context.Context, runId int64) ([]*bo.SettlementReversal, error) {
+	db := srv.DB.Session()
+
+	if _, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{RunId: runId}); err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", errs.SettlementErr.RunNotFoundError)
+		}
+		return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+
+	poReversals, err := srv.settlementRunRepo.GetReversals(ctx, db, &po.SettlementReversalCond{RunId: runId})
+	if err != nil {
+		return nil, xerrors.Errorf("settlementRunRepo.GetReversals: %w", err)
+	}
+
+	reversals := make([]*bo.SettlementReversal, 0, len(poReversals))
+	for _, poReversal := range poReversals {
+		reversals = append(reversals, toSettlementReversalBo(poReversal))
+	}
+
+	return reversals, nil
+}
+
+// ReverseSettlement 撤銷單一學生或整個學期的結算：刪除 kintone 結算記錄、退回清除的點數並同步 db，撤銷記錄會保留操作人與原因
+// 撤銷失敗時可以再次撤銷，停在撤銷中的學生會繼續處理
+func (srv *SemesterSettleRecordService) ReverseSettlement(ctx context.Context, cond *bo.ReverseSettlementCond) (*bo.SettlementReversal, error) {
+	if strings.TrimSpace(cond.Operator) == "" || strings.TrimSpace(cond.Reason) == "" {
+		return nil, xerrors.Errorf("ReverseSettlement: %w", errs.SettlementErr.InvalidReversalError)
+	}
+
+	db := srv.DB.Session()
+
+	run, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{RunId: cond.RunId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", errs.SettlementErr.RunNotFoundError)
+		}
+		return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+	if run.Status == settlement.RunStatusReversed {
+		return nil, xerrors.Errorf("ReverseSettlement run_id: %d: %w", run.RunId, errs.SettlementErr.RunReversedError)
+	}
+	if cond.StudentId != 0 {
+		items, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: run.RunId, StudentId: cond.StudentId})
+		if err != nil {
+			return nil, xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+		}
+		if len(items) == 0 {
+			return nil, xerrors.Errorf("ReverseSettlement student_id: %d: %w", cond.StudentId, errs.SettlementErr.ItemNotFoundError)
+		}
+		if items[0].Step == settlement.ItemStepReversed {
+			return nil, xerrors.Errorf("ReverseSettlement student_id: %d: %w", cond.StudentId, errs.SettlementErr.ItemReversedError)
+		}
+	}
+
+	before, err := srv.getSettlementRunSummary(ctx, run.RunId)
+	if err != nil {
+		return nil, xerrors.Errorf("getSettlementRunSummary: %w", err)
+	}
+
+	/* 取得執行權並建立撤銷記錄，避免與結算或其他撤銷同時執行 */
+	poReversal, err := srv.startSettlementReversal(ctx, run, cond)
+	if err != nil {
+		return nil, xerrors.Errorf("startSettlementReversal: %w", err)
+	}
+
+	reversedCount, err := srv.reverseSettlementItems(ctx, run, poReversal.ReversalId, cond.StudentId)
+	srv.finishSettlementReversal(ctx, run, poReversal, cond.StudentId, reversedCount, err)
+	srv.addSettlementRunAuditLog(ctx, audit.ActionSettlementReverse, before)
+	if err != nil {
+		return nil, xerrors.Errorf("reverseSettlementItems: %w", err)
+	}
+
+	return toSettlementReversalBo(poReversal), nil
+}
+
+func (srv *SemesterSettleRecordService) startSettlementReversal(ctx context.Context, run *po.SettlementRun, cond *bo.ReverseSettlementCond) (*po.SettlementReversal, error) {
+	statuses := []settlement.RunStatus{settlement.RunStatusPending, settlement.RunStatusFailed, settlement.RunStatusCompleted}
+	if cond.Force {
+		statuses = append(statuses, settlement.RunStatusReversing)
+	}
+	if !slices.Contains(statuses, run.Status) {
+		return nil, xerrors.Errorf("run_id: %d status: %s: %w", run.RunId, run.Status, errs.SettlementErr.RunIsRunningError)
+	}
+
+	reversalId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+	poReversal := &po.SettlementReversal{
+		ReversalId: reversalId,
+		RunId:      run.RunId,
+		Operator:   cond.Operator,
+		Reason:     cond.Reason,
+		Status:     settlement.ReversalStatusRunning,
+	}
+	if cond.StudentId != 0 {
+		poReversal.StudentId = &cond.StudentId
+	}
+
+	tx := srv.DB.Session().Begin()
+	reversing := settlement.RunStatusReversing
+	// 只在狀態沒有被其他地方改變時更新，撤銷結束後才能回到原本的狀態
+	affected, err := srv.settlementRunRepo.UpdateRun(ctx, tx, &po.UpdateSettlementRunCond{RunId: run.RunId, Statuses: []settlement.RunStatus{run.Status}}, &po.UpdateSettlementRunData{
+		Status: &reversing,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, xerrors.Errorf("settlementRunRepo.UpdateRun: %w", err)
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return nil, xerrors.Errorf("settlementRunRepo.UpdateRun run_id: %d: %w", run.RunId, errs.SettlementErr.RunIsRunningError)
+	}
+	if err := srv.settlementRunRepo.AddReversal(ctx, tx, poReversal); err != nil {
+		tx.Rollback()
+		return nil, xerrors.Errorf("settlementRunRepo.AddReversal: %w", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, xerrors.Errorf("Commit: %w", err)
+	}
+
+	return poReversal, nil
+}
+
+// finishSettlementReversal 撤銷整個學期成功時結算改為已撤銷，其他情況回到撤銷前的狀態
+func (srv *SemesterSettleRecordService) finishSettlementReversal(ctx context.Context, run *po.SettlementRun, poReversal *po.SettlementReversal, studentId int64, reversedCount int, reverseErr error) {
+	now := time.Now()
+	poReversal.Status = settlement.ReversalStatusCompleted
+	poReversal.ReversedCount = reversedCount
+	poReversal.FinishedAt = &now
+	if reverseErr != nil {
+		poReversal.Status = settlement.ReversalStatusFailed
+		poReversal.Error = reverseErr.Error()
+	}
+
+	db := srv.DB.Session()
+	err := srv.settlementRunRepo.UpdateReversal(ctx, db, &po.UpdateSettlementReversalCond{ReversalId: poReversal.ReversalId}, &po.UpdateSettlementReversalData{
+		Status:        poReversal.Status,
+		ReversedCount: poReversal.ReversedCount,
+		Error:         poReversal.Error,
+		FinishedAt:    now,
+	})
+	if err != nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService finishSettlementReversal settlementRunRepo.UpdateReversal failed", err, zap.Int64("reversal_id", poReversal.ReversalId))
+	}
+
+	status := run.Status
+	// 強制接手時不知道撤銷前的狀態，改為失敗，之後可以繼續執行或撤銷
+	if status == settlement.RunStatusReversing {
+		status = settlement.RunStatusFailed
+	}
+	runData := &po.UpdateSettlementRunData{Status: &status}
+	if reverseErr == nil && studentId == 0 {
+		status = settlement.RunStatusReversed
+		runData.FinishedAt = &now
+	}
+	_, err = srv.settlementRunRepo.UpdateRun(ctx, db, &po.UpdateSettlementRunCond{RunId: run.RunId}, runData)
+	if err != nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService finishSettlementReversal settlementRunRepo.UpdateRun failed", err, zap.Int64("run_id", run.RunId), zap.String("status", string(status)))
+	}
+}
+
+// reverseSettlementItems 先將學生標記為撤銷中，再刪除 kintone 結算記錄，最後每批在同一個交易內退回點數並記錄步驟
+func (srv *SemesterSettleRecordService) reverseSettlementItems(ctx context.Context, run *po.SettlementRun, reversalId int64, studentId int64) (reversedCount int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = xerrors.Errorf("panic on error: %v", r)
+		}
+	}()
+
+	db := srv.DB.Session()
+
+	allItems, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: run.RunId, StudentId: studentId})
+	if err != nil {
+		return 0, xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+	}
+	items := make([]*po.SettlementRunItem, 0, len(allItems))
+	studentIds := make([]int64, 0, len(allItems))
+	for _, item := range allItems {
+		if item.Step == settlement.ItemStepReversed {
+			continue
+		}
+		items = append(items, item)
+		studentIds = append(studentIds, item.StudentId)
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	/* 標記為撤銷中，之後繼續執行結算時不會再處理這些學生 */
+	err = srv.settlementRunRepo.UpdateRunItems(ctx, db, &po.UpdateSettlementRunItemCond{RunId: run.RunId, StudentIds: studentIds}, &po.UpdateSettlementRunItemData{
+		Step:       settlement.ItemStepReversing,
+		ReversalId: &reversalId,
+	})
+	if err != nil {
+		return 0, xerrors.Errorf("settlementRunRepo.UpdateRunItems: %w", err)
+	}
+
+	/* 刪除 kintone 結算記錄，以學生名稱比對，新增後還沒記錄步驟的結算記錄也會一併刪除 */
+	settleRecordReq := &dto.SemesterSettleRecordReq{
+		StartTime: run.StartTime,
+		EndTime:   run.EndTime,
+	}
+	if studentId != 0 {
+		settleRecordReq.StudentName = items[0].KintoneStudentName
+	}
+	settleRecords, err := srv.GetAllKintoneSemesterSettleRecords(ctx, settleRecordReq)
+	if err != nil {
+		return 0, xerrors.Errorf("GetAllKintoneSemesterSettleRecords: %w", err)
+	}
+	kintoneRefIds := matchSettleRecordRefIds(items, settleRecords)
+	deleteRefIds := make([]int, 0, len(kintoneRefIds))
+	for _, refIds := range kintoneRefIds {
+		deleteRefIds = append(deleteRefIds, refIds...)
+	}
+	slices.Sort(deleteRefIds)
+	err = utils.RunInBatch(len(deleteRefIds), kintone.BatchDeleteRecordsMaxLimit, func(batchIndex int, start int, end int) error {
+		err := srv.kintoneSemesterSettleRecordRepo.DeleteKintoneSemesterSettleRecords(ctx, &dto.DeleteSemesterSettleRecordsReq{Ids: deleteRefIds[start:end]})
+		if err != nil {
+			return xerrors.Errorf("kintoneSemesterSettleRecordRepo.DeleteKintoneSemesterSettleRecords batch [%d]: %w", batchIndex, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, xerrors.Errorf("utils.RunInBatch: %w", err)
+	}
+
+	/* 退回 db 與 kintone 點數卡，刪除 db 結算記錄 */
+	err = utils.RunInBatch(len(items), kintone.BatchUpdateRecordsMaxLimit, func(batchIndex int, start int, end int) (err error) {
+		batch := items[start:end]
+		batchStudentIds := studentIds[start:end]
+
+		tx := db.Begin()
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+
+		deleted := true
+		now := time.Now()
+		for _, item := range batch {
+			refIds := kintoneRefIds[item.StudentId]
+			if item.SettleRecordRefId != nil && !slices.Contains(refIds, *item.SettleRecordRefId) {
+				refIds = append(refIds, *item.SettleRecordRefId)
+			}
+			for _, refId := range refIds {
+				err = srv.semesterSettleRecordRepo.UpdateRecord(ctx, tx, &po.UpdateSemesterSettleRecordCond{RecordRefId: refId}, &po.UpdateSemesterSettleRecordData{
+					IsDeleted: &deleted,
+					DeletedAt: &now,
+				})
+				if err != nil {
+					return xerrors.Errorf("semesterSettleRecordRepo.UpdateRecord batch [%d]: %w", batchIndex, err)
+				}
+			}
+		}
+
+		err = srv.settlementRunRepo.UpdateRunItems(ctx, tx, &po.UpdateSettlementRunItemCond{
+			RunId:      run.RunId,
+			StudentIds: batchStudentIds,
+			Step:       settlement.ItemStepReversing,
+		}, &po.UpdateSettlementRunItemData{
+			Step: settlement.ItemStepReversed,
+		})
+		if err != nil {
+			return xerrors.Errorf("settlementRunRepo.UpdateRunItems batch [%d]: %w", batchIndex, err)
+		}
+
+		if err = srv.pointCardSrv.SyncSettledStudentPointCards(ctx, tx, restoredPointCardData(batch)); err != nil {
+			return xerrors.Errorf("pointCardSrv.SyncSettledStudentPointCards batch [%d]: %w", batchIndex, err)
+		}
+		if err = tx.Commit().Error; err != nil {
+			return xerrors.Errorf("Commit batch [%d]: %w", batchIndex, err)
+		}
+
+		reversedCount += len(batch)
+		srv.logger.Info(ctx, fmt.Sprintf("reverseSettlementItems: batch %d succeeded", batchIndex), zap.Int64("run_id", run.RunId), zap.Int64("reversal_id", reversalId))
+		return nil
+	})
+	if err != nil {
+		return reversedCount, xerrors.Errorf("utils.RunInBatch: %w", err)
+	}
+
+	return reversedCount, nil
+}
+
+// matchSettleRecordRefIds 以學生名稱比對 kintone 上的結算記錄，回傳各學生的結算記錄 id
+func matchSettleRecordRefIds(items []*po.SettlementRunItem, settleRecords []*bo.SemesterSettleRecord) map[int64][]int {
+	studentIdMap := make(map[string]int64, len(items))
+	for _, item := range items {
+		studentIdMap[item.KintoneStudentName] = item.StudentId
+	}
+
+	refIds := make(map[int64][]int)
+	for _, record := range settleRecords {
+		if studentId, ok := studentIdMap[record.KintoneStudentName]; ok {
+			refIds[studentId] = append(refIds[studentId], record.RecordRefId)
+		}
+	}
+
+	return refIds
+}
+
+// restoredPointCardData 只退回已經扣除點數的學生，db 點數卡回到建立結算清單時的點數，kintone 點數卡的清除點數歸零
+// 不以目前的點數卡計算，刪除 kintone 結算記錄後點數卡可能已被同步過；結算後的儲值、扣點在下次同步點數卡時更新
+func restoredPointCardData(items []*po.SettlementRunItem) []*bo.SyncSettledStudentPointCardData {
+	data := make([]*bo.SyncSettledStudentPointCardData, 0, len(items))
+	for _, item := range items {
+		if item.RestPointsAfter == nil {
+			continue
+		}
+		data = append(data, &bo.SyncSettledStudentPointCardData{
+			StudentId:          item.StudentId,
+			KintoneStudentName: item.KintoneStudentName,
+			ClearPoints:        points.Points{},
+			RestPoints:         item.RestPointsBefore,
+		})
+	}
+
+	return data
+}
+
+func toSettlementReversalBo(poReversal *po.SettlementReversal) *bo.SettlementReversal {
+	return &bo.SettlementReversal{
+		ReversalId:    poReversal.ReversalId,
+		RunId:         poReversal.RunId,
+		StudentId:     poReversal.StudentId,
+		Operator:      poReversal.Operator,
+		Reason:        poReversal.Reason,
+		Status:        poReversal.Status,
+		ReversedCount: poReversal.ReversedCount,
+		Error:         poReversal.Error,
+		FinishedAt:    poReversal.FinishedAt,
+		CreatedAt:     poReversal.CreatedAt,
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/repository"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"jaystar/internal/utils/points"
+	"testing"
+	"time"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type fakeSemesterSettleRecordRepo struct {
+	interfaces.ISemesterSettleRecordRepo
+}
+
+func (r *fakeSemesterSettleRecordRepo) UpdateRecord(context.Context, *gorm.DB, *po.UpdateSemesterSettleRecordCond, *po.UpdateSemesterSettleRecordData) error {
+	return nil
+}
+
+func TestMatchSettleRecordRefIds(t *testing.T) {
+	items := []*po.SettlementRunItem{
+		{StudentId: 1, KintoneStudentName: "A1"},
+		{StudentId: 2, KintoneStudentName: "B2"},
+	}
+	settleRecords := []*bo.SemesterSettleRecord{
+		{KintoneStudentName: "A1", RecordRefId: 101},
+		// 新增後還沒記錄步驟，重新執行時又新增了一筆
+		{KintoneStudentName: "A1", RecordRefId: 111},
+		{KintoneStudentName: "Z9", RecordRefId: 199},
+	}
+
+	want := map[int64][]int{1: {101, 111}}
+	assert.Equal(t, want, matchSettleRecordRefIds(items, settleRecords))
+}
+
+func TestRestoredPointCardData(t *testing.T) {
+	restPointsAfter := func(f float64) *points.Points {
+		p := pts(f)
+		return &p
+	}
+	items := []*po.SettlementRunItem{
+		{StudentId: 1, KintoneStudentName: "A1", ClearPoints: pts(7.5), RestPointsBefore: pts(7.5), RestPointsAfter: restPointsAfter(0)},
+		// 還沒扣除點數
+		{StudentId: 2, KintoneStudentName: "B2", ClearPoints: pts(3), RestPointsBefore: pts(3)},
+		// 沒有點數卡
+		{StudentId: 3, KintoneStudentName: "C3", ClearPoints: pts(2), RestPointsAfter: restPointsAfter(-2)},
+	}
+
+	want := []*bo.SyncSettledStudentPointCardData{
+		{StudentId: 1, KintoneStudentName: "A1", ClearPoints: pts(0), RestPoints: pts(7.5)},
+		{StudentId: 3, KintoneStudentName: "C3", ClearPoints: pts(0), RestPoints: pts(0)},
+	}
+	assert.Equal(t, want, restoredPointCardData(items))
+}
+
+func TestReverseSettlementItemsAfterPointCardSynced(t *testing.T) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	cfg := server.ConfigEnv()
+	log := logger.ProviderILogger(cfg)
+
+	refId := 301
+	restPointsAfter := pts(3)
+	runRepo := &fakeSettlementRunRepo{items: []*po.SettlementRunItem{
+		{RunId: 1, StudentId: 2, KintoneStudentName: kintoneFake.StudentB, ClearPoints: pts(2), RestPointsBefore: pts(5), RestPointsAfter: &restPointsAfter, SettleRecordRefId: &refId, Step: settlement.ItemStepReversing},
+	}}
+	// 上次撤銷刪除 kintone 結算記錄後中斷，點數卡已同步為退回後的點數
+	server.Clear(kintoneFake.AppId.SemesterSettleRecord)
+	pointCardSrv := &fakePointCardSrv{pointCards: map[int64]*bo.PointCard{2: {StudentId: 2, RestPoints: pts(5)}}}
+	srv := &SemesterSettleRecordService{
+		DB:                              newFakePostgresDB(t),
+		logger:                          log,
+		kintoneSemesterSettleRecordRepo: repository.ProvideKintoneSemesterSettleRecordRepository(cfg, kintoneAPI.ProvideKintoneClient(cfg, log)),
+		semesterSettleRecordRepo:        &fakeSemesterSettleRecordRepo{},
+		pointCardSrv:                    pointCardSrv,
+		settlementRunRepo:               runRepo,
+	}
+
+	run := &po.SettlementRun{
+		RunId:     1,
+		StartTime: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	}
+	reversedCount, err := srv.reverseSettlementItems(context.TODO(), run, 11, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, reversedCount)
+	assert.Equal(t, settlement.ItemStepReversed, runRepo.items[0].Step)
+	assert.Equal(t, pts(5), pointCardSrv.pointCards[2].RestPoints)
+}
@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/strUtil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func (srv *SemesterSettleRecordService) GetSettlementRuns(ctx context.Context, cond *bo.SettlementRunCond) ([]*bo.SettlementRun, *po.PagerResult, error) {
+	db := srv.DB.Session()
+	poCond := &po.SettlementRunCond{SemesterId: cond.SemesterId}
+	poPager := &po.Pager{Index: cond.Index, Size: cond.Size, Order: cond.Order}
+
+	poRuns, err := srv.settlementRunRepo.GetRuns(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("settlementRunRepo.GetRuns: %w", err)
+	}
+	pagerResult, err := srv.settlementRunRepo.GetRunsPager(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("settlementRunRepo.GetRunsPager: %w", err)
+	}
+
+	runs := make([]*bo.SettlementRun, 0, len(poRuns))
+	if len(poRuns) == 0 {
+		return runs, pagerResult, nil
+	}
+
+	runIds := make([]int64, 0, len(poRuns))
+	for _, poRun := range poRuns {
+		runIds = append(runIds, poRun.RunId)
+	}
+	stepCounts, err := srv.settlementRunRepo.GetRunStepCounts(ctx, db, runIds)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("settlementRunRepo.GetRunStepCounts: %w", err)
+	}
+
+	runMap := make(map[int64]*bo.SettlementRun, len(poRuns))
+	for _, poRun := range poRuns {
+		run := toSettlementRunBo(poRun)
+		runMap[run.RunId] = run
+		runs = append(runs, run)
+	}
+	for _, stepCount := range stepCounts {
+		if run, ok := runMap[stepCount.RunId]; ok {
+			run.StepCounts[stepCount.Step] = stepCount.Count
+		}
+	}
+
+	return runs, pagerResult, nil
+}
+
+func (srv *SemesterSettleRecordService) GetSettlementRun(ctx context.Context, runId int64) (*bo.SettlementRun, error) {
+	db := srv.DB.Session()
+
+	poRun, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{RunId: runId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", errs.SettlementErr.RunNotFoundError)
+		}
+		return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+	poItems, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: runId})
+	if err != nil {
+		return nil, xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+	}
+
+	run := toSettlementRunBo(poRun)
+	run.Items = make([]*bo.SettlementRunItem, 0, len(poItems))
+	for _, poItem := range poItems {
+		run.StepCounts[poItem.Step]++
+		run.Items = append(run.Items, &bo.SettlementRunItem{
+			StudentId:          poItem.StudentId,
+			KintoneStudentName: poItem.KintoneStudentName,
+			ClearPoints:        poItem.ClearPoints,
+			Step:               poItem.Step,
+			SettleRecordRefId:  poItem.SettleRecordRefId,
+			RestPointsBefore:   poItem.RestPointsBefore,
+			RestPointsAfter:    poItem.RestPointsAfter,
+			ReversalId:         poItem.ReversalId,
+		})
+	}
+
+	return run, nil
+}
+
+// ResumeSettlementRun 從中斷的步驟繼續執行結算，force 時會接手狀態停在執行中的結算 (e.g. 執行到一半服務重啟)
+func (srv *SemesterSettleRecordService) ResumeSettlementRun(ctx context.Context, runId int64, force bool) error {
+	if err := srv.executeSettlementRun(ctx, audit.ActionSettlementResume, runId, force); err != nil {
+		return xerrors.Errorf("executeSettlementRun: %w", err)
+	}
+
+	return nil
+}
+
+// getOrCreateSettlementRun 學期已有未撤銷的結算時直接回傳，否則同步 kintone 資料後建立結算清單
+// 結算清單建立後就不會再重新計算，避免新增部分結算記錄後，重新執行時被當作已結算而略過
+// 整個學期的結算撤銷後會建立新的結算，重新計算修正後的資料
+func (srv *SemesterSettleRecordService) getOrCreateSettlementRun(ctx context.Context, semester *bo.Semester) (*po.SettlementRun, error) {
+	db := srv.DB.Session()
+
+	run, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{
+		SemesterId:      semester.SemesterId,
+		ExcludeStatuses: []settlement.RunStatus{settlement.RunStatusReversed},
+	})
+	if err == nil {
+		return run, nil
+	}
+	if !errors.Is(err, errs.DbErr.NoRow) {
+		return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+
+	/* 取得結算起始、結束時間 */
+	r := dateRange{}
+	if err := srv.getSemesterRanges(semester, &r); err != nil {
+		return nil, xerrors.Errorf("getSemesterRanges: %w", errs.SemesterErr.TimeZoneInvalidError)
+	}
+
+	/* 同步 kintone 學生/點數/購課/點名資料 */
+	if err := srv.syncSettlementData(ctx, r); err != nil {
+		return nil, xerrors.Errorf("syncSettlementData: %w", err)
+	}
+
+	/* 撈取需要結算的學生並統計結算清單 */
+	settlementList, err := srv.prepareSettlement(ctx, db, r)
+	if err != nil {
+		return nil, xerrors.Errorf("prepareSettlement: %w", err)
+	}
+
+	runId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+	run = &po.SettlementRun{
+		RunId:      runId,
+		SemesterId: semester.SemesterId,
+		StartTime:  r.Start,
+		EndTime:    r.End,
+		Status:     settlement.RunStatusPending,
+		TotalCount: len(settlementList),
+	}
+	items := make([]*po.SettlementRunItem, 0, len(settlementList))
+	for _, item := range settlementList {
+		items = append(items, &po.SettlementRunItem{
+			RunId:              runId,
+			StudentId:          item.student.StudentId,
+			KintoneStudentName: strUtil.GetFullStudentName(item.student.StudentName, item.student.ParentPhone),
+			ClearPoints:        item.clearPoints,
+			RestPointsBefore:   item.restPoints,
+			Step:               settlement.ItemStepPending,
+		})
+	}
+
+	tx := db.Begin()
+	if err := srv.settlementRunRepo.AddRun(ctx, tx, run, items); err != nil {
+		tx.Rollback()
+		// 同一個學期的結算已經由其他地方建立
+		if errors.Is(err, errs.DbErr.UniqueViolation) {
+			return nil, xerrors.Errorf("settlementRunRepo.AddRun: %w", errs.SettlementErr.RunIsRunningError)
+		}
+		return nil, xerrors.Errorf("settlementRunRepo.AddRun: %w", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, xerrors.Errorf("Commit: %w", err)
+	}
+
+	return run, nil
+}
+
+// syncSettlementData 結算前先同步 kintone 學生/點數/購課/點名資料
+func (srv *SemesterSettleRecordService) syncSettlementData(ctx context.Context, r dateRange) error {
+	// 必須先同步學生資料，後續才同步其他跟學生資料相關的資料
+	studentWg := sync.WaitGroup{}
+	err := srv.studentSrv.BatchSyncStudentsAndUsers(ctx, nil, nil, &studentWg)
+	if err != nil {
+		return xerrors.Errorf("studentSrv.BatchSyncStudentsAndUsers: %w", err)
+	}
+	studentWg.Wait()
+
+	wg := sync.WaitGroup{}
+	err = srv.pointCardSrv.BatchSyncPointCard(ctx, nil, nil, &wg)
+	if err != nil {
+		return xerrors.Errorf("pointCardSrv.BatchSyncPointCard: %w", err)
+	}
+
+	drCond := bo.SyncDepositRecordCond{
+		ChargingDateStart: &r.Start,
+		ChargingDateEnd:   &r.End,
+	}
+	err = srv.depositRecordSrv.BatchSyncDepositRecord(ctx, &drCond, nil, &wg)
+	if err != nil {
+		return xerrors.Errorf("depositRecordSrv.BatchSyncDepositRecord: %w", err)
+	}
+
+	rrCond := bo.SyncReduceRecordCond{
+		ClassTimeStart: &r.Start,
+		ClassTimeEnd:   &r.End,
+	}
+	err = srv.reduceRecordSrv.BatchSyncReduceRecord(ctx, &rrCond, nil, &wg)
+	if err != nil {
+		return xerrors.Errorf("reduceRecordSrv.BatchSyncReduceRecord: %w", err)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// executeSettlementRun 取得執行權後依序執行每個步驟，每個步驟只處理還停在前一個步驟的學生
+// 有執行時以 action 記錄稽核記錄，執行失敗時也會記錄，失敗前可能已有部分學生完成結算
+func (srv *SemesterSettleRecordService) executeSettlementRun(ctx context.Context, action audit.Action, runId int64, force bool) (err error) {
+	db := srv.DB.Session()
+
+	before, err := srv.getSettlementRunSummary(ctx, runId)
+	if err != nil {
+		return xerrors.Errorf("getSettlementRunSummary: %w", err)
+	}
+
+	statuses := []settlement.RunStatus{settlement.RunStatusPending, settlement.RunStatusFailed}
+	if force {
+		statuses = append(statuses, settlement.RunStatusRunning)
+	}
+	now := time.Now()
+	running := settlement.RunStatusRunning
+	emptyErr := ""
+	affected, err := srv.settlementRunRepo.UpdateRun(ctx, db, &po.UpdateSettlementRunCond{RunId: runId, Statuses: statuses}, &po.UpdateSettlementRunData{
+		Status:    &running,
+		Error:     &emptyErr,
+		StartedAt: &now,
+	})
+	if err != nil {
+		return xerrors.Errorf("settlementRunRepo.UpdateRun: %w", err)
+	}
+
+	run, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{RunId: runId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return xerrors.Errorf("settlementRunRepo.GetRun: %w", errs.SettlementErr.RunNotFoundError)
+		}
+		return xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+	if affected == 0 {
+		switch run.Status {
+		case settlement.RunStatusCompleted:
+			return nil
+		case settlement.RunStatusReversed:
+			return xerrors.Errorf("settlementRunRepo.UpdateRun run_id: %d: %w", runId, errs.SettlementErr.RunReversedError)
+		}
+		return xerrors.Errorf("settlementRunRepo.UpdateRun run_id: %d: %w", runId, errs.SettlementErr.RunIsRunningError)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = xerrors.Errorf("panic on error: %v", r)
+		}
+		srv.finishSettlementRun(ctx, runId, err)
+		srv.addSettlementRunAuditLog(ctx, action, before)
+	}()
+
+	if err = srv.insertSettleRecords(ctx, run); err != nil {
+		return xerrors.Errorf("insertSettleRecords: %w", err)
+	}
+	if err = srv.updateSettledPointCards(ctx, run); err != nil {
+		return xerrors.Errorf("updateSettledPointCards: %w", err)
+	}
+	if err = srv.syncSettledRecords(ctx, run); err != nil {
+		return xerrors.Errorf("syncSettledRecords: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *SemesterSettleRecordService) finishSettlementRun(ctx context.Context, runId int64, runErr error) {
+	now := time.Now()
+	status := settlement.RunStatusCompleted
+	errMsg := ""
+	if runErr != nil {
+		status = settlement.RunStatusFailed
+		errMsg = runErr.Error()
+	}
+
+	_, err := srv.settlementRunRepo.UpdateRun(ctx, srv.DB.Session(), &po.UpdateSettlementRunCond{RunId: runId}, &po.UpdateSettlementRunData{
+		Status:     &status,
+		Error:      &errMsg,
+		FinishedAt: &now,
+	})
+	if err != nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService finishSettlementRun settlementRunRepo.UpdateRun failed", err, zap.Int64("run_id", runId), zap.String("status", string(status)))
+	}
+}
+
+// getSettlementRunSummary 結算的狀態與各步驟的學生數，不包含每個學生的結算內容
+func (srv *SemesterSettleRecordService) getSettlementRunSummary(ctx context.Context, runId int64) (*bo.SettlementRun, error) {
+	db := srv.DB.Session()
+
+	poRun, err := srv.settlementRunRepo.GetRun(ctx, db, &po.SettlementRunCond{RunId: runId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", errs.SettlementErr.RunNotFoundError)
+		}
+		return nil, xerrors.Errorf("settlementRunRepo.GetRun: %w", err)
+	}
+	stepCounts, err := srv.settlementRunRepo.GetRunStepCounts(ctx, db, []int64{runId})
+	if err != nil {
+		return nil, xerrors.Errorf("settlementRunRepo.GetRunStepCounts: %w", err)
+	}
+
+	run := toSettlementRunBo(poRun)
+	for _, stepCount := range stepCounts {
+		run.StepCounts[stepCount.Step] = stepCount.Count
+	}
+
+	return run, nil
+}
+
+// addSettlementRunAuditLog 以結算執行前後的狀態記錄稽核記錄
+func (srv *SemesterSettleRecordService) addSettlementRunAuditLog(ctx context.Context, action audit.Action, before *bo.SettlementRun) {
+	after, err := srv.getSettlementRunSummary(ctx, before.RunId)
+	if err != nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService addSettlementRunAuditLog getSettlementRunSummary", err, zap.Int64("run_id", before.RunId))
+		return
+	}
+
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetSettlementRun, TargetId: before.RunId, Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "SemesterSettleRecordService addSettlementRunAuditLog auditLogSrv.AddAuditLog", err, zap.Int64("run_id", before.RunId))
+	}
+}
+
+// insertSettleRecords 新增 kintone 結算記錄，每批新增成功後記錄步驟
+func (srv *SemesterSettleRecordService) insertSettleRecords(ctx context.Context, run *po.SettlementRun) error {
+	db := srv.DB.Session()
+
+	items, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: run.RunId, Step: settlement.ItemStepPending})
+	if err != nil {
+		return xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	// 上次執行可能在 kintone 新增成功後、記錄步驟前中斷，已經有結算記錄的學生只補上步驟，不重複新增
+	settleRecords, err := srv.GetAllKintoneSemesterSettleRecords(ctx, &dto.SemesterSettleRecordReq{
+		StartTime: run.StartTime,
+		EndTime:   run.EndTime,
+	})
+	if err != nil {
+		return xerrors.Errorf("GetAllKintoneSemesterSettleRecords: %w", err)
+	}
+	items, insertedRefIds := splitInsertedSettleRecords(items, settleRecords)
+	for studentId, refId := range insertedRefIds {
+		if err := srv.markSettleRecordInserted(ctx, db, run.RunId, studentId, refId); err != nil {
+			return xerrors.Errorf("markSettleRecordInserted: %w", err)
+		}
+	}
+
+	r := dateRange{Start: run.StartTime, End: run.EndTime}
+	return utils.RunInBatch(len(items), kintone.BatchInsertRecordsMaxLimit, func(batchIndex int, start int, end int) error {
+		batch := items[start:end]
+		refIds, err := srv.insertKintoneSemesterSettleRecords(ctx, batch, r)
+		if err != nil {
+			return xerrors.Errorf("insertKintoneSemesterSettleRecords batch [%d]: %w", batchIndex, err)
+		}
+
+		tx := db.Begin()
+		for i, item := range batch {
+			if err := srv.markSettleRecordInserted(ctx, tx, run.RunId, item.StudentId, refIds[i]); err != nil {
+				tx.Rollback()
+				return xerrors.Errorf("markSettleRecordInserted batch [%d]: %w", batchIndex, err)
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return xerrors.Errorf("Commit batch [%d]: %w", batchIndex, err)
+		}
+
+		srv.logger.Info(ctx, fmt.Sprintf("insertSettleRecords: batch %d succeeded", batchIndex), zap.Int64("run_id", run.RunId))
+		return nil
+	})
+}
+
+func (srv *SemesterSettleRecordService) insertKintoneSemesterSettleRecords(ctx context.Context, items []*po.SettlementRunItem, r dateRange) ([]int, error) {
+	req := &dto.InsertSemesterSettleRecordsReq{
+		Records: make([]dto.InsertSemesterSettleRecord, 0, len(items)),
+	}
+	for _, item := range items {
+		insertRecord := dto.InsertSemesterSettleRecord{}
+		insertRecord.StudentName.Value = item.KintoneStudentName
+		// kintone 應用起始時間/結束時間只記錄日期
+		insertRecord.StartTime.Value = r.Start.Format(time.DateOnly)
+		insertRecord.EndTime.Value = r.End.Format(time.DateOnly)
+		insertRecord.ClearPoints.Value = item.ClearPoints.String()
+		req.Records = append(req.Records, insertRecord)
+	}
+
+	res, err := srv.kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords(ctx, req)
+	if err != nil {
+		return nil, xerrors.Errorf("kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords: %w", err)
+	}
+	if len(res.Ids) != len(items) {
+		return nil, xerrors.Errorf("kintoneSemesterSettleRecordRepo.InsertKintoneSemesterSettleRecords: got %d ids for %d records: %w", len(res.Ids), len(items), errs.KintoneErr.ResponseEmptyError)
+	}
+
+	refIds := make([]int, 0, len(res.Ids))
+	for _, id := range res.Ids {
+		refId, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, xerrors.Errorf("strconv.Atoi: %w", err)
+		}
+		refIds = append(refIds, refId)
+	}
+
+	return refIds, nil
+}
+
+func (srv *SemesterSettleRecordService) markSettleRecordInserted(ctx context.Context, db *gorm.DB, runId int64, studentId int64, refId int) error {
+	return srv.settlementRunRepo.UpdateRunItems(ctx, db, &po.UpdateSettlementRunItemCond{
+		RunId:      runId,
+		StudentIds: []int64{studentId},
+		Step:       settlement.ItemStepPending,
+	}, &po.UpdateSettlementRunItemData{
+		Step:              settlement.ItemStepSettleRecordInserted,
+		SettleRecordRefId: &refId,
+	})
+}
+
+// updateSettledPointCards 每批在同一個交易內更新 db 點數卡與步驟，kintone 點數卡只設定清除點數
+// 扣點後的點數以建立結算清單時的點數計算，中斷後點數卡被同步過再繼續執行也不會重複扣點
+func (srv *SemesterSettleRecordService) updateSettledPointCards(ctx context.Context, run *po.SettlementRun) error {
+	db := srv.DB.Session()
+
+	items, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: run.RunId, Step: settlement.ItemStepSettleRecordInserted})
+	if err != nil {
+		return xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+	}
+
+	return utils.RunInBatch(len(items), kintone.BatchUpdateRecordsMaxLimit, func(batchIndex int, start int, end int) (err error) {
+		batch := items[start:end]
+
+		tx := db.Begin()
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+
+		data := settledPointCardData(batch)
+		for _, d := range data {
+			err = srv.settlementRunRepo.UpdateRunItems(ctx, tx, &po.UpdateSettlementRunItemCond{
+				RunId:      run.RunId,
+				StudentIds: []int64{d.StudentId},
+				Step:       settlement.ItemStepSettleRecordInserted,
+			}, &po.UpdateSettlementRunItemData{
+				Step:            settlement.ItemStepPointCardUpdated,
+				RestPointsAfter: &d.RestPoints,
+			})
+			if err != nil {
+				return xerrors.Errorf("settlementRunRepo.UpdateRunItems batch [%d]: %w", batchIndex, err)
+			}
+		}
+
+		if err = srv.pointCardSrv.SyncSettledStudentPointCards(ctx, tx, data); err != nil {
+			return xerrors.Errorf("pointCardSrv.SyncSettledStudentPointCards batch [%d]: %w", batchIndex, err)
+		}
+		if err = tx.Commit().Error; err != nil {
+			return xerrors.Errorf("Commit batch [%d]: %w", batchIndex, err)
+		}
+
+		return nil
+	})
+}
+
+// syncSettledRecords 將新增的結算記錄同步回 db，同步失敗的學生停在前一個步驟
+func (srv *SemesterSettleRecordService) syncSettledRecords(ctx context.Context, run *po.SettlementRun) error {
+	db := srv.DB.Session()
+
+	items, err := srv.settlementRunRepo.GetRunItems(ctx, db, &po.SettlementRunItemCond{RunId: run.RunId, Step: settlement.ItemStepPointCardUpdated})
+	if err != nil {
+		return xerrors.Errorf("settlementRunRepo.GetRunItems: %w", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	tracker := bo.NewSyncJobTracker()
+	wg := sync.WaitGroup{}
+	err = srv.BatchSyncSemesterSettleRecord(ctx, &bo.SyncSemesterSettleRecordCond{StartTime: &run.StartTime, EndTime: &run.EndTime}, tracker, &wg)
+	if err != nil {
+		return xerrors.Errorf("BatchSyncSemesterSettleRecord: %w", err)
+	}
+	wg.Wait()
+
+	report := tracker.Report()
+	if report.Error != "" {
+		return xerrors.Errorf("BatchSyncSemesterSettleRecord: %s", report.Error)
+	}
+
+	studentIds := syncedSettlementStudentIds(items, report)
+	if len(studentIds) > 0 {
+		err = srv.settlementRunRepo.UpdateRunItems(ctx, db, &po.UpdateSettlementRunItemCond{
+			RunId:      run.RunId,
+			StudentIds: studentIds,
+			Step:       settlement.ItemStepPointCardUpdated,
+		}, &po.UpdateSettlementRunItemData{
+			Step: settlement.ItemStepSynced,
+		})
+		if err != nil {
+			return xerrors.Errorf("settlementRunRepo.UpdateRunItems: %w", err)
+		}
+	}
+	if report.Failed > 0 {
+		return xerrors.Errorf("BatchSyncSemesterSettleRecord: %d records failed", report.Failed)
+	}
+
+	return nil
+}
+
+// settledPointCardData 扣點後的點數為建立結算清單時的點數減去清除點數，不使用目前的點數卡
+func settledPointCardData(items []*po.SettlementRunItem) []*bo.SyncSettledStudentPointCardData {
+	data := make([]*bo.SyncSettledStudentPointCardData, 0, len(items))
+	for _, item := range items {
+		data = append(data, &bo.SyncSettledStudentPointCardData{
+			StudentId:          item.StudentId,
+			KintoneStudentName: item.KintoneStudentName,
+			ClearPoints:        item.ClearPoints,
+			RestPoints:         item.RestPointsBefore.Sub(item.ClearPoints),
+		})
+	}
+
+	return data
+}
+
+// splitInsertedSettleRecords 分出 kintone 上已經有結算記錄的學生，回傳還需要新增的學生與已新增學生的結算記錄 id
+func splitInsertedSettleRecords(items []*po.SettlementRunItem, settleRecords []*bo.SemesterSettleRecord) ([]*po.SettlementRunItem, map[int64]int) {
+	refIdMap := make(map[string]int, len(settleRecords))
+	for _, record := range settleRecords {
+		refIdMap[record.KintoneStudentName] = record.RecordRefId
+	}
+
+	remaining := make([]*po.SettlementRunItem, 0, len(items))
+	insertedRefIds := make(map[int64]int)
+	for _, item := range items {
+		if refId, ok := refIdMap[item.KintoneStudentName]; ok {
+			insertedRefIds[item.StudentId] = refId
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	return remaining, insertedRefIds
+}
+
+// syncedSettlementStudentIds 排除同步失敗的結算記錄，失敗數超過保留的筆數時無法判斷是哪些學生，全部視為失敗
+func syncedSettlementStudentIds(items []*po.SettlementRunItem, report bo.SyncJobReport) []int64 {
+	if report.Failed > len(report.Failures) {
+		return []int64{}
+	}
+
+	failedRefIds := make(map[int]struct{}, len(report.Failures))
+	for _, failure := range report.Failures {
+		failedRefIds[failure.RecordRefId] = struct{}{}
+	}
+
+	studentIds := make([]int64, 0, len(items))
+	for _, item := range items {
+		if item.SettleRecordRefId == nil {
+			continue
+		}
+		if _, ok := failedRefIds[*item.SettleRecordRefId]; ok {
+			continue
+		}
+		studentIds = append(studentIds, item.StudentId)
+	}
+
+	return studentIds
+}
+
+func toSettlementRunBo(poRun *po.SettlementRun) *bo.SettlementRun {
+	return &bo.SettlementRun{
+		RunId:      poRun.RunId,
+		SemesterId: poRun.SemesterId,
+		StartTime:  poRun.StartTime,
+		EndTime:    poRun.EndTime,
+		Status:     poRun.Status,
+		TotalCount: poRun.TotalCount,
+		StepCounts: make(map[settlement.ItemStep]int, len(settlement.ItemSteps)),
+		Error:      poRun.Error,
+		StartedAt:  poRun.StartedAt,
+		FinishedAt: poRun.FinishedAt,
+		CreatedAt:  poRun.CreatedAt,
+		UpdatedAt:  poRun.UpdatedAt,
+	}
+}
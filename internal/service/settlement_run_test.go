@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"jaystar/internal/constant/settlement"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeConnPool 只支援交易的開始與結束，搭配 DryRun 不會真的執行 sql
+type fakeConnPool struct {
+	gorm.ConnPool
+}
+
+func (p *fakeConnPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return p, nil
+}
+
+func (p *fakeConnPool) Commit() error {
+	return nil
+}
+
+func (p *fakeConnPool) Rollback() error {
+	return nil
+}
+
+type fakePostgresDB struct {
+	db *gorm.DB
+}
+
+func (f *fakePostgresDB) Session() *gorm.DB {
+	return f.db.Session(&gorm.Session{})
+}
+
+func newFakePostgresDB(t *testing.T) *fakePostgresDB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &fakeConnPool{}}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return &fakePostgresDB{db: db}
+}
+
+type fakeSettlementRunRepo struct {
+	interfaces.ISettlementRunRepo
+	items []*po.SettlementRunItem
+}
+
+func (r *fakeSettlementRunRepo) GetRunItems(_ context.Context, _ *gorm.DB, cond *po.SettlementRunItemCond) ([]*po.SettlementRunItem, error) {
+	items := make([]*po.SettlementRunItem, 0)
+	for _, item := range r.items {
+		if (cond.StudentId == 0 || item.StudentId == cond.StudentId) && (cond.Step == "" || item.Step == cond.Step) {
+			copied := *item
+			items = append(items, &copied)
+		}
+	}
+	return items, nil
+}
+
+func (r *fakeSettlementRunRepo) UpdateRunItems(_ context.Context, _ *gorm.DB, cond *po.UpdateSettlementRunItemCond, data *po.UpdateSettlementRunItemData) error {
+	for _, item := range r.items {
+		if len(cond.StudentIds) > 0 && !slices.Contains(cond.StudentIds, item.StudentId) || cond.Step != "" && item.Step != cond.Step {
+			continue
+		}
+		item.Step = data.Step
+		if data.SettleRecordRefId != nil {
+			item.SettleRecordRefId = data.SettleRecordRefId
+		}
+		if data.RestPointsAfter != nil {
+			restPointsAfter := *data.RestPointsAfter
+			item.RestPointsAfter = &restPointsAfter
+		}
+		if data.ReversalId != nil {
+			item.ReversalId = data.ReversalId
+		}
+	}
+	return nil
+}
+
+type fakePointCardSrv struct {
+	interfaces.IPointCardSrv
+	pointCards map[int64]*bo.PointCard
+}
+
+func (s *fakePointCardSrv) GetPointCards(_ context.Context, _ *gorm.DB, cond *bo.GetPointCardCond) (map[int64]*bo.PointCard, error) {
+	pointCards := make(map[int64]*bo.PointCard)
+	for _, studentId := range cond.StudentIds {
+		if pointCard, ok := s.pointCards[studentId]; ok {
+			pointCards[studentId] = pointCard
+		}
+	}
+	return pointCards, nil
+}
+
+func (s *fakePointCardSrv) SyncSettledStudentPointCards(_ context.Context, _ *gorm.DB, data []*bo.SyncSettledStudentPointCardData) error {
+	for _, d := range data {
+		s.pointCards[d.StudentId] = &bo.PointCard{StudentId: d.StudentId, RestPoints: d.RestPoints}
+	}
+	return nil
+}
+
+func TestSplitInsertedSettleRecords(t *testing.T) {
+	items := []*po.SettlementRunItem{
+		{StudentId: 1, KintoneStudentName: "A1"},
+		{StudentId: 2, KintoneStudentName: "B2"},
+		{StudentId: 3, KintoneStudentName: "C3"},
+	}
+
+	tests := []struct {
+		name          string
+		settleRecords []*bo.SemesterSettleRecord
+		wantRemaining []*po.SettlementRunItem
+		wantInserted  map[int64]int
+	}{
+		{
+			name:          "沒有已新增的記錄",
+			settleRecords: nil,
+			wantRemaining: items,
+			wantInserted:  map[int64]int{},
+		},
+		{
+			name: "上次中斷前已新增部分記錄",
+			settleRecords: []*bo.SemesterSettleRecord{
+				{KintoneStudentName: "B2", RecordRefId: 102},
+				{KintoneStudentName: "Z9", RecordRefId: 199},
+			},
+			wantRemaining: []*po.SettlementRunItem{items[0], items[2]},
+			wantInserted:  map[int64]int{2: 102},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, inserted := splitInsertedSettleRecords(items, tt.settleRecords)
+			assert.Equal(t, tt.wantRemaining, remaining)
+			assert.Equal(t, tt.wantInserted, inserted)
+		})
+	}
+}
+
+func TestSyncedSettlementStudentIds(t *testing.T) {
+	refId := func(id int) *int { return &id }
+	items := []*po.SettlementRunItem{
+		{StudentId: 1, SettleRecordRefId: refId(101)},
+		{StudentId: 2, SettleRecordRefId: refId(102)},
+		{StudentId: 3},
+	}
+
+	tests := []struct {
+		name   string
+		report bo.SyncJobReport
+		want   []int64
+	}{
+		{
+			name:   "全部同步成功",
+			report: bo.SyncJobReport{},
+			want:   []int64{1, 2},
+		},
+		{
+			name: "排除同步失敗的記錄",
+			report: bo.SyncJobReport{
+				Failed:   1,
+				Failures: []*bo.SyncJobFailure{{RecordRefId: 102}},
+			},
+			want: []int64{1},
+		},
+		{
+			name: "失敗記錄未全部保留",
+			report: bo.SyncJobReport{
+				Failed:   2,
+				Failures: []*bo.SyncJobFailure{{RecordRefId: 102}},
+			},
+			want: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, syncedSettlementStudentIds(items, tt.report))
+		})
+	}
+}
+
+func TestSettledPointCardData(t *testing.T) {
+	items := []*po.SettlementRunItem{
+		{StudentId: 1, KintoneStudentName: "A1", ClearPoints: pts(4), RestPointsBefore: pts(10)},
+		// 沒有點數卡
+		{StudentId: 2, KintoneStudentName: "B2", ClearPoints: pts(2)},
+	}
+
+	want := []*bo.SyncSettledStudentPointCardData{
+		{StudentId: 1, KintoneStudentName: "A1", ClearPoints: pts(4), RestPoints: pts(6)},
+		{StudentId: 2, KintoneStudentName: "B2", ClearPoints: pts(2), RestPoints: pts(-2)},
+	}
+	assert.Equal(t, want, settledPointCardData(items))
+}
+
+func TestUpdateSettledPointCardsResume(t *testing.T) {
+	runRepo := &fakeSettlementRunRepo{items: []*po.SettlementRunItem{
+		{RunId: 1, StudentId: 1, KintoneStudentName: "A1", ClearPoints: pts(4), RestPointsBefore: pts(10), Step: settlement.ItemStepSettleRecordInserted},
+	}}
+	// 新增結算記錄後中斷，繼續執行前點數卡已同步為 kintone 扣點後的點數
+	pointCardSrv := &fakePointCardSrv{pointCards: map[int64]*bo.PointCard{1: {StudentId: 1, RestPoints: pts(6)}}}
+	srv := &SemesterSettleRecordService{DB: newFakePostgresDB(t), settlementRunRepo: runRepo, pointCardSrv: pointCardSrv}
+
+	require.NoError(t, srv.updateSettledPointCards(context.TODO(), &po.SettlementRun{RunId: 1}))
+
+	assert.Equal(t, pts(6), pointCardSrv.pointCards[1].RestPoints)
+	assert.Equal(t, settlement.ItemStepPointCardUpdated, runRepo.items[0].Step)
+	assert.Equal(t, pts(6), *runRepo.items[0].RestPointsAfter)
+}
@@ -13,17 +13,20 @@ import (
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
 	"jaystar/internal/constant/kintone"
-	"jaystar/internal/constant/request"
+	"jaystar/internal/constant/syncjob"
 	"jaystar/internal/constant/user"
+	"jaystar/internal/constant/webhook"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils"
 	"jaystar/internal/utils/errs"
 	"jaystar/internal/utils/pool"
 	"jaystar/internal/utils/strUtil"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,18 +42,24 @@ func ProvideStudentService(
 	depositCommonSrv interfaces.IDepositRecordCommonSrv,
 	reduceCommonSrv interfaces.IReduceRecordCommonSrv,
 	scheduleCommonSrv interfaces.IScheduleCommonSrv,
+	kintoneBulkRepo interfaces.IKintoneBulkRepo,
+	recordLockCommonSrv interfaces.IRecordLockCommonSrv,
+	kintoneRecordRepo interfaces.IKintoneRecordRepo,
 ) *StudentService {
 	return &StudentService{
-		DB:                 db,
-		studentRepo:        studentRepo,
-		userCommonSrv:      userCommonSrv,
-		studentCommonSrv:   studentCommonSrv,
-		logger:             logger,
-		pointCardCommonSrv: pointCardCommonSrv,
-		depositCommonSrv:   depositCommonSrv,
-		reduceCommonSrv:    reduceCommonSrv,
-		scheduleCommonSrv:  scheduleCommonSrv,
-		executorPool:       pool.NewExecutorPool(30),
+		DB:                  db,
+		studentRepo:         studentRepo,
+		userCommonSrv:       userCommonSrv,
+		studentCommonSrv:    studentCommonSrv,
+		logger:              logger,
+		pointCardCommonSrv:  pointCardCommonSrv,
+		depositCommonSrv:    depositCommonSrv,
+		reduceCommonSrv:     reduceCommonSrv,
+		scheduleCommonSrv:   scheduleCommonSrv,
+		kintoneBulkRepo:     kintoneBulkRepo,
+		recordLockCommonSrv: recordLockCommonSrv,
+		kintoneRecordRepo:   kintoneRecordRepo,
+		executorPool:        pool.NewExecutorPool(30),
 	}
 }
 
@@ -65,6 +74,9 @@ type StudentService struct {
 	scheduleCommonSrv    interfaces.IScheduleCommonSrv
 	logger               logger.ILogger
 	kintonePointCardRepo interfaces.IKintonePointCardRepo
+	kintoneBulkRepo      interfaces.IKintoneBulkRepo
+	recordLockCommonSrv  interfaces.IRecordLockCommonSrv
+	kintoneRecordRepo    interfaces.IKintoneRecordRepo
 	executorPool         *ants.Pool `wire:"-"`
 }
 
@@ -215,59 +227,35 @@ func (srv *StudentService) addStudent(ctx context.Context, userId int64, data *b
 	return nil
 }
 
-func (srv *StudentService) UpdateStudent(ctx context.Context, data *bo.Student) error {
+// UpdateStudent db 的更新在 transaction 中先執行，kintone 改名失敗時 rollback
+// kintone 改名成功但 commit 失敗時，還原 kintone 的學生名稱
+func (srv *StudentService) UpdateStudent(ctx context.Context, data *bo.Student) (err error) {
 	// 檢查是否有異動學生姓名 or 家長電話
 	dbStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentRefId: data.StudentRefId})
 	if err != nil {
 		return xerrors.Errorf("studentCommonSrv.GetStudent: %w", err)
 	}
 
-	// 有，執行其他相關記錄資料的更新，修改以下幾個應用程式的對應資料
-	if dbStudent.StudentName != data.StudentName || dbStudent.ParentPhone != data.ParentPhone {
-		// 點數管理
-		oldPointCardName := strUtil.GetFullStudentName(dbStudent.StudentName, dbStudent.ParentPhone)
-		newPointCardName := strUtil.GetFullStudentName(data.StudentName, data.ParentPhone)
-		err = srv.pointCardCommonSrv.UpdateKintonePointCardStudentName(ctx, oldPointCardName, newPointCardName)
-		if err != nil {
-			return xerrors.Errorf("pointCardCommonSrv.UpdateKintonePointCardStudentName: %w", err)
+	// transaction
+	tx := srv.DB.Session().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
 		}
-		// 購課記錄
-		err = srv.depositCommonSrv.UpdateKintoneDepositRecordsStudentNames(ctx, oldPointCardName, newPointCardName)
 		if err != nil {
-			if !errors.Is(err, errs.KintoneErr.ResponseEmptyError) {
-				return xerrors.Errorf("depositCommonSrv.UpdateKintoneDepositRecordsStudentNames: %w", err)
-			} else {
-				srv.logger.Warn(ctx, "studentService UpdateStudent depositCommonSrv.UpdateKintoneDepositRecordsStudentNames", zap.Error(err), zap.String("oldPointCardName", oldPointCardName), zap.String("newPointCardName", newPointCardName))
-			}
+			tx.Rollback()
 		}
-		// 點名管理
-		err = srv.reduceCommonSrv.UpdateKintoneReduceRecordsStudentNames(ctx, oldPointCardName, newPointCardName)
-		if err != nil {
-			if !errors.Is(err, errs.KintoneErr.ResponseEmptyError) {
-				return xerrors.Errorf("reduceCommonSrv.UpdateKintoneReduceRecordsStudentNames: %w", err)
-			} else {
-				srv.logger.Warn(ctx, "studentService UpdateStudent reduceCommonSrv.UpdateKintoneReduceRecordsStudentNames", zap.Error(err), zap.String("oldPointCardName", oldPointCardName), zap.String("newPointCardName", newPointCardName))
-			}
-		}
-		// 課表管理合併點名
-		err = srv.scheduleCommonSrv.UpdateKintoneSchedulesStudentNames(ctx, oldPointCardName, newPointCardName)
-		if err != nil {
-			if !errors.Is(err, errs.KintoneErr.ResponseEmptyError) {
-				return xerrors.Errorf("scheduleCommonSrv.UpdateKintoneSchedulesStudentNames: %w", err)
-			} else {
-				srv.logger.Warn(ctx, "studentService UpdateStudent scheduleCommonSrv.UpdateKintoneSchedulesStudentNames", zap.Error(err), zap.String("oldPointCardName", oldPointCardName), zap.String("newPointCardName", newPointCardName))
-			}
-		}
-	}
+	}()
 
 	// 綁定家長帳號
-	userId, err := srv.getOrCreateUserRetId(ctx, data)
+	userId, err := srv.getOrCreateUserRetId(ctx, tx, data)
 	if err != nil {
 		return xerrors.Errorf("getOrCreateUserRetId: %w", err)
 	}
 
 	// 更新 db 學生資料
-	err = srv.updateStudent(ctx,
+	err = srv.updateStudent(ctx, tx,
 		&bo.UpdateStudentCond{RecordRefId: data.StudentRefId},
 		&bo.UpdateStudentData{
 			StudentName:      data.StudentName,
@@ -282,35 +270,173 @@ func (srv *StudentService) UpdateStudent(ctx context.Context, data *bo.Student)
 		return xerrors.Errorf("updateStudent: %w", err)
 	}
 
+	// 有，執行其他相關記錄資料的更新，修改以下幾個應用程式的對應資料
+	rollbackKintone := func(ctx context.Context) error { return nil }
+	if dbStudent.StudentName != data.StudentName || dbStudent.ParentPhone != data.ParentPhone {
+		oldPointCardName := strUtil.GetFullStudentName(dbStudent.StudentName, dbStudent.ParentPhone)
+		newPointCardName := strUtil.GetFullStudentName(data.StudentName, data.ParentPhone)
+		rollbackKintone, err = srv.renameKintoneStudent(ctx, oldPointCardName, newPointCardName)
+		if err != nil {
+			return xerrors.Errorf("renameKintoneStudent: %w", err)
+		}
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		if rollbackErr := rollbackKintone(ctx); rollbackErr != nil {
+			srv.logger.Error(ctx, "studentService UpdateStudent rollbackKintone", rollbackErr, zap.Int("record_ref_id", data.StudentRefId))
+			return xerrors.Errorf("tx.Commit: %v, rollbackKintone: %v: %w", err, rollbackErr, errs.KintoneErr.PartiallyAppliedError)
+		}
+		return xerrors.Errorf("tx.Commit: %w", err)
+	}
+
 	return nil
 }
 
-func (srv *StudentService) getOrCreateUserRetId(ctx context.Context, newData *bo.Student) (int64, error) {
-	dbUser, err := srv.userCommonSrv.GetUser(ctx, &bo.UserCond{Accounts: []string{newData.ParentPhone}})
-	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		return 0, xerrors.Errorf("userCommonSrv.GetUser: %w", err)
+// renameKintoneStudent 修改點數管理、購課記錄、點名管理、課表管理合併點名中的學生名稱
+// 所有更新能放進一個 bulkRequest 時一次送出，全部成功或全部不套用
+// 超過時分批送出，後面的批次失敗會還原已送出的批次，無法還原時回傳 PartiallyAppliedError 並列出仍是新名稱的記錄
+// 遇到 revision 衝突且沒有殘留部分更新時，重新讀取後再試
+// 成功時回傳還原改名的 rollback，供後續步驟失敗時使用
+func (srv *StudentService) renameKintoneStudent(ctx context.Context, oldPointCardName string, newPointCardName string) (rollback func(ctx context.Context) error, err error) {
+	err = utils.RetryOnError(kintone.RevisionConflictMaxAttempts, errs.KintoneErr.RevisionConflictError, func(_ int) error {
+		var applyErr error
+		rollback, applyErr = srv.applyKintoneStudentRename(ctx, oldPointCardName, newPointCardName)
+		return applyErr
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var userId int64
+	return rollback, nil
+}
 
-	if dbUser != nil {
-		userId = dbUser.UserId
-	} else {
-		userData, err := srv.genDefaultCreateUserData(ctx, newData)
-		if err != nil {
-			return 0, xerrors.Errorf("genDefaultCreateUserData: %w", err)
+func (srv *StudentService) applyKintoneStudentRename(ctx context.Context, oldPointCardName string, newPointCardName string) (func(ctx context.Context) error, error) {
+	forward, backward, err := srv.getKintoneStudentNameUpdates(ctx, oldPointCardName, newPointCardName)
+	if err != nil {
+		return nil, xerrors.Errorf("getKintoneStudentNameUpdates: %w", err)
+	}
+
+	batchCount := (len(forward) + kintone.BulkRequestMaxRequests - 1) / kintone.BulkRequestMaxRequests
+	if batchCount > 1 {
+		srv.logger.Warn(ctx, "studentService applyKintoneStudentRename updates exceed a single bulkRequest, apply in batches",
+			zap.String("oldPointCardName", oldPointCardName),
+			zap.String("newPointCardName", newPointCardName),
+			zap.Int("requests", len(forward)),
+			zap.Int("batches", batchCount),
+		)
+	}
+
+	results := make([]dto.KintoneBulkRequestResult, 0, len(forward))
+	err = utils.RunInBatch(len(forward), kintone.BulkRequestMaxRequests, func(batchIndex int, start int, end int) error {
+		res, err := srv.kintoneBulkRepo.BulkUpdateRecords(ctx, forward[start:end])
+		if err == nil {
+			results = append(results, res.Results...)
+			return nil
+		}
+		if start == 0 {
+			return xerrors.Errorf("kintoneBulkRepo.BulkUpdateRecords: %w", err)
 		}
 
-		userId, err = srv.userCommonSrv.CreateUser(ctx, userData)
+		if rollbackErr := srv.rollbackKintoneStudentName(ctx, backward[:start], results); rollbackErr != nil {
+			srv.logger.Error(ctx, "studentService applyKintoneStudentRename rollbackKintoneStudentName", rollbackErr,
+				zap.String("oldPointCardName", oldPointCardName),
+				zap.String("newPointCardName", newPointCardName),
+			)
+			// 不保留原本的錯誤 (e.g. revision 衝突)，避免呼叫端在部分更新的狀態下重試
+			return xerrors.Errorf("kintoneBulkRepo.BulkUpdateRecords batch %d/%d: %v, rollbackKintoneStudentName: %v: %w", batchIndex, batchCount, err, rollbackErr, errs.KintoneErr.PartiallyAppliedError)
+		}
+
+		return xerrors.Errorf("kintoneBulkRepo.BulkUpdateRecords batch %d/%d (rolled back): %w", batchIndex, batchCount, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return srv.rollbackKintoneStudentName(ctx, backward, results)
+	}, nil
+}
+
+func (srv *StudentService) getKintoneStudentNameUpdates(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error) {
+	var forward, backward []*dto.KintoneBulkUpdateRecords
+	for _, getUpdates := range []struct {
+		name string
+		fn   func(ctx context.Context, oldPointCardName string, newPointCardName string) ([]*dto.KintoneBulkUpdateRecords, []*dto.KintoneBulkUpdateRecords, error)
+	}{
+		// 點數管理
+		{name: "pointCardCommonSrv.GetKintonePointCardStudentNameUpdates", fn: srv.pointCardCommonSrv.GetKintonePointCardStudentNameUpdates},
+		// 購課記錄
+		{name: "depositCommonSrv.GetKintoneDepositRecordsStudentNameUpdates", fn: srv.depositCommonSrv.GetKintoneDepositRecordsStudentNameUpdates},
+		// 點名管理
+		{name: "reduceCommonSrv.GetKintoneReduceRecordsStudentNameUpdates", fn: srv.reduceCommonSrv.GetKintoneReduceRecordsStudentNameUpdates},
+		// 課表管理合併點名
+		{name: "scheduleCommonSrv.GetKintoneSchedulesStudentNameUpdates", fn: srv.scheduleCommonSrv.GetKintoneSchedulesStudentNameUpdates},
+	} {
+		f, b, err := getUpdates.fn(ctx, oldPointCardName, newPointCardName)
 		if err != nil {
-			return 0, xerrors.Errorf("userCommonSrv.CreateUser: %w", err)
+			return nil, nil, xerrors.Errorf("%s: %w", getUpdates.name, err)
 		}
+		forward = append(forward, f...)
+		backward = append(backward, b...)
 	}
+
+	return forward, backward, nil
+}
+
+// rollbackKintoneStudentName 帶上改名後回傳的 revision 還原，還原前已被其他人修改的記錄不會被覆蓋
+func (srv *StudentService) rollbackKintoneStudentName(ctx context.Context, backward []*dto.KintoneBulkUpdateRecords, results []dto.KintoneBulkRequestResult) error {
+	for i, update := range backward {
+		for j := range update.Records {
+			revision, err := strconv.Atoi(results[i].Records[j].Revision)
+			if err != nil {
+				return xerrors.Errorf("strconv.Atoi revision: %s, err: %w", results[i].Records[j].Revision, err)
+			}
+			update.Records[j].Revision = revision
+		}
+	}
+
+	return utils.RunInBatch(len(backward), kintone.BulkRequestMaxRequests, func(_ int, start int, end int) error {
+		if _, err := srv.kintoneBulkRepo.BulkUpdateRecords(ctx, backward[start:end]); err != nil {
+			return xerrors.Errorf("kintoneBulkRepo.BulkUpdateRecords records not restored: %s: %w", describeKintoneBulkUpdates(backward[start:]), err)
+		}
+		return nil
+	})
+}
+
+func describeKintoneBulkUpdates(updates []*dto.KintoneBulkUpdateRecords) string {
+	ids := make(map[kintone.App][]int)
+	apps := make([]kintone.App, 0)
+	for _, update := range updates {
+		if _, ok := ids[update.App]; !ok {
+			apps = append(apps, update.App)
+		}
+		for _, record := range update.Records {
+			ids[update.App] = append(ids[update.App], record.Id)
+		}
+	}
+
+	descriptions := make([]string, 0, len(apps))
+	for _, app := range apps {
+		descriptions = append(descriptions, fmt.Sprintf("%s %v", app, ids[app]))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func (srv *StudentService) getOrCreateUserRetId(ctx context.Context, db *gorm.DB, newData *bo.Student) (int64, error) {
+	userData, err := srv.genDefaultCreateUserData(ctx, newData)
+	if err != nil {
+		return 0, xerrors.Errorf("genDefaultCreateUserData: %w", err)
+	}
+
+	userId, err := srv.userCommonSrv.GetOrCreateUser(ctx, db, userData)
+	if err != nil {
+		return 0, xerrors.Errorf("userCommonSrv.GetOrCreateUser: %w", err)
+	}
+
 	return userId, nil
 }
 
-func (srv *StudentService) updateStudent(ctx context.Context, cond *bo.UpdateStudentCond, data *bo.UpdateStudentData) error {
-	db := srv.DB.Session()
+func (srv *StudentService) updateStudent(ctx context.Context, db *gorm.DB, cond *bo.UpdateStudentCond, data *bo.UpdateStudentData) error {
 	poUpdateStudentCond := &po.UpdateStudentCond{StudentRefId: cond.RecordRefId}
 
 	poUpdateStudentData := &po.UpdateStudentData{
@@ -375,17 +501,9 @@ func (srv *StudentService) deleteStudent(ctx context.Context, studentRefId int)
 	return nil
 }
 
-func (srv *StudentService) BatchSyncStudentsAndUsers(ctx context.Context, cond *bo.SyncStudentCond, wg ...*sync.WaitGroup) error {
+func (srv *StudentService) BatchSyncStudentsAndUsers(ctx context.Context, cond *bo.SyncStudentCond, tracker *bo.SyncJobTracker, wg ...*sync.WaitGroup) error {
 	boStudentReq := &dto.StudentReq{}
 
-	var (
-		total           int
-		err             error
-		kintoneStudents []*bo.Student
-	)
-	boStudentReq.Limit = request.GetRecordsBatchLimit
-	boStudentReq.Offset = request.GetRecordsBatchOffset
-
 	if cond != nil {
 		if cond.StudentName != nil {
 			boStudentReq.StudentName = *cond.StudentName
@@ -393,30 +511,15 @@ func (srv *StudentService) BatchSyncStudentsAndUsers(ctx context.Context, cond *
 		if cond.ParentPhone != nil {
 			boStudentReq.ParentPhone = *cond.ParentPhone
 		}
-		if cond.Limit != nil {
-			boStudentReq.Limit = *cond.Limit
-		}
-		if cond.Offset != nil {
-			boStudentReq.Offset = *cond.Offset
+		if cond.UpdatedSince != nil {
+			boStudentReq.UpdatedSince = *cond.UpdatedSince
 		}
 	}
 
-	kintoneStudents, total, err = srv.studentCommonSrv.GetKintoneStudents(ctx, boStudentReq)
+	snapshotAt := time.Now()
+	allStudents, err := srv.studentCommonSrv.GetAllKintoneStudents(ctx, boStudentReq)
 	if err != nil {
-		return xerrors.Errorf("studentService BatchSyncStudentsAndUsers studentCommonSrv.GetKintoneStudents: %w", err)
-	}
-	allStudents := make([]*bo.Student, 0, total)
-	allStudents = append(allStudents, kintoneStudents...)
-
-	offset, limit := boStudentReq.Offset, boStudentReq.Limit
-	for ((offset * limit) + limit) < total {
-		offset += 1
-		boStudentReq.Offset = offset * limit
-		kintoneStudents, _, err = srv.studentCommonSrv.GetKintoneStudents(ctx, boStudentReq)
-		if err != nil {
-			return xerrors.Errorf("studentService BatchSyncStudentsAndUsers studentCommonSrv.GetKintoneStudents: %w", err)
-		}
-		allStudents = append(allStudents, kintoneStudents...)
+		return xerrors.Errorf("studentService BatchSyncStudentsAndUsers studentCommonSrv.GetAllKintoneStudents: %w", err)
 	}
 
 	var wait *sync.WaitGroup
@@ -431,13 +534,14 @@ func (srv *StudentService) BatchSyncStudentsAndUsers(ctx context.Context, cond *
 				srv.logger.Error(ctx, "studentService syncStudentsOrUsers panic", nil,
 					zap.Any(logger.PanicMessage, r),
 				)
+				tracker.Abort(syncjob.TypeStudent, xerrors.Errorf("panic: %v", r))
 			}
 
 			if wait != nil {
 				wait.Done()
 			}
 		}()
-		srv.syncStudentsOrUsers(ctx, allStudents, cond)
+		srv.syncStudentsOrUsers(ctx, allStudents, cond, snapshotAt, tracker)
 	}()
 
 	return nil
@@ -461,7 +565,15 @@ func (srv *StudentService) genDefaultCreateUserData(ctx context.Context, data *b
 	return boCreateUserData, nil
 }
 
-func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords []*bo.Student, cond *bo.SyncStudentCond) {
+func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords []*bo.Student, cond *bo.SyncStudentCond, snapshotAt time.Time, tracker *bo.SyncJobTracker) {
+	pendingRecords, err := srv.recordLockCommonSrv.GetPendingRecords(ctx, webhook.SourceStudent, snapshotAt)
+	if err != nil {
+		srv.logger.Error(ctx, "studentService syncStudentsOrUsers recordLockCommonSrv.GetPendingRecords", err)
+		tracker.Abort(syncjob.TypeStudent, err)
+		return
+	}
+
+	tracker.AddTotal(len(allRecords))
 	wg := &sync.WaitGroup{}
 	currentStudentRefIdMap := map[int]struct{}{}
 	for _, student := range allRecords {
@@ -470,15 +582,28 @@ func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords [
 		srv.executorPool.Submit(func() {
 			defer func() {
 				if r := recover(); r != nil {
-					srv.logger.Error(ctx, "studentService syncStudentsOrUsers syncStudentOrUser panic", nil,
+					srv.logger.Error(ctx, "studentService syncStudentsOrUsers SyncStudent panic", nil,
 						zap.Any(logger.PanicMessage, r),
 						zap.Any("student", *s),
 					)
+					tracker.Track(syncjob.TypeStudent, s.StudentRefId, syncjob.ActionSkipped, xerrors.Errorf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			srv.syncStudentOrUser(ctx, s)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, s.StudentRefId, func() (err error) {
+				if tracker.DryRun() {
+					action, err = srv.diffStudent(ctx, s, tracker)
+					return err
+				}
+				action, err = srv.syncStudent(ctx, s)
+				return err
+			})
+			if err != nil {
+				srv.logger.Error(ctx, "studentService syncStudentsOrUsers SyncStudent", err, zap.Int("record_ref_id", s.StudentRefId))
+			}
+			tracker.Track(syncjob.TypeStudent, s.StudentRefId, action, err)
 		})
 
 		currentStudentRefIdMap[s.StudentRefId] = struct{}{}
@@ -486,6 +611,13 @@ func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords [
 
 	wg.Wait()
 
+	currentStudentRefIdMap, err = getKintoneRecordIds(ctx, srv.kintoneRecordRepo, kintone.AppStudentInfo, cond != nil && cond.UpdatedSince != nil, currentStudentRefIdMap)
+	if err != nil {
+		srv.logger.Error(ctx, "studentService syncStudentsOrUsers getKintoneRecordIds", err)
+		tracker.Abort(syncjob.TypeStudent, err)
+		return
+	}
+
 	db := srv.DB.Session()
 
 	isDeleted := false
@@ -504,17 +636,34 @@ func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords [
 	studentRefIdsInDb, err := srv.studentRepo.GetStudentRefIds(ctx, db, poStudentCond)
 	if err != nil {
 		srv.logger.Error(ctx, "studentService syncStudentsOrUsers GetStudentRefIds", err)
+		tracker.Abort(syncjob.TypeStudent, err)
 		return
 	}
 
 	for _, studentRefIdInDb := range studentRefIdsInDb {
 		if _, found := currentStudentRefIdMap[studentRefIdInDb]; !found {
-			if err := srv.DeleteStudent(ctx, studentRefIdInDb); err != nil {
+			tracker.AddTotal(1)
+			action := syncjob.ActionSkipped
+			err := srv.recordLockCommonSrv.SyncRecord(ctx, pendingRecords, studentRefIdInDb, func() error {
+				action = syncjob.ActionDeleted
+				if tracker.DryRun() {
+					tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeStudent, RecordRefId: studentRefIdInDb})
+					return nil
+				}
+				return srv.DeleteStudent(ctx, studentRefIdInDb)
+			})
+			if err != nil {
 				srv.logger.Error(ctx, "studentService syncStudentsOrUsers DeleteStudent", err, zap.Int("record_ref_id", studentRefIdInDb))
 			}
+			tracker.Track(syncjob.TypeStudent, studentRefIdInDb, action, err)
 		}
 	}
 
+	// dry run 不會刪除學生，停用使用者與撤銷登入也一併略過
+	if tracker.DryRun() {
+		return
+	}
+
 	updatedUsers, err := srv.userCommonSrv.DeactivateUserWithNoActiveStudent(ctx)
 	if err != nil {
 		srv.logger.Error(ctx, "studentService syncStudentsOrUsers userRepo.DeactivateUserWithNoActiveStudent", err)
@@ -529,40 +678,40 @@ func (srv *StudentService) syncStudentsOrUsers(ctx context.Context, allRecords [
 	}
 }
 
-func (srv *StudentService) syncStudentOrUser(ctx context.Context, student *bo.Student) {
+// SyncStudent 以 kintone 上的學生資料新增或更新 db 的學生與使用者
+func (srv *StudentService) SyncStudent(ctx context.Context, student *bo.Student) error {
+	_, err := srv.syncStudent(ctx, student)
+	return err
+}
+
+func (srv *StudentService) syncStudent(ctx context.Context, student *bo.Student) (syncjob.Action, error) {
 	boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentRefId: student.StudentRefId})
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		srv.logger.Error(ctx, "studentService syncStudentOrUser studentCommonSrv.GetStudent", err, zap.Int("record_ref_id", student.StudentRefId))
-		return
+		return "", xerrors.Errorf("studentService SyncStudent studentCommonSrv.GetStudent: %w", err)
 	}
 
 	boUser, err := srv.userCommonSrv.GetUser(ctx, &bo.UserCond{Accounts: []string{student.ParentPhone}})
 	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
-		srv.logger.Error(ctx, "studentService syncStudentOrUser userCommonSrv.GetUser", err)
-		return
+		return "", xerrors.Errorf("studentService SyncStudent userCommonSrv.GetUser: %w", err)
 	}
 
 	if boStudent == nil && boUser == nil {
 		err := srv.UserRegisterAndCreateStudent(ctx, student)
 		if err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser UserRegisterAndCreateStudent", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent UserRegisterAndCreateStudent: %w", err)
 		}
 	} else if boUser == nil {
 		createUserData, err := srv.genDefaultCreateUserData(ctx, student)
 		if err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser genDefaultCreateUserData", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent genDefaultCreateUserData: %w", err)
 		}
 		_, err = srv.userCommonSrv.CreateUser(ctx, createUserData)
 		if err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser userCommonSrv.CreateUser", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent userCommonSrv.CreateUser: %w", err)
 		}
 
 		if err := srv.UpdateStudent(ctx, student); err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser boUser == nil UpdateStudent", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent boUser == nil UpdateStudent: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -571,19 +720,16 @@ func (srv *StudentService) syncStudentOrUser(ctx context.Context, student *bo.St
 				db.Where("student_id = ?", boStudent.StudentId)
 				return db
 			}); err != nil {
-				srv.logger.Error(ctx, "studentService syncStudentOrUser boUser == nil ResetFromDeleted", err)
-				return
+				return "", xerrors.Errorf("studentService SyncStudent boUser == nil ResetFromDeleted: %w", err)
 			}
 		}
 	} else if boStudent == nil {
 		if err := srv.addStudent(ctx, boUser.UserId, student); err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser addStudent", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent addStudent: %w", err)
 		}
 	} else {
 		if err := srv.UpdateStudent(ctx, student); err != nil {
-			srv.logger.Error(ctx, "studentService syncStudentOrUser UpdateStudent", err)
-			return
+			return "", xerrors.Errorf("studentService SyncStudent UpdateStudent: %w", err)
 		}
 		//如果在 kintone 上刪除了照理說不會再拿到相同 id 的資料，這裡是為了避免程式邏輯錯誤導致誤刪除了不該刪的記錄
 		//所以 API 如果取得到這筆確實存在的記錄但是 DB 卻標示為刪除時，還是重置該記錄的刪除狀態
@@ -592,9 +738,46 @@ func (srv *StudentService) syncStudentOrUser(ctx context.Context, student *bo.St
 				db.Where("student_id = ?", boStudent.StudentId)
 				return db
 			}); err != nil {
-				srv.logger.Error(ctx, "studentService syncStudentOrUser ResetFromDeleted", err)
-				return
+				return "", xerrors.Errorf("studentService SyncStudent ResetFromDeleted: %w", err)
 			}
 		}
 	}
+
+	if boStudent == nil {
+		return syncjob.ActionCreated, nil
+	}
+	return syncjob.ActionUpdated, nil
+}
+
+// diffStudent dry run 時計算 syncStudent 會套用的變更，不寫入 db 也不修改 kintone 上的學生名稱
+// 家長帳號還不存在時 user_id 的新值為 nil，表示會建立新的帳號
+func (srv *StudentService) diffStudent(ctx context.Context, student *bo.Student, tracker *bo.SyncJobTracker) (syncjob.Action, error) {
+	boStudent, err := srv.studentCommonSrv.GetStudent(ctx, &bo.StudentCond{StudentRefId: student.StudentRefId})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("studentService diffStudent studentCommonSrv.GetStudent: %w", err)
+	}
+
+	boUser, err := srv.userCommonSrv.GetUser(ctx, &bo.UserCond{Accounts: []string{student.ParentPhone}})
+	if err != nil && !errors.Is(err, errs.DbErr.NoRow) {
+		return "", xerrors.Errorf("studentService diffStudent userCommonSrv.GetUser: %w", err)
+	}
+
+	differ := newSyncFieldDiffer(boStudent == nil)
+	record := &bo.Student{}
+	if boStudent != nil {
+		record = boStudent
+	}
+	var userId any
+	if boUser != nil {
+		userId = boUser.UserId
+	}
+	differ.compare("user_id", record.UserId, userId)
+	differ.compare("student_name", record.StudentName, student.StudentName)
+	differ.compare("parent_name", record.ParentName, student.ParentName)
+	differ.compare("parent_phone", record.ParentPhone, student.ParentPhone)
+	differ.compare("mode", record.Mode.ToKey(), student.Mode.ToKey())
+	differ.compare("is_settle_normally", record.IsSettleNormally, student.IsSettleNormally)
+
+	diffRecord := &bo.SyncDiffRecord{Type: syncjob.TypeStudent, RecordRefId: student.StudentRefId, Changes: differ.changes}
+	return trackSyncDiff(tracker, diffRecord, boStudent != nil, record.IsDeleted || record.DeletedAt != nil), nil
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/repository"
+	"jaystar/internal/service/common"
+	"jaystar/internal/utils/errs"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"net/http"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKintoneRenameStudentService(t *testing.T) (*StudentService, *kintoneFake.Server) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	cfg := server.ConfigEnv()
+	log := logger.ProviderILogger(cfg)
+	kc := kintoneAPI.ProvideKintoneClient(cfg, log)
+
+	return &StudentService{
+		logger:             log,
+		pointCardCommonSrv: &PointCardService{kintonePointCardRepo: repository.ProvideKintonePointCardRepository(cfg, kc), logger: log},
+		depositCommonSrv:   common.ProvideDepositRecordCommonService(repository.ProvideKintoneDepositRecordRepository(cfg, kc)),
+		reduceCommonSrv:    common.ProvideReduceRecordCommonService(repository.ProvideKintoneReduceRecordRepository(cfg, kc)),
+		scheduleCommonSrv:  common.ProvideScheduleCommonService(repository.ProvideKintoneScheduleRepository(cfg, kc)),
+		kintoneBulkRepo:    repository.ProvideKintoneBulkRepository(cfg, kc),
+	}, server
+}
+
+// countStudentName 點數管理、購課記錄、點名管理的學生名稱與課表點名子表格中符合 name 的數量
+func countStudentName(server *kintoneFake.Server, name string) int {
+	count := 0
+	for _, appId := range []string{kintoneFake.AppId.PointCard, kintoneFake.AppId.DepositRecord, kintoneFake.AppId.ReduceRecord} {
+		for _, rec := range server.Records(appId) {
+			if rec["studentName"].Value == name {
+				count++
+			}
+		}
+	}
+	for _, rec := range server.Records(kintoneFake.AppId.ScheduleRecord) {
+		rows, _ := rec["attendance"].Value.([]any)
+		for _, row := range rows {
+			value := row.(map[string]any)["value"].(map[string]any)
+			if value["studentName"].(map[string]any)["value"] == name {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func countBulkRequests(server *kintoneFake.Server) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Path == kintone.BulkRequestPath {
+			count++
+		}
+	}
+	return count
+}
+
+// addReduceRecords 複製既有的點名記錄，讓改名的更新超過一個 bulkRequest
+func addReduceRecords(t *testing.T, server *kintoneFake.Server, n int) {
+	base, ok := server.GetRecord(kintoneFake.AppId.ReduceRecord, 23441)
+	require.True(t, ok)
+	delete(base, "$id")
+	for i := 0; i < n; i++ {
+		server.AddRecord(kintoneFake.AppId.ReduceRecord, base)
+	}
+}
+
+func TestRenameKintoneStudent(t *testing.T) {
+	const newName = kintoneFake.StudentARenamed
+	// 點數卡 1 + 課表點名 3 + 購課 2 + 點名 3
+	const fixtureCount = 9
+	// 點名記錄 18 個請求，加上點數卡、購課、課表共 21 個請求
+	const extraReduceRecords = 17*kintone.BatchUpdateRecordsMaxLimit + 50
+
+	t.Run("single bulk request", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+
+		_, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		assert.Equal(t, fixtureCount, countStudentName(server, newName))
+		assert.Zero(t, countStudentName(server, kintoneFake.StudentA))
+		assert.Equal(t, 1, countBulkRequests(server))
+	})
+
+	t.Run("rollback restores renamed records", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+
+		rollback, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		require.NoError(t, rollback(context.TODO()))
+		assert.Zero(t, countStudentName(server, newName))
+		assert.Equal(t, fixtureCount, countStudentName(server, kintoneFake.StudentA))
+	})
+
+	t.Run("retry on revision conflict", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+		server.InjectFault(kintoneFake.Fault{Method: http.MethodPost, Path: kintone.BulkRequestPath, StatusCode: http.StatusConflict, Code: kintoneAPI.CodeRevisionConflict})
+
+		_, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		assert.Equal(t, fixtureCount, countStudentName(server, newName))
+		assert.Equal(t, 2, countBulkRequests(server))
+	})
+
+	t.Run("multiple bulk requests", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+		addReduceRecords(t, server, extraReduceRecords)
+
+		_, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		require.NoError(t, err)
+		assert.Equal(t, fixtureCount+extraReduceRecords, countStudentName(server, newName))
+		assert.Equal(t, 2, countBulkRequests(server))
+	})
+
+	t.Run("rollback applied batches when later batch fails", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+		addReduceRecords(t, server, extraReduceRecords)
+		server.InjectFault(kintoneFake.Fault{Method: http.MethodPost, Path: kintone.BulkRequestPath, StatusCode: http.StatusBadRequest, Code: kintoneFake.CodeValidation, Skip: 1})
+
+		_, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, errs.KintoneErr.PartiallyAppliedError), err)
+		assert.Zero(t, countStudentName(server, newName))
+		assert.Equal(t, fixtureCount+extraReduceRecords, countStudentName(server, kintoneFake.StudentA))
+		assert.Equal(t, 3, countBulkRequests(server))
+	})
+
+	t.Run("partially applied when rollback fails", func(t *testing.T) {
+		srv, server := newKintoneRenameStudentService(t)
+		addReduceRecords(t, server, extraReduceRecords)
+		server.InjectFault(kintoneFake.Fault{Method: http.MethodPost, Path: kintone.BulkRequestPath, StatusCode: http.StatusConflict, Code: kintoneAPI.CodeRevisionConflict, Skip: 1, Times: 2})
+
+		_, err := srv.renameKintoneStudent(context.TODO(), kintoneFake.StudentA, newName)
+		assert.True(t, errors.Is(err, errs.KintoneErr.PartiallyAppliedError), err)
+		assert.False(t, errors.Is(err, errs.KintoneErr.RevisionConflictError), "partially applied rename must not be retried")
+		assert.Contains(t, err.Error(), "point_card [1439]")
+		assert.Equal(t, 3, countBulkRequests(server))
+		assert.NotZero(t, countStudentName(server, newName))
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils"
+	"jaystar/internal/utils/points"
+	"reflect"
+	"time"
+)
+
+// syncFieldDiffer dry run 時比較 db 與 kintone 的欄位，只留下有差異的欄位
+// 新增的記錄沒有舊的值，Old 一律為 nil
+type syncFieldDiffer struct {
+	created bool
+	changes []*bo.SyncFieldChange
+}
+
+func newSyncFieldDiffer(created bool) *syncFieldDiffer {
+	return &syncFieldDiffer{created: created}
+}
+
+func (d *syncFieldDiffer) compare(field string, oldValue, newValue any) {
+	oldValue, newValue = syncDiffValue(oldValue), syncDiffValue(newValue)
+	if d.created {
+		oldValue = nil
+	} else if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	d.changes = append(d.changes, &bo.SyncFieldChange{Field: field, Old: oldValue, New: newValue})
+}
+
+// syncDiffValue 指標取出實際的值，時間轉成同一個時區的字串，避免時區或精度不同被當成差異
+func syncDiffValue(value any) any {
+	switch v := value.(type) {
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return syncDiffValue(*v)
+	case time.Time:
+		return v.In(utils.GetLocation()).Format(time.RFC3339)
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *points.Points:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *bool:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+		return v
+	}
+
+	return value
+}
+
+// trackSyncDiff 依 db 記錄的狀態記錄 dry run 的變更，回傳實際同步時的 Action
+func trackSyncDiff(tracker *bo.SyncJobTracker, record *bo.SyncDiffRecord, exists bool, deleted bool) syncjob.Action {
+	switch {
+	case !exists:
+		tracker.Diff(syncjob.DiffCreate, record)
+		return syncjob.ActionCreated
+	case deleted:
+		tracker.Diff(syncjob.DiffRestore, record)
+		return syncjob.ActionUpdated
+	case len(record.Changes) > 0:
+		tracker.Diff(syncjob.DiffUpdate, record)
+		return syncjob.ActionUpdated
+	}
+
+	return syncjob.ActionUnchanged
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/utils/points"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncFieldDiffer(t *testing.T) {
+	classTime := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)
+	reducePoints := points.FromFloat(3.5)
+
+	differ := newSyncFieldDiffer(false)
+	// 同一個時間點在不同時區不算差異
+	differ.compare("class_time", &classTime, classTime.In(time.FixedZone("UTC+8", 8*60*60)))
+	differ.compare("charging_method", []string(nil), []string{})
+	differ.compare("reduce_points", &reducePoints, points.FromFloat(3.5))
+	differ.compare("teacher_name", "Amy", "Ben")
+	differ.compare("charging_date", (*time.Time)(nil), classTime)
+	assert.Equal(t, []*bo.SyncFieldChange{
+		{Field: "teacher_name", Old: "Amy", New: "Ben"},
+		{Field: "charging_date", Old: nil, New: syncDiffValue(classTime)},
+	}, differ.changes)
+
+	// 新增的記錄列出所有欄位，沒有舊的值
+	differ = newSyncFieldDiffer(true)
+	differ.compare("student_id", int64(0), int64(1))
+	differ.compare("is_attended", false, false)
+	assert.Equal(t, []*bo.SyncFieldChange{
+		{Field: "student_id", Old: nil, New: int64(1)},
+		{Field: "is_attended", Old: nil, New: false},
+	}, differ.changes)
+}
+
+func TestTrackSyncDiff(t *testing.T) {
+	changes := []*bo.SyncFieldChange{{Field: "rest_points", Old: 1.0, New: 2.0}}
+	tests := []struct {
+		name       string
+		changes    []*bo.SyncFieldChange
+		exists     bool
+		deleted    bool
+		wantAction syncjob.Action
+		wantDiff   func(diff *bo.SyncDiff) []*bo.SyncDiffRecord
+	}{
+		{name: "create", changes: changes, wantAction: syncjob.ActionCreated, wantDiff: func(diff *bo.SyncDiff) []*bo.SyncDiffRecord { return diff.Creates }},
+		{name: "update", changes: changes, exists: true, wantAction: syncjob.ActionUpdated, wantDiff: func(diff *bo.SyncDiff) []*bo.SyncDiffRecord { return diff.Updates }},
+		{name: "restore without changes", exists: true, deleted: true, wantAction: syncjob.ActionUpdated, wantDiff: func(diff *bo.SyncDiff) []*bo.SyncDiffRecord { return diff.Restores }},
+		{name: "unchanged", exists: true, wantAction: syncjob.ActionUnchanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := bo.NewDryRunSyncJobTracker()
+			record := &bo.SyncDiffRecord{Type: syncjob.TypePointCard, RecordRefId: 1, Changes: tt.changes}
+
+			assert.Equal(t, tt.wantAction, trackSyncDiff(tracker, record, tt.exists, tt.deleted))
+			diff := tracker.Report().Diff
+			if tt.wantDiff == nil {
+				assert.Equal(t, &bo.SyncDiff{}, diff)
+				return
+			}
+			assert.Equal(t, []*bo.SyncDiffRecord{record}, tt.wantDiff(diff))
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/errs"
+	"time"
+)
+
+func ProvideSyncJobService(db database.IPostgresDB, syncJobRepo interfaces.ISyncJobRepo, auditLogSrv interfaces.IAuditLogSrv, logger pkgLogger.ILogger) *SyncJobService {
+	return &SyncJobService{
+		DB:          db,
+		syncJobRepo: syncJobRepo,
+		auditLogSrv: auditLogSrv,
+		logger:      logger,
+		running:     make(chan struct{}, syncjob.MaxRunningJobs),
+	}
+}
+
+type SyncJobService struct {
+	DB          database.IPostgresDB
+	syncJobRepo interfaces.ISyncJobRepo
+	auditLogSrv interfaces.IAuditLogSrv
+	logger      pkgLogger.ILogger
+	running     chan struct{} `wire:"-"`
+}
+
+// StartJob 建立同步工作並在背景執行 run，run 回傳的錯誤與 tracker 的結果會寫回同步工作
+// dryRun 時 tracker 只記錄會套用的變更，run 不應寫入 db
+func (srv *SyncJobService) StartJob(ctx context.Context, jobType syncjob.Type, cond any, dryRun bool, run func(ctx context.Context, tracker *bo.SyncJobTracker) error) (*bo.SyncJob, error) {
+	jobId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("syncJobService StartJob autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+
+	condJson := []byte("{}")
+	if cond != nil {
+		if condJson, err = json.Marshal(cond); err != nil {
+			return nil, xerrors.Errorf("syncJobService StartJob json.Marshal: %w", err)
+		}
+	}
+
+	poJob := &po.SyncJob{
+		JobId:       jobId,
+		JobType:     jobType,
+		DryRun:      dryRun,
+		Cond:        string(condJson),
+		Status:      syncjob.StatusQueued,
+		Failures:    "[]",
+		HeartbeatAt: time.Now(),
+	}
+	if err := srv.syncJobRepo.AddJob(ctx, srv.DB.Session(), poJob); err != nil {
+		return nil, xerrors.Errorf("syncJobService StartJob syncJobRepo.AddJob: %w", err)
+	}
+	boJob := toSyncJobBo(poJob)
+	auditData := &bo.AddAuditLogData{Action: audit.ActionSyncStart, TargetType: audit.TargetSyncJob, TargetId: jobId, After: boJob}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, auditData); err != nil {
+		srv.logger.Error(ctx, "syncJobService StartJob auditLogSrv.AddAuditLog", err, zap.Int64("job_id", jobId))
+	}
+
+	go srv.runJob(newSyncJobCtx(ctx), poJob.JobId, jobType, dryRun, run)
+
+	return boJob, nil
+}
+
+// HasActiveJob 是否有同類型的同步工作在排隊或執行中
+// 服務重啟後不會再執行的同步沒有 heartbeat，會先標示為 failed
+func (srv *SyncJobService) HasActiveJob(ctx context.Context, jobType syncjob.Type) (bool, error) {
+	heartbeatAfter := time.Now().Add(-syncjob.HeartbeatTimeout)
+	if err := srv.failStaleJobs(ctx, jobType, heartbeatAfter); err != nil {
+		return false, xerrors.Errorf("syncJobService HasActiveJob failStaleJobs: %w", err)
+	}
+
+	cond := &po.SyncJobCond{
+		JobType:        jobType,
+		Statuses:       []syncjob.Status{syncjob.StatusQueued, syncjob.StatusRunning},
+		HeartbeatAfter: &heartbeatAfter,
+	}
+	if _, err := srv.syncJobRepo.GetJob(ctx, srv.DB.Session(), cond); err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return false, nil
+		}
+		return false, xerrors.Errorf("syncJobService HasActiveJob syncJobRepo.GetJob: %w", err)
+	}
+
+	return true, nil
+}
+
+func (srv *SyncJobService) failStaleJobs(ctx context.Context, jobType syncjob.Type, heartbeatBefore time.Time) error {
+	cond := &po.UpdateSyncJobCond{
+		JobType:         jobType,
+		Statuses:        []syncjob.Status{syncjob.StatusQueued, syncjob.StatusRunning},
+		HeartbeatBefore: &heartbeatBefore,
+	}
+	status := syncjob.StatusFailed
+	errMsg := syncjob.InterruptedError
+	finishedAt := time.Now()
+	data := &po.UpdateSyncJobData{Status: &status, Error: &errMsg, FinishedAt: &finishedAt}
+	count, err := srv.syncJobRepo.UpdateJobs(ctx, srv.DB.Session(), cond, data)
+	if err != nil {
+		return xerrors.Errorf("syncJobRepo.UpdateJobs: %w", err)
+	}
+	if count > 0 {
+		srv.logger.Warn(ctx, "syncJobService failStaleJobs", zap.String("job_type", string(jobType)), zap.Int64("count", count))
+	}
+
+	return nil
+}
+
+func (srv *SyncJobService) runJob(ctx context.Context, jobId int64, jobType syncjob.Type, dryRun bool, run func(ctx context.Context, tracker *bo.SyncJobTracker) error) {
+	tracker := bo.NewSyncJobTracker()
+	if dryRun {
+		tracker = bo.NewDryRunSyncJobTracker()
+	}
+	var runErr error
+	defer func() {
+		if r := recover(); r != nil {
+			srv.logger.Error(ctx, "syncJobService runJob panic", nil, zap.Any(pkgLogger.PanicMessage, r), zap.Int64("job_id", jobId))
+			runErr = xerrors.Errorf("panic: %v", r)
+		}
+		srv.finishJob(ctx, jobId, tracker.Report(), runErr)
+	}()
+
+	// 排隊中也要寫入 heartbeat，避免被當成已中斷的同步
+	done := make(chan struct{})
+	defer close(done)
+	go srv.flushProgress(ctx, jobId, tracker, done)
+
+	srv.running <- struct{}{}
+	defer func() { <-srv.running }()
+
+	startedAt := time.Now()
+	status := syncjob.StatusRunning
+	if err := srv.syncJobRepo.UpdateJob(ctx, srv.DB.Session(), &po.UpdateSyncJobCond{JobId: jobId}, &po.UpdateSyncJobData{Status: &status, StartedAt: &startedAt, HeartbeatAt: &startedAt}); err != nil {
+		srv.logger.Error(ctx, "syncJobService runJob syncJobRepo.UpdateJob", err, zap.Int64("job_id", jobId))
+	}
+
+	if runErr = run(ctx, tracker); runErr != nil {
+		srv.logger.Error(ctx, "syncJobService runJob", runErr, zap.Int64("job_id", jobId), zap.String("job_type", string(jobType)))
+	}
+}
+
+// flushProgress 同步結束前定期寫入目前的進度與 heartbeat
+func (srv *SyncJobService) flushProgress(ctx context.Context, jobId int64, tracker *bo.SyncJobTracker, done <-chan struct{}) {
+	ticker := time.NewTicker(syncjob.ProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			data := syncJobReportData(tracker.Report())
+			data.HeartbeatAt = &now
+			if err := srv.syncJobRepo.UpdateJob(ctx, srv.DB.Session(), &po.UpdateSyncJobCond{JobId: jobId}, data); err != nil {
+				srv.logger.Error(ctx, "syncJobService flushProgress syncJobRepo.UpdateJob", err, zap.Int64("job_id", jobId))
+			}
+		}
+	}
+}
+
+func (srv *SyncJobService) finishJob(ctx context.Context, jobId int64, report bo.SyncJobReport, runErr error) {
+	if runErr != nil {
+		report.Error = joinSyncJobError(runErr.Error(), report.Error)
+	}
+
+	finishedAt := time.Now()
+	status := syncJobStatus(report)
+	data := syncJobReportData(report)
+	data.Status = &status
+	data.Error = &report.Error
+	data.FinishedAt = &finishedAt
+	if err := srv.syncJobRepo.UpdateJob(ctx, srv.DB.Session(), &po.UpdateSyncJobCond{JobId: jobId}, data); err != nil {
+		srv.logger.Error(ctx, "syncJobService finishJob syncJobRepo.UpdateJob", err, zap.Int64("job_id", jobId), zap.String("status", string(status)))
+	}
+}
+
+func (srv *SyncJobService) GetJob(ctx context.Context, jobId int64) (*bo.SyncJob, error) {
+	poJob, err := srv.syncJobRepo.GetJob(ctx, srv.DB.Session(), &po.SyncJobCond{JobId: jobId})
+	if err != nil {
+		if errors.Is(err, errs.DbErr.NoRow) {
+			return nil, xerrors.Errorf("syncJobService GetJob syncJobRepo.GetJob: %w", errs.SyncJobErr.JobNotFoundError)
+		}
+		return nil, xerrors.Errorf("syncJobService GetJob syncJobRepo.GetJob: %w", err)
+	}
+
+	return toSyncJobBo(poJob), nil
+}
+
+func (srv *SyncJobService) GetJobs(ctx context.Context, cond *bo.SyncJobCond) ([]*bo.SyncJob, *po.PagerResult, error) {
+	poCond := &po.SyncJobCond{
+		JobType: cond.JobType,
+		Status:  cond.Status,
+	}
+	poPager := &po.Pager{
+		Index: cond.Index,
+		Size:  cond.Size,
+		Order: cond.Order,
+	}
+
+	db := srv.DB.Session()
+	poJobs, err := srv.syncJobRepo.GetJobs(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("syncJobService GetJobs syncJobRepo.GetJobs: %w", err)
+	}
+	poPagerResult, err := srv.syncJobRepo.GetJobsPager(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("syncJobService GetJobs syncJobRepo.GetJobsPager: %w", err)
+	}
+
+	boJobs := make([]*bo.SyncJob, 0, len(poJobs))
+	for _, poJob := range poJobs {
+		boJobs = append(boJobs, toSyncJobBo(poJob))
+	}
+
+	return boJobs, poPagerResult, nil
+}
+
+// syncJobStatus 無法完成同步時為 failed，有記錄同步失敗時為 partial
+func syncJobStatus(report bo.SyncJobReport) syncjob.Status {
+	if report.Error != "" {
+		return syncjob.StatusFailed
+	}
+	if report.Failed > 0 {
+		return syncjob.StatusPartial
+	}
+
+	return syncjob.StatusSucceeded
+}
+
+func syncJobReportData(report bo.SyncJobReport) *po.UpdateSyncJobData {
+	failures := "[]"
+	if len(report.Failures) > 0 {
+		if b, err := json.Marshal(report.Failures); err == nil {
+			failures = string(b)
+		}
+	}
+
+	data := &po.UpdateSyncJobData{
+		TotalCount:     &report.Total,
+		CreatedCount:   &report.Created,
+		UpdatedCount:   &report.Updated,
+		DeletedCount:   &report.Deleted,
+		SkippedCount:   &report.Skipped,
+		FailedCount:    &report.Failed,
+		UnchangedCount: &report.Unchanged,
+		Failures:       &failures,
+	}
+	if report.Diff != nil {
+		if b, err := json.Marshal(report.Diff); err == nil {
+			diff := string(b)
+			data.Diff = &diff
+		}
+	}
+	if report.Reconciliation != nil {
+		if b, err := json.Marshal(report.Reconciliation); err == nil {
+			reconciliation := string(b)
+			data.Reconciliation = &reconciliation
+		}
+	}
+
+	return data
+}
+
+func joinSyncJobError(errMsgs ...string) string {
+	joined := ""
+	for _, msg := range errMsgs {
+		if msg == "" {
+			continue
+		}
+		if joined != "" {
+			joined += "; "
+		}
+		joined += msg
+	}
+
+	return joined
+}
+
+func toSyncJobBo(poJob *po.SyncJob) *bo.SyncJob {
+	failures := make([]*bo.SyncJobFailure, 0)
+	_ = json.Unmarshal([]byte(poJob.Failures), &failures)
+	var diff *bo.SyncDiff
+	if poJob.Diff != nil {
+		diff = &bo.SyncDiff{}
+		_ = json.Unmarshal([]byte(*poJob.Diff), diff)
+	}
+	var reconciliation *bo.ReconciliationReport
+	if poJob.Reconciliation != nil {
+		reconciliation = &bo.ReconciliationReport{}
+		_ = json.Unmarshal([]byte(*poJob.Reconciliation), reconciliation)
+	}
+
+	return &bo.SyncJob{
+		JobId:   poJob.JobId,
+		JobType: poJob.JobType,
+		DryRun:  poJob.DryRun,
+		Cond:    poJob.Cond,
+		Status:  poJob.Status,
+		Report: bo.SyncJobReport{
+			Total:          poJob.TotalCount,
+			Created:        poJob.CreatedCount,
+			Updated:        poJob.UpdatedCount,
+			Deleted:        poJob.DeletedCount,
+			Skipped:        poJob.SkippedCount,
+			Failed:         poJob.FailedCount,
+			Unchanged:      poJob.UnchangedCount,
+			Failures:       failures,
+			Error:          poJob.Error,
+			Diff:           diff,
+			Reconciliation: reconciliation,
+		},
+		StartedAt:  poJob.StartedAt,
+		FinishedAt: poJob.FinishedAt,
+		CreatedAt:  poJob.CreatedAt,
+		UpdatedAt:  poJob.UpdatedAt,
+	}
+}
+
+// newSyncJobCtx 同步會在請求結束後繼續執行，不能沿用請求的 ctx (gin.Context 請求結束後會被重複使用)
+// 只帶入請求的 action id 與操作人，同步寫入的稽核記錄仍記錄觸發的人
+func newSyncJobCtx(ctx context.Context) context.Context {
+	actionId, _ := ctx.Value(pkgLogger.CtxActionIdKey).(string)
+	if actionId == "" {
+		actionId = uuid.NewString()
+	}
+	jobCtx := context.WithValue(context.Background(), pkgLogger.CtxActionIdKey, actionId)
+	if actor := ctxUtil.GetOperatorFromCtx(ctx); actor != "" {
+		jobCtx = ctxUtil.WithActor(jobCtx, actor)
+	}
+
+	return jobCtx
+}
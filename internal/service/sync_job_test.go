@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	contextKey "jaystar/internal/constant/context"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/reconciliation"
+	"jaystar/internal/constant/syncjob"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/auth"
+	"jaystar/internal/utils/ctxUtil"
+	"jaystar/internal/utils/points"
+	"sync"
+	"testing"
+	"time"
+
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncJobTracker(t *testing.T) {
+	tracker := bo.NewSyncJobTracker()
+	tracker.AddTotal(4)
+
+	wg := sync.WaitGroup{}
+	for i, action := range []syncjob.Action{syncjob.ActionCreated, syncjob.ActionUpdated, syncjob.ActionSkipped} {
+		wg.Add(1)
+		go func(recordRefId int, action syncjob.Action) {
+			defer wg.Done()
+			tracker.Track(syncjob.TypeDepositRecord, recordRefId, action, nil)
+		}(i+1, action)
+	}
+	wg.Wait()
+	tracker.Track(syncjob.TypeDepositRecord, 4, syncjob.ActionSkipped, errors.New("student not found"))
+
+	report := tracker.Report()
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, []*bo.SyncJobFailure{{Type: syncjob.TypeDepositRecord, RecordRefId: 4, Error: "student not found"}}, report.Failures)
+	assert.Empty(t, report.Error)
+
+	// BatchSync 不需要追蹤時傳入 nil
+	var nilTracker *bo.SyncJobTracker
+	nilTracker.AddTotal(1)
+	nilTracker.Track(syncjob.TypeStudent, 1, syncjob.ActionCreated, nil)
+	nilTracker.Abort(syncjob.TypeStudent, errors.New("kintone unavailable"))
+	assert.Equal(t, bo.SyncJobReport{}, nilTracker.Report())
+}
+
+func TestSyncJobTrackerMaxFailures(t *testing.T) {
+	tracker := bo.NewSyncJobTracker()
+	for i := 0; i < syncjob.MaxFailures+10; i++ {
+		tracker.Track(syncjob.TypeSchedule, i, syncjob.ActionSkipped, errors.New("failed"))
+	}
+
+	report := tracker.Report()
+	assert.Equal(t, syncjob.MaxFailures+10, report.Failed)
+	assert.Len(t, report.Failures, syncjob.MaxFailures)
+}
+
+func TestSyncJobTrackerDryRun(t *testing.T) {
+	tracker := bo.NewSyncJobTracker()
+	assert.False(t, tracker.DryRun())
+	tracker.Diff(syncjob.DiffCreate, &bo.SyncDiffRecord{Type: syncjob.TypeStudent, RecordRefId: 1})
+	assert.Nil(t, tracker.Report().Diff)
+
+	tracker = bo.NewDryRunSyncJobTracker()
+	assert.True(t, tracker.DryRun())
+	for i := 0; i < syncjob.MaxDiffRecords+10; i++ {
+		tracker.Diff(syncjob.DiffDelete, &bo.SyncDiffRecord{Type: syncjob.TypeSchedule, RecordRefId: i})
+		tracker.Track(syncjob.TypeSchedule, i, syncjob.ActionDeleted, nil)
+	}
+	tracker.Track(syncjob.TypeSchedule, 0, syncjob.ActionUnchanged, nil)
+
+	report := tracker.Report()
+	assert.Equal(t, syncjob.MaxDiffRecords+10, report.Deleted)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Len(t, report.Diff.Deletes, syncjob.MaxDiffRecords)
+	assert.True(t, report.Diff.Truncated)
+
+	var nilTracker *bo.SyncJobTracker
+	assert.False(t, nilTracker.DryRun())
+	nilTracker.Diff(syncjob.DiffCreate, &bo.SyncDiffRecord{})
+}
+
+func TestSyncJobReconciliation(t *testing.T) {
+	report := &bo.ReconciliationReport{
+		CheckedAt: time.Date(2026, 10, 18, 3, 30, 0, 0, time.UTC),
+		Apps:      []*bo.ReconciliationApp{{App: kintone.AppDepositRecord, KintoneCount: 2, DbCount: 1, MissingInDb: []int{2}, MissingInKintone: []int{}}},
+		Students: []*bo.ReconciliationStudent{{
+			StudentId:   1,
+			StudentName: "Amy",
+			ParentPhone: "0900000001",
+			Mismatches:  []*bo.ReconciliationMismatch{{Field: reconciliation.FieldDepositedPoints, Kintone: points.FromInt(20), Db: points.FromInt(10)}},
+		}},
+	}
+	tracker := bo.NewSyncJobTracker()
+	tracker.SetReconciliation(report)
+
+	// 對帳結果以 json 保存在同步工作，讀取後與原本的結果相同
+	data := syncJobReportData(tracker.Report())
+	job := toSyncJobBo(&po.SyncJob{Failures: *data.Failures, Reconciliation: data.Reconciliation})
+	assert.Equal(t, report, job.Report.Reconciliation)
+
+	job = toSyncJobBo(&po.SyncJob{Failures: "[]"})
+	assert.Nil(t, job.Report.Reconciliation)
+}
+
+func TestSyncJobStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		report bo.SyncJobReport
+		want   syncjob.Status
+	}{
+		{name: "all records synced", report: bo.SyncJobReport{Total: 2, Created: 1, Updated: 1}, want: syncjob.StatusSucceeded},
+		{name: "nothing to sync", report: bo.SyncJobReport{}, want: syncjob.StatusSucceeded},
+		{name: "some records failed", report: bo.SyncJobReport{Total: 2, Created: 1, Failed: 1}, want: syncjob.StatusPartial},
+		{name: "aborted", report: bo.SyncJobReport{Total: 2, Created: 2, Error: "reduce_record: connection refused"}, want: syncjob.StatusFailed},
+		{name: "aborted with failed records", report: bo.SyncJobReport{Failed: 1, Error: "panic"}, want: syncjob.StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, syncJobStatus(tt.report))
+		})
+	}
+}
+
+func TestJoinSyncJobError(t *testing.T) {
+	assert.Equal(t, "", joinSyncJobError("", ""))
+	assert.Equal(t, "kintone unavailable", joinSyncJobError("kintone unavailable", ""))
+	assert.Equal(t, "kintone unavailable; student: panic", joinSyncJobError("kintone unavailable", "student: panic"))
+}
+
+func TestNewSyncJobCtx(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	reqCtx = context.WithValue(reqCtx, pkgLogger.CtxActionIdKey, "action-1")
+	reqCtx = context.WithValue(reqCtx, contextKey.UserSession, auth.UserSession{Account: "admin"})
+
+	jobCtx := newSyncJobCtx(reqCtx)
+	// 請求結束後同步仍繼續執行
+	cancel()
+	assert.NoError(t, jobCtx.Err())
+	assert.Equal(t, "action-1", jobCtx.Value(pkgLogger.CtxActionIdKey))
+	assert.Equal(t, "admin", ctxUtil.GetOperatorFromCtx(jobCtx))
+
+	// 排程觸發的同步沒有操作人，稽核記錄為系統
+	jobCtx = newSyncJobCtx(context.Background())
+	assert.NotEmpty(t, jobCtx.Value(pkgLogger.CtxActionIdKey))
+	assert.Empty(t, ctxUtil.GetOperatorFromCtx(jobCtx))
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/interfaces"
+)
+
+// getKintoneRecordIds 回傳 kintone 上還存在的記錄 $id，db 中不在其中的記錄會標示為刪除
+// 增量同步只取得更新過的記錄，改以 kintone 上全部的 $id 判斷，其他同步直接使用 syncedIds
+// 需在更新過的記錄同步完之後才取得 $id，否則期間新增的記錄會在同步後馬上被標示為刪除
+func getKintoneRecordIds(ctx context.Context, kintoneRecordRepo interfaces.IKintoneRecordRepo, app kintone.App, incremental bool, syncedIds map[int]struct{}) (map[int]struct{}, error) {
+	if !incremental {
+		return syncedIds, nil
+	}
+
+	ids, err := kintoneRecordRepo.GetAllRecordIds(ctx, app)
+	if err != nil {
+		return nil, xerrors.Errorf("kintoneRecordRepo.GetAllRecordIds: %w", err)
+	}
+
+	return ids, nil
+}
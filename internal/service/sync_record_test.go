@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/repository"
+	"jaystar/internal/utils/kintoneAPI"
+	"jaystar/internal/utils/kintoneFake"
+	"testing"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKintoneRecordIds(t *testing.T) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	cfg := server.ConfigEnv()
+	repo := repository.ProvideKintoneRecordRepository(cfg, kintoneAPI.ProvideKintoneClient(cfg, logger.ProviderILogger(cfg)))
+	syncedIds := map[int]struct{}{1730: {}}
+
+	t.Run("full sync", func(t *testing.T) {
+		ids, err := getKintoneRecordIds(context.TODO(), repo, kintone.AppDepositRecord, false, syncedIds)
+		require.NoError(t, err)
+		assert.Equal(t, syncedIds, ids)
+	})
+
+	t.Run("incremental sync", func(t *testing.T) {
+		// 取得更新過的記錄之後才新增的記錄不會被當成已刪除
+		base, ok := server.GetRecord(kintoneFake.AppId.DepositRecord, 1730)
+		require.True(t, ok)
+		delete(base, "$id")
+		id := server.AddRecord(kintoneFake.AppId.DepositRecord, base)
+
+		ids, err := getKintoneRecordIds(context.TODO(), repo, kintone.AppDepositRecord, true, syncedIds)
+		require.NoError(t, err)
+		assert.Equal(t, map[int]struct{}{1730: {}, 1801: {}, 1929: {}, id: {}}, ids)
+	})
+}
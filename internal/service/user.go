@@ -6,12 +6,15 @@ import (
 	"github.com/SeanZhenggg/go-utils/logger"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/loginsession"
 	"jaystar/internal/constant/user"
 	"jaystar/internal/database"
 	"jaystar/internal/interfaces"
 	"jaystar/internal/model/bo"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/model/po"
+	"jaystar/internal/utils/ctxUtil"
 	"jaystar/internal/utils/errs"
 )
 
@@ -20,6 +23,8 @@ func ProvideUserService(
 	userRepo interfaces.IUserRepo,
 	userCommonSrv interfaces.IUserCommonSrv,
 	studentCommonSrv interfaces.IStudentCommonSrv,
+	auditLogSrv interfaces.IAuditLogSrv,
+	loginSessionSrv interfaces.ILoginSessionSrv,
 	logger logger.ILogger,
 ) *UserService {
 	return &UserService{
@@ -27,6 +32,8 @@ func ProvideUserService(
 		DB:               db,
 		userCommonSrv:    userCommonSrv,
 		studentCommonSrv: studentCommonSrv,
+		auditLogSrv:      auditLogSrv,
+		loginSessionSrv:  loginSessionSrv,
 		logger:           logger,
 	}
 }
@@ -36,6 +43,8 @@ type UserService struct {
 	DB               database.IPostgresDB
 	userCommonSrv    interfaces.IUserCommonSrv
 	studentCommonSrv interfaces.IStudentCommonSrv
+	auditLogSrv      interfaces.IAuditLogSrv
+	loginSessionSrv  interfaces.ILoginSessionSrv
 	logger           logger.ILogger
 }
 
@@ -44,6 +53,7 @@ func (srv *UserService) GetUsers(ctx context.Context, cond *bo.UserCond) ([]*bo.
 		UserId:            cond.UserId,
 		Accounts:          cond.Accounts,
 		Status:            cond.Status.ToKey(),
+		Level:             cond.Level.ToKey(),
 		IsChangedPassword: cond.IsChangedPassword,
 	}
 
@@ -71,6 +81,7 @@ func (srv *UserService) GetUsers(ctx context.Context, cond *bo.UserCond) ([]*bo.
 			Password:          poUser.Password,
 			Status:            user.UserStatusToEnum(poUser.Status),
 			Level:             user.UserLevelToEnum(poUser.Level),
+			Role:              user.AdminRoleToEnum(poUser.Role),
 			IsChangedPassword: poUser.IsChangedPassword,
 			CreatedAt:         poUser.CreatedAt,
 			UpdatedAt:         poUser.UpdatedAt,
@@ -108,6 +119,7 @@ func (srv *UserService) UserLogin(ctx *gin.Context, cond *bo.UserLoginCond) (*bo
 		Password:          poUser.Password,
 		Status:            userStatus,
 		Level:             user.UserLevelToEnum(poUser.Level),
+		Role:              user.AdminRoleToEnum(poUser.Role),
 		IsChangedPassword: poUser.IsChangedPassword,
 		CreatedAt:         poUser.CreatedAt,
 		UpdatedAt:         poUser.UpdatedAt,
@@ -116,6 +128,21 @@ func (srv *UserService) UserLogin(ctx *gin.Context, cond *bo.UserLoginCond) (*bo
 	return boUser, nil
 }
 
+// AdminLogin 只允許有角色的管理者帳號登入
+func (srv *UserService) AdminLogin(ctx *gin.Context, cond *bo.UserLoginCond) (*bo.User, error) {
+	boUser, err := srv.UserLogin(ctx, cond)
+	if err != nil {
+		return nil, xerrors.Errorf("userService AdminLogin UserLogin: %w", err)
+	}
+
+	// 不透露帳號是否存在
+	if boUser.Level != user.Admin || boUser.Role == user.RoleNone {
+		return nil, xerrors.Errorf("userService AdminLogin level: %s: %w", boUser.Level.ToKey(), errs.UserErr.AccOrPwdVerificationFailedErr)
+	}
+
+	return boUser, nil
+}
+
 func (srv *UserService) UserRegister(ctx context.Context, cond *bo.StudentCond, data *bo.CreateUserData) error {
 	boStudentReq := &dto.StudentReq{
 		StudentName: cond.StudentName,
@@ -133,6 +160,32 @@ func (srv *UserService) UserRegister(ctx context.Context, cond *bo.StudentCond,
 	return srv.userCommonSrv.CreateUserAndStudent(ctx, data, students[0])
 }
 
+func (srv *UserService) CreateAdminUser(ctx context.Context, data *bo.CreateUserData) (*bo.User, error) {
+	if data.Account == "" || data.Password == "" {
+		return nil, xerrors.Errorf("userService CreateAdminUser: %w", errs.UserErr.AccountOrPasswordInvalidErr)
+	}
+	if data.Role == user.RoleNone {
+		return nil, xerrors.Errorf("userService CreateAdminUser: %w", errs.UserErr.AdminRoleInvalidErr)
+	}
+	data.Level = user.Admin
+
+	userId, err := srv.userCommonSrv.CreateUser(ctx, data)
+	if err != nil {
+		if errors.Is(err, errs.DbErr.UniqueViolation) {
+			return nil, xerrors.Errorf("userService CreateAdminUser userCommonSrv.CreateUser: %w", errs.UserErr.AccountDuplicatedErr)
+		}
+		return nil, xerrors.Errorf("userService CreateAdminUser userCommonSrv.CreateUser: %w", err)
+	}
+
+	created, err := srv.GetUser(ctx, &bo.UserCond{UserId: userId})
+	if err != nil {
+		return nil, xerrors.Errorf("userService CreateAdminUser GetUser: %w", err)
+	}
+	srv.addAuditLog(ctx, audit.ActionAdminUserCreate, userId, nil, userAuditSnapshot(created, false))
+
+	return created, nil
+}
+
 func (srv *UserService) UpdateUser(ctx context.Context, cond *bo.UserCond, data *bo.UpdateUserData) error {
 	boUser, err := srv.userCommonSrv.GetUser(ctx, cond)
 	if err != nil {
@@ -165,14 +218,62 @@ func (srv *UserService) UpdateUser(ctx context.Context, cond *bo.UserCond, data
 		poUpdateUserData.Status = data.Status.ToKey()
 	}
 
+	if data.Role != user.RoleNone {
+		if boUser.Level != user.Admin {
+			return xerrors.Errorf("userService UpdateUser level: %s: %w", boUser.Level.ToKey(), errs.UserErr.AdminRoleInvalidErr)
+		}
+		poUpdateUserData.Role = data.Role.ToKey()
+	}
+
 	db := srv.DB.Session()
 	if err := srv.userRepo.UpdateUser(ctx, db, &po.UserCond{UserId: boUser.UserId}, poUpdateUserData); err != nil {
 		return xerrors.Errorf("userService UpdateUser userRepo.UpdateUser: %w", err)
 	}
 
+	if revokeData := userUpdateRevokeData(ctx, boUser.UserId, data); revokeData != nil {
+		if err := srv.loginSessionSrv.RevokeUserLoginSessions(ctx, revokeData); err != nil {
+			return xerrors.Errorf("userService UpdateUser loginSessionSrv.RevokeUserLoginSessions: %w", err)
+		}
+	}
+
+	updated, err := srv.userCommonSrv.GetUser(ctx, &bo.UserCond{UserId: boUser.UserId})
+	if err != nil {
+		srv.logger.Error(ctx, "userService UpdateUser userCommonSrv.GetUser", err)
+		return nil
+	}
+	srv.addAuditLog(ctx, audit.ActionUserUpdate, boUser.UserId, userAuditSnapshot(boUser, false), userAuditSnapshot(updated, data.Password != nil))
+
+	return nil
+}
+
+func (srv *UserService) addAuditLog(ctx context.Context, action audit.Action, userId int64, before, after *bo.User) {
+	data := &bo.AddAuditLogData{Action: action, TargetType: audit.TargetUser, TargetId: userId, Before: before, After: after}
+	if err := srv.auditLogSrv.AddAuditLog(ctx, data); err != nil {
+		srv.logger.Error(ctx, "userService addAuditLog auditLogSrv.AddAuditLog", err)
+	}
+}
+
+// userUpdateRevokeData 停用帳號時撤銷所有登入，修改密碼時保留自己目前的登入
+func userUpdateRevokeData(ctx context.Context, userId int64, data *bo.UpdateUserData) *bo.RevokeLoginSessionsData {
+	if data.Status == user.Deactivate {
+		return &bo.RevokeLoginSessionsData{UserIds: []int64{userId}, Reason: loginsession.ReasonDeactivated}
+	}
+	if data.Password != nil {
+		return &bo.RevokeLoginSessionsData{UserIds: []int64{userId}, Reason: loginsession.ReasonPasswordChanged, ExceptToken: ctxUtil.GetSessionTokenFromCtx(ctx)}
+	}
 	return nil
 }
 
+// userAuditSnapshot 稽核記錄不保存密碼 hash，密碼有變更時只標示已變更
+func userAuditSnapshot(boUser *bo.User, passwordChanged bool) *bo.User {
+	snapshot := *boUser
+	snapshot.Password = ""
+	if passwordChanged {
+		snapshot.Password = audit.RedactedValue
+	}
+	return &snapshot
+}
+
 func (srv *UserService) GetEncryptedPassword(ctx context.Context, data dto.PasswordIO) (string, error) {
 	pwd, err := srv.userCommonSrv.GetEncryptedPwd(*data.Password)
 	if err != nil {
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	pkgLogger "github.com/SeanZhenggg/go-utils/logger"
+	"github.com/SeanZhenggg/go-utils/snowflake/autoId"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/audit"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/database"
+	"jaystar/internal/interfaces"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"time"
+)
+
+func ProvideWebhookEventService(db database.IPostgresDB, webhookEventRepo interfaces.IWebhookEventRepo, auditLogSrv interfaces.IAuditLogSrv, logger pkgLogger.ILogger) *WebhookEventService {
+	return &WebhookEventService{
+		DB:               db,
+		webhookEventRepo: webhookEventRepo,
+		auditLogSrv:      auditLogSrv,
+		logger:           logger,
+	}
+}
+
+type WebhookEventService struct {
+	DB               database.IPostgresDB
+	webhookEventRepo interfaces.IWebhookEventRepo
+	auditLogSrv      interfaces.IAuditLogSrv
+	logger           pkgLogger.ILogger
+}
+
+// AddEvent 先將 webhook 原始資料存起來，之後由 job 處理
+func (srv *WebhookEventService) AddEvent(ctx context.Context, source webhook.Source, payload []byte) (*bo.WebhookEvent, error) {
+	req := dto.KintoneWebhookEventIO{}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, xerrors.Errorf("webhookEventService AddEvent json.Unmarshal: %v: %w", err, errs.WebhookErr.InvalidPayloadError)
+	}
+
+	eventId, err := autoId.DefaultSnowFlake.GenNextId()
+	if err != nil {
+		return nil, xerrors.Errorf("webhookEventService AddEvent autoId.DefaultSnowFlake.GenNextId: %w", err)
+	}
+
+	recordId := req.RecordId
+	if recordId == "" {
+		recordId = req.Record.Id.Value
+	}
+	// 刪除的 webhook 沒有 record，revision 為 0
+	recordRevision, _ := req.Record.Revision.ToId()
+
+	poEvent := &po.WebhookEvent{
+		EventId:        eventId,
+		Source:         source,
+		WebhookId:      req.Id,
+		WebhookType:    string(req.Type),
+		AppId:          req.App.Id,
+		RecordId:       recordId,
+		RecordRevision: recordRevision,
+		Payload:        string(payload),
+		Status:         webhook.StatusPending,
+		NextAttemptAt:  time.Now(),
+	}
+	if err := srv.webhookEventRepo.AddEvent(ctx, srv.DB.Session(), poEvent); err != nil {
+		// kintone 重送的 webhook id 相同
+		if errors.Is(err, errs.DbErr.UniqueViolation) {
+			return nil, xerrors.Errorf("webhookEventService AddEvent webhook_id: %s: %w", req.Id, errs.WebhookErr.DuplicateEventError)
+		}
+		return nil, xerrors.Errorf("webhookEventService AddEvent webhookEventRepo.AddEvent: %w", err)
+	}
+
+	return toWebhookEventBo(poEvent), nil
+}
+
+func (srv *WebhookEventService) ClaimEvents(ctx context.Context, limit int) ([]*bo.WebhookEvent, error) {
+	now := time.Now()
+	poEvents, err := srv.webhookEventRepo.ClaimEvents(ctx, srv.DB.Session(), &po.ClaimWebhookEventsCond{
+		Now:         now,
+		StaleBefore: now.Add(-webhook.ProcessingTimeout),
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("webhookEventService ClaimEvents webhookEventRepo.ClaimEvents: %w", err)
+	}
+
+	boEvents := make([]*bo.WebhookEvent, 0, len(poEvents))
+	for _, poEvent := range poEvents {
+		boEvents = append(boEvents, toWebhookEventBo(poEvent))
+	}
+
+	return boEvents, nil
+}
+
+func (srv *WebhookEventService) CompleteEvent(ctx context.Context, event *bo.WebhookEvent) error {
+	now := time.Now()
+	status := webhook.StatusSucceeded
+	lastError := ""
+	_, err := srv.webhookEventRepo.UpdateEvents(ctx, srv.DB.Session(),
+		&po.UpdateWebhookEventCond{EventIds: []int64{event.EventId}, Status: webhook.StatusProcessing},
+		&po.UpdateWebhookEventData{Status: &status, LastError: &lastError, ProcessedAt: &now},
+	)
+	if err != nil {
+		return xerrors.Errorf("webhookEventService CompleteEvent webhookEventRepo.UpdateEvents: %w", err)
+	}
+
+	return nil
+}
+
+// IsStaleEvent 同一筆記錄已處理過刪除，或已處理過 revision 較新的事件時，這個事件不需要再處理
+// e.g. kintone 先送到 UPDATE 才送到 ADD 時，ADD 的資料比 db 現有的舊
+func (srv *WebhookEventService) IsStaleEvent(ctx context.Context, event *bo.WebhookEvent) (bool, error) {
+	if event.RecordId == "" {
+		return false, nil
+	}
+
+	poEvents, err := srv.webhookEventRepo.GetEvents(ctx, srv.DB.Session(), &po.WebhookEventCond{
+		Source:   event.Source,
+		RecordId: event.RecordId,
+		Status:   webhook.StatusSucceeded,
+	}, nil)
+	if err != nil {
+		return false, xerrors.Errorf("webhookEventService IsStaleEvent webhookEventRepo.GetEvents: %w", err)
+	}
+
+	return isStaleWebhookEvent(event, poEvents), nil
+}
+
+// isStaleWebhookEvent kintone 刪除的記錄 id 不會再被使用，處理過刪除後同一筆記錄的事件都是舊的
+func isStaleWebhookEvent(event *bo.WebhookEvent, succeeded []*po.WebhookEvent) bool {
+	for _, poEvent := range succeeded {
+		if poEvent.EventId == event.EventId {
+			continue
+		}
+		if poEvent.WebhookType == string(kintone.DeleteRecordType) {
+			return true
+		}
+		if event.RecordRevision > 0 && poEvent.RecordRevision > event.RecordRevision {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (srv *WebhookEventService) SkipEvent(ctx context.Context, event *bo.WebhookEvent) error {
+	now := time.Now()
+	status := webhook.StatusSkipped
+	_, err := srv.webhookEventRepo.UpdateEvents(ctx, srv.DB.Session(),
+		&po.UpdateWebhookEventCond{EventIds: []int64{event.EventId}, Status: webhook.StatusProcessing},
+		&po.UpdateWebhookEventData{Status: &status, ProcessedAt: &now},
+	)
+	if err != nil {
+		return xerrors.Errorf("webhookEventService SkipEvent webhookEventRepo.UpdateEvents: %w", err)
+	}
+
+	return nil
+}
+
+// FailEvent 依重試次數延後下次處理時間，超過次數或資料本身有問題時改為 dead
+func (srv *WebhookEventService) FailEvent(ctx context.Context, event *bo.WebhookEvent, processErr error) error {
+	data := failedWebhookEventData(event.Attempts, processErr, time.Now())
+	_, err := srv.webhookEventRepo.UpdateEvents(ctx, srv.DB.Session(),
+		&po.UpdateWebhookEventCond{EventIds: []int64{event.EventId}, Status: webhook.StatusProcessing},
+		data,
+	)
+	if err != nil {
+		return xerrors.Errorf("webhookEventService FailEvent webhookEventRepo.UpdateEvents: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *WebhookEventService) GetEvents(ctx context.Context, cond *bo.WebhookEventCond) ([]*bo.WebhookEvent, *po.PagerResult, error) {
+	poCond := &po.WebhookEventCond{
+		Source: cond.Source,
+		Status: cond.Status,
+	}
+	poPager := &po.Pager{
+		Index: cond.Index,
+		Size:  cond.Size,
+		Order: cond.Order,
+	}
+
+	db := srv.DB.Session()
+	poEvents, err := srv.webhookEventRepo.GetEvents(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("webhookEventService GetEvents webhookEventRepo.GetEvents: %w", err)
+	}
+	poPagerResult, err := srv.webhookEventRepo.GetEventsPager(ctx, db, poCond, poPager)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("webhookEventService GetEvents webhookEventRepo.GetEventsPager: %w", err)
+	}
+
+	boEvents := make([]*bo.WebhookEvent, 0, len(poEvents))
+	for _, poEvent := range poEvents {
+		boEvents = append(boEvents, toWebhookEventBo(poEvent))
+	}
+
+	return boEvents, poPagerResult, nil
+}
+
+// ReplayEvents 將 dead 的事件重設為待處理，重試次數從頭計算
+func (srv *WebhookEventService) ReplayEvents(ctx context.Context, eventIds []int64) error {
+	db := srv.DB.Session()
+	poEvents, err := srv.webhookEventRepo.GetEvents(ctx, db, &po.WebhookEventCond{EventIds: eventIds}, nil)
+	if err != nil {
+		return xerrors.Errorf("webhookEventService ReplayEvents webhookEventRepo.GetEvents: %w", err)
+	}
+	if len(poEvents) != len(eventIds) {
+		return xerrors.Errorf("webhookEventService ReplayEvents found %d of %d events: %w", len(poEvents), len(eventIds), errs.WebhookErr.EventNotFoundError)
+	}
+	for _, poEvent := range poEvents {
+		if poEvent.Status != webhook.StatusDead {
+			return xerrors.Errorf("webhookEventService ReplayEvents event %d status %s: %w", poEvent.EventId, poEvent.Status, errs.WebhookErr.EventNotReplayableError)
+		}
+	}
+
+	status := webhook.StatusPending
+	attempts := 0
+	now := time.Now()
+	replayData := &po.UpdateWebhookEventData{Status: &status, Attempts: &attempts, NextAttemptAt: &now}
+	affected, err := srv.webhookEventRepo.UpdateEvents(ctx, db,
+		&po.UpdateWebhookEventCond{EventIds: eventIds, Status: webhook.StatusDead},
+		replayData,
+	)
+	if err != nil {
+		return xerrors.Errorf("webhookEventService ReplayEvents webhookEventRepo.UpdateEvents: %w", err)
+	}
+	if int(affected) != len(eventIds) {
+		srv.logger.Warn(ctx, "webhookEventService ReplayEvents some events changed status before replay")
+	}
+
+	// 只記錄重設的欄位，payload 已保存在 webhook_events
+	for _, poEvent := range poEvents {
+		auditData := &bo.AddAuditLogData{
+			Action:     audit.ActionWebhookReplay,
+			TargetType: audit.TargetWebhookEvent,
+			TargetId:   poEvent.EventId,
+			Before:     &po.UpdateWebhookEventData{Status: &poEvent.Status, Attempts: &poEvent.Attempts, LastError: &poEvent.LastError},
+			After:      replayData,
+		}
+		if err := srv.auditLogSrv.AddAuditLog(ctx, auditData); err != nil {
+			srv.logger.Error(ctx, "webhookEventService ReplayEvents auditLogSrv.AddAuditLog", err)
+		}
+	}
+
+	return nil
+}
+
+func failedWebhookEventData(attempts int, processErr error, now time.Time) *po.UpdateWebhookEventData {
+	status := webhook.StatusPending
+	if attempts >= webhook.MaxAttempts || errors.Is(processErr, errs.WebhookErr.InvalidPayloadError) {
+		status = webhook.StatusDead
+	}
+	nextAttemptAt := now.Add(webhookRetryDelay(attempts))
+	lastError := processErr.Error()
+
+	return &po.UpdateWebhookEventData{
+		Status:        &status,
+		NextAttemptAt: &nextAttemptAt,
+		LastError:     &lastError,
+	}
+}
+
+// webhookRetryDelay 第 n 次失敗後等待 RetryBaseDelay * 2^(n-1)，最多 RetryMaxDelay
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhook.RetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhook.RetryMaxDelay {
+			return webhook.RetryMaxDelay
+		}
+	}
+
+	return delay
+}
+
+func toWebhookEventBo(poEvent *po.WebhookEvent) *bo.WebhookEvent {
+	return &bo.WebhookEvent{
+		EventId:        poEvent.EventId,
+		Source:         poEvent.Source,
+		WebhookId:      poEvent.WebhookId,
+		WebhookType:    poEvent.WebhookType,
+		AppId:          poEvent.AppId,
+		RecordId:       poEvent.RecordId,
+		RecordRevision: poEvent.RecordRevision,
+		Payload:        poEvent.Payload,
+		Status:         poEvent.Status,
+		Attempts:       poEvent.Attempts,
+		NextAttemptAt:  poEvent.NextAttemptAt,
+		LastError:      poEvent.LastError,
+		ProcessedAt:    poEvent.ProcessedAt,
+		CreatedAt:      poEvent.CreatedAt,
+		UpdatedAt:      poEvent.UpdatedAt,
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/constant/webhook"
+	"jaystar/internal/model/bo"
+	"jaystar/internal/model/po"
+	"jaystar/internal/utils/errs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: webhook.RetryBaseDelay},
+		{attempts: 2, want: 2 * webhook.RetryBaseDelay},
+		{attempts: 4, want: 8 * webhook.RetryBaseDelay},
+		{attempts: 7, want: webhook.RetryMaxDelay},
+		{attempts: 100, want: webhook.RetryMaxDelay},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookRetryDelay(tt.attempts), "attempts: %d", tt.attempts)
+	}
+}
+
+func TestFailedWebhookEventData(t *testing.T) {
+	now := time.Date(2026, 10, 18, 4, 0, 0, 0, time.UTC)
+	dbErr := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name       string
+		attempts   int
+		err        error
+		wantStatus webhook.Status
+		wantNext   time.Time
+	}{
+		{
+			name:       "retry after first failure",
+			attempts:   1,
+			err:        dbErr,
+			wantStatus: webhook.StatusPending,
+			wantNext:   now.Add(webhook.RetryBaseDelay),
+		},
+		{
+			name:       "back off on later failures",
+			attempts:   3,
+			err:        dbErr,
+			wantStatus: webhook.StatusPending,
+			wantNext:   now.Add(4 * webhook.RetryBaseDelay),
+		},
+		{
+			name:       "dead after max attempts",
+			attempts:   webhook.MaxAttempts,
+			err:        dbErr,
+			wantStatus: webhook.StatusDead,
+			wantNext:   now.Add(webhook.RetryMaxDelay),
+		},
+		{
+			name:       "dead on invalid payload",
+			attempts:   1,
+			err:        xerrors.Errorf("studentCtrl addStudent: %w", errs.WebhookErr.InvalidPayloadError),
+			wantStatus: webhook.StatusDead,
+			wantNext:   now.Add(webhook.RetryBaseDelay),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := failedWebhookEventData(tt.attempts, tt.err, now)
+			assert.Equal(t, tt.wantStatus, *data.Status)
+			assert.Equal(t, tt.wantNext, *data.NextAttemptAt)
+			assert.Equal(t, tt.err.Error(), *data.LastError)
+		})
+	}
+}
+
+func TestIsStaleWebhookEvent(t *testing.T) {
+	update := func(eventId int64, revision int) *po.WebhookEvent {
+		return &po.WebhookEvent{EventId: eventId, WebhookType: string(kintone.UpdateRecordType), RecordRevision: revision}
+	}
+	deleted := &po.WebhookEvent{EventId: 9, WebhookType: string(kintone.DeleteRecordType)}
+
+	tests := []struct {
+		name      string
+		event     *bo.WebhookEvent
+		succeeded []*po.WebhookEvent
+		want      bool
+	}{
+		{
+			name:  "first event of record",
+			event: &bo.WebhookEvent{EventId: 1, RecordRevision: 1},
+			want:  false,
+		},
+		{
+			name:      "newer revision than processed events",
+			event:     &bo.WebhookEvent{EventId: 3, RecordRevision: 3},
+			succeeded: []*po.WebhookEvent{update(1, 1), update(2, 2)},
+			want:      false,
+		},
+		{
+			name:      "add delivered after update",
+			event:     &bo.WebhookEvent{EventId: 2, RecordRevision: 1},
+			succeeded: []*po.WebhookEvent{update(1, 2)},
+			want:      true,
+		},
+		{
+			name:      "record already deleted",
+			event:     &bo.WebhookEvent{EventId: 10, RecordRevision: 5},
+			succeeded: []*po.WebhookEvent{update(1, 4), deleted},
+			want:      true,
+		},
+		{
+			name:      "replayed event ignores itself",
+			event:     &bo.WebhookEvent{EventId: 1, RecordRevision: 2},
+			succeeded: []*po.WebhookEvent{update(1, 2)},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isStaleWebhookEvent(tt.event, tt.succeeded))
+		})
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"encoding/gob"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
 	"jaystar/internal/constant/user"
+	"net/http"
 	"time"
 )
 
 type UserSession struct {
-	UserId int64
-	Level  user.UserLevel // unused
+	UserId  int64
+	Level   user.UserLevel
+	Account string
+	Role    user.AdminRole // 只有管理者有角色
 }
 
 func RegSessionValueTypes() {
@@ -32,6 +36,29 @@ func GetUserSession(ctx *gin.Context, key string) *UserSession {
 	return nil
 }
 
+// sessionRegenerator 可以在登入時重新發行 token 的 session store
+type sessionRegenerator interface {
+	Regenerate(r *http.Request, session *gsessions.Session) error
+}
+
+// RegenerateSession 登入前呼叫，不沿用 cookie 帶來的 session
+func RegenerateSession(ctx *gin.Context) error {
+	s, ok := sessions.Default(ctx).(interface{ Session() *gsessions.Session })
+	if !ok {
+		return nil
+	}
+	session := s.Session()
+	if session == nil {
+		return nil
+	}
+	store, ok := session.Store().(sessionRegenerator)
+	if !ok {
+		return nil
+	}
+
+	return store.Regenerate(ctx.Request, session)
+}
+
 func RenewSession(ctx *gin.Context, env string) error {
 	session := sessions.Default(ctx)
 
@@ -3,6 +3,7 @@ package ctxUtil
 import (
 	"context"
 	contextKey "jaystar/internal/constant/context"
+	"jaystar/internal/model/bo"
 	"jaystar/internal/utils/auth"
 )
 
@@ -10,6 +11,32 @@ func GetUserSessionFromCtx(ctx context.Context) auth.UserSession {
 	return GetGenericValueFromCtx[auth.UserSession](ctx, contextKey.UserSession)
 }
 
+// GetApiKeyFromCtx 以 API key 呼叫時才有值
+func GetApiKeyFromCtx(ctx context.Context) *bo.ApiKey {
+	return GetGenericValueFromCtx[*bo.ApiKey](ctx, contextKey.ApiKey)
+}
+
+// GetSessionTokenFromCtx 以登入 session 呼叫時才有值
+func GetSessionTokenFromCtx(ctx context.Context) string {
+	return GetGenericValueFromCtx[string](ctx, contextKey.SessionToken)
+}
+
+// WithActor 不是由請求觸發的操作 (e.g. 處理 webhook) 以此指定操作人
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, contextKey.Actor, actor)
+}
+
+// GetOperatorFromCtx 內部 API 的操作人，管理者為帳號，API key 為 api_key:<名稱>
+func GetOperatorFromCtx(ctx context.Context) string {
+	if actor := GetGenericValueFromCtx[string](ctx, contextKey.Actor); actor != "" {
+		return actor
+	}
+	if apiKey := GetApiKeyFromCtx(ctx); apiKey != nil {
+		return "api_key:" + apiKey.Name
+	}
+	return GetUserSessionFromCtx(ctx).Account
+}
+
 func GetGenericValueFromCtx[T any](ctx context.Context, key string) T {
 	var defVal T
 	if ctx == nil {
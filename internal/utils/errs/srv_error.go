@@ -6,6 +6,12 @@ const (
 	ReduceRecordGroupCode
 	ScheduleGroupCode
 	KintoneGroupCode
+	WebhookGroupCode
+	SyncJobGroupCode
+	SemesterGroupCode
+	SettlementGroupCode
+	ApiKeyGroupCode
+	LoginSessionGroupCode
 )
 
 func ProvideUserSrvError() *userSrvError {
@@ -19,6 +25,7 @@ func ProvideUserSrvError() *userSrvError {
 		UserNotFoundErr:               group.GenError(5, "找不到對應的使用者"),
 		UserIdInvalidErr:              group.GenError(6, "無效的使用者ID"),
 		AccountDuplicatedErr:          group.GenError(7, "使用者帳號重複"),
+		AdminRoleInvalidErr:           group.GenError(8, "無效的管理者角色"),
 	}
 }
 
@@ -30,6 +37,7 @@ type userSrvError struct {
 	UpdatePasswordEmptyError      error
 	UserNotFoundErr               error
 	AccountDuplicatedErr          error
+	AdminRoleInvalidErr           error
 }
 
 func ProvideStudentSrvError() *studentSrvError {
@@ -86,10 +94,132 @@ func ProvideKintoneError() *kintoneError {
 	group := Define.GenErrorGroup(KintoneGroupCode)
 
 	return &kintoneError{
-		ResponseEmptyError: group.GenError(1, "查詢不到 kintone 資料"),
+		ResponseEmptyError:    group.GenError(1, "查詢不到 kintone 資料"),
+		RevisionConflictError: group.GenError(2, "kintone 資料已被其他人修改，請重新讀取後再試"),
+		BulkRequestLimitError: group.GenError(3, "kintone bulkRequest 超過單次請求上限"),
+		PartiallyAppliedError: group.GenError(4, "kintone 資料只有部分更新且無法還原，需人工確認"),
 	}
 }
 
 type kintoneError struct {
-	ResponseEmptyError error
+	ResponseEmptyError    error
+	RevisionConflictError error
+	BulkRequestLimitError error
+	PartiallyAppliedError error
+}
+
+func ProvideWebhookError() *webhookError {
+	group := Define.GenErrorGroup(WebhookGroupCode)
+
+	return &webhookError{
+		InvalidPayloadError:     group.GenError(1, "無效的 webhook 資料"),
+		EventNotFoundError:      group.GenError(2, "找不到對應的 webhook 事件"),
+		EventNotReplayableError: group.GenError(3, "只有處理失敗的 webhook 事件可以重新處理"),
+		DuplicateEventError:     group.GenError(4, "重複的 webhook 事件"),
+		TokenInvalidError:       group.GenError(5, "無效的 webhook token"),
+		SourceIpDeniedError:     group.GenError(6, "不允許的 webhook 來源 IP"),
+		AppIdMismatchError:      group.GenError(7, "webhook 的應用程式與路徑不符"),
+		PayloadTooLargeError:    group.GenError(8, "webhook 資料過大"),
+	}
+}
+
+type webhookError struct {
+	InvalidPayloadError     error
+	EventNotFoundError      error
+	EventNotReplayableError error
+	DuplicateEventError     error
+	TokenInvalidError       error
+	SourceIpDeniedError     error
+	AppIdMismatchError      error
+	PayloadTooLargeError    error
+}
+
+func ProvideSyncJobError() *syncJobError {
+	group := Define.GenErrorGroup(SyncJobGroupCode)
+
+	return &syncJobError{
+		JobNotFoundError: group.GenError(1, "找不到對應的同步工作"),
+		JobIsActiveError: group.GenError(2, "已有同類型的同步工作在排隊或執行中"),
+	}
+}
+
+type syncJobError struct {
+	JobNotFoundError error
+	JobIsActiveError error
+}
+
+func ProvideSemesterError() *semesterError {
+	group := Define.GenErrorGroup(SemesterGroupCode)
+
+	return &semesterError{
+		SemesterNotFoundError:   group.GenError(1, "找不到對應的學期"),
+		InvalidSemesterError:    group.GenError(2, "無效的學期資料"),
+		TimeZoneInvalidError:    group.GenError(3, "無效的時區"),
+		SemesterConflictError:   group.GenError(4, "學期期間或結算日與其他學期重疊"),
+		SemesterSettledError:    group.GenError(5, "學期已經有結算記錄，無法刪除"),
+		NoUpcomingSemesterError: group.GenError(6, "沒有之後要結算的學期"),
+	}
+}
+
+type semesterError struct {
+	SemesterNotFoundError   error
+	InvalidSemesterError    error
+	TimeZoneInvalidError    error
+	SemesterConflictError   error
+	SemesterSettledError    error
+	NoUpcomingSemesterError error
+}
+
+func ProvideSettlementError() *settlementError {
+	group := Define.GenErrorGroup(SettlementGroupCode)
+
+	return &settlementError{
+		RunNotFoundError:     group.GenError(1, "找不到對應的學期結算"),
+		RunIsRunningError:    group.GenError(2, "學期結算正在執行中"),
+		RunReversedError:     group.GenError(3, "學期結算已撤銷"),
+		ItemNotFoundError:    group.GenError(4, "學期結算中找不到對應的學生"),
+		ItemReversedError:    group.GenError(5, "學生的學期結算已撤銷"),
+		InvalidReversalError: group.GenError(6, "撤銷學期結算必須填寫操作人與原因"),
+	}
+}
+
+type settlementError struct {
+	RunNotFoundError     error
+	RunIsRunningError    error
+	RunReversedError     error
+	ItemNotFoundError    error
+	ItemReversedError    error
+	InvalidReversalError error
+}
+
+func ProvideApiKeyError() *apiKeyError {
+	group := Define.GenErrorGroup(ApiKeyGroupCode)
+
+	return &apiKeyError{
+		ApiKeyNotFoundError: group.GenError(1, "找不到對應的 API key"),
+		ApiKeyInvalidError:  group.GenError(2, "無效、已撤銷或已過期的 API key"),
+		InvalidApiKeyError:  group.GenError(3, "API key 名稱與 scope 必填"),
+		ScopeInvalidError:   group.GenError(4, "無效的 scope"),
+	}
+}
+
+type apiKeyError struct {
+	ApiKeyNotFoundError error
+	ApiKeyInvalidError  error
+	InvalidApiKeyError  error
+	ScopeInvalidError   error
+}
+
+func ProvideLoginSessionError() *loginSessionError {
+	group := Define.GenErrorGroup(LoginSessionGroupCode)
+
+	return &loginSessionError{
+		SessionNotFoundError:  group.GenError(1, "找不到對應的登入"),
+		SessionUserEmptyError: group.GenError(2, "登入資訊不得為空"),
+	}
+}
+
+type loginSessionError struct {
+	SessionNotFoundError  error
+	SessionUserEmptyError error
 }
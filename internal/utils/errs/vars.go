@@ -18,4 +18,10 @@ var (
 	ReduceRecordErr = ProvideReduceRecordError()
 	ScheduleErr     = ProvideScheduleError()
 	KintoneErr      = ProvideKintoneError()
+	WebhookErr      = ProvideWebhookError()
+	SyncJobErr      = ProvideSyncJobError()
+	SemesterErr     = ProvideSemesterError()
+	SettlementErr   = ProvideSettlementError()
+	ApiKeyErr       = ProvideApiKeyError()
+	LoginSessionErr = ProvideLoginSessionError()
 )
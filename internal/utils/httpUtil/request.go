@@ -21,8 +21,7 @@ func ProvideHttpClient(timeout time.Duration) *HttpClient {
 }
 
 func (c *HttpClient) Get(url string, query map[string]string, headers map[string]string) (int, []byte, error) {
-	_url := fmt.Sprintf("%s?%s", url, queryMapEncode(query))
-	return c.Request(http.MethodGet, _url, nil, headers)
+	return c.Request(http.MethodGet, EncodeUrl(url, query), nil, headers)
 }
 
 func (c *HttpClient) Post(url string, body []byte, headers map[string]string) (int, []byte, error) {
@@ -33,7 +32,17 @@ func (c *HttpClient) Put(url string, body []byte, headers map[string]string) (in
 	return c.Request(http.MethodPut, url, body, headers)
 }
 
+func (c *HttpClient) Delete(url string, body []byte, headers map[string]string) (int, []byte, error) {
+	return c.Request(http.MethodDelete, url, body, headers)
+}
+
 func (c *HttpClient) Request(method string, url string, body []byte, headers map[string]string) (int, []byte, error) {
+	code, _, resp, err := c.RequestWithHeader(method, url, body, headers)
+	return code, resp, err
+}
+
+// RequestWithHeader 同 Request，另外回傳 response header (e.g. Retry-After)
+func (c *HttpClient) RequestWithHeader(method string, url string, body []byte, headers map[string]string) (int, http.Header, []byte, error) {
 	c.client = &http.Client{
 		Timeout: c.timeout,
 	}
@@ -46,7 +55,7 @@ func (c *HttpClient) Request(method string, url string, body []byte, headers map
 
 	req, err := http.NewRequest(method, url, bodyReader)
 	if err != nil {
-		return http.StatusInternalServerError, nil, err
+		return http.StatusInternalServerError, nil, nil, err
 	}
 
 	for k, v := range headers {
@@ -55,15 +64,20 @@ func (c *HttpClient) Request(method string, url string, body []byte, headers map
 
 	res, err := c.client.Do(req)
 	if err != nil {
-		return res.StatusCode, nil, err
+		return 0, nil, nil, err
 	}
+	defer res.Body.Close()
 
 	readRes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return res.StatusCode, nil, err
+		return res.StatusCode, res.Header, nil, err
 	}
 
-	return res.StatusCode, readRes, nil
+	return res.StatusCode, res.Header, readRes, nil
+}
+
+func EncodeUrl(url string, query map[string]string) string {
+	return fmt.Sprintf("%s?%s", url, queryMapEncode(query))
 }
 
 func queryMapEncode(qm map[string]string) string {
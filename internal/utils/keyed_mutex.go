@@ -0,0 +1,43 @@
+package utils
+
+import "sync"
+
+// KeyedMutex 依 key 分別上鎖，同一個 key 同時只會有一個持有者，不同 key 互不影響
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock 取得 key 的鎖，回傳的 unlock 只能呼叫一次
+func (m *KeyedMutex) Lock(key string) (unlock func()) {
+	m.mu.Lock()
+	l, found := m.locks[key]
+	if !found {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
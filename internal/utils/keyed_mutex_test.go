@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	t.Run("same key is serialised", func(t *testing.T) {
+		m := NewKeyedMutex()
+		var running, maxRunning int32
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := m.Lock("deposit_record:1730")
+				defer unlock()
+
+				n := atomic.AddInt32(&running, 1)
+				for {
+					cur := atomic.LoadInt32(&maxRunning)
+					if n <= cur || atomic.CompareAndSwapInt32(&maxRunning, cur, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), maxRunning)
+		assert.Empty(t, m.locks, "unused keys should be released")
+	})
+
+	t.Run("different keys do not block", func(t *testing.T) {
+		m := NewKeyedMutex()
+		unlock := m.Lock("deposit_record:1730")
+		defer unlock()
+
+		done := make(chan struct{})
+		go func() {
+			m.Lock("deposit_record:1731")()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("lock on a different key was blocked")
+		}
+	})
+}
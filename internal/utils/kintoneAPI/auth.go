@@ -0,0 +1,69 @@
+package kintoneAPI
+
+import (
+	"jaystar/internal/constant/kintone"
+	"strings"
+)
+
+// Auth 請求的目標應用程式與存取權限，實際使用的認證資訊由 KintoneClient 決定
+type Auth struct {
+	App string
+	// Apps bulkRequest 涉及的所有應用程式
+	Apps  []string
+	Write bool
+}
+
+func ReadAuth(app string) Auth {
+	return Auth{App: app}
+}
+
+func WriteAuth(app string) Auth {
+	return Auth{App: app, Write: true}
+}
+
+func BulkWriteAuth(apps ...string) Auth {
+	return Auth{Apps: apps, Write: true}
+}
+
+// authHeader 應用程式有設定 API token 時優先使用 token，否則使用帳號密碼認證
+func (kc *KintoneClient) authHeader(auth Auth) (string, string) {
+	cfg := kc.cfg.GetKintoneConfig()
+	if len(auth.Apps) > 0 {
+		return kc.bulkAuthHeader(auth)
+	}
+	token := cfg.AppId.GetApiToken(auth.App)
+
+	if auth.Write {
+		if token.Write != "" {
+			return kintone.HeaderApiToken, token.Write
+		}
+		return kintone.HeaderUserAuthorization, cfg.AdminUserAuthorization
+	}
+
+	if token.Read != "" {
+		return kintone.HeaderApiToken, token.Read
+	}
+	return kintone.HeaderUserAuthorization, cfg.CommonUserAuthorization
+}
+
+// bulkAuthHeader 所有應用程式都有設定寫入 token 時以逗號串接，有任一個沒有設定則使用帳號密碼認證
+func (kc *KintoneClient) bulkAuthHeader(auth Auth) (string, string) {
+	cfg := kc.cfg.GetKintoneConfig()
+
+	tokens := make([]string, 0, len(auth.Apps))
+	seen := make(map[string]struct{}, len(auth.Apps))
+	for _, app := range auth.Apps {
+		if _, ok := seen[app]; ok {
+			continue
+		}
+		seen[app] = struct{}{}
+
+		token := cfg.AppId.GetApiToken(app)
+		if token.Write == "" {
+			return kintone.HeaderUserAuthorization, cfg.AdminUserAuthorization
+		}
+		tokens = append(tokens, token.Write)
+	}
+
+	return kintone.HeaderApiToken, strings.Join(tokens, ",")
+}
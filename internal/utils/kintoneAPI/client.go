@@ -3,13 +3,10 @@ package kintoneAPI
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"github.com/SeanZhenggg/go-utils/logger"
 	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 	"jaystar/internal/config"
-	"jaystar/internal/constant/kintone"
 	"jaystar/internal/model/dto"
 	"jaystar/internal/utils/httpUtil"
 	"net/http"
@@ -30,131 +27,102 @@ func ProvideKintoneClient(cfg config.IConfigEnv, logger logger.ILogger) *Kintone
 	}
 }
 
-func (kc *KintoneClient) Get(ctx context.Context, authKey string, path string, query map[string]string, respBody any) (err error) {
-	var (
-		code int
-		resp []byte
-	)
-
-	defer func() {
-		r := recover()
-		if r != nil || err != nil {
-			kc.logger.Info(ctx, "KintoneClient Get api response",
-				zap.Int("http_status_code", code),
-				zap.String("http_response", string(resp)),
-				zap.Error(err),
-			)
-		}
-	}()
-
-	_url := kc.cfg.GetKintoneConfig().Url + path
+// Get 預設視為冪等請求，暫時性錯誤會依 DefaultRetryPolicy 重試
+func (kc *KintoneClient) Get(ctx context.Context, auth Auth, path string, query map[string]string, respBody any, opts ...CallOption) error {
+	authHeader, authValue := kc.authHeader(auth)
 	headers := map[string]string{
-		kintone.HeaderUserAuthorization: authKey,
-	}
-	code, resp, err = kc.HttpClient.Get(_url, query, headers)
-	if err != nil {
-		return xerrors.Errorf("HttpClient.Get error: %w", err)
+		authHeader: authValue,
 	}
 
-	if code >= http.StatusBadRequest {
-		baseResp := &dto.KintoneApiBaseResponse{}
-		if err = json.Unmarshal(resp, baseResp); err != nil {
-			return xerrors.Errorf("json.Unmarshal error: %w", err)
-		}
-
-		return errors.New(fmt.Sprintf(
-			"status: %d, id: %s, code: %s, message: %s",
-			code,
-			baseResp.Id,
-			baseResp.Code,
-			baseResp.Message,
-		))
-	}
-
-	err = json.Unmarshal(resp, respBody)
-	if err != nil {
-		return xerrors.Errorf("json.Unmarshal error: %w", err)
-	}
-
-	return nil
+	return kc.do(ctx, http.MethodGet, path, query, nil, headers, respBody, newCallOptions(http.MethodGet, opts))
 }
 
-func (kc *KintoneClient) Post(ctx context.Context, authKey string, path string, body any, respBody any) (err error) {
-	var (
-		code      int
-		resp      []byte
-		bodyBytes []byte
-		headers   map[string]string
-	)
+// Post 預設只在 429 時重試，可安全重送的呼叫需帶 WithIdempotent(true)
+func (kc *KintoneClient) Post(ctx context.Context, auth Auth, path string, body any, respBody any, opts ...CallOption) error {
+	return kc.send(ctx, http.MethodPost, auth, path, body, respBody, opts)
+}
 
-	defer func() {
-		r := recover()
-		if r != nil || err != nil {
-			kc.logger.Info(ctx, "KintoneClient Post api response",
-				zap.String("http_request_body", string(bodyBytes)),
-				zap.Any("http_request_header", headers),
-				zap.Int("http_status_code", code),
-				zap.String("http_response_body", string(resp)),
-				zap.Error(err),
-			)
-		}
-	}()
+// Put 預設只在 429 時重試，可安全重送的呼叫需帶 WithIdempotent(true)
+func (kc *KintoneClient) Put(ctx context.Context, auth Auth, path string, body any, respBody any, opts ...CallOption) error {
+	return kc.send(ctx, http.MethodPut, auth, path, body, respBody, opts)
+}
 
-	_url := kc.cfg.GetKintoneConfig().Url + path
+// Delete 刪除為冪等操作，暫時性錯誤會重試
+func (kc *KintoneClient) Delete(ctx context.Context, auth Auth, path string, body any, respBody any, opts ...CallOption) error {
+	return kc.send(ctx, http.MethodDelete, auth, path, body, respBody, append([]CallOption{WithIdempotent(true)}, opts...))
+}
 
-	headers = map[string]string{
-		kintone.HeaderUserAuthorization: authKey,
-		"Content-Type":                  "application/json",
+func (kc *KintoneClient) send(ctx context.Context, method string, auth Auth, path string, body any, respBody any, opts []CallOption) error {
+	authHeader, authValue := kc.authHeader(auth)
+	headers := map[string]string{
+		authHeader:     authValue,
+		"Content-Type": "application/json",
 	}
 
-	bodyBytes, err = json.Marshal(body)
+	bodyBytes, err := json.Marshal(body)
 	if err != nil {
-		err = xerrors.Errorf("KintoneAPI Post json.Marshal error: %w", err)
-		return
+		return xerrors.Errorf("KintoneAPI %s json.Marshal error: %w", method, err)
 	}
 
-	code, resp, err = kc.HttpClient.Post(_url, bodyBytes, headers)
-	if err != nil {
-		return xerrors.Errorf("KintoneAPI kc.HttpClient.Post error: %w", err)
+	return kc.do(ctx, method, path, nil, bodyBytes, headers, respBody, newCallOptions(method, opts))
+}
+
+func (kc *KintoneClient) do(ctx context.Context, method string, path string, query map[string]string, body []byte, headers map[string]string, respBody any, opts *callOptions) error {
+	maxAttempts := opts.retryPolicy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
 
-	if code >= http.StatusBadRequest {
-		errResp := &dto.KintoneApiBaseResponse{}
-		if err := json.Unmarshal(resp, errResp); err != nil {
-			return xerrors.Errorf("KintoneAPI response json.Unmarshal error: %w", err)
+	for attempt := 1; ; attempt++ {
+		err := kc.doOnce(ctx, method, path, query, body, headers, respBody)
+		if err == nil {
+			return nil
 		}
 
-		return errors.New(fmt.Sprintf(
-			"KintoneAPI response send error : status: %d, id: %s, code: %s, message: %s",
-			code,
-			errResp.Id,
-			errResp.Code,
-			errResp.Message,
-		))
-	}
+		if attempt >= maxAttempts || !opts.shouldRetry(err) {
+			return err
+		}
 
-	err = json.Unmarshal(resp, respBody)
-	if err != nil {
-		return xerrors.Errorf("KintoneAPI kc.HttpClient.Post json.Unmarshal error: %w", err)
-	}
+		var retryAfter time.Duration
+		if kErr, ok := AsKintoneError(err); ok {
+			retryAfter = kErr.RetryAfter
+		}
+		wait, ok := opts.retryPolicy.delay(attempt, retryAfter)
+		if !ok {
+			return err
+		}
 
-	return nil
+		kc.logger.Warn(ctx, "KintoneClient retry api request",
+			zap.String("http_method", method),
+			zap.String("path", path),
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return xerrors.Errorf("KintoneAPI %s retry canceled: %w", method, err)
+		case <-timer.C:
+		}
+	}
 }
 
-func (kc *KintoneClient) Put(ctx context.Context, authKey string, path string, body any, respBody any) (err error) {
+func (kc *KintoneClient) doOnce(ctx context.Context, method string, path string, query map[string]string, body []byte, headers map[string]string, respBody any) (err error) {
 	var (
-		code      int
-		resp      []byte
-		bodyBytes []byte
-		headers   map[string]string
+		code       int
+		respHeader http.Header
+		resp       []byte
 	)
 
 	defer func() {
 		r := recover()
 		if r != nil || err != nil {
-			kc.logger.Info(ctx, "KintoneClient Put api response",
-				zap.String("http_request_body", string(bodyBytes)),
-				zap.Any("http_request_header", headers),
+			kc.logger.Info(ctx, "KintoneClient api response",
+				zap.String("http_method", method),
+				zap.String("http_request_body", string(body)),
 				zap.Int("http_status_code", code),
 				zap.String("http_response_body", string(resp)),
 				zap.Error(err),
@@ -163,42 +131,54 @@ func (kc *KintoneClient) Put(ctx context.Context, authKey string, path string, b
 	}()
 
 	_url := kc.cfg.GetKintoneConfig().Url + path
-
-	headers = map[string]string{
-		kintone.HeaderUserAuthorization: authKey,
-		"Content-Type":                  "application/json",
-	}
-
-	bodyBytes, err = json.Marshal(body)
-	if err != nil {
-		err = xerrors.Errorf("KintoneAPI Put json.Marshal error: %w", err)
-		return
+	if query != nil {
+		_url = httpUtil.EncodeUrl(_url, query)
 	}
 
-	code, resp, err = kc.HttpClient.Put(_url, bodyBytes, headers)
+	code, respHeader, resp, err = kc.HttpClient.RequestWithHeader(method, _url, body, headers)
 	if err != nil {
-		return xerrors.Errorf("KintoneAPI kc.HttpClient.Put error: %w", err)
+		return &transportError{err: xerrors.Errorf("KintoneAPI HttpClient.%s error: %w", method, err)}
 	}
 
 	if code >= http.StatusBadRequest {
-		errResp := &dto.KintoneApiBaseResponse{}
-		if err := json.Unmarshal(resp, errResp); err != nil {
-			return xerrors.Errorf("KintoneAPI response json.Unmarshal error: %w", err)
+		kErr := &KintoneError{
+			Method:     method,
+			Path:       path,
+			StatusCode: code,
+			RetryAfter: parseRetryAfter(respHeader, time.Now()),
+		}
+		errResp := &kintoneErrorResponse{}
+		// 429/5xx 可能是 proxy 回傳的非 json 內容，解析失敗仍回傳 KintoneError 讓上層判斷是否重試
+		if jsonErr := json.Unmarshal(resp, errResp); jsonErr != nil {
+			kErr.Message = string(resp)
+		} else {
+			kErr.Code = errResp.Code
+			kErr.Id = errResp.Id
+			kErr.Message = errResp.Message
+			// bulkRequest 失敗時只有失敗的請求帶有錯誤內容，其餘為空物件
+			for i, result := range errResp.Results {
+				if result.Code != "" {
+					kErr.Code = result.Code
+					kErr.Id = result.Id
+					kErr.Message = result.Message
+					kErr.BulkRequestIndex = i + 1
+					break
+				}
+			}
 		}
 
-		return errors.New(fmt.Sprintf(
-			"KintoneAPI response send error : status: %d, id: %s, code: %s, message: %s",
-			code,
-			errResp.Id,
-			errResp.Code,
-			errResp.Message,
-		))
+		return kErr
 	}
 
 	err = json.Unmarshal(resp, respBody)
 	if err != nil {
-		return xerrors.Errorf("KintoneAPI kc.HttpClient.Put json.Unmarshal error: %w", err)
+		return xerrors.Errorf("KintoneAPI %s json.Unmarshal error: %w", method, err)
 	}
 
 	return nil
 }
+
+type kintoneErrorResponse struct {
+	dto.KintoneApiBaseResponse
+	Results []dto.KintoneApiBaseResponse `json:"results"`
+}
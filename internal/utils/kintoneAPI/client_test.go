@@ -0,0 +1,39 @@
+package kintoneAPI
+
+import (
+	"context"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"jaystar/internal/utils/kintoneFake"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SeanZhenggg/go-utils/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeKintoneClient(t *testing.T) (*KintoneClient, *kintoneFake.Server) {
+	server := kintoneFake.NewServer()
+	t.Cleanup(server.Close)
+	cfg := server.ConfigEnv()
+	return ProvideKintoneClient(cfg, logger.ProviderILogger(cfg)), server
+}
+
+func TestKintoneClientRetry(t *testing.T) {
+	kc, server := newFakeKintoneClient(t)
+	fastRetry := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxRetryAfter: time.Second})
+	query := map[string]string{kintone.QueryApp: kintoneFake.AppId.StudentInfo, kintone.QueryQuery: "limit 1"}
+
+	server.InjectFault(kintoneFake.Fault{Path: kintone.RecordsPath, StatusCode: http.StatusServiceUnavailable, Body: "<html>Service Unavailable</html>", Times: 2})
+	res := &dto.StudentRes{}
+	require.NoError(t, kc.Get(context.TODO(), ReadAuth(kintoneFake.AppId.StudentInfo), kintone.RecordsPath, query, res, fastRetry))
+	assert.Len(t, res.Records, 1)
+	assert.Len(t, server.Requests(), 3)
+
+	server.InjectFault(kintoneFake.Fault{Path: kintone.RecordsPath, StatusCode: http.StatusBadRequest, Code: kintoneFake.CodeValidation, Message: "invalid"})
+	err := kc.Get(context.TODO(), ReadAuth(kintoneFake.AppId.StudentInfo), kintone.RecordsPath, query, res, fastRetry)
+	assert.True(t, IsKintoneErrorCode(err, kintoneFake.CodeValidation))
+	assert.Len(t, server.Requests(), 4, "400 must not be retried")
+}
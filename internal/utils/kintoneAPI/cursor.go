@@ -0,0 +1,97 @@
+package kintoneAPI
+
+import (
+	"context"
+	"golang.org/x/xerrors"
+	"jaystar/internal/constant/kintone"
+	"jaystar/internal/model/dto"
+	"strconv"
+)
+
+// RecordCursor kintone cursor API 串流讀取，不受 offset 10,000 筆的限制
+// 使用方式:
+//
+//	for cursor.Next(ctx) { records := cursor.Records() }
+//	if err := cursor.Err(); err != nil { ... }
+//
+// 未讀完就中斷時必須呼叫 Close 釋放 kintone 端的 cursor (每個網域同時最多 10 個)
+type RecordCursor[T any] struct {
+	kc         *KintoneClient
+	auth       Auth
+	id         string
+	totalCount int
+	records    []T
+	hasNext    bool
+	err        error
+}
+
+func NewRecordCursor[T any](ctx context.Context, kc *KintoneClient, auth Auth, req *dto.KintoneCreateCursorReq) (*RecordCursor[T], error) {
+	res := &dto.KintoneCreateCursorRes{}
+	if err := kc.Post(ctx, auth, kintone.RecordsCursorPath, req, res); err != nil {
+		return nil, xerrors.Errorf("KintoneAPI NewRecordCursor kc.Post: %w", err)
+	}
+
+	total, err := strconv.Atoi(res.TotalCount)
+	if err != nil {
+		cursor := &RecordCursor[T]{kc: kc, auth: auth, id: res.Id, hasNext: true}
+		_ = cursor.Close(ctx)
+		return nil, xerrors.Errorf("KintoneAPI NewRecordCursor strconv.Atoi: %w", err)
+	}
+
+	return &RecordCursor[T]{
+		kc:         kc,
+		auth:       auth,
+		id:         res.Id,
+		totalCount: total,
+		hasNext:    true,
+	}, nil
+}
+
+// Next 取得下一批資料，沒有資料或發生錯誤時回傳 false
+func (c *RecordCursor[T]) Next(ctx context.Context) bool {
+	if c.err != nil || !c.hasNext {
+		c.records = nil
+		return false
+	}
+
+	res := &dto.KintoneCursorRecordsRes[T]{}
+	query := map[string]string{kintone.QueryId: c.id}
+	// cursor 每次讀取都會往前推進，請求可能已被處理時不可重送
+	if err := c.kc.Get(ctx, c.auth, kintone.RecordsCursorPath, query, res, WithIdempotent(false)); err != nil {
+		c.err = xerrors.Errorf("KintoneAPI RecordCursor kc.Get: %w", err)
+		c.records = nil
+		return false
+	}
+
+	c.records = res.Records
+	c.hasNext = res.Next
+
+	return len(c.records) > 0 || c.hasNext
+}
+
+func (c *RecordCursor[T]) Records() []T {
+	return c.records
+}
+
+func (c *RecordCursor[T]) TotalCount() int {
+	return c.totalCount
+}
+
+func (c *RecordCursor[T]) Err() error {
+	return c.err
+}
+
+// Close 讀取完畢時 kintone 會自動刪除 cursor，只有中途結束才需要呼叫 API
+func (c *RecordCursor[T]) Close(ctx context.Context) error {
+	if !c.hasNext {
+		return nil
+	}
+	c.hasNext = false
+
+	req := &dto.KintoneDeleteCursorReq{Id: c.id}
+	if err := c.kc.Delete(ctx, c.auth, kintone.RecordsCursorPath, req, &struct{}{}); err != nil {
+		return xerrors.Errorf("KintoneAPI RecordCursor kc.Delete: %w", err)
+	}
+
+	return nil
+}
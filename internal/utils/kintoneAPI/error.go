@@ -0,0 +1,92 @@
+package kintoneAPI
+
+import (
+	"errors"
+	"fmt"
+	"jaystar/internal/utils/errs"
+	"net/http"
+	"time"
+)
+
+// CodeRevisionConflict 更新時帶的 revision 不是最新 (記錄已被其他人修改)
+const CodeRevisionConflict = "GAIA_CO02"
+
+// KintoneError kintone API 回傳 4xx/5xx 時的錯誤，保留 kintone 的 code / id 供呼叫端判斷
+type KintoneError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Code       string
+	Id         string
+	Message    string
+	// RetryAfter response header Retry-After 解析後的等待時間，沒有帶則為 0
+	RetryAfter time.Duration
+	// BulkRequestIndex bulkRequest 中失敗的請求序號 (從 1 開始)，0 代表不是 bulkRequest 的錯誤
+	BulkRequestIndex int
+}
+
+func (e *KintoneError) Error() string {
+	if e.BulkRequestIndex > 0 {
+		return fmt.Sprintf(
+			"KintoneAPI %s %s response error: status: %d, request: %d, id: %s, code: %s, message: %s",
+			e.Method,
+			e.Path,
+			e.StatusCode,
+			e.BulkRequestIndex,
+			e.Id,
+			e.Code,
+			e.Message,
+		)
+	}
+	return fmt.Sprintf(
+		"KintoneAPI %s %s response error: status: %d, id: %s, code: %s, message: %s",
+		e.Method,
+		e.Path,
+		e.StatusCode,
+		e.Id,
+		e.Code,
+		e.Message,
+	)
+}
+
+// Is 讓呼叫端可以用 errors.Is(err, errs.KintoneErr.RevisionConflictError) 判斷 revision 衝突
+func (e *KintoneError) Is(target error) bool {
+	return target == errs.KintoneErr.RevisionConflictError && e.IsRevisionConflict()
+}
+
+func (e *KintoneError) IsRevisionConflict() bool {
+	return e.Code == CodeRevisionConflict
+}
+
+// IsRateLimited kintone 拒絕處理該請求 (同時連線數或請求數超過上限)，請求未被執行
+func (e *KintoneError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsTemporary 暫時性錯誤，稍後重試可能成功
+func (e *KintoneError) IsTemporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// AsKintoneError 從 wrap 過的 error 中取出 *KintoneError
+func AsKintoneError(err error) (*KintoneError, bool) {
+	var kErr *KintoneError
+	if errors.As(err, &kErr) {
+		return kErr, true
+	}
+	return nil, false
+}
+
+// IsKintoneErrorCode 判斷 err 是否為指定 kintone error code (e.g. GAIA_CO02)
+func IsKintoneErrorCode(err error, code string) bool {
+	kErr, ok := AsKintoneError(err)
+	return ok && kErr.Code == code
+}
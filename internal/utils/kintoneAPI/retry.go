@@ -0,0 +1,148 @@
+package kintoneAPI
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy kintone API 重試策略，exponential backoff + jitter
+type RetryPolicy struct {
+	// MaxAttempts 包含第一次請求的最大嘗試次數，<= 1 代表不重試
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// MaxRetryAfter Retry-After 可接受的最長等待時間，超過則直接回傳錯誤
+	MaxRetryAfter time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   5,
+	BaseDelay:     200 * time.Millisecond,
+	MaxDelay:      5 * time.Second,
+	MaxRetryAfter: 30 * time.Second,
+}
+
+// NoRetryPolicy 只請求一次
+var NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// backoff 第 attempt 次 (從 1 開始) 失敗後的等待時間，區間為 [d/2, d]
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// delay 決定下次重試前的等待時間，有 Retry-After 時以 Retry-After 為準
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+	return p.backoff(attempt), true
+}
+
+type callOptions struct {
+	retryPolicy RetryPolicy
+	// idempotent 為 true 時，5xx 與網路錯誤也會重試；否則只重試 429 (kintone 尚未處理該請求)
+	idempotent bool
+}
+
+type CallOption func(opts *callOptions)
+
+// WithRetryPolicy 覆寫單次呼叫的重試策略
+func WithRetryPolicy(policy RetryPolicy) CallOption {
+	return func(opts *callOptions) {
+		opts.retryPolicy = policy
+	}
+}
+
+// WithNoRetry 單次呼叫不重試
+func WithNoRetry() CallOption {
+	return WithRetryPolicy(NoRetryPolicy)
+}
+
+// WithIdempotent 標記此呼叫可安全重送 (e.g. 以 updateKey 更新為固定值的 PUT)
+// 帶 revision 的更新不可標記，重送已寫入的請求會收到 GAIA_CO02
+func WithIdempotent(idempotent bool) CallOption {
+	return func(opts *callOptions) {
+		opts.idempotent = idempotent
+	}
+}
+
+func newCallOptions(method string, opts []CallOption) *callOptions {
+	o := &callOptions{
+		retryPolicy: DefaultRetryPolicy,
+		idempotent:  method == http.MethodGet,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// transportError 請求未取得 response (連線失敗、timeout 等)
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// shouldRetry 網路錯誤或暫時性錯誤才重試；
+// 非冪等的請求只有在確定未被處理 (429) 時才重試
+func (o *callOptions) shouldRetry(err error) bool {
+	var tErr *transportError
+	if errors.As(err, &tErr) {
+		return o.idempotent
+	}
+	kErr, ok := AsKintoneError(err)
+	if !ok {
+		return false
+	}
+	if kErr.IsRateLimited() {
+		return true
+	}
+	return o.idempotent && kErr.IsTemporary()
+}
+
+// parseRetryAfter 支援秒數與 HTTP-date 兩種格式
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
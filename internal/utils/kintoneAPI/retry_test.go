@@ -0,0 +1,80 @@
+package kintoneAPI
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "no header", header: http.Header{}, want: 0},
+		{name: "seconds", header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second},
+		{name: "negative seconds", header: http.Header{"Retry-After": {"-1"}}, want: 0},
+		{name: "http date", header: http.Header{"Retry-After": {now.Add(2 * time.Second).Format(http.TimeFormat)}}, want: 2 * time.Second},
+		{name: "past http date", header: http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, want: 0},
+		{name: "invalid", header: http.Header{"Retry-After": {"soon"}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 400 * time.Millisecond, MaxRetryAfter: time.Second}
+
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 6: 400 * time.Millisecond} {
+		d, ok := p.delay(attempt, 0)
+		if !ok || d < max/2 || d > max {
+			t.Errorf("delay(%d) = %v, want in [%v, %v]", attempt, d, max/2, max)
+		}
+	}
+
+	if d, ok := p.delay(1, 800*time.Millisecond); !ok || d != 800*time.Millisecond {
+		t.Errorf("delay with Retry-After = %v, %v, want 800ms, true", d, ok)
+	}
+	if _, ok := p.delay(1, 2*time.Second); ok {
+		t.Errorf("delay with Retry-After over MaxRetryAfter should give up")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	rateLimited := &KintoneError{StatusCode: http.StatusTooManyRequests}
+	unavailable := &KintoneError{StatusCode: http.StatusServiceUnavailable}
+	badRequest := &KintoneError{StatusCode: http.StatusBadRequest, Code: "CB_VA01"}
+	network := &transportError{err: errors.New("connection reset")}
+
+	tests := []struct {
+		name   string
+		method string
+		opts   []CallOption
+		err    error
+		want   bool
+	}{
+		{name: "get 503", method: http.MethodGet, err: unavailable, want: true},
+		{name: "get network error", method: http.MethodGet, err: network, want: true},
+		{name: "get 400", method: http.MethodGet, err: badRequest, want: false},
+		{name: "post 429", method: http.MethodPost, err: rateLimited, want: true},
+		{name: "post 503", method: http.MethodPost, err: unavailable, want: false},
+		{name: "post network error", method: http.MethodPost, err: network, want: false},
+		{name: "idempotent put 503", method: http.MethodPut, opts: []CallOption{WithIdempotent(true)}, err: unavailable, want: true},
+		{name: "json error", method: http.MethodGet, err: errors.New("json.Unmarshal error"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newCallOptions(tt.method, tt.opts).shouldRetry(tt.err); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
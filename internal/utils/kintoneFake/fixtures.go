@@ -0,0 +1,361 @@
+package kintoneFake
+
+import (
+	"jaystar/internal/config"
+)
+
+// App 應用程式設定，Fields 為欄位代碼對應欄位型別，子表格內的欄位也列在同一層
+// 查詢條件與排序只能使用 Fields 內的欄位，寫入時依此補上欄位型別
+type App struct {
+	Id     string
+	Name   string
+	Fields map[string]string
+}
+
+// AppId NewServer 註冊的應用程式 id
+var AppId = config.AppIdInfo{
+	StudentInfo:          "1",
+	PointCard:            "2",
+	ScheduleRecord:       "3",
+	DepositRecord:        "4",
+	ReduceRecord:         "5",
+	SemesterSettleRecord: "6",
+}
+
+var fakeUser = map[string]string{"code": "fake", "name": "fake"}
+
+// 學生資訊/課表/儲值/扣課的自動欄位代碼為英文，點數卡/學期結算為中文
+var (
+	auditFields = map[string]string{
+		"createdBy": TypeCreator,
+		"createdAt": TypeCreatedTime,
+		"updatedBy": TypeModifier,
+		"updatedAt": TypeUpdatedTime,
+	}
+	auditFieldsZh = map[string]string{
+		"建立人":  TypeCreator,
+		"建立時間": TypeCreatedTime,
+		"更新人":  TypeModifier,
+		"更新時間": TypeUpdatedTime,
+	}
+)
+
+func withFields(base map[string]string, fields map[string]string) map[string]string {
+	for code, fieldType := range base {
+		fields[code] = fieldType
+	}
+	return fields
+}
+
+// DefaultApps 與正式環境欄位代碼一致的六個應用程式
+func DefaultApps() []App {
+	return []App{
+		{
+			Id:   AppId.StudentInfo,
+			Name: "學生資訊",
+			Fields: withFields(auditFields, map[string]string{
+				"studentName":      TypeText,
+				"birthday":         "DATE",
+				"gender":           "RADIO_BUTTON",
+				"parentName":       TypeText,
+				"parentPhone":      TypeText,
+				"restPoints":       "NUMBER",
+				"description":      "MULTI_LINE_TEXT",
+				"taxId":            TypeText,
+				"relationship":     "DROP_DOWN",
+				"mode":             "DROP_DOWN",
+				"isSettleNormally": "RADIO_BUTTON",
+			}),
+		},
+		{
+			Id:   AppId.PointCard,
+			Name: "點數卡",
+			Fields: withFields(auditFieldsZh, map[string]string{
+				"studentName": TypeText,
+				"restPoints":  "NUMBER",
+				"clearPoints": "NUMBER",
+			}),
+		},
+		{
+			Id:   AppId.ScheduleRecord,
+			Name: "課表",
+			Fields: withFields(auditFields, map[string]string{
+				"teacherName":  "DROP_DOWN",
+				"classLevel":   "DROP_DOWN",
+				"classType":    "DROP_DOWN",
+				"classTime":    "DATETIME",
+				"description":  "MULTI_LINE_TEXT",
+				"attendance":   TypeSubtable,
+				"recordId":     "NUMBER",
+				"attendStatus": "CHECK_BOX",
+				"studentName":  TypeText,
+				"reducePoints": "NUMBER",
+			}),
+		},
+		{
+			Id:   AppId.DepositRecord,
+			Name: "儲值紀錄",
+			Fields: withFields(auditFields, map[string]string{
+				"chargingMethod":       "CHECK_BOX",
+				"actualChargingAmount": "NUMBER",
+				"gender":               "RADIO_BUTTON",
+				"depositedPoints":      "NUMBER",
+				"teacherName":          "DROP_DOWN",
+				"description":          "MULTI_LINE_TEXT",
+				"parentPhone":          TypeText,
+				"chargingDate":         "DATE",
+				"chargingAmount":       "NUMBER",
+				"parentName":           TypeText,
+				"accountLastFiveYards": TypeText,
+				"taxId":                TypeText,
+				"studentName":          TypeText,
+				"chargingStatus":       "RADIO_BUTTON",
+			}),
+		},
+		{
+			Id:   AppId.ReduceRecord,
+			Name: "扣課紀錄",
+			Fields: withFields(auditFields, map[string]string{
+				"studentName":  TypeText,
+				"classLevel":   "DROP_DOWN",
+				"classType":    "DROP_DOWN",
+				"classTime":    "DATETIME",
+				"teacherName":  "DROP_DOWN",
+				"reducePoints": "NUMBER",
+				"attendStatus": "CHECK_BOX",
+				"description":  "MULTI_LINE_TEXT",
+			}),
+		},
+		{
+			Id:   AppId.SemesterSettleRecord,
+			Name: "學期結算紀錄",
+			Fields: withFields(auditFieldsZh, map[string]string{
+				"studentName": TypeText,
+				"startTime":   "DATE",
+				"endTime":     "DATE",
+				"clearPoints": "NUMBER",
+			}),
+		},
+	}
+}
+
+// Fixture 使用的學生皆為虛構的姓名與家長電話，其他應用程式的 studentName 為「姓名/家長電話」
+const (
+	StudentAName  = "測試學生A"
+	StudentAPhone = "0900000001"
+	StudentBName  = "測試學生B"
+	StudentBPhone = "0900000002"
+	StudentCName  = "測試學生C"
+	StudentCPhone = "0900000003"
+	StudentDName  = "測試學生D"
+	StudentDPhone = "0900000004"
+	StudentEName  = "測試學生E"
+	StudentEPhone = "0900000005"
+	// StudentFName 兄弟姊妹共用一筆學生資料時以 . 分隔姓名
+	StudentFName  = "測試學生F.G"
+	StudentFPhone = "0900000006"
+
+	StudentA = StudentAName + "/" + StudentAPhone
+	StudentB = StudentBName + "/" + StudentBPhone
+	StudentC = StudentCName + "/" + StudentCPhone
+	StudentD = StudentDName + "/" + StudentDPhone
+	StudentE = StudentEName + "/" + StudentEPhone
+	StudentF = StudentFName + "/" + StudentFPhone
+
+	// StudentARenamed 測試學生改名時使用的新名稱
+	StudentARenamed = StudentAName + "/0900000011"
+)
+
+const fixtureTime = "2024-03-01T02:00:00Z"
+
+func field(fieldType string, value any) Field {
+	return Field{Type: fieldType, Value: value}
+}
+
+func text(value string) Field {
+	return field(TypeText, value)
+}
+
+func audit(rec Record) Record {
+	rec["createdBy"] = field(TypeCreator, fakeUser)
+	rec["createdAt"] = field(TypeCreatedTime, fixtureTime)
+	rec["updatedBy"] = field(TypeModifier, fakeUser)
+	rec["updatedAt"] = field(TypeUpdatedTime, fixtureTime)
+	return rec
+}
+
+func auditZh(rec Record) Record {
+	rec["建立人"] = field(TypeCreator, fakeUser)
+	rec["建立時間"] = field(TypeCreatedTime, fixtureTime)
+	rec["更新人"] = field(TypeModifier, fakeUser)
+	rec["更新時間"] = field(TypeUpdatedTime, fixtureTime)
+	return rec
+}
+
+func student(id, name, phone, mode, restPoints string) Record {
+	return audit(Record{
+		fieldId:            text(id),
+		"studentName":      text(name),
+		"birthday":         field("DATE", "2018-05-01"),
+		"gender":           field("RADIO_BUTTON", "男"),
+		"parentName":       text(name + "家長"),
+		"parentPhone":      text(phone),
+		"restPoints":       field("NUMBER", restPoints),
+		"description":      field("MULTI_LINE_TEXT", ""),
+		"taxId":            text(""),
+		"relationship":     field("DROP_DOWN", "母親"),
+		"mode":             field("DROP_DOWN", mode),
+		"isSettleNormally": field("RADIO_BUTTON", "是"),
+	})
+}
+
+func pointCard(id, studentName, restPoints string) Record {
+	return auditZh(Record{
+		fieldId:       text(id),
+		"studentName": text(studentName),
+		"restPoints":  field("NUMBER", restPoints),
+		"clearPoints": field("NUMBER", "0"),
+	})
+}
+
+type attendee struct {
+	rowId       string
+	recordId    string
+	studentName string
+}
+
+func schedule(id, classTime string, attendees ...attendee) Record {
+	rows := make([]map[string]any, 0, len(attendees))
+	for _, a := range attendees {
+		rows = append(rows, map[string]any{
+			"id": a.rowId,
+			"value": map[string]Field{
+				"recordId":     field("NUMBER", a.recordId),
+				"attendStatus": field("CHECK_BOX", []string{"出席"}),
+				"studentName":  text(a.studentName),
+				"reducePoints": field("NUMBER", "1"),
+			},
+		})
+	}
+	return audit(Record{
+		fieldId:       text(id),
+		"teacherName": field("DROP_DOWN", "Jay"),
+		"classLevel":  field("DROP_DOWN", "Level 1"),
+		"classType":   field("DROP_DOWN", "團課"),
+		"classTime":   field("DATETIME", classTime),
+		"description": field("MULTI_LINE_TEXT", ""),
+		"attendance":  field(TypeSubtable, rows),
+	})
+}
+
+func depositRecord(id, studentName, chargingDate, points string) Record {
+	return audit(Record{
+		fieldId:                text(id),
+		"chargingMethod":       field("CHECK_BOX", []string{"匯款ATM"}),
+		"actualChargingAmount": field("NUMBER", "12000"),
+		"gender":               field("RADIO_BUTTON", "男"),
+		"depositedPoints":      field("NUMBER", points),
+		"teacherName":          field("DROP_DOWN", "Jay"),
+		"description":          field("MULTI_LINE_TEXT", ""),
+		"parentPhone":          text(""),
+		"chargingDate":         field("DATE", chargingDate),
+		"chargingAmount":       field("NUMBER", "12000"),
+		"parentName":           text(""),
+		"accountLastFiveYards": text("12345"),
+		"taxId":                text(""),
+		"studentName":          text(studentName),
+		"chargingStatus":       field("RADIO_BUTTON", "已入款"),
+	})
+}
+
+func reduceRecord(id, studentName, classTime string) Record {
+	return audit(Record{
+		fieldId:        text(id),
+		"studentName":  text(studentName),
+		"classLevel":   field("DROP_DOWN", "Level 1"),
+		"classType":    field("DROP_DOWN", "團課"),
+		"classTime":    field("DATETIME", classTime),
+		"teacherName":  field("DROP_DOWN", "Jay"),
+		"reducePoints": field("NUMBER", "1"),
+		"attendStatus": field("CHECK_BOX", []string{"出席"}),
+		"description":  field("MULTI_LINE_TEXT", ""),
+	})
+}
+
+func semesterSettleRecord(id, studentName, startTime, endTime, clearPoints string) Record {
+	return auditZh(Record{
+		fieldId:       text(id),
+		"studentName": text(studentName),
+		"startTime":   field("DATE", startTime),
+		"endTime":     field("DATE", endTime),
+		"clearPoints": field("NUMBER", clearPoints),
+	})
+}
+
+// SeedFixtures 載入各應用程式的測試資料，記錄 id 沿用正式環境測試曾使用的 id
+func (s *Server) SeedFixtures() {
+	for _, rec := range []Record{
+		student("101", StudentAName, StudentAPhone, "學期制", "12"),
+		student("102", StudentBName, StudentBPhone, "舊制", "5"),
+		student("103", StudentCName, StudentCPhone, "學期制", "8"),
+		student("104", StudentDName, StudentDPhone, "學期制", "3"),
+		student("105", StudentEName, StudentEPhone, "舊制", "0"),
+		student("106", StudentFName, StudentFPhone, "學期制", "20"),
+	} {
+		s.AddRecord(AppId.StudentInfo, rec)
+	}
+
+	for _, rec := range []Record{
+		pointCard("1439", StudentA, "12"),
+		pointCard("1440", StudentB, "5"),
+		pointCard("1441", StudentC, "8"),
+		pointCard("1442", StudentD, "3"),
+		pointCard("1443", StudentE, "0"),
+		pointCard("1444", StudentF, "20"),
+	} {
+		s.AddRecord(AppId.PointCard, rec)
+	}
+
+	for _, rec := range []Record{
+		schedule("7369", "2024-03-02T02:00:00Z",
+			attendee{rowId: "271986", recordId: "23440", studentName: StudentC},
+			attendee{rowId: "271988", recordId: "23441", studentName: StudentA},
+		),
+		schedule("7452", "2024-03-09T02:00:00Z",
+			attendee{rowId: "272663", recordId: "23445", studentName: StudentD},
+			attendee{rowId: "272665", recordId: "23446", studentName: StudentE},
+			attendee{rowId: "272667", recordId: "23447", studentName: StudentF},
+			attendee{rowId: "272669", recordId: "23448", studentName: StudentA},
+		),
+		schedule("7460", "2024-03-16T02:00:00Z",
+			attendee{rowId: "281616", recordId: "23611", studentName: StudentC},
+			attendee{rowId: "281618", recordId: "23612", studentName: StudentA},
+		),
+	} {
+		s.AddRecord(AppId.ScheduleRecord, rec)
+	}
+
+	for _, rec := range []Record{
+		depositRecord("1730", StudentA, "2024-02-20", "10"),
+		depositRecord("1801", StudentB, "2024-02-25", "10"),
+		depositRecord("1929", StudentA, "2024-03-05", "10"),
+	} {
+		s.AddRecord(AppId.DepositRecord, rec)
+	}
+
+	for _, rec := range []Record{
+		reduceRecord("23440", StudentC, "2024-03-02T02:00:00Z"),
+		reduceRecord("23441", StudentA, "2024-03-02T02:00:00Z"),
+		reduceRecord("23448", StudentA, "2024-03-09T02:00:00Z"),
+		reduceRecord("23611", StudentC, "2024-03-16T02:00:00Z"),
+		reduceRecord("23612", StudentA, "2024-03-16T02:00:00Z"),
+	} {
+		s.AddRecord(AppId.ReduceRecord, rec)
+	}
+
+	for _, rec := range []Record{
+		semesterSettleRecord("301", StudentB, "2023-09-01", "2024-02-29", "2"),
+	} {
+		s.AddRecord(AppId.SemesterSettleRecord, rec)
+	}
+}
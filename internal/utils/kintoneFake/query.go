@@ -0,0 +1,518 @@
+package kintoneFake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 支援 kintoneQuery 會產生的語法子集:
+//
+//	條件: = != > < >= <= like "not like" in "not in"，以 and / or 串接並可用括號分組 (and 優先於 or)
+//	排序: order by field asc|desc, ...
+//	分頁: limit n offset n
+//
+// 不支援 kintone 的函式 (TODAY() 等) 與欄位型別相關的運算子限制
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			sb := strings.Builder{}
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, value: sb.String()})
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, token{kind: tokenSymbol, value: string(r)})
+			i++
+		case r == '=' || r == '!' || r == '<' || r == '>':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenSymbol, value: string(runes[i : i+2])})
+				i += 2
+				continue
+			}
+			if r == '!' {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, value: string(r)})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`"(),=!<>`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type condition interface {
+	match(rec *record) bool
+	fields() []string
+}
+
+type andCond []condition
+
+func (c andCond) match(rec *record) bool {
+	for _, cond := range c {
+		if !cond.match(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c andCond) fields() []string { return collectFields(c) }
+
+type orCond []condition
+
+func (c orCond) match(rec *record) bool {
+	for _, cond := range c {
+		if cond.match(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c orCond) fields() []string { return collectFields(c) }
+
+func collectFields(conds []condition) []string {
+	fields := make([]string, 0, len(conds))
+	for _, cond := range conds {
+		fields = append(fields, cond.fields()...)
+	}
+	return fields
+}
+
+type compareCond struct {
+	field  string
+	op     string
+	values []string
+}
+
+func (c compareCond) fields() []string { return []string{c.field} }
+
+func (c compareCond) match(rec *record) bool {
+	actuals := rec.lookup(c.field)
+	switch c.op {
+	case "=":
+		return anyOf(actuals, func(a string) bool { return compareValues(a, c.values[0]) == 0 })
+	case "!=":
+		return !anyOf(actuals, func(a string) bool { return compareValues(a, c.values[0]) == 0 })
+	case ">":
+		return anyOf(actuals, func(a string) bool { return a != "" && compareValues(a, c.values[0]) > 0 })
+	case "<":
+		return anyOf(actuals, func(a string) bool { return a != "" && compareValues(a, c.values[0]) < 0 })
+	case ">=":
+		return anyOf(actuals, func(a string) bool { return a != "" && compareValues(a, c.values[0]) >= 0 })
+	case "<=":
+		return anyOf(actuals, func(a string) bool { return a != "" && compareValues(a, c.values[0]) <= 0 })
+	case "like":
+		return anyOf(actuals, func(a string) bool { return strings.Contains(a, c.values[0]) })
+	case "not like":
+		return !anyOf(actuals, func(a string) bool { return strings.Contains(a, c.values[0]) })
+	case "in":
+		return anyOf(actuals, func(a string) bool { return containsValue(c.values, a) })
+	case "not in":
+		return !anyOf(actuals, func(a string) bool { return containsValue(c.values, a) })
+	}
+	return false
+}
+
+func anyOf(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if compareValues(v, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues 兩邊都是日期時間或數字時依型別比較，否則以字串比較
+func compareValues(a, b string) int {
+	if ta, ok := parseTime(a); ok {
+		if tb, ok := parseTime(b); ok {
+			return ta.Compare(tb)
+		}
+	}
+	if fa, err := strconv.ParseFloat(a, 64); err == nil {
+		if fb, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+type order struct {
+	field string
+	desc  bool
+}
+
+type query struct {
+	cond      condition
+	orders    []order
+	limit     int
+	hasLimit  bool
+	offset    int
+	hasOffset bool
+}
+
+func parseQuery(s string) (*query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &query{}
+
+	if !p.done() && !p.isKeyword("order") && !p.isKeyword("limit") && !p.isKeyword("offset") {
+		q.cond, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.isKeyword("order") {
+		p.next()
+		if !p.isKeyword("by") {
+			return nil, fmt.Errorf("expected by after order")
+		}
+		p.next()
+		for {
+			tok, ok := p.next()
+			if !ok || tok.kind != tokenIdent {
+				return nil, fmt.Errorf("expected field in order by")
+			}
+			o := order{field: tok.value}
+			if p.isKeyword("asc") {
+				p.next()
+			} else if p.isKeyword("desc") {
+				p.next()
+				o.desc = true
+			}
+			q.orders = append(q.orders, o)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.isKeyword("limit") {
+		p.next()
+		if q.limit, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+		q.hasLimit = true
+	}
+
+	if p.isKeyword("offset") {
+		p.next()
+		if q.offset, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+		q.hasOffset = true
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos].value)
+	}
+
+	return q, nil
+}
+
+func (q *query) match(rec *record) bool {
+	return q.cond == nil || q.cond.match(rec)
+}
+
+func (q *query) fields() []string {
+	fields := make([]string, 0)
+	if q.cond != nil {
+		fields = append(fields, q.cond.fields()...)
+	}
+	for _, o := range q.orders {
+		fields = append(fields, o.field)
+	}
+	return fields
+}
+
+// sort 沒有指定排序時與 kintone 相同以 $id desc 排序
+func (q *query) sort(records []*record) {
+	orders := q.orders
+	if len(orders) == 0 {
+		orders = []order{{field: fieldId, desc: true}}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, o := range orders {
+			c := compareValues(firstOf(records[i].lookup(o.field)), firstOf(records[j].lookup(o.field)))
+			if c == 0 {
+				continue
+			}
+			if o.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.done() {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (token, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	tok, ok := p.peek()
+	return ok && tok.kind == tokenIdent && strings.EqualFold(tok.value, keyword)
+}
+
+func (p *parser) isSymbol(symbol string) bool {
+	tok, ok := p.peek()
+	return ok && tok.kind == tokenSymbol && tok.value == symbol
+}
+
+func (p *parser) parseInt() (int, error) {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokenIdent {
+		return 0, fmt.Errorf("expected number")
+	}
+	n, err := strconv.Atoi(tok.value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", tok.value)
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	conds := orCond{first}
+	for p.isKeyword("or") {
+		p.next()
+		cond, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 1 {
+		return first, nil
+	}
+	return conds, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	conds := andCond{first}
+	for p.isKeyword("and") {
+		p.next()
+		cond, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 1 {
+		return first, nil
+	}
+	return conds, nil
+}
+
+func (p *parser) parseTerm() (condition, error) {
+	if p.isSymbol("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isSymbol(")") {
+			return nil, fmt.Errorf("expected )")
+		}
+		p.next()
+		return cond, nil
+	}
+
+	field, ok := p.next()
+	if !ok || field.kind != tokenIdent {
+		return nil, fmt.Errorf("expected field")
+	}
+
+	op, err := p.parseOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "in" || op == "not in" {
+		values, err := p.parseValueList()
+		if err != nil {
+			return nil, err
+		}
+		return compareCond{field: field.value, op: op, values: values}, nil
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return compareCond{field: field.value, op: op, values: []string{value}}, nil
+}
+
+func (p *parser) parseOperator() (string, error) {
+	tok, ok := p.next()
+	if !ok {
+		return "", fmt.Errorf("expected operator")
+	}
+	if tok.kind == tokenSymbol {
+		switch tok.value {
+		case "=", "!=", ">", "<", ">=", "<=":
+			return tok.value, nil
+		}
+		return "", fmt.Errorf("unexpected symbol %q", tok.value)
+	}
+	switch strings.ToLower(tok.value) {
+	case "like", "in":
+		return strings.ToLower(tok.value), nil
+	case "not":
+		next, ok := p.next()
+		if ok && next.kind == tokenIdent && (strings.EqualFold(next.value, "like") || strings.EqualFold(next.value, "in")) {
+			return "not " + strings.ToLower(next.value), nil
+		}
+	}
+	return "", fmt.Errorf("unknown operator %q", tok.value)
+}
+
+// parseValue 字串值或未加引號的數字
+func (p *parser) parseValue() (string, error) {
+	tok, ok := p.next()
+	if !ok {
+		return "", fmt.Errorf("expected value")
+	}
+	if tok.kind == tokenString {
+		return tok.value, nil
+	}
+	if tok.kind == tokenIdent {
+		if _, err := strconv.ParseFloat(tok.value, 64); err == nil {
+			return tok.value, nil
+		}
+	}
+	return "", fmt.Errorf("invalid value %q", tok.value)
+}
+
+func (p *parser) parseValueList() ([]string, error) {
+	if !p.isSymbol("(") {
+		return nil, fmt.Errorf("expected ( after in")
+	}
+	p.next()
+	values := make([]string, 0)
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.isSymbol(")") {
+			p.next()
+			return values, nil
+		}
+		if !p.isSymbol(",") {
+			return nil, fmt.Errorf("expected , or )")
+		}
+		p.next()
+	}
+}
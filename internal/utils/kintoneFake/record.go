@@ -0,0 +1,143 @@
+package kintoneFake
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+const (
+	fieldId       = "$id"
+	fieldRevision = "$revision"
+)
+
+// 欄位型別，只列出 fake server 需要特別處理的型別
+const (
+	TypeId          = "__ID__"
+	TypeRevision    = "__REVISION__"
+	TypeText        = "SINGLE_LINE_TEXT"
+	TypeSubtable    = "SUBTABLE"
+	TypeCreatedTime = "CREATED_TIME"
+	TypeUpdatedTime = "UPDATED_TIME"
+	TypeCreator     = "CREATOR"
+	TypeModifier    = "MODIFIER"
+)
+
+// Field kintone REST API 的欄位格式，Value 依欄位型別可能是字串、字串陣列、使用者物件或子表格列
+type Field struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// Record 欄位代碼對應欄位值，$id / $revision 由 server 管理
+type Record map[string]Field
+
+type record struct {
+	id       int
+	revision int
+	fields   Record
+}
+
+// toRecord 回傳含 $id / $revision 的複本，避免呼叫端修改 server 狀態
+func (r *record) toRecord() Record {
+	rec := make(Record, len(r.fields)+2)
+	for code, f := range r.fields {
+		rec[code] = Field{Type: f.Type, Value: normalize(f.Value)}
+	}
+	rec[fieldId] = Field{Type: TypeId, Value: strconv.Itoa(r.id)}
+	rec[fieldRevision] = Field{Type: TypeRevision, Value: strconv.Itoa(r.revision)}
+	return rec
+}
+
+// lookup 取得查詢用的欄位值，找不到時搜尋子表格內的欄位，沒有值則視為空字串
+func (r *record) lookup(code string) []string {
+	switch code {
+	case fieldId:
+		return []string{strconv.Itoa(r.id)}
+	case fieldRevision:
+		return []string{strconv.Itoa(r.revision)}
+	}
+
+	if f, ok := r.fields[code]; ok {
+		return valueStrings(f.Value)
+	}
+
+	values := make([]string, 0)
+	for _, f := range r.fields {
+		if f.Type != TypeSubtable {
+			continue
+		}
+		for _, row := range subtableRows(f.Value) {
+			if inner, ok := row[code]; ok {
+				values = append(values, valueStrings(inner)...)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return []string{""}
+	}
+	return values
+}
+
+// subtableRows 回傳子表格每一列的欄位 (欄位代碼對應 value)
+func subtableRows(v any) []map[string]any {
+	rows, _ := v.([]any)
+	result := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		rowMap, _ := row.(map[string]any)
+		fields, _ := rowMap["value"].(map[string]any)
+		values := make(map[string]any, len(fields))
+		for code, field := range fields {
+			fieldMap, _ := field.(map[string]any)
+			values[code] = fieldMap["value"]
+		}
+		result = append(result, values)
+	}
+	return result
+}
+
+func valueStrings(v any) []string {
+	switch val := v.(type) {
+	case nil:
+		return []string{""}
+	case string:
+		return []string{val}
+	case float64:
+		return []string{strconv.FormatFloat(val, 'f', -1, 64)}
+	case map[string]any:
+		code, _ := val["code"].(string)
+		return []string{code}
+	case []any:
+		values := make([]string, 0, len(val))
+		for _, item := range val {
+			values = append(values, valueStrings(item)...)
+		}
+		if len(values) == 0 {
+			return []string{""}
+		}
+		return values
+	}
+	return []string{""}
+}
+
+// normalize 以 json 轉換成 map[string]any / []any 等通用型別，同時做為深拷貝
+func normalize(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+func sortedRecords(records map[int]*record) []*record {
+	result := make([]*record, 0, len(records))
+	for _, rec := range records {
+		result = append(result, rec)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
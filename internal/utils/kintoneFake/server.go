@@ -0,0 +1,923 @@
+package kintoneFake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"jaystar/internal/config"
+	"jaystar/internal/constant/kintone"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kintone error code，只列出 fake server 會回傳的部分
+const (
+	CodeUnauthorized     = "CB_AU01"
+	CodeValidation       = "CB_VA01"
+	CodeAppNotFound      = "GAIA_AP01"
+	CodeRecordNotFound   = "GAIA_RE01"
+	CodeRevisionConflict = "GAIA_CO02"
+	CodeFieldNotFound    = "GAIA_IQ11"
+	CodeCursorNotFound   = "GAIA_RE20"
+)
+
+// kintone API 限制
+const (
+	maxGetLimit       = 500
+	defaultGetLimit   = 100
+	maxOffset         = 10000
+	maxWriteRecords   = 100
+	defaultCursorSize = 100
+	maxBulkRequests   = 20
+)
+
+// FakeUserAuthorization NewServer 的 ConfigEnv 使用的帳號密碼認證值
+const FakeUserAuthorization = "ZmFrZTpmYWtl"
+
+// Server 在 process 內模擬 kintone REST API 的 records / record / cursor / bulkRequest 端點
+// 資料只存在記憶體，每個測試應建立自己的 Server 並在結束時 Close
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	now       func() time.Time
+	apps      map[string]*app
+	cursors   map[string]*cursor
+	seq       int
+	faults    []*Fault
+	requests  []Request
+	errorSeq  int
+	subRowSeq int
+}
+
+type app struct {
+	spec    App
+	records map[int]*record
+	nextId  int
+}
+
+type cursor struct {
+	app     string
+	records []Record
+	size    int
+}
+
+// Fault 讓符合條件的請求回傳指定錯誤，用來測試重試與錯誤處理
+type Fault struct {
+	// Method / Path 空字串代表不限
+	Method     string
+	Path       string
+	StatusCode int
+	Code       string
+	Message    string
+	// RetryAfter 有設定時帶 Retry-After header
+	RetryAfter string
+	// Body 有設定時直接回傳此內容 (e.g. proxy 回傳的非 json 錯誤頁)
+	Body string
+	// Times 觸發次數，<= 0 視為 1
+	Times int
+	// Skip 符合條件的前 Skip 個請求照常處理，之後才觸發
+	Skip int
+}
+
+// Request server 收到的請求紀錄
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// NewServer 建立並啟動 fake server，註冊 DefaultApps 並載入 fixtures
+func NewServer() *Server {
+	s := &Server{
+		now:     time.Now,
+		apps:    make(map[string]*app),
+		cursors: make(map[string]*cursor),
+	}
+	for _, spec := range DefaultApps() {
+		s.AddApp(spec)
+	}
+	s.SeedFixtures()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ConfigEnv 指向此 server 的設定，應用程式 id 為 AppId
+func (s *Server) ConfigEnv() config.IConfigEnv {
+	return config.NewKintoneConfigEnv(s.URL, FakeUserAuthorization, AppId)
+}
+
+// SetNow 設定 createdAt / updatedAt 等自動欄位使用的時間
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// AddApp 註冊應用程式，已存在時清空資料
+func (s *Server) AddApp(spec App) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apps[spec.Id] = &app{spec: spec, records: make(map[int]*record), nextId: 1}
+}
+
+// Clear 清空應用程式的所有記錄
+func (s *Server) Clear(appId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.apps[appId]; ok {
+		a.records = make(map[int]*record)
+	}
+}
+
+// AddRecord 直接寫入記錄，rec 帶有 $id 時使用該 id，回傳記錄 id
+func (s *Server) AddRecord(appId string, rec Record) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.apps[appId]
+	if !ok {
+		panic(fmt.Sprintf("kintoneFake: app %s not found", appId))
+	}
+
+	id := a.nextId
+	if f, ok := rec[fieldId]; ok {
+		if v, err := strconv.Atoi(fmt.Sprint(f.Value)); err == nil {
+			id = v
+		}
+	}
+	values := make(map[string]any, len(rec))
+	for code, f := range rec {
+		if code == fieldId || code == fieldRevision {
+			continue
+		}
+		values[code] = f.Value
+	}
+	s.insert(a, id, values)
+	return id
+}
+
+// GetRecord 取得記錄複本 (含 $id / $revision)
+func (s *Server) GetRecord(appId string, id int) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.apps[appId]
+	if !ok {
+		return nil, false
+	}
+	rec, ok := a.records[id]
+	if !ok {
+		return nil, false
+	}
+	return rec.toRecord(), true
+}
+
+// Records 依 id 排序回傳應用程式所有記錄的複本
+func (s *Server) Records(appId string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.apps[appId]
+	if !ok {
+		return nil
+	}
+	records := make([]Record, 0, len(a.records))
+	for _, rec := range sortedRecords(a.records) {
+		records = append(records, rec.toRecord())
+	}
+	return records
+}
+
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.Times <= 0 {
+		f.Times = 1
+	}
+	s.faults = append(s.faults, &f)
+}
+
+// Requests 回傳目前為止收到的請求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, CodeValidation, "failed to read request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	if r.Header.Get(kintone.HeaderUserAuthorization) == "" && r.Header.Get(kintone.HeaderApiToken) == "" {
+		s.writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+		return
+	}
+
+	if f := s.takeFault(r.Method, r.URL.Path); f != nil {
+		if f.RetryAfter != "" {
+			w.Header().Set("Retry-After", f.RetryAfter)
+		}
+		if f.Body != "" {
+			w.WriteHeader(f.StatusCode)
+			_, _ = w.Write([]byte(f.Body))
+			return
+		}
+		s.writeError(w, f.StatusCode, f.Code, f.Message)
+		return
+	}
+
+	resp, kErr := s.dispatch(r.Method, r.URL.Path, r.URL.Query(), body)
+	if kErr != nil {
+		if kErr.bulkResults != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(kErr.status)
+			_ = json.NewEncoder(w).Encode(map[string]any{"results": kErr.bulkResults})
+			return
+		}
+		s.writeError(w, kErr.status, kErr.code, kErr.message)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) dispatch(method, path string, values url.Values, body []byte) (any, *apiError) {
+	switch method + " " + path {
+	case http.MethodGet + " " + kintone.RecordPath:
+		return s.getRecord(values)
+	case http.MethodPost + " " + kintone.RecordPath:
+		return s.postRecord(body)
+	case http.MethodPut + " " + kintone.RecordPath:
+		return s.putRecord(body)
+	case http.MethodGet + " " + kintone.RecordsPath:
+		return s.getRecords(values)
+	case http.MethodPost + " " + kintone.RecordsPath:
+		return s.postRecords(body)
+	case http.MethodPut + " " + kintone.RecordsPath:
+		return s.putRecords(body)
+	case http.MethodDelete + " " + kintone.RecordsPath:
+		return s.deleteRecords(values, body)
+	case http.MethodPost + " " + kintone.RecordsCursorPath:
+		return s.postCursor(body)
+	case http.MethodGet + " " + kintone.RecordsCursorPath:
+		return s.getCursor(values)
+	case http.MethodDelete + " " + kintone.RecordsCursorPath:
+		return s.deleteCursor(body)
+	case http.MethodPost + " " + kintone.BulkRequestPath:
+		return s.bulkRequest(body)
+	}
+	return nil, &apiError{status: http.StatusNotFound, code: CodeValidation, message: fmt.Sprintf("unsupported api %s %s", method, path)}
+}
+
+func (s *Server) takeFault(method, path string) *Fault {
+	for i, f := range s.faults {
+		if (f.Method != "" && f.Method != method) || (f.Path != "" && f.Path != path) {
+			continue
+		}
+		if f.Skip > 0 {
+			f.Skip--
+			return nil
+		}
+		f.Times--
+		if f.Times <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return f
+	}
+	return nil
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+	// bulkResults bulkRequest 失敗時的 results，只有失敗的請求帶有錯誤內容
+	bulkResults []any
+}
+
+func validationError(format string, args ...any) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: CodeValidation, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, code, message string) {
+	s.errorSeq++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"code":    code,
+		"id":      fmt.Sprintf("fake-error-%d", s.errorSeq),
+		"message": message,
+	})
+}
+
+func (s *Server) findApp(raw string) (*app, *apiError) {
+	a, ok := s.apps[raw]
+	if !ok {
+		return nil, &apiError{status: http.StatusNotFound, code: CodeAppNotFound, message: fmt.Sprintf("The app (ID: %s) not found.", raw)}
+	}
+	return a, nil
+}
+
+func (s *Server) findRecord(a *app, id int) (*record, *apiError) {
+	rec, ok := a.records[id]
+	if !ok {
+		return nil, &apiError{status: http.StatusNotFound, code: CodeRecordNotFound, message: fmt.Sprintf("The record (ID: %d) not found.", id)}
+	}
+	return rec, nil
+}
+
+// findByUpdateKey updateKey 必須剛好對應一筆記錄
+func (s *Server) findByUpdateKey(a *app, key *updateKey) (*record, *apiError) {
+	var found *record
+	for _, rec := range a.records {
+		if firstOf(rec.lookup(key.Field)) != rawString(key.Value) {
+			continue
+		}
+		if found != nil {
+			return nil, validationError("updateKey %s matches multiple records", key.Field)
+		}
+		found = rec
+	}
+	if found == nil {
+		return nil, &apiError{status: http.StatusNotFound, code: CodeRecordNotFound, message: fmt.Sprintf("The record (%s: %s) not found.", key.Field, rawString(key.Value))}
+	}
+	return found, nil
+}
+
+// checkRevision 未指定或 -1 時不檢查
+func checkRevision(rec *record, raw json.RawMessage) *apiError {
+	revision := rawString(raw)
+	if revision == "" || revision == "-1" {
+		return nil
+	}
+	if revision != strconv.Itoa(rec.revision) {
+		return &apiError{status: http.StatusConflict, code: CodeRevisionConflict, message: fmt.Sprintf("The revision is not the latest. Someone may update a record. (ID: %d)", rec.id)}
+	}
+	return nil
+}
+
+// rawString 數字或字串格式的 json 值轉為字串
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func rawInt(raw json.RawMessage) (int, bool) {
+	n, err := strconv.Atoi(rawString(raw))
+	return n, err == nil
+}
+
+func (s *Server) validateQuery(a *app, q *query) *apiError {
+	for _, field := range q.fields() {
+		if field == fieldId || field == fieldRevision {
+			continue
+		}
+		if _, ok := a.spec.Fields[field]; !ok {
+			return &apiError{status: http.StatusBadRequest, code: CodeFieldNotFound, message: fmt.Sprintf("The field (code: %s) not found.", field)}
+		}
+	}
+	return nil
+}
+
+// filter 回傳符合 query 條件並排序後的記錄 (未分頁)
+func (s *Server) filter(a *app, rawQuery string) ([]*record, *query, *apiError) {
+	q, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, nil, validationError("invalid query: %v", err)
+	}
+	if kErr := s.validateQuery(a, q); kErr != nil {
+		return nil, nil, kErr
+	}
+
+	matched := make([]*record, 0)
+	for _, rec := range sortedRecords(a.records) {
+		if q.match(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	q.sort(matched)
+	return matched, q, nil
+}
+
+func (s *Server) getRecord(values url.Values) (any, *apiError) {
+	a, kErr := s.findApp(values.Get(kintone.QueryApp))
+	if kErr != nil {
+		return nil, kErr
+	}
+	id, err := strconv.Atoi(values.Get(kintone.QueryId))
+	if err != nil {
+		return nil, validationError("id is required")
+	}
+	rec, kErr := s.findRecord(a, id)
+	if kErr != nil {
+		return nil, kErr
+	}
+	return map[string]any{"record": rec.toRecord()}, nil
+}
+
+func (s *Server) getRecords(values url.Values) (any, *apiError) {
+	a, kErr := s.findApp(values.Get(kintone.QueryApp))
+	if kErr != nil {
+		return nil, kErr
+	}
+	matched, q, kErr := s.filter(a, values.Get(kintone.QueryQuery))
+	if kErr != nil {
+		return nil, kErr
+	}
+
+	limit := defaultGetLimit
+	if q.hasLimit {
+		limit = q.limit
+	}
+	if limit > maxGetLimit {
+		return nil, validationError("limit must be less than or equal to %d", maxGetLimit)
+	}
+	if q.offset > maxOffset {
+		return nil, validationError("offset must be less than or equal to %d", maxOffset)
+	}
+
+	page := make([]Record, 0, limit)
+	for i := q.offset; i < len(matched) && len(page) < limit; i++ {
+		page = append(page, matched[i].toRecord())
+	}
+
+	var totalCount *string
+	if values.Get(kintone.TotalCount) == "true" {
+		count := strconv.Itoa(len(matched))
+		totalCount = &count
+	}
+
+	return map[string]any{"records": page, "totalCount": totalCount}, nil
+}
+
+type writeField struct {
+	Value any `json:"value"`
+}
+
+type updateKey struct {
+	Field string          `json:"field"`
+	Value json.RawMessage `json:"value"`
+}
+
+type recordUpdate struct {
+	Id        json.RawMessage       `json:"id"`
+	UpdateKey *updateKey            `json:"updateKey"`
+	Revision  json.RawMessage       `json:"revision"`
+	Record    map[string]writeField `json:"record"`
+}
+
+type recordReq struct {
+	App json.RawMessage `json:"app"`
+	recordUpdate
+}
+
+type recordsReq struct {
+	App     json.RawMessage   `json:"app"`
+	Records []json.RawMessage `json:"records"`
+}
+
+func (s *Server) postRecord(body []byte) (any, *apiError) {
+	req := &recordReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+	rec := s.insert(a, a.nextId, writeValues(req.Record))
+	return map[string]string{"id": strconv.Itoa(rec.id), "revision": strconv.Itoa(rec.revision)}, nil
+}
+
+func (s *Server) postRecords(body []byte) (any, *apiError) {
+	req := &recordsReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+	if len(req.Records) > maxWriteRecords {
+		return nil, validationError("records must be less than or equal to %d", maxWriteRecords)
+	}
+
+	values := make([]map[string]any, 0, len(req.Records))
+	for _, raw := range req.Records {
+		fields := make(map[string]writeField)
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, validationError("invalid record: %v", err)
+		}
+		values = append(values, writeValues(fields))
+	}
+
+	ids := make([]string, 0, len(values))
+	revisions := make([]string, 0, len(values))
+	for _, v := range values {
+		rec := s.insert(a, a.nextId, v)
+		ids = append(ids, strconv.Itoa(rec.id))
+		revisions = append(revisions, strconv.Itoa(rec.revision))
+	}
+	return map[string][]string{"ids": ids, "revisions": revisions}, nil
+}
+
+func (s *Server) putRecord(body []byte) (any, *apiError) {
+	req := &recordReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+	rec, kErr := s.resolveUpdate(a, &req.recordUpdate)
+	if kErr != nil {
+		return nil, kErr
+	}
+	s.update(a, rec, writeValues(req.Record))
+	return map[string]string{"revision": strconv.Itoa(rec.revision)}, nil
+}
+
+// putRecords 與 kintone 相同，任一筆失敗時全部不更新
+func (s *Server) putRecords(body []byte) (any, *apiError) {
+	req := &recordsReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+	if len(req.Records) > maxWriteRecords {
+		return nil, validationError("records must be less than or equal to %d", maxWriteRecords)
+	}
+
+	targets := make([]*record, 0, len(req.Records))
+	updates := make([]map[string]any, 0, len(req.Records))
+	for _, raw := range req.Records {
+		update := &recordUpdate{}
+		if err := json.Unmarshal(raw, update); err != nil {
+			return nil, validationError("invalid record: %v", err)
+		}
+		rec, kErr := s.resolveUpdate(a, update)
+		if kErr != nil {
+			return nil, kErr
+		}
+		targets = append(targets, rec)
+		updates = append(updates, writeValues(update.Record))
+	}
+
+	results := make([]map[string]string, 0, len(targets))
+	for i, rec := range targets {
+		s.update(a, rec, updates[i])
+		results = append(results, map[string]string{"id": strconv.Itoa(rec.id), "revision": strconv.Itoa(rec.revision)})
+	}
+	return map[string]any{"records": results}, nil
+}
+
+func (s *Server) resolveUpdate(a *app, update *recordUpdate) (*record, *apiError) {
+	var (
+		rec  *record
+		kErr *apiError
+	)
+	if update.UpdateKey != nil {
+		rec, kErr = s.findByUpdateKey(a, update.UpdateKey)
+	} else {
+		id, ok := rawInt(update.Id)
+		if !ok {
+			return nil, validationError("id or updateKey is required")
+		}
+		rec, kErr = s.findRecord(a, id)
+	}
+	if kErr != nil {
+		return nil, kErr
+	}
+	if kErr := checkRevision(rec, update.Revision); kErr != nil {
+		return nil, kErr
+	}
+	return rec, nil
+}
+
+type deleteReq struct {
+	App       json.RawMessage   `json:"app"`
+	Ids       []json.RawMessage `json:"ids"`
+	Revisions []json.RawMessage `json:"revisions"`
+}
+
+// deleteRecords 支援 query string (ids[0]=1) 與 json body 兩種格式
+func (s *Server) deleteRecords(values url.Values, body []byte) (any, *apiError) {
+	req := &deleteReq{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, req); err != nil {
+			return nil, validationError("invalid request body: %v", err)
+		}
+	} else {
+		req.App = json.RawMessage(strconv.Quote(values.Get(kintone.QueryApp)))
+		for i := 0; ; i++ {
+			id := values.Get(fmt.Sprintf("ids[%d]", i))
+			if id == "" {
+				break
+			}
+			req.Ids = append(req.Ids, json.RawMessage(strconv.Quote(id)))
+			if revision := values.Get(fmt.Sprintf("revisions[%d]", i)); revision != "" {
+				req.Revisions = append(req.Revisions, json.RawMessage(strconv.Quote(revision)))
+			}
+		}
+	}
+
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+	if len(req.Ids) > maxWriteRecords {
+		return nil, validationError("ids must be less than or equal to %d", maxWriteRecords)
+	}
+
+	targets := make([]*record, 0, len(req.Ids))
+	for i, raw := range req.Ids {
+		id, ok := rawInt(raw)
+		if !ok {
+			return nil, validationError("invalid id %s", string(raw))
+		}
+		rec, kErr := s.findRecord(a, id)
+		if kErr != nil {
+			return nil, kErr
+		}
+		if i < len(req.Revisions) {
+			if kErr := checkRevision(rec, req.Revisions[i]); kErr != nil {
+				return nil, kErr
+			}
+		}
+		targets = append(targets, rec)
+	}
+
+	for _, rec := range targets {
+		delete(a.records, rec.id)
+	}
+	return struct{}{}, nil
+}
+
+func (s *Server) postCursor(body []byte) (any, *apiError) {
+	req := &struct {
+		App   json.RawMessage `json:"app"`
+		Query string          `json:"query"`
+		Size  json.RawMessage `json:"size"`
+	}{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	a, kErr := s.findApp(rawString(req.App))
+	if kErr != nil {
+		return nil, kErr
+	}
+
+	size := defaultCursorSize
+	if n, ok := rawInt(req.Size); ok {
+		size = n
+	}
+	if size <= 0 || size > maxGetLimit {
+		return nil, validationError("size must be between 1 and %d", maxGetLimit)
+	}
+
+	matched, q, kErr := s.filter(a, req.Query)
+	if kErr != nil {
+		return nil, kErr
+	}
+	if q.hasLimit || q.hasOffset {
+		return nil, validationError("limit and offset are not allowed in cursor query")
+	}
+
+	records := make([]Record, 0, len(matched))
+	for _, rec := range matched {
+		records = append(records, rec.toRecord())
+	}
+
+	s.seq++
+	id := fmt.Sprintf("fake-cursor-%d", s.seq)
+	s.cursors[id] = &cursor{app: a.spec.Id, records: records, size: size}
+
+	return map[string]string{"id": id, "totalCount": strconv.Itoa(len(records))}, nil
+}
+
+// getCursor 讀完最後一批後刪除 cursor
+func (s *Server) getCursor(values url.Values) (any, *apiError) {
+	id := values.Get(kintone.QueryId)
+	c, ok := s.cursors[id]
+	if !ok {
+		return nil, &apiError{status: http.StatusBadRequest, code: CodeCursorNotFound, message: fmt.Sprintf("The cursor (ID: %s) not found.", id)}
+	}
+
+	n := c.size
+	if n > len(c.records) {
+		n = len(c.records)
+	}
+	records := c.records[:n]
+	c.records = c.records[n:]
+
+	next := len(c.records) > 0
+	if !next {
+		delete(s.cursors, id)
+	}
+	return map[string]any{"records": records, "next": next}, nil
+}
+
+func (s *Server) deleteCursor(body []byte) (any, *apiError) {
+	req := &struct {
+		Id string `json:"id"`
+	}{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	if _, ok := s.cursors[req.Id]; !ok {
+		return nil, &apiError{status: http.StatusBadRequest, code: CodeCursorNotFound, message: fmt.Sprintf("The cursor (ID: %s) not found.", req.Id)}
+	}
+	delete(s.cursors, req.Id)
+	return struct{}{}, nil
+}
+
+type bulkReq struct {
+	Requests []struct {
+		Method  string          `json:"method"`
+		Api     string          `json:"api"`
+		Payload json.RawMessage `json:"payload"`
+	} `json:"requests"`
+}
+
+// bulkRequest 依序執行各請求，任一請求失敗時還原所有應用程式的變更
+func (s *Server) bulkRequest(body []byte) (any, *apiError) {
+	req := &bulkReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, validationError("invalid request body: %v", err)
+	}
+	if len(req.Requests) == 0 || len(req.Requests) > maxBulkRequests {
+		return nil, validationError("requests must be between 1 and %d", maxBulkRequests)
+	}
+	for _, sub := range req.Requests {
+		if sub.Method == http.MethodGet || sub.Api == kintone.BulkRequestPath || sub.Api == kintone.RecordsCursorPath {
+			return nil, validationError("unsupported api in bulkRequest %s %s", sub.Method, sub.Api)
+		}
+	}
+
+	snapshot := s.snapshot()
+	results := make([]any, 0, len(req.Requests))
+	for i, sub := range req.Requests {
+		resp, kErr := s.dispatch(sub.Method, sub.Api, nil, sub.Payload)
+		if kErr != nil {
+			s.apps = snapshot
+			s.errorSeq++
+			kErr.bulkResults = make([]any, len(req.Requests))
+			for j := range kErr.bulkResults {
+				kErr.bulkResults[j] = struct{}{}
+			}
+			kErr.bulkResults[i] = map[string]string{
+				"code":    kErr.code,
+				"id":      fmt.Sprintf("fake-error-%d", s.errorSeq),
+				"message": kErr.message,
+			}
+			return nil, kErr
+		}
+		results = append(results, resp)
+	}
+	return map[string]any{"results": results}, nil
+}
+
+// snapshot 複製所有應用程式的記錄，欄位值在更新時會整個替換，所以只需複製到 fields map
+func (s *Server) snapshot() map[string]*app {
+	apps := make(map[string]*app, len(s.apps))
+	for id, a := range s.apps {
+		records := make(map[int]*record, len(a.records))
+		for recordId, rec := range a.records {
+			fields := make(Record, len(rec.fields))
+			for code, f := range rec.fields {
+				fields[code] = f
+			}
+			records[recordId] = &record{id: rec.id, revision: rec.revision, fields: fields}
+		}
+		apps[id] = &app{spec: a.spec, records: records, nextId: a.nextId}
+	}
+	return apps
+}
+
+func writeValues(fields map[string]writeField) map[string]any {
+	values := make(map[string]any, len(fields))
+	for code, f := range fields {
+		values[code] = f.Value
+	}
+	return values
+}
+
+// insert 自動欄位 (建立時間/建立人等) 沒有指定值時由 server 填入
+func (s *Server) insert(a *app, id int, values map[string]any) *record {
+	rec := &record{id: id, revision: 1, fields: make(Record)}
+	s.apply(a, rec, values)
+
+	now := s.now().UTC().Truncate(time.Second).Format(time.RFC3339)
+	for code, fieldType := range a.spec.Fields {
+		if _, ok := rec.fields[code]; ok {
+			continue
+		}
+		switch fieldType {
+		case TypeCreatedTime, TypeUpdatedTime:
+			rec.fields[code] = Field{Type: fieldType, Value: now}
+		case TypeCreator, TypeModifier:
+			rec.fields[code] = Field{Type: fieldType, Value: normalize(fakeUser)}
+		}
+	}
+
+	a.records[id] = rec
+	if id >= a.nextId {
+		a.nextId = id + 1
+	}
+	return rec
+}
+
+// update 更新欄位值並遞增 revision，更新時間/更新人一律由 server 改寫
+func (s *Server) update(a *app, rec *record, values map[string]any) {
+	s.apply(a, rec, values)
+	rec.revision++
+
+	now := s.now().UTC().Truncate(time.Second).Format(time.RFC3339)
+	for code, fieldType := range a.spec.Fields {
+		switch fieldType {
+		case TypeUpdatedTime:
+			rec.fields[code] = Field{Type: fieldType, Value: now}
+		case TypeModifier:
+			rec.fields[code] = Field{Type: fieldType, Value: normalize(fakeUser)}
+		}
+	}
+}
+
+// apply 依應用程式欄位設定補上型別，子表格沒有 id 的列會配發新的 id
+func (s *Server) apply(a *app, rec *record, values map[string]any) {
+	for code, v := range values {
+		fieldType, ok := a.spec.Fields[code]
+		if !ok {
+			fieldType = TypeText
+			if existing, ok := rec.fields[code]; ok {
+				fieldType = existing.Type
+			}
+		}
+
+		value := normalize(v)
+		if fieldType == TypeSubtable {
+			value = s.applySubtable(a, value)
+		}
+		rec.fields[code] = Field{Type: fieldType, Value: value}
+	}
+}
+
+func (s *Server) applySubtable(a *app, value any) any {
+	rows, _ := value.([]any)
+	for _, row := range rows {
+		rowMap, ok := row.(map[string]any)
+		if !ok {
+			continue
+		}
+		if id, _ := rowMap["id"].(string); id == "" {
+			s.subRowSeq++
+			rowMap["id"] = strconv.Itoa(1000000 + s.subRowSeq)
+		}
+		fields, _ := rowMap["value"].(map[string]any)
+		for code, field := range fields {
+			fieldMap, ok := field.(map[string]any)
+			if !ok {
+				continue
+			}
+			if t, _ := fieldMap["type"].(string); t == "" {
+				fieldType, ok := a.spec.Fields[code]
+				if !ok {
+					fieldType = TypeText
+				}
+				fieldMap["type"] = fieldType
+			}
+		}
+	}
+	return rows
+}
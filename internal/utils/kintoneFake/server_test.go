@@ -0,0 +1,279 @@
+package kintoneFake
+
+import (
+	"bytes"
+	"encoding/json"
+	"jaystar/internal/constant/kintone"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doRequest(t *testing.T, s *Server, method, path string, query url.Values, body any) (int, map[string]any) {
+	t.Helper()
+	u := s.URL + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	require.NoError(t, err)
+	req.Header.Set(kintone.HeaderUserAuthorization, FakeUserAuthorization)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	resp := map[string]any{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&resp))
+	return res.StatusCode, resp
+}
+
+func recordIds(resp map[string]any) []string {
+	ids := make([]string, 0)
+	records, _ := resp["records"].([]any)
+	for _, rec := range records {
+		id := rec.(map[string]any)[fieldId].(map[string]any)["value"].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestGetRecordsQuery(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantIds   []string
+		wantTotal string
+	}{
+		{name: "default order $id desc", query: "", wantIds: []string{"1929", "1801", "1730"}, wantTotal: "3"},
+		{name: "eq", query: `studentName = "` + StudentA + `" order by $id asc`, wantIds: []string{"1730", "1929"}, wantTotal: "2"},
+		{name: "date range", query: `chargingDate >= "2024-02-21T00:00:00Z" and chargingDate <= "2024-03-01T00:00:00Z"`, wantIds: []string{"1801"}, wantTotal: "1"},
+		{name: "or group", query: `(studentName = "` + StudentB + `" or $id = "1730") order by $id desc`, wantIds: []string{"1801", "1730"}, wantTotal: "2"},
+		{name: "in and not in", query: `$id in ("1730", "1801") and $id not in ("1801")`, wantIds: []string{"1730"}, wantTotal: "1"},
+		{name: "paging", query: `order by $id asc limit 1 offset 1`, wantIds: []string{"1801"}, wantTotal: "3"},
+		{name: "checkbox like", query: `chargingMethod in ("匯款ATM") and studentName like "` + StudentBName + `"`, wantIds: []string{"1801"}, wantTotal: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := doRequest(t, s, http.MethodGet, kintone.RecordsPath, url.Values{
+				kintone.QueryApp:   {AppId.DepositRecord},
+				kintone.QueryQuery: {tt.query},
+				kintone.TotalCount: {"true"},
+			}, nil)
+			require.Equal(t, http.StatusOK, status, resp)
+			assert.Equal(t, tt.wantIds, recordIds(resp))
+			assert.Equal(t, tt.wantTotal, resp["totalCount"])
+		})
+	}
+}
+
+func TestGetRecordsSubtableField(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	status, resp := doRequest(t, s, http.MethodGet, kintone.RecordsPath, url.Values{
+		kintone.QueryApp:   {AppId.ScheduleRecord},
+		kintone.QueryQuery: {`studentName in ("` + StudentD + `", "` + StudentF + `")`},
+	}, nil)
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, []string{"7452"}, recordIds(resp))
+	assert.Nil(t, resp["totalCount"])
+}
+
+func TestGetRecordsError(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	tests := []struct {
+		name       string
+		app        string
+		query      string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "app not found", app: "999", wantStatus: http.StatusNotFound, wantCode: CodeAppNotFound},
+		{name: "unknown field", app: AppId.DepositRecord, query: `unknown = "1"`, wantStatus: http.StatusBadRequest, wantCode: CodeFieldNotFound},
+		{name: "syntax error", app: AppId.DepositRecord, query: `studentName = `, wantStatus: http.StatusBadRequest, wantCode: CodeValidation},
+		{name: "limit over 500", app: AppId.DepositRecord, query: `limit 501`, wantStatus: http.StatusBadRequest, wantCode: CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := doRequest(t, s, http.MethodGet, kintone.RecordsPath, url.Values{
+				kintone.QueryApp:   {tt.app},
+				kintone.QueryQuery: {tt.query},
+			}, nil)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantCode, resp["code"])
+		})
+	}
+}
+
+func TestPutRecordsRevision(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	update := func(id, revision string) (int, map[string]any) {
+		return doRequest(t, s, http.MethodPut, kintone.RecordsPath, nil, map[string]any{
+			"app": AppId.ReduceRecord,
+			"records": []map[string]any{
+				{"id": "23441", "record": map[string]any{"studentName": map[string]any{"value": "new"}}},
+				{"id": id, "revision": revision, "record": map[string]any{"studentName": map[string]any{"value": "new"}}},
+			},
+		})
+	}
+
+	status, resp := update("23448", "2")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, CodeRevisionConflict, resp["code"])
+	rec, _ := s.GetRecord(AppId.ReduceRecord, 23441)
+	assert.Equal(t, StudentA, rec["studentName"].Value, "failed bulk update must not apply any record")
+
+	status, resp = update("23448", "1")
+	require.Equal(t, http.StatusOK, status, resp)
+	rec, _ = s.GetRecord(AppId.ReduceRecord, 23448)
+	assert.Equal(t, "new", rec["studentName"].Value)
+	assert.Equal(t, "2", rec[fieldRevision].Value)
+	assert.NotEqual(t, fixtureTime, rec["updatedAt"].Value)
+}
+
+func TestPutRecordByUpdateKey(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	status, resp := doRequest(t, s, http.MethodPut, kintone.RecordPath, nil, map[string]any{
+		"app":       AppId.PointCard,
+		"updateKey": map[string]string{"field": "studentName", "value": StudentB},
+		"record":    map[string]any{"clearPoints": map[string]any{"value": "2"}},
+	})
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, "2", resp["revision"])
+
+	rec, _ := s.GetRecord(AppId.PointCard, 1440)
+	assert.Equal(t, "2", rec["clearPoints"].Value)
+	assert.Equal(t, "NUMBER", rec["clearPoints"].Type)
+}
+
+func TestPostAndDeleteRecords(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	status, resp := doRequest(t, s, http.MethodPost, kintone.RecordsPath, nil, map[string]any{
+		"app": AppId.SemesterSettleRecord,
+		"records": []map[string]any{
+			{"studentName": map[string]any{"value": StudentA}, "clearPoints": map[string]any{"value": "1"}},
+		},
+	})
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, []any{"302"}, resp["ids"])
+
+	rec, ok := s.GetRecord(AppId.SemesterSettleRecord, 302)
+	require.True(t, ok)
+	assert.NotEmpty(t, rec["建立時間"].Value)
+
+	status, resp = doRequest(t, s, http.MethodDelete, kintone.RecordsPath, nil, map[string]any{
+		"app": AppId.SemesterSettleRecord,
+		"ids": []int{301, 302},
+	})
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Empty(t, s.Records(AppId.SemesterSettleRecord))
+}
+
+func TestCursor(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	status, resp := doRequest(t, s, http.MethodPost, kintone.RecordsCursorPath, nil, map[string]any{
+		"app":   AppId.StudentInfo,
+		"query": `order by $id asc`,
+		"size":  4,
+	})
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, "6", resp["totalCount"])
+	cursorId := resp["id"].(string)
+
+	status, resp = doRequest(t, s, http.MethodGet, kintone.RecordsCursorPath, url.Values{kintone.QueryId: {cursorId}}, nil)
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, []string{"101", "102", "103", "104"}, recordIds(resp))
+	assert.Equal(t, true, resp["next"])
+
+	status, resp = doRequest(t, s, http.MethodGet, kintone.RecordsCursorPath, url.Values{kintone.QueryId: {cursorId}}, nil)
+	require.Equal(t, http.StatusOK, status, resp)
+	assert.Equal(t, []string{"105", "106"}, recordIds(resp))
+	assert.Equal(t, false, resp["next"])
+
+	status, resp = doRequest(t, s, http.MethodGet, kintone.RecordsCursorPath, url.Values{kintone.QueryId: {cursorId}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, CodeCursorNotFound, resp["code"])
+}
+
+func TestInjectFault(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFault(Fault{Method: http.MethodGet, Path: kintone.RecordsPath, StatusCode: http.StatusTooManyRequests, Code: "GAIA_TM12", RetryAfter: "1"})
+
+	query := url.Values{kintone.QueryApp: {AppId.StudentInfo}}
+	status, resp := doRequest(t, s, http.MethodGet, kintone.RecordsPath, query, nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "GAIA_TM12", resp["code"])
+
+	status, _ = doRequest(t, s, http.MethodGet, kintone.RecordsPath, query, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, s.Requests(), 2)
+}
+
+func TestBulkRequest(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	bulk := func(reduceRevision string) (int, map[string]any) {
+		return doRequest(t, s, http.MethodPost, kintone.BulkRequestPath, nil, map[string]any{
+			"requests": []map[string]any{
+				{"method": http.MethodPut, "api": kintone.RecordsPath, "payload": map[string]any{
+					"app":     AppId.DepositRecord,
+					"records": []map[string]any{{"id": 1730, "record": map[string]any{"studentName": map[string]any{"value": "new"}}}},
+				}},
+				{"method": http.MethodPut, "api": kintone.RecordsPath, "payload": map[string]any{
+					"app":     AppId.ReduceRecord,
+					"records": []map[string]any{{"id": 23440, "revision": reduceRevision, "record": map[string]any{"studentName": map[string]any{"value": "new"}}}},
+				}},
+			},
+		})
+	}
+
+	status, resp := bulk("2")
+	assert.Equal(t, http.StatusConflict, status)
+	results := resp["results"].([]any)
+	require.Len(t, results, 2)
+	assert.Empty(t, results[0])
+	assert.Equal(t, CodeRevisionConflict, results[1].(map[string]any)["code"])
+	rec, _ := s.GetRecord(AppId.DepositRecord, 1730)
+	assert.Equal(t, StudentA, rec["studentName"].Value, "failed bulk request must not apply any request")
+	assert.Equal(t, "1", rec[fieldRevision].Value)
+
+	status, resp = bulk("1")
+	require.Equal(t, http.StatusOK, status, resp)
+	results = resp["results"].([]any)
+	require.Len(t, results, 2)
+	assert.Equal(t, []any{map[string]any{"id": "23440", "revision": "2"}}, results[1].(map[string]any)["records"])
+	rec, _ = s.GetRecord(AppId.DepositRecord, 1730)
+	assert.Equal(t, "new", rec["studentName"].Value)
+	rec, _ = s.GetRecord(AppId.ReduceRecord, 23440)
+	assert.Equal(t, "new", rec["studentName"].Value)
+}
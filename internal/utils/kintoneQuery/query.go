@@ -0,0 +1,222 @@
+package kintoneQuery
+
+import (
+	"fmt"
+	kintoneConst "jaystar/internal/constant/kintone"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Operator string
+
+const (
+	OpEq      Operator = "="
+	OpNotEq   Operator = "!="
+	OpGt      Operator = ">"
+	OpLt      Operator = "<"
+	OpGe      Operator = ">="
+	OpLe      Operator = "<="
+	OpIn      Operator = "in"
+	OpNotIn   Operator = "not in"
+	OpLike    Operator = "like"
+	OpNotLike Operator = "not like"
+)
+
+type Direction string
+
+const (
+	Asc  Direction = "asc"
+	Desc Direction = "desc"
+)
+
+// Value 可用於查詢條件的值型別，time.Time 以 RFC3339 格式輸出
+type Value interface {
+	~string | ~int | ~int64 | ~float64 | time.Time
+}
+
+// Condition 查詢條件，String 回傳空字串代表沒有條件
+type Condition interface {
+	String() string
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quote 值一律加上雙引號並跳脫 \ 與 "
+func quote[T Value](v T) string {
+	var s string
+	switch val := any(v).(type) {
+	case time.Time:
+		s = val.Format(time.RFC3339)
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(val)
+	}
+	return `"` + escaper.Replace(s) + `"`
+}
+
+type comparison struct {
+	field string
+	op    Operator
+	value string
+}
+
+func (c comparison) String() string {
+	return fmt.Sprintf("%s %s %s", c.field, c.op, c.value)
+}
+
+func compare[T Value](field string, op Operator, v T) Condition {
+	return comparison{field: field, op: op, value: quote(v)}
+}
+
+func Eq[T Value](field string, v T) Condition           { return compare(field, OpEq, v) }
+func NotEq[T Value](field string, v T) Condition        { return compare(field, OpNotEq, v) }
+func Gt[T Value](field string, v T) Condition           { return compare(field, OpGt, v) }
+func Lt[T Value](field string, v T) Condition           { return compare(field, OpLt, v) }
+func Ge[T Value](field string, v T) Condition           { return compare(field, OpGe, v) }
+func Le[T Value](field string, v T) Condition           { return compare(field, OpLe, v) }
+func Like(field string, v string) Condition             { return compare(field, OpLike, v) }
+func NotLike(field string, v string) Condition          { return compare(field, OpNotLike, v) }
+func In[T Value](field string, values []T) Condition    { return inCondition(field, OpIn, values) }
+func NotIn[T Value](field string, values []T) Condition { return inCondition(field, OpNotIn, values) }
+
+type multiValue struct {
+	field  string
+	op     Operator
+	values []string
+}
+
+// String 空的 in / not in 條件 kintone 無法解析，視為沒有條件
+func (c multiValue) String() string {
+	if len(c.values) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s %s (%s)", c.field, c.op, strings.Join(c.values, ", "))
+}
+
+func inCondition[T Value](field string, op Operator, values []T) Condition {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, quote(v))
+	}
+	return multiValue{field: field, op: op, values: quoted}
+}
+
+type group struct {
+	op    string
+	conds []Condition
+}
+
+// String 忽略空條件，有多個條件時巢狀群組會加上括號
+func (g group) String() string {
+	type part struct {
+		s       string
+		isGroup bool
+	}
+	parts := make([]part, 0, len(g.conds))
+	for _, cond := range g.conds {
+		if cond == nil {
+			continue
+		}
+		s := cond.String()
+		if s == "" {
+			continue
+		}
+		_, isGroup := cond.(group)
+		parts = append(parts, part{s: s, isGroup: isGroup})
+	}
+
+	if len(parts) == 1 {
+		return parts[0].s
+	}
+
+	strs := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.isGroup {
+			p.s = "(" + p.s + ")"
+		}
+		strs = append(strs, p.s)
+	}
+	return strings.Join(strs, " "+g.op+" ")
+}
+
+func And(conds ...Condition) Condition {
+	return group{op: "and", conds: conds}
+}
+
+func Or(conds ...Condition) Condition {
+	return group{op: "or", conds: conds}
+}
+
+type Order struct {
+	Field     string
+	Direction Direction
+}
+
+// Query kintone 記錄查詢字串，Where 多次呼叫的條件以 and 串接，輸出順序與呼叫順序一致
+type Query struct {
+	conds  []Condition
+	orders []Order
+	limit  int
+	offset int
+}
+
+func New(conds ...Condition) *Query {
+	return &Query{conds: conds}
+}
+
+func (q *Query) Where(conds ...Condition) *Query {
+	q.conds = append(q.conds, conds...)
+	return q
+}
+
+func (q *Query) OrderBy(field string, direction Direction) *Query {
+	q.orders = append(q.orders, Order{Field: field, Direction: direction})
+	return q
+}
+
+// Limit <= 0 時不輸出
+func (q *Query) Limit(limit int) *Query {
+	q.limit = limit
+	return q
+}
+
+// Offset <= 0 時不輸出
+func (q *Query) Offset(offset int) *Query {
+	q.offset = offset
+	return q
+}
+
+func (q *Query) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(q.StringWithoutPaging())
+	if q.limit > 0 {
+		writeClause(&sb, fmt.Sprintf("%s %d", kintoneConst.QueryQueryLimit, q.limit))
+	}
+	if q.offset > 0 {
+		writeClause(&sb, fmt.Sprintf("%s %d", kintoneConst.QueryQueryOffset, q.offset))
+	}
+	return sb.String()
+}
+
+// StringWithoutPaging cursor API 的 query 不可帶 limit / offset
+func (q *Query) StringWithoutPaging() string {
+	sb := strings.Builder{}
+	sb.WriteString(group{op: "and", conds: q.conds}.String())
+	if len(q.orders) > 0 {
+		orders := make([]string, 0, len(q.orders))
+		for _, o := range q.orders {
+			orders = append(orders, fmt.Sprintf("%s %s", o.Field, o.Direction))
+		}
+		writeClause(&sb, fmt.Sprintf("%s %s", kintoneConst.QueryQueryOrderBy, strings.Join(orders, ", ")))
+	}
+	return sb.String()
+}
+
+func writeClause(sb *strings.Builder, clause string) {
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(clause)
+}
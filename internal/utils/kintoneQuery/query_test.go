@@ -0,0 +1,94 @@
+package kintoneQuery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryString(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query *Query
+		want  string
+	}{
+		{
+			name:  "empty",
+			query: New(),
+			want:  "",
+		},
+		{
+			name:  "paging only",
+			query: New().Limit(500).Offset(1000),
+			want:  "limit 500 offset 1000",
+		},
+		{
+			name:  "zero paging omitted",
+			query: New(Eq("studentName", "王小明/0912345678")).Limit(0).Offset(0),
+			want:  `studentName = "王小明/0912345678"`,
+		},
+		{
+			name: "and keeps call order",
+			query: New().
+				Where(Eq("studentName", "a")).
+				Where(Ge("chargingDate", date), Le("chargingDate", date.AddDate(0, 6, 0))).
+				Limit(100),
+			want: `studentName = "a" and chargingDate >= "2024-03-01T00:00:00Z" and chargingDate <= "2024-09-01T00:00:00Z" limit 100`,
+		},
+		{
+			name:  "escape quote and backslash",
+			query: New(Eq("studentName", `王"小\明`)),
+			want:  `studentName = "王\"小\\明"`,
+		},
+		{
+			name:  "in over slice",
+			query: New(In("studentName", []string{"a", `b"c`})),
+			want:  `studentName in ("a", "b\"c")`,
+		},
+		{
+			name:  "empty in ignored",
+			query: New(In("studentName", []string{}), Eq("mode", "semester")),
+			want:  `mode = "semester"`,
+		},
+		{
+			name:  "numbers",
+			query: New(Gt("restPoints", 1.5), NotIn("$id", []int{1, 2})),
+			want:  `restPoints > "1.5" and $id not in ("1", "2")`,
+		},
+		{
+			name:  "or group wrapped in parentheses",
+			query: New(Eq("mode", "semester"), Or(Eq("studentName", "a"), Like("parentPhone", "0912"))),
+			want:  `mode = "semester" and (studentName = "a" or parentPhone like "0912")`,
+		},
+		{
+			name:  "single element group not wrapped",
+			query: New(Or(Eq("studentName", "a"), In("parentPhone", []string{}))),
+			want:  `studentName = "a"`,
+		},
+		{
+			name:  "nested groups",
+			query: New(Or(And(Eq("a", "1"), Eq("b", "2")), And(Eq("c", "3"), NotLike("d", "4")))),
+			want:  `(a = "1" and b = "2") or (c = "3" and d not like "4")`,
+		},
+		{
+			name:  "order by",
+			query: New(NotEq("studentName", "")).OrderBy("classTime", Asc).OrderBy("$id", Desc).Limit(10).Offset(20),
+			want:  `studentName != "" order by classTime asc, $id desc limit 10 offset 20`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryStringWithoutPaging(t *testing.T) {
+	q := New(Eq("studentName", "a")).OrderBy("$id", Asc).Limit(500).Offset(500)
+	want := `studentName = "a" order by $id asc`
+	if got := q.StringWithoutPaging(); got != want {
+		t.Errorf("StringWithoutPaging() = %s, want %s", got, want)
+	}
+}
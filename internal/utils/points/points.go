@@ -0,0 +1,203 @@
+package points
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decimals 點數保留的小數位數
+const Decimals = 2
+
+const unit = 100 // 10^Decimals
+
+// Points 以 0.01 點為單位的定點數，加減不會有浮點數誤差
+// 超過兩位小數的值一律四捨五入 (0.5 遠離 0)，例如 0.125 => 0.13、-0.125 => -0.13
+// 只能透過 FromInt、FromFloat、Parse 建立，避免整數常數被誤當成 0.01 點
+type Points struct {
+	hundredths int64
+}
+
+func FromInt(n int) Points {
+	return Points{hundredths: int64(n) * unit}
+}
+
+// FromFloat 只用在來源本身就是浮點數的情況 (e.g. 指數表示法的字串)
+func FromFloat(f float64) Points {
+	return Points{hundredths: int64(math.Round(f * unit))}
+}
+
+// Parse 以十進位字串解析，不經過浮點數
+func Parse(s string) (Points, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Points{}, fmt.Errorf("points: invalid value %q", raw)
+		}
+		return FromFloat(f), nil
+	}
+
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return Points{}, fmt.Errorf("points: invalid value %q", raw)
+	}
+
+	var value int64
+	if intPart != "" {
+		n, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || n > math.MaxInt64/unit-1 {
+			return Points{}, fmt.Errorf("points: value out of range %q", raw)
+		}
+		value = n * unit
+	}
+
+	for i := 0; i < Decimals; i++ {
+		digit := int64(0)
+		if i < len(fracPart) {
+			digit = int64(fracPart[i] - '0')
+		}
+		value += digit * pow10(Decimals-1-i)
+	}
+	if len(fracPart) > Decimals && fracPart[Decimals] >= '5' {
+		value++
+	}
+
+	if neg {
+		value = -value
+	}
+	return Points{hundredths: value}, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+func (p Points) Add(other Points) Points {
+	return Points{hundredths: p.hundredths + other.hundredths}
+}
+
+func (p Points) Sub(other Points) Points {
+	return Points{hundredths: p.hundredths - other.hundredths}
+}
+
+func (p Points) Neg() Points {
+	return Points{hundredths: -p.hundredths}
+}
+
+// Cmp p < other 時回傳 -1，相等時回傳 0，p > other 時回傳 1
+func (p Points) Cmp(other Points) int {
+	switch {
+	case p.hundredths < other.hundredths:
+		return -1
+	case p.hundredths > other.hundredths:
+		return 1
+	}
+	return 0
+}
+
+func (p Points) IsZero() bool {
+	return p.hundredths == 0
+}
+
+func (p Points) Float64() float64 {
+	return float64(p.hundredths) / unit
+}
+
+// String 去掉小數點後多餘的 0，例如 12、12.5、-0.25
+func (p Points) String() string {
+	value := p.hundredths
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	intPart, fracPart := value/unit, value%unit
+	if fracPart == 0 {
+		return sign + strconv.FormatInt(intPart, 10)
+	}
+
+	frac := strings.TrimRight(fmt.Sprintf("%0*d", Decimals, fracPart), "0")
+	return sign + strconv.FormatInt(intPart, 10) + "." + frac
+}
+
+// MarshalJSON 輸出成 json 數字
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON 接受 json 數字或字串
+func (p *Points) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Value 以十進位字串寫入 db 的 numeric 欄位
+func (p Points) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+func (p *Points) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = Points{}
+	case int64:
+		*p = Points{hundredths: v * unit}
+	case float64:
+		*p = FromFloat(v)
+	case []byte:
+		return p.scanString(string(v))
+	case string:
+		return p.scanString(v)
+	default:
+		return fmt.Errorf("points: cannot scan %T", src)
+	}
+	return nil
+}
+
+func (p *Points) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
@@ -0,0 +1,137 @@
+package points
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hundredths(n int64) Points {
+	return Points{hundredths: n}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Points
+		wantErr bool
+	}{
+		{name: "integer", input: "12", want: hundredths(1200)},
+		{name: "half point", input: "0.5", want: hundredths(50)},
+		{name: "two decimals", input: "3.25", want: hundredths(325)},
+		{name: "negative", input: "-1.5", want: hundredths(-150)},
+		{name: "leading dot", input: ".5", want: hundredths(50)},
+		{name: "trailing dot", input: "7.", want: hundredths(700)},
+		{name: "round half up", input: "0.125", want: hundredths(13)},
+		{name: "round half away from zero", input: "-0.125", want: hundredths(-13)},
+		{name: "round down", input: "0.124999", want: hundredths(12)},
+		{name: "exponent", input: "1e2", want: hundredths(10000)},
+		{name: "empty", input: "", wantErr: true},
+		{name: "dot only", input: ".", wantErr: true},
+		{name: "invalid", input: "1.2.3", wantErr: true},
+		{name: "out of range", input: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input Points
+		want  string
+	}{
+		{input: hundredths(0), want: "0"},
+		{input: hundredths(1200), want: "12"},
+		{input: hundredths(1250), want: "12.5"},
+		{input: hundredths(5), want: "0.05"},
+		{input: hundredths(-25), want: "-0.25"},
+		{input: hundredths(-1200), want: "-12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.input.String())
+		})
+	}
+}
+
+// 浮點數加總 0.1 十次不等於 1，定點數加總必須完全相等
+func TestSumIsExact(t *testing.T) {
+	var floatSum float64
+	var sum Points
+	for i := 0; i < 10; i++ {
+		floatSum += 0.1
+		p, err := Parse("0.1")
+		assert.NoError(t, err)
+		sum = sum.Add(p)
+	}
+
+	assert.NotEqual(t, 1.0, floatSum)
+	assert.Equal(t, FromInt(1), sum)
+	assert.Equal(t, "1", sum.String())
+}
+
+func TestFromFloat(t *testing.T) {
+	assert.Equal(t, hundredths(30), FromFloat(0.1+0.2))
+	assert.Equal(t, hundredths(-13), FromFloat(-0.125))
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Points Points `json:"points"`
+	}{Points: hundredths(1250)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"points":12.5}`, string(data))
+
+	var got struct {
+		A Points `json:"a"`
+		B Points `json:"b"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":0.5,"b":"-3.25"}`), &got))
+	assert.Equal(t, hundredths(50), got.A)
+	assert.Equal(t, hundredths(-325), got.B)
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  Points
+	}{
+		{name: "nil", input: nil, want: hundredths(0)},
+		{name: "int64", input: int64(3), want: hundredths(300)},
+		{name: "float64", input: 0.30000000000000004, want: hundredths(30)},
+		{name: "numeric text", input: []byte("12.50"), want: hundredths(1250)},
+		{name: "string", input: "-1.5", want: hundredths(-150)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Points
+			assert.NoError(t, got.Scan(tt.input))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := FromInt(3), hundredths(50)
+
+	assert.Equal(t, hundredths(350), a.Add(b))
+	assert.Equal(t, hundredths(250), a.Sub(b))
+	assert.Equal(t, hundredths(-50), b.Neg())
+	assert.Equal(t, 1, a.Cmp(b))
+	assert.Equal(t, -1, b.Cmp(a))
+	assert.Equal(t, 0, a.Cmp(FromInt(3)))
+	assert.True(t, Points{}.IsZero())
+	assert.Equal(t, 3.5, a.Add(b).Float64())
+}
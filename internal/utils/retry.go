@@ -0,0 +1,20 @@
+package utils
+
+import "errors"
+
+// RetryOnError do 回傳的錯誤為 target (errors.Is) 時重新執行，最多執行 maxAttempts 次，attempt 從 1 開始
+func RetryOnError(maxAttempts int, target error, do func(attempt int) error) error {
+	if maxAttempts < 1 {
+		return errors.New("invalid maxAttempts")
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = do(attempt)
+		if err == nil || !errors.Is(err, target) {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryOnError(t *testing.T) {
+	retryableErr := errors.New("retryable")
+	fatalErr := errors.New("fatal")
+
+	tests := []struct {
+		name         string
+		results      []error
+		maxAttempts  int
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "success first attempt", results: []error{nil}, maxAttempts: 3, wantErr: nil, wantAttempts: 1},
+		{name: "success after retry", results: []error{retryableErr, retryableErr, nil}, maxAttempts: 3, wantErr: nil, wantAttempts: 3},
+		{name: "give up after max attempts", results: []error{retryableErr, retryableErr, retryableErr}, maxAttempts: 3, wantErr: retryableErr, wantAttempts: 3},
+		{name: "not retryable", results: []error{fatalErr}, maxAttempts: 3, wantErr: fatalErr, wantAttempts: 1},
+		{name: "wrapped target", results: []error{fmt.Errorf("wrap: %w", retryableErr), nil}, maxAttempts: 3, wantErr: nil, wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := RetryOnError(tt.maxAttempts, retryableErr, func(attempt int) error {
+				attempts++
+				assert.Equal(t, attempts, attempt)
+				return tt.results[attempt-1]
+			})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS webhook_events
+(
+    event_id        BIGINT       NOT NULL PRIMARY KEY,
+    source          VARCHAR(50)  NOT NULL,
+    webhook_id      VARCHAR(100) NOT NULL DEFAULT '',
+    webhook_type    VARCHAR(50)  NOT NULL DEFAULT '',
+    app_id          VARCHAR(20)  NOT NULL DEFAULT '',
+    record_id       VARCHAR(20)  NOT NULL DEFAULT '',
+    payload         JSONB        NOT NULL,
+    status          VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    attempts        INT          NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    locked_at       TIMESTAMPTZ,
+    last_error      TEXT         NOT NULL DEFAULT '',
+    processed_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status_next_attempt_at ON webhook_events (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_source ON webhook_events (source);
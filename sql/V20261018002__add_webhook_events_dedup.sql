@@ -0,0 +1,4 @@
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS record_revision INT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_webhook_events_webhook_id ON webhook_events (webhook_id) WHERE webhook_id <> '';
+CREATE INDEX IF NOT EXISTS idx_webhook_events_source_record_id ON webhook_events (source, record_id, event_id);
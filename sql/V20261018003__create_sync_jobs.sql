@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS sync_jobs
+(
+    job_id        BIGINT      NOT NULL PRIMARY KEY,
+    job_type      VARCHAR(50) NOT NULL,
+    cond          JSONB       NOT NULL DEFAULT '{}',
+    status        VARCHAR(20) NOT NULL DEFAULT 'queued',
+    total_count   INT         NOT NULL DEFAULT 0,
+    created_count INT         NOT NULL DEFAULT 0,
+    updated_count INT         NOT NULL DEFAULT 0,
+    deleted_count INT         NOT NULL DEFAULT 0,
+    skipped_count INT         NOT NULL DEFAULT 0,
+    failed_count  INT         NOT NULL DEFAULT 0,
+    failures      JSONB       NOT NULL DEFAULT '[]',
+    error         TEXT        NOT NULL DEFAULT '',
+    started_at    TIMESTAMPTZ,
+    finished_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_job_type_status ON sync_jobs (job_type, status);
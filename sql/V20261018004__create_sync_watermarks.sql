@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS sync_watermarks
+(
+    job_type   VARCHAR(50) NOT NULL PRIMARY KEY,
+    watermark  TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
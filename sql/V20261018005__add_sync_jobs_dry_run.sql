@@ -0,0 +1,4 @@
+ALTER TABLE sync_jobs
+    ADD COLUMN IF NOT EXISTS dry_run         BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS unchanged_count INT     NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS diff            JSONB;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS semesters
+(
+    semester_id BIGINT      NOT NULL PRIMARY KEY,
+    name        VARCHAR(50) NOT NULL,
+    start_date  DATE        NOT NULL,
+    end_date    DATE        NOT NULL,
+    settle_date DATE        NOT NULL,
+    time_zone   VARCHAR(50) NOT NULL DEFAULT 'Asia/Taipei',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_semesters_name ON semesters (name);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_semesters_settle_date ON semesters (settle_date);
+
+-- 原本寫死在程式裡的 03/01、09/01 結算日
+INSERT INTO semesters (semester_id, name, start_date, end_date, settle_date)
+VALUES (1, '2026 秋季', '2026-09-01', '2027-02-28', '2027-03-01'),
+       (2, '2027 春季', '2027-03-01', '2027-08-31', '2027-09-01')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS settlement_runs
+(
+    run_id      BIGINT      NOT NULL PRIMARY KEY,
+    semester_id BIGINT      NOT NULL,
+    start_time  TIMESTAMPTZ NOT NULL,
+    end_time    TIMESTAMPTZ NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total_count INT         NOT NULL DEFAULT 0,
+    error       TEXT        NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 同一個學期只會結算一次，重新執行時沿用原本的結算清單
+CREATE UNIQUE INDEX IF NOT EXISTS uk_settlement_runs_semester_id ON settlement_runs (semester_id);
+
+CREATE TABLE IF NOT EXISTS settlement_run_items
+(
+    run_id               BIGINT           NOT NULL,
+    student_id           BIGINT           NOT NULL,
+    kintone_student_name VARCHAR(100)     NOT NULL,
+    clear_points         DOUBLE PRECISION NOT NULL DEFAULT 0,
+    step                 VARCHAR(30)      NOT NULL DEFAULT 'pending',
+    settle_record_ref_id INT,
+    rest_points_after    DOUBLE PRECISION,
+    created_at           TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, student_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_run_items_run_id_step ON settlement_run_items (run_id, step);
@@ -0,0 +1,20 @@
+-- student_id 為空值時代表撤銷整個學期的結算
+CREATE TABLE IF NOT EXISTS settlement_reversals
+(
+    reversal_id    BIGINT       NOT NULL PRIMARY KEY,
+    run_id         BIGINT       NOT NULL,
+    student_id     BIGINT,
+    operator       VARCHAR(100) NOT NULL,
+    reason         TEXT         NOT NULL,
+    status         VARCHAR(20)  NOT NULL DEFAULT 'running',
+    reversed_count INT          NOT NULL DEFAULT 0,
+    error          TEXT         NOT NULL DEFAULT '',
+    finished_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_reversals_run_id ON settlement_reversals (run_id);
+
+-- 撤銷該學生結算的 reversal_id
+ALTER TABLE settlement_run_items ADD COLUMN IF NOT EXISTS reversal_id BIGINT;
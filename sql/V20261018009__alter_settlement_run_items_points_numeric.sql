@@ -0,0 +1,4 @@
+-- 點數改用定點數，保留兩位小數，避免浮點數誤差
+ALTER TABLE settlement_run_items
+    ALTER COLUMN clear_points TYPE NUMERIC(12, 2),
+    ALTER COLUMN rest_points_after TYPE NUMERIC(12, 2);
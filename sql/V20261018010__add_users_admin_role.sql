@@ -0,0 +1,7 @@
+-- 管理者帳號 (level = 'admin') 的角色，一般使用者為 null
+CREATE TYPE admin_role AS ENUM ('viewer', 'operator', 'finance', 'manager');
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role admin_role;
+
+-- 第一個管理者帳號需手動指定，之後由 manager 透過 /_internal/admin_user 建立其他管理者
+-- e.g. UPDATE users SET level = 'admin', role = 'manager' WHERE account = 'xxx';
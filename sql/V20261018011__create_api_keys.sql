@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    key_id       BIGINT       NOT NULL PRIMARY KEY,
+    name         VARCHAR(100) NOT NULL,
+    -- key 的開頭字元，只用來辨識，不能用來驗證
+    key_prefix   VARCHAR(20)  NOT NULL,
+    -- key 的 sha256，原始的 key 只在建立時回傳一次
+    key_hash     VARCHAR(64)  NOT NULL,
+    scopes       JSONB        NOT NULL DEFAULT '[]',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_by   VARCHAR(100) NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_api_keys_key_hash ON api_keys (key_hash);
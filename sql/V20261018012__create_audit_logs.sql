@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS audit_logs
+(
+    log_id      BIGINT       NOT NULL PRIMARY KEY,
+    -- 管理者帳號、api_key:<名稱>、webhook_event:<event_id> 或 system
+    actor       VARCHAR(150) NOT NULL,
+    action      VARCHAR(50)  NOT NULL,
+    target_type VARCHAR(50)  NOT NULL,
+    target_id   BIGINT       NOT NULL DEFAULT 0,
+    before      JSONB,
+    after       JSONB,
+    -- 與 log 的 action id 相同，可以用來查詢同一個請求的 log
+    request_id  VARCHAR(64)  NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+-- 稽核記錄只能新增，不能修改或刪除
+CREATE OR REPLACE FUNCTION reject_audit_logs_change() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_logs_append_only ON audit_logs;
+CREATE TRIGGER trg_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW
+EXECUTE FUNCTION reject_audit_logs_change();
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS login_sessions
+(
+    session_id     BIGINT       NOT NULL PRIMARY KEY,
+    -- cookie 中 token 的 sha256，原始的 token 不保存
+    token_hash     VARCHAR(64)  NOT NULL,
+    user_id        BIGINT       NOT NULL,
+    -- session 的內容，以 session key 簽章加密
+    data           TEXT         NOT NULL,
+    user_agent     VARCHAR(512) NOT NULL DEFAULT '',
+    ip             VARCHAR(64)  NOT NULL DEFAULT '',
+    expires_at     TIMESTAMPTZ  NOT NULL,
+    last_seen_at   TIMESTAMPTZ  NOT NULL,
+    revoked_at     TIMESTAMPTZ,
+    revoked_reason VARCHAR(32)  NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_login_sessions_token_hash ON login_sessions (token_hash);
+CREATE INDEX IF NOT EXISTS idx_login_sessions_user_id ON login_sessions (user_id, revoked_at);
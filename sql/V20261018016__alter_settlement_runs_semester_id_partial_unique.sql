@@ -0,0 +1,3 @@
+-- 已撤銷的結算不佔用學期，修正資料後可以重新結算
+DROP INDEX IF EXISTS uk_settlement_runs_semester_id;
+CREATE UNIQUE INDEX IF NOT EXISTS uk_settlement_runs_semester_id ON settlement_runs (semester_id) WHERE status <> 'reversed';
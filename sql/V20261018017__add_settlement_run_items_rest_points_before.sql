@@ -0,0 +1,23 @@
+-- 建立結算清單時的剩餘點數，扣點與撤銷都以此計算，不受之後同步點數卡影響
+ALTER TABLE settlement_run_items ADD COLUMN IF NOT EXISTS rest_points_before NUMERIC(12, 2);
+
+-- 已扣點的學生可以由扣點後的點數推回，其餘以目前的點數卡補上
+UPDATE settlement_run_items
+SET rest_points_before = rest_points_after + clear_points
+WHERE rest_points_before IS NULL
+  AND rest_points_after IS NOT NULL;
+
+UPDATE settlement_run_items AS sri
+SET rest_points_before = pc.rest_points
+FROM point_card AS pc
+WHERE sri.rest_points_before IS NULL
+  AND pc.student_id = sri.student_id
+  AND pc.is_deleted = false;
+
+UPDATE settlement_run_items
+SET rest_points_before = 0
+WHERE rest_points_before IS NULL;
+
+ALTER TABLE settlement_run_items
+    ALTER COLUMN rest_points_before SET DEFAULT 0,
+    ALTER COLUMN rest_points_before SET NOT NULL;
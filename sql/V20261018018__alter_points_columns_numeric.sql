@@ -0,0 +1,9 @@
+-- 點數欄位與 settlement_run_items 相同改用定點數，保留兩位小數，加總時不需再轉型
+ALTER TABLE point_card
+    ALTER COLUMN rest_points TYPE NUMERIC(12, 2);
+
+ALTER TABLE reduce_point_records
+    ALTER COLUMN reduce_points TYPE NUMERIC(12, 2);
+
+ALTER TABLE semester_settle_records
+    ALTER COLUMN clear_points TYPE NUMERIC(12, 2);